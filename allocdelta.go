@@ -0,0 +1,103 @@
+package autopprof
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/google/pprof/profile"
+)
+
+// allocTracker keeps the baseline of the cumulative allocations at
+// the last heap report, so the next one tells the allocation churn
+// since then. The baseline is reset by each report. A nil allocTracker
+// tracks nothing.
+type allocTracker struct {
+	mu sync.Mutex
+	// totalAlloc is the runtime.MemStats.TotalAlloc at the last report.
+	totalAlloc uint64
+	// allocs is the allocs profile at the last report. It's nil until
+	//  the first one with the Option.ReportAllocsDiff.
+	allocs []byte
+}
+
+func newAllocTracker() *allocTracker {
+	return &allocTracker{}
+}
+
+// since returns the bytes allocated since the last report, or since
+// the start for the first one, and resets the baseline to the total.
+func (t *allocTracker) since(totalAlloc uint64) uint64 {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delta := totalAlloc - t.totalAlloc
+	if totalAlloc < t.totalAlloc {
+		// It never decreases, but don't wrap around.
+		delta = 0
+	}
+	t.totalAlloc = totalAlloc
+	return delta
+}
+
+// diff returns the allocs profile of the allocations since the last
+// report, and resets the baseline to the allocs. The first one is
+// the allocs as is, since the profile is cumulative from the start.
+func (t *allocTracker) diff(allocs []byte) ([]byte, error) {
+	if t == nil {
+		return allocs, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev := t.allocs
+	t.allocs = allocs
+	if prev == nil {
+		return allocs, nil
+	}
+	return diffProfile(prev, allocs)
+}
+
+// diffProfile returns the profile of the cur minus the base, like
+// the -diff_base of the go tool pprof. The samples unchanged since
+// the base are dropped.
+func diffProfile(base, cur []byte) ([]byte, error) {
+	bp, err := profile.Parse(bytes.NewReader(base))
+	if err != nil {
+		return nil, err
+	}
+	cp, err := profile.Parse(bytes.NewReader(cur))
+	if err != nil {
+		return nil, err
+	}
+	return diffParsedProfile(bp, cp)
+}
+
+// diffParsedProfile returns the profile of the cp minus the bp.
+// (See diffProfile) The bp is negated.
+func diffParsedProfile(bp, cp *profile.Profile) ([]byte, error) {
+	bp.Scale(-1)
+	p, err := profile.Merge([]*profile.Profile{cp, bp})
+	if err != nil {
+		return nil, err
+	}
+
+	samples := p.Sample[:0]
+	for _, s := range p.Sample {
+		for _, v := range s.Value {
+			if v != 0 {
+				samples = append(samples, s)
+				break
+			}
+		}
+	}
+	p.Sample = samples
+
+	var buf bytes.Buffer
+	if err := p.Compact().Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
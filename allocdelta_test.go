@@ -0,0 +1,97 @@
+package autopprof
+
+import (
+	"bytes"
+	"runtime"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+func TestAllocTracker_since(t *testing.T) {
+	// A nil allocTracker tracks nothing.
+	var nilTracker *allocTracker
+	if got := nilTracker.since(100); got != 0 {
+		t.Errorf("since() of nil = %d, want 0", got)
+	}
+
+	tracker := newAllocTracker()
+	testCases := []struct {
+		totalAlloc uint64
+		want       uint64
+	}{
+		{totalAlloc: 100, want: 100}, // Since the start.
+		{totalAlloc: 250, want: 150},
+		{totalAlloc: 250, want: 0},
+		{totalAlloc: 200, want: 0}, // Doesn't wrap around.
+		{totalAlloc: 300, want: 100},
+	}
+	for i, tc := range testCases {
+		if got := tracker.since(tc.totalAlloc); got != tc.want {
+			t.Errorf("#%d since(%d) = %d, want %d", i, tc.totalAlloc, got, tc.want)
+		}
+	}
+}
+
+var allocSink [][]byte
+
+func TestAllocTracker_diff(t *testing.T) {
+	prevRate := runtime.MemProfileRate
+	runtime.MemProfileRate = 1
+	defer func() { runtime.MemProfileRate = prevRate }()
+
+	allocs := func() []byte {
+		// The allocs profile is as of the last gc.
+		runtime.GC()
+		var buf bytes.Buffer
+		if err := pprof.Lookup("allocs").WriteTo(&buf, 0); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	allocSpace := func(b []byte) int64 {
+		p, err := profile.Parse(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("profile.Parse() = %v, want nil", err)
+		}
+		var idx int
+		for i, st := range p.SampleType {
+			if st.Type == "alloc_space" {
+				idx = i
+			}
+		}
+		var total int64
+		for _, s := range p.Sample {
+			total += s.Value[idx]
+		}
+		return total
+	}
+
+	tracker := newAllocTracker()
+	first := allocs()
+	got, err := tracker.diff(first)
+	if err != nil {
+		t.Fatalf("diff() = %v, want nil", err)
+	}
+	if !bytes.Equal(got, first) {
+		t.Errorf("diff() of the first = the diff, want the allocs as is")
+	}
+
+	for i := 0; i < 64; i++ {
+		allocSink = append(allocSink, make([]byte, 64<<10))
+	}
+	allocSink = nil
+	second := allocs()
+	got, err = tracker.diff(second)
+	if err != nil {
+		t.Fatalf("diff() = %v, want nil", err)
+	}
+	delta := allocSpace(got)
+	if delta < 64*64<<10 {
+		t.Errorf("alloc_space of the diff = %d, want >= %d", delta, 64*64<<10)
+	}
+	if want := allocSpace(second) - allocSpace(first); delta != want {
+		t.Errorf("alloc_space of the diff = %d, want %d", delta, want)
+	}
+}
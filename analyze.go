@@ -0,0 +1,25 @@
+package autopprof
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/pprof/profile"
+)
+
+// analyzeProfile parses the profile once and runs the analyze with it.
+// The profile is reported anyway if it fails to parse, so the error is
+// only logged.
+func analyzeProfile(b []byte, analyze func(*profile.Profile)) {
+	if analyze == nil {
+		return
+	}
+	p, err := profile.Parse(bytes.NewReader(b))
+	if err != nil {
+		logger().Println(fmt.Errorf(
+			"autopprof: failed to parse the profile to analyze: %w", err,
+		))
+		return
+	}
+	analyze(p)
+}
@@ -0,0 +1,30 @@
+package autopprof
+
+import (
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+func TestAnalyzeProfile(t *testing.T) {
+	b, err := newDefaultProfiler(defaultCPUProfilingDuration).profileHeap()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var analyzed *profile.Profile
+	analyzeProfile(b, func(p *profile.Profile) { analyzed = p })
+	if analyzed == nil || len(analyzed.SampleType) == 0 {
+		t.Errorf("analyzeProfile() didn't run the analyze with the parsed profile")
+	}
+
+	// The corrupted profile isn't analyzed.
+	analyzed = nil
+	analyzeProfile(b[:len(b)/2], func(p *profile.Profile) { analyzed = p })
+	if analyzed != nil {
+		t.Errorf("analyzeProfile() ran the analyze with the corrupted profile")
+	}
+
+	// Nothing to run.
+	analyzeProfile(b, nil)
+}
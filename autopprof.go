@@ -6,22 +6,50 @@ package autopprof
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/looko-corp/autopprof/report"
-)
+	"github.com/google/pprof/profile"
 
-const (
-	reportTimeout = 5 * time.Second
+	"github.com/looko-corp/autopprof/report"
 )
 
 type autoPprof struct {
+	// sequence is the last capture sequence number. It's the first
+	//  field to be 64-bit aligned for the atomic operations.
+	sequence uint64
+	// panics is the number of the recovered panics. It's 64-bit aligned
+	//  next to the sequence.
+	panics uint64
+
+	// recoverPanics recovers the panics of the goroutines of
+	//  the autopprof, and restartOnPanic restarts the panicked
+	//  watchers.
+	recoverPanics  bool
+	restartOnPanic bool
+
 	// watchInterval is the interval to watch the resource usages.
 	// Default: 5s.
 	watchInterval time.Duration
 
+	// sampleInterval is the interval to sample the cpu usage between
+	//  the watches.
+	// Default: 0. (means the watchInterval)
+	sampleInterval time.Duration
+
+	// cpuQuotaRefreshInterval is the interval to re-read the cpu quota.
+	// cpuQuotaRefreshedAt is when the cpu quota was read last. It's
+	//  touched by the cpu watcher only.
+	cpuQuotaRefreshInterval time.Duration
+	cpuQuotaRefreshedAt     time.Time
+
 	// cpuThreshold is the cpu usage threshold to trigger profile.
 	// If the cpu usage is over the threshold, the autopprof will
 	//  report the cpu profile.
@@ -34,30 +62,336 @@ type autoPprof struct {
 	// Default: 0.75. (mean 75%)
 	memThreshold float64
 
+	// cpuWarnThreshold and memWarnThreshold are the usage thresholds
+	//  to emit the warning events without the profiling.
+	// Default: 0. (means disabled)
+	cpuWarnThreshold float64
+	memWarnThreshold float64
+
+	// warnCooldown is the minimum duration between the warning events
+	//  of the same type.
+	// Default: 0. (means minConsecutiveOverThreshold * watchInterval)
+	warnCooldown time.Duration
+
+	// labels are the labels of the reports.
+	// Default: nil.
+	labels map[string]string
+
+	// onEvent is called with the events such as the warnings.
+	onEvent func(Event)
+
+	// triggerFile watches the trigger files touched by the operator.
+	//  It's nil unless the TriggerFilePath is set.
+	triggerFile *triggerFileWatcher
+
+	// analyzeCPU and analyzeHeap are called with the parsed profiles
+	//  before the reporting.
+	// Default: nil.
+	analyzeCPU  func(*profile.Profile)
+	analyzeHeap func(*profile.Profile)
+
+	// fdThreshold is the file descriptor usage threshold to trigger
+	//  the goroutine profile.
+	// Default: 0. (means disabled)
+	fdThreshold float64
+
+	// includeKernelMemory adds the kmem to the memory usage.
+	includeKernelMemory bool
+
+	// memUsageComponents is the memory fields summed into the memory
+	//  usage. It's disabled by the memFieldsUnavailable if the queryer
+	//  doesn't read the memory fields.
+	// Default: 0. (means the working set)
+	memUsageComponents MemUsageComponents
+	// memFieldsUnavailable is set to 1 by the memory watcher once
+	//  the memory fields are unavailable. It's accessed atomically,
+	//  since the reports read it concurrently.
+	memFieldsUnavailable uint32
+
+	// livenessInterval is the interval to send the liveness marker.
+	// Default: 0. (means disabled)
+	livenessInterval time.Duration
+
+	// fdUsage returns the file descriptor usage.
+	fdUsage func() (float64, error)
+
+	// pidThreshold is the usage threshold of the pids limit of
+	//  the cgroup to trigger the goroutine profile.
+	// Default: 0. (means disabled)
+	pidThreshold float64
+	// pidUsage returns the number of the tasks of the cgroup and its
+	//  limit.
+	pidUsage func() (pidStat, error)
+
+	// goroutineDropThreshold is the ratio of the goroutines gone
+	//  between the watches to trigger the goroutine profile, and
+	//  goroutineDropMinCount is the minimum number of them.
+	// Default: 0. (means disabled) and 100.
+	goroutineDropThreshold float64
+	goroutineDropMinCount  int
+
+	// goroutineThreshold is the number of the goroutines to trigger
+	//  the goroutine profile.
+	// Default: 0. (means disabled)
+	goroutineThreshold int
+	// numGoroutine returns the number of the goroutines.
+	numGoroutine func() int
+
+	// memLimitMode is the memory limit to compute the memory usage
+	//  against.
+	// Default: MemLimitCgroup.
+	memLimitMode MemLimitMode
+
+	// burstCount is the number of the captures in quick succession
+	//  after the first breach, and burstInterval is the interval
+	//  between them.
+	// Default: 0. (means a single capture)
+	burstCount    int
+	burstInterval time.Duration
+
+	// stats counts the reports for the expvar. It's nil unless
+	//  the PublishExpvar is set.
+	stats *reportStats
+
+	// watchers tracks the watchers for the HealthHandler.
+	watchers *watcherHealth
+
+	// latencyDebounce limits the captures of the NotifyLatencyBreach.
+	latencyDebounce *debounce
+
+	// errorRateThreshold is the rate of the errors recorded by
+	//  the RecordError to trigger the profiling, and errors counts them.
+	// Default: 0. (means disabled)
+	errorRateThreshold float64
+	errors             *errorRate
+
+	// enableWallClock captures the wall-clock profile along with
+	//  the captures triggered by the application.
+	enableWallClock bool
+
+	// captureOnStartup captures the cpu and heap profiles once after
+	//  the startupCaptureDelay since the start.
+	captureOnStartup    bool
+	startupCaptureDelay time.Duration
+
+	// incidents groups the breaches into the incidents.
+	incidents *incident
+
+	// limits are the cgroup limits in effect to report with
+	//  the profiles.
+	limits *cgroupLimits
+
+	// capabilities are probed once at the start for the HealthHandler.
+	capabilities Capabilities
+
+	// cpuFilter and memFilter smooth the usages before the comparison
+	//  with the thresholds.
+	cpuFilter *usageFilter
+	memFilter *usageFilter
+
+	// usagePercentile is the percentile of the usages per tick over
+	//  the window compared with the thresholds, and memWindow keeps
+	//  the memory usages of the window. The cpu usages are derived from
+	//  the snapshots of the queryer.
+	// Default: 0. (means the average cpu usage over the window and
+	//  the instantaneous memory usage)
+	usagePercentile float64
+	memWindow       *usageWindow
+
+	// cpuBaseline raises the cpu threshold to the learned usage of
+	//  the time-of-day.
+	cpuBaseline *usageBaseline
+
+	// captureSequence sets the sequence numbers and the elapsed times
+	//  of the reports.
+	captureSequence bool
+	// startedAt is when the autopprof started. It carries the monotonic
+	//  clock reading.
+	startedAt time.Time
+
+	// cpuUsageBasis is the denominator to compute the cpu usage against.
+	// Default: CPUUsageQuota.
+	cpuUsageBasis CPUUsageBasis
+
+	// cpuPressureThreshold is the cpu pressure threshold to trigger
+	//  the cpu profile. It's disabled by the psiUnavailable if
+	//  the pressure stall information is unavailable.
+	// Default: 0. (means disabled)
+	cpuPressureThreshold float64
+	// psiUnavailable is set to 1 by the cpu watcher once the pressure
+	//  stall information is unavailable. It's accessed atomically,
+	//  since the reports read it concurrently.
+	psiUnavailable uint32
+
+	// gcRateThreshold and gcPauseThreshold are the thresholds of
+	//  the gc cycles per second and the 99th percentile of the gc pauses
+	//  to trigger the heap profile.
+	// Default: 0. (means disabled)
+	gcRateThreshold  float64
+	gcPauseThreshold time.Duration
+
+	// readMemStats reads the runtime.MemStats.
+	readMemStats func(*runtime.MemStats)
+
+	// memMinAvailableBytes is the minimum available memory bytes.
+	// If the available memory is lower than this, the autopprof will
+	//  report the heap profile regardless of the memThreshold.
+	// Default: 0. (means disabled)
+	memMinAvailableBytes uint64
+
+	// memAbsoluteThreshold and memGrowthThreshold trigger the heap
+	//  profiling when no memory limit is detected.
+	// Default: 0. (means the growth) and 0.5.
+	memAbsoluteThreshold uint64
+	memGrowthThreshold   float64
+
+	// numaThreshold is the memory usage threshold of a NUMA node to
+	//  trigger the heap profiling. It's disabled by the numaUnavailable
+	//  if the NUMA stat is unavailable.
+	// Default: 0. (means disabled)
+	numaThreshold float64
+	// numaUnavailable is set to 1 by the memory watcher once the NUMA
+	//  stat is unavailable. It's accessed atomically, since the reports
+	//  read it concurrently.
+	numaUnavailable uint32
+
+	// memRequest is the memory request in bytes to compute the memory
+	//  usage against, and memRequestThreshold is the threshold of
+	//  the usage against it to trigger the heap profiling.
+	// Default: 0. (means no request) and 0. (means disabled)
+	memRequest          uint64
+	memRequestThreshold float64
+
 	// minConsecutiveOverThreshold is the minimum consecutive
 	// number of over a threshold for reporting profile again.
 	// Default: 12.
 	minConsecutiveOverThreshold int
 
+	// cpuTopN is the number of the top functions to report with
+	//  the cpu profile.
+	// Default: 0. (means disabled)
+	cpuTopN int
+
+	// cpuProfileLabels are the pprof labels to scope the cpu profile to.
+	cpuProfileLabels map[string]string
+
+	// cpuProfilingDuration is the duration of the cpu profiling.
+	// Default: 10s.
+	cpuProfilingDuration time.Duration
+
 	// queryer is used to query the quota and the cgroup stat.
 	queryer queryer
 
 	// profiler is used to profile the cpu and the heap memory.
 	profiler profiler
+	// verifier verifies the profiles of the profiler. It's nil unless
+	//  the VerifyProfiles is set.
+	verifier *verifyingProfiler
 
 	// reporter is the reporter to send the profiling reports.
 	reporter report.Reporter
 
+	// reportTimeout is the timeout of the reporting unless the reporter
+	//  advertises its own timeout.
+	// Default: 5s.
+	reportTimeout time.Duration
+
+	// state persists the state of the reporting across the restarts.
+	state *stateStore
+
+	// cpuBudget caps the cumulative cpu profiling time.
+	// Nil means unlimited.
+	cpuBudget *profilingBudget
+
+	// breaker stops calling the reporter for a while if the reporter
+	//  fails consecutively.
+	breaker *circuitBreaker
+
+	// fullHeapCapture reports the inuse_space, inuse_objects and
+	//  alloc_space views of the heap profile with a shared trigger id.
+	fullHeapCapture bool
+
+	// heapPrimarySampleType is the sample type of the heap profile
+	//  the backend should show by default.
+	heapPrimarySampleType string
+
+	// heapSampleReduction is the share of the samples dropped from
+	//  the heap profile.
+	heapSampleReduction float64
+
+	// heapThrottle throttles the heap profiling of the huge heap.
+	heapThrottle *heapThrottle
+
+	// heapGoroutineDump reports the human-readable goroutine dump with
+	//  the heap profile.
+	heapGoroutineDump bool
+	// cpuCrossingDump reports the goroutine dump captured when the cpu
+	//  usage crosses the threshold.
+	cpuCrossingDump bool
+
+	// allocs tracks the allocations since the last heap report, and
+	//  reportAllocsDiff reports the allocs profile of them.
+	allocs           *allocTracker
+	reportAllocsDiff bool
+
+	// cpuDiff tracks the last cpu profile to report the difference from
+	//  it. It's nil unless the ReportCPUDiff is set.
+	cpuDiff *cpuDiffTracker
+
+	// prevMemProfileRate is the runtime.MemProfileRate before the Start,
+	//  restored at the Stop. Zero means the rate isn't changed.
+	prevMemProfileRate int
+
+	// blockProfileRate and mutexProfileFraction are the rates of
+	//  the block and the mutex profiling set at the Start.
+	// Default: 0. (means disabled)
+	blockProfileRate     int
+	mutexProfileFraction int
+	// prevBlockProfileRate and prevMutexProfileFraction are the rates
+	//  before the Start, restored at the Stop.
+	prevBlockProfileRate     int
+	prevMutexProfileFraction int
+
+	// wal is the write-ahead log wrapping the reporter. It's nil unless
+	//  the WALPath is set.
+	wal *report.WALReporter
+
+	// reportSem limits the number of the concurrent reports.
+	// Nil means unlimited.
+	reportSem chan struct{}
+
+	// severityCooldowns are the cooldowns of the usage bands.
+	// Nil means the fixed cooldown.
+	severityCooldowns []SeverityCooldown
+
+	// edgeTriggered reports only once per crossing of the threshold.
+	edgeTriggered bool
+	// emitRecoveryEvents emits the recovery events when the usage
+	//  drops back below the threshold in the edge-triggered mode.
+	emitRecoveryEvents bool
+
 	// reportBoth sets whether to trigger reports for both CPU and memory when either threshold is exceeded.
 	// If some profiling is disabled, exclude it.
 	reportBoth bool
 
-	// Flags to disable the profiling.
+	// Flags to disable the profiling. They're switched at runtime by
+	//  the EnableCPUProfiling and the EnableMemProfiling, so read them
+	//  by the cpuProfDisabled and the memProfDisabled.
 	disableCPUProf bool
 	disableMemProf bool
+	// disableGoroutineProf disables the goroutine profiling of
+	//  the watchers.
+	disableGoroutineProf bool
+	// profMu guards the flags, and profChanged is closed on their
+	//  changes to wake up the watchers waiting for the enable.
+	profMu      sync.RWMutex
+	profChanged chan struct{}
 
 	// stopC is the signal channel to stop the watch processes.
 	stopC chan struct{}
+	// stopOnce stops once by either the Stop or the context of
+	//  the StartWithContext.
+	stopOnce sync.Once
 }
 
 // globalAp is the global autopprof instance.
@@ -65,49 +399,315 @@ var globalAp *autoPprof
 
 // Start configures and runs the autopprof process.
 func Start(opt Option) error {
-	qryer, err := newQueryer()
+	return StartWithContext(context.Background(), opt)
+}
+
+// StartWithContext configures and runs the autopprof process until
+// the ctx is done or the Stop is called. The ctx only bounds
+// the lifetime of the watchers, not the Start itself.
+func StartWithContext(ctx context.Context, opt Option) (err error) {
+	qryer, err := newQueryer(opt.ContainerCgroupPath)
 	if err != nil {
 		return err
 	}
 	if err := opt.validate(); err != nil {
 		return err
 	}
+	// Log the rest of the Start to the Logger, but don't leave it
+	//  replaced by the failed Start.
+	prevLogger := logger()
+	setLogger(opt.Logger)
+	defer func() {
+		if err != nil {
+			setLogger(prevLogger)
+		}
+	}()
 
+	if cgv1, ok := qryer.(*cgroupV1); ok && opt.CPUAcctCgroupPath != "" {
+		cgv1.cpuacctPath = opt.CPUAcctCgroupPath
+	}
+	if cgv1, ok := qryer.(*cgroupV1); ok && opt.CPUSubsystemPath != "" {
+		cgv1.setCPUSubsystem(opt.CPUSubsystemPath)
+	}
 	if opt.UseAWSFargate {
 		qryer = newAWSFargate(opt.VCPUSize)
 	}
+	if opt.OCISpecPath != "" {
+		qryer, err = newOCISpecQueryer(qryer, opt.OCISpecPath)
+		if err != nil {
+			return err
+		}
+	}
+	qryer = newTimeoutQueryer(qryer, cgroupReadTimeout)
+
+	var (
+		reporter = opt.Reporter
+		wal      *report.WALReporter
+	)
+	if opt.WALPath != "" {
+		wal, err = report.NewWALReporter(opt.Reporter, &report.WALReporterOption{
+			Path:     opt.WALPath,
+			MaxBytes: opt.WALMaxBytes,
+		})
+		if err != nil {
+			return err
+		}
+		defer func() {
+			// Don't leave the WAL open by the failure.
+			if err != nil {
+				wal.Close()
+			}
+		}()
+		reporter = wal
+	}
+	if opt.SummaryLog {
+		reporter, err = newSummaryReporter(reporter, opt.SummaryLogTemplate)
+		if err != nil {
+			return ErrInvalidSummaryLogTemplate
+		}
+	}
 
-	profr := newDefaultProfiler(defaultCPUProfilingDuration)
+	cpuProfilingDuration := defaultCPUProfilingDuration
+	if opt.CPUProfilingDuration != 0 {
+		cpuProfilingDuration = opt.CPUProfilingDuration
+	}
+	profr := newDefaultProfiler(cpuProfilingDuration)
+	profr.cpuProfileRate = opt.CPUProfileRate
+	profr.lowPriority = opt.LowPriorityCapture
+	profr.lockOSThread = opt.LockOSThread
+	var (
+		profiler profiler = profr
+		verifier *verifyingProfiler
+	)
+	if opt.VerifyProfiles {
+		verifier = newVerifyingProfiler(profr)
+		profiler = verifier
+	}
+	heapPrimarySampleType := report.SampleTypeInuseSpace
+	if opt.HeapPrimarySampleType != "" {
+		heapPrimarySampleType = opt.HeapPrimarySampleType
+	}
+	breakerThreshold := defaultReporterFailureThreshold
+	if opt.ReporterFailureThreshold != 0 {
+		breakerThreshold = opt.ReporterFailureThreshold
+	}
+	breakerCooldown := defaultReporterCooldown
+	if opt.ReporterCooldown != 0 {
+		breakerCooldown = opt.ReporterCooldown
+	}
 	ap := &autoPprof{
 		watchInterval:               defaultWatchInterval,
 		cpuThreshold:                defaultCPUThreshold,
 		memThreshold:                defaultMemThreshold,
+		cpuWarnThreshold:            opt.CPUWarnThreshold,
+		memWarnThreshold:            opt.MemWarnThreshold,
+		warnCooldown:                opt.WarnCooldown,
+		onEvent:                     opt.OnEvent,
+		recoverPanics:               opt.RecoverPanics,
+		restartOnPanic:              opt.RestartOnPanic,
+		analyzeCPU:                  opt.AnalyzeCPU,
+		analyzeHeap:                 opt.AnalyzeHeap,
+		labels:                      defaultReportLabels(opt),
+		memMinAvailableBytes:        opt.MemMinAvailableBytes,
+		memAbsoluteThreshold:        opt.MemAbsoluteThreshold,
+		numaThreshold:               opt.NUMAThreshold,
+		memRequest:                  opt.MemRequestBytes,
+		memRequestThreshold:         opt.MemRequestThreshold,
+		memGrowthThreshold:          defaultMemGrowthThreshold,
+		memLimitMode:                opt.MemLimitMode,
+		cpuUsageBasis:               opt.CPUUsageBasis,
+		cpuPressureThreshold:        opt.CPUPressureThreshold,
+		sampleInterval:              opt.SampleInterval,
+		captureSequence:             opt.CaptureSequence,
+		burstCount:                  opt.BurstCount,
+		burstInterval:               opt.BurstInterval,
+		startedAt:                   time.Now(),
+		watchers:                    newWatcherHealth(),
+		gcRateThreshold:             opt.GCRateThreshold,
+		gcPauseThreshold:            opt.GCPauseThreshold,
+		readMemStats:                runtime.ReadMemStats,
+		fdThreshold:                 opt.FDThreshold,
+		livenessInterval:            opt.LivenessInterval,
+		includeKernelMemory:         opt.IncludeKernelMemory,
+		memUsageComponents:          opt.MemUsageComponents,
+		fdUsage:                     fdUsage,
+		pidThreshold:                opt.PIDThreshold,
+		goroutineDropThreshold:      opt.GoroutineDropThreshold,
+		goroutineThreshold:          opt.GoroutineThreshold,
+		goroutineDropMinCount:       defaultGoroutineDropMinCount,
+		numGoroutine:                runtime.NumGoroutine,
 		minConsecutiveOverThreshold: defaultMinConsecutiveOverThreshold,
+		cpuProfilingDuration:        cpuProfilingDuration,
+		cpuTopN:                     opt.CPUTopN,
+		cpuProfileLabels:            opt.CPUProfileLabels,
 		queryer:                     qryer,
-		profiler:                    profr,
-		reporter:                    opt.Reporter,
+		profiler:                    profiler,
+		verifier:                    verifier,
+		reporter:                    reporter,
+		wal:                         wal,
+		reportTimeout:               opt.ReportTimeout,
+		breaker:                     newCircuitBreaker(breakerThreshold, breakerCooldown),
+		fullHeapCapture:             opt.FullHeapCapture,
+		heapPrimarySampleType:       heapPrimarySampleType,
+		heapSampleReduction:         opt.HeapSampleReduction,
+		heapThrottle:                newHeapThrottle(opt.MaxHeapProfileSize, opt.LargeHeapSampleReduction),
+		heapGoroutineDump:           opt.HeapGoroutineDump,
+		cpuCrossingDump:             opt.CPUCrossingGoroutineDump,
+		allocs:                      newAllocTracker(),
+		reportAllocsDiff:            opt.ReportAllocsDiff,
+		severityCooldowns:           opt.SeverityBasedCooldown,
+		edgeTriggered:               opt.EdgeTriggered,
+		emitRecoveryEvents:          opt.EmitRecoveryEvents,
 		reportBoth:                  opt.ReportBoth,
 		disableCPUProf:              opt.DisableCPUProf,
 		disableMemProf:              opt.DisableMemProf,
+		disableGoroutineProf:        opt.DisableGoroutineProf,
 		stopC:                       make(chan struct{}),
 	}
+	if opt.WatchInterval != 0 {
+		ap.watchInterval = opt.WatchInterval
+	}
+	if opt.MinConsecutiveOverThreshold != 0 {
+		ap.minConsecutiveOverThreshold = opt.MinConsecutiveOverThreshold
+	}
 	if opt.CPUThreshold != 0 {
 		ap.cpuThreshold = opt.CPUThreshold
 	}
+	if opt.MaxConcurrentReports != 0 {
+		ap.reportSem = make(chan struct{}, opt.MaxConcurrentReports)
+	}
+	if opt.CPUProfilingBudget != 0 {
+		ap.cpuBudget = newProfilingBudget(opt.CPUProfilingBudget)
+	}
+	if opt.StateFile != "" {
+		ap.state = loadStateStore(opt.StateFile)
+	}
 	if opt.MemThreshold != 0 {
 		ap.memThreshold = opt.MemThreshold
 	}
+	if opt.GoroutineDropMinCount != 0 {
+		ap.goroutineDropMinCount = opt.GoroutineDropMinCount
+	}
+	if opt.MemGrowthThreshold != 0 {
+		ap.memGrowthThreshold = opt.MemGrowthThreshold
+	}
+	latencyBreachDebounce := defaultLatencyBreachDebounce
+	if opt.LatencyBreachDebounce != 0 {
+		latencyBreachDebounce = opt.LatencyBreachDebounce
+	}
+	ap.latencyDebounce = newDebounce(latencyBreachDebounce)
+	ap.cpuQuotaRefreshInterval = defaultCPUQuotaRefreshInterval
+	if opt.CPUQuotaRefreshInterval != 0 {
+		ap.cpuQuotaRefreshInterval = opt.CPUQuotaRefreshInterval
+	}
+	if opt.ErrorRateThreshold != 0 {
+		errorRateWindow := defaultErrorRateWindow
+		if opt.ErrorRateWindow != 0 {
+			errorRateWindow = opt.ErrorRateWindow
+		}
+		ap.errorRateThreshold = opt.ErrorRateThreshold
+		ap.errors = newErrorRate(errorRateWindow)
+	}
+	ap.enableWallClock = opt.EnableWallClock
+	ap.captureOnStartup = opt.CaptureOnStartup
+	ap.incidents = newIncident(opt.IncidentWindow)
+	ap.limits = newCgroupLimits()
+	ap.capabilities, _ = Probe()
+	ap.cpuFilter = newUsageFilter(opt.UsageSmoothingAlpha)
+	ap.memFilter = newUsageFilter(opt.UsageSmoothingAlpha)
+	ap.usagePercentile = opt.UsagePercentile
+	ap.memWindow = newUsageWindow(opt.UsagePercentile, cpuUsageSnapshotQueueSize)
+	ap.cpuBaseline = newUsageBaseline(
+		opt.CPUBaselineDeviation, opt.CPUBaselineBucket,
+		ap.state.cpuBaseline(opt.CPUBaselineBucket),
+	)
+	if ap.cpuBaseline != nil && ap.state != nil {
+		ap.cpuBaseline.persist = func(expected []float64) {
+			if err := ap.state.saveCPUBaseline(opt.CPUBaselineBucket, expected); err != nil {
+				logger().Println(fmt.Errorf(
+					"autopprof: failed to save the state file: %w", err,
+				))
+			}
+		}
+	}
+	ap.startupCaptureDelay = opt.StartupCaptureDelay
+	if opt.PublishExpvar {
+		ap.stats = &reportStats{}
+	}
+	if opt.VerifyReporter {
+		if err := ap.verifyReporter(); err != nil {
+			return err
+		}
+	}
 	if !ap.disableCPUProf {
 		if err := ap.loadCPUQuota(); err != nil {
 			return err
 		}
+		ap.resizeCPUSnapshots()
+	}
+	if opt.ReportCPUDiff {
+		ap.cpuDiff = newCPUDiffTracker()
+	}
+	if ap.pidThreshold > 0 {
+		ap.pidUsage = newPIDUsage(pidsDir(opt.ContainerCgroupPath))
+	}
+	if opt.TriggerFilePath != "" {
+		// Watch the last, so the watch isn't leaked by the failure.
+		ap.triggerFile, err = newTriggerFileWatcher(opt.TriggerFilePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Change the global rates of the runtime after all the failures, so
+	//  the failed Start leaves them intact.
+	if opt.MemProfileRate != 0 {
+		ap.prevMemProfileRate = runtime.MemProfileRate
+		runtime.MemProfileRate = opt.MemProfileRate
+	}
+	if opt.BlockProfileRate > 0 {
+		ap.blockProfileRate = opt.BlockProfileRate
+		// The runtime can't tell the block profile rate, so
+		//  the application tells its own.
+		ap.prevBlockProfileRate = opt.PreviousBlockProfileRate
+		runtime.SetBlockProfileRate(opt.BlockProfileRate)
+	}
+	if opt.MutexProfileFraction > 0 {
+		ap.mutexProfileFraction = opt.MutexProfileFraction
+		ap.prevMutexProfileFraction = runtime.SetMutexProfileFraction(opt.MutexProfileFraction)
 	}
 
 	go ap.watch()
+	if done := ctx.Done(); done != nil {
+		go func() {
+			select {
+			case <-done:
+				ap.stop()
+			case <-ap.stopC:
+			}
+		}()
+	}
+	if ap.wal != nil {
+		go ap.runRecovered("wal replay", ap.replayWAL)
+	}
 	globalAp = ap
+	if opt.PublishExpvar {
+		publishExpvar()
+	}
 	return nil
 }
 
+// running returns the global autopprof process, or nil if it isn't
+// started or it's stopped, e.g. by the Stop or by the cancel of the ctx
+// of the StartWithContext.
+func running() *autoPprof {
+	ap := globalAp
+	if ap == nil || ap.stopped() {
+		return nil
+	}
+	return ap
+}
+
 // Stop stops the global autopprof process.
 func Stop() {
 	if globalAp != nil {
@@ -115,9 +715,212 @@ func Stop() {
 	}
 }
 
+// Status returns the runtime status of the global autopprof process.
+func Status() StatusInfo {
+	if globalAp == nil {
+		return StatusInfo{}
+	}
+	return globalAp.status()
+}
+
+// CPUSnapshots returns the copies of the cpu usage snapshots of
+// the global autopprof process from the oldest to the newest, to debug
+// the cpu usages. It's nil if the autopprof isn't started.
+func CPUSnapshots() []CPUSnapshot {
+	if globalAp == nil || globalAp.queryer == nil {
+		return nil
+	}
+	return globalAp.queryer.cpuSnapshots()
+}
+
+// NotifyLatencyBreach captures and reports the cpu and goroutine
+// profiles of the global autopprof process immediately. The application
+// calls it when it detects the violation of its SLO, (e.g. the p99
+// latency over the budget) so the profiles are tied to the symptom
+// rather than the resource usages. They're marked with
+// the report.TriggerLatencyBreach and share the TriggerID.
+// It blocks for the cpu profiling, and the ctx bounds the reporting.
+// It returns ErrLatencyBreachDebounced within
+// the Option.LatencyBreachDebounce of the last capture, and
+// ErrNotStarted if the autopprof isn't started or is stopped.
+func NotifyLatencyBreach(ctx context.Context) error {
+	ap := running()
+	if ap == nil {
+		return ErrNotStarted
+	}
+	return ap.notifyLatencyBreach(ctx)
+}
+
+// RecordError records an error of the application for
+// the Option.ErrorRateThreshold. It's cheap enough to be called on every
+// error, and does nothing if the threshold isn't set or the autopprof
+// isn't running.
+func RecordError() {
+	ap := running()
+	if ap == nil {
+		return
+	}
+	ap.errors.record(time.Now())
+}
+
+// HealthHandler returns the handler serving the HealthInfo of
+// the global autopprof process as JSON, with the status code 200 if
+// it's healthy and 503 if not:
+//
+//	http.Handle(autopprof.HealthPath, autopprof.HealthHandler())
+func HealthHandler() http.Handler {
+	return newHealthHandler(func() HealthInfo {
+		if globalAp == nil {
+			return HealthInfo{
+				Status:   HealthDown,
+				Reasons:  []string{"not started"},
+				Watchers: map[string]WatcherHealth{},
+			}
+		}
+		return globalAp.healthInfo()
+	})
+}
+
+// CaptureOnCrash captures and reports the final heap profile and
+// goroutine dump of the global autopprof process for the post-mortem.
+// It's for the terminal conditions, so the application calls it from
+// the recover() handler of a panic or on an impending OOM.
+// It's best-effort: the circuit breaker, the cooldown and the limit of
+// the concurrent reports are ignored, and both are reported at once.
+// It returns ErrNotStarted if the autopprof isn't started or is
+// stopped.
+func CaptureOnCrash(reason string) error {
+	ap := running()
+	if ap == nil {
+		return ErrNotStarted
+	}
+	return ap.captureOnCrash(reason)
+}
+
+// CaptureNamed captures the profile of the pprof.Lookup by its name
+// (e.g. "threadcreate", "block", "mutex") and reports it with
+// the report.ReportProfile. The name is the report.ProfileKind of it.
+// It returns ErrUnknownProfile if there's no such profile, and
+// ErrNotStarted if the autopprof isn't started or is stopped.
+func CaptureNamed(name string) error {
+	ap := running()
+	if ap == nil {
+		return ErrNotStarted
+	}
+	return ap.captureNamed(name)
+}
+
+// verifyReporter checks the connectivity of the reporter if it's
+// the report.PingReporter.
+func (ap *autoPprof) verifyReporter() error {
+	pr, ok := ap.reporter.(report.PingReporter)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ap.timeout())
+	defer cancel()
+
+	if err := pr.Ping(ctx); err != nil {
+		return fmt.Errorf("%w: %v", ErrReporterUnreachable, err)
+	}
+	return nil
+}
+
+// nextSequence returns the next capture sequence number and the elapsed
+// time since the start. They're zero unless the captureSequence is set.
+func (ap *autoPprof) nextSequence() (uint64, time.Duration) {
+	if !ap.captureSequence {
+		return 0, 0
+	}
+	return atomic.AddUint64(&ap.sequence, 1), time.Since(ap.startedAt)
+}
+
+// cpuUsage returns the cpu usage in the cpuUsageBasis.
+func (ap *autoPprof) cpuUsage() (float64, error) {
+	usage, err := ap.queryer.cpuUsage()
+	// The zero usage means the snapshots aren't enough yet.
+	if err == nil && usage != 0 && ap.usagePercentile > 0 {
+		usage = cpuUsagePercentile(
+			ap.queryer.cpuSnapshots(), ap.queryer.status().CPUQuota, ap.usagePercentile,
+		)
+	}
+	if err != nil || ap.cpuUsageBasis == CPUUsageQuota {
+		return usage, err
+	}
+	return ap.cpuUsageBasis.normalize(usage, ap.queryer.status().CPUQuota), nil
+}
+
+// cpuPressureEnabled reports whether the cpuPressureThreshold is set and
+// the pressure stall information is available.
+func (ap *autoPprof) cpuPressureEnabled() bool {
+	return ap.cpuPressureThreshold > 0 && atomic.LoadUint32(&ap.psiUnavailable) == 0
+}
+
+// cpuPressure returns the cpu pressure. It's zero if the cpuPressureThreshold
+// isn't set. If the pressure stall information is unavailable, it
+// disables the cpuPressureThreshold.
+func (ap *autoPprof) cpuPressure() float64 {
+	if !ap.cpuPressureEnabled() {
+		return 0
+	}
+	pressure, err := ap.queryer.cpuPressure()
+	if errors.Is(err, ErrPSIUnavailable) {
+		logger().Println(fmt.Errorf(
+			"autopprof: disable the cpu pressure threshold: %w", err,
+		))
+		atomic.StoreUint32(&ap.psiUnavailable, 1)
+		return 0
+	}
+	if err != nil {
+		// Keep watching the cpu usage.
+		logger().Println(err)
+		return 0
+	}
+	return pressure
+}
+
+// cpuPressureHigh reports whether the cpu pressure is over
+// the cpuPressureThreshold.
+func (ap *autoPprof) cpuPressureHigh(pressure float64) bool {
+	return ap.cpuPressureEnabled() && pressure >= ap.cpuPressureThreshold
+}
+
+// sampling reports whether the cpu usage is sampled between the watches.
+func (ap *autoPprof) sampling() bool {
+	return ap.sampleInterval > 0 && ap.sampleInterval < ap.watchInterval
+}
+
+// resizeCPUSnapshots resizes the queue of the cpu usage snapshots, so
+// the usage is averaged over the same duration with the samples.
+func (ap *autoPprof) resizeCPUSnapshots() {
+	if !ap.sampling() {
+		return
+	}
+	window := time.Duration(cpuUsageSnapshotQueueSize) * ap.watchInterval
+	ap.queryer.setCPUSnapshotSize(int(window / ap.sampleInterval))
+}
+
+// refreshCPUQuota re-reads the cpu quota every cpuQuotaRefreshInterval,
+// so the cpu usage and the reports follow the resize of the container.
+// It keeps the last quota if the quota is unavailable, e.g. removed.
+// It doesn't re-read without the limits to keep.
+func (ap *autoPprof) refreshCPUQuota() {
+	if ap.limits == nil ||
+		time.Since(ap.cpuQuotaRefreshedAt) < ap.cpuQuotaRefreshInterval {
+		return
+	}
+	ap.cpuQuotaRefreshedAt = time.Now()
+	if err := ap.queryer.setCPUQuota(); err != nil {
+		return
+	}
+	ap.limits.setCPUQuota(ap.queryer.status().CPUQuota)
+}
+
 func (ap *autoPprof) loadCPUQuota() error {
+	ap.cpuQuotaRefreshedAt = time.Now()
 	err := ap.queryer.setCPUQuota()
 	if err == nil {
+		ap.limits.setCPUQuota(ap.queryer.status().CPUQuota)
 		return nil
 	}
 
@@ -128,7 +931,7 @@ func (ap *autoPprof) loadCPUQuota() error {
 	}
 	// If memory profiling is enabled, just logs the error and
 	//  disables the cpu profiling.
-	log.Println(
+	logger().Println(
 		"autopprof: disable the cpu profiling due to the CPU quota isn't set",
 	)
 	ap.disableCPUProf = true
@@ -136,33 +939,85 @@ func (ap *autoPprof) loadCPUQuota() error {
 }
 
 func (ap *autoPprof) watch() {
-	go ap.watchCPUUsage()
-	go ap.watchMemUsage()
+	go ap.runWatcher(watcherCPU, ap.watchCPUUsage)
+	go ap.runWatcher(watcherMem, ap.watchMemUsage)
+	go ap.runWatcher(watcherGoroutine, ap.watchGoroutineCount)
+	go ap.runWatcher(watcherFD, ap.watchFDUsage)
+	go ap.runWatcher(watcherPID, ap.watchPIDUsage)
+	go ap.runWatcher(watcherGoroutineDrop, ap.watchGoroutineDrop)
+	go ap.runWatcher(watcherGC, ap.watchGCPressure)
+	go ap.runWatcher(watcherErrorRate, ap.watchErrorRate)
+	go ap.runRecovered("startup capture", ap.captureStartup)
+	go ap.runWatcher("trigger file watcher", ap.watchTriggerFile)
+	go ap.runWatcher("liveness", ap.sendLiveness)
 	<-ap.stopC
 }
 
 func (ap *autoPprof) watchCPUUsage() {
-	if ap.disableCPUProf {
-		return
-	}
+	ap.watchWhileEnabled(watcherCPU, ap.cpuProfDisabled, ap.watchCPUUsageEnabled)
+}
+
+// watchCPUUsageEnabled watches the cpu usage until the cpu profiling is
+// disabled.
+func (ap *autoPprof) watchCPUUsageEnabled() {
+	ap.watchers.start(watcherCPU)
+	defer ap.watchers.exit(watcherCPU)
 
 	ticker := time.NewTicker(ap.watchInterval)
 	defer ticker.Stop()
 
-	var consecutiveOverThresholdCnt int
+	// sampleC is nil without the sampling, so it never fires.
+	var sampleC <-chan time.Time
+	if ap.sampling() {
+		sampleTicker := time.NewTicker(ap.sampleInterval)
+		defer sampleTicker.Stop()
+		sampleC = sampleTicker.C
+	}
+
+	var (
+		consecutiveOverThresholdCnt int
+		lastWarnedAt                time.Time
+
+		cpuBurst = newBurst(ap.burstCount, ap.burstInterval)
+	)
 	for {
 		select {
+		case <-sampleC:
+			// Only snapshot the cpu usage. The failure is reported by
+			//  the next watch.
+			_, _ = ap.queryer.cpuUsage()
 		case <-ticker.C:
-			usage, err := ap.queryer.cpuUsage()
-			fmt.Println("@@ autopprof @@ cpu usage: ", usage)
-
+			if ap.cpuProfDisabled() {
+				return
+			}
+			ap.watchers.tick(watcherCPU)
+			ap.refreshCPUQuota()
+			usage, err := ap.cpuUsage()
+			if errors.Is(err, ErrCgroupReadTimeout) {
+				// Skip this tick to keep the watcher alive.
+				logger().Println(err)
+				continue
+			}
 			if err != nil {
-				log.Println(err)
+				logger().Println(err)
 				return
 			}
-			if usage < ap.cpuThreshold {
+			ap.stats.setCPUUsage(usage)
+			usage = ap.cpuFilter.update(usage)
+			threshold := ap.cpuBaseline.threshold(time.Now(), usage, ap.cpuThreshold)
+			pressure := ap.cpuPressure()
+			lastWarnedAt = ap.warn(
+				EventCPUWarning, usage, ap.cpuWarnThreshold, threshold,
+				lastWarnedAt,
+			)
+			if usage < threshold && !ap.cpuPressureHigh(pressure) {
+				ap.emitRecovery(
+					EventCPURecovered, usage, threshold,
+					consecutiveOverThresholdCnt,
+				)
 				// Reset the count if the cpu usage goes under the threshold.
 				consecutiveOverThresholdCnt = 0
+				cpuBurst.reset()
 				continue
 			}
 
@@ -170,136 +1025,1943 @@ func (ap *autoPprof) watchCPUUsage() {
 			//  duplicate reports are sent.
 			// This is to prevent the autopprof from sending too many reports.
 			if consecutiveOverThresholdCnt == 0 {
-				if err := ap.reportCPUProfile(usage); err != nil {
-					log.Println(fmt.Errorf(
+				if err := ap.reportCPUProfile(usage, pressure); err != nil {
+					logger().Println(fmt.Errorf(
 						"autopprof: failed to report the cpu profile: %w", err,
 					))
 				}
-				if ap.reportBoth && !ap.disableMemProf {
-					memUsage, err := ap.queryer.memUsage()
-					if err != nil {
-						log.Println(err)
+				cpuBurst.start(time.Now())
+				if ap.reportBoth && !ap.memProfDisabled() {
+					memStat, err := ap.memUsage()
+					switch {
+					case errors.Is(err, ErrCgroupReadTimeout):
+						// Skip the heap profile of this tick to keep
+						//  the watcher alive.
+						logger().Println(err)
+					case err != nil:
+						logger().Println(err)
 						return
-					}
-					if err := ap.reportHeapProfile(memUsage); err != nil {
-						log.Println(fmt.Errorf(
-							"autopprof: failed to report the heap profile: %w", err,
-						))
+					default:
+						if err := ap.reportHeapProfile(memStat); err != nil {
+							logger().Println(fmt.Errorf(
+								"autopprof: failed to report the heap profile: %w", err,
+							))
+						}
 					}
 				}
+				if ap.reportBoth {
+					ap.reportContentionProfiles()
+				}
+			} else if cpuBurst.due(time.Now()) {
+				if err := ap.burstCPUProfile(usage, pressure); err != nil {
+					logger().Println(fmt.Errorf(
+						"autopprof: failed to report the cpu profile: %w", err,
+					))
+				}
 			}
 
-			consecutiveOverThresholdCnt++
-			if consecutiveOverThresholdCnt >= ap.minConsecutiveOverThreshold {
-				// Reset the count and ready to report the cpu profile again.
-				consecutiveOverThresholdCnt = 0
-			}
+			consecutiveOverThresholdCnt = ap.nextOverThresholdCnt(
+				consecutiveOverThresholdCnt, usage,
+			)
 		case <-ap.stopC:
 			return
 		}
 	}
 }
 
-func (ap *autoPprof) reportCPUProfile(cpuUsage float64) error {
-	b, err := ap.profiler.profileCPU()
-	if err != nil {
-		return fmt.Errorf("autopprof: failed to profile the cpu: %w", err)
+// warn emits the warning event if the usage is between the warning
+// threshold and the threshold, and returns the time the last warning
+// is emitted at.
+// The warnings are debounced by the warnCooldown since the last one,
+// apart from the reports.
+func (ap *autoPprof) warn(
+	typ EventType, usage, warnThreshold, threshold float64, lastWarnedAt time.Time,
+) time.Time {
+	if warnThreshold == 0 || usage < warnThreshold {
+		return lastWarnedAt
+	}
+	if usage >= threshold {
+		// It's reported with the profile instead.
+		return lastWarnedAt
 	}
+	now := time.Now()
+	if !lastWarnedAt.IsZero() && now.Sub(lastWarnedAt) < ap.warnCooldownOrDefault() {
+		return lastWarnedAt
+	}
+	ap.emitEvent(Event{
+		Type:                typ,
+		ThresholdPercentage: warnThreshold * 100,
+		UsagePercentage:     usage * 100,
+		Time:                now,
+	})
+	return now
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
-	defer cancel()
+// warnCooldownOrDefault returns the warnCooldown, or the cooldown of
+// the reports without the severity if it isn't set.
+func (ap *autoPprof) warnCooldownOrDefault() time.Duration {
+	if ap.warnCooldown > 0 {
+		return ap.warnCooldown
+	}
+	return time.Duration(ap.minConsecutiveOverThreshold) * ap.watchInterval
+}
 
-	ci := report.CPUInfo{
-		ThresholdPercentage: ap.cpuThreshold * 100,
-		UsagePercentage:     cpuUsage * 100,
+// nextOverThresholdCnt returns the updated consecutive count of over
+// the threshold. The report is sent when the count is zero.
+// The count is reset after the cooldown of the usage.
+func (ap *autoPprof) nextOverThresholdCnt(cnt int, usage float64) int {
+	limit := ap.minConsecutiveOverThreshold
+	if ap.severityCooldowns != nil {
+		limit = int(ap.reportCooldown(usage) / ap.watchInterval)
+		if limit < 1 {
+			limit = 1
+		}
 	}
-	bReader := bytes.NewReader(b)
-	if err := ap.reporter.ReportCPUProfile(ctx, bReader, ci); err != nil {
-		return err
+	cnt++
+	if !ap.edgeTriggered && cnt >= limit {
+		// Reset the count and ready to report the profile again.
+		cnt = 0
 	}
-	return nil
+	return cnt
 }
 
-func (ap *autoPprof) watchMemUsage() {
-	if ap.disableMemProf {
+// emitRecovery emits the recovery event if the usage dropped back below
+// the threshold in the edge-triggered mode.
+// The cnt is the consecutive count of over the threshold before
+// the usage dropped.
+func (ap *autoPprof) emitRecovery(
+	typ EventType, usage, threshold float64, cnt int,
+) {
+	if !ap.edgeTriggered || !ap.emitRecoveryEvents || cnt == 0 {
 		return
 	}
+	ap.emitEvent(Event{
+		Type:                typ,
+		ThresholdPercentage: threshold * 100,
+		UsagePercentage:     usage * 100,
+		Time:                time.Now(),
+	})
+}
 
-	ticker := time.NewTicker(ap.watchInterval)
-	defer ticker.Stop()
+func (ap *autoPprof) emitEvent(ev Event) {
+	if ap.onEvent != nil {
+		ap.onEvent(ev)
+	}
+}
 
-	var consecutiveOverThresholdCnt int
-	for {
-		select {
-		case <-ticker.C:
-			usage, err := ap.queryer.memUsage()
-			if err != nil {
-				log.Println(err)
-				return
-			}
+func (ap *autoPprof) reportCPUProfile(cpuUsage, cpuPressure float64) error {
+	// Don't waste the profiling if the reporter is unavailable.
+	releaseTrial, ok := ap.breaker.acquire()
+	if !ok {
+		ap.stats.drop()
+		return nil
+	}
+	defer releaseTrial()
+	// Honor the cooldown of the previous process.
+	if ap.state.inCooldown(stateKindCPU, ap.reportCooldown(cpuUsage)) {
+		return nil
+	}
+	return ap.captureCPUProfile(cpuUsage, cpuPressure, true)
+}
 
-			fmt.Println("@@ autopprof @@ mem usage: ", usage)
+// burstCPUProfile reports the cpu profile of the burst. The cooldown
+// isn't honored, since the burst is the captures within it.
+func (ap *autoPprof) burstCPUProfile(cpuUsage, cpuPressure float64) error {
+	releaseTrial, ok := ap.breaker.acquire()
+	if !ok {
+		ap.stats.drop()
+		return nil
+	}
+	defer releaseTrial()
+	return ap.captureCPUProfile(cpuUsage, cpuPressure, false)
+}
 
-			if usage < ap.memThreshold {
-				// Reset the count if the memory usage goes under the threshold.
-				consecutiveOverThresholdCnt = 0
-				continue
-			}
+// captureCPUProfile captures and reports the cpu profile.
+// The crossing is whether the usage has just crossed the threshold,
+// rather than the burst after it.
+func (ap *autoPprof) captureCPUProfile(
+	cpuUsage, cpuPressure float64, crossing bool,
+) error {
+	// Cap the cumulative profiling overhead.
+	if !ap.cpuBudget.take(ap.cpuProfilingDuration) {
+		ap.stats.drop()
+		return nil
+	}
+	ci := ap.cpuInfo(cpuUsage, cpuPressure)
+	var dump []byte
+	if crossing && ap.cpuCrossingDump {
+		// Dump before the profiling averages the instant away.
+		var err error
+		dump, err = ap.profiler.dumpGoroutines()
+		if err != nil {
+			// Profile the cpu anyway.
+			logger().Println(fmt.Errorf(
+				"autopprof: failed to dump the goroutines: %w", err,
+			))
+		} else {
+			ci.TriggerID = newTriggerID()
+		}
+	}
+	err := ap.profileAndSendCPU(ci)
+	if dump != nil {
+		// The dump is worth reporting even if the cpu profiling failed.
+		if dumpErr := ap.reportCrossingDump(dump, ci); dumpErr != nil && err == nil {
+			err = dumpErr
+		}
+	}
+	return err
+}
+
+// profileAndSendCPU profiles the cpu and reports the profile of the ci.
+func (ap *autoPprof) profileAndSendCPU(ci report.CPUInfo) error {
+	// The streamed profile can't be verified, analyzed nor diffed
+	// before the reporting.
+	if sr, ok := ap.reporter.(report.StreamReporter); ok && sr.CanStream() &&
+		ap.verifier == nil && ap.analyzeCPU == nil && ap.cpuDiff == nil {
+		return ap.streamCPUProfile(ci)
+	}
+	b, err := ap.profiler.profileCPU()
+	if errors.Is(err, ErrCPUProfilingInUse) {
+		// Skip this time. The other profiling is running.
+		logger().Println(err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to profile the cpu: %w", err)
+	}
+
+	return ap.sendCPUProfile(context.Background(), b, ci)
+}
+
+// reportCrossingDump reports the goroutine dump captured when the cpu
+// usage crossed the threshold.
+func (ap *autoPprof) reportCrossingDump(dump []byte, ci report.CPUInfo) error {
+	release := ap.acquireReport()
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), ap.timeout())
+	defer cancel()
+
+	trigger := ci.Trigger
+	if trigger == "" {
+		trigger = report.TriggerCPU
+	}
+	gi := report.GoroutineInfo{
+		SchemaVersion:       report.SchemaVersion,
+		Labels:              ap.labels,
+		Trigger:             trigger,
+		TriggerID:           ci.TriggerID,
+		IncidentID:          ap.incidentID(ci.Trigger),
+		Dump:                true,
+		ThresholdPercentage: ci.ThresholdPercentage,
+		UsagePercentage:     ci.UsagePercentage,
+	}
+	gi.CPUQuotaCores, gi.MemLimitBytes = ap.limits.get()
+	gi.Sequence, gi.Elapsed = ap.nextSequence()
+	if err := ap.reporter.ReportGoroutineProfile(ctx, bytes.NewReader(dump), gi); err != nil {
+		return fmt.Errorf("autopprof: failed to report the goroutine dump: %w", err)
+	}
+	return nil
+}
+
+// sendCPUProfile reports the cpu profile with the top functions within
+// the timeout of the ctx.
+func (ap *autoPprof) sendCPUProfile(
+	ctx context.Context, b []byte, ci report.CPUInfo,
+) error {
+	release := ap.acquireReport()
+	defer release()
+
+	ctx, cancel := context.WithTimeout(ctx, ap.timeout())
+	defer cancel()
+
+	if len(ap.cpuProfileLabels) > 0 {
+		filtered, share, err := filterProfileByLabels(b, ap.cpuProfileLabels)
+		if err != nil {
+			// Report the whole profile anyway.
+			logger().Println(fmt.Errorf(
+				"autopprof: failed to filter the cpu profile by the labels: %w", err,
+			))
+		} else {
+			b = filtered
+			ci.ProfileLabels = ap.cpuProfileLabels
+			ci.ProfileLabelsPercentage = share * 100
+		}
+	}
+	analyzeProfile(b, ap.analyzeCPU)
+	if ap.cpuTopN > 0 {
+		top, err := topFunctions(b, ap.cpuTopN)
+		if err != nil {
+			// Report the profile anyway.
+			logger().Println(fmt.Errorf(
+				"autopprof: failed to get the top functions: %w", err,
+			))
+		}
+		ci.TopFunctions = top
+	}
+	var (
+		diff          []byte
+		baseTriggerID string
+	)
+	if ap.cpuDiff != nil {
+		if ci.TriggerID == "" {
+			ci.TriggerID = newTriggerID()
+		}
+		var err error
+		diff, baseTriggerID, err = ap.cpuDiff.diff(b, ci.TriggerID)
+		if err != nil {
+			// Report the profile anyway.
+			logger().Println(fmt.Errorf(
+				"autopprof: failed to diff the cpu profile: %w", err,
+			))
+		}
+	}
+	ci.IncidentID = ap.incidentID(ci.Trigger)
+	ci.CPUQuotaCores, ci.MemLimitBytes = ap.limits.get()
+	bReader := bytes.NewReader(b)
+	if err := ap.recordReport(
+		stateKindCPU, ap.reporter.ReportCPUProfile(ctx, bReader, ci),
+	); err != nil {
+		return err
+	}
+	if diff != nil {
+		return ap.sendCPUDiffProfile(ctx, diff, ci, baseTriggerID)
+	}
+	return nil
+}
+
+// sendCPUDiffProfile reports the difference of the cpu profile of
+// the ci from the one of the baseTriggerID within the timeout of
+// the ctx.
+func (ap *autoPprof) sendCPUDiffProfile(
+	ctx context.Context, b []byte, ci report.CPUInfo, baseTriggerID string,
+) error {
+	pi := report.ProfileInfo{
+		SchemaVersion: report.SchemaVersion,
+		Labels:        ap.labels,
+		Name:          string(report.ProfileKindCPU),
+		Delta:         true,
+		TriggerID:     ci.TriggerID,
+		BaseTriggerID: baseTriggerID,
+	}
+	pi.Sequence, pi.Elapsed = ap.nextSequence()
+	if err := report.ReportProfile(
+		ctx, ap.reporter, bytes.NewReader(b), report.ProfileKindCPU, pi,
+	); err != nil {
+		return fmt.Errorf("autopprof: failed to report the cpu diff: %w", err)
+	}
+	return nil
+}
+
+// cpuInfo returns the report.CPUInfo of the cpu profile.
+func (ap *autoPprof) cpuInfo(cpuUsage, cpuPressure float64) report.CPUInfo {
+	threshold := ap.cpuThreshold
+	if t := ap.cpuBaseline.status().Threshold; t > 0 {
+		threshold = t
+	}
+	ci := report.CPUInfo{
+		SchemaVersion:       report.SchemaVersion,
+		Labels:              ap.labels,
+		ThresholdPercentage: threshold * 100,
+		UsagePercentage:     cpuUsage * 100,
+	}
+	if ap.cpuPressureEnabled() {
+		ci.PressurePercentage = cpuPressure * 100
+		ci.PressureThresholdPercentage = ap.cpuPressureThreshold * 100
+		if cpuUsage < threshold && ap.cpuPressureHigh(cpuPressure) {
+			ci.Trigger = report.TriggerCPUPressure
+		}
+	}
+	ci.Sequence, ci.Elapsed = ap.nextSequence()
+	return ci
+}
+
+// streamCPUProfile reports the cpu profile while profiling through
+// a pipe, so the profile isn't buffered before the reporting.
+func (ap *autoPprof) streamCPUProfile(ci report.CPUInfo) error {
+	release := ap.acquireReport()
+	defer release()
+
+	// The reporter reads the stream until the profiling ends.
+	ctx, cancel := context.WithTimeout(
+		context.Background(), ap.cpuProfilingDuration+ap.timeout(),
+	)
+	defer cancel()
+
+	var (
+		pr, pw = io.Pipe()
+		inUseC = make(chan struct{}, 1)
+	)
+	go func() {
+		var panicErr error
+		defer func() {
+			if panicErr != nil {
+				// Unblock the reporter.
+				pw.CloseWithError(panicErr)
+			}
+		}()
+		defer ap.recoverPanic("cpu profiling", &panicErr)
+
+		if err := ap.profiler.writeCPUProfile(pw); err != nil {
+			if errors.Is(err, ErrCPUProfilingInUse) {
+				// Notify before the reporter sees the error.
+				inUseC <- struct{}{}
+			}
+			pw.CloseWithError(fmt.Errorf(
+				"autopprof: failed to profile the cpu: %w", err,
+			))
+			return
+		}
+		pw.Close()
+	}()
+	// Unblock the profiling if the reporter returns without reading all.
+	defer pr.Close()
+
+	ci.IncidentID = ap.incidentID(ci.Trigger)
+	ci.CPUQuotaCores, ci.MemLimitBytes = ap.limits.get()
+	reportErr := ap.reporter.ReportCPUProfile(ctx, pr, ci)
+	select {
+	case <-inUseC:
+		// Skip this time. It's not the failure of the reporter.
+		logger().Println(ErrCPUProfilingInUse)
+		return nil
+	default:
+	}
+	if err := ap.recordReport(stateKindCPU, reportErr); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ap *autoPprof) watchMemUsage() {
+	ap.watchWhileEnabled(watcherMem, ap.memProfDisabled, ap.watchMemUsageEnabled)
+}
+
+// watchMemUsageEnabled watches the memory usage until the heap
+// profiling is disabled.
+func (ap *autoPprof) watchMemUsageEnabled() {
+	ap.watchers.start(watcherMem)
+	defer ap.watchers.exit(watcherMem)
+
+	ticker := time.NewTicker(ap.watchInterval)
+	defer ticker.Stop()
+
+	var (
+		consecutiveOverThresholdCnt int
+		lastWarnedAt                time.Time
+
+		memBurst = newBurst(ap.burstCount, ap.burstInterval)
+
+		// unlimited triggers the heap profiling without the memory
+		//  limit, and switched reports whether it's logged.
+		unlimited = &unlimitedMemTrigger{
+			absolute: ap.memAbsoluteThreshold,
+			growth:   ap.memGrowthThreshold,
+		}
+		switched bool
+	)
+	for {
+		select {
+		case <-ticker.C:
+			if ap.memProfDisabled() {
+				return
+			}
+			ap.watchers.tick(watcherMem)
+			stat, err := ap.memUsage()
+			if errors.Is(err, ErrCgroupReadTimeout) {
+				// Skip this tick to keep the watcher alive.
+				logger().Println(err)
+				continue
+			}
+			if err != nil {
+				logger().Println(err)
+				return
+			}
+			// The usages per NUMA node are read by the mem watcher only.
+			stat.numa = ap.numaNodes()
+			usage := stat.ratioOf(ap.memLimitMode)
+			ap.stats.setMemUsage(usage)
+			usage = ap.memWindow.update(usage)
+			usage = ap.memFilter.update(usage)
+
+			var unlimitedBreached bool
+			if !ap.memLimited(stat) {
+				if !switched {
+					logger().Printf(
+						"autopprof: no memory limit is detected, trigger the heap profiling by %s",
+						unlimited,
+					)
+					switched = true
+				}
+				unlimitedBreached = unlimited.breached(stat.usage)
+			}
+
+			lastWarnedAt = ap.warn(
+				EventMemWarning, usage, ap.memWarnThreshold, ap.memThreshold,
+				lastWarnedAt,
+			)
+
+			if usage < ap.memThreshold && !ap.memAvailableLow(stat) &&
+				!unlimitedBreached && !ap.numaHigh(stat) && !ap.memRequestHigh(stat) {
+				ap.emitRecovery(
+					EventMemRecovered, usage, ap.memThreshold,
+					consecutiveOverThresholdCnt,
+				)
+				// Reset the count if the memory usage goes under the threshold.
+				consecutiveOverThresholdCnt = 0
+				memBurst.reset()
+				continue
+			}
 
 			// If memory utilization remains high for a short period of time,
 			//  no duplicate reports are sent.
 			// This is to prevent the autopprof from sending too many reports.
 			if consecutiveOverThresholdCnt == 0 {
-				if err := ap.reportHeapProfile(usage); err != nil {
-					log.Println(fmt.Errorf(
+				if err := ap.reportHeapProfile(stat); err != nil {
+					logger().Println(fmt.Errorf(
 						"autopprof: failed to report the heap profile: %w", err,
 					))
 				}
-				if ap.reportBoth && !ap.disableCPUProf {
-					cpuUsage, err := ap.queryer.cpuUsage()
-					if err != nil {
-						log.Println(err)
+				if unlimitedBreached {
+					unlimited.reported(stat.usage)
+				}
+				memBurst.start(time.Now())
+				if ap.reportBoth && !ap.cpuProfDisabled() {
+					cpuUsage, err := ap.cpuUsage()
+					switch {
+					case errors.Is(err, ErrCgroupReadTimeout):
+						// Skip the cpu profile of this tick to keep
+						//  the watcher alive.
+						logger().Println(err)
+					case err != nil:
+						logger().Println(err)
 						return
+					default:
+						// The cpu pressure is read by the cpu watcher only.
+						if err := ap.reportCPUProfile(cpuUsage, 0); err != nil {
+							logger().Println(fmt.Errorf(
+								"autopprof: failed to report the cpu profile: %w", err,
+							))
+						}
 					}
-					if err := ap.reportCPUProfile(cpuUsage); err != nil {
-						log.Println(fmt.Errorf(
-							"autopprof: failed to report the cpu profile: %w", err,
-						))
-					}
+				}
+				if ap.reportBoth {
+					ap.reportContentionProfiles()
+				}
+			} else if memBurst.due(time.Now()) {
+				if err := ap.burstHeapProfile(stat); err != nil {
+					logger().Println(fmt.Errorf(
+						"autopprof: failed to report the heap profile: %w", err,
+					))
 				}
 			}
 
-			consecutiveOverThresholdCnt++
-			if consecutiveOverThresholdCnt >= ap.minConsecutiveOverThreshold {
-				// Reset the count and ready to report the heap profile again.
-				consecutiveOverThresholdCnt = 0
-			}
+			consecutiveOverThresholdCnt = ap.nextOverThresholdCnt(
+				consecutiveOverThresholdCnt, usage,
+			)
 		case <-ap.stopC:
 			return
 		}
 	}
 }
 
-func (ap *autoPprof) reportHeapProfile(memUsage float64) error {
+// memUsage queries the memory usage of the cgroup and the Go runtime.
+func (ap *autoPprof) memUsage() (*memStat, error) {
+	stat, err := ap.queryer.memUsage()
+	if err != nil {
+		return nil, err
+	}
+	ap.limits.setMemLimit(stat.limit)
+	ap.sumMemUsageComponents(stat)
+	if ap.includeKernelMemory {
+		stat.includeKmem()
+	}
+	stat.goUsage, stat.goLimit = readGoMemStat()
+	return stat, nil
+}
+
+// sumMemUsageComponents replaces the usage of the stat with the sum of
+// the memUsageComponents if they're set. It disables them if
+// the queryer doesn't read the memory fields.
+func (ap *autoPprof) sumMemUsageComponents(stat *memStat) {
+	if ap.memUsageComponents == 0 || atomic.LoadUint32(&ap.memFieldsUnavailable) != 0 {
+		return
+	}
+	if stat.fields == nil {
+		logger().Println(fmt.Errorf(
+			"autopprof: disable the memory usage components %s: %w",
+			ap.memUsageComponents, ErrMemFieldsUnavailable,
+		))
+		atomic.StoreUint32(&ap.memFieldsUnavailable, 1)
+		return
+	}
+	stat.usage = stat.fields.sum(ap.memUsageComponents)
+	// None of the components is the kernel memory.
+	stat.kmemInUsage = false
+}
+
+// numaNodes returns the usages per NUMA node if the numaThreshold is
+// set. It disables the threshold if they're unavailable.
+func (ap *autoPprof) numaNodes() []numaNode {
+	if ap.numaThreshold == 0 || atomic.LoadUint32(&ap.numaUnavailable) != 0 {
+		return nil
+	}
+	nodes, err := ap.queryer.numaStat()
+	if errors.Is(err, ErrNUMAUnavailable) {
+		logger().Println(fmt.Errorf(
+			"autopprof: disable the NUMA threshold: %w", err,
+		))
+		atomic.StoreUint32(&ap.numaUnavailable, 1)
+		return nil
+	}
+	if err != nil {
+		// Keep watching the memory usage.
+		logger().Println(err)
+		return nil
+	}
+	return nodes
+}
+
+// numaHigh reports whether the usage of any NUMA node of the stat is
+// over the numaThreshold.
+func (ap *autoPprof) numaHigh(stat *memStat) bool {
+	for _, n := range stat.numa {
+		if n.ratio() >= ap.numaThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// memRequestRatio returns the ratio of the working set to the memory
+// request. It's zero if the request isn't set.
+func (ap *autoPprof) memRequestRatio(stat *memStat) float64 {
+	if ap.memRequest == 0 {
+		return 0
+	}
+	return float64(stat.usage) / float64(ap.memRequest)
+}
+
+// memRequestHigh reports whether the memory usage against the request
+// is over the threshold.
+func (ap *autoPprof) memRequestHigh(stat *memStat) bool {
+	return ap.memRequestThreshold > 0 &&
+		ap.memRequestRatio(stat) >= ap.memRequestThreshold
+}
+
+// memLimited reports whether the memory usage has the limit to be
+// relative to, the cgroup one or the GOMEMLIMIT of the mode.
+func (ap *autoPprof) memLimited(stat *memStat) bool {
+	return stat.limited() ||
+		(ap.memLimitMode != MemLimitCgroup && stat.goLimit != 0)
+}
+
+// memAvailableLow reports whether the available memory is lower than
+// the memMinAvailableBytes.
+func (ap *autoPprof) memAvailableLow(stat *memStat) bool {
+	return ap.memMinAvailableBytes > 0 &&
+		stat.available() < ap.memMinAvailableBytes
+}
+
+func (ap *autoPprof) reportHeapProfile(stat *memStat) error {
+	// Don't waste the profiling if the reporter is unavailable.
+	releaseTrial, ok := ap.breaker.acquire()
+	if !ok {
+		ap.stats.drop()
+		return nil
+	}
+	defer releaseTrial()
+	// Honor the cooldown of the previous process.
+	if ap.state.inCooldown(stateKindHeap, ap.reportCooldown(stat.ratioOf(ap.memLimitMode))) {
+		return nil
+	}
+	return ap.captureHeapProfile(stat)
+}
+
+// burstHeapProfile reports the heap profile of the burst. The cooldown
+// isn't honored, since the burst is the captures within it.
+func (ap *autoPprof) burstHeapProfile(stat *memStat) error {
+	releaseTrial, ok := ap.breaker.acquire()
+	if !ok {
+		ap.stats.drop()
+		return nil
+	}
+	defer releaseTrial()
+	return ap.captureHeapProfile(stat)
+}
+
+// profileHeap profiles the heap, reduced by the heapSampleReduction.
+// The profiling of the huge heap is throttled by the heapThrottle.
+func (ap *autoPprof) profileHeap() ([]byte, error) {
+	reduction := ap.heapSampleReduction
+	if ap.heapThrottle != nil {
+		var err error
+		reduction, err = ap.heapThrottle.reduction(ap.heapAlloc(), reduction)
+		if err != nil {
+			return nil, err
+		}
+	}
 	b, err := ap.profiler.profileHeap()
 	if err != nil {
-		return fmt.Errorf("autopprof: failed to profile the heap: %w", err)
+		return nil, fmt.Errorf("autopprof: failed to profile the heap: %w", err)
+	}
+	if reduction == 0 {
+		return b, nil
+	}
+	b, err = reduceHeapProfile(b, reduction, ap.heapPrimarySampleType)
+	if err != nil {
+		return nil, fmt.Errorf("autopprof: failed to reduce the heap profile: %w", err)
 	}
+	return b, nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
-	defer cancel()
+// captureHeapProfile captures and reports the heap profile.
+func (ap *autoPprof) captureHeapProfile(stat *memStat) error {
+	b, err := ap.profileHeap()
+	if err != nil {
+		return err
+	}
+	analyzeProfile(b, ap.analyzeHeap)
+	var dump []byte
+	if ap.heapGoroutineDump {
+		// Dump right after the heap profiling to correlate them.
+		dump, err = ap.profiler.dumpGoroutines()
+		if err != nil {
+			// Report the heap profile anyway.
+			logger().Println(fmt.Errorf(
+				"autopprof: failed to dump the goroutines: %w", err,
+			))
+		}
+	}
+	var allocsDiff []byte
+	if ap.reportAllocsDiff {
+		allocsDiff, err = ap.profileAllocsDiff()
+		if err != nil {
+			// Report the heap profile anyway.
+			logger().Println(err)
+		}
+	}
+	var views [][]byte
+	if ap.fullHeapCapture {
+		views, err = heapViews(b, fullHeapSampleTypes)
+		if err != nil {
+			return fmt.Errorf("autopprof: failed to parse the heap profile: %w", err)
+		}
+	}
 
 	mi := report.MemInfo{
+		SchemaVersion:       report.SchemaVersion,
+		Labels:              ap.labels,
 		ThresholdPercentage: ap.memThreshold * 100,
-		UsagePercentage:     memUsage * 100,
+		UsagePercentage:     stat.ratioOf(ap.memLimitMode) * 100,
+		AvailableBytes:      stat.available(),
+		MinAvailableBytes:   ap.memMinAvailableBytes,
+		KernelMemoryBytes:   stat.kmem,
+		MemLimitBytes:       stat.limit,
+		PrimarySampleType:   ap.heapPrimarySampleType,
+
+		AllocBytesSinceLastReport: ap.allocatedSinceLastReport(),
 	}
-	bReader := bytes.NewReader(b)
-	if err := ap.reporter.ReportHeapProfile(ctx, bReader, mi); err != nil {
+	if len(stat.numa) > 0 {
+		mi.NUMANodes = make([]report.NUMANodeStat, 0, len(stat.numa))
+		for _, n := range stat.numa {
+			mi.NUMANodes = append(mi.NUMANodes, report.NUMANodeStat{
+				Node:            n.node,
+				UsageBytes:      n.usage,
+				TotalBytes:      n.total,
+				UsagePercentage: n.ratio() * 100,
+			})
+		}
+		mi.NUMAThresholdPercentage = ap.numaThreshold * 100
+		if mi.UsagePercentage < mi.ThresholdPercentage &&
+			!ap.memAvailableLow(stat) && ap.numaHigh(stat) {
+			mi.Trigger = report.TriggerNUMA
+		}
+	}
+	if ap.memRequest > 0 {
+		mi.RequestBytes = ap.memRequest
+		mi.RequestUsagePercentage = ap.memRequestRatio(stat) * 100
+		mi.RequestThresholdPercentage = ap.memRequestThreshold * 100
+		if mi.Trigger == "" && mi.UsagePercentage < mi.ThresholdPercentage &&
+			!ap.memAvailableLow(stat) && ap.memRequestHigh(stat) {
+			mi.Trigger = report.TriggerMemRequest
+		}
+	}
+	if ap.memUsageComponents != 0 && stat.fields != nil {
+		mi.UsageComponents = ap.memUsageComponents.String()
+		mi.UsageComponentBytes = stat.fields.values(ap.memUsageComponents)
+	}
+	if stat.goLimit != 0 {
+		mi.CgroupUsagePercentage = stat.ratio() * 100
+		mi.GoMemLimitUsagePercentage = stat.goRatio() * 100
+		mi.GoMemLimitBytes = stat.goLimit
+	}
+	if views != nil || dump != nil || allocsDiff != nil {
+		mi.TriggerID = newTriggerID()
+	}
+
+	var reportErr error
+	if views != nil {
+		reportErr = ap.reportHeapViews(views, fullHeapSampleTypes, mi)
+	} else {
+		reportErr = ap.reportHeap(b, mi)
+	}
+	if dump != nil {
+		if err := ap.reportGoroutineDump(dump, mi); err != nil && reportErr == nil {
+			reportErr = err
+		}
+	}
+	if allocsDiff != nil {
+		if err := ap.reportAllocsDiffProfile(allocsDiff, mi); err != nil && reportErr == nil {
+			reportErr = err
+		}
+	}
+	if err := ap.recordReport(stateKindHeap, reportErr); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (ap *autoPprof) stop() {
-	close(ap.stopC)
+func (ap *autoPprof) reportHeap(b []byte, mi report.MemInfo) error {
+	release := ap.acquireReport()
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), ap.timeout())
+	defer cancel()
+
+	mi.Sequence, mi.Elapsed = ap.nextSequence()
+	mi.IncidentID = ap.incidentID(mi.Trigger)
+	cpuQuota, memLimit := ap.limits.get()
+	mi.CPUQuotaCores = cpuQuota
+	if mi.MemLimitBytes == 0 {
+		mi.MemLimitBytes = memLimit
+	}
+	return ap.reporter.ReportHeapProfile(ctx, bytes.NewReader(b), mi)
+}
+
+// reportHeapViews reports the views of the heap profile concurrently.
+// The number of the concurrent reports is limited by the reportSem.
+func (ap *autoPprof) reportHeapViews(
+	views [][]byte, sampleTypes []string, mi report.MemInfo,
+) error {
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(views))
+	)
+	for i, view := range views {
+		wg.Add(1)
+		go func(i int, view []byte) {
+			defer wg.Done()
+			defer ap.recoverPanic("heap view report", &errs[i])
+
+			vmi := mi
+			vmi.SampleType = sampleTypes[i]
+			errs[i] = ap.reportHeap(view, vmi)
+		}(i, view)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportGoroutineDump reports the goroutine dump captured with
+// the heap profile.
+func (ap *autoPprof) reportGoroutineDump(dump []byte, mi report.MemInfo) error {
+	release := ap.acquireReport()
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), ap.timeout())
+	defer cancel()
+
+	gi := report.GoroutineInfo{
+		SchemaVersion:       report.SchemaVersion,
+		Labels:              ap.labels,
+		Trigger:             report.TriggerHeap,
+		TriggerID:           mi.TriggerID,
+		IncidentID:          ap.incidentID(report.TriggerHeap),
+		Dump:                true,
+		ThresholdPercentage: mi.ThresholdPercentage,
+		UsagePercentage:     mi.UsagePercentage,
+		CPUQuotaCores:       mi.CPUQuotaCores,
+		MemLimitBytes:       mi.MemLimitBytes,
+	}
+	gi.Sequence, gi.Elapsed = ap.nextSequence()
+	return ap.reporter.ReportGoroutineProfile(ctx, bytes.NewReader(dump), gi)
+}
+
+// heapAlloc returns the bytes of the allocated heap objects.
+func (ap *autoPprof) heapAlloc() uint64 {
+	if ap.readMemStats == nil {
+		return 0
+	}
+	var ms runtime.MemStats
+	ap.readMemStats(&ms)
+	return ms.HeapAlloc
+}
+
+// allocatedSinceLastReport returns the bytes allocated since the last
+// heap report, and resets the baseline.
+func (ap *autoPprof) allocatedSinceLastReport() uint64 {
+	if ap.allocs == nil || ap.readMemStats == nil {
+		return 0
+	}
+	var ms runtime.MemStats
+	ap.readMemStats(&ms)
+	return ap.allocs.since(ms.TotalAlloc)
+}
+
+// profileAllocsDiff profiles the allocs and returns the difference of
+// them since the last heap report.
+func (ap *autoPprof) profileAllocsDiff() ([]byte, error) {
+	b, err := ap.profiler.profileNamed(string(report.ProfileKindAllocs))
+	if err != nil {
+		return nil, fmt.Errorf("autopprof: failed to profile the allocs: %w", err)
+	}
+	b, err = ap.allocs.diff(b)
+	if err != nil {
+		return nil, fmt.Errorf("autopprof: failed to diff the allocs profile: %w", err)
+	}
+	return b, nil
+}
+
+// reportAllocsDiffProfile reports the allocs diff captured with
+// the heap profile.
+func (ap *autoPprof) reportAllocsDiffProfile(b []byte, mi report.MemInfo) error {
+	release := ap.acquireReport()
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), ap.timeout())
+	defer cancel()
+
+	pi := report.ProfileInfo{
+		SchemaVersion: report.SchemaVersion,
+		Labels:        ap.labels,
+		Name:          string(report.ProfileKindAllocs),
+		Delta:         true,
+		TriggerID:     mi.TriggerID,
+	}
+	pi.Sequence, pi.Elapsed = ap.nextSequence()
+	return report.ReportProfile(
+		ctx, ap.reporter, bytes.NewReader(b), report.ProfileKindAllocs, pi,
+	)
+}
+
+// reportContentionProfiles reports the block and the mutex profiles
+// enabled by the blockProfileRate and the mutexProfileFraction.
+func (ap *autoPprof) reportContentionProfiles() {
+	// Don't waste the profiling if the reporter can't take them.
+	if _, ok := ap.reporter.(report.ProfileReporter); !ok {
+		return
+	}
+	if ap.blockProfileRate > 0 {
+		if err := ap.reportContentionProfile(
+			report.ProfileKindBlock, ap.profiler.profileBlock,
+		); err != nil {
+			logger().Println(fmt.Errorf(
+				"autopprof: failed to report the block profile: %w", err,
+			))
+		}
+	}
+	if ap.mutexProfileFraction > 0 {
+		if err := ap.reportContentionProfile(
+			report.ProfileKindMutex, ap.profiler.profileMutex,
+		); err != nil {
+			logger().Println(fmt.Errorf(
+				"autopprof: failed to report the mutex profile: %w", err,
+			))
+		}
+	}
+}
+
+func (ap *autoPprof) reportContentionProfile(
+	kind report.ProfileKind, profile func() ([]byte, error),
+) error {
+	// Don't waste the profiling if the reporter is unavailable.
+	releaseTrial, ok := ap.breaker.acquire()
+	if !ok {
+		ap.stats.drop()
+		return nil
+	}
+	defer releaseTrial()
+	b, err := profile()
+	if err != nil {
+		return err
+	}
+
+	release := ap.acquireReport()
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), ap.timeout())
+	defer cancel()
+
+	pi := report.ProfileInfo{
+		SchemaVersion: report.SchemaVersion,
+		Labels:        ap.labels,
+		Name:          string(kind),
+	}
+	pi.Sequence, pi.Elapsed = ap.nextSequence()
+	return ap.recordReport(string(kind), report.ReportProfile(
+		ctx, ap.reporter, bytes.NewReader(b), kind, pi,
+	))
+}
+
+func (ap *autoPprof) captureNamed(name string) error {
+	// Don't waste the profiling if the reporter is unavailable.
+	releaseTrial, ok := ap.breaker.acquire()
+	if !ok {
+		ap.stats.drop()
+		return nil
+	}
+	defer releaseTrial()
+	b, err := ap.profiler.profileNamed(name)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to profile the %s: %w", name, err)
+	}
+
+	release := ap.acquireReport()
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), ap.timeout())
+	defer cancel()
+
+	pi := report.ProfileInfo{
+		SchemaVersion: report.SchemaVersion,
+		Labels:        ap.labels,
+		Name:          name,
+	}
+	pi.Sequence, pi.Elapsed = ap.nextSequence()
+	return ap.recordReport(name, report.ReportProfile(
+		ctx, ap.reporter, bytes.NewReader(b), report.ProfileKind(name), pi,
+	))
+}
+
+func (ap *autoPprof) captureOnCrash(reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ap.timeout())
+	defer cancel()
+
+	triggerID := newTriggerID()
+	var (
+		wg      sync.WaitGroup
+		heapErr error
+		dumpErr error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer ap.recoverPanic("crash heap report", &heapErr)
+
+		b, err := ap.profileHeap()
+		if err != nil {
+			heapErr = err
+			return
+		}
+		mi := report.MemInfo{
+			SchemaVersion:     report.SchemaVersion,
+			Labels:            ap.labels,
+			Trigger:           report.TriggerCrash,
+			Reason:            reason,
+			TriggerID:         triggerID,
+			IncidentID:        ap.incidentID(report.TriggerCrash),
+			PrimarySampleType: ap.heapPrimarySampleType,
+		}
+		mi.CPUQuotaCores, mi.MemLimitBytes = ap.limits.get()
+		mi.Sequence, mi.Elapsed = ap.nextSequence()
+		heapErr = ap.reporter.ReportHeapProfile(ctx, bytes.NewReader(b), mi)
+	}()
+	go func() {
+		defer wg.Done()
+		defer ap.recoverPanic("crash goroutine dump report", &dumpErr)
+
+		dump, err := ap.profiler.dumpGoroutines()
+		if err != nil {
+			dumpErr = fmt.Errorf("autopprof: failed to dump the goroutines: %w", err)
+			return
+		}
+		gi := report.GoroutineInfo{
+			SchemaVersion: report.SchemaVersion,
+			Labels:        ap.labels,
+			Trigger:       report.TriggerCrash,
+			Reason:        reason,
+			TriggerID:     triggerID,
+			IncidentID:    ap.incidentID(report.TriggerCrash),
+			Dump:          true,
+		}
+		gi.CPUQuotaCores, gi.MemLimitBytes = ap.limits.get()
+		gi.Sequence, gi.Elapsed = ap.nextSequence()
+		dumpErr = ap.reporter.ReportGoroutineProfile(ctx, bytes.NewReader(dump), gi)
+	}()
+	wg.Wait()
+
+	if heapErr != nil {
+		return heapErr
+	}
+	return dumpErr
+}
+
+func (ap *autoPprof) notifyLatencyBreach(ctx context.Context) error {
+	if !ap.latencyDebounce.allow(time.Now()) {
+		return ErrLatencyBreachDebounced
+	}
+	// The cpu usage is read by the cpu watcher only.
+	ci := ap.cpuInfo(0, 0)
+	ci.Trigger = report.TriggerLatencyBreach
+	gi := report.GoroutineInfo{
+		SchemaVersion: report.SchemaVersion,
+		Labels:        ap.labels,
+		Trigger:       report.TriggerLatencyBreach,
+	}
+	return ap.captureAppTriggered(ctx, ci, gi)
+}
+
+// watchErrorRate captures the cpu and goroutine profiles when the rate
+// of the errors recorded by the application is over the threshold.
+func (ap *autoPprof) watchErrorRate() {
+	if ap.errorRateThreshold == 0 {
+		return
+	}
+	ap.watchers.start(watcherErrorRate)
+	defer ap.watchers.exit(watcherErrorRate)
+
+	ticker := time.NewTicker(ap.watchInterval)
+	defer ticker.Stop()
+
+	var consecutiveOverThresholdCnt int
+	for {
+		select {
+		case <-ticker.C:
+			ap.watchers.tick(watcherErrorRate)
+			rate := ap.errors.rate(time.Now())
+			if rate < ap.errorRateThreshold {
+				// Reset the count if the error rate goes under the threshold.
+				consecutiveOverThresholdCnt = 0
+				continue
+			}
+
+			// If the error rate remains high for a short period of time,
+			//  no duplicate reports are sent.
+			if consecutiveOverThresholdCnt == 0 {
+				// The cpu usage is read by the cpu watcher only.
+				ci := ap.cpuInfo(0, 0)
+				ci.Trigger = report.TriggerErrorRate
+				ci.ErrorRate, ci.ErrorRateThreshold = rate, ap.errorRateThreshold
+				gi := report.GoroutineInfo{
+					SchemaVersion:      report.SchemaVersion,
+					Labels:             ap.labels,
+					Trigger:            report.TriggerErrorRate,
+					ErrorRate:          rate,
+					ErrorRateThreshold: ap.errorRateThreshold,
+				}
+				if err := ap.captureAppTriggered(context.Background(), ci, gi); err != nil {
+					logger().Println(fmt.Errorf(
+						"autopprof: failed to report the profiles of the error rate: %w", err,
+					))
+				}
+			}
+
+			consecutiveOverThresholdCnt = ap.nextOverThresholdCnt(
+				consecutiveOverThresholdCnt, 0,
+			)
+		case <-ap.stopC:
+			return
+		}
+	}
+}
+
+// captureStartup captures the cpu and heap profiles once after
+// the startupCaptureDelay as the baseline of the cold start.
+func (ap *autoPprof) captureStartup() {
+	if !ap.captureOnStartup {
+		return
+	}
+	timer := time.NewTimer(ap.startupCaptureDelay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ap.stopC:
+		return
+	}
+	if err := ap.reportStartupProfiles(); err != nil {
+		logger().Println(fmt.Errorf(
+			"autopprof: failed to report the startup profiles: %w", err,
+		))
+	}
+}
+
+// reportStartupProfiles captures and reports the cpu and heap profiles
+// regardless of the thresholds, which share the trigger id.
+func (ap *autoPprof) reportStartupProfiles() error {
+	// Don't waste the profiling if the reporter is unavailable.
+	releaseTrial, ok := ap.breaker.acquire()
+	if !ok {
+		ap.stats.drop()
+		return nil
+	}
+	defer releaseTrial()
+
+	triggerID := newTriggerID()
+	var (
+		wg      sync.WaitGroup
+		cpuErr  error
+		heapErr error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer ap.recoverPanic("startup cpu report", &cpuErr)
+
+		// The cpu usage is read by the cpu watcher only.
+		ci := ap.cpuInfo(0, 0)
+		ci.Trigger, ci.TriggerID = report.TriggerStartup, triggerID
+		cpuErr = ap.captureAppTriggeredCPUProfile(context.Background(), ci)
+	}()
+	go func() {
+		defer wg.Done()
+		defer ap.recoverPanic("startup heap report", &heapErr)
+
+		if ap.memProfDisabled() {
+			return
+		}
+		b, err := ap.profileHeap()
+		if err != nil {
+			heapErr = err
+			return
+		}
+		mi := report.MemInfo{
+			SchemaVersion:     report.SchemaVersion,
+			Labels:            ap.labels,
+			Trigger:           report.TriggerStartup,
+			TriggerID:         triggerID,
+			PrimarySampleType: ap.heapPrimarySampleType,
+		}
+		// It doesn't start the cooldown of the heap profiling by the usage.
+		heapErr = ap.recordReport(stateKindStartup, ap.reportHeap(b, mi))
+	}()
+	wg.Wait()
+
+	if cpuErr != nil {
+		return cpuErr
+	}
+	return heapErr
+}
+
+// captureAppTriggered captures and reports the cpu and goroutine
+// profiles of the trigger by the application, (e.g. the latency breach)
+// which share the trigger id.
+func (ap *autoPprof) captureAppTriggered(
+	ctx context.Context, ci report.CPUInfo, gi report.GoroutineInfo,
+) error {
+	// Don't waste the profiling if the reporter is unavailable.
+	releaseTrial, ok := ap.breaker.acquire()
+	if !ok {
+		ap.stats.drop()
+		return nil
+	}
+	defer releaseTrial()
+
+	triggerID := newTriggerID()
+	ci.TriggerID, gi.TriggerID = triggerID, triggerID
+	var (
+		wg           sync.WaitGroup
+		cpuErr       error
+		goroutineErr error
+		wallClockErr error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer ap.recoverPanic("cpu report", &cpuErr)
+		cpuErr = ap.captureAppTriggeredCPUProfile(ctx, ci)
+	}()
+	go func() {
+		defer wg.Done()
+		defer ap.recoverPanic("goroutine report", &goroutineErr)
+		goroutineErr = ap.sendGoroutineProfile(ctx, gi)
+	}()
+	if ap.enableWallClock {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer ap.recoverPanic("wall-clock report", &wallClockErr)
+			wallClockErr = ap.captureWallClockProfile(ctx, triggerID)
+		}()
+	}
+	wg.Wait()
+
+	if cpuErr != nil {
+		return cpuErr
+	}
+	if goroutineErr != nil {
+		return goroutineErr
+	}
+	return wallClockErr
+}
+
+// captureWallClockProfile captures and reports the wall-clock profile
+// of the trigger by the application.
+func (ap *autoPprof) captureWallClockProfile(
+	ctx context.Context, triggerID string,
+) error {
+	b, err := ap.profiler.profileWallClock()
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to profile the wall-clock: %w", err)
+	}
+
+	release := ap.acquireReport()
+	defer release()
+
+	ctx, cancel := context.WithTimeout(ctx, ap.timeout())
+	defer cancel()
+
+	pi := report.ProfileInfo{
+		SchemaVersion: report.SchemaVersion,
+		Labels:        ap.labels,
+		Name:          string(report.ProfileKindWallClock),
+		TriggerID:     triggerID,
+	}
+	pi.Sequence, pi.Elapsed = ap.nextSequence()
+	return ap.recordReport(pi.Name, report.ReportProfile(
+		ctx, ap.reporter, bytes.NewReader(b), report.ProfileKindWallClock, pi,
+	))
+}
+
+// captureAppTriggeredCPUProfile captures and reports the cpu profile of
+// the trigger by the application. It's skipped if the cpu profiling is
+// disabled.
+func (ap *autoPprof) captureAppTriggeredCPUProfile(
+	ctx context.Context, ci report.CPUInfo,
+) error {
+	if ap.cpuProfDisabled() {
+		return nil
+	}
+	if !ap.cpuBudget.take(ap.cpuProfilingDuration) {
+		ap.stats.drop()
+		return nil
+	}
+	b, err := ap.profiler.profileCPU()
+	if errors.Is(err, ErrCPUProfilingInUse) {
+		// Skip this time. The other profiling is running.
+		logger().Println(err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to profile the cpu: %w", err)
+	}
+	return ap.sendCPUProfile(ctx, b, ci)
+}
+
+// incidentID returns the id of the incident the report of the trigger
+// joins. The startup and the manual profiles aren't of any incident.
+func (ap *autoPprof) incidentID(trigger string) string {
+	if trigger == report.TriggerStartup || trigger == report.TriggerManual {
+		return ""
+	}
+	return ap.incidents.join(time.Now())
+}
+
+// timeout returns the timeout of the reporting.
+// The timeout of the report.TimeoutReporter takes precedence over
+// the reportTimeout.
+func (ap *autoPprof) timeout() time.Duration {
+	if tr, ok := ap.reporter.(report.TimeoutReporter); ok {
+		if d := tr.Timeout(); d > 0 {
+			return d
+		}
+	}
+	if ap.reportTimeout > 0 {
+		return ap.reportTimeout
+	}
+	return defaultReportTimeout
+}
+
+// acquireReport waits for a slot of the concurrent reports and
+// returns the function to release it.
+func (ap *autoPprof) acquireReport() (release func()) {
+	if ap.reportSem == nil {
+		return func() {}
+	}
+	ap.reportSem <- struct{}{}
+	return func() { <-ap.reportSem }
+}
+
+// recordReport records the result of the reporting of the kind to
+// the breaker and the state, and returns the given error as is.
+func (ap *autoPprof) recordReport(kind string, err error) error {
+	ap.stats.record(err)
+	if ap.breaker.record(err) {
+		logger().Printf(
+			"autopprof: the reporter keeps failing, skip the reporting for %s",
+			ap.breaker.cooldown,
+		)
+	}
+	if err == nil {
+		if err := ap.state.recordReport(kind, time.Now()); err != nil {
+			logger().Println(fmt.Errorf(
+				"autopprof: failed to save the state file: %w", err,
+			))
+		}
+	}
+	return err
+}
+
+// reportCooldown returns the minimum duration between the reports of
+// the sustained high usage.
+func (ap *autoPprof) reportCooldown(usage float64) time.Duration {
+	return severityCooldown(
+		ap.severityCooldowns, usage,
+		time.Duration(ap.minConsecutiveOverThreshold)*ap.watchInterval,
+	)
+}
+
+func (ap *autoPprof) status() StatusInfo {
+	st := StatusInfo{
+		Running:   !ap.stopped(),
+		Breaker:   ap.breaker.status(),
+		CPUBudget: ap.cpuBudget.status(),
+		Incident:  ap.incidents.status(time.Now()),
+		CPUUsage:  ap.cpuFilter.status(),
+		MemUsage:  ap.memFilter.status(),
+
+		HeapThrottle: ap.heapThrottle.status(),
+		CPUBaseline:  ap.cpuBaseline.status(),
+	}
+	if ap.queryer != nil {
+		st.Cgroup = ap.queryer.status()
+		st.CPUSnapshots = ap.queryer.cpuSnapshots()
+	}
+	if ap.verifier != nil {
+		st.InvalidProfiles = ap.verifier.invalidCount()
+	}
+	st.Panics = ap.panicCount()
+	return st
+}
+
+// healthInfo returns the HealthInfo. It only reads the state kept by
+// the watchers and the reports, so it's cheap enough for every scrape
+// of the orchestration.
+func (ap *autoPprof) healthInfo() HealthInfo {
+	breaker := ap.breaker.status()
+	info := HealthInfo{
+		Status:       HealthOK,
+		Running:      !ap.stopped(),
+		Watchers:     ap.watchers.snapshot(),
+		Capabilities: ap.capabilities,
+		Reporter: ReporterHealth{
+			Breaker:             breaker.State.String(),
+			ConsecutiveFailures: breaker.ConsecutiveFailures,
+		},
+	}
+	info.Limits.CPUQuota, info.Limits.MemLimitBytes = ap.limits.get()
+	if ap.stats != nil {
+		info.Reporter.LastReportTime = ap.stats.snapshot().LastReportTime
+	}
+	if !info.Running {
+		info.Status = HealthDown
+		info.Reasons = append(info.Reasons, "stopped")
+		return info
+	}
+
+	degraded := func(reason string) {
+		info.Status = HealthDegraded
+		info.Reasons = append(info.Reasons, reason)
+	}
+	names := make([]string, 0, len(info.Watchers))
+	for name := range info.Watchers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w := info.Watchers[name]
+		if !w.Active {
+			degraded(fmt.Sprintf("%s watcher stopped", name))
+			continue
+		}
+		last := w.LastTick
+		if last.IsZero() {
+			last = ap.startedAt
+		}
+		if time.Since(last) > staleWatchIntervals*ap.watchInterval {
+			degraded(fmt.Sprintf("%s watcher stalled", name))
+		}
+	}
+	switch {
+	case breaker.State == BreakerOpen:
+		degraded("reporter breaker open")
+	case breaker.ConsecutiveFailures > 0:
+		degraded("reporter failing")
+	}
+	return info
+}
+
+// sendLiveness sends the liveness marker every livenessInterval.
+// The markers don't go through the circuit breaker, since they're
+// also the signal of the reporter's recovery to the backend.
+func (ap *autoPprof) sendLiveness() {
+	if ap.livenessInterval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(ap.livenessInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := ap.reportLiveness(); err != nil {
+				logger().Println(fmt.Errorf(
+					"autopprof: failed to report the liveness: %w", err,
+				))
+			}
+		case <-ap.stopC:
+			return
+		}
+	}
+}
+
+func (ap *autoPprof) reportLiveness() error {
+	ctx, cancel := context.WithTimeout(context.Background(), ap.timeout())
+	defer cancel()
+
+	pi := report.ProfileInfo{
+		SchemaVersion: report.SchemaVersion,
+		Labels:        ap.labels,
+		Name:          string(report.ProfileKindLiveness),
+	}
+	return report.ReportProfile(
+		ctx, ap.reporter, bytes.NewReader(nil), report.ProfileKindLiveness, pi,
+	)
+}
+
+func (ap *autoPprof) watchFDUsage() {
+	if ap.fdThreshold == 0 || ap.disableGoroutineProf {
+		return
+	}
+
+	ap.watchers.start(watcherFD)
+	defer ap.watchers.exit(watcherFD)
+
+	ticker := time.NewTicker(ap.watchInterval)
+	defer ticker.Stop()
+
+	var consecutiveOverThresholdCnt int
+	for {
+		select {
+		case <-ticker.C:
+			ap.watchers.tick(watcherFD)
+			usage, err := ap.fdUsage()
+			if err != nil {
+				logger().Println(err)
+				return
+			}
+			if usage < ap.fdThreshold {
+				// Reset the count if the fd usage goes under the threshold.
+				consecutiveOverThresholdCnt = 0
+				continue
+			}
+
+			if consecutiveOverThresholdCnt == 0 {
+				if err := ap.reportGoroutineProfile(report.GoroutineInfo{
+					SchemaVersion:       report.SchemaVersion,
+					Labels:              ap.labels,
+					Trigger:             report.TriggerFD,
+					ThresholdPercentage: ap.fdThreshold * 100,
+					UsagePercentage:     usage * 100,
+				}); err != nil {
+					logger().Println(fmt.Errorf(
+						"autopprof: failed to report the goroutine profile: %w", err,
+					))
+				}
+			}
+
+			consecutiveOverThresholdCnt++
+			if consecutiveOverThresholdCnt >= ap.minConsecutiveOverThreshold {
+				// Reset the count and ready to report the goroutine profile again.
+				consecutiveOverThresholdCnt = 0
+			}
+		case <-ap.stopC:
+			return
+		}
+	}
+}
+
+func (ap *autoPprof) watchPIDUsage() {
+	if ap.pidThreshold == 0 || ap.disableGoroutineProf {
+		return
+	}
+	if _, err := ap.pidUsage(); errors.Is(err, ErrPIDLimitUnavailable) {
+		logger().Println(fmt.Errorf(
+			"autopprof: disable the pid threshold: %w", err,
+		))
+		return
+	}
+
+	ap.watchers.start(watcherPID)
+	defer ap.watchers.exit(watcherPID)
+
+	ticker := time.NewTicker(ap.watchInterval)
+	defer ticker.Stop()
+
+	var consecutiveOverThresholdCnt int
+	for {
+		select {
+		case <-ticker.C:
+			ap.watchers.tick(watcherPID)
+			stat, err := ap.pidUsage()
+			if err != nil {
+				logger().Println(err)
+				return
+			}
+			usage := stat.ratio()
+			if usage < ap.pidThreshold {
+				// Reset the count if the pid usage goes under the threshold.
+				consecutiveOverThresholdCnt = 0
+				continue
+			}
+
+			if consecutiveOverThresholdCnt == 0 {
+				if err := ap.reportGoroutineProfile(report.GoroutineInfo{
+					SchemaVersion:       report.SchemaVersion,
+					Labels:              ap.labels,
+					Trigger:             report.TriggerPID,
+					ThresholdPercentage: ap.pidThreshold * 100,
+					UsagePercentage:     usage * 100,
+					PIDs:                stat.current,
+					PIDLimit:            stat.max,
+				}); err != nil {
+					logger().Println(fmt.Errorf(
+						"autopprof: failed to report the goroutine profile: %w", err,
+					))
+				}
+			}
+
+			consecutiveOverThresholdCnt++
+			if consecutiveOverThresholdCnt >= ap.minConsecutiveOverThreshold {
+				// Reset the count and ready to report the goroutine profile again.
+				consecutiveOverThresholdCnt = 0
+			}
+		case <-ap.stopC:
+			return
+		}
+	}
+}
+
+func (ap *autoPprof) watchGoroutineCount() {
+	if ap.goroutineThreshold == 0 || ap.disableGoroutineProf {
+		return
+	}
+
+	ap.watchers.start(watcherGoroutine)
+	defer ap.watchers.exit(watcherGoroutine)
+
+	ticker := time.NewTicker(ap.watchInterval)
+	defer ticker.Stop()
+
+	var consecutiveOverThresholdCnt int
+	for {
+		select {
+		case <-ticker.C:
+			ap.watchers.tick(watcherGoroutine)
+			n := ap.numGoroutine()
+			if n < ap.goroutineThreshold {
+				// Reset the count if the goroutines go under the threshold.
+				consecutiveOverThresholdCnt = 0
+				continue
+			}
+
+			// Report only if the goroutines stay over the threshold,
+			//  since they come and go with the load.
+			consecutiveOverThresholdCnt++
+			if consecutiveOverThresholdCnt < ap.minConsecutiveOverThreshold {
+				continue
+			}
+			if err := ap.reportGoroutineProfile(report.GoroutineInfo{
+				SchemaVersion:      report.SchemaVersion,
+				Labels:             ap.labels,
+				Trigger:            report.TriggerGoroutine,
+				Goroutines:         n,
+				GoroutineThreshold: ap.goroutineThreshold,
+			}); err != nil {
+				logger().Println(fmt.Errorf(
+					"autopprof: failed to report the goroutine profile: %w", err,
+				))
+			}
+			// Reset the count and ready to report the goroutine profile again.
+			consecutiveOverThresholdCnt = 0
+		case <-ap.stopC:
+			return
+		}
+	}
+}
+
+func (ap *autoPprof) watchGoroutineDrop() {
+	if ap.goroutineDropThreshold == 0 || ap.disableGoroutineProf {
+		return
+	}
+
+	ap.watchers.start(watcherGoroutineDrop)
+	defer ap.watchers.exit(watcherGoroutineDrop)
+
+	ticker := time.NewTicker(ap.watchInterval)
+	defer ticker.Stop()
+
+	var (
+		prev = ap.numGoroutine()
+		// cooldownCnt is the number of the watches left to skip after
+		//  the report, since the drop is a single watch event.
+		cooldownCnt int
+	)
+	for {
+		select {
+		case <-ticker.C:
+			ap.watchers.tick(watcherGoroutineDrop)
+			cur := ap.numGoroutine()
+			ratio, dropped := ap.goroutineDropped(prev, cur)
+			before := prev
+			prev = cur
+
+			if cooldownCnt > 0 {
+				cooldownCnt--
+				continue
+			}
+			if !dropped {
+				continue
+			}
+			if err := ap.reportGoroutineProfile(report.GoroutineInfo{
+				SchemaVersion:       report.SchemaVersion,
+				Labels:              ap.labels,
+				Trigger:             report.TriggerGoroutineDrop,
+				ThresholdPercentage: ap.goroutineDropThreshold * 100,
+				UsagePercentage:     ratio * 100,
+				GoroutinesBefore:    before,
+				GoroutinesAfter:     cur,
+			}); err != nil {
+				logger().Println(fmt.Errorf(
+					"autopprof: failed to report the goroutine profile: %w", err,
+				))
+			}
+			cooldownCnt = ap.minConsecutiveOverThreshold - 1
+		case <-ap.stopC:
+			return
+		}
+	}
+}
+
+// goroutineDropped returns the ratio of the goroutines gone from
+// the prev to the cur, and reports whether it's over
+// the goroutineDropThreshold.
+func (ap *autoPprof) goroutineDropped(prev, cur int) (float64, bool) {
+	if prev == 0 || cur >= prev {
+		return 0, false
+	}
+	ratio := float64(prev-cur) / float64(prev)
+	return ratio, ratio >= ap.goroutineDropThreshold && prev-cur >= ap.goroutineDropMinCount
+}
+
+func (ap *autoPprof) watchGCPressure() {
+	if ap.gcRateThreshold == 0 && ap.gcPauseThreshold == 0 {
+		return
+	}
+
+	ap.watchers.start(watcherGC)
+	defer ap.watchers.exit(watcherGC)
+
+	ticker := time.NewTicker(ap.watchInterval)
+	defer ticker.Stop()
+
+	var (
+		prev, cur                   runtime.MemStats
+		prevAt                      = time.Now()
+		consecutiveOverThresholdCnt int
+	)
+	ap.readMemStats(&prev)
+	for {
+		select {
+		case <-ticker.C:
+			ap.watchers.tick(watcherGC)
+			ap.readMemStats(&cur)
+			now := time.Now()
+			p := newGCPressure(&prev, &cur, now.Sub(prevAt))
+			prev, prevAt = cur, now
+
+			if !ap.gcPressureHigh(p) {
+				// Reset the count if the gc pressure goes under the thresholds.
+				consecutiveOverThresholdCnt = 0
+				continue
+			}
+
+			if consecutiveOverThresholdCnt == 0 {
+				if err := ap.reportGCHeapProfile(p); err != nil {
+					logger().Println(fmt.Errorf(
+						"autopprof: failed to report the heap profile: %w", err,
+					))
+				}
+			}
+
+			// The gc pressure has no usage band.
+			consecutiveOverThresholdCnt = ap.nextOverThresholdCnt(
+				consecutiveOverThresholdCnt, 0,
+			)
+		case <-ap.stopC:
+			return
+		}
+	}
+}
+
+// gcPressureHigh reports whether the gc pressure is over any of
+// the thresholds.
+func (ap *autoPprof) gcPressureHigh(p gcPressure) bool {
+	return (ap.gcRateThreshold > 0 && p.rate >= ap.gcRateThreshold) ||
+		(ap.gcPauseThreshold > 0 && p.pauseP99 >= ap.gcPauseThreshold)
+}
+
+// reportGCHeapProfile reports the in-use and the allocation views of
+// the heap profile triggered by the gc pressure.
+func (ap *autoPprof) reportGCHeapProfile(p gcPressure) error {
+	// Don't waste the profiling if the reporter is unavailable.
+	releaseTrial, ok := ap.breaker.acquire()
+	if !ok {
+		ap.stats.drop()
+		return nil
+	}
+	defer releaseTrial()
+	// Honor the cooldown of the previous process.
+	if ap.state.inCooldown(stateKindGC, ap.reportCooldown(0)) {
+		return nil
+	}
+	b, err := ap.profileHeap()
+	if err != nil {
+		return err
+	}
+	views, err := heapViews(b, gcHeapSampleTypes)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to parse the heap profile: %w", err)
+	}
+
+	mi := report.MemInfo{
+		SchemaVersion:     report.SchemaVersion,
+		Labels:            ap.labels,
+		Trigger:           report.TriggerGC,
+		TriggerID:         newTriggerID(),
+		GCPerSecond:       p.rate,
+		GCPauseP99:        p.pauseP99,
+		GCRateThreshold:   ap.gcRateThreshold,
+		GCPauseThreshold:  ap.gcPauseThreshold,
+		PrimarySampleType: ap.heapPrimarySampleType,
+	}
+	if err := ap.recordReport(
+		stateKindGC, ap.reportHeapViews(views, gcHeapSampleTypes, mi),
+	); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ap *autoPprof) reportGoroutineProfile(gi report.GoroutineInfo) error {
+	// Don't waste the profiling if the reporter is unavailable.
+	releaseTrial, ok := ap.breaker.acquire()
+	if !ok {
+		ap.stats.drop()
+		return nil
+	}
+	defer releaseTrial()
+	// Honor the cooldown of the previous process.
+	if ap.state.inCooldown(stateKindGoroutine, ap.reportCooldown(0)) {
+		return nil
+	}
+	return ap.sendGoroutineProfile(context.Background(), gi)
+}
+
+// sendGoroutineProfile profiles the goroutines and reports them within
+// the timeout of the ctx.
+func (ap *autoPprof) sendGoroutineProfile(
+	ctx context.Context, gi report.GoroutineInfo,
+) error {
+	b, err := ap.profiler.profileGoroutine()
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to profile the goroutine: %w", err)
+	}
+	gi.Sequence, gi.Elapsed = ap.nextSequence()
+	gi.IncidentID = ap.incidentID(gi.Trigger)
+	gi.CPUQuotaCores, gi.MemLimitBytes = ap.limits.get()
+
+	release := ap.acquireReport()
+	defer release()
+
+	ctx, cancel := context.WithTimeout(ctx, ap.timeout())
+	defer cancel()
+
+	bReader := bytes.NewReader(b)
+	if err := ap.recordReport(
+		stateKindGoroutine, ap.reporter.ReportGoroutineProfile(ctx, bReader, gi),
+	); err != nil {
+		return err
+	}
+	return nil
+}
+
+// replayWAL sends the profiles left in the WAL by the previous process.
+// It's canceled by the stop.
+func (ap *autoPprof) replayWAL() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-ap.stopC:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if err := ap.wal.Replay(ctx); err != nil {
+		logger().Println(err)
+	}
+}
+
+func (ap *autoPprof) stop() {
+	ap.stopOnce.Do(ap.doStop)
+}
+
+// stopped reports whether the process is stopped.
+func (ap *autoPprof) stopped() bool {
+	select {
+	case <-ap.stopC:
+		return true
+	default:
+		return false
+	}
+}
+
+func (ap *autoPprof) doStop() {
+	close(ap.stopC)
+	if ap.prevMemProfileRate != 0 {
+		runtime.MemProfileRate = ap.prevMemProfileRate
+	}
+	// Give the contention profiling back to the application as it was.
+	if ap.blockProfileRate > 0 {
+		runtime.SetBlockProfileRate(ap.prevBlockProfileRate)
+	}
+	if ap.mutexProfileFraction > 0 {
+		runtime.SetMutexProfileFraction(ap.prevMutexProfileFraction)
+	}
+	if f, ok := ap.reporter.(report.Flusher); ok {
+		// Send the reports buffered by the reporter.
+		ctx, cancel := context.WithTimeout(context.Background(), ap.timeout())
+		defer cancel()
+		if err := f.Flush(ctx); err != nil {
+			logger().Println(fmt.Errorf(
+				"autopprof: failed to flush the reporter: %w", err,
+			))
+		}
+	}
+	if ap.wal != nil {
+		if err := ap.wal.Close(); err != nil {
+			logger().Println(fmt.Errorf(
+				"autopprof: failed to close the WAL: %w", err,
+			))
+		}
+	}
 }
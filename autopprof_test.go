@@ -4,9 +4,17 @@
 package autopprof
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"io"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -43,6 +51,186 @@ func TestStart(t *testing.T) {
 			},
 			want: ErrInvalidCPUThreshold,
 		},
+		{
+			name: "invalid CPUThreshold value 3",
+			opt: Option{
+				CPUThreshold:  2.5,
+				CPUUsageBasis: CPUUsageAllCores,
+			},
+			want: ErrInvalidCPUThreshold,
+		},
+		{
+			name: "invalid CPUUsageBasis value",
+			opt: Option{
+				CPUUsageBasis: CPUUsageAllCores + 1,
+			},
+			want: ErrInvalidCPUUsageBasis,
+		},
+		{
+			name: "invalid CPUPressureThreshold value",
+			opt: Option{
+				CPUPressureThreshold: 1.5,
+			},
+			want: ErrInvalidCPUPressureThreshold,
+		},
+		{
+			name: "invalid WALMaxBytes value",
+			opt: Option{
+				WALMaxBytes: -1,
+			},
+			want: ErrInvalidWALMaxBytes,
+		},
+		{
+			name: "invalid CPUProfilingDuration value",
+			opt: Option{
+				CPUProfilingDuration: -time.Second,
+			},
+			want: ErrInvalidCPUProfilingDuration,
+		},
+		{
+			name: "invalid WatchInterval value",
+			opt: Option{
+				WatchInterval: -time.Second,
+			},
+			want: ErrInvalidWatchInterval,
+		},
+		{
+			name: "invalid MinConsecutiveOverThreshold value",
+			opt: Option{
+				MinConsecutiveOverThreshold: -1,
+			},
+			want: ErrInvalidMinConsecutiveOverThreshold,
+		},
+		{
+			name: "SampleInterval longer than the WatchInterval",
+			opt: Option{
+				WatchInterval:  time.Second,
+				SampleInterval: 2 * time.Second,
+			},
+			want: ErrInvalidSampleInterval,
+		},
+		{
+			name: "invalid SampleInterval value",
+			opt: Option{
+				SampleInterval: defaultWatchInterval + time.Second,
+			},
+			want: ErrInvalidSampleInterval,
+		},
+		{
+			name: "invalid CPUQuotaRefreshInterval value",
+			opt: Option{
+				CPUQuotaRefreshInterval: -time.Second,
+			},
+			want: ErrInvalidCPUQuotaRefreshInterval,
+		},
+		{
+			name: "invalid GoroutineDropThreshold value",
+			opt: Option{
+				GoroutineDropThreshold: 1.5,
+			},
+			want: ErrInvalidGoroutineDropThreshold,
+		},
+		{
+			name: "invalid HeapPrimarySampleType value",
+			opt: Option{
+				HeapPrimarySampleType: "inuse",
+			},
+			want: ErrInvalidHeapSampleType,
+		},
+		{
+			name: "invalid HeapSampleReduction value",
+			opt: Option{
+				HeapSampleReduction: 1,
+			},
+			want: ErrInvalidHeapSampleReduction,
+		},
+		{
+			name: "invalid MemGrowthThreshold value",
+			opt: Option{
+				MemGrowthThreshold: -0.5,
+			},
+			want: ErrInvalidMemGrowthThreshold,
+		},
+		{
+			name: "invalid LatencyBreachDebounce value",
+			opt: Option{
+				LatencyBreachDebounce: -time.Second,
+			},
+			want: ErrInvalidLatencyBreachDebounce,
+		},
+		{
+			name: "invalid NUMAThreshold value",
+			opt: Option{
+				NUMAThreshold: 1.5,
+			},
+			want: ErrInvalidNUMAThreshold,
+		},
+		{
+			name: "MemRequestThreshold without MemRequestBytes",
+			opt: Option{
+				MemRequestThreshold: 1,
+			},
+			want: ErrInvalidMemRequestThreshold,
+		},
+		{
+			name: "invalid SummaryLogTemplate",
+			opt: Option{
+				SummaryLogTemplate: "{{.Kind",
+			},
+			want: ErrInvalidSummaryLogTemplate,
+		},
+		{
+			name: "invalid ErrorRateThreshold value",
+			opt: Option{
+				ErrorRateThreshold: -1,
+			},
+			want: ErrInvalidErrorRate,
+		},
+		{
+			name: "invalid ErrorRateWindow value",
+			opt: Option{
+				ErrorRateThreshold: 1,
+				ErrorRateWindow:    -time.Second,
+			},
+			want: ErrInvalidErrorRate,
+		},
+		{
+			name: "invalid StartupCaptureDelay value",
+			opt: Option{
+				CaptureOnStartup:    true,
+				StartupCaptureDelay: -time.Second,
+			},
+			want: ErrInvalidStartupCaptureDelay,
+		},
+		{
+			name: "invalid IncidentWindow value",
+			opt: Option{
+				IncidentWindow: -time.Second,
+			},
+			want: ErrInvalidIncidentWindow,
+		},
+		{
+			name: "invalid CPUBaselineBucket value",
+			opt: Option{
+				CPUBaselineDeviation: 0.5,
+				CPUBaselineBucket:    25 * time.Hour,
+			},
+			want: ErrInvalidCPUBaseline,
+		},
+		{
+			name: "invalid UsageSmoothingAlpha value",
+			opt: Option{
+				UsageSmoothingAlpha: 1.5,
+			},
+			want: ErrInvalidUsageSmoothingAlpha,
+		},
+		{
+			name: "invalid BurstCount value",
+			opt: Option{
+				BurstCount: -1,
+			},
+			want: ErrInvalidBurst,
+		},
 		{
 			name: "invalid MemThreshold value 1",
 			opt: Option{
@@ -57,6 +245,215 @@ func TestStart(t *testing.T) {
 			},
 			want: ErrInvalidMemThreshold,
 		},
+		{
+			name: "CPUWarnThreshold is higher than CPUThreshold",
+			opt: Option{
+				CPUThreshold:     0.5,
+				CPUWarnThreshold: 0.6,
+			},
+			want: ErrInvalidCPUWarnThreshold,
+		},
+		{
+			name: "MemWarnThreshold is higher than default MemThreshold",
+			opt: Option{
+				MemWarnThreshold: 0.8,
+			},
+			want: ErrInvalidMemWarnThreshold,
+		},
+		{
+			name: "negative WarnCooldown",
+			opt: Option{
+				WarnCooldown: -time.Second,
+			},
+			want: ErrInvalidWarnCooldown,
+		},
+		{
+			name: "invalid FDThreshold value",
+			opt: Option{
+				FDThreshold: 1.5,
+			},
+			want: ErrInvalidFDThreshold,
+		},
+		{
+			name: "invalid CPUProfileRate value",
+			opt: Option{
+				CPUProfileRate: 5000,
+			},
+			want: ErrInvalidCPUProfileRate,
+		},
+		{
+			name: "LowPriorityCapture with LockOSThread",
+			opt: Option{
+				LowPriorityCapture: true,
+				LockOSThread:       true,
+			},
+			want: ErrInvalidLowPriorityCapture,
+		},
+		{
+			name: "invalid MemProfileRate value",
+			opt: Option{
+				MemProfileRate: -1,
+			},
+			want: ErrInvalidMemProfileRate,
+		},
+		{
+			name: "invalid BlockProfileRate value",
+			opt: Option{
+				BlockProfileRate: -1,
+			},
+			want: ErrInvalidBlockProfileRate,
+		},
+		{
+			name: "invalid MutexProfileFraction value",
+			opt: Option{
+				MutexProfileFraction: -1,
+			},
+			want: ErrInvalidMutexProfileFraction,
+		},
+		{
+			name: "invalid MemLimitMode value",
+			opt: Option{
+				MemLimitMode: MemLimitBoth + 1,
+			},
+			want: ErrInvalidMemLimitMode,
+		},
+		{
+			name: "invalid MemUsageComponents value",
+			opt: Option{
+				MemUsageComponents: MemUsageSwap << 1,
+			},
+			want: ErrInvalidMemUsageComponents,
+		},
+		{
+			name: "invalid PIDThreshold value",
+			opt: Option{
+				PIDThreshold: 1.5,
+			},
+			want: ErrInvalidPIDThreshold,
+		},
+		{
+			name: "invalid GoroutineThreshold value",
+			opt: Option{
+				GoroutineThreshold: -1,
+			},
+			want: ErrInvalidGoroutineThreshold,
+		},
+		{
+			name: "invalid UsagePercentile value",
+			opt: Option{
+				UsagePercentile: 1.5,
+			},
+			want: ErrInvalidUsagePercentile,
+		},
+		{
+			name: "invalid CPUProfilingBudget value",
+			opt: Option{
+				CPUProfilingBudget: -time.Second,
+			},
+			want: ErrInvalidCPUProfilingBudget,
+		},
+		{
+			name: "invalid GCPauseThreshold value",
+			opt: Option{
+				GCPauseThreshold: -time.Millisecond,
+			},
+			want: ErrInvalidGCThreshold,
+		},
+		{
+			name: "invalid ReportTimeout value",
+			opt: Option{
+				ReportTimeout: -time.Second,
+			},
+			want: ErrInvalidReportTimeout,
+		},
+		{
+			name: "invalid MaxConcurrentReports value",
+			opt: Option{
+				MaxConcurrentReports: -1,
+			},
+			want: ErrInvalidMaxConcurrentReports,
+		},
+		{
+			name: "invalid SeverityBasedCooldown value",
+			opt: Option{
+				SeverityBasedCooldown: []SeverityCooldown{
+					{Usage: 0.75, Cooldown: time.Minute},
+					{Usage: 0.95, Cooldown: 2 * time.Minute},
+				},
+			},
+			want: ErrInvalidSeverityBasedCooldown,
+		},
+		{
+			name: "invalid LivenessInterval value",
+			opt: Option{
+				LivenessInterval: -time.Second,
+			},
+			want: ErrInvalidLivenessInterval,
+		},
+		{
+			name: "liveness with the reporter not implementing the report.ProfileReporter",
+			opt: Option{
+				LivenessInterval: time.Minute,
+				Reporter:         report.NewSlackReporter(&report.SlackReporterOption{}),
+			},
+			want: ErrInvalidLivenessInterval,
+		},
+		{
+			name: "wall-clock with the reporter not implementing the report.ProfileReporter",
+			opt: Option{
+				EnableWallClock: true,
+				Reporter:        report.NewSlackReporter(&report.SlackReporterOption{}),
+			},
+			want: ErrInvalidWallClock,
+		},
+		{
+			name: "allocs diff with the reporter not implementing the report.ProfileReporter",
+			opt: Option{
+				ReportAllocsDiff: true,
+				Reporter:         report.NewSlackReporter(&report.SlackReporterOption{}),
+			},
+			want: ErrInvalidAllocsDiff,
+		},
+		{
+			name: "cpu diff with the reporter not implementing the report.ProfileReporter",
+			opt: Option{
+				ReportCPUDiff: true,
+				Reporter:      report.NewSlackReporter(&report.SlackReporterOption{}),
+			},
+			want: ErrInvalidCPUDiff,
+		},
+		{
+			name: "block profile with the reporter not implementing the report.ProfileReporter",
+			opt: Option{
+				BlockProfileRate: 1,
+				Reporter:         report.NewSlackReporter(&report.SlackReporterOption{}),
+			},
+			want: ErrInvalidContentionProfiles,
+		},
+		{
+			name: "mutex profile with the reporter not implementing the report.ProfileReporter",
+			opt: Option{
+				MutexProfileFraction: 1,
+				Reporter:             report.NewSlackReporter(&report.SlackReporterOption{}),
+			},
+			want: ErrInvalidContentionProfiles,
+		},
+		{
+			name: "invalid LargeHeapSampleReduction value",
+			opt: Option{
+				MaxHeapProfileSize:       1 << 30,
+				LargeHeapSampleReduction: 1,
+			},
+			want: ErrInvalidHeapSampleReduction,
+		},
+		{
+			name: "invalid ReporterFailureThreshold value",
+			opt: Option{
+				ReporterFailureThreshold: -1,
+				Reporter:                 report.NewSlackReporter(&report.SlackReporterOption{}),
+			},
+			want: ErrInvalidReporterBreaker,
+		},
 		{
 			name: "when given reporter is nil",
 			opt: Option{
@@ -91,6 +488,21 @@ func TestStart(t *testing.T) {
 			},
 			want: nil,
 		},
+		{
+			name: "valid option 3",
+			opt: Option{
+				CPUThreshold:  1.5,
+				CPUUsageBasis: CPUUsageSingleCore,
+				Reporter: report.NewSlackReporter(
+					&report.SlackReporterOption{
+						App:     "appname",
+						Token:   "token",
+						Channel: "channel",
+					},
+				),
+			},
+			want: nil,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -146,38 +558,170 @@ func TestStop(t *testing.T) {
 	}
 }
 
-func TestAutoPprof_loadCPUQuota(t *testing.T) {
-	testCases := []struct {
-		name                   string
-		newAp                  func() *autoPprof
-		wantDisableCPUProfFlag bool
-		wantErr                error
-	}{
-		{
-			name: "cpu quota is set",
-			newAp: func() *autoPprof {
-				ctrl := gomock.NewController(t)
-
-				mockQueryer := NewMockqueryer(ctrl)
-				mockQueryer.EXPECT().
-					setCPUQuota().
-					Return(nil) // Means that the quota is set correctly.
-
-				return &autoPprof{
-					queryer:        mockQueryer,
-					disableCPUProf: false,
-					disableMemProf: false,
-				}
+func TestStartWithContext(t *testing.T) {
+	t.Cleanup(func() {
+		globalAp = nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := StartWithContext(ctx, Option{
+		MemThreshold: 0.5,
+		Reporter: report.NewSlackReporter(
+			&report.SlackReporterOption{
+				App:     "appname",
+				Token:   "token",
+				Channel: "channel",
 			},
-			wantDisableCPUProfFlag: false,
-			wantErr:                nil,
-		},
-		{
-			name: "cpu quota isn't set and memory profiling is enabled",
-			newAp: func() *autoPprof {
-				ctrl := gomock.NewController(t)
+		),
+	})
+	if err != nil {
+		t.Skipf("StartWithContext() = %v", err)
+	}
 
-				mockQueryer := NewMockqueryer(ctrl)
+	cancel()
+	select {
+	case <-globalAp.stopC:
+	case <-time.After(time.Second):
+		t.Fatalf("the autopprof isn't stopped by the context")
+	}
+	Stop() // Expect no panic after the context stopped it.
+}
+
+func TestStart_failureKeepsRuntimeRates(t *testing.T) {
+	t.Cleanup(func() {
+		globalAp = nil
+	})
+
+	prevMem := runtime.MemProfileRate
+	prevMutex := runtime.SetMutexProfileFraction(-1) // Read only.
+	t.Cleanup(func() {
+		runtime.MemProfileRate = prevMem
+		runtime.SetBlockProfileRate(0)
+		runtime.SetMutexProfileFraction(prevMutex)
+	})
+	err := Start(Option{
+		MemThreshold:         0.5,
+		MemProfileRate:       4096,
+		BlockProfileRate:     1,
+		MutexProfileFraction: 1,
+		WALPath:              filepath.Join(t.TempDir(), "wal"),
+		// The directory to watch doesn't exist.
+		TriggerFilePath: filepath.Join(t.TempDir(), "missing", "capture"),
+		Reporter:        report.NewWriterReporter(io.Discard),
+	})
+	if err == nil {
+		Stop()
+		t.Fatalf("Start() = nil, want the error of the trigger file")
+	}
+	if !strings.Contains(err.Error(), "trigger file") {
+		t.Skipf("Start() = %v, want the error of the trigger file", err)
+	}
+	if runtime.MemProfileRate != prevMem {
+		t.Errorf("MemProfileRate = %d, want %d", runtime.MemProfileRate, prevMem)
+	}
+	if got := runtime.SetMutexProfileFraction(-1); got != prevMutex {
+		t.Errorf("mutex profile fraction = %d, want %d", got, prevMutex)
+	}
+}
+
+func TestAutoPprof_stop_memProfileRate(t *testing.T) {
+	prev := runtime.MemProfileRate
+	t.Cleanup(func() {
+		runtime.MemProfileRate = prev
+	})
+
+	ap := &autoPprof{
+		prevMemProfileRate: prev,
+		stopC:              make(chan struct{}),
+	}
+	runtime.MemProfileRate = 4096 // Set by the Start.
+	ap.stop()
+	if runtime.MemProfileRate != prev {
+		t.Errorf("MemProfileRate = %d, want %d", runtime.MemProfileRate, prev)
+	}
+}
+
+func TestAutoPprof_stop_contentionRates(t *testing.T) {
+	prevMutex := runtime.SetMutexProfileFraction(3) // Set by the application.
+	t.Cleanup(func() {
+		runtime.SetBlockProfileRate(0)
+		runtime.SetMutexProfileFraction(prevMutex)
+	})
+
+	ap := &autoPprof{
+		blockProfileRate:     1,
+		mutexProfileFraction: 1,
+		prevBlockProfileRate: 100,
+		// As returned by the runtime.SetMutexProfileFraction at the Start.
+		prevMutexProfileFraction: runtime.SetMutexProfileFraction(1),
+		stopC:                    make(chan struct{}),
+	}
+	runtime.SetBlockProfileRate(1)
+	ap.stop()
+	if got := runtime.SetMutexProfileFraction(-1); got != 3 {
+		t.Errorf("mutex profile fraction = %d, want 3 of the application", got)
+	}
+}
+
+func TestAutoPprof_stop_flush(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockReporter := report.NewMockBundleReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportBundle(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+
+	batch := report.NewBatchReporter(mockReporter, &report.BatchReporterOption{
+		FlushInterval: time.Hour,
+	})
+	_ = batch.ReportCPUProfile(context.Background(), bytes.NewReader([]byte("prof")), report.CPUInfo{})
+
+	ap := &autoPprof{
+		reporter: batch,
+		stopC:    make(chan struct{}),
+	}
+	ap.stop() // Expect the batch to be flushed.
+}
+
+func TestAutoPprof_loadCPUQuota(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		newAp                  func() *autoPprof
+		wantDisableCPUProfFlag bool
+		wantErr                error
+		wantCPUQuota           float64
+	}{
+		{
+			name: "cpu quota is set",
+			newAp: func() *autoPprof {
+				ctrl := gomock.NewController(t)
+
+				mockQueryer := NewMockqueryer(ctrl)
+				mockQueryer.EXPECT().
+					setCPUQuota().
+					Return(nil) // Means that the quota is set correctly.
+				mockQueryer.EXPECT().
+					status().
+					Return(CgroupStatus{CPUQuota: 2})
+
+				return &autoPprof{
+					queryer:        mockQueryer,
+					limits:         newCgroupLimits(),
+					disableCPUProf: false,
+					disableMemProf: false,
+				}
+			},
+			wantDisableCPUProfFlag: false,
+			wantErr:                nil,
+			wantCPUQuota:           2,
+		},
+		{
+			name: "cpu quota isn't set and memory profiling is enabled",
+			newAp: func() *autoPprof {
+				ctrl := gomock.NewController(t)
+
+				mockQueryer := NewMockqueryer(ctrl)
 				mockQueryer.EXPECT().
 					setCPUQuota().
 					Return(ErrV2CPUQuotaUndefined)
@@ -221,10 +765,78 @@ func TestAutoPprof_loadCPUQuota(t *testing.T) {
 			if ap.disableCPUProf != tc.wantDisableCPUProfFlag {
 				t.Errorf("disableCPUProf = %v, want %v", ap.disableCPUProf, tc.wantDisableCPUProfFlag)
 			}
+			if cpuQuota, _ := ap.limits.get(); cpuQuota != tc.wantCPUQuota {
+				t.Errorf("limits cpu quota = %v, want %v", cpuQuota, tc.wantCPUQuota)
+			}
 		})
 	}
 }
 
+func TestAutoPprof_refreshCPUQuota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockQueryer := NewMockqueryer(ctrl)
+	gomock.InOrder(
+		// Resized by the VPA.
+		mockQueryer.EXPECT().
+			setCPUQuota().
+			Return(nil),
+		mockQueryer.EXPECT().
+			status().
+			Return(CgroupStatus{CPUQuota: 4}),
+		// The limit is removed, so the last quota is kept.
+		mockQueryer.EXPECT().
+			setCPUQuota().
+			Return(ErrV2CPUQuotaUndefined),
+	)
+
+	ap := &autoPprof{
+		queryer: mockQueryer,
+		limits:  newCgroupLimits(),
+	}
+	ap.limits.setCPUQuota(2)
+
+	ap.refreshCPUQuota()
+	if cpuQuota, _ := ap.limits.get(); cpuQuota != 4 {
+		t.Errorf("limits cpu quota = %v, want 4", cpuQuota)
+	}
+	ap.refreshCPUQuota()
+	if cpuQuota, _ := ap.limits.get(); cpuQuota != 4 {
+		t.Errorf("limits cpu quota = %v, want 4", cpuQuota)
+	}
+}
+
+func TestAutoPprof_refreshCPUQuota_interval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		setCPUQuota().
+		Return(nil)
+	mockQueryer.EXPECT().
+		status().
+		Return(CgroupStatus{CPUQuota: 4})
+
+	ap := &autoPprof{
+		queryer:                 mockQueryer,
+		limits:                  newCgroupLimits(),
+		cpuQuotaRefreshInterval: time.Minute,
+		cpuQuotaRefreshedAt:     time.Now(),
+	}
+	ap.limits.setCPUQuota(2)
+
+	// Not re-read within the interval.
+	ap.refreshCPUQuota()
+	if cpuQuota, _ := ap.limits.get(); cpuQuota != 2 {
+		t.Errorf("limits cpu quota = %v, want 2 within the interval", cpuQuota)
+	}
+	ap.cpuQuotaRefreshedAt = time.Now().Add(-time.Minute)
+	ap.refreshCPUQuota()
+	if cpuQuota, _ := ap.limits.get(); cpuQuota != 4 {
+		t.Errorf("limits cpu quota = %v, want 4 after the interval", cpuQuota)
+	}
+}
+
 func TestAutoPprof_watchCPUUsage(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
@@ -288,6 +900,54 @@ func TestAutoPprof_watchCPUUsage(t *testing.T) {
 	}
 }
 
+func TestAutoPprof_watchCPUUsage_smoothing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	// The cpu usage spikes once, and then stays.
+	var (
+		mu     sync.Mutex
+		usages = []float64{0.2, 0.9, 0.2}
+	)
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		cpuUsage().
+		AnyTimes().
+		DoAndReturn(
+			func() (float64, error) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				usage := usages[0]
+				if len(usages) > 1 {
+					usages = usages[1:]
+				}
+				return usage, nil
+			},
+		)
+
+	// The smoothed spike doesn't cross the threshold, so nothing is
+	//  profiled nor reported.
+	ap := &autoPprof{
+		disableMemProf: true,
+		watchInterval:  100 * time.Millisecond,
+		cpuThreshold:   0.75, // 75%.
+		cpuFilter:      newUsageFilter(0.5),
+		queryer:        mockQueryer,
+		profiler:       NewMockprofiler(ctrl),
+		reporter:       report.NewMockReporter(ctrl),
+		stopC:          make(chan struct{}),
+	}
+
+	go ap.watchCPUUsage()
+	t.Cleanup(func() { ap.stop() })
+
+	time.Sleep(350 * time.Millisecond)
+	st := ap.cpuFilter.status()
+	if st.Raw != 0.2 || st.Smoothed <= st.Raw || st.Smoothed >= ap.cpuThreshold {
+		t.Errorf("cpuFilter.status() = %+v, want the raw 0.2 and the smoothed above it", st)
+	}
+}
+
 func TestAutoPprof_watchCPUUsage_consecutive(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
@@ -379,320 +1039,484 @@ func TestAutoPprof_watchCPUUsage_consecutive(t *testing.T) {
 	}
 }
 
-func TestAutoPprof_watchCPUUsage_reportBoth(t *testing.T) {
-	type fields struct {
-		watchInterval  time.Duration
-		cpuThreshold   float64
-		reportBoth     bool
-		disableMemProf bool
-		stopC          chan struct{}
-	}
-	testCases := []struct {
-		name     string
-		fields   fields
-		mockFunc func(*Mockqueryer, *Mockprofiler, *report.MockReporter)
-	}{
-		{
-			name: "reportBoth: true",
-			fields: fields{
-				watchInterval:  1 * time.Second,
-				cpuThreshold:   0.5, // 50%.
-				reportBoth:     true,
-				disableMemProf: false,
-				stopC:          make(chan struct{}),
-			},
-			mockFunc: func(mockQueryer *Mockqueryer, mockProfiler *Mockprofiler, mockReporter *report.MockReporter) {
-				gomock.InOrder(
-					mockQueryer.EXPECT().
-						cpuUsage().
-						AnyTimes().
-						Return(0.6, nil),
+func TestAutoPprof_watchCPUUsage_warn(t *testing.T) {
+	ctrl := gomock.NewController(t)
 
-					mockProfiler.EXPECT().
-						profileCPU().
-						AnyTimes().
-						Return([]byte("cpu_prof"), nil),
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		cpuUsage().
+		AnyTimes().
+		Return(0.6, nil)
 
-					mockReporter.EXPECT().
-						ReportCPUProfile(gomock.Any(), gomock.Any(), report.CPUInfo{
-							ThresholdPercentage: 0.5 * 100,
-							UsagePercentage:     0.6 * 100,
-						}).
-						AnyTimes().
-						Return(nil),
+	// No profiling and reporting are expected.
+	mockProfiler := NewMockprofiler(ctrl)
+	mockReporter := report.NewMockReporter(ctrl)
 
-					mockQueryer.EXPECT().
-						memUsage().
-						AnyTimes().
-						Return(0.2, nil),
+	eventC := make(chan Event, 10)
+	ap := &autoPprof{
+		disableMemProf:              true,
+		watchInterval:               100 * time.Millisecond,
+		cpuThreshold:                0.9, // 90%.
+		cpuWarnThreshold:            0.5, // 50%.
+		minConsecutiveOverThreshold: 3,
+		onEvent:                     func(ev Event) { eventC <- ev },
+		queryer:                     mockQueryer,
+		profiler:                    mockProfiler,
+		reporter:                    mockReporter,
+		stopC:                       make(chan struct{}),
+	}
 
-					mockProfiler.EXPECT().
-						profileHeap().
-						AnyTimes().
-						Return([]byte("mem_prof"), nil),
+	go ap.watchCPUUsage()
+	t.Cleanup(func() { ap.stop() })
 
-					mockReporter.EXPECT().
-						ReportHeapProfile(gomock.Any(), gomock.Any(), report.MemInfo{
-							ThresholdPercentage: 0.5 * 100,
-							UsagePercentage:     0.2 * 100,
-						}).
-						AnyTimes().
-						Return(nil),
-				)
-			},
-		},
-		{
-			name: "reportBoth: true, disableMemProf: true",
-			fields: fields{
-				watchInterval:  1 * time.Second,
-				cpuThreshold:   0.5, // 50%.
-				reportBoth:     true,
-				disableMemProf: true,
-				stopC:          make(chan struct{}),
-			},
-			mockFunc: func(mockQueryer *Mockqueryer, mockProfiler *Mockprofiler, mockReporter *report.MockReporter) {
-				gomock.InOrder(
-					mockQueryer.EXPECT().
-						cpuUsage().
-						AnyTimes().
-						Return(0.6, nil),
+	// Wait for 3 ticks. Only the 1st tick emits the warning.
+	time.Sleep(350 * time.Millisecond)
+	if got := len(eventC); got != 1 {
+		t.Errorf("number of events = %d, want 1", got)
+		t.FailNow()
+	}
+	ev := <-eventC
+	if ev.Type != EventCPUWarning {
+		t.Errorf("event type = %s, want %s", ev.Type, EventCPUWarning)
+	}
+	if ev.ThresholdPercentage != 0.5*100 || ev.UsagePercentage != 0.6*100 {
+		t.Errorf("event = %+v, want 50%% threshold and 60%% usage", ev)
+	}
+}
 
-					mockProfiler.EXPECT().
-						profileCPU().
-						AnyTimes().
-						Return([]byte("cpu_prof"), nil),
+func TestAutoPprof_warn(t *testing.T) {
+	var events []Event
+	ap := &autoPprof{
+		watchInterval:               100 * time.Millisecond,
+		minConsecutiveOverThreshold: 12,
+		warnCooldown:                time.Hour,
+		onEvent:                     func(ev Event) { events = append(events, ev) },
+	}
 
-					mockReporter.EXPECT().
-						ReportCPUProfile(gomock.Any(), gomock.Any(), report.CPUInfo{
-							ThresholdPercentage: 0.5 * 100,
-							UsagePercentage:     0.6 * 100,
-						}).
-						AnyTimes().
-						Return(nil),
-				)
-			},
-		},
-		{
-			name: "reportBoth: false",
-			fields: fields{
-				watchInterval:  1 * time.Second,
-				cpuThreshold:   0.5, // 50%.
-				reportBoth:     false,
-				disableMemProf: false,
-				stopC:          make(chan struct{}),
-			},
-			mockFunc: func(mockQueryer *Mockqueryer, mockProfiler *Mockprofiler, mockReporter *report.MockReporter) {
-				gomock.InOrder(
-					mockQueryer.EXPECT().
-						cpuUsage().
-						AnyTimes().
-						Return(0.6, nil),
+	lastWarnedAt := ap.warn(EventMemWarning, 0.6, 0.5, 0.9, time.Time{})
+	if len(events) != 1 || lastWarnedAt.IsZero() {
+		t.Fatalf("warn() emitted %d events, want 1", len(events))
+	}
+	// The usage wavering around the warning threshold doesn't emit
+	//  the warning again within the cooldown.
+	got := ap.warn(EventMemWarning, 0.4, 0.5, 0.9, lastWarnedAt)
+	got = ap.warn(EventMemWarning, 0.6, 0.5, 0.9, got)
+	if len(events) != 1 || !got.Equal(lastWarnedAt) {
+		t.Errorf("warn() emitted %d events within the cooldown, want 1", len(events))
+	}
+	// After the cooldown, it's emitted again.
+	ap.warnCooldown = time.Nanosecond
+	if got = ap.warn(EventMemWarning, 0.6, 0.5, 0.9, got); len(events) != 2 || got.Equal(lastWarnedAt) {
+		t.Errorf("warn() emitted %d events after the cooldown, want 2", len(events))
+	}
+}
 
-					mockProfiler.EXPECT().
-						profileCPU().
-						AnyTimes().
-						Return([]byte("cpu_prof"), nil),
+func TestAutoPprof_watchCPUUsage_edgeTriggered(t *testing.T) {
+	ctrl := gomock.NewController(t)
 
-					mockReporter.EXPECT().
-						ReportCPUProfile(gomock.Any(), gomock.Any(), report.CPUInfo{
-							ThresholdPercentage: 0.5 * 100,
-							UsagePercentage:     0.6 * 100,
-						}).
-						AnyTimes().
-						Return(nil),
-				)
+	// Over the threshold for 4 ticks, and then under the threshold.
+	var queriedCnt int
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		cpuUsage().
+		AnyTimes().
+		DoAndReturn(
+			func() (float64, error) {
+				queriedCnt++
+				if queriedCnt <= 4 {
+					return 0.6, nil
+				}
+				return 0.2, nil
 			},
-		},
-	}
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			ctrl := gomock.NewController(t)
+		)
 
-			mockQueryer := NewMockqueryer(ctrl)
-			mockProfiler := NewMockprofiler(ctrl)
-			mockReporter := report.NewMockReporter(ctrl)
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileCPU().
+		Return([]byte("prof"), nil)
 
-			ap := &autoPprof{
-				watchInterval:  tc.fields.watchInterval,
-				cpuThreshold:   tc.fields.cpuThreshold,
-				memThreshold:   0.5, // 50%.
-				queryer:        mockQueryer,
-				profiler:       mockProfiler,
-				reporter:       mockReporter,
-				reportBoth:     tc.fields.reportBoth,
-				disableMemProf: tc.fields.disableMemProf,
-				stopC:          tc.fields.stopC,
-			}
+	// Only the crossing is reported, although the usage stays over
+	//  the threshold longer than the minConsecutiveOverThreshold.
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
 
-			tc.mockFunc(mockQueryer, mockProfiler, mockReporter)
+	eventC := make(chan Event, 10)
+	ap := &autoPprof{
+		disableMemProf:              true,
+		watchInterval:               100 * time.Millisecond,
+		cpuThreshold:                0.5, // 50%.
+		minConsecutiveOverThreshold: 2,
+		edgeTriggered:               true,
+		emitRecoveryEvents:          true,
+		onEvent:                     func(ev Event) { eventC <- ev },
+		queryer:                     mockQueryer,
+		profiler:                    mockProfiler,
+		reporter:                    mockReporter,
+		stopC:                       make(chan struct{}),
+	}
 
-			go ap.watchCPUUsage()
-			defer ap.stop()
+	go ap.watchCPUUsage()
+	t.Cleanup(func() { ap.stop() })
 
-			// Wait for profiling and reporting.
-			time.Sleep(1050 * time.Millisecond)
-		})
+	// Wait for 6 ticks. The 5th tick emits the recovery.
+	time.Sleep(650 * time.Millisecond)
+	if got := len(eventC); got != 1 {
+		t.Errorf("number of events = %d, want 1", got)
+		t.FailNow()
+	}
+	if ev := <-eventC; ev.Type != EventCPURecovered {
+		t.Errorf("event type = %s, want %s", ev.Type, EventCPURecovered)
 	}
 }
 
-func TestAutoPprof_watchMemUsage(t *testing.T) {
+func TestAutoPprof_watchCPUUsage_severityBasedCooldown(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
-	var (
-		profiled bool
-		reported bool
-	)
-
 	mockQueryer := NewMockqueryer(ctrl)
 	mockQueryer.EXPECT().
-		memUsage().
+		cpuUsage().
 		AnyTimes().
-		DoAndReturn(
-			func() (float64, error) {
-				return 0.3, nil
-			},
-		)
+		Return(0.95, nil)
 
+	reported := make(chan struct{}, 5)
 	mockProfiler := NewMockprofiler(ctrl)
 	mockProfiler.EXPECT().
-		profileHeap().
-		DoAndReturn(
-			func() ([]byte, error) {
-				profiled = true
-				return []byte("prof"), nil
-			},
-		)
+		profileCPU().
+		AnyTimes().
+		Return([]byte("prof"), nil)
 
 	mockReporter := report.NewMockReporter(ctrl)
 	mockReporter.EXPECT().
-		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		AnyTimes().
 		DoAndReturn(
-			func(_ context.Context, _ io.Reader, _ report.MemInfo) error {
-				reported = true
+			func(_ context.Context, _ io.Reader, _ report.CPUInfo) error {
+				reported <- struct{}{}
 				return nil
 			},
 		)
 
 	ap := &autoPprof{
-		disableCPUProf: true,
-		watchInterval:  1 * time.Second,
-		memThreshold:   0.2, // 20%.
-		queryer:        mockQueryer,
-		profiler:       mockProfiler,
-		reporter:       mockReporter,
-		stopC:          make(chan struct{}),
+		disableMemProf:              true,
+		watchInterval:               100 * time.Millisecond,
+		cpuThreshold:                0.5, // 50%.
+		minConsecutiveOverThreshold: 12,
+		severityCooldowns: []SeverityCooldown{
+			{Usage: 0.75, Cooldown: time.Second},
+			{Usage: 0.9, Cooldown: 200 * time.Millisecond},
+		},
+		queryer:  mockQueryer,
+		profiler: mockProfiler,
+		reporter: mockReporter,
+		stopC:    make(chan struct{}),
 	}
 
-	go ap.watchMemUsage()
+	go ap.watchCPUUsage()
 	t.Cleanup(func() { ap.stop() })
 
-	// Wait for profiling and reporting.
-	time.Sleep(1050 * time.Millisecond)
-	if !profiled {
-		t.Errorf("mem usage is not profiled")
-	}
-	if !reported {
-		t.Errorf("mem usage is not reported")
+	// Wait for 5 ticks. The 1st, 3rd and 5th ticks report at 95%,
+	//  although the default cooldown is 12 ticks.
+	time.Sleep(550 * time.Millisecond)
+	if n := len(reported); n != 3 {
+		t.Errorf("cpu profile is reported %d times, want 3", n)
 	}
 }
 
-func TestAutoPprof_watchMemUsage_consecutive(t *testing.T) {
+func TestAutoPprof_watchCPUUsage_burst(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
-	var (
-		profiledCnt int
-		reportedCnt int
-	)
-
 	mockQueryer := NewMockqueryer(ctrl)
 	mockQueryer.EXPECT().
-		memUsage().
+		cpuUsage().
 		AnyTimes().
-		DoAndReturn(
-			func() (float64, error) {
-				return 0.3, nil
-			},
-		)
+		Return(0.95, nil)
 
+	reported := make(chan struct{}, 5)
 	mockProfiler := NewMockprofiler(ctrl)
 	mockProfiler.EXPECT().
-		profileHeap().
+		profileCPU().
 		AnyTimes().
-		DoAndReturn(
-			func() ([]byte, error) {
-				profiledCnt++
-				return []byte("prof"), nil
-			},
-		)
+		Return([]byte("prof"), nil)
 
 	mockReporter := report.NewMockReporter(ctrl)
 	mockReporter.EXPECT().
-		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
 		AnyTimes().
 		DoAndReturn(
-			func(_ context.Context, _ io.Reader, _ report.MemInfo) error {
-				reportedCnt++
+			func(_ context.Context, _ io.Reader, _ report.CPUInfo) error {
+				reported <- struct{}{}
 				return nil
 			},
 		)
 
 	ap := &autoPprof{
-		disableCPUProf:              true,
-		watchInterval:               1 * time.Second,
-		memThreshold:                0.2, // 20%.
-		minConsecutiveOverThreshold: 3,
+		disableMemProf:              true,
+		watchInterval:               100 * time.Millisecond,
+		cpuThreshold:                0.5, // 50%.
+		minConsecutiveOverThreshold: 12,
+		burstCount:                  3,
 		queryer:                     mockQueryer,
 		profiler:                    mockProfiler,
 		reporter:                    mockReporter,
 		stopC:                       make(chan struct{}),
 	}
 
-	go ap.watchMemUsage()
+	go ap.watchCPUUsage()
 	t.Cleanup(func() { ap.stop() })
 
-	// Wait for profiling and reporting.
-	time.Sleep(1050 * time.Millisecond)
-	if profiledCnt != 1 {
-		t.Errorf("mem usage is profiled %d times, want 1", profiledCnt)
+	// Wait for 5 ticks. The first 3 ticks report as the burst, and
+	//  the cooldown of 12 ticks follows.
+	time.Sleep(550 * time.Millisecond)
+	if n := len(reported); n != 3 {
+		t.Errorf("cpu profile is reported %d times, want 3", n)
 	}
-	if reportedCnt != 1 {
-		t.Errorf("mem usage is reported %d times, want 1", reportedCnt)
+}
+
+func TestAutoPprof_watchCPUUsage_cpuPressure(t *testing.T) {
+	testCases := []struct {
+		name         string
+		pressure     float64
+		pressureErr  error
+		wantReported bool
+		wantTrigger  string
+	}{
+		{
+			name:         "pressure over the threshold",
+			pressure:     0.4,
+			wantReported: true,
+			wantTrigger:  report.TriggerCPUPressure,
+		},
+		{
+			name:         "pressure under the threshold",
+			pressure:     0.1,
+			wantReported: false,
+		},
+		{
+			name:         "psi unavailable",
+			pressureErr:  ErrPSIUnavailable,
+			wantReported: false,
+		},
 	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
 
-	time.Sleep(1050 * time.Millisecond)
-	// 2nd time. It shouldn't be profiled and reported.
-	if profiledCnt != 1 {
-		t.Errorf("mem usage is profiled %d times, want 1", profiledCnt)
+			mockQueryer := NewMockqueryer(ctrl)
+			mockQueryer.EXPECT().
+				cpuUsage().
+				AnyTimes().
+				Return(0.2, nil)
+			pressureCall := mockQueryer.EXPECT().
+				cpuPressure().
+				Return(tc.pressure, tc.pressureErr)
+			if tc.pressureErr == nil {
+				pressureCall.AnyTimes()
+			}
+
+			mockProfiler := NewMockprofiler(ctrl)
+			mockProfiler.EXPECT().
+				profileCPU().
+				AnyTimes().
+				Return([]byte("prof"), nil)
+
+			reported := make(chan report.CPUInfo, 3)
+			mockReporter := report.NewMockReporter(ctrl)
+			mockReporter.EXPECT().
+				ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+				AnyTimes().
+				DoAndReturn(
+					func(_ context.Context, _ io.Reader, ci report.CPUInfo) error {
+						reported <- ci
+						return nil
+					},
+				)
+
+			ap := &autoPprof{
+				disableMemProf:              true,
+				watchInterval:               100 * time.Millisecond,
+				cpuThreshold:                0.5, // 50%.
+				cpuPressureThreshold:        0.3, // 30%.
+				minConsecutiveOverThreshold: 12,
+				queryer:                     mockQueryer,
+				profiler:                    mockProfiler,
+				reporter:                    mockReporter,
+				stopC:                       make(chan struct{}),
+			}
+
+			go ap.watchCPUUsage()
+			t.Cleanup(func() { ap.stop() })
+
+			time.Sleep(250 * time.Millisecond)
+			var (
+				info        report.CPUInfo
+				gotReported bool
+			)
+			select {
+			case info = <-reported:
+				gotReported = true
+			default:
+			}
+			if gotReported != tc.wantReported {
+				t.Errorf("cpu profile reported = %v, want %v", gotReported, tc.wantReported)
+			}
+			if !tc.wantReported {
+				return
+			}
+			if info.Trigger != tc.wantTrigger {
+				t.Errorf("CPUInfo.Trigger = %q, want %q", info.Trigger, tc.wantTrigger)
+			}
+			if info.PressurePercentage != tc.pressure*100 {
+				t.Errorf("CPUInfo.PressurePercentage = %v, want %v", info.PressurePercentage, tc.pressure*100)
+			}
+			if info.PressureThresholdPercentage != 30 {
+				t.Errorf("CPUInfo.PressureThresholdPercentage = %v, want 30", info.PressureThresholdPercentage)
+			}
+		})
 	}
-	if reportedCnt != 1 {
-		t.Errorf("mem usage is reported %d times, want 1", reportedCnt)
+}
+
+func TestAutoPprof_watchCPUUsage_sampleInterval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var queried int32
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		setCPUSnapshotSize(120). // 24 * 100ms / 20ms.
+		Times(1)
+	mockQueryer.EXPECT().
+		cpuUsage().
+		AnyTimes().
+		DoAndReturn(
+			func() (float64, error) {
+				atomic.AddInt32(&queried, 1)
+				return 0.2, nil
+			},
+		)
+
+	ap := &autoPprof{
+		disableMemProf: true,
+		watchInterval:  100 * time.Millisecond,
+		sampleInterval: 20 * time.Millisecond,
+		cpuThreshold:   0.5, // 50%.
+		queryer:        mockQueryer,
+		stopC:          make(chan struct{}),
 	}
+	ap.resizeCPUSnapshots()
 
-	time.Sleep(1050 * time.Millisecond)
-	// 3rd time. It shouldn't be profiled and reported.
-	if profiledCnt != 1 {
-		t.Errorf("mem usage is profiled %d times, want 1", profiledCnt)
+	go ap.watchCPUUsage()
+	t.Cleanup(func() { ap.stop() })
+
+	// Wait for 2 watches, which are 10 samples including them.
+	time.Sleep(210 * time.Millisecond)
+	if n := atomic.LoadInt32(&queried); n < 8 {
+		t.Errorf("cpu usage is queried %d times, want the samples between the watches", n)
 	}
-	if reportedCnt != 1 {
-		t.Errorf("mem usage is reported %d times, want 1", reportedCnt)
+}
+
+func TestAutoPprof_reportCPUProfile_stream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		writeCPUProfile(gomock.Any()).
+		DoAndReturn(
+			func(w io.Writer) error {
+				_, err := w.Write([]byte("prof"))
+				return err
+			},
+		)
+
+	var streamed []byte
+	mockReporter := report.NewMockStreamReporter(ctrl)
+	mockReporter.EXPECT().
+		CanStream().
+		Return(true)
+	mockReporter.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, r io.Reader, _ report.CPUInfo) error {
+				b, err := io.ReadAll(r)
+				streamed = b
+				return err
+			},
+		)
+
+	ap := &autoPprof{
+		cpuThreshold:         0.5, // 50%.
+		cpuProfilingDuration: time.Second,
+		profiler:             mockProfiler,
+		reporter:             mockReporter,
+		stopC:                make(chan struct{}),
+	}
+	if err := ap.reportCPUProfile(0.6, 0); err != nil {
+		t.Errorf("reportCPUProfile() = %v, want nil", err)
+	}
+	if string(streamed) != "prof" {
+		t.Errorf("streamed profile = %q, want %q", streamed, "prof")
+	}
+}
+
+func TestAutoPprof_reportCPUProfile_inUse(t *testing.T) {
+	// Simulate the concurrent cpu profiling by the others.
+	//  (e.g. /debug/pprof/profile of the net/http/pprof)
+	if err := pprof.StartCPUProfile(io.Discard); err != nil {
+		t.Fatalf("StartCPUProfile() = %v, want nil", err)
 	}
+	defer pprof.StopCPUProfile()
 
-	time.Sleep(1050 * time.Millisecond)
-	// 4th time. Now it should be profiled and reported.
-	if profiledCnt != 2 {
-		t.Errorf("mem usage is profiled %d times, want 2", profiledCnt)
+	testCases := []struct {
+		name   string
+		stream bool
+	}{
+		{name: "buffered", stream: false},
+		{name: "streamed", stream: true},
 	}
-	if reportedCnt != 2 {
-		t.Errorf("mem usage is reported %d times, want 2", reportedCnt)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			mockReporter := report.NewMockStreamReporter(ctrl)
+			mockReporter.EXPECT().
+				CanStream().
+				Return(tc.stream)
+			if tc.stream {
+				mockReporter.EXPECT().
+					ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(
+						func(_ context.Context, r io.Reader, _ report.CPUInfo) error {
+							_, err := io.ReadAll(r)
+							return err
+						},
+					)
+			}
+
+			ap := &autoPprof{
+				cpuThreshold:         0.5, // 50%.
+				cpuProfilingDuration: time.Second,
+				profiler:             newDefaultProfiler(time.Second),
+				reporter:             mockReporter,
+				breaker:              newCircuitBreaker(1, time.Minute),
+				stopC:                make(chan struct{}),
+			}
+			if err := ap.reportCPUProfile(0.6, 0); err != nil {
+				t.Errorf("reportCPUProfile() = %v, want nil", err)
+			}
+			// The skipped profiling isn't the failure of the reporter.
+			if got := ap.status().Breaker.State; got != BreakerClosed {
+				t.Errorf("breaker state = %s, want %s", got, BreakerClosed)
+			}
+		})
 	}
 }
 
-func TestAutoPprof_watchMemUsage_reportBoth(t *testing.T) {
+func TestAutoPprof_watchCPUUsage_reportBoth(t *testing.T) {
 	type fields struct {
 		watchInterval  time.Duration
-		memThreshold   float64
+		cpuThreshold   float64
 		reportBoth     bool
-		disableCPUProf bool
+		disableMemProf bool
 		stopC          chan struct{}
 	}
 	testCases := []struct {
@@ -704,25 +1528,26 @@ func TestAutoPprof_watchMemUsage_reportBoth(t *testing.T) {
 			name: "reportBoth: true",
 			fields: fields{
 				watchInterval:  1 * time.Second,
-				memThreshold:   0.5, // 50%.
+				cpuThreshold:   0.5, // 50%.
 				reportBoth:     true,
-				disableCPUProf: false,
+				disableMemProf: false,
 				stopC:          make(chan struct{}),
 			},
 			mockFunc: func(mockQueryer *Mockqueryer, mockProfiler *Mockprofiler, mockReporter *report.MockReporter) {
 				gomock.InOrder(
 					mockQueryer.EXPECT().
-						memUsage().
+						cpuUsage().
 						AnyTimes().
 						Return(0.6, nil),
 
 					mockProfiler.EXPECT().
-						profileHeap().
+						profileCPU().
 						AnyTimes().
 						Return([]byte("cpu_prof"), nil),
 
 					mockReporter.EXPECT().
-						ReportHeapProfile(gomock.Any(), gomock.Any(), report.MemInfo{
+						ReportCPUProfile(gomock.Any(), gomock.Any(), report.CPUInfo{
+							SchemaVersion:       report.SchemaVersion,
 							ThresholdPercentage: 0.5 * 100,
 							UsagePercentage:     0.6 * 100,
 						}).
@@ -730,19 +1555,22 @@ func TestAutoPprof_watchMemUsage_reportBoth(t *testing.T) {
 						Return(nil),
 
 					mockQueryer.EXPECT().
-						cpuUsage().
+						memUsage().
 						AnyTimes().
-						Return(0.2, nil),
+						Return(&memStat{usage: 2, limit: 10}, nil),
 
 					mockProfiler.EXPECT().
-						profileCPU().
+						profileHeap().
 						AnyTimes().
 						Return([]byte("mem_prof"), nil),
 
 					mockReporter.EXPECT().
-						ReportCPUProfile(gomock.Any(), gomock.Any(), report.CPUInfo{
+						ReportHeapProfile(gomock.Any(), gomock.Any(), report.MemInfo{
+							SchemaVersion:       report.SchemaVersion,
 							ThresholdPercentage: 0.5 * 100,
 							UsagePercentage:     0.2 * 100,
+							MemLimitBytes:       10,
+							AvailableBytes:      8,
 						}).
 						AnyTimes().
 						Return(nil),
@@ -750,28 +1578,29 @@ func TestAutoPprof_watchMemUsage_reportBoth(t *testing.T) {
 			},
 		},
 		{
-			name: "reportBoth: true, disableCPUProf: true",
+			name: "reportBoth: true, disableMemProf: true",
 			fields: fields{
 				watchInterval:  1 * time.Second,
-				memThreshold:   0.5, // 50%.
+				cpuThreshold:   0.5, // 50%.
 				reportBoth:     true,
-				disableCPUProf: true,
+				disableMemProf: true,
 				stopC:          make(chan struct{}),
 			},
 			mockFunc: func(mockQueryer *Mockqueryer, mockProfiler *Mockprofiler, mockReporter *report.MockReporter) {
 				gomock.InOrder(
 					mockQueryer.EXPECT().
-						memUsage().
+						cpuUsage().
 						AnyTimes().
 						Return(0.6, nil),
 
 					mockProfiler.EXPECT().
-						profileHeap().
+						profileCPU().
 						AnyTimes().
 						Return([]byte("cpu_prof"), nil),
 
 					mockReporter.EXPECT().
-						ReportHeapProfile(gomock.Any(), gomock.Any(), report.MemInfo{
+						ReportCPUProfile(gomock.Any(), gomock.Any(), report.CPUInfo{
+							SchemaVersion:       report.SchemaVersion,
 							ThresholdPercentage: 0.5 * 100,
 							UsagePercentage:     0.6 * 100,
 						}).
@@ -784,25 +1613,26 @@ func TestAutoPprof_watchMemUsage_reportBoth(t *testing.T) {
 			name: "reportBoth: false",
 			fields: fields{
 				watchInterval:  1 * time.Second,
-				memThreshold:   0.5, // 50%.
+				cpuThreshold:   0.5, // 50%.
 				reportBoth:     false,
-				disableCPUProf: false,
+				disableMemProf: false,
 				stopC:          make(chan struct{}),
 			},
 			mockFunc: func(mockQueryer *Mockqueryer, mockProfiler *Mockprofiler, mockReporter *report.MockReporter) {
 				gomock.InOrder(
 					mockQueryer.EXPECT().
-						memUsage().
+						cpuUsage().
 						AnyTimes().
 						Return(0.6, nil),
 
 					mockProfiler.EXPECT().
-						profileHeap().
+						profileCPU().
 						AnyTimes().
 						Return([]byte("cpu_prof"), nil),
 
 					mockReporter.EXPECT().
-						ReportHeapProfile(gomock.Any(), gomock.Any(), report.MemInfo{
+						ReportCPUProfile(gomock.Any(), gomock.Any(), report.CPUInfo{
+							SchemaVersion:       report.SchemaVersion,
 							ThresholdPercentage: 0.5 * 100,
 							UsagePercentage:     0.6 * 100,
 						}).
@@ -822,19 +1652,19 @@ func TestAutoPprof_watchMemUsage_reportBoth(t *testing.T) {
 
 			ap := &autoPprof{
 				watchInterval:  tc.fields.watchInterval,
-				cpuThreshold:   0.5, // 50%.
-				memThreshold:   tc.fields.memThreshold,
+				cpuThreshold:   tc.fields.cpuThreshold,
+				memThreshold:   0.5, // 50%.
 				queryer:        mockQueryer,
 				profiler:       mockProfiler,
 				reporter:       mockReporter,
 				reportBoth:     tc.fields.reportBoth,
-				disableCPUProf: tc.fields.disableCPUProf,
+				disableMemProf: tc.fields.disableMemProf,
 				stopC:          tc.fields.stopC,
 			}
 
 			tc.mockFunc(mockQueryer, mockProfiler, mockReporter)
 
-			go ap.watchMemUsage()
+			go ap.watchCPUUsage()
 			defer ap.stop()
 
 			// Wait for profiling and reporting.
@@ -843,81 +1673,2281 @@ func TestAutoPprof_watchMemUsage_reportBoth(t *testing.T) {
 	}
 }
 
-func fib(n int) int64 {
-	if n <= 1 {
-		return int64(n)
+func TestAutoPprof_watchMemUsage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var (
+		profiled bool
+		reported bool
+	)
+
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		memUsage().
+		AnyTimes().
+		DoAndReturn(
+			func() (*memStat, error) {
+				return &memStat{usage: 3, limit: 10}, nil
+			},
+		)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileHeap().
+		DoAndReturn(
+			func() ([]byte, error) {
+				profiled = true
+				return []byte("prof"), nil
+			},
+		)
+
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, _ report.MemInfo) error {
+				reported = true
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		disableCPUProf: true,
+		watchInterval:  1 * time.Second,
+		memThreshold:   0.2, // 20%.
+		queryer:        mockQueryer,
+		profiler:       mockProfiler,
+		reporter:       mockReporter,
+		stopC:          make(chan struct{}),
 	}
-	return fib(n-1) + fib(n-2)
-}
 
-func BenchmarkLightJob(b *testing.B) {
-	for i := 0; i < b.N; i++ {
-		fib(10)
+	go ap.watchMemUsage()
+	t.Cleanup(func() { ap.stop() })
+
+	// Wait for profiling and reporting.
+	time.Sleep(1050 * time.Millisecond)
+	if !profiled {
+		t.Errorf("mem usage is not profiled")
+	}
+	if !reported {
+		t.Errorf("mem usage is not reported")
 	}
 }
 
-func BenchmarkLightJobWithWatchCPUUsage(b *testing.B) {
+func TestAutoPprof_watchMemUsage_consecutive(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
 	var (
-		qryer, _ = newQueryer()
-		ticker   = time.NewTicker(defaultWatchInterval)
+		profiledCnt int
+		reportedCnt int
 	)
-	for i := 0; i < b.N; i++ {
-		select {
-		case <-ticker.C:
-			_, _ = qryer.cpuUsage()
-		default:
-			fib(10)
-		}
+
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		memUsage().
+		AnyTimes().
+		DoAndReturn(
+			func() (*memStat, error) {
+				return &memStat{usage: 3, limit: 10}, nil
+			},
+		)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileHeap().
+		AnyTimes().
+		DoAndReturn(
+			func() ([]byte, error) {
+				profiledCnt++
+				return []byte("prof"), nil
+			},
+		)
+
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, _ report.MemInfo) error {
+				reportedCnt++
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		disableCPUProf:              true,
+		watchInterval:               1 * time.Second,
+		memThreshold:                0.2, // 20%.
+		minConsecutiveOverThreshold: 3,
+		queryer:                     mockQueryer,
+		profiler:                    mockProfiler,
+		reporter:                    mockReporter,
+		stopC:                       make(chan struct{}),
 	}
-}
 
-func BenchmarkLightJobWithWatchMemUsage(b *testing.B) {
-	var (
-		qryer, _ = newQueryer()
-		ticker   = time.NewTicker(defaultWatchInterval)
-	)
-	for i := 0; i < b.N; i++ {
-		select {
-		case <-ticker.C:
-			_, _ = qryer.memUsage()
-		default:
-			fib(10)
-		}
+	go ap.watchMemUsage()
+	t.Cleanup(func() { ap.stop() })
+
+	// Wait for profiling and reporting.
+	time.Sleep(1050 * time.Millisecond)
+	if profiledCnt != 1 {
+		t.Errorf("mem usage is profiled %d times, want 1", profiledCnt)
+	}
+	if reportedCnt != 1 {
+		t.Errorf("mem usage is reported %d times, want 1", reportedCnt)
 	}
-}
 
-func BenchmarkHeavyJob(b *testing.B) {
-	for i := 0; i < b.N; i++ {
-		fib(24)
+	time.Sleep(1050 * time.Millisecond)
+	// 2nd time. It shouldn't be profiled and reported.
+	if profiledCnt != 1 {
+		t.Errorf("mem usage is profiled %d times, want 1", profiledCnt)
+	}
+	if reportedCnt != 1 {
+		t.Errorf("mem usage is reported %d times, want 1", reportedCnt)
+	}
+
+	time.Sleep(1050 * time.Millisecond)
+	// 3rd time. It shouldn't be profiled and reported.
+	if profiledCnt != 1 {
+		t.Errorf("mem usage is profiled %d times, want 1", profiledCnt)
+	}
+	if reportedCnt != 1 {
+		t.Errorf("mem usage is reported %d times, want 1", reportedCnt)
+	}
+
+	time.Sleep(1050 * time.Millisecond)
+	// 4th time. Now it should be profiled and reported.
+	if profiledCnt != 2 {
+		t.Errorf("mem usage is profiled %d times, want 2", profiledCnt)
+	}
+	if reportedCnt != 2 {
+		t.Errorf("mem usage is reported %d times, want 2", reportedCnt)
 	}
 }
 
-func BenchmarkHeavyJobWithWatchCPUUsage(b *testing.B) {
-	var (
-		qryer, _ = newQueryer()
-		ticker   = time.NewTicker(defaultWatchInterval)
-	)
-	for i := 0; i < b.N; i++ {
-		select {
-		case <-ticker.C:
-			_, _ = qryer.cpuUsage()
-		default:
-			fib(24)
-		}
+func TestAutoPprof_watchMemUsage_minAvailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	reported := make(chan struct{}, 1)
+
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		memUsage().
+		AnyTimes().
+		Return(&memStat{usage: 3, limit: 10}, nil)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileHeap().
+		Return([]byte("prof"), nil)
+
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), report.MemInfo{
+			SchemaVersion:       report.SchemaVersion,
+			ThresholdPercentage: 0.9 * 100,
+			UsagePercentage:     0.3 * 100,
+			MemLimitBytes:       10,
+			AvailableBytes:      7,
+			MinAvailableBytes:   8,
+		}).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, _ report.MemInfo) error {
+				reported <- struct{}{}
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		disableCPUProf:       true,
+		watchInterval:        100 * time.Millisecond,
+		memThreshold:         0.9, // 90%.
+		memMinAvailableBytes: 8,
+		queryer:              mockQueryer,
+		profiler:             mockProfiler,
+		reporter:             mockReporter,
+		stopC:                make(chan struct{}),
+	}
+
+	go ap.watchMemUsage()
+	t.Cleanup(func() { ap.stop() })
+
+	select {
+	case <-reported:
+	case <-time.After(time.Second):
+		t.Errorf("mem usage is not reported")
 	}
 }
 
-func BenchmarkHeavyJobWithWatchMemUsage(b *testing.B) {
-	var (
-		qryer, _ = newQueryer()
-		ticker   = time.NewTicker(defaultWatchInterval)
-	)
-	for i := 0; i < b.N; i++ {
-		select {
-		case <-ticker.C:
-			_, _ = qryer.memUsage()
+func TestAutoPprof_watchMemUsage_unlimited(t *testing.T) {
+	testCases := []struct {
+		name     string
+		absolute uint64
+		usages   []uint64
+	}{
+		{
+			name:     "absolute",
+			absolute: 150,
+			usages:   []uint64{100, 200},
+		},
+		{
+			name:   "growth",
+			usages: []uint64{100, 200},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			var (
+				mu       sync.Mutex
+				watches  int
+				reported int
+			)
+			mockQueryer := NewMockqueryer(ctrl)
+			mockQueryer.EXPECT().
+				memUsage().
+				AnyTimes().
+				DoAndReturn(func() (*memStat, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					usage := tc.usages[len(tc.usages)-1]
+					if watches < len(tc.usages) {
+						usage = tc.usages[watches]
+					}
+					watches++
+					return &memStat{usage: usage, limit: memUnlimited}, nil
+				})
+
+			mockProfiler := NewMockprofiler(ctrl)
+			mockProfiler.EXPECT().
+				profileHeap().
+				AnyTimes().
+				Return([]byte("prof"), nil)
+
+			mockReporter := report.NewMockReporter(ctrl)
+			mockReporter.EXPECT().
+				ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+				AnyTimes().
+				DoAndReturn(
+					func(_ context.Context, _ io.Reader, _ report.MemInfo) error {
+						mu.Lock()
+						reported++
+						mu.Unlock()
+						return nil
+					},
+				)
+
+			ap := &autoPprof{
+				disableCPUProf:       true,
+				watchInterval:        100 * time.Millisecond,
+				memThreshold:         0.5, // 50%.
+				memAbsoluteThreshold: tc.absolute,
+				memGrowthThreshold:   0.5, // 50%.
+				queryer:              mockQueryer,
+				profiler:             mockProfiler,
+				reporter:             mockReporter,
+				stopC:                make(chan struct{}),
+			}
+
+			go ap.watchMemUsage()
+			t.Cleanup(func() { ap.stop() })
+
+			// Wait for the two watches.
+			time.Sleep(250 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			if reported != 1 {
+				t.Errorf("reported = %d, want 1", reported)
+			}
+		})
+	}
+}
+
+func TestAutoPprof_watchMemUsage_numa(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	reported := make(chan struct{}, 1)
+
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		memUsage().
+		AnyTimes().
+		Return(&memStat{usage: 3, limit: 10}, nil)
+	mockQueryer.EXPECT().
+		numaStat().
+		AnyTimes().
+		Return([]numaNode{
+			{node: 0, usage: 1, total: 10},
+			{node: 1, usage: 9, total: 10},
+		}, nil)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileHeap().
+		Return([]byte("prof"), nil)
+
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, mi report.MemInfo) error {
+				reported <- struct{}{}
+				if mi.Trigger != report.TriggerNUMA {
+					t.Errorf("MemInfo.Trigger = %q, want %q", mi.Trigger, report.TriggerNUMA)
+				}
+				want := []report.NUMANodeStat{
+					{Node: 0, UsageBytes: 1, TotalBytes: 10, UsagePercentage: 10},
+					{Node: 1, UsageBytes: 9, TotalBytes: 10, UsagePercentage: 90},
+				}
+				if !reflect.DeepEqual(mi.NUMANodes, want) {
+					t.Errorf("MemInfo.NUMANodes = %+v, want %+v", mi.NUMANodes, want)
+				}
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		disableCPUProf: true,
+		watchInterval:  100 * time.Millisecond,
+		memThreshold:   0.5, // 50%.
+		numaThreshold:  0.8, // 80%.
+		queryer:        mockQueryer,
+		profiler:       mockProfiler,
+		reporter:       mockReporter,
+		stopC:          make(chan struct{}),
+	}
+
+	go ap.watchMemUsage()
+	t.Cleanup(func() { ap.stop() })
+
+	select {
+	case <-reported:
+	case <-time.After(time.Second):
+		t.Errorf("NUMA node usage is not reported")
+	}
+}
+
+func TestAutoPprof_watchMemUsage_memRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	reported := make(chan struct{}, 1)
+
+	// 30% of the limit, but 120% of the request.
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		memUsage().
+		AnyTimes().
+		Return(&memStat{usage: 12, limit: 40}, nil)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileHeap().
+		Return([]byte("prof"), nil)
+
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, mi report.MemInfo) error {
+				reported <- struct{}{}
+				if mi.Trigger != report.TriggerMemRequest {
+					t.Errorf("MemInfo.Trigger = %q, want %q", mi.Trigger, report.TriggerMemRequest)
+				}
+				if mi.RequestBytes != 10 || mi.RequestUsagePercentage != 120 ||
+					mi.RequestThresholdPercentage != 100 {
+					t.Errorf("MemInfo = %+v, want 120%% of the 10 bytes request over 100%%", mi)
+				}
+				if mi.UsagePercentage != 30 {
+					t.Errorf("MemInfo.UsagePercentage = %v, want 30", mi.UsagePercentage)
+				}
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		disableCPUProf:      true,
+		watchInterval:       100 * time.Millisecond,
+		memThreshold:        0.5, // 50%.
+		memRequest:          10,
+		memRequestThreshold: 1, // 100%.
+		queryer:             mockQueryer,
+		profiler:            mockProfiler,
+		reporter:            mockReporter,
+		stopC:               make(chan struct{}),
+	}
+
+	go ap.watchMemUsage()
+	t.Cleanup(func() { ap.stop() })
+
+	select {
+	case <-reported:
+	case <-time.After(time.Second):
+		t.Errorf("memory usage of the request is not reported")
+	}
+}
+func TestAutoPprof_numaNodes_unavailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		numaStat().
+		Return(nil, ErrNUMAUnavailable)
+
+	ap := &autoPprof{
+		numaThreshold: 0.8,
+		queryer:       mockQueryer,
+	}
+	if nodes := ap.numaNodes(); nodes != nil {
+		t.Errorf("numaNodes() = %v, want nil", nodes)
+	}
+	if ap.numaUnavailable == 0 {
+		t.Errorf("numaUnavailable = 0, want 1 after the unavailable")
+	}
+	// It isn't read again.
+	ap.numaNodes()
+}
+
+func TestAutoPprof_reportHeapProfile_fullHeapCapture(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var buf bytes.Buffer
+	if err := pprof.Lookup("heap").WriteTo(&buf, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileHeap().
+		Return(buf.Bytes(), nil)
+
+	var (
+		mu                      sync.Mutex
+		running, maxRunning     int
+		sampleTypes, triggerIDs = map[string]bool{}, map[string]bool{}
+	)
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(3).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, mi report.MemInfo) error {
+				mu.Lock()
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				sampleTypes[mi.SampleType] = true
+				triggerIDs[mi.TriggerID] = true
+				mu.Unlock()
+
+				time.Sleep(10 * time.Millisecond)
+
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		memThreshold:    0.5, // 50%.
+		fullHeapCapture: true,
+		reportSem:       make(chan struct{}, 1),
+		profiler:        mockProfiler,
+		reporter:        mockReporter,
+		stopC:           make(chan struct{}),
+	}
+	if err := ap.reportHeapProfile(&memStat{usage: 6, limit: 10}); err != nil {
+		t.Fatalf("reportHeapProfile() = %v, want nil", err)
+	}
+	for _, typ := range fullHeapSampleTypes {
+		if !sampleTypes[typ] {
+			t.Errorf("%s view is not reported", typ)
+		}
+	}
+	if len(triggerIDs) != 1 || triggerIDs[""] {
+		t.Errorf("trigger ids = %v, want one shared id", triggerIDs)
+	}
+	if maxRunning != 1 {
+		t.Errorf("max concurrent reports = %d, want 1", maxRunning)
+	}
+}
+
+func TestAutoPprof_reportHeapProfile_primarySampleType(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileHeap().
+		Return([]byte("heap"), nil)
+
+	var primary string
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, mi report.MemInfo) error {
+				primary = mi.PrimarySampleType
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		memThreshold:          0.5, // 50%.
+		heapPrimarySampleType: report.SampleTypeAllocSpace,
+		profiler:              mockProfiler,
+		reporter:              mockReporter,
+		stopC:                 make(chan struct{}),
+	}
+	if err := ap.reportHeapProfile(&memStat{usage: 6, limit: 10}); err != nil {
+		t.Fatalf("reportHeapProfile() = %v, want nil", err)
+	}
+	if primary != report.SampleTypeAllocSpace {
+		t.Errorf("MemInfo.PrimarySampleType = %q, want %q", primary, report.SampleTypeAllocSpace)
+	}
+}
+
+func TestAutoPprof_reportHeapProfile_goroutineDump(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileHeap().
+		Return([]byte("prof"), nil)
+	mockProfiler.EXPECT().
+		dumpGoroutines().
+		Return([]byte("goroutine 1 [running]:"), nil)
+
+	var (
+		mu             sync.Mutex
+		heapTriggerID  string
+		dumpTriggerID  string
+		dumpReportedAs report.GoroutineInfo
+	)
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, mi report.MemInfo) error {
+				mu.Lock()
+				defer mu.Unlock()
+				heapTriggerID = mi.TriggerID
+				return nil
+			},
+		)
+	mockReporter.EXPECT().
+		ReportGoroutineProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, gi report.GoroutineInfo) error {
+				mu.Lock()
+				defer mu.Unlock()
+				dumpTriggerID = gi.TriggerID
+				dumpReportedAs = gi
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		memThreshold:      0.5, // 50%.
+		heapGoroutineDump: true,
+		profiler:          mockProfiler,
+		reporter:          mockReporter,
+		stopC:             make(chan struct{}),
+	}
+	if err := ap.reportHeapProfile(&memStat{usage: 6, limit: 10}); err != nil {
+		t.Fatalf("reportHeapProfile() = %v, want nil", err)
+	}
+	if heapTriggerID == "" || heapTriggerID != dumpTriggerID {
+		t.Errorf("trigger ids = %q and %q, want the same id", heapTriggerID, dumpTriggerID)
+	}
+	if !dumpReportedAs.Dump || dumpReportedAs.Trigger != report.TriggerHeap {
+		t.Errorf("goroutine info = %+v, want the dump triggered by the heap", dumpReportedAs)
+	}
+}
+
+func TestAutoPprof_reportCPUProfile_crossingDump(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	gomock.InOrder(
+		// The instant is dumped before the profiling.
+		mockProfiler.EXPECT().
+			dumpGoroutines().
+			Return([]byte("goroutine 1 [running]:"), nil),
+		mockProfiler.EXPECT().
+			profileCPU().
+			Return([]byte("prof"), nil),
+	)
+
+	var (
+		cpuTriggerID   string
+		dumpReportedAs report.GoroutineInfo
+	)
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, ci report.CPUInfo) error {
+				cpuTriggerID = ci.TriggerID
+				return nil
+			},
+		)
+	mockReporter.EXPECT().
+		ReportGoroutineProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, gi report.GoroutineInfo) error {
+				dumpReportedAs = gi
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		cpuThreshold:    0.5, // 50%.
+		cpuCrossingDump: true,
+		profiler:        mockProfiler,
+		reporter:        mockReporter,
+		stopC:           make(chan struct{}),
+	}
+	if err := ap.reportCPUProfile(0.6, 0); err != nil {
+		t.Fatalf("reportCPUProfile() = %v, want nil", err)
+	}
+	if cpuTriggerID == "" || cpuTriggerID != dumpReportedAs.TriggerID {
+		t.Errorf("trigger ids = %q and %q, want the same id", cpuTriggerID, dumpReportedAs.TriggerID)
+	}
+	if !dumpReportedAs.Dump || dumpReportedAs.Trigger != report.TriggerCPU {
+		t.Errorf("goroutine info = %+v, want the dump triggered by the cpu", dumpReportedAs)
+	}
+
+	// The bursts after the crossing don't dump.
+	mockProfiler.EXPECT().
+		profileCPU().
+		Return([]byte("prof"), nil)
+	mockReporter.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+	if err := ap.burstCPUProfile(0.6, 0); err != nil {
+		t.Fatalf("burstCPUProfile() = %v, want nil", err)
+	}
+}
+
+func TestAutoPprof_reportHeapProfile_allocsDiff(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileHeap().
+		Return([]byte("prof"), nil)
+	mockProfiler.EXPECT().
+		profileNamed("allocs").
+		Return([]byte("allocs"), nil)
+
+	var (
+		mu             sync.Mutex
+		heapReportedAs report.MemInfo
+		diffReportedAs report.ProfileInfo
+	)
+	mockReporter := report.NewMockProfileReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, mi report.MemInfo) error {
+				mu.Lock()
+				defer mu.Unlock()
+				heapReportedAs = mi
+				return nil
+			},
+		)
+	mockReporter.EXPECT().
+		ReportProfile(gomock.Any(), gomock.Any(), report.ProfileKindAllocs, gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, _ report.ProfileKind, pi report.ProfileInfo) error {
+				mu.Lock()
+				defer mu.Unlock()
+				diffReportedAs = pi
+				return nil
+			},
+		)
+
+	allocs := newAllocTracker()
+	allocs.since(1000)
+	ap := &autoPprof{
+		memThreshold:     0.5, // 50%.
+		allocs:           allocs,
+		reportAllocsDiff: true,
+		readMemStats: func(ms *runtime.MemStats) {
+			ms.TotalAlloc = 1500
+		},
+		profiler: mockProfiler,
+		reporter: mockReporter,
+		stopC:    make(chan struct{}),
+	}
+	if err := ap.reportHeapProfile(&memStat{usage: 6, limit: 10}); err != nil {
+		t.Fatalf("reportHeapProfile() = %v, want nil", err)
+	}
+	if got := heapReportedAs.AllocBytesSinceLastReport; got != 500 {
+		t.Errorf("MemInfo.AllocBytesSinceLastReport = %d, want 500", got)
+	}
+	if heapReportedAs.TriggerID == "" || heapReportedAs.TriggerID != diffReportedAs.TriggerID {
+		t.Errorf("trigger ids = %q and %q, want the same id", heapReportedAs.TriggerID, diffReportedAs.TriggerID)
+	}
+	if !diffReportedAs.Delta {
+		t.Errorf("ProfileInfo.Delta = false, want true")
+	}
+}
+
+func TestAutoPprof_reportHeapProfile_heapThrottle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	// Neither profiled nor reported.
+	mockProfiler := NewMockprofiler(ctrl)
+	mockReporter := report.NewMockReporter(ctrl)
+
+	ap := &autoPprof{
+		memThreshold: 0.5, // 50%.
+		heapThrottle: newHeapThrottle(1<<30, 0),
+		readMemStats: func(ms *runtime.MemStats) {
+			ms.HeapAlloc = 2 << 30
+		},
+		profiler: mockProfiler,
+		reporter: mockReporter,
+		stopC:    make(chan struct{}),
+	}
+	err := ap.reportHeapProfile(&memStat{usage: 6, limit: 10})
+	if !errors.Is(err, ErrHeapTooLarge) {
+		t.Errorf("reportHeapProfile() = %v, want %v", err, ErrHeapTooLarge)
+	}
+	if got := ap.status().HeapThrottle.Skipped; got != 1 {
+		t.Errorf("HeapThrottle.Skipped = %d, want 1", got)
+	}
+}
+
+func TestAutoPprof_captureOnCrash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileHeap().
+		Return([]byte("prof"), nil)
+	mockProfiler.EXPECT().
+		dumpGoroutines().
+		Return([]byte("goroutine 1 [running]:"), nil)
+
+	var (
+		mu             sync.Mutex
+		heapReportedAs report.MemInfo
+		dumpReportedAs report.GoroutineInfo
+	)
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, mi report.MemInfo) error {
+				mu.Lock()
+				defer mu.Unlock()
+				heapReportedAs = mi
+				return nil
+			},
+		)
+	mockReporter.EXPECT().
+		ReportGoroutineProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, gi report.GoroutineInfo) error {
+				mu.Lock()
+				defer mu.Unlock()
+				dumpReportedAs = gi
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		profiler: mockProfiler,
+		reporter: mockReporter,
+		// The breaker is ignored for the crash.
+		breaker: newCircuitBreaker(1, time.Minute),
+		stopC:   make(chan struct{}),
+	}
+	ap.breaker.record(errors.New("sink is down"))
+	if err := ap.captureOnCrash("panic: boom"); err != nil {
+		t.Fatalf("captureOnCrash() = %v, want nil", err)
+	}
+	if heapReportedAs.Trigger != report.TriggerCrash || heapReportedAs.Reason != "panic: boom" {
+		t.Errorf("mem info = %+v, want the crash with the reason", heapReportedAs)
+	}
+	if !dumpReportedAs.Dump || dumpReportedAs.Trigger != report.TriggerCrash ||
+		dumpReportedAs.Reason != "panic: boom" {
+		t.Errorf("goroutine info = %+v, want the dump of the crash with the reason", dumpReportedAs)
+	}
+	if heapReportedAs.TriggerID == "" || heapReportedAs.TriggerID != dumpReportedAs.TriggerID {
+		t.Errorf("trigger ids = %q and %q, want the same id", heapReportedAs.TriggerID, dumpReportedAs.TriggerID)
+	}
+}
+
+func TestAutoPprof_captureNamed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileNamed("threadcreate").
+		Return([]byte("prof"), nil)
+
+	mockReporter := report.NewMockProfileReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportProfile(gomock.Any(), gomock.Any(), report.ProfileKindThreadCreate, report.ProfileInfo{
+			SchemaVersion: report.SchemaVersion,
+			Name:          "threadcreate",
+		}).
+		Return(nil)
+
+	ap := &autoPprof{
+		profiler: mockProfiler,
+		reporter: mockReporter,
+		stopC:    make(chan struct{}),
+	}
+	if err := ap.captureNamed("threadcreate"); err != nil {
+		t.Errorf("captureNamed() = %v, want nil", err)
+	}
+}
+
+func TestAutoPprof_reportContentionProfiles(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileBlock().
+		Return([]byte("block"), nil)
+	mockProfiler.EXPECT().
+		profileMutex().
+		Return([]byte("mutex"), nil)
+
+	mockReporter := report.NewMockProfileReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportProfile(gomock.Any(), gomock.Any(), report.ProfileKindBlock, report.ProfileInfo{
+			SchemaVersion: report.SchemaVersion,
+			Name:          "block",
+		}).
+		Return(nil)
+	mockReporter.EXPECT().
+		ReportProfile(gomock.Any(), gomock.Any(), report.ProfileKindMutex, report.ProfileInfo{
+			SchemaVersion: report.SchemaVersion,
+			Name:          "mutex",
+		}).
+		Return(nil)
+
+	ap := &autoPprof{
+		blockProfileRate:     1,
+		mutexProfileFraction: 1,
+		profiler:             mockProfiler,
+		reporter:             mockReporter,
+		stopC:                make(chan struct{}),
+	}
+	ap.reportContentionProfiles()
+
+	// The disabled profiles aren't profiled.
+	ap.blockProfileRate, ap.mutexProfileFraction = 0, 0
+	ap.reportContentionProfiles()
+}
+
+func TestAutoPprof_reportContentionProfiles_unsupported(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	// Neither profiled nor reported to the reporter which can't take them.
+	ap := &autoPprof{
+		blockProfileRate:     1,
+		mutexProfileFraction: 1,
+		profiler:             NewMockprofiler(ctrl),
+		reporter:             report.NewMockReporter(ctrl),
+		stopC:                make(chan struct{}),
+	}
+	ap.reportContentionProfiles()
+}
+
+func TestAutoPprof_captureSequence(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileNamed("threadcreate").
+		Return([]byte("prof"), nil)
+
+	var (
+		pi report.ProfileInfo
+		gi report.GoroutineInfo
+	)
+	mockReporter := report.NewMockProfileReporter(ctrl)
+	gomock.InOrder(
+		mockReporter.EXPECT().
+			ReportProfile(gomock.Any(), gomock.Any(), report.ProfileKindThreadCreate, gomock.Any()).
+			DoAndReturn(
+				func(_ context.Context, _ io.Reader, _ report.ProfileKind, i report.ProfileInfo) error {
+					pi = i
+					return nil
+				},
+			),
+		mockReporter.EXPECT().
+			ReportGoroutineProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(
+				func(_ context.Context, _ io.Reader, i report.GoroutineInfo) error {
+					gi = i
+					return nil
+				},
+			),
+	)
+
+	ap := &autoPprof{
+		profiler:        mockProfiler,
+		reporter:        mockReporter,
+		captureSequence: true,
+		startedAt:       time.Now(),
+		stopC:           make(chan struct{}),
+	}
+	if err := ap.captureNamed("threadcreate"); err != nil {
+		t.Fatalf("captureNamed() = %v, want nil", err)
+	}
+	if err := ap.reportGoroutineDump([]byte("dump"), report.MemInfo{}); err != nil {
+		t.Fatalf("reportGoroutineDump() = %v, want nil", err)
+	}
+
+	// The sequence is shared across the kinds of the profiles.
+	if pi.Sequence != 1 || gi.Sequence != 2 {
+		t.Errorf("sequences = %d, %d, want 1, 2", pi.Sequence, gi.Sequence)
+	}
+	if pi.Elapsed <= 0 || gi.Elapsed < pi.Elapsed {
+		t.Errorf("elapsed times = %v, %v, want the increasing positive ones", pi.Elapsed, gi.Elapsed)
+	}
+}
+
+func TestAutoPprof_sendLiveness(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var (
+		mu    sync.Mutex
+		sizes []int
+	)
+	mockReporter := report.NewMockProfileReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportProfile(gomock.Any(), gomock.Any(), report.ProfileKindLiveness, report.ProfileInfo{
+			SchemaVersion: report.SchemaVersion,
+			Name:          "liveness",
+		}).
+		AnyTimes().
+		DoAndReturn(
+			func(_ context.Context, r io.Reader, _ report.ProfileKind, _ report.ProfileInfo) error {
+				b, err := io.ReadAll(r)
+				mu.Lock()
+				defer mu.Unlock()
+				sizes = append(sizes, len(b))
+				return err
+			},
+		)
+
+	ap := &autoPprof{
+		livenessInterval: 100 * time.Millisecond,
+		reporter:         mockReporter,
+		stopC:            make(chan struct{}),
+	}
+	go ap.sendLiveness()
+	time.Sleep(350 * time.Millisecond)
+	ap.stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sizes) < 2 {
+		t.Errorf("liveness is reported %d times, want >= 2", len(sizes))
+	}
+	for _, size := range sizes {
+		if size != 0 {
+			t.Errorf("liveness marker has %d bytes, want no profiling data", size)
+		}
+	}
+}
+
+func TestAutoPprof_verifyReporter(t *testing.T) {
+	testCases := []struct {
+		name        string
+		newReporter func(*gomock.Controller) report.Reporter
+		want        error
+	}{
+		{
+			name: "reachable",
+			newReporter: func(ctrl *gomock.Controller) report.Reporter {
+				r := report.NewMockPingReporter(ctrl)
+				r.EXPECT().Ping(gomock.Any()).Return(nil)
+				return r
+			},
+			want: nil,
+		},
+		{
+			name: "unreachable",
+			newReporter: func(ctrl *gomock.Controller) report.Reporter {
+				r := report.NewMockPingReporter(ctrl)
+				r.EXPECT().Ping(gomock.Any()).Return(errors.New("bad credentials"))
+				return r
+			},
+			want: ErrReporterUnreachable,
+		},
+		{
+			name: "not a ping reporter",
+			newReporter: func(ctrl *gomock.Controller) report.Reporter {
+				return report.NewMockReporter(ctrl)
+			},
+			want: nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			ap := &autoPprof{
+				reporter: tc.newReporter(ctrl),
+				stopC:    make(chan struct{}),
+			}
+			if err := ap.verifyReporter(); !errors.Is(err, tc.want) {
+				t.Errorf("verifyReporter() = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestCaptureOnCrash_notStarted(t *testing.T) {
+	if err := CaptureOnCrash("panic"); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("CaptureOnCrash() = %v, want %v", err, ErrNotStarted)
+	}
+}
+
+func TestCaptureOnCrash_stopped(t *testing.T) {
+	t.Cleanup(func() {
+		globalAp = nil
+	})
+	// No profiling and reporting are expected after the Stop.
+	ctrl := gomock.NewController(t)
+	globalAp = &autoPprof{
+		profiler: NewMockprofiler(ctrl),
+		reporter: report.NewMockReporter(ctrl),
+		stopC:    make(chan struct{}),
+	}
+	Stop()
+
+	if err := CaptureOnCrash("panic"); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("CaptureOnCrash() = %v, want %v", err, ErrNotStarted)
+	}
+	if err := CaptureNamed("threadcreate"); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("CaptureNamed() = %v, want %v", err, ErrNotStarted)
+	}
+	if err := EnableCPUProfiling(true); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("EnableCPUProfiling() = %v, want %v", err, ErrNotStarted)
+	}
+}
+
+func TestAutoPprof_watchMemUsage_reportBoth(t *testing.T) {
+	type fields struct {
+		watchInterval  time.Duration
+		memThreshold   float64
+		reportBoth     bool
+		disableCPUProf bool
+		stopC          chan struct{}
+	}
+	testCases := []struct {
+		name     string
+		fields   fields
+		mockFunc func(*Mockqueryer, *Mockprofiler, *report.MockReporter)
+	}{
+		{
+			name: "reportBoth: true",
+			fields: fields{
+				watchInterval:  1 * time.Second,
+				memThreshold:   0.5, // 50%.
+				reportBoth:     true,
+				disableCPUProf: false,
+				stopC:          make(chan struct{}),
+			},
+			mockFunc: func(mockQueryer *Mockqueryer, mockProfiler *Mockprofiler, mockReporter *report.MockReporter) {
+				gomock.InOrder(
+					mockQueryer.EXPECT().
+						memUsage().
+						AnyTimes().
+						Return(&memStat{usage: 6, limit: 10}, nil),
+
+					mockProfiler.EXPECT().
+						profileHeap().
+						AnyTimes().
+						Return([]byte("cpu_prof"), nil),
+
+					mockReporter.EXPECT().
+						ReportHeapProfile(gomock.Any(), gomock.Any(), report.MemInfo{
+							SchemaVersion:       report.SchemaVersion,
+							ThresholdPercentage: 0.5 * 100,
+							UsagePercentage:     0.6 * 100,
+							MemLimitBytes:       10,
+							AvailableBytes:      4,
+						}).
+						AnyTimes().
+						Return(nil),
+
+					mockQueryer.EXPECT().
+						cpuUsage().
+						AnyTimes().
+						Return(0.2, nil),
+
+					mockProfiler.EXPECT().
+						profileCPU().
+						AnyTimes().
+						Return([]byte("mem_prof"), nil),
+
+					mockReporter.EXPECT().
+						ReportCPUProfile(gomock.Any(), gomock.Any(), report.CPUInfo{
+							SchemaVersion:       report.SchemaVersion,
+							ThresholdPercentage: 0.5 * 100,
+							UsagePercentage:     0.2 * 100,
+						}).
+						AnyTimes().
+						Return(nil),
+				)
+			},
+		},
+		{
+			name: "reportBoth: true, disableCPUProf: true",
+			fields: fields{
+				watchInterval:  1 * time.Second,
+				memThreshold:   0.5, // 50%.
+				reportBoth:     true,
+				disableCPUProf: true,
+				stopC:          make(chan struct{}),
+			},
+			mockFunc: func(mockQueryer *Mockqueryer, mockProfiler *Mockprofiler, mockReporter *report.MockReporter) {
+				gomock.InOrder(
+					mockQueryer.EXPECT().
+						memUsage().
+						AnyTimes().
+						Return(&memStat{usage: 6, limit: 10}, nil),
+
+					mockProfiler.EXPECT().
+						profileHeap().
+						AnyTimes().
+						Return([]byte("cpu_prof"), nil),
+
+					mockReporter.EXPECT().
+						ReportHeapProfile(gomock.Any(), gomock.Any(), report.MemInfo{
+							SchemaVersion:       report.SchemaVersion,
+							ThresholdPercentage: 0.5 * 100,
+							UsagePercentage:     0.6 * 100,
+							MemLimitBytes:       10,
+							AvailableBytes:      4,
+						}).
+						AnyTimes().
+						Return(nil),
+				)
+			},
+		},
+		{
+			name: "reportBoth: false",
+			fields: fields{
+				watchInterval:  1 * time.Second,
+				memThreshold:   0.5, // 50%.
+				reportBoth:     false,
+				disableCPUProf: false,
+				stopC:          make(chan struct{}),
+			},
+			mockFunc: func(mockQueryer *Mockqueryer, mockProfiler *Mockprofiler, mockReporter *report.MockReporter) {
+				gomock.InOrder(
+					mockQueryer.EXPECT().
+						memUsage().
+						AnyTimes().
+						Return(&memStat{usage: 6, limit: 10}, nil),
+
+					mockProfiler.EXPECT().
+						profileHeap().
+						AnyTimes().
+						Return([]byte("cpu_prof"), nil),
+
+					mockReporter.EXPECT().
+						ReportHeapProfile(gomock.Any(), gomock.Any(), report.MemInfo{
+							SchemaVersion:       report.SchemaVersion,
+							ThresholdPercentage: 0.5 * 100,
+							UsagePercentage:     0.6 * 100,
+							MemLimitBytes:       10,
+							AvailableBytes:      4,
+						}).
+						AnyTimes().
+						Return(nil),
+				)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			mockQueryer := NewMockqueryer(ctrl)
+			mockProfiler := NewMockprofiler(ctrl)
+			mockReporter := report.NewMockReporter(ctrl)
+
+			ap := &autoPprof{
+				watchInterval:  tc.fields.watchInterval,
+				cpuThreshold:   0.5, // 50%.
+				memThreshold:   tc.fields.memThreshold,
+				queryer:        mockQueryer,
+				profiler:       mockProfiler,
+				reporter:       mockReporter,
+				reportBoth:     tc.fields.reportBoth,
+				disableCPUProf: tc.fields.disableCPUProf,
+				stopC:          tc.fields.stopC,
+			}
+
+			tc.mockFunc(mockQueryer, mockProfiler, mockReporter)
+
+			go ap.watchMemUsage()
+			defer ap.stop()
+
+			// Wait for profiling and reporting.
+			time.Sleep(1050 * time.Millisecond)
+		})
+	}
+}
+
+func TestAutoPprof_watchGCPressure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var buf bytes.Buffer
+	if err := pprof.Lookup("heap").WriteTo(&buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileHeap().
+		Return(buf.Bytes(), nil)
+
+	var (
+		mu          sync.Mutex
+		sampleTypes []string
+	)
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(len(gcHeapSampleTypes)).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, mi report.MemInfo) error {
+				mu.Lock()
+				defer mu.Unlock()
+				if mi.Trigger != report.TriggerGC {
+					t.Errorf("trigger = %s, want %s", mi.Trigger, report.TriggerGC)
+				}
+				sampleTypes = append(sampleTypes, mi.SampleType)
+				return nil
+			},
+		)
+
+	// 10 gc cycles per tick, which is 100 gc cycles per second.
+	var numGC uint32
+	ap := &autoPprof{
+		watchInterval:               100 * time.Millisecond,
+		gcRateThreshold:             50,
+		minConsecutiveOverThreshold: 12,
+		readMemStats: func(ms *runtime.MemStats) {
+			ms.NumGC = numGC
+			numGC += 10
+		},
+		profiler: mockProfiler,
+		reporter: mockReporter,
+		stopC:    make(chan struct{}),
+	}
+
+	go ap.watchGCPressure()
+	t.Cleanup(func() { ap.stop() })
+
+	// Wait for 2 ticks. Only the 1st tick reports.
+	time.Sleep(250 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sampleTypes) != len(gcHeapSampleTypes) {
+		t.Errorf("reported views = %v, want %v", sampleTypes, gcHeapSampleTypes)
+	}
+}
+
+func TestAutoPprof_timeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	timeoutReporter := func(d time.Duration) report.Reporter {
+		r := report.NewMockTimeoutReporter(ctrl)
+		r.EXPECT().Timeout().AnyTimes().Return(d)
+		return r
+	}
+	testCases := []struct {
+		name          string
+		reporter      report.Reporter
+		reportTimeout time.Duration
+		want          time.Duration
+	}{
+		{
+			name:     "default",
+			reporter: report.NewMockReporter(ctrl),
+			want:     defaultReportTimeout,
+		},
+		{
+			name:          "global timeout",
+			reporter:      report.NewMockReporter(ctrl),
+			reportTimeout: time.Second,
+			want:          time.Second,
+		},
+		{
+			name:          "reporter timeout",
+			reporter:      timeoutReporter(time.Minute),
+			reportTimeout: time.Second,
+			want:          time.Minute,
+		},
+		{
+			name:          "zero reporter timeout falls back",
+			reporter:      timeoutReporter(0),
+			reportTimeout: time.Second,
+			want:          time.Second,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ap := &autoPprof{
+				reporter:      tc.reporter,
+				reportTimeout: tc.reportTimeout,
+			}
+			if got := ap.timeout(); got != tc.want {
+				t.Errorf("timeout() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAutoPprof_reportCPUProfile_breaker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var profiledCnt int
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileCPU().
+		AnyTimes().
+		DoAndReturn(
+			func() ([]byte, error) {
+				profiledCnt++
+				return []byte("prof"), nil
+			},
+		)
+
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(errors.New("sink is down"))
+
+	ap := &autoPprof{
+		cpuThreshold: 0.5, // 50%.
+		profiler:     mockProfiler,
+		reporter:     mockReporter,
+		breaker:      newCircuitBreaker(2, time.Minute),
+		stopC:        make(chan struct{}),
+	}
+
+	for i := 0; i < 3; i++ {
+		_ = ap.reportCPUProfile(0.6, 0)
+	}
+	// The 3rd report must be skipped without the profiling.
+	if profiledCnt != 2 {
+		t.Errorf("cpu usage is profiled %d times, want 2", profiledCnt)
+	}
+	if got := ap.status().Breaker.State; got != BreakerOpen {
+		t.Errorf("breaker state = %s, want %s", got, BreakerOpen)
+	}
+}
+
+func TestAutoPprof_watchFDUsage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	reported := make(chan struct{}, 1)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileGoroutine().
+		Return([]byte("prof"), nil)
+
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportGoroutineProfile(gomock.Any(), gomock.Any(), report.GoroutineInfo{
+			SchemaVersion:       report.SchemaVersion,
+			Trigger:             report.TriggerFD,
+			ThresholdPercentage: 0.5 * 100,
+			UsagePercentage:     0.6 * 100,
+		}).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, _ report.GoroutineInfo) error {
+				reported <- struct{}{}
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		disableCPUProf:              true,
+		disableMemProf:              true,
+		watchInterval:               100 * time.Millisecond,
+		fdThreshold:                 0.5, // 50%.
+		fdUsage:                     func() (float64, error) { return 0.6, nil },
+		minConsecutiveOverThreshold: 12,
+		profiler:                    mockProfiler,
+		reporter:                    mockReporter,
+		stopC:                       make(chan struct{}),
+	}
+
+	go ap.watchFDUsage()
+	t.Cleanup(func() { ap.stop() })
+
+	select {
+	case <-reported:
+	case <-time.After(time.Second):
+		t.Errorf("fd usage is not reported")
+	}
+}
+
+func TestAutoPprof_watchPIDUsage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	reported := make(chan struct{}, 1)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileGoroutine().
+		Return([]byte("prof"), nil)
+
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportGoroutineProfile(gomock.Any(), gomock.Any(), report.GoroutineInfo{
+			SchemaVersion:       report.SchemaVersion,
+			Trigger:             report.TriggerPID,
+			ThresholdPercentage: 0.8 * 100,
+			UsagePercentage:     0.9 * 100,
+			PIDs:                900,
+			PIDLimit:            1000,
+		}).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, _ report.GoroutineInfo) error {
+				reported <- struct{}{}
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		disableCPUProf:              true,
+		disableMemProf:              true,
+		watchInterval:               100 * time.Millisecond,
+		pidThreshold:                0.8, // 80%.
+		pidUsage:                    func() (pidStat, error) { return pidStat{current: 900, max: 1000}, nil },
+		minConsecutiveOverThreshold: 12,
+		profiler:                    mockProfiler,
+		reporter:                    mockReporter,
+		stopC:                       make(chan struct{}),
+	}
+
+	go ap.watchPIDUsage()
+	t.Cleanup(func() { ap.stop() })
+
+	select {
+	case <-reported:
+	case <-time.After(time.Second):
+		t.Errorf("pid usage is not reported")
+	}
+}
+
+func TestAutoPprof_watchGoroutineCount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	reported := make(chan struct{}, 1)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileGoroutine().
+		Return([]byte("prof"), nil)
+
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportGoroutineProfile(gomock.Any(), gomock.Any(), report.GoroutineInfo{
+			SchemaVersion:      report.SchemaVersion,
+			Trigger:            report.TriggerGoroutine,
+			Goroutines:         1500,
+			GoroutineThreshold: 1000,
+		}).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, _ report.GoroutineInfo) error {
+				reported <- struct{}{}
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		disableCPUProf:              true,
+		disableMemProf:              true,
+		watchInterval:               10 * time.Millisecond,
+		goroutineThreshold:          1000,
+		numGoroutine:                func() int { return 1500 },
+		minConsecutiveOverThreshold: 3,
+		profiler:                    mockProfiler,
+		reporter:                    mockReporter,
+		stopC:                       make(chan struct{}),
+	}
+
+	go ap.watchGoroutineCount()
+	t.Cleanup(func() { ap.stop() })
+
+	select {
+	case <-reported:
+	case <-time.After(time.Second):
+		t.Errorf("goroutine count is not reported")
+	}
+}
+
+func TestAutoPprof_watchGoroutineCount_disabled(t *testing.T) {
+	ap := &autoPprof{
+		watchInterval:        10 * time.Millisecond,
+		goroutineThreshold:   1000,
+		disableGoroutineProf: true,
+		numGoroutine:         func() int { return 1500 },
+		watchers:             newWatcherHealth(),
+		stopC:                make(chan struct{}),
+	}
+	t.Cleanup(func() { close(ap.stopC) })
+
+	done := make(chan struct{})
+	go func() {
+		ap.watchGoroutineCount()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("watchGoroutineCount() doesn't return with the DisableGoroutineProf")
+	}
+}
+
+func TestAutoPprof_watchPIDUsage_unlimited(t *testing.T) {
+	ap := &autoPprof{
+		watchInterval: 10 * time.Millisecond,
+		pidThreshold:  0.8,
+		pidUsage:      func() (pidStat, error) { return pidStat{}, ErrPIDLimitUnavailable },
+		watchers:      newWatcherHealth(),
+		stopC:         make(chan struct{}),
+	}
+	t.Cleanup(func() { close(ap.stopC) })
+
+	done := make(chan struct{})
+	go func() {
+		ap.watchPIDUsage()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("watchPIDUsage() doesn't return without the pids limit")
+	}
+	if _, ok := ap.watchers.snapshot()[watcherPID]; ok {
+		t.Errorf("the disabled pid watcher is tracked by the health")
+	}
+}
+
+func TestAutoPprof_goroutineDropped(t *testing.T) {
+	testCases := []struct {
+		name        string
+		prev, cur   int
+		wantRatio   float64
+		wantDropped bool
+	}{
+		{
+			name:        "dropped",
+			prev:        1000,
+			cur:         400,
+			wantRatio:   0.6,
+			wantDropped: true,
+		},
+		{
+			name:        "dropped under the threshold",
+			prev:        1000,
+			cur:         600,
+			wantRatio:   0.4,
+			wantDropped: false,
+		},
+		{
+			name:        "dropped under the min count",
+			prev:        100,
+			cur:         10,
+			wantRatio:   0.9,
+			wantDropped: false,
+		},
+		{
+			name:        "grown",
+			prev:        1000,
+			cur:         2000,
+			wantRatio:   0,
+			wantDropped: false,
+		},
+	}
+	ap := &autoPprof{
+		goroutineDropThreshold: 0.5, // 50%.
+		goroutineDropMinCount:  100,
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ratio, dropped := ap.goroutineDropped(tc.prev, tc.cur)
+			if ratio != tc.wantRatio || dropped != tc.wantDropped {
+				t.Errorf(
+					"goroutineDropped() = (%v, %v), want (%v, %v)",
+					ratio, dropped, tc.wantRatio, tc.wantDropped,
+				)
+			}
+		})
+	}
+}
+
+func TestAutoPprof_watchGoroutineDrop(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	reported := make(chan struct{}, 1)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileGoroutine().
+		Return([]byte("prof"), nil)
+
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportGoroutineProfile(gomock.Any(), gomock.Any(), report.GoroutineInfo{
+			SchemaVersion:       report.SchemaVersion,
+			Trigger:             report.TriggerGoroutineDrop,
+			ThresholdPercentage: 0.5 * 100,
+			UsagePercentage:     0.8 * 100,
+			GoroutinesBefore:    1000,
+			GoroutinesAfter:     200,
+		}).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, _ report.GoroutineInfo) error {
+				reported <- struct{}{}
+				return nil
+			},
+		)
+
+	// The goroutines drop once, and then stay.
+	var (
+		mu     sync.Mutex
+		counts = []int{1000, 1000, 200}
+	)
+	numGoroutine := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+
+		n := counts[0]
+		if len(counts) > 1 {
+			counts = counts[1:]
+		}
+		return n
+	}
+
+	ap := &autoPprof{
+		disableCPUProf:              true,
+		disableMemProf:              true,
+		watchInterval:               100 * time.Millisecond,
+		goroutineDropThreshold:      0.5, // 50%.
+		goroutineDropMinCount:       100,
+		numGoroutine:                numGoroutine,
+		minConsecutiveOverThreshold: 12,
+		profiler:                    mockProfiler,
+		reporter:                    mockReporter,
+		stopC:                       make(chan struct{}),
+	}
+
+	go ap.watchGoroutineDrop()
+	t.Cleanup(func() { ap.stop() })
+
+	select {
+	case <-reported:
+	case <-time.After(time.Second):
+		t.Errorf("goroutine drop is not reported")
+	}
+}
+
+func TestAutoPprof_watchErrorRate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileCPU().
+		Return([]byte("cpu"), nil)
+	mockProfiler.EXPECT().
+		profileGoroutine().
+		Return([]byte("goroutine"), nil)
+
+	var (
+		mu       sync.Mutex
+		reported int
+	)
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, ci report.CPUInfo) error {
+				if ci.Trigger != report.TriggerErrorRate {
+					t.Errorf("CPUInfo.Trigger = %q, want %q", ci.Trigger, report.TriggerErrorRate)
+				}
+				if ci.ErrorRate < ci.ErrorRateThreshold {
+					t.Errorf("CPUInfo.ErrorRate = %v, want >= %v", ci.ErrorRate, ci.ErrorRateThreshold)
+				}
+				mu.Lock()
+				reported++
+				mu.Unlock()
+				return nil
+			},
+		)
+	mockReporter.EXPECT().
+		ReportGoroutineProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, gi report.GoroutineInfo) error {
+				if gi.Trigger != report.TriggerErrorRate {
+					t.Errorf("GoroutineInfo.Trigger = %q, want %q", gi.Trigger, report.TriggerErrorRate)
+				}
+				mu.Lock()
+				reported++
+				mu.Unlock()
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		watchInterval:               100 * time.Millisecond,
+		errorRateThreshold:          10,
+		errors:                      newErrorRate(time.Second),
+		minConsecutiveOverThreshold: 12,
+		profiler:                    mockProfiler,
+		reporter:                    mockReporter,
+		stopC:                       make(chan struct{}),
+	}
+
+	// 20 errors per second over the window.
+	for i := 0; i < 20; i++ {
+		ap.errors.record(time.Now())
+	}
+
+	go ap.watchErrorRate()
+	t.Cleanup(func() { ap.stop() })
+
+	// Wait for profiling and reporting.
+	time.Sleep(350 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if reported != 2 {
+		t.Errorf("reported = %d, want 2", reported)
+	}
+}
+
+func TestAutoPprof_captureAppTriggered_wallClock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileCPU().
+		Return([]byte("cpu"), nil)
+	mockProfiler.EXPECT().
+		profileGoroutine().
+		Return([]byte("goroutine"), nil)
+	mockProfiler.EXPECT().
+		profileWallClock().
+		Return([]byte("wallclock"), nil)
+
+	var (
+		mu         sync.Mutex
+		triggerIDs = map[string]bool{}
+	)
+	mockReporter := report.NewMockProfileReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, ci report.CPUInfo) error {
+				mu.Lock()
+				triggerIDs[ci.TriggerID] = true
+				mu.Unlock()
+				return nil
+			},
+		)
+	mockReporter.EXPECT().
+		ReportGoroutineProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, gi report.GoroutineInfo) error {
+				mu.Lock()
+				triggerIDs[gi.TriggerID] = true
+				mu.Unlock()
+				return nil
+			},
+		)
+	mockReporter.EXPECT().
+		ReportProfile(gomock.Any(), gomock.Any(), report.ProfileKindWallClock, gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, r io.Reader, _ report.ProfileKind, pi report.ProfileInfo) error {
+				if b, _ := io.ReadAll(r); string(b) != "wallclock" {
+					t.Errorf("wall-clock profile = %q, want %q", b, "wallclock")
+				}
+				mu.Lock()
+				triggerIDs[pi.TriggerID] = true
+				mu.Unlock()
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		enableWallClock: true,
+		profiler:        mockProfiler,
+		reporter:        mockReporter,
+		stopC:           make(chan struct{}),
+	}
+	if err := ap.captureAppTriggered(
+		context.Background(),
+		report.CPUInfo{Trigger: report.TriggerLatencyBreach},
+		report.GoroutineInfo{Trigger: report.TriggerLatencyBreach},
+	); err != nil {
+		t.Fatalf("captureAppTriggered() = %v, want nil", err)
+	}
+	if len(triggerIDs) != 1 || triggerIDs[""] {
+		t.Errorf("TriggerIDs = %v, want a shared one", triggerIDs)
+	}
+}
+
+func TestAutoPprof_captureStartup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileCPU().
+		Return([]byte("cpu"), nil)
+	mockProfiler.EXPECT().
+		profileHeap().
+		Return([]byte("heap"), nil)
+
+	var (
+		mu         sync.Mutex
+		triggerIDs = map[string]bool{}
+		reported   int
+	)
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, ci report.CPUInfo) error {
+				if ci.Trigger != report.TriggerStartup {
+					t.Errorf("CPUInfo.Trigger = %q, want %q", ci.Trigger, report.TriggerStartup)
+				}
+				mu.Lock()
+				triggerIDs[ci.TriggerID] = true
+				reported++
+				mu.Unlock()
+				return nil
+			},
+		)
+	mockReporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, mi report.MemInfo) error {
+				if mi.Trigger != report.TriggerStartup {
+					t.Errorf("MemInfo.Trigger = %q, want %q", mi.Trigger, report.TriggerStartup)
+				}
+				mu.Lock()
+				triggerIDs[mi.TriggerID] = true
+				reported++
+				mu.Unlock()
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		captureOnStartup:    true,
+		startupCaptureDelay: 100 * time.Millisecond,
+		profiler:            mockProfiler,
+		reporter:            mockReporter,
+		stopC:               make(chan struct{}),
+	}
+
+	go ap.captureStartup()
+	t.Cleanup(func() { ap.stop() })
+
+	// Nothing is captured before the delay.
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	if reported != 0 {
+		t.Errorf("reported before the delay = %d, want 0", reported)
+	}
+	mu.Unlock()
+
+	// Wait for profiling and reporting.
+	time.Sleep(200 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if reported != 2 {
+		t.Errorf("reported = %d, want 2", reported)
+	}
+	if len(triggerIDs) != 1 || triggerIDs[""] {
+		t.Errorf("trigger ids = %v, want one shared id", triggerIDs)
+	}
+}
+
+func TestAutoPprof_incident(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileGoroutine().
+		Return([]byte("goroutine"), nil)
+
+	var (
+		mu          sync.Mutex
+		incidentIDs = map[string]bool{}
+	)
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(2).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, mi report.MemInfo) error {
+				mu.Lock()
+				incidentIDs[mi.IncidentID] = true
+				mu.Unlock()
+				return nil
+			},
+		)
+	mockReporter.EXPECT().
+		ReportGoroutineProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, gi report.GoroutineInfo) error {
+				mu.Lock()
+				incidentIDs[gi.IncidentID] = true
+				mu.Unlock()
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		incidents: newIncident(time.Minute),
+		profiler:  mockProfiler,
+		reporter:  mockReporter,
+		stopC:     make(chan struct{}),
+	}
+	if err := ap.reportHeap([]byte("heap"), report.MemInfo{}); err != nil {
+		t.Fatalf("reportHeap() = %v, want nil", err)
+	}
+	if err := ap.sendGoroutineProfile(
+		context.Background(), report.GoroutineInfo{Trigger: report.TriggerFD},
+	); err != nil {
+		t.Fatalf("sendGoroutineProfile() = %v, want nil", err)
+	}
+	if len(incidentIDs) != 1 || incidentIDs[""] {
+		t.Errorf("incident ids = %v, want one shared id", incidentIDs)
+	}
+	st := ap.status()
+	if !st.Incident.Open || !incidentIDs[st.Incident.ID] || st.Incident.Reports != 2 {
+		t.Errorf("status().Incident = %+v, want the open incident of 2 reports", st.Incident)
+	}
+
+	// The startup profile isn't of the incident.
+	incidentIDs = map[string]bool{}
+	if err := ap.reportHeap(
+		[]byte("heap"), report.MemInfo{Trigger: report.TriggerStartup},
+	); err != nil {
+		t.Fatalf("reportHeap() = %v, want nil", err)
+	}
+	if !incidentIDs[""] {
+		t.Errorf("incident ids = %v, want no id of the startup profile", incidentIDs)
+	}
+}
+
+func fib(n int) int64 {
+	if n <= 1 {
+		return int64(n)
+	}
+	return fib(n-1) + fib(n-2)
+}
+
+func BenchmarkLightJob(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		fib(10)
+	}
+}
+
+func BenchmarkLightJobWithWatchCPUUsage(b *testing.B) {
+	var (
+		qryer, _ = newQueryer("")
+		ticker   = time.NewTicker(defaultWatchInterval)
+	)
+	for i := 0; i < b.N; i++ {
+		select {
+		case <-ticker.C:
+			_, _ = qryer.cpuUsage()
+		default:
+			fib(10)
+		}
+	}
+}
+
+func BenchmarkLightJobWithWatchMemUsage(b *testing.B) {
+	var (
+		qryer, _ = newQueryer("")
+		ticker   = time.NewTicker(defaultWatchInterval)
+	)
+	for i := 0; i < b.N; i++ {
+		select {
+		case <-ticker.C:
+			_, _ = qryer.memUsage()
+		default:
+			fib(10)
+		}
+	}
+}
+
+func BenchmarkHeavyJob(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		fib(24)
+	}
+}
+
+func BenchmarkHeavyJobWithWatchCPUUsage(b *testing.B) {
+	var (
+		qryer, _ = newQueryer("")
+		ticker   = time.NewTicker(defaultWatchInterval)
+	)
+	for i := 0; i < b.N; i++ {
+		select {
+		case <-ticker.C:
+			_, _ = qryer.cpuUsage()
+		default:
+			fib(24)
+		}
+	}
+}
+
+func BenchmarkHeavyJobWithWatchMemUsage(b *testing.B) {
+	var (
+		qryer, _ = newQueryer("")
+		ticker   = time.NewTicker(defaultWatchInterval)
+	)
+	for i := 0; i < b.N; i++ {
+		select {
+		case <-ticker.C:
+			_, _ = qryer.memUsage()
 		default:
 			fib(24)
 		}
 	}
 }
+
+func TestAutoPprof_reportGoroutineProfile_releaseTrial(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	// Nothing is reported in the cooldown of the state.
+	mockReporter := report.NewMockReporter(ctrl)
+
+	state := loadStateStore(filepath.Join(t.TempDir(), "state.json"))
+	if err := state.recordReport(stateKindGoroutine, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	breaker := newCircuitBreaker(1, time.Minute)
+	breaker.record(errors.New("sink is down"))
+	breaker.openedAt = time.Now().Add(-time.Minute) // The cooldown elapsed.
+
+	ap := &autoPprof{
+		watchInterval:               time.Minute,
+		minConsecutiveOverThreshold: 1,
+		reporter:                    mockReporter,
+		breaker:                     breaker,
+		state:                       state,
+		stopC:                       make(chan struct{}),
+	}
+	if err := ap.reportGoroutineProfile(report.GoroutineInfo{}); err != nil {
+		t.Errorf("reportGoroutineProfile() = %v, want nil", err)
+	}
+	if got := breaker.status().State; got != BreakerHalfOpen {
+		t.Errorf("state = %s, want %s", got, BreakerHalfOpen)
+	}
+	// The skipped report gave up the trial for the next one.
+	if !breaker.allow() {
+		t.Errorf("allow() after the skipped trial = false, want true")
+	}
+}
+
+func TestAutoPprof_healthInfo(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	// Neither the queryer nor the reporter is called by the health.
+	mockQueryer := NewMockqueryer(ctrl)
+	mockReporter := report.NewMockPingReporter(ctrl)
+
+	limits := newCgroupLimits()
+	limits.setCPUQuota(2)
+	limits.setMemLimit(100)
+
+	breaker := newCircuitBreaker(3, time.Minute)
+	breaker.record(errors.New("unreachable"))
+
+	stats := &reportStats{}
+	stats.record(nil)
+
+	watchers := newWatcherHealth()
+	watchers.start(watcherCPU)
+	watchers.tick(watcherCPU)
+	watchers.start(watcherMem)
+
+	ap := &autoPprof{
+		watchInterval: 100 * time.Millisecond,
+		startedAt:     time.Now().Add(-time.Second),
+		watchers:      watchers,
+		queryer:       mockQueryer,
+		reporter:      mockReporter,
+		limits:        limits,
+		breaker:       breaker,
+		stats:         stats,
+		capabilities:  Capabilities{CgroupVersion: 2},
+		stopC:         make(chan struct{}),
+	}
+	info := ap.healthInfo()
+	if info.Status != HealthDegraded {
+		t.Errorf("Status = %s, want %s", info.Status, HealthDegraded)
+	}
+	wantReasons := []string{"mem watcher stalled", "reporter failing"}
+	if !reflect.DeepEqual(info.Reasons, wantReasons) {
+		t.Errorf("Reasons = %v, want %v", info.Reasons, wantReasons)
+	}
+	if info.Limits.CPUQuota != 2 || info.Limits.MemLimitBytes != 100 {
+		t.Errorf("Limits = %+v, want the cpu quota 2 and the mem limit 100", info.Limits)
+	}
+	if info.Capabilities.CgroupVersion != 2 {
+		t.Errorf("Capabilities = %+v, want the probed ones", info.Capabilities)
+	}
+	if info.Reporter.ConsecutiveFailures != 1 || info.Reporter.LastReportTime.IsZero() {
+		t.Errorf("Reporter = %+v, want the failure and the last report", info.Reporter)
+	}
+
+	ap.stop()
+	if info := ap.healthInfo(); info.Status != HealthDown {
+		t.Errorf("Status = %s, want %s after the stop", info.Status, HealthDown)
+	}
+}
+
+func TestAutoPprof_notifyLatencyBreach(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileCPU().
+		Return([]byte("cpu"), nil)
+	mockProfiler.EXPECT().
+		profileGoroutine().
+		Return([]byte("goroutine"), nil)
+
+	var (
+		mu         sync.Mutex
+		triggerIDs = map[string]bool{}
+	)
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, ci report.CPUInfo) error {
+				if ci.Trigger != report.TriggerLatencyBreach {
+					t.Errorf("CPUInfo.Trigger = %q, want %q", ci.Trigger, report.TriggerLatencyBreach)
+				}
+				mu.Lock()
+				triggerIDs[ci.TriggerID] = true
+				mu.Unlock()
+				return nil
+			},
+		)
+	mockReporter.EXPECT().
+		ReportGoroutineProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, gi report.GoroutineInfo) error {
+				if gi.Trigger != report.TriggerLatencyBreach {
+					t.Errorf("GoroutineInfo.Trigger = %q, want %q", gi.Trigger, report.TriggerLatencyBreach)
+				}
+				mu.Lock()
+				triggerIDs[gi.TriggerID] = true
+				mu.Unlock()
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		cpuThreshold:    0.5, // 50%.
+		latencyDebounce: newDebounce(time.Minute),
+		profiler:        mockProfiler,
+		reporter:        mockReporter,
+		stopC:           make(chan struct{}),
+	}
+	ctx := context.Background()
+	if err := ap.notifyLatencyBreach(ctx); err != nil {
+		t.Fatalf("notifyLatencyBreach() = %v, want nil", err)
+	}
+	if len(triggerIDs) != 1 || triggerIDs[""] {
+		t.Errorf("trigger ids = %v, want one shared id", triggerIDs)
+	}
+
+	// The next notification is debounced without the profiling.
+	if err := ap.notifyLatencyBreach(ctx); !errors.Is(err, ErrLatencyBreachDebounced) {
+		t.Errorf("notifyLatencyBreach() = %v, want %v", err, ErrLatencyBreachDebounced)
+	}
+}
+
+func TestAutoPprof_sumMemUsageComponents(t *testing.T) {
+	fields := &memFields{rss: 4, activeFile: 2, inactiveFile: 3, swap: 1}
+	testCases := []struct {
+		name       string
+		components MemUsageComponents
+		stat       memStat
+		want       uint64
+		wantValues map[string]uint64
+	}{
+		{
+			name:       "working set by default",
+			components: 0,
+			stat:       memStat{usage: 6, fields: fields},
+			want:       6,
+		},
+		{
+			name:       "rss",
+			components: MemUsageRSS,
+			stat:       memStat{usage: 6, fields: fields},
+			want:       4,
+			wantValues: map[string]uint64{"rss": 4},
+		},
+		{
+			name:       "rss and cache",
+			components: MemUsageRSS | MemUsageCache,
+			stat:       memStat{usage: 6, fields: fields},
+			want:       9,
+			wantValues: map[string]uint64{"rss": 4, "active_file": 2, "inactive_file": 3},
+		},
+		{
+			name:       "rss and swap",
+			components: MemUsageRSS | MemUsageSwap,
+			stat:       memStat{usage: 6, fields: fields},
+			want:       5,
+			wantValues: map[string]uint64{"rss": 4, "swap": 1},
+		},
+		{
+			name:       "fields are unavailable",
+			components: MemUsageRSS,
+			stat:       memStat{usage: 6},
+			want:       6,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ap := &autoPprof{memUsageComponents: tc.components}
+			ap.sumMemUsageComponents(&tc.stat)
+			if tc.stat.usage != tc.want {
+				t.Errorf("usage = %d, want %d", tc.stat.usage, tc.want)
+			}
+			if tc.wantValues == nil {
+				return
+			}
+			if got := tc.stat.fields.values(tc.components); !reflect.DeepEqual(got, tc.wantValues) {
+				t.Errorf("values() = %v, want %v", got, tc.wantValues)
+			}
+		})
+	}
+}
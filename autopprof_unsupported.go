@@ -3,10 +3,75 @@
 
 package autopprof
 
+import (
+	"context"
+	"net/http"
+)
+
 // Start does not do anything on unsupported platforms.
 func Start(opt Option) error {
 	return ErrUnsupportedPlatform
 }
 
+// StartWithContext does not do anything on unsupported platforms.
+func StartWithContext(ctx context.Context, opt Option) error {
+	return ErrUnsupportedPlatform
+}
+
 // Stop does not do anything on unsupported platforms.
 func Stop() {}
+
+// Status returns the zero StatusInfo on unsupported platforms.
+func Status() StatusInfo {
+	return StatusInfo{}
+}
+
+// HealthHandler returns the handler serving the HealthDown on
+// unsupported platforms.
+func HealthHandler() http.Handler {
+	return newHealthHandler(func() HealthInfo {
+		return HealthInfo{
+			Status:   HealthDown,
+			Reasons:  []string{ErrUnsupportedPlatform.Error()},
+			Watchers: map[string]WatcherHealth{},
+		}
+	})
+}
+
+// CaptureOnCrash does not do anything on unsupported platforms.
+func CaptureOnCrash(reason string) error {
+	return ErrUnsupportedPlatform
+}
+
+// NotifyLatencyBreach does not do anything on unsupported platforms.
+func NotifyLatencyBreach(ctx context.Context) error {
+	return ErrUnsupportedPlatform
+}
+
+// CPUSnapshots returns nil on unsupported platforms.
+func CPUSnapshots() []CPUSnapshot {
+	return nil
+}
+
+// RecordError does not do anything on unsupported platforms.
+func RecordError() {}
+
+// CaptureNamed does not do anything on unsupported platforms.
+func CaptureNamed(name string) error {
+	return ErrUnsupportedPlatform
+}
+
+// Probe does not do anything on unsupported platforms.
+func Probe() (Capabilities, error) {
+	return Capabilities{}, ErrUnsupportedPlatform
+}
+
+// EnableCPUProfiling does not do anything on unsupported platforms.
+func EnableCPUProfiling(enabled bool) error {
+	return ErrUnsupportedPlatform
+}
+
+// EnableMemProfiling does not do anything on unsupported platforms.
+func EnableMemProfiling(enabled bool) error {
+	return ErrUnsupportedPlatform
+}
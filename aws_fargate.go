@@ -5,7 +5,6 @@ package autopprof
 
 import (
 	"bufio"
-	"fmt"
 	"os"
 	"path"
 	"strconv"
@@ -47,6 +46,15 @@ func (c *awsFargate) setCPUQuota() error {
 	return nil
 }
 
+func (c *awsFargate) setCPUSnapshotSize(size int) {
+	c.q = newCPUUsageSnapshotQueue(size)
+}
+
+func (c *awsFargate) cpuSnapshots() []CPUSnapshot {
+	// The usage of the fargate is in nanoseconds.
+	return c.q.export(time.Nanosecond)
+}
+
 func (c *awsFargate) snapshotCPUUsage(usage uint64) {
 	c.q.enqueue(&cpuUsageSnapshot{
 		usage:     usage,
@@ -89,23 +97,48 @@ func (c *awsFargate) cpuUsage() (float64, error) {
 	return totalUsage / cpuLimit, nil
 }
 
-func (c *awsFargate) memUsage() (float64, error) {
+func (c *awsFargate) memUsage() (*memStat, error) {
 	stat, err := c.stat()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	var (
-		sm    = stat.Memory
-		usage = sm.Usage.Usage - sm.InactiveFile
-		limit = sm.HierarchicalMemoryLimit
-	)
-	return float64(usage) / float64(limit), nil
+	sm := stat.Memory
+	return &memStat{
+		usage:  workingSet(sm.Usage.Usage, sm.InactiveFile),
+		limit:  sm.HierarchicalMemoryLimit,
+		kmem:   kmemUsageV1(sm),
+		fields: memFieldsV1(sm),
+	}, nil
 }
 
-func (c *awsFargate) parseCPU(filename string) (int, error) {
-	fullpath := path.Join(c.mountPoint, c.cpuSubsystem, filename)
-	fmt.Println("@@ autopprof @@ fullpath = ", fullpath)
+// cpuPressure returns ErrPSIUnavailable, since the task metadata
+// doesn't provide the pressure stall information.
+func (c *awsFargate) cpuPressure() (float64, error) {
+	return 0, ErrPSIUnavailable
+}
+
+// numaStat returns ErrNUMAUnavailable, since the task metadata
+// doesn't provide the NUMA stats.
+func (c *awsFargate) numaStat() ([]numaNode, error) {
+	return nil, ErrNUMAUnavailable
+}
+
+func (c *awsFargate) status() CgroupStatus {
+	return CgroupStatus{
+		Version: 1,
+		Path:    c.staticPath,
+		// The cpu quota is given by the Option.VCPUSize.
+		CPUQuota: c.vCPUSize,
+		CPUUsageSource: path.Join(
+			c.mountPoint, string(cgroups.Cpuacct), c.staticPath, "cpuacct.usage",
+		),
+		MemLimitSource: path.Join(
+			c.mountPoint, string(cgroups.Memory), c.staticPath, "memory.stat",
+		) + " (hierarchical_memory_limit)",
+	}
+}
 
+func (c *awsFargate) parseCPU(filename string) (int, error) {
 	f, err := os.Open(
 		path.Join(c.mountPoint, c.cpuSubsystem, filename),
 	)
@@ -115,8 +148,6 @@ func (c *awsFargate) parseCPU(filename string) (int, error) {
 	scanner := bufio.NewScanner(f)
 	if scanner.Scan() {
 		scanned := scanner.Text()
-		fmt.Println("@@ autopprof @@ scanned = ", scanned)
-
 		val, err := strconv.Atoi(scanned)
 		if err != nil {
 			return 0, err
@@ -0,0 +1,146 @@
+package autopprof
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultCPUBaselineBucket = time.Hour
+
+	// baselineDayWeight is the weight of the mean usage of the day in
+	//  the expected usage of its bucket, so the baseline follows
+	//  the drift of the pattern over about a week.
+	baselineDayWeight = 0.3
+)
+
+// usageBaseline learns the typical usage of each time-of-day bucket
+// across the days, so the usage is compared with the expected one of
+// the hour rather than the static threshold. (See
+// Option.CPUBaselineDeviation) A nil usageBaseline keeps the static
+// threshold.
+type usageBaseline struct {
+	bucket    time.Duration
+	deviation float64
+	// persist is called with the copy of the expected usages when
+	//  a bucket is learned. It may be nil.
+	persist func(expected []float64)
+
+	mu sync.Mutex
+	// expected is the expected usage per bucket. Zero means the bucket
+	//  isn't learned yet.
+	expected []float64
+
+	// cur is the bucket being observed since the curStart, and sum and
+	//  count are its usages so far.
+	cur      int
+	curStart time.Time
+	sum      float64
+	count    int
+
+	last BaselineStatus
+}
+
+// newUsageBaseline returns the usageBaseline of the bucket, starting
+// from the expected usages if they're of the same bucket. It returns
+// nil if the deviation is zero.
+func newUsageBaseline(
+	deviation float64, bucket time.Duration, expected []float64,
+) *usageBaseline {
+	if deviation == 0 {
+		return nil
+	}
+	if bucket == 0 {
+		bucket = defaultCPUBaselineBucket
+	}
+	n := int((24*time.Hour + bucket - 1) / bucket)
+	b := &usageBaseline{
+		bucket:    bucket,
+		deviation: deviation,
+		expected:  make([]float64, n),
+		cur:       -1,
+	}
+	if len(expected) == n {
+		copy(b.expected, expected)
+	}
+	return b
+}
+
+// bucketOf returns the bucket of the local time-of-day of the t.
+func (b *usageBaseline) bucketOf(t time.Time) int {
+	d := time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	return int(d / b.bucket)
+}
+
+// threshold observes the usage at the now, and returns the threshold
+// to compare it with. It's the expected usage of the bucket raised by
+// the deviation, but never lower than the static threshold, so only
+// the buckets of the expected high usage raise it, and the idle ones
+// don't trigger on the noise.
+func (b *usageBaseline) threshold(now time.Time, usage, static float64) float64 {
+	if b == nil {
+		return static
+	}
+	learned := b.observe(now, usage)
+
+	b.mu.Lock()
+	expected := b.expected[b.cur]
+	threshold := static
+	if expected > 0 && expected*(1+b.deviation) > static {
+		threshold = expected * (1 + b.deviation)
+	}
+	b.last = BaselineStatus{
+		Bucket:    b.cur,
+		Expected:  expected,
+		Actual:    usage,
+		Threshold: threshold,
+	}
+	b.mu.Unlock()
+
+	if learned != nil && b.persist != nil {
+		b.persist(learned)
+	}
+	return threshold
+}
+
+// observe adds the usage to its bucket. If the bucket has ended,
+// it folds the mean usage of the bucket into its expected usage and
+// returns the copy of the expected usages.
+func (b *usageBaseline) observe(now time.Time, usage float64) []float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var learned []float64
+	idx := b.bucketOf(now)
+	if idx != b.cur || now.Sub(b.curStart) >= b.bucket {
+		if b.count > 0 {
+			mean := b.sum / float64(b.count)
+			if b.expected[b.cur] == 0 {
+				b.expected[b.cur] = mean
+			} else {
+				b.expected[b.cur] = baselineDayWeight*mean +
+					(1-baselineDayWeight)*b.expected[b.cur]
+			}
+			learned = make([]float64, len(b.expected))
+			copy(learned, b.expected)
+		}
+		b.cur, b.curStart = idx, now
+		b.sum, b.count = 0, 0
+	}
+	b.sum += usage
+	b.count++
+	return learned
+}
+
+// status returns the last observation.
+func (b *usageBaseline) status() BaselineStatus {
+	if b == nil {
+		return BaselineStatus{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.last
+}
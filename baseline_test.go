@@ -0,0 +1,105 @@
+package autopprof
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUsageBaseline_threshold(t *testing.T) {
+	// A nil usageBaseline keeps the static threshold.
+	var nilBaseline *usageBaseline
+	if got := nilBaseline.threshold(time.Now(), 0.9, 0.75); got != 0.75 {
+		t.Errorf("threshold() of nil = %v, want 0.75", got)
+	}
+	if got := nilBaseline.status(); got != (BaselineStatus{}) {
+		t.Errorf("status() of nil = %+v, want zero", got)
+	}
+
+	var (
+		day   = time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+		at    = func(d, h int) time.Time { return day.AddDate(0, 0, d).Add(time.Duration(h) * time.Hour) }
+		saved []float64
+		b     = newUsageBaseline(0.5, time.Hour, nil)
+	)
+	b.persist = func(expected []float64) { saved = expected }
+
+	testCases := []struct {
+		name  string
+		now   time.Time
+		usage float64
+		want  float64
+	}{
+		{name: "peak hour of the first day", now: at(0, 13), usage: 0.8, want: 0.75},
+		{name: "idle hour of the first day", now: at(0, 14), usage: 0.1, want: 0.75},
+		// 0.8 is learned for the 13:00, so the threshold is 0.8 * 1.5.
+		{name: "peak hour of the next day", now: at(1, 13), usage: 0.8, want: 1.2},
+		// 0.1 * 1.5 is lower than the static threshold.
+		{name: "idle hour of the next day", now: at(1, 14), usage: 0.1, want: 0.75},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := b.threshold(tc.now, tc.usage, 0.75)
+			if math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("threshold() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	if len(saved) != 24 || math.Abs(saved[13]-0.8) > 1e-9 || saved[14] != 0.1 {
+		t.Errorf("persisted = %v, want 0.8 at 13 and 0.1 at 14", saved)
+	}
+	st := b.status()
+	if st.Bucket != 14 || st.Expected != 0.1 || st.Actual != 0.1 || st.Threshold != 0.75 {
+		t.Errorf("status() = %+v", st)
+	}
+}
+
+func TestUsageBaseline_observe(t *testing.T) {
+	var (
+		day = time.Date(2024, 1, 1, 13, 0, 0, 0, time.Local)
+		b   = newUsageBaseline(0.5, time.Hour, nil)
+	)
+	for _, usage := range []float64{0.4, 0.8} {
+		if learned := b.observe(day, usage); learned != nil {
+			t.Fatalf("observe() = %v, want nil within the bucket", learned)
+		}
+	}
+	// The mean of the bucket is learned when it ends.
+	learned := b.observe(day.Add(time.Hour), 0.1)
+	if math.Abs(learned[13]-0.6) > 1e-9 {
+		t.Errorf("expected usage of 13:00 = %v, want 0.6", learned[13])
+	}
+
+	// The next day is weighted into the expected usage.
+	b.observe(day.AddDate(0, 0, 1), 0.9)
+	learned = b.observe(day.AddDate(0, 0, 1).Add(time.Hour), 0.1)
+	if want := 0.3*0.9 + 0.7*0.6; math.Abs(learned[13]-want) > 1e-9 {
+		t.Errorf("expected usage of 13:00 = %v, want %v", learned[13], want)
+	}
+}
+
+func TestStateStore_cpuBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "autopprof.state")
+
+	expected := make([]float64, 24)
+	expected[13] = 0.8
+	if err := loadStateStore(path).saveCPUBaseline(time.Hour, expected); err != nil {
+		t.Fatalf("saveCPUBaseline() = %v, want nil", err)
+	}
+
+	s := loadStateStore(path)
+	if got := s.cpuBaseline(time.Hour); !reflect.DeepEqual(got, expected) {
+		t.Errorf("cpuBaseline() = %v, want %v", got, expected)
+	}
+	// The baseline of the other bucket is relearned.
+	if got := s.cpuBaseline(30 * time.Minute); got != nil {
+		t.Errorf("cpuBaseline() of the other bucket = %v, want nil", got)
+	}
+	b := newUsageBaseline(0.5, time.Hour, s.cpuBaseline(time.Hour))
+	if got := b.threshold(time.Date(2024, 1, 1, 13, 0, 0, 0, time.Local), 0.8, 0.75); math.Abs(got-1.2) > 1e-9 {
+		t.Errorf("threshold() of the restored baseline = %v, want 1.2", got)
+	}
+}
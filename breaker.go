@@ -0,0 +1,157 @@
+package autopprof
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of the circuit breaker around the reporter.
+type BreakerState int
+
+const (
+	// BreakerClosed means that the reporter is healthy and
+	//  the reports are sent as usual.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen means that the reporter failed consecutively and
+	//  the reports are skipped until the cooldown elapses.
+	BreakerOpen
+	// BreakerHalfOpen means that the cooldown elapsed and a single
+	//  report is sent to test whether the reporter is recovered.
+	//  The others are skipped until its result is recorded.
+	BreakerHalfOpen
+)
+
+// String returns the name of the breaker state.
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// circuitBreaker stops calling the failing reporter for a while.
+// A nil breaker never opens.
+type circuitBreaker struct {
+	// failureThreshold is the number of consecutive failures
+	//  to open the breaker.
+	failureThreshold int
+	// cooldown is the duration to keep the breaker open.
+	cooldown time.Duration
+
+	mu                  sync.Mutex
+	state               BreakerState
+	consecutiveFailures int
+	openedAt            time.Time
+	// trialAt is when the trial of the half-open breaker is allowed.
+	//  It's zero without the trial in flight.
+	trialAt time.Time
+
+	// now returns the current time. It's replaced in tests.
+	now func() time.Time
+}
+
+func newCircuitBreaker(
+	failureThreshold int, cooldown time.Duration,
+) *circuitBreaker {
+	return &circuitBreaker{
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+		now:              time.Now,
+	}
+}
+
+// allow reports whether the reporter can be called now.
+// If the breaker is open and the cooldown elapsed, the breaker
+// becomes half-open and allows the reporter to be called once as
+// the trial. The others are rejected until the result of the trial is
+// recorded. The trial neither recorded nor released, e.g. of a hung
+// report, is taken over after the cooldown.
+func (b *circuitBreaker) allow() bool {
+	_, ok := b.acquire()
+	return ok
+}
+
+// acquire is the allow which also returns the release of the trial.
+// The caller must call the release when it's done, so the trial given
+// up without the result, e.g. skipped by the cooldown of the reports,
+// is handed to the next report at once. It does nothing if the result
+// is recorded or the call isn't the trial.
+func (b *circuitBreaker) acquire() (release func(), ok bool) {
+	release = func() {}
+	if b == nil {
+		return release, true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	switch b.state {
+	case BreakerClosed:
+		return release, true
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return release, false
+		}
+		b.state = BreakerHalfOpen
+	case BreakerHalfOpen:
+		if !b.trialAt.IsZero() && now.Sub(b.trialAt) < b.cooldown {
+			return release, false
+		}
+	}
+	b.trialAt = now
+	return func() { b.release(now) }, true
+}
+
+// release gives up the trial taken at the trialAt unless its result
+// is recorded.
+func (b *circuitBreaker) release(trialAt time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen && b.trialAt.Equal(trialAt) {
+		b.trialAt = time.Time{}
+	}
+}
+
+// record records the result of the reporter call.
+func (b *circuitBreaker) record(err error) (opened bool) {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialAt = time.Time{}
+	if err == nil {
+		b.state = BreakerClosed
+		b.consecutiveFailures = 0
+		return false
+	}
+	b.consecutiveFailures++
+	if b.state == BreakerHalfOpen ||
+		(b.failureThreshold > 0 && b.consecutiveFailures >= b.failureThreshold) {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+		return true
+	}
+	return false
+}
+
+func (b *circuitBreaker) status() BreakerStatus {
+	if b == nil {
+		return BreakerStatus{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return BreakerStatus{
+		State:               b.state,
+		ConsecutiveFailures: b.consecutiveFailures,
+		OpenedAt:            b.openedAt,
+	}
+}
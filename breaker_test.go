@@ -0,0 +1,169 @@
+package autopprof
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var (
+		now     = time.Now()
+		errSink = errors.New("sink is down")
+	)
+	b := newCircuitBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	steps := []struct {
+		name      string
+		advance   time.Duration
+		result    error
+		wantAllow bool
+		wantState BreakerState
+	}{
+		{
+			name:      "first failure",
+			result:    errSink,
+			wantAllow: true,
+			wantState: BreakerClosed,
+		},
+		{
+			name:      "second failure opens the breaker",
+			result:    errSink,
+			wantAllow: true,
+			wantState: BreakerOpen,
+		},
+		{
+			name:      "skip while cooldown",
+			advance:   30 * time.Second,
+			wantAllow: false,
+			wantState: BreakerOpen,
+		},
+		{
+			name:      "half-open failure opens the breaker again",
+			advance:   30 * time.Second,
+			result:    errSink,
+			wantAllow: true,
+			wantState: BreakerOpen,
+		},
+		{
+			name:      "skip while cooldown again",
+			advance:   59 * time.Second,
+			wantAllow: false,
+			wantState: BreakerOpen,
+		},
+		{
+			name:      "half-open success closes the breaker",
+			advance:   time.Second,
+			result:    nil,
+			wantAllow: true,
+			wantState: BreakerClosed,
+		},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		allowed := b.allow()
+		if allowed != step.wantAllow {
+			t.Errorf("%s: allow() = %v, want %v", step.name, allowed, step.wantAllow)
+		}
+		if allowed {
+			b.record(step.result)
+		}
+		if got := b.status().State; got != step.wantState {
+			t.Errorf("%s: state = %s, want %s", step.name, got, step.wantState)
+		}
+	}
+}
+
+func TestCircuitBreaker_halfOpenTrial(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreaker(1, time.Minute)
+	b.now = func() time.Time { return now }
+
+	b.allow()
+	b.record(errors.New("sink is down"))
+	now = now.Add(time.Minute)
+
+	// Only one of the concurrent reports is the trial.
+	var (
+		wg      sync.WaitGroup
+		allowed int32
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.allow() {
+				atomic.AddInt32(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 1 {
+		t.Fatalf("allow() = true for %d reports, want 1", allowed)
+	}
+	if got := b.status().State; got != BreakerHalfOpen {
+		t.Errorf("state = %s, want %s", got, BreakerHalfOpen)
+	}
+
+	// The trial neither recorded nor released is taken over after
+	//  the cooldown.
+	now = now.Add(time.Minute)
+	if !b.allow() {
+		t.Errorf("allow() after the cooldown of the trial = false, want true")
+	}
+	if b.allow() {
+		t.Errorf("allow() during the trial = true, want false")
+	}
+	b.record(nil)
+	if !b.allow() {
+		t.Errorf("allow() after the successful trial = false, want true")
+	}
+}
+
+func TestCircuitBreaker_release(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreaker(1, time.Minute)
+	b.now = func() time.Time { return now }
+
+	b.allow()
+	b.record(errors.New("sink is down"))
+	now = now.Add(time.Minute)
+
+	release, ok := b.acquire()
+	if !ok {
+		t.Fatalf("acquire() = false after the cooldown, want true")
+	}
+	if b.allow() {
+		t.Errorf("allow() during the trial = true, want false")
+	}
+	// The trial given up without the result is handed over at once.
+	release()
+	release, ok = b.acquire()
+	if !ok {
+		t.Fatalf("acquire() after the release = false, want true")
+	}
+
+	// The release after the result doesn't give up the next trial.
+	b.record(errors.New("sink is down"))
+	now = now.Add(time.Minute)
+	if !b.allow() {
+		t.Fatalf("allow() after the cooldown = false, want true")
+	}
+	release()
+	if b.allow() {
+		t.Errorf("allow() during the next trial = true, want false")
+	}
+}
+
+func TestCircuitBreaker_nil(t *testing.T) {
+	var b *circuitBreaker
+	if !b.allow() {
+		t.Errorf("allow() = false, want true")
+	}
+	if b.record(errors.New("error")) {
+		t.Errorf("record() = true, want false")
+	}
+}
@@ -0,0 +1,84 @@
+package autopprof
+
+import (
+	"sync"
+	"time"
+)
+
+// profilingBudgetWindow is the window in which the profiling budget
+// is refilled fully.
+const profilingBudgetWindow = time.Hour
+
+// profilingBudget is the token bucket of the profiling time.
+// The bucket holds the capacity at most, and it's refilled at the rate
+// of the capacity per profilingBudgetWindow.
+// A nil budget is unlimited.
+type profilingBudget struct {
+	capacity time.Duration
+
+	mu      sync.Mutex
+	tokens  time.Duration
+	last    time.Time
+	skipped int
+
+	// now returns the current time. It's replaced in tests.
+	now func() time.Time
+}
+
+func newProfilingBudget(capacity time.Duration) *profilingBudget {
+	return &profilingBudget{
+		capacity: capacity,
+		tokens:   capacity,
+		last:     time.Now(),
+		now:      time.Now,
+	}
+}
+
+// take takes the d from the budget and reports whether it's taken.
+// If the budget is exhausted, it counts the skipped capture.
+func (b *profilingBudget) take(d time.Duration) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	if b.tokens < d {
+		b.skipped++
+		return false
+	}
+	b.tokens -= d
+	return true
+}
+
+// refill refills the tokens for the elapsed time since the last refill.
+func (b *profilingBudget) refill() {
+	now := b.now()
+	elapsed := now.Sub(b.last)
+	b.last = now
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens += time.Duration(
+		float64(elapsed) / float64(profilingBudgetWindow) * float64(b.capacity),
+	)
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+func (b *profilingBudget) status() BudgetStatus {
+	if b == nil {
+		return BudgetStatus{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	return BudgetStatus{
+		Capacity:  b.capacity,
+		Remaining: b.tokens,
+		Skipped:   b.skipped,
+	}
+}
@@ -0,0 +1,81 @@
+package autopprof
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProfilingBudget(t *testing.T) {
+	now := time.Now()
+	b := newProfilingBudget(30 * time.Second)
+	b.now = func() time.Time { return now }
+	b.last = now
+
+	steps := []struct {
+		name          string
+		advance       time.Duration
+		wantTaken     bool
+		wantRemaining time.Duration
+		wantSkipped   int
+	}{
+		{
+			name:          "first capture",
+			wantTaken:     true,
+			wantRemaining: 20 * time.Second,
+		},
+		{
+			name:          "second capture",
+			wantTaken:     true,
+			wantRemaining: 10 * time.Second,
+		},
+		{
+			name:          "third capture",
+			wantTaken:     true,
+			wantRemaining: 0,
+		},
+		{
+			name:          "skip while exhausted",
+			advance:       time.Minute, // Refills 0.5s.
+			wantTaken:     false,
+			wantRemaining: 500 * time.Millisecond,
+			wantSkipped:   1,
+		},
+		{
+			name:          "refilled",
+			advance:       19 * time.Minute, // Refills 9.5s.
+			wantTaken:     true,
+			wantRemaining: 0,
+			wantSkipped:   1,
+		},
+		{
+			name:          "refill is capped",
+			advance:       3 * time.Hour,
+			wantTaken:     true,
+			wantRemaining: 20 * time.Second,
+			wantSkipped:   1,
+		},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		if taken := b.take(10 * time.Second); taken != step.wantTaken {
+			t.Errorf("%s: take() = %v, want %v", step.name, taken, step.wantTaken)
+		}
+		st := b.status()
+		if st.Remaining != step.wantRemaining {
+			t.Errorf("%s: remaining = %s, want %s", step.name, st.Remaining, step.wantRemaining)
+		}
+		if st.Skipped != step.wantSkipped {
+			t.Errorf("%s: skipped = %d, want %d", step.name, st.Skipped, step.wantSkipped)
+		}
+	}
+}
+
+func TestProfilingBudget_nil(t *testing.T) {
+	var b *profilingBudget
+	if !b.take(time.Hour) {
+		t.Errorf("take() = false, want true")
+	}
+	if st := b.status(); st != (BudgetStatus{}) {
+		t.Errorf("status() = %+v, want zero", st)
+	}
+}
@@ -0,0 +1,61 @@
+package autopprof
+
+import (
+	"time"
+)
+
+// burst schedules the extra captures in quick succession after
+// the first breach of an incident. (See Option.BurstCount)
+// A nil burst never schedules.
+type burst struct {
+	count    int
+	interval time.Duration
+
+	// started reports whether the burst of the ongoing incident is
+	//  started, so the later reports of the incident don't restart it.
+	started bool
+	left    int
+	next    time.Time
+}
+
+// newBurst returns the burst of the count captures including the first
+// one. It returns nil if the count is less than 2.
+func newBurst(count int, interval time.Duration) *burst {
+	if count < 2 {
+		return nil
+	}
+	return &burst{
+		count:    count,
+		interval: interval,
+	}
+}
+
+// start starts the burst at the first breach of the incident.
+func (b *burst) start(now time.Time) {
+	if b == nil || b.started {
+		return
+	}
+	b.started = true
+	b.left = b.count - 1
+	b.next = now.Add(b.interval)
+}
+
+// due reports whether the next capture of the burst is due, and
+// schedules the one after it if so.
+func (b *burst) due(now time.Time) bool {
+	if b == nil || b.left == 0 || now.Before(b.next) {
+		return false
+	}
+	b.left--
+	b.next = now.Add(b.interval)
+	return true
+}
+
+// reset ends the incident, so the next breach starts a new burst.
+func (b *burst) reset() {
+	if b == nil {
+		return
+	}
+	b.started = false
+	b.left = 0
+}
@@ -0,0 +1,39 @@
+package autopprof
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBurst(t *testing.T) {
+	if b := newBurst(1, time.Second); b != nil {
+		t.Errorf("newBurst(1) = %+v, want nil", b)
+	}
+
+	var (
+		b   = newBurst(3, time.Second)
+		now = time.Now()
+	)
+	b.start(now)
+	if b.due(now.Add(500 * time.Millisecond)) {
+		t.Errorf("due() before the interval = true, want false")
+	}
+	if !b.due(now.Add(time.Second)) {
+		t.Errorf("due() after the interval = false, want true")
+	}
+	// The later reports of the incident don't restart the burst.
+	b.start(now.Add(time.Second))
+	if !b.due(now.Add(2 * time.Second)) {
+		t.Errorf("due() of the last capture = false, want true")
+	}
+	if b.due(now.Add(3 * time.Second)) {
+		t.Errorf("due() after the burst = true, want false")
+	}
+
+	// The next incident starts a new burst.
+	b.reset()
+	b.start(now.Add(4 * time.Second))
+	if !b.due(now.Add(5 * time.Second)) {
+		t.Errorf("due() of the new burst = false, want true")
+	}
+}
@@ -0,0 +1,30 @@
+package autopprof
+
+// Environment is the detected environment where the application runs.
+type Environment string
+
+// Environments.
+const (
+	EnvironmentHost       Environment = "host"
+	EnvironmentAWSFargate Environment = "aws-fargate"
+	// EnvironmentKubernetes is any kubernetes cluster. (e.g. GKE, EKS)
+	EnvironmentKubernetes Environment = "kubernetes"
+)
+
+// Capabilities is what the autopprof can do in the current environment.
+type Capabilities struct {
+	// CgroupVersion is the version of the cgroup. (1 or 2)
+	// Zero means that the cgroup is unavailable.
+	CgroupVersion int `json:"cgroup_version"`
+
+	// CPUQuotaSet reports whether the cpu quota is set.
+	// The cpu profiling is disabled if the quota isn't set.
+	CPUQuotaSet bool `json:"cpu_quota_set"`
+	// MemLimitSet reports whether the memory limit is set.
+	MemLimitSet bool `json:"mem_limit_set"`
+	// PSIAvailable reports whether the pressure stall information
+	//  of the cgroup v2 is available.
+	PSIAvailable bool `json:"psi_available"`
+
+	Environment Environment `json:"environment"`
+}
@@ -4,7 +4,10 @@
 package autopprof
 
 import (
-	"fmt"
+	"bufio"
+	"os"
+	"path"
+	"strings"
 
 	"github.com/containerd/cgroups"
 )
@@ -12,24 +15,101 @@ import (
 //go:generate mockgen -source=cgroups.go -destination=cgroups_mock.go -package=autopprof
 
 const (
-	cpuUsageSnapshotQueueSize = 24 // 24 * 5s = 2 minutes.
+	// cpuUsageSnapshotQueueSize is the number of the watches the cpu
+	//  usage is averaged over. (24 * 5s = 2 minutes by default)
+	cpuUsageSnapshotQueueSize = 24
+
+	procSelfCgroupFile = "/proc/self/cgroup"
 )
 
 type queryer interface {
 	cpuUsage() (float64, error)
-	memUsage() (float64, error)
+	memUsage() (*memStat, error)
+	// cpuPressure returns the cpu pressure stall information between
+	//  0 and 1. It returns ErrPSIUnavailable if it's unavailable.
+	cpuPressure() (float64, error)
+	// numaStat returns the memory usages per NUMA node. It returns
+	//  ErrNUMAUnavailable if they're unavailable.
+	numaStat() ([]numaNode, error)
 
 	setCPUQuota() error
+	// setCPUSnapshotSize resizes the queue of the cpu usage snapshots
+	//  which the cpu usage is averaged over. The snapshots taken so far
+	//  are discarded.
+	setCPUSnapshotSize(size int)
+	// cpuSnapshots returns the copies of the cpu usage snapshots from
+	//  the oldest to the newest.
+	cpuSnapshots() []CPUSnapshot
+
+	// status returns where the usages are read from.
+	status() CgroupStatus
 }
 
-func newQueryer() (queryer, error) {
+// newQueryer returns the queryer of the cgroup of the process.
+// If the cgroupPath is given, it's used instead of the detected one.
+func newQueryer(cgroupPath string) (queryer, error) {
 	switch cgroups.Mode() {
 	case cgroups.Legacy:
-		fmt.Println("@@ autopprof @@: Cgroup Version = newCgroupsV1")
-		return newCgroupsV1(), nil
+		cgv1 := newCgroupsV1()
+		if cgroupPath != "" {
+			cgv1.staticPath = cgroupPath
+		}
+		cgv1.gVisor = detectGVisor()
+		return cgv1, nil
 	case cgroups.Hybrid, cgroups.Unified:
-		fmt.Println("@@ autopprof @@: Cgroup Version = newCgroupsV2")
-		return newCgroupsV2(), nil
+		cgv2 := newCgroupsV2()
+		if cgroupPath != "" {
+			cgv2.groupPath = cgroupPath
+		}
+		cgv2.gVisor = detectGVisor()
+		return cgv2, nil
 	}
 	return nil, ErrCgroupsUnavailable
 }
+
+// detectCgroupPath detects the cgroup path of the process from the
+// procCgroupFile. The controller is the v1 controller to look up, and
+// the empty controller means the v2 unified hierarchy.
+// In the multi-container pods, the mounted hierarchy may be the pod's
+// one, so the container's leaf is used if it exists under the dir.
+// Otherwise (e.g. the cgroup namespace is used), it returns "/".
+func detectCgroupPath(procCgroupFile, dir, controller string) string {
+	f, err := os.Open(procCgroupFile)
+	if err != nil {
+		return "/"
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Format: hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if !hasController(fields[1], controller) {
+			continue
+		}
+		p := path.Clean("/" + fields[2])
+		if p == "/" {
+			return p
+		}
+		if fi, err := os.Stat(path.Join(dir, p)); err == nil && fi.IsDir() {
+			return p
+		}
+		return "/"
+	}
+	return "/"
+}
+
+func hasController(controllers, controller string) bool {
+	if controller == "" {
+		return controllers == ""
+	}
+	for _, c := range strings.Split(controllers, ",") {
+		if c == controller {
+			return true
+		}
+	}
+	return false
+}
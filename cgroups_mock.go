@@ -33,6 +33,35 @@ func (m *Mockqueryer) EXPECT() *MockqueryerMockRecorder {
 	return m.recorder
 }
 
+// cpuPressure mocks base method.
+func (m *Mockqueryer) cpuPressure() (float64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "cpuPressure")
+	ret0, _ := ret[0].(float64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// cpuPressure indicates an expected call of cpuPressure.
+func (mr *MockqueryerMockRecorder) cpuPressure() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "cpuPressure", reflect.TypeOf((*Mockqueryer)(nil).cpuPressure))
+}
+
+// cpuSnapshots mocks base method.
+func (m *Mockqueryer) cpuSnapshots() []CPUSnapshot {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "cpuSnapshots")
+	ret0, _ := ret[0].([]CPUSnapshot)
+	return ret0
+}
+
+// cpuSnapshots indicates an expected call of cpuSnapshots.
+func (mr *MockqueryerMockRecorder) cpuSnapshots() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "cpuSnapshots", reflect.TypeOf((*Mockqueryer)(nil).cpuSnapshots))
+}
+
 // cpuUsage mocks base method.
 func (m *Mockqueryer) cpuUsage() (float64, error) {
 	m.ctrl.T.Helper()
@@ -49,10 +78,10 @@ func (mr *MockqueryerMockRecorder) cpuUsage() *gomock.Call {
 }
 
 // memUsage mocks base method.
-func (m *Mockqueryer) memUsage() (float64, error) {
+func (m *Mockqueryer) memUsage() (*memStat, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "memUsage")
-	ret0, _ := ret[0].(float64)
+	ret0, _ := ret[0].(*memStat)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -63,6 +92,21 @@ func (mr *MockqueryerMockRecorder) memUsage() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "memUsage", reflect.TypeOf((*Mockqueryer)(nil).memUsage))
 }
 
+// numaStat mocks base method.
+func (m *Mockqueryer) numaStat() ([]numaNode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "numaStat")
+	ret0, _ := ret[0].([]numaNode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// numaStat indicates an expected call of numaStat.
+func (mr *MockqueryerMockRecorder) numaStat() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "numaStat", reflect.TypeOf((*Mockqueryer)(nil).numaStat))
+}
+
 // setCPUQuota mocks base method.
 func (m *Mockqueryer) setCPUQuota() error {
 	m.ctrl.T.Helper()
@@ -76,3 +120,29 @@ func (mr *MockqueryerMockRecorder) setCPUQuota() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "setCPUQuota", reflect.TypeOf((*Mockqueryer)(nil).setCPUQuota))
 }
+
+// setCPUSnapshotSize mocks base method.
+func (m *Mockqueryer) setCPUSnapshotSize(size int) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "setCPUSnapshotSize", size)
+}
+
+// setCPUSnapshotSize indicates an expected call of setCPUSnapshotSize.
+func (mr *MockqueryerMockRecorder) setCPUSnapshotSize(size interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "setCPUSnapshotSize", reflect.TypeOf((*Mockqueryer)(nil).setCPUSnapshotSize), size)
+}
+
+// status mocks base method.
+func (m *Mockqueryer) status() CgroupStatus {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "status")
+	ret0, _ := ret[0].(CgroupStatus)
+	return ret0
+}
+
+// status indicates an expected call of status.
+func (mr *MockqueryerMockRecorder) status() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "status", reflect.TypeOf((*Mockqueryer)(nil).status))
+}
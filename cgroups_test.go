@@ -4,6 +4,8 @@
 package autopprof
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/containerd/cgroups"
@@ -11,10 +13,88 @@ import (
 
 func TestNewQueryer(t *testing.T) {
 	mode := cgroups.Mode()
-	_, err := newQueryer()
+	_, err := newQueryer("")
 	if mode == cgroups.Unavailable && err == nil {
 		t.Errorf("newQueryer() = nil, want error")
 	} else if err != nil {
 		t.Errorf("newQueryer() = %v, want nil", err)
 	}
 }
+
+func TestDetectCgroupPath(t *testing.T) {
+	// Fixture of the nested pod/container layout.
+	root := t.TempDir()
+	containerPath := "/kubepods/burstable/pod1234/container5678"
+	for _, dir := range []string{
+		filepath.Join(root, "unified", containerPath),
+		filepath.Join(root, "memory", containerPath),
+	} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name       string
+		procCgroup string
+		dir        string
+		controller string
+		want       string
+	}{
+		{
+			name:       "v2 container leaf",
+			procCgroup: "0::" + containerPath + "\n",
+			dir:        filepath.Join(root, "unified"),
+			controller: "",
+			want:       containerPath,
+		},
+		{
+			name: "v1 container leaf",
+			procCgroup: "5:cpu,cpuacct:/kubepods/burstable/pod1234\n" +
+				"4:memory:" + containerPath + "\n",
+			dir:        filepath.Join(root, "memory"),
+			controller: "memory",
+			want:       containerPath,
+		},
+		{
+			name:       "v1 combined controllers",
+			procCgroup: "5:cpu,cpuacct:" + containerPath + "\n",
+			dir:        filepath.Join(root, "memory"),
+			controller: "cpuacct",
+			want:       containerPath,
+		},
+		{
+			name:       "cgroup namespace",
+			procCgroup: "0::/\n",
+			dir:        filepath.Join(root, "unified"),
+			controller: "",
+			want:       "/",
+		},
+		{
+			name:       "mounted at the container leaf",
+			procCgroup: "0::/docker/abcdef\n",
+			dir:        filepath.Join(root, "unified"),
+			controller: "",
+			want:       "/",
+		},
+		{
+			name:       "missing controller",
+			procCgroup: "4:memory:" + containerPath + "\n",
+			dir:        filepath.Join(root, "memory"),
+			controller: "pids",
+			want:       "/",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			procCgroupFile := filepath.Join(t.TempDir(), "cgroup")
+			if err := os.WriteFile(procCgroupFile, []byte(tc.procCgroup), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got := detectCgroupPath(procCgroupFile, tc.dir, tc.controller)
+			if got != tc.want {
+				t.Errorf("detectCgroupPath() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
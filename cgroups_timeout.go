@@ -0,0 +1,104 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"sync"
+	"time"
+)
+
+// cgroupReadTimeout is the bound of a read of the cgroup files.
+const cgroupReadTimeout = 3 * time.Second
+
+// timeoutQueryer abandons the read of the inner queryer if it doesn't
+// finish in the timeout, so a stuck filesystem (e.g. a hung overlay)
+// doesn't block the watchers forever.
+// The concurrent reads of the same kind share the running one and its
+// result, e.g. the health check during the watch. The abandoned read
+// keeps running in its goroutine. Until it returns, the next reads of
+// the same kind fail immediately instead of piling up the stuck
+// goroutines.
+type timeoutQueryer struct {
+	queryer
+
+	timeout time.Duration
+
+	// cpuCall and memCall are the running reads. They're nil while no
+	//  read of the kind is running.
+	mu      sync.Mutex
+	cpuCall *readCall
+	memCall *readCall
+}
+
+// readCall is the read of the inner queryer shared by the concurrent
+// callers. The result is set before the doneC is closed.
+type readCall struct {
+	startedAt time.Time
+	doneC     chan struct{}
+
+	cpuUsage float64
+	memStat  *memStat
+	err      error
+}
+
+func newTimeoutQueryer(inner queryer, timeout time.Duration) *timeoutQueryer {
+	return &timeoutQueryer{
+		queryer: inner,
+		timeout: timeout,
+	}
+}
+
+func (q *timeoutQueryer) cpuUsage() (float64, error) {
+	c, err := q.do(&q.cpuCall, func(c *readCall) {
+		c.cpuUsage, c.err = q.queryer.cpuUsage()
+	})
+	if err != nil {
+		return 0, err
+	}
+	return c.cpuUsage, c.err
+}
+
+func (q *timeoutQueryer) memUsage() (*memStat, error) {
+	c, err := q.do(&q.memCall, func(c *readCall) {
+		c.memStat, c.err = q.queryer.memUsage()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.memStat, c.err
+}
+
+// do joins the running read of the call, or starts the read in
+// a goroutine, and waits for it up to the timeout since it started.
+// It returns ErrCgroupReadTimeout if the read is abandoned or
+// the running read already exceeded the timeout.
+func (q *timeoutQueryer) do(call **readCall, read func(*readCall)) (*readCall, error) {
+	q.mu.Lock()
+	c := *call
+	if c == nil {
+		c = &readCall{startedAt: time.Now(), doneC: make(chan struct{})}
+		*call = c
+		go func() {
+			read(c)
+			q.mu.Lock()
+			*call = nil
+			q.mu.Unlock()
+			close(c.doneC)
+		}()
+	}
+	q.mu.Unlock()
+
+	remaining := q.timeout - time.Since(c.startedAt)
+	if remaining <= 0 {
+		return nil, ErrCgroupReadTimeout
+	}
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-c.doneC:
+		return c, nil
+	case <-timer.C:
+		return nil, ErrCgroupReadTimeout
+	}
+}
@@ -0,0 +1,201 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/looko-corp/autopprof/report"
+)
+
+func TestTimeoutQueryer_cpuUsage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var (
+		blockC = make(chan struct{})
+		doneC  = make(chan struct{})
+	)
+	mockQueryer := NewMockqueryer(ctrl)
+	gomock.InOrder(
+		mockQueryer.EXPECT().
+			cpuUsage().
+			DoAndReturn(
+				func() (float64, error) {
+					defer close(doneC)
+					<-blockC // Simulate the hung filesystem.
+					return 0.5, nil
+				},
+			),
+		mockQueryer.EXPECT().
+			cpuUsage().
+			Return(0.6, nil),
+	)
+
+	q := newTimeoutQueryer(mockQueryer, 100*time.Millisecond)
+
+	start := time.Now()
+	if _, err := q.cpuUsage(); !errors.Is(err, ErrCgroupReadTimeout) {
+		t.Errorf("cpuUsage() = %v, want %v", err, ErrCgroupReadTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cpuUsage() took %s, want to be abandoned after the timeout", elapsed)
+	}
+	// The hung read is still running, so the next one fails immediately.
+	if _, err := q.cpuUsage(); !errors.Is(err, ErrCgroupReadTimeout) {
+		t.Errorf("cpuUsage() = %v, want %v", err, ErrCgroupReadTimeout)
+	}
+
+	close(blockC)
+	<-doneC
+	// Wait for the hung read to finish.
+	time.Sleep(10 * time.Millisecond)
+	usage, err := q.cpuUsage()
+	if err != nil {
+		t.Errorf("cpuUsage() = %v, want nil", err)
+	}
+	if usage != 0.6 {
+		t.Errorf("cpuUsage() = %v, want %v", usage, 0.6)
+	}
+}
+
+func TestTimeoutQueryer_shared(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	// The slow but healthy read is shared by the concurrent readers.
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		memUsage().
+		DoAndReturn(
+			func() (*memStat, error) {
+				time.Sleep(50 * time.Millisecond)
+				return &memStat{usage: 6, limit: 10}, nil
+			},
+		)
+
+	q := newTimeoutQueryer(mockQueryer, time.Second)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stat, err := q.memUsage()
+			if err != nil {
+				t.Errorf("memUsage() = %v, want nil", err)
+				return
+			}
+			if stat.ratio() != 0.6 {
+				t.Errorf("memUsage().ratio() = %v, want %v", stat.ratio(), 0.6)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTimeoutQueryer_memUsage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		memUsage().
+		Return(&memStat{usage: 6, limit: 10}, nil)
+
+	q := newTimeoutQueryer(mockQueryer, 100*time.Millisecond)
+	stat, err := q.memUsage()
+	if err != nil {
+		t.Errorf("memUsage() = %v, want nil", err)
+	}
+	if stat.ratio() != 0.6 {
+		t.Errorf("memUsage().ratio() = %v, want %v", stat.ratio(), 0.6)
+	}
+}
+
+func TestAutoPprof_watchCPUUsage_readTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var calls int32
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		cpuUsage().
+		AnyTimes().
+		DoAndReturn(
+			func() (float64, error) {
+				atomic.AddInt32(&calls, 1)
+				return 0, ErrCgroupReadTimeout
+			},
+		)
+
+	ap := &autoPprof{
+		watchInterval: 100 * time.Millisecond,
+		cpuThreshold:  0.5, // 50%.
+		queryer:       mockQueryer,
+		stopC:         make(chan struct{}),
+	}
+	go ap.watchCPUUsage()
+	defer ap.stop()
+
+	// The watcher must keep reading after the timed out read.
+	time.Sleep(350 * time.Millisecond)
+	if n := atomic.LoadInt32(&calls); n < 2 {
+		t.Errorf("cpu usage is read %d times, want the watcher alive", n)
+	}
+}
+
+func TestAutoPprof_watchCPUUsage_reportBothReadTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var calls int32
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		cpuUsage().
+		AnyTimes().
+		DoAndReturn(
+			func() (float64, error) {
+				atomic.AddInt32(&calls, 1)
+				return 0.9, nil
+			},
+		)
+	mockQueryer.EXPECT().
+		memUsage().
+		AnyTimes().
+		Return(nil, ErrCgroupReadTimeout)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileCPU().
+		AnyTimes().
+		Return([]byte("prof"), nil)
+
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(nil)
+
+	ap := &autoPprof{
+		watchInterval:               100 * time.Millisecond,
+		cpuThreshold:                0.5, // 50%.
+		memThreshold:                0.5, // 50%.
+		minConsecutiveOverThreshold: 1,
+		reportBoth:                  true,
+		queryer:                     mockQueryer,
+		profiler:                    mockProfiler,
+		reporter:                    mockReporter,
+		stopC:                       make(chan struct{}),
+	}
+	go ap.watchCPUUsage()
+	defer ap.stop()
+
+	// The timed out read of the memory usage skips the heap profile
+	//  only, and the watcher keeps reading.
+	time.Sleep(350 * time.Millisecond)
+	if n := atomic.LoadInt32(&calls); n < 2 {
+		t.Errorf("cpu usage is read %d times, want the watcher alive", n)
+	}
+}
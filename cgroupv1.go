@@ -8,6 +8,8 @@ import (
 	"os"
 	"path"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/containerd/cgroups"
@@ -20,14 +22,45 @@ const (
 	cgroupV1CPUQuotaFile  = "cpu.cfs_quota_us"
 	cgroupV1CPUPeriodFile = "cpu.cfs_period_us"
 
+	cgroupV1CPUAcctSubsystem = "cpuacct"
+	cgroupV1CPUAcctUsageFile = "cpuacct.usage"
+
 	cgroupV1UsageUnit = time.Nanosecond
 )
 
+// cgroupV1CPUSubsystems are the directories of the cpu subsystem under
+// the mount point in the order of the detection. Some hosts mount it
+// only at the combined cpu,cpuacct without the cpu symlink.
+var cgroupV1CPUSubsystems = []string{
+	cgroupV1CPUSubsystem,
+	"cpu,cpuacct",
+}
+
 type cgroupV1 struct {
+	// staticPath is the cgroup path used for all subsystems.
+	// If it's empty, the path of each subsystem is detected.
 	staticPath   string
 	mountPoint   string
 	cpuSubsystem string
 
+	// cpuacctSubsystem is the directory of the cpuacct subsystem
+	//  under the mountPoint.
+	cpuacctSubsystem string
+	// cpuacctPath is the cgroup path of the cpuacct subsystem.
+	// If it's empty, the path is same as the other subsystems.
+	cpuacctPath string
+	// splitCPUHierarchy reports whether the cpu and the cpuacct
+	//  subsystems are mounted separately. If so, the cpu usage is read
+	//  from the cpuacct.usage directly.
+	splitCPUHierarchy bool
+	// gVisor reports whether the cgroup files are virtualized by
+	//  the gVisor sentry. If so, the usages are read from the files
+	//  it provides directly. (See isGVisor)
+	gVisor bool
+
+	// quotaMu guards the cpuQuota, which is re-read by the cpu watcher
+	//  while the others read it.
+	quotaMu  sync.RWMutex
 	cpuQuota float64
 
 	q cpuUsageSnapshotQueuer
@@ -37,12 +70,69 @@ func newCgroupsV1() *cgroupV1 {
 	q := newCPUUsageSnapshotQueue(
 		cpuUsageSnapshotQueueSize,
 	)
-	return &cgroupV1{
-		staticPath:   "/",
-		mountPoint:   cgroupV1MountPoint,
-		cpuSubsystem: cgroupV1CPUSubsystem,
-		q:            q,
+	c := &cgroupV1{
+		staticPath:       "",
+		mountPoint:       cgroupV1MountPoint,
+		cpuacctSubsystem: cgroupV1CPUAcctSubsystem,
+		q:                q,
+	}
+	c.setCPUSubsystem(detectCPUSubsystem(cgroupV1MountPoint))
+	return c
+}
+
+// detectCPUSubsystem returns the first of the cgroupV1CPUSubsystems
+// mounted under the mountPoint. It's the cpu if none of them is.
+func detectCPUSubsystem(mountPoint string) string {
+	for _, subsystem := range cgroupV1CPUSubsystems {
+		fi, err := os.Stat(path.Join(mountPoint, subsystem))
+		if err == nil && fi.IsDir() {
+			return subsystem
+		}
+	}
+	return cgroupV1CPUSubsystem
+}
+
+// setCPUSubsystem sets the directory of the cpu subsystem under
+// the mount point. (See Option.CPUSubsystemPath)
+func (c *cgroupV1) setCPUSubsystem(subsystem string) {
+	c.cpuSubsystem = subsystem
+	c.splitCPUHierarchy = isSplitCPUHierarchy(
+		c.mountPoint, c.cpuSubsystem, c.cpuacctSubsystem,
+	)
+}
+
+// isSplitCPUHierarchy reports whether the cpu and the cpuacct
+// subsystems are mounted separately under the mountPoint.
+// Usually, they're mounted together at the cpu,cpuacct and the cpu and
+// the cpuacct are the symlinks to it.
+func isSplitCPUHierarchy(mountPoint, cpuSubsystem, cpuacctSubsystem string) bool {
+	cpu, err := os.Stat(path.Join(mountPoint, cpuSubsystem))
+	if err != nil {
+		return false
 	}
+	cpuacct, err := os.Stat(path.Join(mountPoint, cpuacctSubsystem))
+	if err != nil {
+		return false
+	}
+	return !os.SameFile(cpu, cpuacct)
+}
+
+func (c *cgroupV1) overrideCPUQuota(quota float64) {
+	c.storeCPUQuota(quota)
+}
+
+func (c *cgroupV1) storeCPUQuota(quota float64) {
+	c.quotaMu.Lock()
+	defer c.quotaMu.Unlock()
+
+	c.cpuQuota = quota
+}
+
+func (c *cgroupV1) loadCPUQuota() float64 {
+	c.quotaMu.RLock()
+	defer c.quotaMu.RUnlock()
+
+	return c.cpuQuota
 }
 
 func (c *cgroupV1) setCPUQuota() error {
@@ -54,11 +144,22 @@ func (c *cgroupV1) setCPUQuota() error {
 	if err != nil {
 		return err
 	}
-	// fmt.Println("@@ autopprof @@ quota = ", quota, ", period = ", period)
-	c.cpuQuota = float64(quota) / float64(period)
+	// The quota is -1 without the limit.
+	if quota <= 0 || period <= 0 {
+		return ErrV1CPUQuotaUndefined
+	}
+	c.storeCPUQuota(float64(quota) / float64(period))
 	return nil
 }
 
+func (c *cgroupV1) setCPUSnapshotSize(size int) {
+	c.q = newCPUUsageSnapshotQueue(size)
+}
+
+func (c *cgroupV1) cpuSnapshots() []CPUSnapshot {
+	return c.q.export(cgroupV1UsageUnit)
+}
+
 func (c *cgroupV1) snapshotCPUUsage(usage uint64) {
 	c.q.enqueue(&cpuUsageSnapshot{
 		usage:     usage,
@@ -66,11 +167,20 @@ func (c *cgroupV1) snapshotCPUUsage(usage uint64) {
 	})
 }
 
+// path returns the cgroup path of the subsystem.
+func (c *cgroupV1) path(subsystem cgroups.Name) (string, error) {
+	if c.staticPath != "" {
+		return c.staticPath, nil
+	}
+	return detectCgroupPath(
+		procSelfCgroupFile,
+		path.Join(c.mountPoint, string(subsystem)),
+		string(subsystem),
+	), nil
+}
+
 func (c *cgroupV1) stat() (*v1.Metrics, error) {
-	var (
-		path    = cgroups.StaticPath(c.staticPath)
-		cg, err = cgroups.Load(cgroups.V1, path)
-	)
+	cg, err := cgroups.Load(cgroups.V1, c.path)
 	if err != nil {
 		return nil, err
 	}
@@ -81,54 +191,184 @@ func (c *cgroupV1) stat() (*v1.Metrics, error) {
 	return stat, nil
 }
 
-func (c *cgroupV1) cpuUsage() (float64, error) {
+// cpuCgroupPath returns the cgroup path of the cpu subsystem. The path
+// is detected by the cpu controller, since the directory of
+// the subsystem may be named after the multiple controllers.
+// (e.g. cpu,cpuacct)
+func (c *cgroupV1) cpuCgroupPath() string {
+	if c.staticPath != "" {
+		return c.staticPath
+	}
+	return detectCgroupPath(
+		procSelfCgroupFile,
+		path.Join(c.mountPoint, c.cpuSubsystem),
+		string(cgroups.Cpu),
+	)
+}
+
+// cpuacctCgroupPath returns the cgroup path of the cpuacct subsystem.
+func (c *cgroupV1) cpuacctCgroupPath() string {
+	if c.cpuacctPath != "" {
+		return c.cpuacctPath
+	}
+	p, _ := c.path(cgroups.Name(c.cpuacctSubsystem))
+	return p
+}
+
+// readCPUAcctUsage reads the total cpu usage in nanoseconds from
+// the cpuacct.usage of the cpuacct subsystem.
+func (c *cgroupV1) readCPUAcctUsage() (uint64, error) {
+	b, err := os.ReadFile(path.Join(
+		c.mountPoint, c.cpuacctSubsystem, c.cpuacctCgroupPath(),
+		cgroupV1CPUAcctUsageFile,
+	))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// totalCPUUsage returns the total cpu usage in nanoseconds.
+func (c *cgroupV1) totalCPUUsage() (uint64, error) {
+	if c.splitCPUHierarchy || c.cpuacctPath != "" || c.gVisor {
+		// The stat of the cgroups.Load may not reflect the cpuacct
+		//  subsystem on the split hierarchy, and may fail on
+		//  the files absent in the gVisor.
+		return c.readCPUAcctUsage()
+	}
 	stat, err := c.stat()
 	if err != nil {
 		return 0, err
 	}
+	return stat.CPU.Usage.Total, nil
+}
+
+func (c *cgroupV1) cpuUsage() (float64, error) {
+	usage, err := c.totalCPUUsage()
+	if err != nil {
+		return 0, err
+	}
 
-	c.snapshotCPUUsage(stat.CPU.Usage.Total) // In nanoseconds.
+	c.snapshotCPUUsage(usage) // In nanoseconds.
 
 	// Calculate the usage only if there are enough snapshots.
 	if !c.q.isFull() {
-		// fmt.Println("@@ autopprof @@ cpu is full")
 		return 0, nil
 	}
 
 	s1, s2 := c.q.head(), c.q.tail()
-	// fmt.Printf("@@ autopprof @@ s1 = %+v, s2 = %+v \n", s1, s2)
 	delta := time.Duration(s2.usage-s1.usage) * cgroupV1UsageUnit
 	duration := s2.timestamp.Sub(s1.timestamp)
-	// fmt.Printf("@@ autopprof @@ delta = %+v(%+v), duration = %+v(%+v), cpuQuota = %+v \n", delta, float64(delta), duration, float64(duration), c.cpuQuota)
-	return (float64(delta) / float64(duration)) / c.cpuQuota, nil
+	return (float64(delta) / float64(duration)) / c.loadCPUQuota(), nil
 }
 
-func (c *cgroupV1) memUsage() (float64, error) {
+func (c *cgroupV1) memUsage() (*memStat, error) {
+	if c.gVisor {
+		return c.gVisorMemUsage()
+	}
 	stat, err := c.stat()
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	sm := stat.Memory
+	return &memStat{
+		usage:  workingSet(sm.Usage.Usage, sm.InactiveFile),
+		limit:  sm.HierarchicalMemoryLimit,
+		kmem:   kmemUsageV1(sm),
+		fields: memFieldsV1(sm),
+	}, nil
+}
+
+// memFieldsV1 returns the memory fields in the memory.stat and
+// the swap in the memory.memsw.usage_in_bytes. The swap is zero if
+// the swap accounting is disabled.
+func memFieldsV1(sm *v1.MemoryStat) *memFields {
+	f := &memFields{
+		rss:          sm.RSS,
+		activeFile:   sm.ActiveFile,
+		inactiveFile: sm.InactiveFile,
+	}
+	if sm.Swap != nil && sm.Usage != nil {
+		f.swap = swapV1(sm.Swap.Usage, sm.Usage.Usage)
+	}
+	return f
+}
+
+// kmemUsageV1 returns the kernel memory usage in the
+// memory.kmem.usage_in_bytes and memory.kmem.tcp.usage_in_bytes.
+// They're zero if the kmem accounting is disabled.
+func kmemUsageV1(sm *v1.MemoryStat) uint64 {
+	var kmem uint64
+	if sm.Kernel != nil {
+		kmem += sm.Kernel.Usage
+	}
+	if sm.KernelTCP != nil {
+		kmem += sm.KernelTCP.Usage
+	}
+	return kmem
+}
+
+// cpuPressure returns ErrPSIUnavailable, since the pressure stall
+// information is only in the cgroup v2.
+func (c *cgroupV1) cpuPressure() (float64, error) {
+	return 0, ErrPSIUnavailable
+}
+
+func (c *cgroupV1) numaStat() ([]numaNode, error) {
+	if c.gVisor {
+		return nil, ErrNUMAUnavailable
 	}
+	memPath, err := c.path(cgroups.Memory)
+	if err != nil {
+		return nil, err
+	}
+	return readNUMANodes(
+		path.Join(c.mountPoint, string(cgroups.Memory), memPath, cgroupNUMAStatFile),
+		parseNUMAStatV1, sysNodeDir,
+	)
+}
+
+func (c *cgroupV1) status() CgroupStatus {
 	var (
-		sm    = stat.Memory
-		usage = sm.Usage.Usage - sm.InactiveFile
-		limit = sm.HierarchicalMemoryLimit
+		cpuPath    = c.cpuCgroupPath()
+		memPath, _ = c.path(cgroups.Memory)
 	)
-	return float64(usage) / float64(limit), nil
+	s := CgroupStatus{
+		Version: 1,
+		Path:    memPath,
+		CPUQuotaFiles: []string{
+			path.Join(c.mountPoint, c.cpuSubsystem, cpuPath, cgroupV1CPUQuotaFile),
+			path.Join(c.mountPoint, c.cpuSubsystem, cpuPath, cgroupV1CPUPeriodFile),
+		},
+		CPUQuota: c.loadCPUQuota(),
+		CPUUsageSource: path.Join(
+			c.mountPoint, c.cpuacctSubsystem, c.cpuacctCgroupPath(),
+			cgroupV1CPUAcctUsageFile,
+		),
+		MemLimitSource: path.Join(
+			c.mountPoint, string(cgroups.Memory), memPath, "memory.stat",
+		) + " (hierarchical_memory_limit)",
+		GVisor: c.gVisor,
+	}
+	if c.gVisor {
+		s.MemLimitSource = path.Join(
+			c.mountPoint, string(cgroups.Memory), memPath, cgroupV1MemLimitFile,
+		)
+	}
+	return s
 }
 
 func (c *cgroupV1) parseCPU(filename string) (int, error) {
-	fullpath := path.Join(c.mountPoint, c.cpuSubsystem, filename)
-	//("@@ autopprof @@ fullpath = ", fullpath)
+	fullpath := path.Join(c.mountPoint, c.cpuSubsystem, c.cpuCgroupPath(), filename)
 
 	f, err := os.Open(fullpath)
 	if err != nil {
 		return 0, err
 	}
+	defer f.Close()
 	scanner := bufio.NewScanner(f)
 	if scanner.Scan() {
 		scanned := scanner.Text()
-		//fmt.Println("@@ autopprof @@ scanned = ", scanned)
-
 		val, err := strconv.Atoi(scanned)
 		if err != nil {
 			return 0, err
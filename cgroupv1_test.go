@@ -4,10 +4,13 @@
 package autopprof
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/containerd/cgroups"
+	v1 "github.com/containerd/cgroups/stats/v1"
 )
 
 func TestCgroupV1_cpuUsage(t *testing.T) {
@@ -53,12 +56,42 @@ func TestCgroupV1_memUsage(t *testing.T) {
 	if mode != cgroups.Legacy {
 		t.Skip("cgroup v1 is not available")
 	}
-	usage, err := newCgroupsV1().memUsage()
+	stat, err := newCgroupsV1().memUsage()
 	if err != nil {
 		t.Errorf("memUsage() = %v, want nil", err)
+		t.FailNow()
 	}
-	if usage < 0 || usage > 1 {
-		t.Errorf("memUsage() = %f, want between 0 and 1", usage)
+	if usage := stat.ratio(); usage < 0 || usage > 1 {
+		t.Errorf("memUsage().ratio() = %f, want between 0 and 1", usage)
+	}
+}
+
+func TestKmemUsageV1(t *testing.T) {
+	testCases := []struct {
+		name string
+		sm   *v1.MemoryStat
+		want uint64
+	}{
+		{
+			name: "kmem and kmem.tcp",
+			sm: &v1.MemoryStat{
+				Kernel:    &v1.MemoryEntry{Usage: 100},
+				KernelTCP: &v1.MemoryEntry{Usage: 20},
+			},
+			want: 120,
+		},
+		{
+			name: "kmem accounting is disabled",
+			sm:   &v1.MemoryStat{},
+			want: 0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := kmemUsageV1(tc.sm); got != tc.want {
+				t.Errorf("kmemUsageV1() = %d, want %d", got, tc.want)
+			}
+		})
 	}
 }
 
@@ -76,3 +109,179 @@ func TestCgroupV1_setCPUQuota(t *testing.T) {
 		t.Errorf("cpuQuota = %f, want 1.5", cgv1.cpuQuota)
 	}
 }
+
+func TestIsSplitCPUHierarchy(t *testing.T) {
+	// The joint hierarchy: cpu and cpuacct are the symlinks to the cpu,cpuacct.
+	joint := t.TempDir()
+	if err := os.Mkdir(filepath.Join(joint, "cpu,cpuacct"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"cpu", "cpuacct"} {
+		if err := os.Symlink("cpu,cpuacct", filepath.Join(joint, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The split hierarchy: cpu and cpuacct are mounted separately.
+	split := t.TempDir()
+	for _, name := range []string{"cpu", "cpuacct"} {
+		if err := os.Mkdir(filepath.Join(split, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name       string
+		mountPoint string
+		want       bool
+	}{
+		{
+			name:       "joint hierarchy",
+			mountPoint: joint,
+			want:       false,
+		},
+		{
+			name:       "split hierarchy",
+			mountPoint: split,
+			want:       true,
+		},
+		{
+			name:       "no cpu subsystems",
+			mountPoint: t.TempDir(),
+			want:       false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := isSplitCPUHierarchy(
+				tc.mountPoint, cgroupV1CPUSubsystem, cgroupV1CPUAcctSubsystem,
+			)
+			if got != tc.want {
+				t.Errorf("isSplitCPUHierarchy() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDetectCPUSubsystem(t *testing.T) {
+	newMountPoint := func(t *testing.T, subsystems ...string) string {
+		mountPoint := t.TempDir()
+		for _, subsystem := range subsystems {
+			if err := os.Mkdir(filepath.Join(mountPoint, subsystem), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return mountPoint
+	}
+
+	testCases := []struct {
+		name       string
+		subsystems []string
+		want       string
+	}{
+		{
+			name:       "cpu",
+			subsystems: []string{"cpu", "cpu,cpuacct"},
+			want:       "cpu",
+		},
+		{
+			name:       "combined cpu,cpuacct only",
+			subsystems: []string{"cpu,cpuacct"},
+			want:       "cpu,cpuacct",
+		},
+		{
+			name: "no cpu subsystems",
+			want: "cpu",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mountPoint := newMountPoint(t, tc.subsystems...)
+			if got := detectCPUSubsystem(mountPoint); got != tc.want {
+				t.Errorf("detectCPUSubsystem() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCgroupV1_setCPUQuota_combinedSubsystem(t *testing.T) {
+	// The quota files are only under the combined cpu,cpuacct.
+	mountPoint := t.TempDir()
+	dir := filepath.Join(mountPoint, "cpu,cpuacct", "app")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		cgroupV1CPUQuotaFile:  "150000\n",
+		cgroupV1CPUPeriodFile: "100000\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cgv1 := &cgroupV1{
+		staticPath:       "/app",
+		mountPoint:       mountPoint,
+		cpuacctSubsystem: cgroupV1CPUAcctSubsystem,
+	}
+	cgv1.setCPUSubsystem(detectCPUSubsystem(mountPoint))
+	if err := cgv1.setCPUQuota(); err != nil {
+		t.Fatalf("setCPUQuota() = %v, want nil", err)
+	}
+	if cgv1.cpuQuota != 1.5 {
+		t.Errorf("cpuQuota = %f, want 1.5", cgv1.cpuQuota)
+	}
+}
+
+func TestCgroupV1_cpuUsage_splitHierarchy(t *testing.T) {
+	mountPoint := t.TempDir()
+	cpuacctDir := filepath.Join(mountPoint, "cpuacct", "app")
+	if err := os.MkdirAll(cpuacctDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(mountPoint, "cpu", "app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeUsage := func(usage string) {
+		file := filepath.Join(cpuacctDir, cgroupV1CPUAcctUsageFile)
+		if err := os.WriteFile(file, []byte(usage), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cgv1 := &cgroupV1{
+		staticPath:       "/app",
+		mountPoint:       mountPoint,
+		cpuSubsystem:     cgroupV1CPUSubsystem,
+		cpuacctSubsystem: cgroupV1CPUAcctSubsystem,
+		splitCPUHierarchy: isSplitCPUHierarchy(
+			mountPoint, cgroupV1CPUSubsystem, cgroupV1CPUAcctSubsystem,
+		),
+		cpuQuota: 2,
+		q:        newCPUUsageSnapshotQueue(2),
+	}
+	if !cgv1.splitCPUHierarchy {
+		t.Fatalf("splitCPUHierarchy = false, want true")
+	}
+
+	writeUsage("1000000000\n")
+	usage, err := cgv1.cpuUsage()
+	if err != nil {
+		t.Errorf("cpuUsage() = %v, want nil", err)
+	}
+	if usage != 0 { // The cpu usage is 0 until the queue is full.
+		t.Errorf("cpuUsage() = %f, want 0", usage)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// 100ms of the cpu time is used during about 100ms with 2 cpus.
+	writeUsage("1100000000\n")
+	usage, err = cgv1.cpuUsage()
+	if err != nil {
+		t.Errorf("cpuUsage() = %v, want nil", err)
+	}
+	if usage <= 0 || usage > 0.5 {
+		t.Errorf("cpuUsage() = %f, want between 0 and 0.5", usage)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	cgroupsv2 "github.com/containerd/cgroups/v2"
@@ -32,6 +33,13 @@ type cgroupV2 struct {
 	mountPoint string
 	cpuMaxFile string
 
+	// gVisor reports whether the cgroup files are virtualized by
+	//  the gVisor sentry. It's only reported in the status.
+	gVisor bool
+
+	// quotaMu guards the cpuQuota, which is re-read by the cpu watcher
+	//  while the others read it.
+	quotaMu  sync.RWMutex
 	cpuQuota float64
 
 	q cpuUsageSnapshotQueuer
@@ -42,16 +50,34 @@ func newCgroupsV2() *cgroupV2 {
 		cpuUsageSnapshotQueueSize,
 	)
 	return &cgroupV2{
-		groupPath:  "",
+		groupPath:  detectCgroupPath(procSelfCgroupFile, cgroupV2MountPoint, ""),
 		mountPoint: cgroupV2MountPoint,
 		cpuMaxFile: cgroupV2CPUMaxFile,
 		q:          q,
 	}
 }
 
+func (c *cgroupV2) overrideCPUQuota(quota float64) {
+	c.storeCPUQuota(quota)
+}
+
+func (c *cgroupV2) storeCPUQuota(quota float64) {
+	c.quotaMu.Lock()
+	defer c.quotaMu.Unlock()
+
+	c.cpuQuota = quota
+}
+
+func (c *cgroupV2) loadCPUQuota() float64 {
+	c.quotaMu.RLock()
+	defer c.quotaMu.RUnlock()
+
+	return c.cpuQuota
+}
+
 func (c *cgroupV2) setCPUQuota() error {
 	f, err := os.Open(
-		path.Join(c.mountPoint, c.cpuMaxFile),
+		path.Join(c.mountPoint, c.groupPath, c.cpuMaxFile),
 	)
 	if os.IsNotExist(err) {
 		return ErrV2CPUQuotaUndefined
@@ -59,6 +85,7 @@ func (c *cgroupV2) setCPUQuota() error {
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 
 	scanner := bufio.NewScanner(f)
 	if scanner.Scan() {
@@ -84,7 +111,12 @@ func (c *cgroupV2) setCPUQuota() error {
 				return err
 			}
 		}
-		c.cpuQuota = float64(max) / float64(period)
+		if max <= 0 || period <= 0 {
+			return fmt.Errorf(
+				"autopprof: invalid cpu.max value %q", scanner.Text(),
+			)
+		}
+		c.storeCPUQuota(float64(max) / float64(period))
 		return nil
 	}
 	if err := scanner.Err(); err != nil {
@@ -93,6 +125,14 @@ func (c *cgroupV2) setCPUQuota() error {
 	return ErrV2CPUMaxEmpty
 }
 
+func (c *cgroupV2) setCPUSnapshotSize(size int) {
+	c.q = newCPUUsageSnapshotQueue(size)
+}
+
+func (c *cgroupV2) cpuSnapshots() []CPUSnapshot {
+	return c.q.export(cgroupV2UsageUnit)
+}
+
 func (c *cgroupV2) snapshotCPUUsage(usage uint64) {
 	c.q.enqueue(&cpuUsageSnapshot{
 		usage:     usage,
@@ -101,11 +141,7 @@ func (c *cgroupV2) snapshotCPUUsage(usage uint64) {
 }
 
 func (c *cgroupV2) stat() (*stats.Metrics, error) {
-	path, err := cgroupsv2.NestedGroupPath(c.groupPath)
-	if err != nil {
-		return nil, err
-	}
-	m, err := cgroupsv2.LoadManager(c.mountPoint, path)
+	m, err := cgroupsv2.LoadManager(c.mountPoint, c.groupPath)
 	if err != nil {
 		return nil, err
 	}
@@ -131,18 +167,53 @@ func (c *cgroupV2) cpuUsage() (float64, error) {
 	s1, s2 := c.q.head(), c.q.tail()
 	delta := time.Duration(s2.usage-s1.usage) * cgroupV2UsageUnit
 	duration := s2.timestamp.Sub(s1.timestamp)
-	return (float64(delta) / float64(duration)) / c.cpuQuota, nil
+	return (float64(delta) / float64(duration)) / c.loadCPUQuota(), nil
 }
 
-func (c *cgroupV2) memUsage() (float64, error) {
+func (c *cgroupV2) memUsage() (*memStat, error) {
 	stat, err := c.stat()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	var (
-		sm    = stat.Memory
-		usage = sm.Usage - sm.InactiveFile
-		limit = sm.UsageLimit
+	sm := stat.Memory
+	return &memStat{
+		usage: workingSet(sm.Usage, sm.InactiveFile),
+		limit: sm.UsageLimit,
+		// The memory.current charges the kernel memory in the v2.
+		kmem:        sm.KernelStack + sm.Slab + sm.Sock,
+		kmemInUsage: true,
+		fields: &memFields{
+			rss:          sm.Anon,
+			activeFile:   sm.ActiveFile,
+			inactiveFile: sm.InactiveFile,
+			swap:         sm.SwapUsage,
+		},
+	}, nil
+}
+
+func (c *cgroupV2) cpuPressure() (float64, error) {
+	return readPSISomeAvg10(
+		path.Join(c.mountPoint, c.groupPath, cgroupV2CPUPressureFile),
+	)
+}
+
+func (c *cgroupV2) numaStat() ([]numaNode, error) {
+	return readNUMANodes(
+		path.Join(c.mountPoint, c.groupPath, cgroupNUMAStatFile),
+		parseNUMAStatV2, sysNodeDir,
 	)
-	return float64(usage) / float64(limit), nil
+}
+
+func (c *cgroupV2) status() CgroupStatus {
+	return CgroupStatus{
+		Version: 2,
+		Path:    c.groupPath,
+		CPUQuotaFiles: []string{
+			path.Join(c.mountPoint, c.groupPath, c.cpuMaxFile),
+		},
+		CPUQuota:       c.loadCPUQuota(),
+		CPUUsageSource: path.Join(c.mountPoint, c.groupPath, "cpu.stat") + " (usage_usec)",
+		MemLimitSource: path.Join(c.mountPoint, c.groupPath, "memory.max"),
+		GVisor:         c.gVisor,
+	}
 }
@@ -4,6 +4,10 @@
 package autopprof
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -54,12 +58,13 @@ func TestCgroupV2_memUsage(t *testing.T) {
 		t.Skip("cgroup v2 is not available")
 	}
 	cgv2 := newCgroupsV2()
-	usage, err := cgv2.memUsage()
+	stat, err := cgv2.memUsage()
 	if err != nil {
 		t.Errorf("memUsage() = %v, want nil", err)
+		t.FailNow()
 	}
-	if usage < 0 || usage > 1 {
-		t.Errorf("memUsage() = %f, want between 0 and 1", usage)
+	if usage := stat.ratio(); usage < 0 || usage > 1 {
+		t.Errorf("memUsage().ratio() = %f, want between 0 and 1", usage)
 	}
 }
 
@@ -77,3 +82,95 @@ func TestCgroupV2_setCPUQuota(t *testing.T) {
 		t.Errorf("cpuQuota = %f, want 1.5", cgv2.cpuQuota)
 	}
 }
+
+func TestCgroupV2_setCPUQuota_cpuMax(t *testing.T) {
+	testCases := []struct {
+		name      string
+		cpuMax    string // Not written if empty.
+		want      float64
+		wantErr   error
+		wantFails bool
+	}{
+		{
+			name:   "quota and period",
+			cpuMax: "150000 100000\n",
+			want:   1.5,
+		},
+		{
+			name:   "quota only",
+			cpuMax: "50000\n",
+			want:   0.5,
+		},
+		{
+			name:    "unlimited",
+			cpuMax:  "max 100000\n",
+			wantErr: ErrV2CPUQuotaUndefined,
+		},
+		{
+			name:    "no cpu.max",
+			wantErr: ErrV2CPUQuotaUndefined,
+		},
+		{
+			name:      "zero period",
+			cpuMax:    "150000 0\n",
+			wantFails: true,
+		},
+		{
+			name:      "invalid format",
+			cpuMax:    "150000 100000 1\n",
+			wantFails: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mountPoint := t.TempDir()
+			if tc.cpuMax != "" {
+				if err := os.WriteFile(
+					filepath.Join(mountPoint, cgroupV2CPUMaxFile), []byte(tc.cpuMax), 0o644,
+				); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cgv2 := &cgroupV2{
+				groupPath:  "/",
+				mountPoint: mountPoint,
+				cpuMaxFile: cgroupV2CPUMaxFile,
+			}
+			err := cgv2.setCPUQuota()
+			if tc.wantFails {
+				if err == nil {
+					t.Errorf("setCPUQuota() = nil, want an error")
+				}
+				return
+			}
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("setCPUQuota() = %v, want %v", err, tc.wantErr)
+			}
+			if cgv2.cpuQuota != tc.want {
+				t.Errorf("cpuQuota = %f, want %f", cgv2.cpuQuota, tc.want)
+			}
+		})
+	}
+}
+
+func TestCgroupV2_status(t *testing.T) {
+	cgv2 := &cgroupV2{
+		groupPath:  "/kubepods/pod1/app",
+		mountPoint: cgroupV2MountPoint,
+		cpuMaxFile: cgroupV2CPUMaxFile,
+		cpuQuota:   1.5,
+	}
+	want := CgroupStatus{
+		Version: 2,
+		Path:    "/kubepods/pod1/app",
+		CPUQuotaFiles: []string{
+			"/sys/fs/cgroup/kubepods/pod1/app/cpu.max",
+		},
+		CPUQuota:       1.5,
+		CPUUsageSource: "/sys/fs/cgroup/kubepods/pod1/app/cpu.stat (usage_usec)",
+		MemLimitSource: "/sys/fs/cgroup/kubepods/pod1/app/memory.max",
+	}
+	if got := cgv2.status(); !reflect.DeepEqual(got, want) {
+		t.Errorf("status() = %+v, want %+v", got, want)
+	}
+}
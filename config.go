@@ -0,0 +1,39 @@
+package autopprof
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadOption loads the Option from the config in the YAML or the JSON,
+// which is a subset of the YAML. The keys are the snake_case names of
+// the fields, (e.g. cpu_threshold) the durations are in the format of
+// the time.ParseDuration, (e.g. "30s") and the MemLimitMode and
+// the CPUUsageBasis are in their names. (e.g. "single_core")
+//
+// The unknown keys are rejected, so the typos don't silently leave
+// the defaults. The fields which can't be serialized, i.e. the Reporter
+// and the OnEvent, must be set after loading:
+//
+//	opt, err := autopprof.LoadOption(f)
+//	if err != nil {
+//		return err
+//	}
+//	opt.Reporter = reporter
+//	err = autopprof.Start(opt)
+func LoadOption(r io.Reader) (Option, error) {
+	var opt Option
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	// The empty config is the default option.
+	if err := dec.Decode(&opt); err != nil && !errors.Is(err, io.EOF) {
+		return Option{}, fmt.Errorf("autopprof: failed to load the option: %w", err)
+	}
+	if err := opt.validateConfig(); err != nil {
+		return Option{}, err
+	}
+	return opt, nil
+}
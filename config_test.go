@@ -0,0 +1,105 @@
+package autopprof
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadOption(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  string
+		want    Option
+		wantErr error
+	}{
+		{
+			name:   "empty",
+			config: "",
+			want:   Option{},
+		},
+		{
+			name: "yaml",
+			config: `
+cpu_threshold: 1.5
+cpu_usage_basis: single_core
+mem_threshold: 0.8
+mem_limit_mode: both
+mem_usage_components: rss+cache
+disable_mem_prof: false
+sample_interval: 500ms
+reporter_cooldown: 5m
+severity_based_cooldown:
+  - usage: 0.8
+    cooldown: 10m
+  - usage: 0.95
+    cooldown: 1m
+labels:
+  service: api
+`,
+			want: Option{
+				CPUThreshold:       1.5,
+				CPUUsageBasis:      CPUUsageSingleCore,
+				MemThreshold:       0.8,
+				MemLimitMode:       MemLimitBoth,
+				MemUsageComponents: MemUsageRSS | MemUsageCache,
+				SampleInterval:     500 * time.Millisecond,
+				ReporterCooldown:   5 * time.Minute,
+				SeverityBasedCooldown: []SeverityCooldown{
+					{Usage: 0.8, Cooldown: 10 * time.Minute},
+					{Usage: 0.95, Cooldown: time.Minute},
+				},
+				Labels: map[string]string{"service": "api"},
+			},
+		},
+		{
+			name:   "json",
+			config: `{"disable_cpu_prof": true, "mem_threshold": 0.9, "cpu_profiling_budget": "1m"}`,
+			want: Option{
+				DisableCPUProf:     true,
+				MemThreshold:       0.9,
+				CPUProfilingBudget: time.Minute,
+			},
+		},
+		{
+			name:    "unknown key",
+			config:  "cpu_treshold: 0.8",
+			wantErr: errors.New("not found"),
+		},
+		{
+			name:    "reporter",
+			config:  "reporter: slack",
+			wantErr: errors.New("not found"),
+		},
+		{
+			name:    "unknown cpu usage basis",
+			config:  "cpu_usage_basis: cores",
+			wantErr: errors.New("unknown cpu usage basis"),
+		},
+		{
+			name:    "invalid value",
+			config:  "mem_threshold: 1.5",
+			wantErr: ErrInvalidMemThreshold,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := LoadOption(strings.NewReader(tc.config))
+			if tc.wantErr != nil {
+				if err == nil ||
+					(!errors.Is(err, tc.wantErr) && !strings.Contains(err.Error(), tc.wantErr.Error())) {
+					t.Fatalf("LoadOption() error = %v, want %v", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadOption() error = %v, want nil", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("LoadOption() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
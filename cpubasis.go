@@ -0,0 +1,63 @@
+package autopprof
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// CPUUsageBasis is the denominator to compute the cpu usage against.
+type CPUUsageBasis int
+
+const (
+	// CPUUsageQuota computes the cpu usage against the cpu quota of
+	//  the cgroup. 1.0 means the process uses all of its quota, e.g.
+	//  1.5 cores with the 1.5 cores quota.
+	CPUUsageQuota CPUUsageBasis = iota
+	// CPUUsageSingleCore computes the cpu usage against a single core,
+	//  i.e. the number of the cores in use. 1.5 means 1.5 cores
+	//  regardless of the cpu quota, so it can be higher than 1.
+	CPUUsageSingleCore
+	// CPUUsageAllCores computes the cpu usage against all of the cores
+	//  of the host (runtime.NumCPU), regardless of the cpu quota.
+	//  e.g. 1.5 cores on the 4 cores host is 0.375.
+	CPUUsageAllCores
+)
+
+// String returns the name of the basis.
+func (b CPUUsageBasis) String() string {
+	switch b {
+	case CPUUsageQuota:
+		return "quota"
+	case CPUUsageSingleCore:
+		return "single_core"
+	case CPUUsageAllCores:
+		return "all_cores"
+	}
+	return "unknown"
+}
+
+// UnmarshalText parses the name of the basis, so it's loaded from
+// the config by the LoadOption.
+func (b *CPUUsageBasis) UnmarshalText(text []byte) error {
+	for _, basis := range []CPUUsageBasis{
+		CPUUsageQuota, CPUUsageSingleCore, CPUUsageAllCores,
+	} {
+		if basis.String() == string(text) {
+			*b = basis
+			return nil
+		}
+	}
+	return fmt.Errorf("autopprof: unknown cpu usage basis: %q", text)
+}
+
+// normalize converts the usage against the cpu quota to the usage
+// against the basis. The quota is the cpu quota in cores.
+func (b CPUUsageBasis) normalize(usage, quota float64) float64 {
+	switch b {
+	case CPUUsageSingleCore:
+		return usage * quota
+	case CPUUsageAllCores:
+		return usage * quota / float64(runtime.NumCPU())
+	}
+	return usage
+}
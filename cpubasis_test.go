@@ -0,0 +1,38 @@
+package autopprof
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestCPUUsageBasis_normalize(t *testing.T) {
+	// 1.5 cores with the 2 cores quota.
+	const (
+		usage = 0.75
+		quota = 2
+	)
+	testCases := []struct {
+		basis CPUUsageBasis
+		want  float64
+	}{
+		{
+			basis: CPUUsageQuota,
+			want:  0.75,
+		},
+		{
+			basis: CPUUsageSingleCore,
+			want:  1.5,
+		},
+		{
+			basis: CPUUsageAllCores,
+			want:  1.5 / float64(runtime.NumCPU()),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.basis.String(), func(t *testing.T) {
+			if got := tc.basis.normalize(usage, quota); got != tc.want {
+				t.Errorf("normalize() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package autopprof
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/google/pprof/profile"
+)
+
+// cpuDiffTracker keeps the last cpu profile, so the next one tells
+// the functions got hotter since then. A nil cpuDiffTracker tracks
+// nothing.
+type cpuDiffTracker struct {
+	mu sync.Mutex
+	// prev is the last cpu profile, and prevTriggerID is its trigger
+	//  id. prev is nil until the first cpu profile.
+	prev          []byte
+	prevTriggerID string
+}
+
+func newCPUDiffTracker() *cpuDiffTracker {
+	return &cpuDiffTracker{}
+}
+
+// diff returns the cpu profile of the cur minus the last one with
+// the trigger id of the last one, and replaces the last one with
+// the cur of the triggerID. It's nil for the first one, since there's
+// nothing to compare with.
+func (t *cpuDiffTracker) diff(cur []byte, triggerID string) ([]byte, string, error) {
+	if t == nil {
+		return nil, "", nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev, prevTriggerID := t.prev, t.prevTriggerID
+	t.prev, t.prevTriggerID = cur, triggerID
+	if prev == nil {
+		return nil, "", nil
+	}
+	b, err := diffCPUProfile(prev, cur)
+	if err != nil {
+		return nil, "", err
+	}
+	return b, prevTriggerID, nil
+}
+
+// diffCPUProfile returns the profile of the cur minus the base scaled to
+// the duration of the cur, so the profiles of the different durations
+// compare by the cpu time per second. The positive values are
+// the functions got hotter, and the negative ones cooled down.
+// Without the durations, the base is scaled to the total samples of
+// the cur instead, like the -normalize of the go tool pprof.
+func diffCPUProfile(base, cur []byte) ([]byte, error) {
+	bp, err := profile.Parse(bytes.NewReader(base))
+	if err != nil {
+		return nil, err
+	}
+	cp, err := profile.Parse(bytes.NewReader(cur))
+	if err != nil {
+		return nil, err
+	}
+	if bp.DurationNanos > 0 && cp.DurationNanos > 0 {
+		bp.Scale(float64(cp.DurationNanos) / float64(bp.DurationNanos))
+	} else if err := bp.Normalize(cp); err != nil {
+		return nil, err
+	}
+	return diffParsedProfile(bp, cp)
+}
@@ -0,0 +1,154 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/pprof/profile"
+
+	"github.com/looko-corp/autopprof/report"
+)
+
+// cpuProfile returns the cpu profile of the duration with the cpu
+// nanoseconds of the functions.
+func cpuProfile(t *testing.T, duration time.Duration, nanos map[string]int64) []byte {
+	t.Helper()
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		PeriodType:    &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:        10000000,
+		DurationNanos: int64(duration),
+	}
+	var id uint64
+	for name, v := range nanos {
+		id++
+		fn := &profile.Function{ID: id, Name: name}
+		loc := &profile.Location{ID: id, Line: []profile.Line{{Function: fn}}}
+		p.Function = append(p.Function, fn)
+		p.Location = append(p.Location, loc)
+		p.Sample = append(p.Sample, &profile.Sample{
+			Location: []*profile.Location{loc},
+			Value:    []int64{v / p.Period, v},
+		})
+	}
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// cpuNanos returns the cpu nanoseconds of the functions of the profile.
+func cpuNanos(t *testing.T, b []byte) map[string]int64 {
+	t.Helper()
+	p, err := profile.Parse(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("profile.Parse() = %v, want nil", err)
+	}
+	nanos := make(map[string]int64)
+	for _, s := range p.Sample {
+		nanos[s.Location[0].Line[0].Function.Name] += s.Value[1]
+	}
+	return nanos
+}
+
+func TestCPUDiffTracker_diff(t *testing.T) {
+	// A nil cpuDiffTracker tracks nothing.
+	var nilTracker *cpuDiffTracker
+	if got, _, err := nilTracker.diff([]byte("prof"), "t1"); got != nil || err != nil {
+		t.Errorf("diff() of nil = %v, %v, want nil, nil", got, err)
+	}
+
+	tracker := newCPUDiffTracker()
+	first := cpuProfile(t, 10*time.Second, map[string]int64{
+		"main.steady": int64(2 * time.Second),
+		"main.hot":    int64(time.Second),
+		"main.cold":   int64(time.Second),
+	})
+	got, base, err := tracker.diff(first, "t1")
+	if got != nil || base != "" || err != nil {
+		t.Errorf("diff() of the first = %v, %q, %v, want nil", got, base, err)
+	}
+
+	// Half the duration of the first, so the first is halved.
+	second := cpuProfile(t, 5*time.Second, map[string]int64{
+		"main.steady": int64(time.Second),
+		"main.hot":    int64(2 * time.Second),
+	})
+	got, base, err = tracker.diff(second, "t2")
+	if err != nil {
+		t.Fatalf("diff() = %v, want nil", err)
+	}
+	if base != "t1" {
+		t.Errorf("diff() base trigger id = %q, want t1", base)
+	}
+	nanos := cpuNanos(t, got)
+	want := map[string]int64{
+		"main.hot":  int64(1500 * time.Millisecond),
+		"main.cold": -int64(500 * time.Millisecond),
+	}
+	for name, v := range want {
+		if nanos[name] != v {
+			t.Errorf("diff of %s = %d, want %d", name, nanos[name], v)
+		}
+	}
+	if _, ok := nanos["main.steady"]; ok {
+		t.Errorf("diff has the unchanged main.steady")
+	}
+}
+
+func TestAutoPprof_sendCPUProfile_diff(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	first := cpuProfile(t, 10*time.Second, map[string]int64{"main.hot": int64(time.Second)})
+	second := cpuProfile(t, 10*time.Second, map[string]int64{"main.hot": int64(3 * time.Second)})
+
+	var cpuInfos []report.CPUInfo
+	mockReporter := report.NewMockProfileReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ io.Reader, ci report.CPUInfo) error {
+			cpuInfos = append(cpuInfos, ci)
+			return nil
+		}).
+		Times(2)
+	mockReporter.EXPECT().
+		ReportProfile(gomock.Any(), gomock.Any(), report.ProfileKindCPU, gomock.Any()).
+		DoAndReturn(func(_ context.Context, r io.Reader, _ report.ProfileKind, pi report.ProfileInfo) error {
+			if !pi.Delta {
+				t.Errorf("ProfileInfo.Delta = false, want true")
+			}
+			if pi.TriggerID != cpuInfos[1].TriggerID || pi.BaseTriggerID != cpuInfos[0].TriggerID {
+				t.Errorf("ProfileInfo trigger ids = %q, %q, want the ones of the cpu profiles", pi.TriggerID, pi.BaseTriggerID)
+			}
+			b, _ := io.ReadAll(r)
+			if got := cpuNanos(t, b)["main.hot"]; got != int64(2*time.Second) {
+				t.Errorf("diff of main.hot = %d, want %d", got, int64(2*time.Second))
+			}
+			return nil
+		})
+
+	ap := &autoPprof{
+		reporter: mockReporter,
+		cpuDiff:  newCPUDiffTracker(),
+		stopC:    make(chan struct{}),
+	}
+	for _, b := range [][]byte{first, second} {
+		if err := ap.sendCPUProfile(context.Background(), b, report.CPUInfo{}); err != nil {
+			t.Errorf("sendCPUProfile() = %v, want nil", err)
+		}
+	}
+	if cpuInfos[0].TriggerID == "" || cpuInfos[0].TriggerID == cpuInfos[1].TriggerID {
+		t.Errorf("CPUInfo.TriggerIDs = %q, %q, want the distinct ones", cpuInfos[0].TriggerID, cpuInfos[1].TriggerID)
+	}
+}
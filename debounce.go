@@ -0,0 +1,36 @@
+package autopprof
+
+import (
+	"sync"
+	"time"
+)
+
+// debounce allows an event once within the interval.
+// A nil debounce allows all events.
+type debounce struct {
+	mu       sync.Mutex
+	interval time.Duration
+	last     time.Time
+}
+
+func newDebounce(interval time.Duration) *debounce {
+	return &debounce{
+		interval: interval,
+	}
+}
+
+// allow reports whether the event at the now is allowed, and records
+// it if so.
+func (d *debounce) allow(now time.Time) bool {
+	if d == nil {
+		return true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.last.IsZero() && now.Sub(d.last) < d.interval {
+		return false
+	}
+	d.last = now
+	return true
+}
@@ -0,0 +1,52 @@
+package autopprof
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDebounce_allow(t *testing.T) {
+	// A nil debounce allows all events.
+	var nilDebounce *debounce
+	if !nilDebounce.allow(time.Now()) || !nilDebounce.allow(time.Now()) {
+		t.Errorf("allow() of nil = false, want true")
+	}
+
+	var (
+		now = time.Now()
+		d   = newDebounce(time.Minute)
+	)
+	testCases := []struct {
+		name string
+		at   time.Time
+		want bool
+	}{
+		{
+			name: "first",
+			at:   now,
+			want: true,
+		},
+		{
+			name: "within the interval",
+			at:   now.Add(30 * time.Second),
+			want: false,
+		},
+		{
+			name: "after the interval",
+			at:   now.Add(time.Minute),
+			want: true,
+		},
+		{
+			name: "within the interval of the last allowed",
+			at:   now.Add(90 * time.Second),
+			want: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := d.allow(tc.at); got != tc.want {
+				t.Errorf("allow() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
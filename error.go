@@ -9,14 +9,202 @@ var (
 	)
 	ErrCgroupsUnavailable  = fmt.Errorf("autopprof: cgroups is unavailable")
 	ErrInvalidCPUThreshold = fmt.Errorf(
-		"autopprof: cpu threshold value must be between 0 and 1",
+		"autopprof: cpu threshold value must be between 0 and 1, or positive with the CPUUsageSingleCore",
 	)
 	ErrInvalidMemThreshold = fmt.Errorf(
 		"autopprof: memory threshold value must be between 0 and 1",
 	)
 	ErrNilReporter         = fmt.Errorf("autopprof: Reporter can't be nil")
 	ErrDisableAllProfiling = fmt.Errorf("autopprof: all profiling is disabled")
+	ErrV1CPUQuotaUndefined = fmt.Errorf("autopprof: v1 cpu quota is undefined")
 	ErrV2CPUQuotaUndefined = fmt.Errorf("autopprof: v2 cpu quota is undefined")
 	ErrV2CPUMaxEmpty       = fmt.Errorf("autopprof: v2 cpu.max is empty")
 	ErrV1CPUSubsystemEmpty = fmt.Errorf("autopprof: v1 cpu subsystem is empty")
+
+	ErrInvalidReporterBreaker = fmt.Errorf(
+		"autopprof: reporter failure threshold and cooldown can't be negative",
+	)
+	ErrInvalidCPUWarnThreshold = fmt.Errorf(
+		"autopprof: cpu warning threshold value must be between 0 and the cpu threshold",
+	)
+	ErrInvalidMemWarnThreshold = fmt.Errorf(
+		"autopprof: memory warning threshold value must be between 0 and the memory threshold",
+	)
+	ErrInvalidWarnCooldown = fmt.Errorf(
+		"autopprof: warning cooldown can't be negative",
+	)
+	ErrInvalidFDThreshold = fmt.Errorf(
+		"autopprof: fd threshold value must be between 0 and 1",
+	)
+	ErrInvalidCPUTopN = fmt.Errorf(
+		"autopprof: cpu top n can't be negative",
+	)
+	ErrInvalidCPUProfileRate = fmt.Errorf(
+		"autopprof: cpu profile rate must be between 0 and 1000",
+	)
+	ErrInvalidLowPriorityCapture = fmt.Errorf(
+		"autopprof: low priority capture can't lock the os thread",
+	)
+	ErrInvalidMaxConcurrentReports = fmt.Errorf(
+		"autopprof: max concurrent reports can't be negative",
+	)
+	ErrInvalidMemLimitMode = fmt.Errorf(
+		"autopprof: invalid memory limit mode",
+	)
+	ErrInvalidMemUsageComponents = fmt.Errorf(
+		"autopprof: invalid memory usage components",
+	)
+	ErrMemFieldsUnavailable = fmt.Errorf(
+		"autopprof: memory fields of the cgroup are unavailable",
+	)
+	ErrInvalidCPUProfilingBudget = fmt.Errorf(
+		"autopprof: cpu profiling budget can't be negative",
+	)
+	ErrInvalidGCThreshold = fmt.Errorf(
+		"autopprof: gc thresholds can't be negative",
+	)
+	ErrInvalidReportTimeout = fmt.Errorf(
+		"autopprof: report timeout can't be negative",
+	)
+	ErrCPUProfilingInUse = fmt.Errorf(
+		"autopprof: cpu profiling is already in use by the others",
+	)
+	ErrInvalidMemProfileRate = fmt.Errorf(
+		"autopprof: mem profile rate can't be negative",
+	)
+	ErrInvalidBlockProfileRate = fmt.Errorf(
+		"autopprof: block profile rate can't be negative",
+	)
+	ErrInvalidMutexProfileFraction = fmt.Errorf(
+		"autopprof: mutex profile fraction can't be negative",
+	)
+	ErrNotStarted = fmt.Errorf(
+		"autopprof: autopprof is not started or is stopped",
+	)
+	ErrCgroupReadTimeout = fmt.Errorf(
+		"autopprof: reading the cgroup timed out",
+	)
+	ErrUnknownProfile = fmt.Errorf(
+		"autopprof: unknown profile name",
+	)
+	ErrInvalidLivenessInterval = fmt.Errorf(
+		"autopprof: liveness interval can't be negative, and the reporter must implement the report.ProfileReporter",
+	)
+	ErrInvalidSeverityBasedCooldown = fmt.Errorf(
+		"autopprof: severity based cooldown must have the increasing usages (between 0 and 1) with the decreasing positive cooldowns",
+	)
+	ErrReporterUnreachable = fmt.Errorf(
+		"autopprof: reporter is unreachable",
+	)
+	ErrInvalidCPUUsageBasis = fmt.Errorf(
+		"autopprof: invalid cpu usage basis",
+	)
+	ErrInvalidOCISpec = fmt.Errorf(
+		"autopprof: invalid OCI runtime spec",
+	)
+	ErrInvalidBurst = fmt.Errorf(
+		"autopprof: burst count and interval can't be negative",
+	)
+	ErrPSIUnavailable = fmt.Errorf(
+		"autopprof: pressure stall information is unavailable",
+	)
+	ErrInvalidCPUPressureThreshold = fmt.Errorf(
+		"autopprof: cpu pressure threshold value must be between 0 and 1",
+	)
+	ErrInvalidWALMaxBytes = fmt.Errorf(
+		"autopprof: WAL max bytes can't be negative",
+	)
+	ErrInvalidCPUProfilingDuration = fmt.Errorf(
+		"autopprof: cpu profiling duration can't be negative",
+	)
+	ErrInvalidWatchInterval = fmt.Errorf(
+		"autopprof: watch interval can't be negative",
+	)
+	ErrInvalidMinConsecutiveOverThreshold = fmt.Errorf(
+		"autopprof: min consecutive over threshold can't be negative",
+	)
+	ErrInvalidSampleInterval = fmt.Errorf(
+		"autopprof: sample interval must be between 0 and the watch interval",
+	)
+	ErrInvalidCPUQuotaRefreshInterval = fmt.Errorf(
+		"autopprof: cpu quota refresh interval can't be negative",
+	)
+	ErrInvalidGoroutineDropThreshold = fmt.Errorf(
+		"autopprof: goroutine drop threshold value must be between 0 and 1",
+	)
+	ErrInvalidProfile = fmt.Errorf(
+		"autopprof: the captured profile is invalid",
+	)
+	ErrInvalidHeapSampleType = fmt.Errorf(
+		"autopprof: heap primary sample type must be one of inuse_space, inuse_objects, alloc_space and alloc_objects",
+	)
+	ErrInvalidHeapSampleReduction = fmt.Errorf(
+		"autopprof: heap sample reduction value must be between 0 and 1 (exclusive)",
+	)
+	ErrInvalidMemGrowthThreshold = fmt.Errorf(
+		"autopprof: memory growth threshold value must be greater than or equal to 0",
+	)
+	ErrInvalidLatencyBreachDebounce = fmt.Errorf(
+		"autopprof: latency breach debounce value must be greater than or equal to 0",
+	)
+	ErrLatencyBreachDebounced = fmt.Errorf(
+		"autopprof: the latency breach is notified within the debounce",
+	)
+	ErrPIDLimitUnavailable = fmt.Errorf(
+		"autopprof: pids limit of the cgroup is unavailable",
+	)
+	ErrInvalidGoroutineThreshold = fmt.Errorf(
+		"autopprof: goroutine threshold can't be negative",
+	)
+	ErrInvalidPIDThreshold = fmt.Errorf(
+		"autopprof: pid threshold must be between 0 and 1",
+	)
+	ErrNUMAUnavailable = fmt.Errorf(
+		"autopprof: NUMA memory stats are unavailable",
+	)
+	ErrInvalidNUMAThreshold = fmt.Errorf(
+		"autopprof: NUMA threshold value must be between 0 and 1",
+	)
+	ErrInvalidErrorRate = fmt.Errorf(
+		"autopprof: error rate threshold and window can't be negative",
+	)
+	ErrInvalidStartupCaptureDelay = fmt.Errorf(
+		"autopprof: startup capture delay can't be negative",
+	)
+	ErrInvalidIncidentWindow = fmt.Errorf(
+		"autopprof: incident window can't be negative",
+	)
+	ErrInvalidUsageSmoothingAlpha = fmt.Errorf(
+		"autopprof: usage smoothing alpha value must be between 0 and 1",
+	)
+	ErrInvalidUsagePercentile = fmt.Errorf(
+		"autopprof: usage percentile must be between 0 and 1",
+	)
+	ErrInvalidWallClock = fmt.Errorf(
+		"autopprof: the reporter must implement the report.ProfileReporter to report the wall-clock profiles",
+	)
+	ErrInvalidAllocsDiff = fmt.Errorf(
+		"autopprof: the reporter must implement the report.ProfileReporter to report the allocs diff",
+	)
+	ErrInvalidCPUDiff = fmt.Errorf(
+		"autopprof: the reporter must implement the report.ProfileReporter to report the cpu diff",
+	)
+	ErrInvalidContentionProfiles = fmt.Errorf(
+		"autopprof: the reporter must implement the report.ProfileReporter to report the block and mutex profiles",
+	)
+	ErrHeapTooLarge = fmt.Errorf(
+		"autopprof: heap is over the max heap profile size, skip the heap profiling",
+	)
+	ErrInvalidCPUBaseline = fmt.Errorf(
+		"autopprof: cpu baseline deviation can't be negative, and the bucket must be between 0 and 24h",
+	)
+	ErrInvalidMemRequestThreshold = fmt.Errorf(
+		"autopprof: memory request threshold can't be negative, and requires the memory request bytes",
+	)
+	ErrPanicked = fmt.Errorf(
+		"autopprof: recovered from the panic",
+	)
+	ErrInvalidSummaryLogTemplate = fmt.Errorf(
+		"autopprof: summary log template is invalid",
+	)
 )
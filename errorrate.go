@@ -0,0 +1,74 @@
+package autopprof
+
+import (
+	"sync"
+	"time"
+)
+
+// errorRateBuckets is the number of the buckets the window of
+// the errorRate is split into.
+const errorRateBuckets = 10
+
+// errorRate counts the errors recorded by the application in a sliding
+// window of the buckets. A nil errorRate counts nothing.
+type errorRate struct {
+	mu     sync.Mutex
+	window time.Duration
+	// width is the duration of a bucket.
+	width   time.Duration
+	buckets [errorRateBuckets]errorBucket
+}
+
+// errorBucket is the count of the errors in the period of the index.
+type errorBucket struct {
+	// index is the unix time divided by the width of the bucket.
+	index int64
+	count uint64
+}
+
+func newErrorRate(window time.Duration) *errorRate {
+	width := window / errorRateBuckets
+	if width <= 0 {
+		width = 1
+	}
+	return &errorRate{
+		window: window,
+		width:  width,
+	}
+}
+
+// record counts an error at the now.
+func (r *errorRate) record(now time.Time) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	idx := now.UnixNano() / int64(r.width)
+	b := &r.buckets[idx%errorRateBuckets]
+	if b.index != idx {
+		b.index, b.count = idx, 0
+	}
+	b.count++
+}
+
+// rate returns the errors per second in the window until the now.
+func (r *errorRate) rate(now time.Time) float64 {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var (
+		idx   = now.UnixNano() / int64(r.width)
+		count uint64
+	)
+	for _, b := range r.buckets {
+		if idx-b.index >= 0 && idx-b.index < errorRateBuckets {
+			count += b.count
+		}
+	}
+	return float64(count) / r.window.Seconds()
+}
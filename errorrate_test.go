@@ -0,0 +1,55 @@
+package autopprof
+
+import (
+	"testing"
+	"time"
+)
+
+func TestErrorRate(t *testing.T) {
+	// A nil errorRate counts nothing.
+	var nilRate *errorRate
+	nilRate.record(time.Now())
+	if got := nilRate.rate(time.Now()); got != 0 {
+		t.Errorf("rate() of nil = %v, want 0", got)
+	}
+
+	var (
+		now = time.Unix(1000, 0)
+		r   = newErrorRate(10 * time.Second)
+	)
+	for i := 0; i < 10; i++ {
+		r.record(now)
+	}
+	for i := 0; i < 10; i++ {
+		r.record(now.Add(5 * time.Second))
+	}
+
+	testCases := []struct {
+		name string
+		at   time.Time
+		want float64
+	}{
+		{
+			name: "within the window",
+			at:   now.Add(5 * time.Second),
+			want: 2,
+		},
+		{
+			name: "first bucket expired",
+			at:   now.Add(10 * time.Second),
+			want: 1,
+		},
+		{
+			name: "all expired",
+			at:   now.Add(15 * time.Second),
+			want: 0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := r.rate(tc.at); got != tc.want {
+				t.Errorf("rate() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package autopprof
+
+import "time"
+
+// EventType is the type of the event.
+type EventType int
+
+// Event types.
+const (
+	// EventCPUWarning is emitted when the cpu usage crosses the
+	//  CPUWarnThreshold but not the CPUThreshold yet.
+	EventCPUWarning EventType = iota + 1
+	// EventMemWarning is emitted when the memory usage crosses the
+	//  MemWarnThreshold but not the MemThreshold yet.
+	EventMemWarning
+	// EventCPURecovered is emitted when the cpu usage drops back below
+	//  the CPUThreshold in the edge-triggered mode.
+	EventCPURecovered
+	// EventMemRecovered is emitted when the memory usage drops back below
+	//  the MemThreshold in the edge-triggered mode.
+	EventMemRecovered
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventCPUWarning:
+		return "cpu_warning"
+	case EventMemWarning:
+		return "mem_warning"
+	case EventCPURecovered:
+		return "cpu_recovered"
+	case EventMemRecovered:
+		return "mem_recovered"
+	}
+	return "unknown"
+}
+
+// Event is the notification about the resource usage which doesn't
+// come with the profile.
+type Event struct {
+	Type                EventType
+	ThresholdPercentage float64
+	UsagePercentage     float64
+	Time                time.Time
+}
@@ -0,0 +1,108 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"expvar"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// expvarName is the name of the expvar the metrics are published under.
+const expvarName = "autopprof"
+
+var publishExpvarOnce sync.Once
+
+// publishExpvar publishes the metrics of the global autopprof process
+// under the expvarName. The expvar is published once and reads
+// the current globalAp, since the expvar can't be unpublished across
+// the restarts.
+func publishExpvar() {
+	publishExpvarOnce.Do(func() {
+		expvar.Publish(expvarName, expvar.Func(func() interface{} {
+			ap := globalAp
+			if ap == nil || ap.stats == nil {
+				return nil
+			}
+			return ap.stats.snapshot()
+		}))
+	})
+}
+
+// reportStats counts the reports of the autopprof for the expvar.
+// A nil reportStats counts nothing.
+type reportStats struct {
+	sent     uint64
+	failures uint64
+	dropped  uint64
+
+	// cpuUsage and memUsage are the bits of the last usages.
+	cpuUsage uint64
+	memUsage uint64
+	// lastReportedAt is the unix nanoseconds of the last report.
+	lastReportedAt int64
+}
+
+// expvarSnapshot is the JSON of the expvar.
+type expvarSnapshot struct {
+	ReportsSent    uint64 `json:"reports_sent"`
+	ReportFailures uint64 `json:"report_failures"`
+	// ReportsDropped is the number of the captures skipped by
+	//  the circuit breaker or the profiling budget.
+	ReportsDropped uint64    `json:"reports_dropped"`
+	CPUUsage       float64   `json:"cpu_usage"`
+	MemUsage       float64   `json:"mem_usage"`
+	LastReportTime time.Time `json:"last_report_time"`
+}
+
+// record counts the result of a report.
+func (s *reportStats) record(err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		atomic.AddUint64(&s.failures, 1)
+		return
+	}
+	atomic.AddUint64(&s.sent, 1)
+	atomic.StoreInt64(&s.lastReportedAt, time.Now().UnixNano())
+}
+
+// drop counts the skipped capture.
+func (s *reportStats) drop() {
+	if s == nil {
+		return
+	}
+	atomic.AddUint64(&s.dropped, 1)
+}
+
+func (s *reportStats) setCPUUsage(usage float64) {
+	if s == nil {
+		return
+	}
+	atomic.StoreUint64(&s.cpuUsage, math.Float64bits(usage))
+}
+
+func (s *reportStats) setMemUsage(usage float64) {
+	if s == nil {
+		return
+	}
+	atomic.StoreUint64(&s.memUsage, math.Float64bits(usage))
+}
+
+func (s *reportStats) snapshot() expvarSnapshot {
+	snap := expvarSnapshot{
+		ReportsSent:    atomic.LoadUint64(&s.sent),
+		ReportFailures: atomic.LoadUint64(&s.failures),
+		ReportsDropped: atomic.LoadUint64(&s.dropped),
+		CPUUsage:       math.Float64frombits(atomic.LoadUint64(&s.cpuUsage)),
+		MemUsage:       math.Float64frombits(atomic.LoadUint64(&s.memUsage)),
+	}
+	if at := atomic.LoadInt64(&s.lastReportedAt); at != 0 {
+		snap.LastReportTime = time.Unix(0, at)
+	}
+	return snap
+}
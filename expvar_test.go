@@ -0,0 +1,61 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"testing"
+)
+
+func TestReportStats(t *testing.T) {
+	// A nil reportStats counts nothing.
+	var nilStats *reportStats
+	nilStats.record(nil)
+	nilStats.drop()
+	nilStats.setCPUUsage(0.5)
+
+	s := &reportStats{}
+	s.record(nil)
+	s.record(errors.New("fail"))
+	s.drop()
+	s.setCPUUsage(0.8)
+	s.setMemUsage(0.5)
+
+	snap := s.snapshot()
+	if snap.ReportsSent != 1 || snap.ReportFailures != 1 || snap.ReportsDropped != 1 {
+		t.Errorf("snapshot() = %+v, want 1 sent, 1 failure and 1 dropped", snap)
+	}
+	if snap.CPUUsage != 0.8 || snap.MemUsage != 0.5 {
+		t.Errorf("snapshot() = %+v, want the cpu usage 0.8 and the mem usage 0.5", snap)
+	}
+	if snap.LastReportTime.IsZero() {
+		t.Errorf("snapshot().LastReportTime is zero, want the time of the report")
+	}
+}
+
+func TestPublishExpvar(t *testing.T) {
+	prev := globalAp
+	t.Cleanup(func() { globalAp = prev })
+
+	globalAp = &autoPprof{stats: &reportStats{}}
+	globalAp.stats.record(nil)
+
+	// Publishing twice doesn't panic.
+	publishExpvar()
+	publishExpvar()
+
+	v := expvar.Get(expvarName)
+	if v == nil {
+		t.Fatalf("expvar.Get(%q) = nil, want the metrics", expvarName)
+	}
+	var snap expvarSnapshot
+	if err := json.Unmarshal([]byte(v.String()), &snap); err != nil {
+		t.Fatalf("json.Unmarshal() = %v, want nil", err)
+	}
+	if snap.ReportsSent != 1 {
+		t.Errorf("reports_sent = %d, want 1", snap.ReportsSent)
+	}
+}
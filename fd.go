@@ -0,0 +1,33 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"os"
+	"syscall"
+)
+
+const procSelfFDDir = "/proc/self/fd"
+
+// fdUsage returns the ratio of the open file descriptors of the
+// process to the soft limit of the number of file descriptors.
+func fdUsage() (float64, error) {
+	var rlim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlim); err != nil {
+		return 0, err
+	}
+	f, err := os.Open(procSelfFDDir)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return 0, err
+	}
+	// Exclude the fd opened to read the directory.
+	open := len(names) - 1
+	return float64(open) / float64(rlim.Cur), nil
+}
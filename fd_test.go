@@ -0,0 +1,34 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"os"
+	"testing"
+)
+
+func TestFDUsage(t *testing.T) {
+	before, err := fdUsage()
+	if err != nil {
+		t.Errorf("fdUsage() = %v, want nil", err)
+		t.FailNow()
+	}
+	if before <= 0 || before > 1 {
+		t.Errorf("fdUsage() = %f, want between 0 and 1", before)
+	}
+
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	after, err := fdUsage()
+	if err != nil {
+		t.Errorf("fdUsage() = %v, want nil", err)
+	}
+	if after <= before {
+		t.Errorf("fdUsage() = %f after opening a file, want > %f", after, before)
+	}
+}
@@ -0,0 +1,50 @@
+package autopprof
+
+import (
+	"runtime"
+	"sort"
+	"time"
+)
+
+// gcHeapSampleTypes are the views of the heap profile reported by
+// the gc pressure trigger. The allocations matter more than the in-use
+// memory for the gc pressure.
+var gcHeapSampleTypes = []string{
+	"inuse_space",
+	"alloc_space",
+}
+
+// gcPressure is the gc activity between two runtime.MemStats.
+type gcPressure struct {
+	// rate is the number of the gc cycles per second.
+	rate float64
+	// pauseP99 is the 99th percentile of the stop-the-world pause
+	//  durations of the gc cycles.
+	pauseP99 time.Duration
+}
+
+// newGCPressure returns the gc activity from the prev to the cur taken
+// the elapsed apart.
+// The runtime keeps only the recent 256 pauses, so the older ones are
+// ignored if there were more gc cycles than that.
+func newGCPressure(prev, cur *runtime.MemStats, elapsed time.Duration) gcPressure {
+	var p gcPressure
+	n := cur.NumGC - prev.NumGC
+	if n == 0 || elapsed <= 0 {
+		return p
+	}
+	p.rate = float64(n) / elapsed.Seconds()
+
+	if n > uint32(len(cur.PauseNs)) {
+		n = uint32(len(cur.PauseNs))
+	}
+	pauses := make([]uint64, 0, n)
+	for i := uint32(0); i < n; i++ {
+		// The pause of the i-th recent gc is at (NumGC-i+255)%256.
+		idx := (cur.NumGC - i + uint32(len(cur.PauseNs)) - 1) % uint32(len(cur.PauseNs))
+		pauses = append(pauses, cur.PauseNs[idx])
+	}
+	sort.Slice(pauses, func(i, j int) bool { return pauses[i] < pauses[j] })
+	p.pauseP99 = time.Duration(pauses[(len(pauses)*99-1)/100])
+	return p
+}
@@ -0,0 +1,34 @@
+package autopprof
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestNewGCPressure(t *testing.T) {
+	var prev, cur runtime.MemStats
+	prev.NumGC = 254
+	cur.NumGC = 264 // 10 gc cycles, wrapping around the PauseNs.
+	for i := uint32(255); i <= cur.NumGC; i++ {
+		cur.PauseNs[(i+255)%256] = uint64(i-254) * uint64(time.Millisecond)
+	}
+	// The pause older than the prev must be ignored.
+	cur.PauseNs[(254+255)%256] = uint64(time.Hour)
+
+	p := newGCPressure(&prev, &cur, 2*time.Second)
+	if p.rate != 5 {
+		t.Errorf("rate = %v, want 5", p.rate)
+	}
+	if p.pauseP99 != 10*time.Millisecond {
+		t.Errorf("pauseP99 = %s, want 10ms", p.pauseP99)
+	}
+}
+
+func TestNewGCPressure_noGC(t *testing.T) {
+	var prev, cur runtime.MemStats
+	prev.NumGC, cur.NumGC = 3, 3
+	if p := newGCPressure(&prev, &cur, time.Second); p != (gcPressure{}) {
+		t.Errorf("newGCPressure() = %+v, want zero", p)
+	}
+}
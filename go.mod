@@ -4,8 +4,12 @@ go 1.19
 
 require (
 	github.com/containerd/cgroups v1.0.4
+	github.com/felixge/fgprof v0.9.2
 	github.com/golang/mock v1.6.0
+	github.com/google/pprof v0.0.0-20221118152302-e6195bd50e26
+	github.com/klauspost/compress v1.15.15
 	github.com/slack-go/slack v0.11.3
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -17,5 +21,5 @@ require (
 	github.com/gorilla/websocket v1.4.2 // indirect
 	github.com/opencontainers/runtime-spec v1.0.2 // indirect
 	github.com/sirupsen/logrus v1.8.1 // indirect
-	golang.org/x/sys v0.0.0-20210510120138-977fb7262007 // indirect
+	golang.org/x/sys v0.0.0-20220310020820-b874c991c1a5 // indirect
 )
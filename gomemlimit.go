@@ -0,0 +1,74 @@
+package autopprof
+
+import (
+	"fmt"
+	"runtime/metrics"
+)
+
+// MemLimitMode is the memory limit to compute the memory usage against.
+type MemLimitMode int
+
+const (
+	// MemLimitCgroup computes the working set of the cgroup against
+	//  the memory limit of the cgroup.
+	MemLimitCgroup MemLimitMode = iota
+	// MemLimitGo computes the memory mapped by the Go runtime against
+	//  the GOMEMLIMIT, which is the runtime's own pressure signal.
+	// It falls back to the MemLimitCgroup if the GOMEMLIMIT isn't set.
+	MemLimitGo
+	// MemLimitBoth computes both and uses the higher one.
+	MemLimitBoth
+)
+
+// String returns the name of the mode.
+func (m MemLimitMode) String() string {
+	switch m {
+	case MemLimitCgroup:
+		return "cgroup"
+	case MemLimitGo:
+		return "go"
+	case MemLimitBoth:
+		return "both"
+	}
+	return "unknown"
+}
+
+// UnmarshalText parses the name of the mode, so it's loaded from
+// the config by the LoadOption.
+func (m *MemLimitMode) UnmarshalText(text []byte) error {
+	for _, mode := range []MemLimitMode{
+		MemLimitCgroup, MemLimitGo, MemLimitBoth,
+	} {
+		if mode.String() == string(text) {
+			*m = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("autopprof: unknown memory limit mode: %q", text)
+}
+
+const (
+	goMemTotalMetric    = "/memory/classes/total:bytes"
+	goMemReleasedMetric = "/memory/classes/heap/released:bytes"
+)
+
+// readGoMemStat returns the memory bytes mapped by the Go runtime and
+// not released to the OS, which is what the runtime limits by
+// the GOMEMLIMIT, and the GOMEMLIMIT. The limit is zero if it's not set.
+func readGoMemStat() (usage, limit uint64) {
+	samples := []metrics.Sample{
+		{Name: goMemTotalMetric},
+		{Name: goMemReleasedMetric},
+	}
+	metrics.Read(samples)
+	for _, s := range samples {
+		if s.Value.Kind() != metrics.KindUint64 {
+			return 0, 0
+		}
+	}
+	total, released := samples[0].Value.Uint64(), samples[1].Value.Uint64()
+	if released < total {
+		usage = total - released
+	}
+	return usage, goMemLimit()
+}
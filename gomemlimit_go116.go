@@ -0,0 +1,10 @@
+//go:build !go1.19
+// +build !go1.19
+
+package autopprof
+
+// goMemLimit returns the GOMEMLIMIT. It's always zero since
+// the GOMEMLIMIT is added in Go 1.19.
+func goMemLimit() uint64 {
+	return 0
+}
@@ -0,0 +1,19 @@
+//go:build go1.19
+// +build go1.19
+
+package autopprof
+
+import (
+	"math"
+	"runtime/debug"
+)
+
+// goMemLimit returns the GOMEMLIMIT. It's zero if it's not set.
+func goMemLimit() uint64 {
+	// The negative input only reads the current limit.
+	limit := debug.SetMemoryLimit(-1)
+	if limit <= 0 || limit == math.MaxInt64 {
+		return 0
+	}
+	return uint64(limit)
+}
@@ -0,0 +1,28 @@
+//go:build go1.19
+// +build go1.19
+
+package autopprof
+
+import (
+	"math"
+	"runtime/debug"
+	"testing"
+)
+
+func TestReadGoMemStat(t *testing.T) {
+	prev := debug.SetMemoryLimit(1 << 40)
+	t.Cleanup(func() { debug.SetMemoryLimit(prev) })
+
+	usage, limit := readGoMemStat()
+	if usage == 0 {
+		t.Errorf("usage = 0, want > 0")
+	}
+	if limit != 1<<40 {
+		t.Errorf("limit = %d, want %d", limit, uint64(1<<40))
+	}
+
+	debug.SetMemoryLimit(prev)
+	if _, limit := readGoMemStat(); prev == math.MaxInt64 && limit != 0 {
+		t.Errorf("limit = %d, want 0", limit)
+	}
+}
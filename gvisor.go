@@ -0,0 +1,114 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/cgroups"
+)
+
+const (
+	procVersionFile = "/proc/version"
+
+	// gVisorProcVersion is the fixed /proc/version of the gVisor
+	// sentry, which doesn't expose the kernel of the host.
+	gVisorProcVersion = "Linux version 4.4.0 #1 SMP Sun Jan 10 15:06:54 PST 2016"
+
+	cgroupV1MemUsageFile = "memory.usage_in_bytes"
+	cgroupV1MemLimitFile = "memory.limit_in_bytes"
+	cgroupV1MemStatFile  = "memory.stat"
+)
+
+// isGVisor reports whether the process runs in the gVisor (runsc)
+// sandbox by the procVersionFile.
+// The sentry virtualizes the cgroup files, so some of them are absent
+// and the readings may be approximate.
+func isGVisor(procVersionFile string) bool {
+	b, err := os.ReadFile(procVersionFile)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(strings.TrimSpace(string(b)), gVisorProcVersion)
+}
+
+// detectGVisor reports whether the process runs in the gVisor, and
+// logs that the readings may be approximate if so.
+func detectGVisor() bool {
+	if !isGVisor(procVersionFile) {
+		return false
+	}
+	logger().Println(
+		"autopprof: running in the gVisor sandbox, the cgroup readings may be approximate",
+	)
+	return true
+}
+
+// gVisorMemUsage reads the memory usage of the cgroup v1 from the files
+// the sentry provides, since its memory.stat lacks the fields read by
+// the stat of the cgroups. (e.g. hierarchical_memory_limit)
+// The inactive file pages are subtracted if the memory.stat has them.
+func (c *cgroupV1) gVisorMemUsage() (*memStat, error) {
+	memPath, _ := c.path(cgroups.Memory)
+	dir := path.Join(c.mountPoint, string(cgroups.Memory), memPath)
+
+	usage, err := readUint(path.Join(dir, cgroupV1MemUsageFile))
+	if err != nil {
+		return nil, err
+	}
+	limit, err := readUint(path.Join(dir, cgroupV1MemLimitFile))
+	if err != nil {
+		return nil, err
+	}
+	inactive := readMemStatField(
+		path.Join(dir, cgroupV1MemStatFile), "total_inactive_file", "inactive_file",
+	)
+	return &memStat{
+		usage: workingSet(usage, inactive),
+		limit: limit,
+	}, nil
+}
+
+// readUint reads the unsigned integer in the file.
+func readUint(file string) (uint64, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// readMemStatField returns the value of the first of the keys found in
+// the memory.stat file. It's zero if none of them is found.
+func readMemStatField(file string, keys ...string) uint64 {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[fields[0]] = v
+	}
+	for _, k := range keys {
+		if v, ok := values[k]; ok {
+			return v
+		}
+	}
+	return 0
+}
@@ -0,0 +1,146 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIsGVisor(t *testing.T) {
+	testCases := []struct {
+		name        string
+		procVersion string
+		want        bool
+	}{
+		{
+			name:        "gVisor",
+			procVersion: gVisorProcVersion + "\n",
+			want:        true,
+		},
+		{
+			name:        "linux",
+			procVersion: "Linux version 5.15.0-1034-aws (buildd@lcy02-amd64-045) (gcc 11.3.0) #38-Ubuntu SMP\n",
+			want:        false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "version")
+			if err := os.WriteFile(file, []byte(tc.procVersion), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if got := isGVisor(file); got != tc.want {
+				t.Errorf("isGVisor() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+	if isGVisor(filepath.Join(t.TempDir(), "version")) {
+		t.Errorf("isGVisor() = true for the missing file, want false")
+	}
+}
+
+// writeGVisorCgroupV1 writes the cgroup v1 view of the gVisor sentry
+// under the mount point. The memory.stat lacks the hierarchical
+// fields, and the cpu usage is only in the cpuacct.usage.
+func writeGVisorCgroupV1(t *testing.T, memStat string) string {
+	t.Helper()
+
+	mountPoint := t.TempDir()
+	files := map[string]string{
+		"memory/app/memory.usage_in_bytes": "600\n",
+		"memory/app/memory.limit_in_bytes": "1000\n",
+		"memory/app/memory.stat":           memStat,
+		"cpu/app/cpu.cfs_quota_us":         "200000\n",
+		"cpu/app/cpu.cfs_period_us":        "100000\n",
+		"cpuacct/app/cpuacct.usage":        "1000000000\n",
+	}
+	for name, content := range files {
+		file := filepath.Join(mountPoint, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mountPoint
+}
+
+func TestCgroupV1_memUsage_gVisor(t *testing.T) {
+	testCases := []struct {
+		name      string
+		memStat   string
+		wantUsage uint64
+	}{
+		{
+			name:      "with inactive_file",
+			memStat:   "cache 300\nrss 300\ninactive_file 100\n",
+			wantUsage: 500,
+		},
+		{
+			name:      "without inactive_file",
+			memStat:   "cache 300\nrss 300\n",
+			wantUsage: 600,
+		},
+		{
+			name:      "inactive_file exceeds the usage",
+			memStat:   "cache 300\nrss 300\ninactive_file 700\n",
+			wantUsage: 0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cgv1 := &cgroupV1{
+				staticPath: "/app",
+				mountPoint: writeGVisorCgroupV1(t, tc.memStat),
+				gVisor:     true,
+			}
+			stat, err := cgv1.memUsage()
+			if err != nil {
+				t.Fatalf("memUsage() = %v, want nil", err)
+			}
+			if stat.usage != tc.wantUsage || stat.limit != 1000 {
+				t.Errorf("memUsage() = %+v, want the usage %d and the limit 1000", stat, tc.wantUsage)
+			}
+		})
+	}
+}
+
+func TestCgroupV1_cpuUsage_gVisor(t *testing.T) {
+	mountPoint := writeGVisorCgroupV1(t, "")
+	cgv1 := &cgroupV1{
+		staticPath:       "/app",
+		mountPoint:       mountPoint,
+		cpuSubsystem:     cgroupV1CPUSubsystem,
+		cpuacctSubsystem: cgroupV1CPUAcctSubsystem,
+		gVisor:           true,
+		q:                newCPUUsageSnapshotQueue(2),
+	}
+	if err := cgv1.setCPUQuota(); err != nil {
+		t.Fatalf("setCPUQuota() = %v, want nil", err)
+	}
+	if _, err := cgv1.cpuUsage(); err != nil {
+		t.Fatalf("cpuUsage() = %v, want nil", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	file := filepath.Join(mountPoint, "cpuacct/app/cpuacct.usage")
+	if err := os.WriteFile(file, []byte("1100000000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	usage, err := cgv1.cpuUsage()
+	if err != nil {
+		t.Fatalf("cpuUsage() = %v, want nil", err)
+	}
+	// 0.1s of the cpu time in about 0.1s with the 2 cores quota.
+	if usage <= 0 || usage > 0.5 {
+		t.Errorf("cpuUsage() = %v, want about 0.5", usage)
+	}
+	if s := cgv1.status(); !s.GVisor {
+		t.Errorf("status().GVisor = false, want true")
+	}
+}
@@ -0,0 +1,181 @@
+package autopprof
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthPath is the conventional path to serve the HealthHandler on.
+const HealthPath = "/autopprof/health"
+
+// Names of the watchers in the HealthInfo.
+const (
+	watcherCPU           = "cpu"
+	watcherMem           = "mem"
+	watcherGoroutine     = "goroutine"
+	watcherFD            = "fd"
+	watcherPID           = "pid"
+	watcherGoroutineDrop = "goroutine_drop"
+	watcherGC            = "gc"
+	watcherErrorRate     = "error_rate"
+)
+
+// staleWatchIntervals is the number of the watch intervals without
+// a watch after which the watcher is considered stalled.
+const staleWatchIntervals = 3
+
+// HealthState is the overall health of the autopprof.
+type HealthState string
+
+// Health states.
+const (
+	// HealthOK means that all of the watchers are ticking and
+	//  the last report succeeded.
+	HealthOK HealthState = "ok"
+	// HealthDegraded means that the autopprof is running, but a watcher
+	//  stopped or stalled, or the reporter is failing.
+	HealthDegraded HealthState = "degraded"
+	// HealthDown means that the autopprof isn't running.
+	HealthDown HealthState = "down"
+)
+
+// HealthInfo is the JSON served by the HealthHandler, so
+// the orchestration can verify that the profiling is functioning.
+type HealthInfo struct {
+	Status HealthState `json:"status"`
+	// Reasons are why the status isn't ok.
+	Reasons []string `json:"reasons,omitempty"`
+
+	Running bool `json:"running"`
+	// Watchers are the health of the enabled watchers by their names.
+	//  (e.g. cpu, mem)
+	Watchers map[string]WatcherHealth `json:"watchers"`
+
+	Capabilities Capabilities   `json:"capabilities"`
+	Limits       HealthLimits   `json:"limits"`
+	Reporter     ReporterHealth `json:"reporter"`
+}
+
+// WatcherHealth is the health of a watcher.
+type WatcherHealth struct {
+	// Active reports whether the watcher is running. A watcher stops
+	//  on the unrecoverable error of reading the usage.
+	Active bool `json:"active"`
+	// LastTick is the time of the last watch. Zero means it hasn't
+	//  watched yet.
+	LastTick time.Time `json:"last_tick"`
+}
+
+// HealthLimits are the resolved limits the usages are relative to.
+type HealthLimits struct {
+	// CPUQuota is the cpu quota in cores.
+	CPUQuota float64 `json:"cpu_quota"`
+	// MemLimitBytes is the memory limit in bytes.
+	MemLimitBytes uint64 `json:"mem_limit_bytes"`
+}
+
+// ReporterHealth is the health of the reporter.
+type ReporterHealth struct {
+	Breaker             string `json:"breaker"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	// LastReportTime is the time of the last successful report.
+	//  Zero means nothing has been reported yet.
+	LastReportTime time.Time `json:"last_report_time"`
+}
+
+// newHealthHandler returns the handler serving the HealthInfo of
+// the health. It responds 200 for the HealthOK and 503 for the others,
+// so it's usable as the probe of the orchestration as is.
+func newHealthHandler(health func() HealthInfo) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		info := health()
+		code := http.StatusOK
+		if info.Status != HealthOK {
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(info)
+	})
+}
+
+// watcherHealth tracks the watchers for the HealthInfo.
+// A nil watcherHealth tracks nothing.
+type watcherHealth struct {
+	mu       sync.Mutex
+	watchers map[string]WatcherHealth
+}
+
+func newWatcherHealth() *watcherHealth {
+	return &watcherHealth{
+		watchers: make(map[string]WatcherHealth),
+	}
+}
+
+// start marks the watcher of the name active.
+func (h *watcherHealth) start(name string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	w := h.watchers[name]
+	w.Active = true
+	h.watchers[name] = w
+}
+
+// tick records the watch of the watcher of the name.
+func (h *watcherHealth) tick(name string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	w := h.watchers[name]
+	w.LastTick = time.Now()
+	h.watchers[name] = w
+}
+
+// exit marks the watcher of the name inactive.
+func (h *watcherHealth) exit(name string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	w := h.watchers[name]
+	w.Active = false
+	h.watchers[name] = w
+}
+
+// remove untracks the watcher of the name, e.g. paused by the disable
+// of the profiling.
+func (h *watcherHealth) remove(name string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.watchers, name)
+}
+
+// snapshot returns the copy of the watchers.
+func (h *watcherHealth) snapshot() map[string]WatcherHealth {
+	watchers := make(map[string]WatcherHealth)
+	if h == nil {
+		return watchers
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for name, w := range h.watchers {
+		watchers[name] = w
+	}
+	return watchers
+}
@@ -0,0 +1,77 @@
+package autopprof
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWatcherHealth(t *testing.T) {
+	// A nil watcherHealth tracks nothing.
+	var nilHealth *watcherHealth
+	nilHealth.start(watcherCPU)
+	nilHealth.tick(watcherCPU)
+	nilHealth.exit(watcherCPU)
+	if got := nilHealth.snapshot(); len(got) != 0 {
+		t.Errorf("snapshot() = %v, want empty", got)
+	}
+
+	h := newWatcherHealth()
+	h.start(watcherCPU)
+	h.tick(watcherCPU)
+	h.start(watcherMem)
+	h.exit(watcherMem)
+
+	got := h.snapshot()
+	if cpu := got[watcherCPU]; !cpu.Active || cpu.LastTick.IsZero() {
+		t.Errorf("snapshot()[cpu] = %+v, want active and ticked", cpu)
+	}
+	if mem := got[watcherMem]; mem.Active || !mem.LastTick.IsZero() {
+		t.Errorf("snapshot()[mem] = %+v, want inactive and not ticked", mem)
+	}
+}
+
+func TestNewHealthHandler(t *testing.T) {
+	testCases := []struct {
+		name   string
+		status HealthState
+		want   int
+	}{
+		{
+			name:   "ok",
+			status: HealthOK,
+			want:   http.StatusOK,
+		},
+		{
+			name:   "degraded",
+			status: HealthDegraded,
+			want:   http.StatusServiceUnavailable,
+		},
+		{
+			name:   "down",
+			status: HealthDown,
+			want:   http.StatusServiceUnavailable,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newHealthHandler(func() HealthInfo {
+				return HealthInfo{Status: tc.status}
+			})
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, HealthPath, nil))
+
+			if rec.Code != tc.want {
+				t.Errorf("status code = %d, want %d", rec.Code, tc.want)
+			}
+			var info HealthInfo
+			if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+				t.Fatalf("json.Unmarshal() = %v, want nil", err)
+			}
+			if info.Status != tc.status {
+				t.Errorf("status = %s, want %s", info.Status, tc.status)
+			}
+		})
+	}
+}
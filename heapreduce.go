@@ -0,0 +1,41 @@
+package autopprof
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/google/pprof/profile"
+)
+
+// reduceHeapProfile parses the heap profile and drops the reduction
+// share of its samples, the smallest ones by the sample type first, and
+// the locations and functions only they refer to. So the profile gets
+// smaller while the dominant allocation sites are kept.
+func reduceHeapProfile(b []byte, reduction float64, sampleType string) ([]byte, error) {
+	p, err := profile.Parse(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	if len(p.SampleType) == 0 {
+		return b, nil
+	}
+	idx := len(p.SampleType) - 1
+	for i, st := range p.SampleType {
+		if st.Type == sampleType {
+			idx = i
+			break
+		}
+	}
+
+	sort.SliceStable(p.Sample, func(i, j int) bool {
+		return p.Sample[i].Value[idx] > p.Sample[j].Value[idx]
+	})
+	keep := len(p.Sample) - int(float64(len(p.Sample))*reduction)
+	p.Sample = p.Sample[:keep]
+
+	var buf bytes.Buffer
+	if err := p.Compact().Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
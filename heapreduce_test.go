@@ -0,0 +1,85 @@
+package autopprof
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/looko-corp/autopprof/report"
+)
+
+func TestReduceHeapProfile(t *testing.T) {
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: report.SampleTypeAllocSpace, Unit: "bytes"},
+			{Type: report.SampleTypeInuseSpace, Unit: "bytes"},
+		},
+	}
+	for i := 1; i <= 4; i++ {
+		fn := &profile.Function{ID: uint64(i), Name: fmt.Sprintf("alloc%d", i)}
+		loc := &profile.Location{
+			ID:   uint64(i),
+			Line: []profile.Line{{Function: fn}},
+		}
+		p.Function = append(p.Function, fn)
+		p.Location = append(p.Location, loc)
+		p.Sample = append(p.Sample, &profile.Sample{
+			Location: []*profile.Location{loc},
+			Value:    []int64{int64(10 - i), int64(i)},
+		})
+	}
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name       string
+		sampleType string
+		want       []string
+	}{
+		{
+			name:       "inuse_space",
+			sampleType: report.SampleTypeInuseSpace,
+			want:       []string{"alloc4", "alloc3"},
+		},
+		{
+			name:       "alloc_space",
+			sampleType: report.SampleTypeAllocSpace,
+			want:       []string{"alloc1", "alloc2"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := reduceHeapProfile(buf.Bytes(), 0.5, tc.sampleType)
+			if err != nil {
+				t.Fatalf("reduceHeapProfile() = %v, want nil", err)
+			}
+			reduced, err := profile.Parse(bytes.NewReader(b))
+			if err != nil {
+				t.Fatalf("profile.Parse() = %v, want nil", err)
+			}
+			if len(reduced.Sample) != len(tc.want) {
+				t.Fatalf("len(Sample) = %d, want %d", len(reduced.Sample), len(tc.want))
+			}
+			for i, s := range reduced.Sample {
+				if got := s.Location[0].Line[0].Function.Name; got != tc.want[i] {
+					t.Errorf("Sample[%d] = %s, want %s", i, got, tc.want[i])
+				}
+			}
+			if len(reduced.Function) != len(tc.want) {
+				t.Errorf("len(Function) = %d, want %d", len(reduced.Function), len(tc.want))
+			}
+		})
+	}
+}
+
+func TestReduceHeapProfile_invalid(t *testing.T) {
+	if _, err := reduceHeapProfile(
+		[]byte("prof"), 0.5, report.SampleTypeInuseSpace,
+	); err == nil {
+		t.Errorf("reduceHeapProfile() = nil, want error")
+	}
+}
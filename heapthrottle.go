@@ -0,0 +1,70 @@
+package autopprof
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// heapThrottle throttles the heap profiling of the heap over the max
+// size, since the profiling of a huge heap is costly by itself and can
+// worsen the memory incident it investigates.
+// (See Option.MaxHeapProfileSize) A nil heapThrottle throttles nothing.
+type heapThrottle struct {
+	// maxSize is the heap size to throttle the profiling above.
+	maxSize uint64
+	// sampleReduction is the share of the samples dropped from
+	//  the profile of the heap over the maxSize. Zero means skipping
+	//  the profiling.
+	sampleReduction float64
+
+	skipped     uint64
+	downsampled uint64
+}
+
+// newHeapThrottle returns the heapThrottle of the maxSize. It returns
+// nil if the maxSize is zero.
+func newHeapThrottle(maxSize uint64, sampleReduction float64) *heapThrottle {
+	if maxSize == 0 {
+		return nil
+	}
+	return &heapThrottle{
+		maxSize:         maxSize,
+		sampleReduction: sampleReduction,
+	}
+}
+
+// reduction returns the sample reduction of the profile of the heap of
+// the size, given the reduction of the normal one. It returns
+// ErrHeapTooLarge if the profiling should be skipped.
+func (t *heapThrottle) reduction(heapSize uint64, reduction float64) (float64, error) {
+	if t == nil || heapSize <= t.maxSize {
+		return reduction, nil
+	}
+	if t.sampleReduction == 0 {
+		atomic.AddUint64(&t.skipped, 1)
+		return 0, fmt.Errorf(
+			"%w: %d bytes > %d bytes", ErrHeapTooLarge, heapSize, t.maxSize,
+		)
+	}
+	atomic.AddUint64(&t.downsampled, 1)
+	logger().Printf(
+		"autopprof: the heap (%d bytes) is over the max heap profile size (%d bytes), downsample the heap profile",
+		heapSize, t.maxSize,
+	)
+	if t.sampleReduction > reduction {
+		return t.sampleReduction, nil
+	}
+	return reduction, nil
+}
+
+// status returns the counts of the throttled profiling.
+func (t *heapThrottle) status() HeapThrottleStatus {
+	if t == nil {
+		return HeapThrottleStatus{}
+	}
+	return HeapThrottleStatus{
+		MaxSize:     t.maxSize,
+		Skipped:     atomic.LoadUint64(&t.skipped),
+		Downsampled: atomic.LoadUint64(&t.downsampled),
+	}
+}
@@ -0,0 +1,73 @@
+package autopprof
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHeapThrottle_reduction(t *testing.T) {
+	// A nil heapThrottle throttles nothing.
+	var nilThrottle *heapThrottle
+	if got, err := nilThrottle.reduction(1<<40, 0.1); got != 0.1 || err != nil {
+		t.Errorf("reduction() of nil = %v, %v, want 0.1, nil", got, err)
+	}
+	if newHeapThrottle(0, 0.5) != nil {
+		t.Errorf("newHeapThrottle(0) = non-nil, want nil")
+	}
+
+	testCases := []struct {
+		name            string
+		sampleReduction float64
+		heapSize        uint64
+		reduction       float64
+		want            float64
+		wantErr         error
+		wantStatus      HeapThrottleStatus
+	}{
+		{
+			name:       "under the max size",
+			heapSize:   1 << 20,
+			reduction:  0.1,
+			want:       0.1,
+			wantStatus: HeapThrottleStatus{MaxSize: 1 << 30},
+		},
+		{
+			name:       "skipped",
+			heapSize:   2 << 30,
+			reduction:  0.1,
+			wantErr:    ErrHeapTooLarge,
+			wantStatus: HeapThrottleStatus{MaxSize: 1 << 30, Skipped: 1},
+		},
+		{
+			name:            "downsampled",
+			sampleReduction: 0.9,
+			heapSize:        2 << 30,
+			reduction:       0.1,
+			want:            0.9,
+			wantStatus:      HeapThrottleStatus{MaxSize: 1 << 30, Downsampled: 1},
+		},
+		{
+			name:            "higher reduction of the normal one",
+			sampleReduction: 0.5,
+			heapSize:        2 << 30,
+			reduction:       0.8,
+			want:            0.8,
+			wantStatus:      HeapThrottleStatus{MaxSize: 1 << 30, Downsampled: 1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			throttle := newHeapThrottle(1<<30, tc.sampleReduction)
+			got, err := throttle.reduction(tc.heapSize, tc.reduction)
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("reduction() error = %v, want %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("reduction() = %v, want %v", got, tc.want)
+			}
+			if got := throttle.status(); got != tc.wantStatus {
+				t.Errorf("status() = %+v, want %+v", got, tc.wantStatus)
+			}
+		})
+	}
+}
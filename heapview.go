@@ -0,0 +1,45 @@
+package autopprof
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/google/pprof/profile"
+)
+
+// fullHeapSampleTypes are the views of the heap profile reported by
+// the full heap capture.
+var fullHeapSampleTypes = []string{
+	"inuse_space",
+	"inuse_objects",
+	"alloc_space",
+}
+
+// heapViews parses the heap profile and returns the copies of it
+// whose default sample type is set to each of the sample types, so
+// the pprof tools open each copy in the given view.
+func heapViews(b []byte, sampleTypes []string) ([][]byte, error) {
+	p, err := profile.Parse(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	views := make([][]byte, 0, len(sampleTypes))
+	for _, typ := range sampleTypes {
+		p.DefaultSampleType = typ
+		var buf bytes.Buffer
+		if err := p.Write(&buf); err != nil {
+			return nil, err
+		}
+		views = append(views, buf.Bytes())
+	}
+	return views, nil
+}
+
+// newTriggerID returns the random id shared by the reports of
+// a trigger.
+func newTriggerID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b) // It never fails on the supported platforms.
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,38 @@
+package autopprof
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+func TestHeapViews(t *testing.T) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("heap").WriteTo(&buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	views, err := heapViews(buf.Bytes(), fullHeapSampleTypes)
+	if err != nil {
+		t.Fatalf("heapViews() = %v, want nil", err)
+	}
+	if len(views) != len(fullHeapSampleTypes) {
+		t.Fatalf("len(heapViews()) = %d, want %d", len(views), len(fullHeapSampleTypes))
+	}
+	for i, view := range views {
+		p, err := profile.Parse(bytes.NewReader(view))
+		if err != nil {
+			t.Fatalf("profile.Parse() = %v, want nil", err)
+		}
+		if p.DefaultSampleType != fullHeapSampleTypes[i] {
+			t.Errorf("DefaultSampleType = %s, want %s", p.DefaultSampleType, fullHeapSampleTypes[i])
+		}
+	}
+}
+
+func TestHeapViews_invalid(t *testing.T) {
+	if _, err := heapViews([]byte("prof"), fullHeapSampleTypes); err == nil {
+		t.Errorf("heapViews() = nil, want error")
+	}
+}
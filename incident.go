@@ -0,0 +1,73 @@
+package autopprof
+
+import (
+	"sync"
+	"time"
+)
+
+// incident groups the breaches within the window since the last one
+// into an incident. (See Option.IncidentWindow)
+// A nil incident groups nothing.
+type incident struct {
+	mu     sync.Mutex
+	window time.Duration
+
+	id       string
+	openedAt time.Time
+	lastAt   time.Time
+	reports  int
+}
+
+// newIncident returns the incident of the window. It returns nil if
+// the window isn't positive.
+func newIncident(window time.Duration) *incident {
+	if window <= 0 {
+		return nil
+	}
+	return &incident{
+		window: window,
+	}
+}
+
+// join returns the id of the incident the breach at the now joins.
+// It opens a new incident if there's no breach within the window.
+func (in *incident) join(now time.Time) string {
+	if in == nil {
+		return ""
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if !in.openLocked(now) {
+		in.id, in.openedAt, in.reports = newTriggerID(), now, 0
+	}
+	in.lastAt = now
+	in.reports++
+	return in.id
+}
+
+// status returns the status of the incident open at the now.
+func (in *incident) status(now time.Time) IncidentStatus {
+	if in == nil {
+		return IncidentStatus{}
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if !in.openLocked(now) {
+		return IncidentStatus{}
+	}
+	return IncidentStatus{
+		Open:         true,
+		ID:           in.id,
+		OpenedAt:     in.openedAt,
+		LastBreachAt: in.lastAt,
+		Reports:      in.reports,
+	}
+}
+
+// openLocked reports whether the incident is open at the now.
+// The in.mu must be held.
+func (in *incident) openLocked(now time.Time) bool {
+	return in.id != "" && now.Sub(in.lastAt) < in.window
+}
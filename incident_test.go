@@ -0,0 +1,57 @@
+package autopprof
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIncident(t *testing.T) {
+	// A nil incident groups nothing.
+	var nilIncident *incident
+	if id := nilIncident.join(time.Now()); id != "" {
+		t.Errorf("join() of nil = %q, want empty", id)
+	}
+	if st := nilIncident.status(time.Now()); st.Open {
+		t.Errorf("status() of nil = %+v, want closed", st)
+	}
+
+	var (
+		now = time.Now()
+		in  = newIncident(time.Minute)
+	)
+	if st := in.status(now); st.Open {
+		t.Errorf("status() before the breach = %+v, want closed", st)
+	}
+
+	first := in.join(now)
+	if first == "" {
+		t.Fatalf("join() = empty, want the id")
+	}
+	// The breach within the window since the last one joins the incident,
+	//  even if it's out of the window since the first one.
+	if id := in.join(now.Add(50 * time.Second)); id != first {
+		t.Errorf("join() within the window = %q, want %q", id, first)
+	}
+	if id := in.join(now.Add(100 * time.Second)); id != first {
+		t.Errorf("join() within the window = %q, want %q", id, first)
+	}
+	st := in.status(now.Add(100 * time.Second))
+	want := IncidentStatus{
+		Open:         true,
+		ID:           first,
+		OpenedAt:     now,
+		LastBreachAt: now.Add(100 * time.Second),
+		Reports:      3,
+	}
+	if st != want {
+		t.Errorf("status() = %+v, want %+v", st, want)
+	}
+
+	// The incident is closed after the window.
+	if st := in.status(now.Add(160 * time.Second)); st.Open {
+		t.Errorf("status() after the window = %+v, want closed", st)
+	}
+	if id := in.join(now.Add(160 * time.Second)); id == first || id == "" {
+		t.Errorf("join() after the window = %q, want a new id", id)
+	}
+}
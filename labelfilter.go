@@ -0,0 +1,64 @@
+package autopprof
+
+import (
+	"bytes"
+
+	"github.com/google/pprof/profile"
+)
+
+// filterProfileByLabels parses the profile and keeps only the samples
+// of all of the pprof labels, which are set by the pprof.Do of
+// the application. It returns the filtered profile and the share of
+// the last sample type (e.g. cpu nanoseconds for the cpu profile) of
+// the kept samples between 0 and 1.
+func filterProfileByLabels(b []byte, labels map[string]string) ([]byte, float64, error) {
+	p, err := profile.Parse(bytes.NewReader(b))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(p.SampleType) == 0 {
+		return b, 0, nil
+	}
+	idx := len(p.SampleType) - 1
+
+	var (
+		total, kept int64
+		samples     = p.Sample[:0]
+	)
+	for _, s := range p.Sample {
+		total += s.Value[idx]
+		if !sampleHasLabels(s, labels) {
+			continue
+		}
+		kept += s.Value[idx]
+		samples = append(samples, s)
+	}
+	p.Sample = samples
+
+	var share float64
+	if total > 0 {
+		share = float64(kept) / float64(total)
+	}
+	var buf bytes.Buffer
+	if err := p.Compact().Write(&buf); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), share, nil
+}
+
+// sampleHasLabels reports whether the sample has all of the labels.
+func sampleHasLabels(s *profile.Sample, labels map[string]string) bool {
+	for k, v := range labels {
+		found := false
+		for _, sv := range s.Label[k] {
+			if sv == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package autopprof
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+func TestFilterProfileByLabels(t *testing.T) {
+	var (
+		fnWorker = &profile.Function{ID: 1, Name: "main.work"}
+		fnHandle = &profile.Function{ID: 2, Name: "main.handle"}
+
+		locWorker = &profile.Location{ID: 1, Line: []profile.Line{{Function: fnWorker}}}
+		locHandle = &profile.Location{ID: 2, Line: []profile.Line{{Function: fnHandle}}}
+	)
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		Sample: []*profile.Sample{
+			{
+				Location: []*profile.Location{locWorker},
+				Value:    []int64{2, 20},
+				Label:    map[string][]string{"subsystem": {"background-worker"}, "queue": {"mail"}},
+			},
+			{
+				Location: []*profile.Location{locWorker},
+				Value:    []int64{1, 10},
+				Label:    map[string][]string{"subsystem": {"background-worker"}, "queue": {"push"}},
+			},
+			{
+				Location: []*profile.Location{locHandle},
+				Value:    []int64{7, 70},
+				Label:    map[string][]string{"subsystem": {"api"}},
+			},
+		},
+		Location: []*profile.Location{locWorker, locHandle},
+		Function: []*profile.Function{fnWorker, fnHandle},
+	}
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name        string
+		labels      map[string]string
+		wantSamples int
+		wantShare   float64
+	}{
+		{
+			name:        "a label",
+			labels:      map[string]string{"subsystem": "background-worker"},
+			wantSamples: 2,
+			wantShare:   0.3,
+		},
+		{
+			name:        "all of the labels",
+			labels:      map[string]string{"subsystem": "background-worker", "queue": "mail"},
+			wantSamples: 1,
+			wantShare:   0.2,
+		},
+		{
+			name:        "no match",
+			labels:      map[string]string{"subsystem": "cron"},
+			wantSamples: 0,
+			wantShare:   0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, share, err := filterProfileByLabels(buf.Bytes(), tc.labels)
+			if err != nil {
+				t.Fatalf("filterProfileByLabels() = %v, want nil", err)
+			}
+			if share != tc.wantShare {
+				t.Errorf("share = %v, want %v", share, tc.wantShare)
+			}
+			fp, err := profile.Parse(bytes.NewReader(b))
+			if err != nil {
+				t.Fatalf("profile.Parse() = %v, want nil", err)
+			}
+			if len(fp.Sample) != tc.wantSamples {
+				t.Errorf("len of samples = %d, want %d", len(fp.Sample), tc.wantSamples)
+			}
+		})
+	}
+}
+
+func TestFilterProfileByLabels_invalidProfile(t *testing.T) {
+	if _, _, err := filterProfileByLabels([]byte("invalid"), map[string]string{"k": "v"}); err == nil {
+		t.Errorf("filterProfileByLabels() = nil, want error")
+	}
+}
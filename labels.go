@@ -0,0 +1,74 @@
+package autopprof
+
+import (
+	"os"
+
+	"github.com/looko-corp/autopprof/report"
+)
+
+// Env vars of the Kubernetes, exposed by the downward API.
+const (
+	envPodName      = "POD_NAME"
+	envPodNamespace = "POD_NAMESPACE"
+	envNodeName     = "NODE_NAME"
+)
+
+// reportLabels returns the labels of the reports. (See Option.Labels)
+// It's nil if there are none.
+func reportLabels(
+	labels map[string]string, kubernetes bool, envVars []string,
+	getenv func(string) string, hostname func() (string, error),
+) map[string]string {
+	merged := make(map[string]string)
+	if kubernetes {
+		for k, v := range kubernetesLabels(getenv, hostname) {
+			merged[k] = v
+		}
+	}
+	// Only the allowlisted env vars, never the whole environment.
+	for _, name := range envVars {
+		if v := getenv(name); v != "" {
+			merged[name] = v
+		}
+	}
+	// The labels set explicitly take precedence.
+	for k, v := range labels {
+		merged[k] = v
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// kubernetesLabels returns the labels of the pod from the env vars.
+// The pod is the hostname without the POD_NAME, since the hostname of
+// the pod is its name by default.
+func kubernetesLabels(
+	getenv func(string) string, hostname func() (string, error),
+) map[string]string {
+	labels := make(map[string]string)
+	pod := getenv(envPodName)
+	if pod == "" {
+		pod, _ = hostname() // Don't care about this error.
+	}
+	if pod != "" {
+		labels[report.LabelPod] = pod
+	}
+	if ns := getenv(envPodNamespace); ns != "" {
+		labels[report.LabelNamespace] = ns
+	}
+	if node := getenv(envNodeName); node != "" {
+		labels[report.LabelNode] = node
+	}
+	return labels
+}
+
+// defaultReportLabels returns the labels of the reports of the option
+// from the env vars of the process.
+func defaultReportLabels(opt Option) map[string]string {
+	return reportLabels(
+		opt.Labels, opt.KubernetesLabels, opt.IncludeEnvVars,
+		os.Getenv, os.Hostname,
+	)
+}
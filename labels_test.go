@@ -0,0 +1,93 @@
+package autopprof
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/looko-corp/autopprof/report"
+)
+
+func TestReportLabels(t *testing.T) {
+	testCases := []struct {
+		name       string
+		labels     map[string]string
+		kubernetes bool
+		envVars    []string
+		env        map[string]string
+		hostname   string
+		want       map[string]string
+	}{
+		{
+			name: "none",
+			want: nil,
+		},
+		{
+			name:   "labels only",
+			labels: map[string]string{"version": "v1.2.3"},
+			env:    map[string]string{envPodName: "api-7d9f"},
+			want:   map[string]string{"version": "v1.2.3"},
+		},
+		{
+			name:       "kubernetes",
+			kubernetes: true,
+			env: map[string]string{
+				envPodName:      "api-7d9f",
+				envPodNamespace: "prod",
+				envNodeName:     "node-1",
+			},
+			want: map[string]string{
+				report.LabelPod:       "api-7d9f",
+				report.LabelNamespace: "prod",
+				report.LabelNode:      "node-1",
+			},
+		},
+		{
+			name:       "kubernetes without the env vars",
+			kubernetes: true,
+			hostname:   "api-7d9f",
+			want:       map[string]string{report.LabelPod: "api-7d9f"},
+		},
+		{
+			name:    "env vars",
+			envVars: []string{"APP_VERSION", "DEPLOY_ID", "REGION"},
+			env: map[string]string{
+				"APP_VERSION": "v1.2.3",
+				"DEPLOY_ID":   "d-42",
+				"SECRET":      "hunter2",
+			},
+			want: map[string]string{
+				"APP_VERSION": "v1.2.3",
+				"DEPLOY_ID":   "d-42",
+			},
+		},
+		{
+			name:       "labels take precedence",
+			labels:     map[string]string{report.LabelNamespace: "staging"},
+			kubernetes: true,
+			env: map[string]string{
+				envPodName:      "api-7d9f",
+				envPodNamespace: "prod",
+			},
+			want: map[string]string{
+				report.LabelPod:       "api-7d9f",
+				report.LabelNamespace: "staging",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			getenv := func(key string) string { return tc.env[key] }
+			hostname := func() (string, error) {
+				if tc.hostname == "" {
+					return "", errors.New("no hostname")
+				}
+				return tc.hostname, nil
+			}
+			got := reportLabels(tc.labels, tc.kubernetes, tc.envVars, getenv, hostname)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("reportLabels() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
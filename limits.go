@@ -0,0 +1,57 @@
+package autopprof
+
+import (
+	"sync"
+)
+
+// cgroupLimits keeps the cgroup limits last read from the queryer, so
+// the reports carry the limits in effect at the capture rather than at
+// the start, e.g. after the resize by the VPA. The cpu quota is re-read
+// at each watch of the cpu usage, and the memory limit at each read of
+// the memory usage, so they're at most a watch interval old. A nil
+// cgroupLimits keeps nothing.
+type cgroupLimits struct {
+	mu sync.Mutex
+	// cpuQuota is the cpu quota in cores the cpu usage is computed
+	//  against, updated by each watch of the cpu usage.
+	cpuQuota float64
+	// memLimit is the memory limit in bytes, updated by each read of
+	//  the memory usage.
+	memLimit uint64
+}
+
+func newCgroupLimits() *cgroupLimits {
+	return &cgroupLimits{}
+}
+
+func (l *cgroupLimits) setCPUQuota(cpuQuota float64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cpuQuota = cpuQuota
+}
+
+func (l *cgroupLimits) setMemLimit(memLimit uint64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.memLimit = memLimit
+}
+
+// get returns the cpu quota in cores and the memory limit in bytes.
+// They're zero if unknown.
+func (l *cgroupLimits) get() (cpuQuota float64, memLimit uint64) {
+	if l == nil {
+		return 0, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.cpuQuota, l.memLimit
+}
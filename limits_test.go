@@ -0,0 +1,24 @@
+package autopprof
+
+import (
+	"testing"
+)
+
+func TestCgroupLimits(t *testing.T) {
+	// A nil cgroupLimits keeps nothing.
+	var nilLimits *cgroupLimits
+	nilLimits.setCPUQuota(2)
+	nilLimits.setMemLimit(1 << 30)
+	if cpuQuota, memLimit := nilLimits.get(); cpuQuota != 0 || memLimit != 0 {
+		t.Errorf("get() of nil = %v, %d, want zeros", cpuQuota, memLimit)
+	}
+
+	limits := newCgroupLimits()
+	limits.setCPUQuota(2)
+	limits.setMemLimit(1 << 30)
+	// Resized by the VPA.
+	limits.setMemLimit(2 << 30)
+	if cpuQuota, memLimit := limits.get(); cpuQuota != 2 || memLimit != 2<<30 {
+		t.Errorf("get() = %v, %d, want 2, %d", cpuQuota, memLimit, 2<<30)
+	}
+}
@@ -0,0 +1,44 @@
+package autopprof
+
+import "sync/atomic"
+
+// Logger is the logger of the diagnostics of the autopprof, e.g.
+// the failures of the watchers and the reports. The *log.Logger
+// satisfies it, so does an adapter of a structured logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+	Println(v ...interface{})
+}
+
+// nopLogger discards the logs.
+type nopLogger struct{}
+
+func (nopLogger) Printf(string, ...interface{}) {}
+func (nopLogger) Println(...interface{})        {}
+
+// loggerHolder holds the Logger in the atomic.Value, which needs
+// the same concrete type for all the stores.
+type loggerHolder struct {
+	Logger
+}
+
+// currentLogger is the Logger of the Option.Logger of the last
+// successful Start.
+var currentLogger atomic.Value
+
+// setLogger sets the logger of the autopprof. The nil logger discards
+// the logs.
+func setLogger(l Logger) {
+	if l == nil {
+		l = nopLogger{}
+	}
+	currentLogger.Store(loggerHolder{l})
+}
+
+// logger returns the logger of the autopprof.
+func logger() Logger {
+	if h, ok := currentLogger.Load().(loggerHolder); ok {
+		return h.Logger
+	}
+	return nopLogger{}
+}
@@ -0,0 +1,56 @@
+package autopprof
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+// captureLog sets the logger writing to the returned buffer until
+// the end of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	prev := logger()
+	var buf bytes.Buffer
+	setLogger(log.New(&buf, "", 0))
+	t.Cleanup(func() { setLogger(prev) })
+	return &buf
+}
+
+func TestSetLogger(t *testing.T) {
+	prev := logger()
+	t.Cleanup(func() { setLogger(prev) })
+
+	setLogger(nil)
+	if _, ok := logger().(nopLogger); !ok {
+		t.Errorf("logger() = %T, want the nopLogger for nil", logger())
+	}
+
+	var buf bytes.Buffer
+	setLogger(log.New(&buf, "", 0))
+	logger().Printf("autopprof: %s", "logged")
+	if got := buf.String(); got != "autopprof: logged\n" {
+		t.Errorf("logged = %q, want %q", got, "autopprof: logged\n")
+	}
+}
+
+func TestStart_failureKeepsLogger(t *testing.T) {
+	buf := captureLog(t)
+
+	var other bytes.Buffer
+	err := Start(Option{
+		CPUThreshold: 1.5,
+		Logger:       log.New(&other, "", 0),
+	})
+	if err == nil {
+		Stop()
+		t.Fatalf("Start() = nil, want the error of the option")
+	}
+	logger().Println("autopprof: logged")
+	if got := buf.String(); got != "autopprof: logged\n" {
+		t.Errorf("logged = %q, want %q by the previous logger", got, "autopprof: logged\n")
+	}
+	if other.Len() != 0 {
+		t.Errorf("logged %q by the logger of the failed Start, want none", other.String())
+	}
+}
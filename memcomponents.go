@@ -0,0 +1,140 @@
+package autopprof
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MemUsageComponents is the set of the memory fields of the cgroup
+// summed into the memory usage. Zero means the working set.
+// (usage - inactive_file)
+type MemUsageComponents int
+
+const (
+	// MemUsageRSS is the anonymous memory. (rss of the v1, anon of
+	//  the v2)
+	MemUsageRSS MemUsageComponents = 1 << iota
+	// MemUsageActiveFile is the active page cache, which is hard for
+	//  the kernel to reclaim.
+	MemUsageActiveFile
+	// MemUsageInactiveFile is the inactive page cache, which is
+	//  reclaimed first under the memory pressure.
+	MemUsageInactiveFile
+	// MemUsageSwap is the memory swapped out.
+	MemUsageSwap
+
+	// MemUsageCache is the whole page cache.
+	MemUsageCache = MemUsageActiveFile | MemUsageInactiveFile
+
+	// memUsageAll is all of the components.
+	memUsageAll = MemUsageRSS | MemUsageCache | MemUsageSwap
+)
+
+// memUsageComponentNames are the names of the components in order.
+var memUsageComponentNames = []struct {
+	component MemUsageComponents
+	name      string
+}{
+	{MemUsageRSS, "rss"},
+	{MemUsageActiveFile, "active_file"},
+	{MemUsageInactiveFile, "inactive_file"},
+	{MemUsageSwap, "swap"},
+}
+
+// String returns the names of the components joined by the "+".
+// (e.g. rss+swap) Zero is the "working_set".
+func (c MemUsageComponents) String() string {
+	if c == 0 {
+		return "working_set"
+	}
+	var names []string
+	for _, n := range memUsageComponentNames {
+		if c&n.component != 0 {
+			names = append(names, n.name)
+		}
+	}
+	if c&^memUsageAll != 0 {
+		names = append(names, "unknown")
+	}
+	return strings.Join(names, "+")
+}
+
+// UnmarshalText parses the names of the components joined by the "+",
+// so it's loaded from the config by the LoadOption. The "cache" is
+// the active_file+inactive_file.
+func (c *MemUsageComponents) UnmarshalText(text []byte) error {
+	if string(text) == "working_set" {
+		*c = 0
+		return nil
+	}
+	var components MemUsageComponents
+	for _, name := range strings.Split(string(text), "+") {
+		component, ok := memUsageComponentOf(strings.TrimSpace(name))
+		if !ok {
+			return fmt.Errorf("autopprof: unknown memory usage component: %q", name)
+		}
+		components |= component
+	}
+	*c = components
+	return nil
+}
+
+func memUsageComponentOf(name string) (MemUsageComponents, bool) {
+	if name == "cache" {
+		return MemUsageCache, true
+	}
+	for _, n := range memUsageComponentNames {
+		if n.name == name {
+			return n.component, true
+		}
+	}
+	return 0, false
+}
+
+// memFields are the memory fields of the cgroup the usage is summed
+// from by the MemUsageComponents.
+type memFields struct {
+	rss          uint64
+	activeFile   uint64
+	inactiveFile uint64
+	swap         uint64
+}
+
+// sum returns the sum of the fields of the components.
+func (f *memFields) sum(c MemUsageComponents) uint64 {
+	var sum uint64
+	for _, v := range f.values(c) {
+		sum += v
+	}
+	return sum
+}
+
+// values returns the bytes of the components by their names.
+func (f *memFields) values(c MemUsageComponents) map[string]uint64 {
+	values := make(map[string]uint64)
+	for _, n := range memUsageComponentNames {
+		if c&n.component == 0 {
+			continue
+		}
+		switch n.component {
+		case MemUsageRSS:
+			values[n.name] = f.rss
+		case MemUsageActiveFile:
+			values[n.name] = f.activeFile
+		case MemUsageInactiveFile:
+			values[n.name] = f.inactiveFile
+		case MemUsageSwap:
+			values[n.name] = f.swap
+		}
+	}
+	return values
+}
+
+// swapV1 returns the swap bytes of the cgroup v1, which only reports
+// the memory+swap usage. (memory.memsw.usage_in_bytes)
+func swapV1(memsw, usage uint64) uint64 {
+	if memsw <= usage {
+		return 0
+	}
+	return memsw - usage
+}
@@ -0,0 +1,159 @@
+package autopprof
+
+import "fmt"
+
+// memUnlimited is the lower bound of the memory limit that is treated
+// as unlimited. The cgroup reports a huge value if there's no limit.
+// (v1: 0x7FFFFFFFFFFFF000, v2: math.MaxUint64)
+const memUnlimited = 1 << 62
+
+// workingSet returns the usage minus the inactive file pages. It's
+// clamped to zero if the inactive file pages exceed the usage by
+// the accounting skew, instead of wrapping around to the huge usage
+// which triggers the heap profiling falsely.
+func workingSet(usage, inactiveFile uint64) uint64 {
+	if inactiveFile > usage {
+		logger().Printf(
+			"autopprof: inactive file (%d bytes) exceeds the memory usage (%d bytes), clamp the working set to zero",
+			inactiveFile, usage,
+		)
+		return 0
+	}
+	return usage - inactiveFile
+}
+
+// memStat is the memory usage stat of the cgroup.
+type memStat struct {
+	// usage is the working set size in bytes. (usage - inactive_file)
+	usage uint64
+	// limit is the memory limit in bytes.
+	limit uint64
+
+	// kmem is the kernel memory bytes. (e.g. the socket buffers and
+	//  the dentry cache) Zero if the kmem accounting is disabled.
+	kmem uint64
+	// kmemInUsage reports whether the usage already includes the kmem.
+	kmemInUsage bool
+
+	// goUsage is the memory bytes mapped by the Go runtime.
+	goUsage uint64
+	// goLimit is the GOMEMLIMIT in bytes. Zero means it's not set.
+	goLimit uint64
+
+	// numa is the usages per NUMA node. It's nil unless
+	//  the NUMAThreshold is set.
+	numa []numaNode
+
+	// fields are the memory fields the usage is summed from by
+	//  the MemUsageComponents. It's nil if the queryer doesn't read
+	//  them. (e.g. gVisor)
+	fields *memFields
+}
+
+// numaNode is the memory usage of the cgroup on a NUMA node.
+type numaNode struct {
+	node int
+	// usage is the memory bytes of the cgroup on the node.
+	usage uint64
+	// total is the memory bytes of the node.
+	total uint64
+}
+
+// ratio returns the ratio of the usage to the memory of the node.
+func (n numaNode) ratio() float64 {
+	if n.total == 0 {
+		return 0
+	}
+	return float64(n.usage) / float64(n.total)
+}
+
+// includeKmem adds the kmem to the usage unless it's already included.
+func (s *memStat) includeKmem() {
+	if s.kmemInUsage {
+		return
+	}
+	s.usage += s.kmem
+	s.kmemInUsage = true
+}
+
+// ratio returns the ratio of the working set to the memory limit.
+func (s *memStat) ratio() float64 {
+	return float64(s.usage) / float64(s.limit)
+}
+
+// goRatio returns the ratio of the memory mapped by the Go runtime
+// to the GOMEMLIMIT. It's zero if the GOMEMLIMIT isn't set.
+func (s *memStat) goRatio() float64 {
+	if s.goLimit == 0 {
+		return 0
+	}
+	return float64(s.goUsage) / float64(s.goLimit)
+}
+
+// ratioOf returns the ratio computed against the limit of the mode.
+func (s *memStat) ratioOf(mode MemLimitMode) float64 {
+	switch mode {
+	case MemLimitGo:
+		if s.goLimit == 0 {
+			return s.ratio()
+		}
+		return s.goRatio()
+	case MemLimitBoth:
+		if r := s.goRatio(); r > s.ratio() {
+			return r
+		}
+	}
+	return s.ratio()
+}
+
+// available returns the available memory bytes until the limit.
+func (s *memStat) available() uint64 {
+	if s.usage >= s.limit {
+		return 0
+	}
+	return s.limit - s.usage
+}
+
+// limited reports whether the memory limit is set.
+func (s *memStat) limited() bool {
+	return s.limit > 0 && s.limit < memUnlimited
+}
+
+// unlimitedMemTrigger triggers the heap profiling by the working set
+// bytes when no memory limit is detected, since the ratio to the limit
+// is meaningless then. It's the absolute threshold if it's set, or
+// the growth of the working set over the baseline.
+type unlimitedMemTrigger struct {
+	absolute uint64
+	growth   float64
+
+	// baseline is the working set the growth is relative to. It's
+	//  the first one, and moves to the one of each report so
+	//  the next growth is reported again.
+	baseline uint64
+}
+
+// breached reports whether the working set is over the threshold.
+func (t *unlimitedMemTrigger) breached(usage uint64) bool {
+	if t.absolute > 0 {
+		return usage >= t.absolute
+	}
+	if t.baseline == 0 {
+		t.baseline = usage
+		return false
+	}
+	return float64(usage) >= float64(t.baseline)*(1+t.growth)
+}
+
+// reported moves the baseline to the working set of the report.
+func (t *unlimitedMemTrigger) reported(usage uint64) {
+	t.baseline = usage
+}
+
+// String describes the threshold for the log.
+func (t *unlimitedMemTrigger) String() string {
+	if t.absolute > 0 {
+		return fmt.Sprintf("the absolute threshold of %d bytes", t.absolute)
+	}
+	return fmt.Sprintf("the growth threshold of %.0f%%", t.growth*100)
+}
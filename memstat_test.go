@@ -0,0 +1,196 @@
+package autopprof
+
+import "testing"
+
+func TestMemStat_ratioOf(t *testing.T) {
+	testCases := []struct {
+		name string
+		stat memStat
+		mode MemLimitMode
+		want float64
+	}{
+		{
+			name: "cgroup",
+			stat: memStat{usage: 5, limit: 10, goUsage: 8, goLimit: 10},
+			mode: MemLimitCgroup,
+			want: 0.5,
+		},
+		{
+			name: "go",
+			stat: memStat{usage: 5, limit: 10, goUsage: 8, goLimit: 10},
+			mode: MemLimitGo,
+			want: 0.8,
+		},
+		{
+			name: "go without the GOMEMLIMIT",
+			stat: memStat{usage: 5, limit: 10, goUsage: 8},
+			mode: MemLimitGo,
+			want: 0.5,
+		},
+		{
+			name: "both uses the higher one",
+			stat: memStat{usage: 9, limit: 10, goUsage: 8, goLimit: 10},
+			mode: MemLimitBoth,
+			want: 0.9,
+		},
+		{
+			name: "both uses the higher one of go",
+			stat: memStat{usage: 5, limit: 10, goUsage: 8, goLimit: 10},
+			mode: MemLimitBoth,
+			want: 0.8,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.stat.ratioOf(tc.mode); got != tc.want {
+				t.Errorf("ratioOf() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMemStat_includeKmem(t *testing.T) {
+	testCases := []struct {
+		name string
+		stat memStat
+		want uint64
+	}{
+		{
+			name: "kmem is added",
+			stat: memStat{usage: 5, limit: 10, kmem: 2},
+			want: 7,
+		},
+		{
+			name: "kmem is already in the usage",
+			stat: memStat{usage: 5, limit: 10, kmem: 2, kmemInUsage: true},
+			want: 5,
+		},
+		{
+			name: "kmem accounting is disabled",
+			stat: memStat{usage: 5, limit: 10},
+			want: 5,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.stat.includeKmem()
+			tc.stat.includeKmem() // Expect no double counting.
+			if tc.stat.usage != tc.want {
+				t.Errorf("usage = %d, want %d", tc.stat.usage, tc.want)
+			}
+		})
+	}
+}
+
+func TestWorkingSet(t *testing.T) {
+	testCases := []struct {
+		name         string
+		usage        uint64
+		inactiveFile uint64
+		want         uint64
+	}{
+		{
+			name:         "inactive file is subtracted",
+			usage:        10,
+			inactiveFile: 4,
+			want:         6,
+		},
+		{
+			name:         "inactive file exceeds the usage",
+			usage:        4,
+			inactiveFile: 10,
+			want:         0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := workingSet(tc.usage, tc.inactiveFile)
+			if got != tc.want {
+				t.Errorf("workingSet() = %d, want %d", got, tc.want)
+			}
+			stat := memStat{usage: got, limit: 10}
+			if ratio := stat.ratio(); ratio < 0 || ratio > 1 {
+				t.Errorf("ratio() = %v, want between 0 and 1", ratio)
+			}
+		})
+	}
+}
+
+func TestUnlimitedMemTrigger(t *testing.T) {
+	testCases := []struct {
+		name    string
+		trigger unlimitedMemTrigger
+		usages  []uint64
+		want    []bool
+	}{
+		{
+			name:    "absolute",
+			trigger: unlimitedMemTrigger{absolute: 100, growth: 0.5},
+			usages:  []uint64{50, 100, 200},
+			want:    []bool{false, true, true},
+		},
+		{
+			name:    "growth",
+			trigger: unlimitedMemTrigger{growth: 0.5},
+			usages:  []uint64{100, 140, 150},
+			want:    []bool{false, false, true},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for i, usage := range tc.usages {
+				if got := tc.trigger.breached(usage); got != tc.want[i] {
+					t.Errorf("breached(%d) = %v, want %v", usage, got, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestUnlimitedMemTrigger_reported(t *testing.T) {
+	trigger := unlimitedMemTrigger{growth: 0.5}
+	trigger.breached(100)
+	if !trigger.breached(200) {
+		t.Fatalf("breached(200) = false, want true")
+	}
+	// The next growth is relative to the report.
+	trigger.reported(200)
+	if trigger.breached(250) {
+		t.Errorf("breached(250) = true, want false after the report")
+	}
+	if !trigger.breached(300) {
+		t.Errorf("breached(300) = false, want true after the report")
+	}
+}
+
+func TestMemUsageComponents_UnmarshalText(t *testing.T) {
+	testCases := []struct {
+		text    string
+		want    MemUsageComponents
+		wantErr bool
+	}{
+		{text: "working_set", want: 0},
+		{text: "rss", want: MemUsageRSS},
+		{text: "rss+cache", want: MemUsageRSS | MemUsageActiveFile | MemUsageInactiveFile},
+		{text: "rss+swap", want: MemUsageRSS | MemUsageSwap},
+		{text: "rss+heap", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			var got MemUsageComponents
+			err := got.UnmarshalText([]byte(tc.text))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("UnmarshalText() = %v, want error %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got != tc.want {
+				t.Errorf("UnmarshalText() = %s, want %s", got, tc.want)
+			}
+			if tc.want != MemUsageRSS|MemUsageCache && got.String() != tc.text {
+				t.Errorf("String() = %s, want %s", got, tc.text)
+			}
+		})
+	}
+}
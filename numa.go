@@ -0,0 +1,172 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	cgroupNUMAStatFile = "memory.numa_stat"
+
+	// sysNodeDir is where the meminfo of the NUMA nodes are.
+	sysNodeDir = "/sys/devices/system/node"
+)
+
+// readNUMANodes reads the usages of the cgroup per NUMA node from
+// the numa_stat file by the parse, and the memory of the nodes from
+// the nodeDir. The nodes are sorted by the node id.
+// It returns ErrNUMAUnavailable if either doesn't exist, e.g. the kernel
+// without the NUMA support.
+func readNUMANodes(
+	statFile string, parse func(io.Reader) (map[int]uint64, error), nodeDir string,
+) ([]numaNode, error) {
+	f, err := os.Open(statFile)
+	if os.IsNotExist(err) {
+		return nil, ErrNUMAUnavailable
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	usages, err := parse(f)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]numaNode, 0, len(usages))
+	for node, usage := range usages {
+		total, err := readNodeMemTotal(nodeDir, node)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, numaNode{node: node, usage: usage, total: total})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].node < nodes[j].node
+	})
+	return nodes, nil
+}
+
+// parseNUMAStatV1 parses the memory.numa_stat of the cgroup v1, whose
+// "total" line is the pages of the cgroup per node:
+//
+//	total=1024 N0=512 N1=512
+//	file=256 N0=128 N1=128
+func parseNUMAStatV1(r io.Reader) (map[int]uint64, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "total=") {
+			continue
+		}
+		pages, err := parseNUMANodeFields(fields[1:])
+		if err != nil {
+			return nil, err
+		}
+		pageSize := uint64(os.Getpagesize())
+		for node := range pages {
+			pages[node] *= pageSize
+		}
+		return pages, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("autopprof: no total in %s", cgroupNUMAStatFile)
+}
+
+// parseNUMAStatV2 parses the memory.numa_stat of the cgroup v2, whose
+// lines are the bytes of each type per node. The usage is the sum of
+// the anon and the file:
+//
+//	anon N0=1048576 N1=0
+//	file N0=524288 N1=4096
+func parseNUMAStatV2(r io.Reader) (map[int]uint64, error) {
+	var (
+		usages = make(map[int]uint64)
+		found  bool
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || (fields[0] != "anon" && fields[0] != "file") {
+			continue
+		}
+		bytes, err := parseNUMANodeFields(fields[1:])
+		if err != nil {
+			return nil, err
+		}
+		for node, b := range bytes {
+			usages[node] += b
+		}
+		found = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("autopprof: no anon and file in %s", cgroupNUMAStatFile)
+	}
+	return usages, nil
+}
+
+// parseNUMANodeFields parses the N<node>=<value> fields.
+func parseNUMANodeFields(fields []string) (map[int]uint64, error) {
+	values := make(map[int]uint64, len(fields))
+	for _, field := range fields {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 || !strings.HasPrefix(kv[0], "N") {
+			continue
+		}
+		node, err := strconv.Atoi(strings.TrimPrefix(kv[0], "N"))
+		if err != nil {
+			return nil, err
+		}
+		v, err := strconv.ParseUint(kv[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		values[node] = v
+	}
+	return values, nil
+}
+
+// readNodeMemTotal reads the MemTotal of the meminfo of the node:
+//
+//	Node 0 MemTotal:       16384000 kB
+func readNodeMemTotal(nodeDir string, node int) (uint64, error) {
+	f, err := os.Open(path.Join(nodeDir, fmt.Sprintf("node%d", node), "meminfo"))
+	if os.IsNotExist(err) {
+		return 0, ErrNUMAUnavailable
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[2] != "MemTotal:" {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[3], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return kb * 1024, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("autopprof: no MemTotal of the node %d", node)
+}
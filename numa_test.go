@@ -0,0 +1,118 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseNUMAStatV1(t *testing.T) {
+	stat := "total=1024 N0=768 N1=256\n" +
+		"file=256 N0=128 N1=128\n" +
+		"hierarchical_total=2048 N0=1024 N1=1024\n"
+	got, err := parseNUMAStatV1(strings.NewReader(stat))
+	if err != nil {
+		t.Fatalf("parseNUMAStatV1() = %v, want nil", err)
+	}
+	pageSize := uint64(os.Getpagesize())
+	want := map[int]uint64{0: 768 * pageSize, 1: 256 * pageSize}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNUMAStatV1() = %v, want %v", got, want)
+	}
+
+	if _, err := parseNUMAStatV1(strings.NewReader("file=256 N0=256\n")); err == nil {
+		t.Errorf("parseNUMAStatV1() = nil, want error without the total")
+	}
+}
+
+func TestParseNUMAStatV2(t *testing.T) {
+	testCases := []struct {
+		name    string
+		stat    string
+		want    map[int]uint64
+		wantErr bool
+	}{
+		{
+			name: "anon and file",
+			stat: "anon N0=1000 N1=0\n" +
+				"file N0=500 N1=4096\n" +
+				"kernel_stack N0=16384 N1=0\n",
+			want: map[int]uint64{0: 1500, 1: 4096},
+		},
+		{
+			name:    "no anon and file",
+			stat:    "kernel_stack N0=16384 N1=0\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid value",
+			stat:    "anon N0=abc\n",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseNUMAStatV2(strings.NewReader(tc.stat))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseNUMAStatV2() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseNUMAStatV2() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadNUMANodes(t *testing.T) {
+	dir := t.TempDir()
+	statFile := filepath.Join(dir, cgroupNUMAStatFile)
+	if err := os.WriteFile(
+		statFile, []byte("anon N0=1024 N1=3072\nfile N0=0 N1=1024\n"), 0o644,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// The meminfo of the nodes is missing yet.
+	nodeDir := filepath.Join(dir, "node")
+	if _, err := readNUMANodes(statFile, parseNUMAStatV2, nodeDir); !errors.Is(err, ErrNUMAUnavailable) {
+		t.Errorf("readNUMANodes() = %v, want %v", err, ErrNUMAUnavailable)
+	}
+
+	for node, total := range []int{4, 8} {
+		nd := filepath.Join(nodeDir, fmt.Sprintf("node%d", node))
+		if err := os.MkdirAll(nd, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		meminfo := fmt.Sprintf(
+			"Node %d MemTotal:       %d kB\nNode %d MemFree:        1 kB\n",
+			node, total, node,
+		)
+		if err := os.WriteFile(filepath.Join(nd, "meminfo"), []byte(meminfo), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := readNUMANodes(statFile, parseNUMAStatV2, nodeDir)
+	if err != nil {
+		t.Fatalf("readNUMANodes() = %v, want nil", err)
+	}
+	want := []numaNode{
+		{node: 0, usage: 1024, total: 4 * 1024},
+		{node: 1, usage: 4096, total: 8 * 1024},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readNUMANodes() = %+v, want %+v", got, want)
+	}
+
+	if _, err := readNUMANodes(
+		filepath.Join(dir, "missing"), parseNUMAStatV2, nodeDir,
+	); !errors.Is(err, ErrNUMAUnavailable) {
+		t.Errorf("readNUMANodes() = %v, want %v", err, ErrNUMAUnavailable)
+	}
+}
@@ -0,0 +1,131 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ociDefaultCPUPeriod is the cpu period of the OCI runtime spec in
+// microseconds if it's not given.
+const ociDefaultCPUPeriod = 100000
+
+// ociSpec is the part of the OCI runtime spec (config.json) with
+// the resource limits of the container.
+type ociSpec struct {
+	Linux *struct {
+		Resources *struct {
+			Memory *struct {
+				Limit *int64 `json:"limit"`
+			} `json:"memory"`
+			CPU *struct {
+				Quota  *int64  `json:"quota"`
+				Period *uint64 `json:"period"`
+			} `json:"cpu"`
+		} `json:"resources"`
+	} `json:"linux"`
+}
+
+// readOCISpecLimits returns the memory limit in bytes and the cpu quota
+// in cores of the linux.resources of the OCI runtime spec at the path.
+// They're zero if they're absent or unlimited.
+func readOCISpecLimits(path string) (memLimit uint64, cpuQuota float64, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var spec ociSpec
+	if err := json.Unmarshal(b, &spec); err != nil {
+		return 0, 0, err
+	}
+	if spec.Linux == nil || spec.Linux.Resources == nil {
+		return 0, 0, nil
+	}
+	res := spec.Linux.Resources
+	if m := res.Memory; m != nil && m.Limit != nil && *m.Limit > 0 {
+		memLimit = uint64(*m.Limit)
+	}
+	if c := res.CPU; c != nil && c.Quota != nil && *c.Quota > 0 {
+		period := uint64(ociDefaultCPUPeriod)
+		if c.Period != nil && *c.Period > 0 {
+			period = *c.Period
+		}
+		cpuQuota = float64(*c.Quota) / float64(period)
+	}
+	return memLimit, cpuQuota, nil
+}
+
+// cpuQuotaOverrider is the queryer whose cpu quota can be given instead
+// of being read from the cgroup.
+type cpuQuotaOverrider interface {
+	overrideCPUQuota(quota float64)
+}
+
+// ociSpecQueryer takes the limits declared in the OCI runtime spec over
+// the ones of the inner queryer, since the cgroup files may differ
+// from the declared limits in the sandboxed runtimes. (e.g. gVisor,
+// Kata Containers) The usages are still read by the inner queryer.
+type ociSpecQueryer struct {
+	queryer
+
+	path     string
+	memLimit uint64
+	cpuQuota float64
+}
+
+// newOCISpecQueryer returns the ociSpecQueryer wrapping the inner
+// queryer with the limits of the OCI runtime spec at the path.
+// It returns the inner queryer as is if the spec has no limits, or
+// the spec doesn't exist so the limits of the cgroup are used.
+func newOCISpecQueryer(inner queryer, path string) (queryer, error) {
+	memLimit, cpuQuota, err := readOCISpecLimits(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return inner, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOCISpec, err)
+	}
+	if memLimit == 0 && cpuQuota == 0 {
+		return inner, nil
+	}
+	return &ociSpecQueryer{
+		queryer:  inner,
+		path:     path,
+		memLimit: memLimit,
+		cpuQuota: cpuQuota,
+	}, nil
+}
+
+func (q *ociSpecQueryer) setCPUQuota() error {
+	if o, ok := q.queryer.(cpuQuotaOverrider); ok && q.cpuQuota > 0 {
+		o.overrideCPUQuota(q.cpuQuota)
+		return nil
+	}
+	return q.queryer.setCPUQuota()
+}
+
+func (q *ociSpecQueryer) memUsage() (*memStat, error) {
+	stat, err := q.queryer.memUsage()
+	if err != nil {
+		return nil, err
+	}
+	if q.memLimit > 0 {
+		stat.limit = q.memLimit
+	}
+	return stat, nil
+}
+
+func (q *ociSpecQueryer) status() CgroupStatus {
+	s := q.queryer.status()
+	if _, ok := q.queryer.(cpuQuotaOverrider); ok && q.cpuQuota > 0 {
+		s.CPUQuotaFiles = []string{q.path}
+	}
+	if q.memLimit > 0 {
+		s.MemLimitSource = q.path
+	}
+	return s
+}
@@ -0,0 +1,127 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+)
+
+func writeOCISpec(t *testing.T, spec string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(spec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadOCISpecLimits(t *testing.T) {
+	testCases := []struct {
+		name         string
+		spec         string
+		wantMemLimit uint64
+		wantCPUQuota float64
+	}{
+		{
+			name: "memory and cpu",
+			spec: `{"linux": {"resources": {
+				"memory": {"limit": 536870912},
+				"cpu": {"quota": 150000, "period": 100000}
+			}}}`,
+			wantMemLimit: 536870912,
+			wantCPUQuota: 1.5,
+		},
+		{
+			name:         "default period",
+			spec:         `{"linux": {"resources": {"cpu": {"quota": 50000}}}}`,
+			wantCPUQuota: 0.5,
+		},
+		{
+			name: "unlimited",
+			spec: `{"linux": {"resources": {
+				"memory": {"limit": -1},
+				"cpu": {"quota": -1}
+			}}}`,
+		},
+		{
+			name: "no resources",
+			spec: `{"ociVersion": "1.0.2", "linux": {}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			memLimit, cpuQuota, err := readOCISpecLimits(writeOCISpec(t, tc.spec))
+			if err != nil {
+				t.Fatalf("readOCISpecLimits() = %v, want nil", err)
+			}
+			if memLimit != tc.wantMemLimit {
+				t.Errorf("memLimit = %d, want %d", memLimit, tc.wantMemLimit)
+			}
+			if cpuQuota != tc.wantCPUQuota {
+				t.Errorf("cpuQuota = %v, want %v", cpuQuota, tc.wantCPUQuota)
+			}
+		})
+	}
+}
+
+func TestNewOCISpecQueryer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockQueryer := NewMockqueryer(ctrl)
+
+	// The missing spec falls back to the cgroup.
+	q, err := newOCISpecQueryer(mockQueryer, filepath.Join(t.TempDir(), "config.json"))
+	if err != nil || q != mockQueryer {
+		t.Errorf("newOCISpecQueryer() = %v, %v, want the inner queryer", q, err)
+	}
+
+	if _, err := newOCISpecQueryer(mockQueryer, writeOCISpec(t, "{")); !errors.Is(err, ErrInvalidOCISpec) {
+		t.Errorf("newOCISpecQueryer() = %v, want %v", err, ErrInvalidOCISpec)
+	}
+}
+
+func TestOCISpecQueryer(t *testing.T) {
+	path := writeOCISpec(t, `{"linux": {"resources": {
+		"memory": {"limit": 1000},
+		"cpu": {"quota": 200000, "period": 100000}
+	}}}`)
+
+	cgv2 := &cgroupV2{}
+	q, err := newOCISpecQueryer(cgv2, path)
+	if err != nil {
+		t.Fatalf("newOCISpecQueryer() = %v, want nil", err)
+	}
+	if err := q.setCPUQuota(); err != nil {
+		t.Fatalf("setCPUQuota() = %v, want nil", err)
+	}
+	if cgv2.cpuQuota != 2 {
+		t.Errorf("cpuQuota = %v, want 2", cgv2.cpuQuota)
+	}
+	if s := q.status(); s.CPUQuota != 2 || s.MemLimitSource != path {
+		t.Errorf("status() = %+v, want the cpu quota 2 and the memory limit from %s", s, path)
+	}
+
+	ctrl := gomock.NewController(t)
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		memUsage().
+		Return(&memStat{usage: 500, limit: 1 << 62}, nil)
+
+	q, err = newOCISpecQueryer(mockQueryer, path)
+	if err != nil {
+		t.Fatalf("newOCISpecQueryer() = %v, want nil", err)
+	}
+	stat, err := q.memUsage()
+	if err != nil {
+		t.Fatalf("memUsage() = %v, want nil", err)
+	}
+	if got := stat.ratio(); got != 0.5 {
+		t.Errorf("memory usage = %v, want 0.5", got)
+	}
+}
@@ -1,8 +1,11 @@
 package autopprof
 
 import (
+	"text/template"
 	"time"
 
+	"github.com/google/pprof/profile"
+
 	"github.com/looko-corp/autopprof/report"
 )
 
@@ -12,52 +15,1024 @@ const (
 	defaultWatchInterval               = 5 * time.Second
 	defaultCPUProfilingDuration        = 10 * time.Second
 	defaultMinConsecutiveOverThreshold = 12 // min 1 minute. (12*5s)
+	defaultReporterFailureThreshold    = 5
+	defaultReporterCooldown            = 10 * time.Minute
+	defaultReportTimeout               = 5 * time.Second
+	defaultGoroutineDropMinCount       = 100
+	defaultMemGrowthThreshold          = 0.5
+	defaultLatencyBreachDebounce       = time.Minute
+	defaultErrorRateWindow             = time.Minute
+	defaultCPUQuotaRefreshInterval     = time.Minute
+
+	maxCPUProfileRate = 1000
 )
 
 // Option is the configuration for the autopprof.
 type Option struct {
 	// DisableCPUProf disables the CPU profiling.
-	DisableCPUProf bool
+	DisableCPUProf bool `json:"disable_cpu_prof" yaml:"disable_cpu_prof"`
 	// DisableMemProf disables the memory profiling.
-	DisableMemProf bool
+	DisableMemProf bool `json:"disable_mem_prof" yaml:"disable_mem_prof"`
+	// DisableGoroutineProf disables the goroutine profiling triggered
+	//  by the watchers. (the GoroutineThreshold, the FDThreshold,
+	//  the PIDThreshold and the GoroutineDropThreshold)
+	DisableGoroutineProf bool `json:"disable_goroutine_prof" yaml:"disable_goroutine_prof"`
 
 	// CPUThreshold is the cpu usage threshold (between 0 and 1)
 	//  to trigger the cpu profiling.
 	// Autopprof will start the cpu profiling when the cpu usage
 	//  is higher than this threshold.
-	CPUThreshold float64
+	// It's in the CPUUsageBasis, so it can be higher than 1 with
+	//  the CPUUsageSingleCore.
+	CPUThreshold float64 `json:"cpu_threshold" yaml:"cpu_threshold"`
 
 	// MemThreshold is the memory usage threshold (between 0 and 1)
 	//  to trigger the heap profiling.
 	// Autopprof will start the heap profiling when the memory usage
 	//  is higher than this threshold.
-	MemThreshold float64
+	MemThreshold float64 `json:"mem_threshold" yaml:"mem_threshold"`
+
+	// CPUWarnThreshold is the cpu usage threshold (between 0 and 1)
+	//  to emit the EventCPUWarning event. It must be lower than
+	//  the CPUThreshold.
+	// The warning event doesn't come with the profile, so it's a cheap
+	//  early warning before the cpu usage crosses the CPUThreshold.
+	// Default: 0. (means disabled)
+	CPUWarnThreshold float64 `json:"cpu_warn_threshold" yaml:"cpu_warn_threshold"`
+
+	// MemWarnThreshold is the memory usage threshold (between 0 and 1)
+	//  to emit the EventMemWarning event. It must be lower than
+	//  the MemThreshold.
+	// Default: 0. (means disabled)
+	MemWarnThreshold float64 `json:"mem_warn_threshold" yaml:"mem_warn_threshold"`
+
+	// WarnCooldown is the minimum duration between the warning events
+	//  of the same type. It's kept apart from the cooldown of
+	//  the reports, so the warnings aren't held back by the profiles
+	//  reported, and a usage wavering around the warning threshold
+	//  doesn't emit the warning on each crossing.
+	// Default: 0. (means MinConsecutiveOverThreshold * WatchInterval)
+	WarnCooldown time.Duration `json:"warn_cooldown" yaml:"warn_cooldown"`
+
+	// OnEvent is called with the event such as the warning.
+	// It's called in the watching goroutine, so it must not block.
+	OnEvent func(Event) `json:"-" yaml:"-"`
+
+	// RecoverPanics recovers the panics of the goroutines of
+	//  the autopprof, such as the watchers and the reports, so a bug of
+	//  the autopprof or the Reporter doesn't crash the application it
+	//  observes. The panic is logged with its stack, and counted in
+	//  the StatusInfo.Panics. The capture of the panicked report
+	//  returns ErrPanicked.
+	// RestartOnPanic restarts the watcher panicked and recovered by
+	//  the RecoverPanics after the watch interval. Otherwise,
+	//  the watcher stays stopped, which the HealthHandler reports.
+	// Default: false and false.
+	RecoverPanics  bool `json:"recover_panics" yaml:"recover_panics"`
+	RestartOnPanic bool `json:"restart_on_panic" yaml:"restart_on_panic"`
+
+	// MemLimitMode is the memory limit to compute the memory usage
+	//  against for the MemThreshold and the MemWarnThreshold.
+	// With the GOMEMLIMIT, the runtime manages the heap against the soft
+	//  limit which may differ from the memory limit of the cgroup.
+	//  The MemLimitGo and the MemLimitBoth align the usage with
+	//  the runtime's view. The GOMEMLIMIT is read at each watch, so
+	//  the changes by the debug.SetMemoryLimit are followed.
+	// Default: MemLimitCgroup.
+	MemLimitMode MemLimitMode `json:"mem_limit_mode" yaml:"mem_limit_mode"`
+
+	// CPUUsageBasis is the denominator to compute the cpu usage against
+	//  for the CPUThreshold and the CPUWarnThreshold, and the reported
+	//  report.CPUInfo.UsagePercentage.
+	// The CPUUsageQuota computes against the cpu quota, so 1.5 cores
+	//  with the 2 cores quota is 0.75. The CPUUsageSingleCore computes
+	//  the number of the cores in use, so it's 1.5 (150%). The
+	//  CPUUsageAllCores computes against the runtime.NumCPU, so it's
+	//  0.375 on the 4 cores host.
+	// Default: CPUUsageQuota.
+	CPUUsageBasis CPUUsageBasis `json:"cpu_usage_basis" yaml:"cpu_usage_basis"`
+
+	// CPUPressureThreshold is the cpu pressure threshold (between 0 and 1)
+	//  to trigger the cpu profiling regardless of the CPUThreshold.
+	// The cpu pressure is the "some avg10" of the cpu.pressure of
+	//  the cgroup v2, the share of the last 10s in which some tasks
+	//  were stalled waiting for the cpu. It catches the throttling and
+	//  the contention which the cpu usage doesn't show.
+	// It's ignored if the pressure stall information is unavailable.
+	//  (e.g. the cgroup v1 or the kernel without the PSI)
+	// Default: 0. (means disabled)
+	CPUPressureThreshold float64 `json:"cpu_pressure_threshold" yaml:"cpu_pressure_threshold"`
+
+	// WatchInterval is the interval to watch the resource usages
+	//  against the thresholds.
+	// The cpu usage is averaged over the last 24 watches, so the window
+	//  of the average scales with it, e.g. 2 minutes by default and
+	//  48s with 2s. So do the UsagePercentile window and the cooldown of
+	//  the MinConsecutiveOverThreshold watches.
+	// Default: 5s.
+	WatchInterval time.Duration `json:"watch_interval" yaml:"watch_interval"`
+
+	// MinConsecutiveOverThreshold is the number of the consecutive
+	//  watches over a threshold to report the profile again after
+	//  a report, so the lower one reports the spiky workloads more
+	//  aggressively and the higher one spams less.
+	// Default: 12. (1 minute with the default WatchInterval)
+	MinConsecutiveOverThreshold int `json:"min_consecutive_over_threshold" yaml:"min_consecutive_over_threshold"`
+
+	// SampleInterval is the interval to sample the cpu usage of
+	//  the cgroup, separate from the interval to evaluate it against
+	//  the thresholds. (WatchInterval) The cpu usage is averaged over
+	//  the samples of the last 24 watches, so the frequent sampling
+	//  follows the usage closely without evaluating the thresholds
+	//  more often. It must not be longer than the WatchInterval.
+	// Default: 0. (means the WatchInterval)
+	SampleInterval time.Duration `json:"sample_interval" yaml:"sample_interval"`
+
+	// CPUQuotaRefreshInterval is the interval to re-read the cpu quota
+	//  of the cgroup, so the cpu usage and the limits reported with
+	//  the profiles follow the resize of the container. (e.g. by the VPA)
+	//  The quota is read by the cpu watcher, so it's refreshed at most
+	//  once per watch.
+	// Default: 0. (means 1 minute)
+	CPUQuotaRefreshInterval time.Duration `json:"cpu_quota_refresh_interval" yaml:"cpu_quota_refresh_interval"`
+
+	// GCRateThreshold is the number of the gc cycles per second to
+	//  trigger the heap profiling.
+	// GCPauseThreshold is the 99th percentile of the gc pause durations
+	//  in a WatchInterval to trigger the heap profiling.
+	// The gc pressure indicates the allocation problems even if
+	//  the memory usage is lower than the MemThreshold. The heap profile
+	//  is reported in the inuse_space and the alloc_space views with
+	//  the report.MemInfo.Trigger set to the report.TriggerGC.
+	// Note that the gc pressure is read by the runtime.ReadMemStats
+	//  which stops the world briefly at each WatchInterval.
+	// Default: 0. (means disabled)
+	GCRateThreshold  float64       `json:"gc_rate_threshold" yaml:"gc_rate_threshold"`
+	GCPauseThreshold time.Duration `json:"gc_pause_threshold" yaml:"gc_pause_threshold"`
+
+	// MemMinAvailableBytes is the minimum available memory bytes
+	//  (the memory limit minus the working set) to trigger the heap
+	//  profiling.
+	// Autopprof will start the heap profiling when the available memory
+	//  is lower than this, even if the memory usage is lower than
+	//  the MemThreshold.
+	// Default: 0. (means disabled)
+	MemMinAvailableBytes uint64 `json:"mem_min_available_bytes" yaml:"mem_min_available_bytes"`
+
+	// MemAbsoluteThreshold is the working set bytes to trigger the heap
+	//  profiling when no memory limit is detected, since the ratio to
+	//  the limit is meaningless then.
+	// If it's not set, the heap profiling is triggered when the working
+	//  set grows by the MemGrowthThreshold (e.g. 0.5 means 50%) over
+	//  the first watch or the last report instead.
+	// Autopprof logs the switch when it finds no memory limit. They're
+	//  ignored if the limit is detected, including the GOMEMLIMIT of
+	//  the MemLimitGo and the MemLimitBoth.
+	// Default: 0. (means the growth) and 0.5.
+	MemAbsoluteThreshold uint64  `json:"mem_absolute_threshold" yaml:"mem_absolute_threshold"`
+	MemGrowthThreshold   float64 `json:"mem_growth_threshold" yaml:"mem_growth_threshold"`
+
+	// NUMAThreshold is the memory usage threshold of a NUMA node to
+	//  trigger the heap profiling, as the ratio of the memory of
+	//  the cgroup on the node to the memory of the node.
+	// On the large multi-socket hosts, the memory pressure can be local
+	//  to a node while the memory usage looks fine. The usages per node
+	//  are read from the memory.numa_stat of the cgroup, and reported
+	//  in the report.MemInfo.NUMANodes. It's disabled with a log if
+	//  they're unavailable. (e.g. the AWS Fargate)
+	// Default: 0. (means disabled)
+	NUMAThreshold float64 `json:"numa_threshold" yaml:"numa_threshold"`
+
+	// MemRequestBytes is the memory request of the container (e.g.
+	//  the resources.requests.memory of the Kubernetes pod) to compute
+	//  the memory usage against in addition to the limit. The pod over
+	//  its request risks the eviction under the memory pressure of
+	//  the node long before it hits its limit. The usage against it is
+	//  reported in the report.MemInfo.RequestUsagePercentage.
+	// MemRequestThreshold is the memory usage threshold against
+	//  the request (e.g. 1 means the request itself) to trigger the heap
+	//  profiling in addition to the MemThreshold against the limit.
+	//  It requires the MemRequestBytes.
+	// Default: 0. (means no request) and 0. (means disabled)
+	MemRequestBytes     uint64  `json:"mem_request_bytes" yaml:"mem_request_bytes"`
+	MemRequestThreshold float64 `json:"mem_request_threshold" yaml:"mem_request_threshold"`
+
+	// IncludeKernelMemory adds the kernel memory (kmem) to the memory
+	//  usage, so the OOMs driven by the kernel memory such as the socket
+	//  buffers and the dentry cache are caught.
+	// On the cgroup v1, the memory.kmem.usage_in_bytes and
+	//  memory.kmem.tcp.usage_in_bytes are added. They're zero if
+	//  the kmem accounting is disabled.
+	// On the cgroup v2, the memory.current already includes the kernel
+	//  memory, so the usage doesn't change.
+	// In both cases, the kmem is reported in the
+	//  report.MemInfo.KernelMemoryBytes regardless of this option.
+	IncludeKernelMemory bool `json:"include_kernel_memory" yaml:"include_kernel_memory"`
+
+	// MemUsageComponents is the memory fields of the cgroup summed into
+	//  the memory usage, so the MemThreshold means what the team's
+	//  dashboards mean by the memory usage. (e.g. MemUsageRSS for
+	//  the RSS, MemUsageRSS|MemUsageCache for the RSS+cache, or
+	//  MemUsageRSS|MemUsageSwap for the RSS+swap) The chosen
+	//  components and their bytes are reported in
+	//  the report.MemInfo.UsageComponents and UsageComponentBytes.
+	// On the cgroup v1, the swap is the memory.memsw.usage_in_bytes
+	//  minus the memory usage, which is zero without the swap
+	//  accounting. They're disabled on the gVisor, whose memory.stat
+	//  lacks the fields.
+	// In the config, it's the names joined by the "+".
+	//  (e.g. "rss+cache")
+	// Default: 0. (means the working set, usage - inactive_file)
+	MemUsageComponents MemUsageComponents `json:"mem_usage_components" yaml:"mem_usage_components"`
+
+	// FDThreshold is the file descriptor usage threshold (between 0 and 1)
+	//  against the soft limit of the number of open files (RLIMIT_NOFILE)
+	//  to trigger the goroutine profiling.
+	// The file descriptor leaks usually come with the goroutine or
+	//  the connection leaks, so the goroutine profile is reported.
+	// Default: 0. (means disabled)
+	FDThreshold float64 `json:"fd_threshold" yaml:"fd_threshold"`
+
+	// PIDThreshold is the usage threshold (between 0 and 1) of the pids
+	//  limit of the cgroup (pids.current against pids.max) to trigger
+	//  the goroutine profiling.
+	// The OS threads count toward the limit, so it catches the thread
+	//  exhaustion by e.g. the runaway cgo calls or the blocking
+	//  syscalls, which the other thresholds miss. It's disabled with
+	//  the log if the pids limit isn't set.
+	// Default: 0. (means disabled)
+	PIDThreshold float64 `json:"pid_threshold" yaml:"pid_threshold"`
+
+	// GoroutineThreshold is the number of the goroutines to trigger
+	//  the goroutine profiling when it stays over the threshold for
+	//  the MinConsecutiveOverThreshold watches, so the goroutine leaks
+	//  are caught. It's reported again after another
+	//  MinConsecutiveOverThreshold watches over the threshold.
+	// Default: 0. (means disabled)
+	GoroutineThreshold int `json:"goroutine_threshold" yaml:"goroutine_threshold"`
+
+	// GoroutineDropThreshold is the ratio (between 0 and 1) of
+	//  the goroutines gone between two watches to trigger
+	//  the goroutine profiling, e.g. 0.5 when the half of them are
+	//  gone. The sudden drop of the goroutines indicates the mass
+	//  connection resets or a partial crash and recovery, and
+	//  the goroutine profile shows what's left.
+	// GoroutineDropMinCount is the minimum number of the goroutines
+	//  gone to trigger it, so the small fluctuations of the process
+	//  with few goroutines are ignored.
+	// Default: 0. (means disabled) and 100.
+	GoroutineDropThreshold float64 `json:"goroutine_drop_threshold" yaml:"goroutine_drop_threshold"`
+	GoroutineDropMinCount  int     `json:"goroutine_drop_min_count" yaml:"goroutine_drop_min_count"`
+
+	// LatencyBreachDebounce is the minimum interval between
+	//  the captures of the NotifyLatencyBreach, so the application can
+	//  notify every violation of its SLO without profiling repeatedly.
+	// Default: 1m.
+	LatencyBreachDebounce time.Duration `json:"latency_breach_debounce" yaml:"latency_breach_debounce"`
+
+	// ErrorRateThreshold is the rate (errors per second) of the errors
+	//  recorded by the RecordError to trigger the cpu and goroutine
+	//  profiling, averaged over the ErrorRateWindow. So the error spikes
+	//  of the application are profiled even if the resource usages look
+	//  fine. The profiles are marked with the report.TriggerErrorRate
+	//  and share the TriggerID.
+	// Default: 0. (means disabled) and 1m.
+	ErrorRateThreshold float64       `json:"error_rate_threshold" yaml:"error_rate_threshold"`
+	ErrorRateWindow    time.Duration `json:"error_rate_window" yaml:"error_rate_window"`
+
+	// EnableWallClock captures the wall-clock profile along with the cpu
+	//  and goroutine profiles of the NotifyLatencyBreach and
+	//  the ErrorRateThreshold. Unlike the cpu profile, it samples both
+	//  of the on-CPU and off-CPU time of the goroutines, like the fgprof,
+	//  so the time spent waiting on the I/O, the locks and the channels,
+	//  where the latency often hides, is visible.
+	// It's reported by the report.ReportProfile with
+	//  the report.ProfileKindWallClock sharing the TriggerID, so
+	//  the reporter must implement the report.ProfileReporter.
+	// The sampling walks the stacks of all goroutines, so it's costly
+	//  with the many goroutines.
+	// Default: false.
+	EnableWallClock bool `json:"enable_wall_clock" yaml:"enable_wall_clock"`
+
+	// CaptureOnStartup captures the cpu and heap profiles once after
+	//  the StartupCaptureDelay since the Start, regardless of
+	//  the thresholds. They're the baseline of the cold start, e.g.
+	//  the heavy preloads, to compare with the steady state. They're
+	//  marked with the report.TriggerStartup and share the TriggerID.
+	// Default: false and 0. (means right after the Start)
+	CaptureOnStartup    bool          `json:"capture_on_startup" yaml:"capture_on_startup"`
+	StartupCaptureDelay time.Duration `json:"startup_capture_delay" yaml:"startup_capture_delay"`
+
+	// IncidentWindow groups the breaches, e.g. the cpu, memory and
+	//  goroutine thresholds breached within seconds of each other,
+	//  into an incident. The profiles of the breaches within the window
+	//  since the last one share the report.CPUInfo.IncidentID, and
+	//  the report.BatchReporter bundles them apart from the others.
+	//  The open incident is in the Status.
+	// Default: 0. (means disabled)
+	IncidentWindow time.Duration `json:"incident_window" yaml:"incident_window"`
+
+	// UsageSmoothingAlpha is the weight (between 0 and 1) of the new
+	//  reading in the exponential moving average of the cpu and memory
+	//  usages, which are compared with the thresholds instead of
+	//  the raw ones. The lower, the smoother, so the momentary spikes
+	//  around the thresholds don't trigger the profiling. Both of
+	//  the raw and the smoothed usages are in the Status.
+	// Default: 0. (means no smoothing)
+	UsageSmoothingAlpha float64 `json:"usage_smoothing_alpha" yaml:"usage_smoothing_alpha"`
+
+	// UsagePercentile is the percentile (between 0 and 1, e.g. 0.9 for
+	//  the p90) of the cpu and memory usages per tick over the window
+	//  of the cpu usage snapshots, which is compared with
+	//  the thresholds instead of the average cpu usage over the window
+	//  and the instantaneous memory usage. So a single outlier tick
+	//  doesn't trigger, but the sustained elevation does: the p90 of
+	//  the 24 ticks is over the threshold when 3 of them are.
+	//  The memory usage isn't compared until the window is full, as
+	//  the cpu usage isn't.
+	// It's applied before the UsageSmoothingAlpha. The percentile stays
+	//  over the threshold until the elevated ticks leave the window, so
+	//  the MinConsecutiveOverThreshold counts them after the elevation
+	//  ends as well. The lower the percentile, the more ticks over
+	//  the threshold it takes to trigger, and the sooner the breach
+	//  ends after the elevation.
+	// Default: 0. (means disabled)
+	UsagePercentile float64 `json:"usage_percentile" yaml:"usage_percentile"`
+
+	// CPUBaselineDeviation compares the cpu usage with the baseline of
+	//  its time-of-day instead of the CPUThreshold alone, for
+	//  the services with the predictable daily pattern which breach
+	//  the static threshold every peak hour.
+	// The typical usage of each CPUBaselineBucket of the day (e.g.
+	//  13:00-14:00 of the local time) is learned across the days, and
+	//  the profiling is triggered when the usage is over the expected
+	//  one of the bucket by the deviation (e.g. 0.5 means 50%).
+	//  The threshold is never lower than the CPUThreshold, which is
+	//  used alone until the bucket is learned on the first day.
+	//  The learned baseline is kept in the StateFile if it's set, and
+	//  the expected and the actual usages are in
+	//  the Status().CPUBaseline.
+	// Default: 0. (means disabled) and 1h.
+	CPUBaselineDeviation float64       `json:"cpu_baseline_deviation" yaml:"cpu_baseline_deviation"`
+	CPUBaselineBucket    time.Duration `json:"cpu_baseline_bucket" yaml:"cpu_baseline_bucket"`
+
+	// CPUProfilingDuration is the duration of a cpu profiling. The longer
+	//  duration collects more samples of the sparse hot paths, and
+	//  the shorter one reports sooner with less overhead.
+	// The cpu watcher waits for the profiling, so the watches during
+	//  the duration are skipped. A duration longer than
+	//  the WatchInterval delays the next watch, and the cooldown of
+	//  the MinConsecutiveOverThreshold watches is counted from there.
+	// Default: 10s.
+	CPUProfilingDuration time.Duration `json:"cpu_profiling_duration" yaml:"cpu_profiling_duration"`
+
+	// CPUProfileRate is the sampling rate (between 1 and 1000 Hz) of
+	//  the cpu profiling.
+	// Higher rate gives more granular profile for the short-lived hot
+	//  paths but costs more overhead.
+	// Note that the rate is a process-global setting of the runtime.
+	//  It's applied right before the cpu profiling starts, and it's
+	//  reset when the profiling stops. The runtime prints
+	//  "runtime: cannot set cpu profile rate until previous profile has
+	//  finished" to the stderr on each capture with the rate set,
+	//  since the pprof.StartCPUProfile sets the default rate again.
+	// Default: 0. (means 100Hz, the default of the runtime/pprof)
+	CPUProfileRate int `json:"cpu_profile_rate" yaml:"cpu_profile_rate"`
+
+	// MemProfileRate is the heap sampling rate in bytes, which is set to
+	//  the runtime.MemProfileRate at the Start. A sample is recorded
+	//  per MemProfileRate bytes allocated on average, so the lower rate
+	//  captures the small allocations in finer detail at the cost of
+	//  more overhead.
+	// Note that the rate must be set as early as possible. The heap
+	//  profile only reflects the allocations sampled after the change,
+	//  so the objects allocated before the Start keep their old
+	//  sampling. The previous rate is restored at the Stop.
+	// Default: 0. (means the runtime default, 512KiB)
+	MemProfileRate int `json:"mem_profile_rate" yaml:"mem_profile_rate"`
+
+	// BlockProfileRate and MutexProfileFraction enable the block and
+	//  the mutex profiling to diagnose the lock contention. They're set
+	//  by the runtime.SetBlockProfileRate and
+	//  the runtime.SetMutexProfileFraction at the Start, and the previous
+	//  rates are restored at the Stop. (See their docs for the rates)
+	// The block and the mutex profiles are reported with
+	//  the report.ReportProfile alongside the cpu and the heap profiles
+	//  when the ReportBoth is set, so the reporter must implement
+	//  the report.ProfileReporter.
+	// Default: 0. (means disabled)
+	BlockProfileRate     int `json:"block_profile_rate" yaml:"block_profile_rate"`
+	MutexProfileFraction int `json:"mutex_profile_fraction" yaml:"mutex_profile_fraction"`
+
+	// PreviousBlockProfileRate is the block profile rate the application
+	//  set before the Start, restored at the Stop with the BlockProfileRate.
+	//  The runtime can't tell the rate, unlike the mutex profile fraction.
+	// Default: 0. (means disabled)
+	PreviousBlockProfileRate int `json:"previous_block_profile_rate" yaml:"previous_block_profile_rate"`
+
+	// CPUTopN is the number of the top functions by the flat cpu time
+	//  to include in the report.CPUInfo, so the hot functions can be
+	//  seen without opening the profile.
+	// It's not applied to the report.StreamReporter that streams
+	//  the cpu profile.
+	// Default: 0. (means disabled)
+	CPUTopN int `json:"cpu_top_n" yaml:"cpu_top_n"`
+
+	// CPUProfileLabels scopes the cpu profile to the goroutines of all
+	//  of the pprof labels, e.g. {"subsystem": "background-worker"},
+	//  so the teams can focus on a known subsystem. The other samples
+	//  are dropped before the reporting, and the share of the cpu time
+	//  of the kept ones is in the report.CPUInfo.
+	// The application must set the labels by the pprof.Do (or
+	//  the pprof.SetGoroutineLabels) around the code paths, and
+	//  the goroutines started inside inherit them. The triggers still
+	//  follow the cpu usage of the whole process.
+	// It's not applied to the report.StreamReporter that streams
+	//  the cpu profile.
+	// Default: nil. (means the whole profile)
+	CPUProfileLabels map[string]string `json:"cpu_profile_labels" yaml:"cpu_profile_labels"`
+
+	// LowPriorityCapture reduces the interference of the profiling with
+	//  the application, which may be in the middle of an incident.
+	// The capturing goroutine yields the processor before each capture
+	//  and is never pinned to a thread, and the cpu profiling samples at
+	//  50Hz instead of 100Hz unless the CPUProfileRate is set. Like with
+	//  the CPUProfileRate, the runtime prints the warning about the rate
+	//  to the stderr on each cpu capture.
+	// It trades the fidelity of the profile (half the cpu samples and
+	//  a slightly delayed capture) for the lower overhead of the signal
+	//  handling and the scheduling.
+	// It can't be set with the LockOSThread.
+	LowPriorityCapture bool `json:"low_priority_capture" yaml:"low_priority_capture"`
+
+	// LockOSThread locks the capturing goroutine to its OS thread by
+	//  the runtime.LockOSThread during the cpu and wall-clock profiling,
+	//  so it isn't migrated between the threads mid-capture, e.g.
+	//  between the start and the stop of the cpu profiling.
+	// It's an advanced fidelity knob. The cpu profiling samples all
+	//  threads of the process by the signals regardless of the thread
+	//  of the capturing goroutine, which is idle while waiting for
+	//  the duration, so it hardly changes the profile in practice.
+	//  It costs a thread held for each capture, so it can't be set with
+	//  the LowPriorityCapture.
+	LockOSThread bool `json:"lock_os_thread" yaml:"lock_os_thread"`
+
+	// CPUProfilingBudget is the cpu profiling time allowed per hour
+	//  across all triggers, to cap the cumulative profiling overhead.
+	// It's refilled continuously like a token bucket. While it's
+	//  exhausted, the cpu profiling is skipped and the skip is counted
+	//  in the Status().CPUBudget.
+	// Each cpu profiling takes the CPUProfilingDuration from the budget,
+	//  so the budget lower than that skips all cpu profiling.
+	// Default: 0. (means unlimited)
+	CPUProfilingBudget time.Duration `json:"cpu_profiling_budget" yaml:"cpu_profiling_budget"`
+
+	// StateFile is the path of the file to persist the state of
+	//  the reporting, such as the last report time of each profile.
+	// With this, the cooldown between the reports
+	//  (MinConsecutiveOverThreshold * WatchInterval) survives
+	//  the restarts, so the crash looping process doesn't flood
+	//  the reporter. The missing or corrupted file is ignored.
+	// Default: "". (means the state isn't persisted)
+	StateFile string `json:"state_file" yaml:"state_file"`
+
+	// WALPath is the path of the write-ahead log of the profiles.
+	//  If it's set, the captured profiles are appended to the WAL
+	//  before the Reporter is called, and removed on the successful
+	//  report. The failed reports are sent again after the next
+	//  successful report, and the profiles left in the WAL by a crash
+	//  mid-upload at the next Start, so the critical profiles are
+	//  delivered at least once.
+	//  (See report.WALReporter)
+	// The WAL buffers the profile, so the report.StreamReporter
+	//  doesn't stream with it.
+	// WALMaxBytes is the maximum size of the WAL. The profile which
+	//  doesn't fit is reported without the WAL.
+	// Default: "". (means disabled) and 64MiB.
+	WALPath     string `json:"wal_path" yaml:"wal_path"`
+	WALMaxBytes int64  `json:"wal_max_bytes" yaml:"wal_max_bytes"`
+
+	// FullHeapCapture reports three views of the heap profile,
+	//  inuse_space, inuse_objects and alloc_space, on a heap trigger
+	//  instead of one, since all of them are usually needed during
+	//  a memory incident.
+	// The views share the report.MemInfo.TriggerID, and each of them
+	//  is marked with the report.MemInfo.SampleType.
+	FullHeapCapture bool `json:"full_heap_capture" yaml:"full_heap_capture"`
+
+	// HeapPrimarySampleType is the sample type of the heap profile
+	//  the backend should show by default, one of the inuse_space,
+	//  inuse_objects, alloc_space and alloc_objects.
+	//  (e.g. alloc_space for the allocation-heavy services)
+	// The heap profile keeps all of them regardless, it's only marked
+	//  with the report.MemInfo.PrimarySampleType for the backend.
+	// Default: "". (means the inuse_space)
+	HeapPrimarySampleType string `json:"heap_primary_sample_type" yaml:"heap_primary_sample_type"`
+
+	// HeapSampleReduction is the share (between 0 and 1) of the samples
+	//  dropped from the heap profile before reporting it, to keep
+	//  the profile small and fast to report on the services with
+	//  the huge heaps.
+	// The samples of the smallest values of the HeapPrimarySampleType
+	//  are dropped first, so the dominant allocation sites are kept.
+	//  But the totals of the profile are under-reported by the dropped
+	//  samples, and the small allocation sites are missing, so
+	//  the profile can't account for the whole heap. The other sample
+	//  types are also reduced by the ranking of the primary one.
+	// Default: 0. (means the full profile)
+	HeapSampleReduction float64 `json:"heap_sample_reduction" yaml:"heap_sample_reduction"`
+
+	// MaxHeapProfileSize is the heap size in bytes (the HeapAlloc of
+	//  the runtime.MemStats) above which the heap profiling is
+	//  throttled, since the profiling of a multi-gigabyte heap is
+	//  costly by itself and can worsen the memory incident.
+	// Above it, the heap profile is reduced by the
+	//  LargeHeapSampleReduction (between 0 and 1) instead of
+	//  the HeapSampleReduction if it's higher, or the heap profiling
+	//  is skipped with a warning if the LargeHeapSampleReduction is
+	//  zero. The reduction keeps the profile small to report, while
+	//  the capture itself still walks all of the samples.
+	// The throttled ones are counted in the Status().HeapThrottle.
+	// Default: 0. (means unlimited) and 0.
+	MaxHeapProfileSize       uint64  `json:"max_heap_profile_size" yaml:"max_heap_profile_size"`
+	LargeHeapSampleReduction float64 `json:"large_heap_sample_reduction" yaml:"large_heap_sample_reduction"`
+
+	// HeapGoroutineDump reports the human-readable stack traces of all
+	//  goroutines (same as the /debug/pprof/goroutine?debug=2) with
+	//  the heap profile, so the alive goroutines can be correlated with
+	//  the heap usage. It's reported by the Reporter.ReportGoroutineProfile
+	//  with the report.GoroutineInfo.Dump set, and shares
+	//  the report.MemInfo.TriggerID with the heap profile.
+	// Note that the dump stops the world while collecting the stacks,
+	//  so it's costly for the process with many goroutines.
+	HeapGoroutineDump bool `json:"heap_goroutine_dump" yaml:"heap_goroutine_dump"`
+
+	// CPUCrossingGoroutineDump dumps the stack traces of all goroutines
+	//  at the watch the cpu usage crosses the threshold, before starting
+	//  the cpu profiling, so the state of the instant isn't averaged
+	//  away by the profiling duration. It's reported after the cpu
+	//  profile by the Reporter.ReportGoroutineProfile with
+	//  the report.GoroutineInfo.Dump set and the report.TriggerCPU, and
+	//  shares the report.CPUInfo.TriggerID with the cpu profile.
+	//  The bursts of the captures after the crossing don't dump.
+	// Note that the dump stops the world like the HeapGoroutineDump.
+	CPUCrossingGoroutineDump bool `json:"cpu_crossing_goroutine_dump" yaml:"cpu_crossing_goroutine_dump"`
+
+	// ReportAllocsDiff reports the allocs profile of the allocations
+	//  since the last heap report, the difference of the cumulative
+	//  ones, with the heap profile triggered by the memory usage. So
+	//  the allocation sites churning between the incidents are told
+	//  apart from the leaking ones. It's reported by
+	//  the report.ReportProfile with the report.ProfileKindAllocs and
+	//  the report.ProfileInfo.Delta set, and shares
+	//  the report.MemInfo.TriggerID with the heap profile, so
+	//  the reporter must implement the report.ProfileReporter.
+	// The first one is the cumulative one since the start.
+	ReportAllocsDiff bool `json:"report_allocs_diff" yaml:"report_allocs_diff"`
+
+	// ReportCPUDiff reports the difference of each cpu profile from
+	//  the last one with the cpu profile, so the functions got hotter
+	//  during an escalating incident stand out. The last one is scaled
+	//  to the duration of the current one, and the functions cooled
+	//  down are negative. It's reported by the report.ReportProfile
+	//  with the report.ProfileKindCPU and the report.ProfileInfo.Delta
+	//  set, shares the report.CPUInfo.TriggerID with the cpu profile,
+	//  and refers to the last one by the BaseTriggerID, so
+	//  the reporter must implement the report.ProfileReporter.
+	// The first cpu profile has no diff. The report.StreamReporter
+	//  doesn't stream the cpu profile with it.
+	ReportCPUDiff bool `json:"report_cpu_diff" yaml:"report_cpu_diff"`
+
+	// MaxConcurrentReports is the maximum number of the reports
+	//  sent to the Reporter at the same time. (e.g. the views of
+	//  the FullHeapCapture or the cpu and heap profiles of ReportBoth)
+	// Default: 0. (means unlimited)
+	MaxConcurrentReports int `json:"max_concurrent_reports" yaml:"max_concurrent_reports"`
+
+	// BurstCount is the number of the profiles captured in quick
+	//  succession after the first breach of the CPUThreshold or
+	//  the MemThreshold, including the first one, so the early
+	//  evolution of the incident is captured as a time series.
+	//  The burst ends when the usage goes under the threshold, and
+	//  the normal cooldown follows.
+	// BurstInterval is the interval between the captures of the burst.
+	//  The usage is checked at each WatchInterval, so it's rounded up
+	//  to the multiple of the WatchInterval.
+	// The captures of the burst skip the cooldown, but they still
+	//  respect the MaxConcurrentReports and the CPUProfilingBudget.
+	// Default: 0. (means a single capture) and 0. (means every
+	//  WatchInterval)
+	BurstCount    int           `json:"burst_count" yaml:"burst_count"`
+	BurstInterval time.Duration `json:"burst_interval" yaml:"burst_interval"`
+
+	// CaptureSequence sets the report.CPUInfo.Sequence and
+	//  the report.CPUInfo.Elapsed (and the same fields of the other
+	//  infos) to order the profiles precisely even if the wall clock
+	//  timestamps collide or the clock jumps. The sequence number is
+	//  incremented across all kinds of the profiles, and the elapsed
+	//  time since the Start is read from the monotonic clock.
+	// The reporters also suffix the <report_time> of the filenames
+	//  with the sequence number.
+	CaptureSequence bool `json:"capture_sequence" yaml:"capture_sequence"`
+
+	// SeverityBasedCooldown shrinks the cooldown between the reports of
+	//  the sustained high usage as the usage climbs, e.g. every 2m at
+	//  75% but every 30s at 95%:
+	//
+	//	[]SeverityCooldown{
+	//		{Usage: 0.75, Cooldown: 2 * time.Minute},
+	//		{Usage: 0.95, Cooldown: 30 * time.Second},
+	//	}
+	//
+	// The bands must be sorted by the usage, and the cooldowns must
+	//  decrease as the usage increases. The cooldown of the highest
+	//  band the usage reaches is applied, and the default cooldown
+	//  (MinConsecutiveOverThreshold * WatchInterval) is applied below
+	//  the lowest band. It's also the cooldown across the restarts
+	//  with the StateFile.
+	// It's applied to the cpu and memory usages.
+	// Default: nil. (means the fixed cooldown)
+	SeverityBasedCooldown []SeverityCooldown `json:"severity_based_cooldown" yaml:"severity_based_cooldown"`
+
+	// EdgeTriggered reports the profile only once when the usage crosses
+	//  above the threshold, instead of reporting again every
+	//  MinConsecutiveOverThreshold * WatchInterval while the usage
+	//  stays high.
+	// The next report is sent after the usage drops back below
+	//  the threshold and crosses it again.
+	// Default: false. (means level-triggered)
+	EdgeTriggered bool `json:"edge_triggered" yaml:"edge_triggered"`
+
+	// EmitRecoveryEvents emits the EventCPURecovered and
+	//  the EventMemRecovered to the OnEvent when the usage drops back
+	//  below the threshold in the EdgeTriggered mode.
+	EmitRecoveryEvents bool `json:"emit_recovery_events" yaml:"emit_recovery_events"`
 
 	// ReportBoth sets whether to trigger reports for both CPU and memory when either threshold is exceeded.
 	// If some profiling is disabled, exclude it.
-	ReportBoth bool
+	ReportBoth bool `json:"report_both" yaml:"report_both"`
+
+	// LivenessInterval is the interval to send the liveness marker to
+	//  the reporter, so the backend can tell that the autopprof died by
+	//  the absence of the markers even without the breaches.
+	// The marker is reported by the report.ReportProfile with
+	//  the report.ProfileKindLiveness and no profiling data, so
+	//  the reporter must implement the report.ProfileReporter.
+	// Default: 0. (means disabled)
+	LivenessInterval time.Duration `json:"liveness_interval" yaml:"liveness_interval"`
+
+	// Labels are the labels of the reports, set to the Labels of
+	//  the report.CPUInfo and the other infos, e.g. the version of
+	//  the application.
+	// Default: nil.
+	Labels map[string]string `json:"labels" yaml:"labels"`
+
+	// KubernetesLabels labels the reports with the pod, the namespace
+	//  and the node from the POD_NAME, POD_NAMESPACE and NODE_NAME
+	//  env vars, which are exposed by the downward API of
+	//  the Kubernetes. (See report.LabelPod) Without the POD_NAME,
+	//  the pod is the hostname. The Labels take precedence over them.
+	KubernetesLabels bool `json:"kubernetes_labels" yaml:"kubernetes_labels"`
+
+	// IncludeEnvVars are the names of the env vars to label the reports
+	//  with, e.g. APP_VERSION, DEPLOY_ID and REGION. The label is
+	//  the name of the env var, and the unset ones are skipped.
+	//  The Labels take precedence over them.
+	// Only the allowlisted env vars are read, never the whole
+	//  environment, but their values are sent to the Reporter as they
+	//  are, so don't list the ones holding the secrets.
+	// Default: nil.
+	IncludeEnvVars []string `json:"include_env_vars" yaml:"include_env_vars"`
+
+	// VerifyProfiles parses the captured profiles before reporting them,
+	//  and skips the ones failed to parse, so the truncated or
+	//  corrupted profiles (e.g. by the disrupted capture during
+	//  an incident) aren't reported. The skipped ones are logged and
+	//  counted in the StatusInfo.InvalidProfiles.
+	// The parsing costs the cpu and the memory of the profile size,
+	//  and the report.StreamReporter doesn't stream with it.
+	VerifyProfiles bool `json:"verify_profiles" yaml:"verify_profiles"`
+
+	// TriggerFilePath is the path prefix of the trigger files, which
+	//  the operator touches to capture the profile regardless of
+	//  the thresholds, through any access to the filesystem such as
+	//  the kubectl exec or a shared volume. The trigger file of a kind
+	//  is the path suffixed with "-<kind>", e.g. for
+	//  the "/tmp/autopprof-capture":
+	//    /tmp/autopprof-capture-cpu       the cpu profile
+	//    /tmp/autopprof-capture-heap      the heap profile
+	//    /tmp/autopprof-capture-goroutine the goroutine profile
+	//    /tmp/autopprof-capture-<name>    the profile of the pprof.Lookup
+	//  (e.g. mutex) as the CaptureNamed.
+	//  Its directory is watched by the inotify, and the trigger file is
+	//  removed once handled. The touches of a kind within 5s of its
+	//  last capture are ignored. The profiles are marked with
+	//  the report.TriggerManual.
+	// Default: "". (means disabled)
+	TriggerFilePath string `json:"trigger_file_path" yaml:"trigger_file_path"`
+
+	// AnalyzeCPU and AnalyzeHeap are called with the parsed cpu and heap
+	//  profiles after the capture and before the reporting, so
+	//  the application can run its own analysis in-process. (e.g.
+	//  escalating when a known-bad function takes more than some share
+	//  of the samples) The profile is parsed once for them.
+	// They're called in the watching goroutine, so they must not block.
+	//  They must not mutate the profile either, since it's reported
+	//  after them. The report.StreamReporter doesn't stream the cpu
+	//  profile with the AnalyzeCPU.
+	// Default: nil.
+	AnalyzeCPU  func(*profile.Profile) `json:"-" yaml:"-"`
+	AnalyzeHeap func(*profile.Profile) `json:"-" yaml:"-"`
 
 	// Reporter is the reporter to send the profiling report implementing
 	//  the report.Reporter interface.
-	Reporter report.Reporter
+	// The report.Router routes the reports to the reporters by their
+	//  kinds and severities at the trigger time.
+	Reporter report.Reporter `json:"-" yaml:"-"`
+
+	// Logger is the logger of the diagnostics, e.g. the failures of
+	//  the watchers and the reports, and the SummaryLog. The
+	//  log.Default() logs them to the standard logger.
+	// Default: nil. (means discarding them)
+	Logger Logger `json:"-" yaml:"-"`
+
+	// SummaryLog logs a one-line summary of each report to the Logger,
+	//  including the failed ones, so the captures are easy to find in
+	//  the logs during the triage. e.g.
+	//    autopprof: cpu report (usage 82.50% > 75.00%) sent 1234567 bytes in 340ms
+	// SummaryLogTemplate is the text/template of the summary executed
+	//  with the ReportSummary.
+	// Default: false and "". (means the format above)
+	SummaryLog         bool   `json:"summary_log" yaml:"summary_log"`
+	SummaryLogTemplate string `json:"summary_log_template" yaml:"summary_log_template"`
+
+	// VerifyReporter checks the connectivity of the Reporter at the Start
+	//  if it implements the report.PingReporter, so the Start fails
+	//  fast on the misconfiguration such as bad credentials.
+	// The Reporter not implementing it isn't checked.
+	VerifyReporter bool `json:"verify_reporter" yaml:"verify_reporter"`
 
-	UseAWSFargate bool
-	VCPUSize float64 
+	// PublishExpvar publishes the counters of the reports (sent, failed
+	//  and dropped), the last usages and the last report time via
+	//  the expvar under the "autopprof", so they're visible on
+	//  the /debug/vars without a metrics backend.
+	PublishExpvar bool `json:"publish_expvar" yaml:"publish_expvar"`
+
+	// ReportTimeout is the timeout of a report.
+	// The reporter implementing the report.TimeoutReporter overrides it
+	//  with its own timeout, since the latencies of the destinations
+	//  vary widely. (e.g. the local file vs the cross-region storage)
+	// The cpu profile streamed to the report.StreamReporter gets
+	//  the cpu profiling duration in addition.
+	// Default: 5s.
+	ReportTimeout time.Duration `json:"report_timeout" yaml:"report_timeout"`
+
+	// ReporterFailureThreshold is the number of consecutive reporter
+	//  failures to open the circuit breaker around the reporter.
+	// While the breaker is open, the profiling and reporting are
+	//  skipped until the ReporterCooldown elapses. After that, the
+	//  next report is sent to test whether the reporter is recovered.
+	// Default: 5.
+	ReporterFailureThreshold int `json:"reporter_failure_threshold" yaml:"reporter_failure_threshold"`
+
+	// ReporterCooldown is the duration to skip the reporting after
+	//  the circuit breaker is opened.
+	// Default: 10m.
+	ReporterCooldown time.Duration `json:"reporter_cooldown" yaml:"reporter_cooldown"`
+
+	// ContainerCgroupPath is the cgroup path of the container relative
+	//  to the cgroup mount point. (e.g. /kubepods/pod<uid>/<container-id>)
+	// By default, it's detected from the /proc/self/cgroup, so the usages
+	//  of the container are read instead of the pod's ones which include
+	//  the sidecar containers.
+	// Default: "". (means auto-detection)
+	ContainerCgroupPath string `json:"container_cgroup_path" yaml:"container_cgroup_path"`
+
+	// CPUAcctCgroupPath is the cgroup path of the cpuacct subsystem
+	//  relative to its mount point on the cgroup v1 hosts.
+	// If it's set, the cpu usage is read from its cpuacct.usage
+	//  directly. It's also read directly if the cpu and the cpuacct
+	//  subsystems are mounted separately. (split hierarchy)
+	// Default: "". (means the same path as the other subsystems)
+	CPUAcctCgroupPath string `json:"cpuacct_cgroup_path" yaml:"cpuacct_cgroup_path"`
+
+	// CPUSubsystemPath is the directory of the cpu subsystem relative to
+	//  the cgroup mount point on the cgroup v1 hosts, where the cpu
+	//  quota files are read from. (e.g. cpu,cpuacct)
+	// By default, the cpu and then the cpu,cpuacct are tried, so
+	//  the hosts mounting it only at the combined directory are
+	//  supported.
+	// Default: "". (means auto-detection)
+	CPUSubsystemPath string `json:"cpu_subsystem_path" yaml:"cpu_subsystem_path"`
+
+	// OCISpecPath is the path of the OCI runtime spec (config.json) of
+	//  the container. If it's set, the memory limit and the cpu quota
+	//  in its linux.resources take precedence over the ones of
+	//  the cgroup, which may differ from the declared limits in
+	//  the sandboxed runtimes. (e.g. gVisor, Kata Containers)
+	// The limits absent in the spec, or the missing spec, fall back to
+	//  the cgroup. The usages are still read from the cgroup.
+	// Default: "". (means the cgroup only)
+	OCISpecPath string `json:"oci_spec_path" yaml:"oci_spec_path"`
+
+	UseAWSFargate bool    `json:"use_aws_fargate" yaml:"use_aws_fargate"`
+	VCPUSize      float64 `json:"vcpu_size" yaml:"vcpu_size"`
 }
 
 // NOTE(mingrammer): testing the validate() is done in autopprof_test.go.
 func (o Option) validate() error {
-	if o.DisableCPUProf && o.DisableMemProf {
+	if err := o.validateConfig(); err != nil {
+		return err
+	}
+	if o.Reporter == nil {
+		return ErrNilReporter
+	}
+	if _, ok := o.Reporter.(report.ProfileReporter); o.LivenessInterval > 0 && !ok {
+		return ErrInvalidLivenessInterval
+	}
+	if _, ok := o.Reporter.(report.ProfileReporter); o.EnableWallClock && !ok {
+		return ErrInvalidWallClock
+	}
+	if _, ok := o.Reporter.(report.ProfileReporter); o.ReportAllocsDiff && !ok {
+		return ErrInvalidAllocsDiff
+	}
+	if _, ok := o.Reporter.(report.ProfileReporter); o.ReportCPUDiff && !ok {
+		return ErrInvalidCPUDiff
+	}
+	if _, ok := o.Reporter.(report.ProfileReporter); (o.BlockProfileRate > 0 || o.MutexProfileFraction > 0) && !ok {
+		return ErrInvalidContentionProfiles
+	}
+	return nil
+}
+
+// validateConfig validates the option except for the fields which are
+// set programmatically, e.g. the Reporter. (See LoadOption)
+func (o Option) validateConfig() error {
+	if o.DisableCPUProf && o.DisableMemProf &&
+		(o.DisableGoroutineProf || o.GoroutineThreshold == 0) {
 		return ErrDisableAllProfiling
 	}
-	if o.CPUThreshold < 0 || o.CPUThreshold > 1 {
+	if o.GoroutineThreshold < 0 {
+		return ErrInvalidGoroutineThreshold
+	}
+	if o.CPUUsageBasis < CPUUsageQuota || o.CPUUsageBasis > CPUUsageAllCores {
+		return ErrInvalidCPUUsageBasis
+	}
+	if o.CPUThreshold < 0 || (o.CPUThreshold > 1 && o.CPUUsageBasis != CPUUsageSingleCore) {
 		return ErrInvalidCPUThreshold
 	}
 	if o.MemThreshold < 0 || o.MemThreshold > 1 {
 		return ErrInvalidMemThreshold
 	}
-	if o.Reporter == nil {
-		return ErrNilReporter
+	if o.MemLimitMode < MemLimitCgroup || o.MemLimitMode > MemLimitBoth {
+		return ErrInvalidMemLimitMode
+	}
+	if o.MemUsageComponents&^memUsageAll != 0 {
+		return ErrInvalidMemUsageComponents
+	}
+	if o.GCRateThreshold < 0 || o.GCPauseThreshold < 0 {
+		return ErrInvalidGCThreshold
+	}
+	if o.FDThreshold < 0 || o.FDThreshold > 1 {
+		return ErrInvalidFDThreshold
+	}
+	if o.PIDThreshold < 0 || o.PIDThreshold > 1 {
+		return ErrInvalidPIDThreshold
+	}
+	if o.GoroutineDropThreshold < 0 || o.GoroutineDropThreshold > 1 ||
+		o.GoroutineDropMinCount < 0 {
+		return ErrInvalidGoroutineDropThreshold
+	}
+	if o.HeapSampleReduction < 0 || o.HeapSampleReduction >= 1 {
+		return ErrInvalidHeapSampleReduction
+	}
+	if o.LargeHeapSampleReduction < 0 || o.LargeHeapSampleReduction >= 1 {
+		return ErrInvalidHeapSampleReduction
+	}
+	if o.NUMAThreshold < 0 || o.NUMAThreshold > 1 {
+		return ErrInvalidNUMAThreshold
+	}
+	if o.SummaryLogTemplate != "" {
+		if _, err := template.New("summary").Parse(o.SummaryLogTemplate); err != nil {
+			return ErrInvalidSummaryLogTemplate
+		}
+	}
+	if o.MemRequestThreshold < 0 ||
+		(o.MemRequestThreshold > 0 && o.MemRequestBytes == 0) {
+		return ErrInvalidMemRequestThreshold
+	}
+	if o.ErrorRateThreshold < 0 || o.ErrorRateWindow < 0 {
+		return ErrInvalidErrorRate
+	}
+	if o.UsageSmoothingAlpha < 0 || o.UsageSmoothingAlpha > 1 {
+		return ErrInvalidUsageSmoothingAlpha
+	}
+	if o.UsagePercentile < 0 || o.UsagePercentile > 1 {
+		return ErrInvalidUsagePercentile
+	}
+	if o.IncidentWindow < 0 {
+		return ErrInvalidIncidentWindow
+	}
+	if o.CPUBaselineDeviation < 0 ||
+		o.CPUBaselineBucket < 0 || o.CPUBaselineBucket > 24*time.Hour {
+		return ErrInvalidCPUBaseline
+	}
+	if o.StartupCaptureDelay < 0 {
+		return ErrInvalidStartupCaptureDelay
+	}
+	if o.LatencyBreachDebounce < 0 {
+		return ErrInvalidLatencyBreachDebounce
+	}
+	if o.MemGrowthThreshold < 0 {
+		return ErrInvalidMemGrowthThreshold
+	}
+	switch o.HeapPrimarySampleType {
+	case "",
+		report.SampleTypeInuseSpace, report.SampleTypeInuseObjects,
+		report.SampleTypeAllocSpace, report.SampleTypeAllocObjects:
+	default:
+		return ErrInvalidHeapSampleType
+	}
+	cpuThreshold := defaultCPUThreshold
+	if o.CPUThreshold != 0 {
+		cpuThreshold = o.CPUThreshold
+	}
+	if o.CPUWarnThreshold < 0 || o.CPUWarnThreshold >= cpuThreshold {
+		return ErrInvalidCPUWarnThreshold
+	}
+	if o.CPUPressureThreshold < 0 || o.CPUPressureThreshold > 1 {
+		return ErrInvalidCPUPressureThreshold
+	}
+	memThreshold := defaultMemThreshold
+	if o.MemThreshold != 0 {
+		memThreshold = o.MemThreshold
+	}
+	if o.MemWarnThreshold < 0 || o.MemWarnThreshold >= memThreshold {
+		return ErrInvalidMemWarnThreshold
+	}
+	if o.WarnCooldown < 0 {
+		return ErrInvalidWarnCooldown
+	}
+	if o.CPUTopN < 0 {
+		return ErrInvalidCPUTopN
+	}
+	if o.CPUProfileRate < 0 || o.CPUProfileRate > maxCPUProfileRate {
+		return ErrInvalidCPUProfileRate
+	}
+	if o.LowPriorityCapture && o.LockOSThread {
+		return ErrInvalidLowPriorityCapture
+	}
+	if o.MemProfileRate < 0 {
+		return ErrInvalidMemProfileRate
+	}
+	if o.BlockProfileRate < 0 {
+		return ErrInvalidBlockProfileRate
+	}
+	if o.MutexProfileFraction < 0 {
+		return ErrInvalidMutexProfileFraction
+	}
+	if o.MaxConcurrentReports < 0 {
+		return ErrInvalidMaxConcurrentReports
+	}
+	if o.CPUProfilingBudget < 0 {
+		return ErrInvalidCPUProfilingBudget
+	}
+	if o.CPUProfilingDuration < 0 {
+		return ErrInvalidCPUProfilingDuration
+	}
+	if o.BurstCount < 0 || o.BurstInterval < 0 {
+		return ErrInvalidBurst
+	}
+	if o.WatchInterval < 0 {
+		return ErrInvalidWatchInterval
+	}
+	if o.MinConsecutiveOverThreshold < 0 {
+		return ErrInvalidMinConsecutiveOverThreshold
+	}
+	watchInterval := defaultWatchInterval
+	if o.WatchInterval != 0 {
+		watchInterval = o.WatchInterval
+	}
+	if o.SampleInterval < 0 || o.SampleInterval > watchInterval {
+		return ErrInvalidSampleInterval
+	}
+	if o.CPUQuotaRefreshInterval < 0 {
+		return ErrInvalidCPUQuotaRefreshInterval
+	}
+	if o.WALMaxBytes < 0 {
+		return ErrInvalidWALMaxBytes
+	}
+	if o.ReportTimeout < 0 {
+		return ErrInvalidReportTimeout
+	}
+	if !validSeverityCooldowns(o.SeverityBasedCooldown) {
+		return ErrInvalidSeverityBasedCooldown
+	}
+	if o.LivenessInterval < 0 {
+		return ErrInvalidLivenessInterval
+	}
+	if o.ReporterFailureThreshold < 0 || o.ReporterCooldown < 0 {
+		return ErrInvalidReporterBreaker
 	}
 	return nil
 }
@@ -0,0 +1,61 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// recoverPanic recovers the panic of the goroutine of the name if
+// the recoverPanics is set, so a bug of the autopprof or the reporter
+// doesn't crash the application. The panic is logged with its stack and
+// counted, and the err is set to ErrPanicked if it's not nil.
+// It must be deferred directly.
+func (ap *autoPprof) recoverPanic(name string, err *error) {
+	if !ap.recoverPanics {
+		return
+	}
+	r := recover()
+	if r == nil {
+		return
+	}
+	atomic.AddUint64(&ap.panics, 1)
+	logger().Printf("autopprof: recovered from the panic of the %s: %v\n%s", name, r, debug.Stack())
+	if err != nil {
+		*err = fmt.Errorf("%w: %v", ErrPanicked, r)
+	}
+}
+
+// runRecovered runs the fn, and reports whether it panicked and
+// the panic is recovered.
+func (ap *autoPprof) runRecovered(name string, fn func()) (panicked bool) {
+	defer ap.recoverPanic(name, nil)
+	panicked = true
+	fn()
+	return false
+}
+
+// runWatcher runs the watcher of the name recovering its panic. With
+// the restartOnPanic, the panicked watcher is restarted after
+// the watchInterval until the stop.
+func (ap *autoPprof) runWatcher(name string, watch func()) {
+	for ap.runRecovered(name, watch) && ap.restartOnPanic {
+		timer := time.NewTimer(ap.watchInterval)
+		select {
+		case <-timer.C:
+			logger().Printf("autopprof: restart the %s after the panic", name)
+		case <-ap.stopC:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// panicCount returns the number of the recovered panics.
+func (ap *autoPprof) panicCount() uint64 {
+	return atomic.LoadUint64(&ap.panics)
+}
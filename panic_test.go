@@ -0,0 +1,106 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/looko-corp/autopprof/report"
+)
+
+func TestAutoPprof_runWatcher_panickingReporter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		memUsage().
+		AnyTimes().
+		Return(&memStat{usage: 9, limit: 10}, nil)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileHeap().
+		AnyTimes().
+		Return([]byte("prof"), nil)
+
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(context.Context, io.Reader, report.MemInfo) error {
+			panic("bad reporter")
+		})
+
+	ap := &autoPprof{
+		disableCPUProf: true,
+		watchInterval:  50 * time.Millisecond,
+		memThreshold:   0.5, // 50%.
+		queryer:        mockQueryer,
+		profiler:       mockProfiler,
+		reporter:       mockReporter,
+		recoverPanics:  true,
+		restartOnPanic: true,
+		stopC:          make(chan struct{}),
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ap.runWatcher(watcherMem, ap.watchMemUsage)
+		close(done)
+	}()
+
+	// Wait for the panic and the restart.
+	time.Sleep(250 * time.Millisecond)
+	if got := ap.panicCount(); got < 2 {
+		t.Errorf("panicCount() = %d, want the restarted watcher to panic again", got)
+	}
+
+	ap.stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("runWatcher() doesn't return after the stop")
+	}
+}
+
+func TestAutoPprof_captureOnCrash_panickingReporter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileHeap().
+		Return([]byte("prof"), nil)
+	mockProfiler.EXPECT().
+		dumpGoroutines().
+		Return([]byte("dump"), nil)
+
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(context.Context, io.Reader, report.MemInfo) error {
+			panic("bad reporter")
+		})
+	mockReporter.EXPECT().
+		ReportGoroutineProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+
+	ap := &autoPprof{
+		profiler:      mockProfiler,
+		reporter:      mockReporter,
+		recoverPanics: true,
+		stopC:         make(chan struct{}),
+	}
+	if err := ap.captureOnCrash("oom"); !errors.Is(err, ErrPanicked) {
+		t.Errorf("captureOnCrash() = %v, want %v", err, ErrPanicked)
+	}
+	if got := ap.panicCount(); got != 1 {
+		t.Errorf("panicCount() = %d, want 1", got)
+	}
+}
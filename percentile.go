@@ -0,0 +1,79 @@
+package autopprof
+
+import (
+	"math"
+	"sort"
+)
+
+// percentile returns the p-th percentile (between 0 and 1) of
+// the values by the nearest rank. The values are sorted in place.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	rank := int(math.Ceil(p*float64(len(values)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return values[rank]
+}
+
+// cpuUsagePercentile returns the p-th percentile of the cpu usages
+// between the consecutive snapshots against the quota, instead of
+// the average between the oldest and the newest ones.
+func cpuUsagePercentile(snapshots []CPUSnapshot, quota, p float64) float64 {
+	if len(snapshots) < 2 || quota == 0 {
+		return 0
+	}
+	usages := make([]float64, 0, len(snapshots)-1)
+	for i := 1; i < len(snapshots); i++ {
+		duration := snapshots[i].Timestamp.Sub(snapshots[i-1].Timestamp)
+		if duration <= 0 {
+			continue
+		}
+		delta := snapshots[i].Usage - snapshots[i-1].Usage
+		usages = append(usages, float64(delta)/float64(duration)/quota)
+	}
+	return percentile(usages, p)
+}
+
+// usageWindow keeps the usages of the last ticks to compute
+// their percentile. (See Option.UsagePercentile) A nil usageWindow
+// passes the usages through.
+type usageWindow struct {
+	p      float64
+	values []float64
+	next   int
+}
+
+// newUsageWindow returns the usageWindow of the p-th percentile over
+// the size ticks. It's nil if the p is zero.
+func newUsageWindow(p float64, size int) *usageWindow {
+	if p == 0 {
+		return nil
+	}
+	return &usageWindow{
+		p:      p,
+		values: make([]float64, 0, size),
+	}
+}
+
+// update adds the usage of the tick and returns the percentile of
+// the window. It's zero until the window is full, as the cpu usage is.
+func (w *usageWindow) update(usage float64) float64 {
+	if w == nil {
+		return usage
+	}
+	if len(w.values) < cap(w.values) {
+		w.values = append(w.values, usage)
+	} else {
+		w.values[w.next] = usage
+	}
+	w.next = (w.next + 1) % cap(w.values)
+	if len(w.values) < cap(w.values) {
+		return 0
+	}
+	sorted := append([]float64(nil), w.values...)
+	return percentile(sorted, w.p)
+}
@@ -0,0 +1,89 @@
+package autopprof
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	testCases := []struct {
+		name   string
+		values []float64
+		p      float64
+		want   float64
+	}{
+		{
+			name:   "p50",
+			values: []float64{0.5, 0.1, 0.3, 0.4, 0.2},
+			p:      0.5,
+			want:   0.3,
+		},
+		{
+			name:   "p90 ignores a single outlier",
+			values: []float64{0.2, 0.2, 0.2, 0.2, 0.2, 0.2, 0.2, 0.2, 0.2, 0.2, 0.2, 0.9},
+			p:      0.9,
+			want:   0.2,
+		},
+		{
+			name:   "p100 is the max",
+			values: []float64{0.2, 0.9, 0.1},
+			p:      1,
+			want:   0.9,
+		},
+		{
+			name: "empty",
+			p:    0.9,
+			want: 0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := percentile(tc.values, tc.p); got != tc.want {
+				t.Errorf("percentile() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCPUUsagePercentile(t *testing.T) {
+	start := time.Now()
+	// A core is used for a second in the 2nd of the 4 intervals, and
+	//  a half of a core in the others.
+	snapshots := []CPUSnapshot{
+		{Timestamp: start, Usage: 0},
+		{Timestamp: start.Add(time.Second), Usage: 500 * time.Millisecond},
+		{Timestamp: start.Add(2 * time.Second), Usage: 1500 * time.Millisecond},
+		{Timestamp: start.Add(3 * time.Second), Usage: 2000 * time.Millisecond},
+		{Timestamp: start.Add(4 * time.Second), Usage: 2500 * time.Millisecond},
+	}
+	if got := cpuUsagePercentile(snapshots, 2, 0.5); got != 0.25 {
+		t.Errorf("cpuUsagePercentile(p50) = %v, want 0.25", got)
+	}
+	if got := cpuUsagePercentile(snapshots, 2, 1); got != 0.5 {
+		t.Errorf("cpuUsagePercentile(p100) = %v, want 0.5", got)
+	}
+	if got := cpuUsagePercentile(snapshots[:1], 2, 0.5); got != 0 {
+		t.Errorf("cpuUsagePercentile() of a snapshot = %v, want 0", got)
+	}
+}
+
+func TestUsageWindow_update(t *testing.T) {
+	// A nil usageWindow passes the usages through.
+	var nilWindow *usageWindow
+	if got := nilWindow.update(0.8); got != 0.8 {
+		t.Errorf("update() of nil = %v, want 0.8", got)
+	}
+
+	w := newUsageWindow(0.5, 3)
+	var got []float64
+	for _, usage := range []float64{0.9, 0.2, 0.3, 0.9, 0.9, 0.1} {
+		got = append(got, w.update(usage))
+	}
+	want := []float64{0, 0, 0.3, 0.3, 0.9, 0.9}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("update() = %v, want %v", got, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,79 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/cgroups"
+)
+
+const (
+	cgroupV1PIDsSubsystem = "pids"
+	cgroupPIDsCurrentFile = "pids.current"
+	cgroupPIDsMaxFile     = "pids.max"
+	// cgroupPIDsUnlimited is the content of the pids.max without
+	//  the limit.
+	cgroupPIDsUnlimited = "max"
+)
+
+// pidStat is the number of the tasks (threads) of the cgroup and its
+// limit.
+type pidStat struct {
+	current uint64
+	max     uint64
+}
+
+// ratio returns the ratio of the tasks to the limit.
+func (s pidStat) ratio() float64 {
+	return float64(s.current) / float64(s.max)
+}
+
+// pidsDir returns the directory of the pids controller of the process.
+// The cgroupPath is used instead of the detected one if it's given.
+// (See Option.ContainerCgroupPath)
+func pidsDir(cgroupPath string) string {
+	if cgroups.Mode() == cgroups.Legacy {
+		dir := path.Join(cgroupV1MountPoint, cgroupV1PIDsSubsystem)
+		if cgroupPath == "" {
+			cgroupPath = detectCgroupPath(procSelfCgroupFile, dir, cgroupV1PIDsSubsystem)
+		}
+		return path.Join(dir, cgroupPath)
+	}
+	if cgroupPath == "" {
+		cgroupPath = detectCgroupPath(procSelfCgroupFile, cgroupV2MountPoint, "")
+	}
+	return path.Join(cgroupV2MountPoint, cgroupPath)
+}
+
+// newPIDUsage returns the function reading the pidStat in the dir of
+// the pids controller. It returns ErrPIDLimitUnavailable if there's no
+// limit.
+func newPIDUsage(dir string) func() (pidStat, error) {
+	return func() (pidStat, error) {
+		b, err := os.ReadFile(path.Join(dir, cgroupPIDsMaxFile))
+		if err != nil {
+			return pidStat{}, err
+		}
+		limit := strings.TrimSpace(string(b))
+		if limit == cgroupPIDsUnlimited {
+			return pidStat{}, ErrPIDLimitUnavailable
+		}
+		max, err := strconv.ParseUint(limit, 10, 64)
+		if err != nil {
+			return pidStat{}, err
+		}
+		if max == 0 {
+			return pidStat{}, ErrPIDLimitUnavailable
+		}
+		current, err := readUint(path.Join(dir, cgroupPIDsCurrentFile))
+		if err != nil {
+			return pidStat{}, err
+		}
+		return pidStat{current: current, max: max}, nil
+	}
+}
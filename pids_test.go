@@ -0,0 +1,52 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewPIDUsage(t *testing.T) {
+	testCases := []struct {
+		name    string
+		current string
+		max     string
+		want    pidStat
+		wantErr error
+	}{
+		{
+			name:    "limited",
+			current: "90\n",
+			max:     "100\n",
+			want:    pidStat{current: 90, max: 100},
+		},
+		{
+			name:    "unlimited",
+			current: "90\n",
+			max:     "max\n",
+			wantErr: ErrPIDLimitUnavailable,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, cgroupPIDsCurrentFile), []byte(tc.current), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, cgroupPIDsMaxFile), []byte(tc.max), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := newPIDUsage(dir)()
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("pidUsage() = %v, want %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("pidUsage() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"os"
+	"path"
+
+	"github.com/containerd/cgroups"
+)
+
+const (
+	cgroupV2MemPressureFile = "memory.pressure"
+
+	awsExecutionEnvFargate = "AWS_ECS_FARGATE"
+)
+
+// Probe detects what the autopprof can do in the current environment
+// without starting anything.
+func Probe() (Capabilities, error) {
+	caps := Capabilities{
+		Environment: detectEnvironment(),
+	}
+	switch cgroups.Mode() {
+	case cgroups.Legacy:
+		caps.CgroupVersion = 1
+	case cgroups.Hybrid, cgroups.Unified:
+		caps.CgroupVersion = 2
+		_, err := os.Stat(path.Join(cgroupV2MountPoint, cgroupV2MemPressureFile))
+		caps.PSIAvailable = err == nil
+	default:
+		return caps, ErrCgroupsUnavailable
+	}
+
+	qryer, err := newQueryer("")
+	if err != nil {
+		return caps, err
+	}
+	caps.CPUQuotaSet = qryer.setCPUQuota() == nil
+	if stat, err := qryer.memUsage(); err == nil {
+		caps.MemLimitSet = stat.limited()
+	}
+	return caps, nil
+}
+
+func detectEnvironment() Environment {
+	if os.Getenv("AWS_EXECUTION_ENV") == awsExecutionEnvFargate {
+		return EnvironmentAWSFargate
+	}
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return EnvironmentKubernetes
+	}
+	return EnvironmentHost
+}
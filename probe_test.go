@@ -0,0 +1,71 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"testing"
+
+	"github.com/containerd/cgroups"
+)
+
+func TestProbe(t *testing.T) {
+	mode := cgroups.Mode()
+	caps, err := Probe()
+	if mode == cgroups.Unavailable {
+		if err == nil {
+			t.Errorf("Probe() = nil, want error")
+		}
+		return
+	}
+	if err != nil {
+		t.Errorf("Probe() = %v, want nil", err)
+	}
+	wantVersion := 2
+	if mode == cgroups.Legacy {
+		wantVersion = 1
+	}
+	if caps.CgroupVersion != wantVersion {
+		t.Errorf("CgroupVersion = %d, want %d", caps.CgroupVersion, wantVersion)
+	}
+}
+
+func TestDetectEnvironment(t *testing.T) {
+	testCases := []struct {
+		name string
+		env  map[string]string
+		want Environment
+	}{
+		{
+			name: "host",
+			env:  map[string]string{},
+			want: EnvironmentHost,
+		},
+		{
+			name: "aws fargate",
+			env: map[string]string{
+				"AWS_EXECUTION_ENV": "AWS_ECS_FARGATE",
+			},
+			want: EnvironmentAWSFargate,
+		},
+		{
+			name: "kubernetes",
+			env: map[string]string{
+				"KUBERNETES_SERVICE_HOST": "10.0.0.1",
+			},
+			want: EnvironmentKubernetes,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("AWS_EXECUTION_ENV", "")
+			t.Setenv("KUBERNETES_SERVICE_HOST", "")
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			if got := detectEnvironment(); got != tc.want {
+				t.Errorf("detectEnvironment() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
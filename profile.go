@@ -3,17 +3,51 @@ package autopprof
 import (
 	"bufio"
 	"bytes"
+	"fmt"
+	"io"
+	"runtime"
 	"runtime/pprof"
+	"strings"
 	"time"
+
+	"github.com/felixge/fgprof"
 )
 
 //go:generate mockgen -source=profile.go -destination=profile_mock.go -package=autopprof
 
+const (
+	// lowPriorityCPUProfileRate is the cpu profiling rate in Hz used
+	// for the low priority capture. It's half of the default rate.
+	lowPriorityCPUProfileRate = 50
+)
+
 type profiler interface {
 	// profileCPU profiles the CPU usage for a specific duration.
+	// It returns ErrCPUProfilingInUse if the cpu profiling is already
+	// running by the others. (e.g. net/http/pprof)
 	profileCPU() ([]byte, error)
+	// writeCPUProfile profiles the CPU usage for a specific duration
+	//  and writes the profile into the w.
+	writeCPUProfile(w io.Writer) error
 	// profileHeap profiles the heap usage.
 	profileHeap() ([]byte, error)
+	// profileGoroutine profiles the stack traces of all goroutines.
+	profileGoroutine() ([]byte, error)
+	// dumpGoroutines dumps the human-readable stack traces of all
+	//  goroutines.
+	dumpGoroutines() ([]byte, error)
+	// profileBlock profiles the stack traces that led to blocking on
+	//  the synchronization primitives.
+	profileBlock() ([]byte, error)
+	// profileMutex profiles the stack traces of the holders of
+	//  the contended mutexes.
+	profileMutex() ([]byte, error)
+	// profileNamed profiles the profile of the pprof.Lookup by its name.
+	//  It returns ErrUnknownProfile if there's no such profile.
+	profileNamed(name string) ([]byte, error)
+	// profileWallClock profiles the on-CPU and off-CPU time of all
+	//  goroutines for a specific duration.
+	profileWallClock() ([]byte, error)
 }
 
 type defaultProfiler struct {
@@ -21,6 +55,20 @@ type defaultProfiler struct {
 	// the enough cpu profiling data.
 	// Default: 10s.
 	cpuProfilingDuration time.Duration
+
+	// cpuProfileRate is the sampling rate of the cpu profiling in Hz.
+	// If it's zero, the default rate of the runtime/pprof is used.
+	// Default: 0. (means 100Hz)
+	cpuProfileRate int
+
+	// lowPriority makes the profiling yield the processor before
+	//  the capture and lowers the default cpu profiling rate to reduce
+	//  the interference with the application.
+	lowPriority bool
+
+	// lockOSThread locks the capturing goroutine to its OS thread
+	//  during the profiling for the duration.
+	lockOSThread bool
 }
 
 func newDefaultProfiler(duration time.Duration) *defaultProfiler {
@@ -30,28 +78,78 @@ func newDefaultProfiler(duration time.Duration) *defaultProfiler {
 }
 
 func (p *defaultProfiler) profileCPU() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := p.writeCPUProfile(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (p *defaultProfiler) writeCPUProfile(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	rate := p.cpuProfileRate
+	if rate == 0 && p.lowPriority {
+		rate = lowPriorityCPUProfileRate
+	}
+	p.yield()
+	defer p.lockThread()()
+	if rate > 0 {
+		// The StartCPUProfile keeps the rate if it's already set, so
+		//  set the rate first. The rate is reset to zero by the
+		//  StopCPUProfile. The runtime prints a warning about it, so
+		//  it's only set if asked.
+		runtime.SetCPUProfileRate(rate)
+	}
+	if err := pprof.StartCPUProfile(bw); err != nil {
+		if isCPUProfilingInUse(err) {
+			return ErrCPUProfilingInUse
+		}
+		return err
+	}
+	<-time.After(p.cpuProfilingDuration)
+	pprof.StopCPUProfile()
+
+	return bw.Flush()
+}
+
+func (p *defaultProfiler) profileHeap() ([]byte, error) {
 	var (
 		buf bytes.Buffer
 		w   = bufio.NewWriter(&buf)
 	)
-	if err := pprof.StartCPUProfile(w); err != nil {
+	p.yield()
+	if err := pprof.WriteHeapProfile(w); err != nil {
 		return nil, err
 	}
-	<-time.After(p.cpuProfilingDuration)
-	pprof.StopCPUProfile()
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
 
+func (p *defaultProfiler) profileGoroutine() ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		w   = bufio.NewWriter(&buf)
+	)
+	p.yield()
+	if err := pprof.Lookup("goroutine").WriteTo(w, 0); err != nil {
+		return nil, err
+	}
 	if err := w.Flush(); err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
 }
 
-func (p *defaultProfiler) profileHeap() ([]byte, error) {
+func (p *defaultProfiler) dumpGoroutines() ([]byte, error) {
 	var (
 		buf bytes.Buffer
 		w   = bufio.NewWriter(&buf)
 	)
-	if err := pprof.WriteHeapProfile(w); err != nil {
+	p.yield()
+	// The debug=2 prints the stacks in the same form as the panics.
+	if err := pprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
 		return nil, err
 	}
 	if err := w.Flush(); err != nil {
@@ -59,3 +157,71 @@ func (p *defaultProfiler) profileHeap() ([]byte, error) {
 	}
 	return buf.Bytes(), nil
 }
+
+func (p *defaultProfiler) profileBlock() ([]byte, error) {
+	return p.profileNamed("block")
+}
+
+func (p *defaultProfiler) profileMutex() ([]byte, error) {
+	return p.profileNamed("mutex")
+}
+
+func (p *defaultProfiler) profileNamed(name string) ([]byte, error) {
+	prof := pprof.Lookup(name)
+	if prof == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProfile, name)
+	}
+	var (
+		buf bytes.Buffer
+		w   = bufio.NewWriter(&buf)
+	)
+	p.yield()
+	if err := prof.WriteTo(w, 0); err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (p *defaultProfiler) profileWallClock() ([]byte, error) {
+	var buf bytes.Buffer
+	p.yield()
+	defer p.lockThread()()
+	// The fgprof samples the goroutine profile instead of the cpu
+	//  profiling, so it doesn't conflict with the cpu profile captured
+	//  at the same time.
+	stop := fgprof.Start(&buf, fgprof.FormatPprof)
+	<-time.After(p.cpuProfilingDuration)
+	if err := stop(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// isCPUProfilingInUse reports whether the error of the StartCPUProfile
+// is because the cpu profiling is already running by the others.
+// (e.g. the /debug/pprof/profile of the net/http/pprof)
+// The runtime/pprof doesn't export the error, so the message is compared.
+func isCPUProfilingInUse(err error) bool {
+	return strings.Contains(err.Error(), "cpu profiling already in use")
+}
+
+// lockThread locks the goroutine to its OS thread if the thread lock is
+// enabled, and returns the function to unlock it.
+func (p *defaultProfiler) lockThread() (unlock func()) {
+	if !p.lockOSThread {
+		return func() {}
+	}
+	runtime.LockOSThread()
+	return runtime.UnlockOSThread
+}
+
+// yield lets the other goroutines run before the capture if the low
+// priority capture is enabled.
+func (p *defaultProfiler) yield() {
+	if p.lowPriority {
+		runtime.Gosched()
+	}
+}
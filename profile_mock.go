@@ -5,6 +5,7 @@
 package autopprof
 
 import (
+	io "io"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -33,6 +34,36 @@ func (m *Mockprofiler) EXPECT() *MockprofilerMockRecorder {
 	return m.recorder
 }
 
+// dumpGoroutines mocks base method.
+func (m *Mockprofiler) dumpGoroutines() ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "dumpGoroutines")
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// dumpGoroutines indicates an expected call of dumpGoroutines.
+func (mr *MockprofilerMockRecorder) dumpGoroutines() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "dumpGoroutines", reflect.TypeOf((*Mockprofiler)(nil).dumpGoroutines))
+}
+
+// profileBlock mocks base method.
+func (m *Mockprofiler) profileBlock() ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "profileBlock")
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// profileBlock indicates an expected call of profileBlock.
+func (mr *MockprofilerMockRecorder) profileBlock() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "profileBlock", reflect.TypeOf((*Mockprofiler)(nil).profileBlock))
+}
+
 // profileCPU mocks base method.
 func (m *Mockprofiler) profileCPU() ([]byte, error) {
 	m.ctrl.T.Helper()
@@ -48,6 +79,21 @@ func (mr *MockprofilerMockRecorder) profileCPU() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "profileCPU", reflect.TypeOf((*Mockprofiler)(nil).profileCPU))
 }
 
+// profileGoroutine mocks base method.
+func (m *Mockprofiler) profileGoroutine() ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "profileGoroutine")
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// profileGoroutine indicates an expected call of profileGoroutine.
+func (mr *MockprofilerMockRecorder) profileGoroutine() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "profileGoroutine", reflect.TypeOf((*Mockprofiler)(nil).profileGoroutine))
+}
+
 // profileHeap mocks base method.
 func (m *Mockprofiler) profileHeap() ([]byte, error) {
 	m.ctrl.T.Helper()
@@ -62,3 +108,62 @@ func (mr *MockprofilerMockRecorder) profileHeap() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "profileHeap", reflect.TypeOf((*Mockprofiler)(nil).profileHeap))
 }
+
+// profileMutex mocks base method.
+func (m *Mockprofiler) profileMutex() ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "profileMutex")
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// profileMutex indicates an expected call of profileMutex.
+func (mr *MockprofilerMockRecorder) profileMutex() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "profileMutex", reflect.TypeOf((*Mockprofiler)(nil).profileMutex))
+}
+
+// profileNamed mocks base method.
+func (m *Mockprofiler) profileNamed(name string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "profileNamed", name)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// profileNamed indicates an expected call of profileNamed.
+func (mr *MockprofilerMockRecorder) profileNamed(name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "profileNamed", reflect.TypeOf((*Mockprofiler)(nil).profileNamed), name)
+}
+
+// profileWallClock mocks base method.
+func (m *Mockprofiler) profileWallClock() ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "profileWallClock")
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// profileWallClock indicates an expected call of profileWallClock.
+func (mr *MockprofilerMockRecorder) profileWallClock() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "profileWallClock", reflect.TypeOf((*Mockprofiler)(nil).profileWallClock))
+}
+
+// writeCPUProfile mocks base method.
+func (m *Mockprofiler) writeCPUProfile(w io.Writer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "writeCPUProfile", w)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// writeCPUProfile indicates an expected call of writeCPUProfile.
+func (mr *MockprofilerMockRecorder) writeCPUProfile(w interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "writeCPUProfile", reflect.TypeOf((*Mockprofiler)(nil).writeCPUProfile), w)
+}
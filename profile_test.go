@@ -1,7 +1,15 @@
 package autopprof
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime/pprof"
 	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"github.com/looko-corp/autopprof/report"
 )
 
 func TestDefaultProfiler_ProfileCPU(t *testing.T) {
@@ -16,6 +24,68 @@ func TestDefaultProfiler_ProfileCPU(t *testing.T) {
 	}
 }
 
+func TestDefaultProfiler_ProfileCPU_rate(t *testing.T) {
+	p := newDefaultProfiler(1 * time.Second)
+	p.cpuProfileRate = 500
+	b, err := p.profileCPU()
+	if err != nil {
+		t.Errorf("profileCPU() = %v, want %v", err, nil)
+		t.FailNow()
+	}
+	if len(b) == 0 {
+		t.Error("len of cpu profile bytes= 0, want > 0")
+	}
+}
+
+func TestDefaultProfiler_WriteCPUProfile(t *testing.T) {
+	p := newDefaultProfiler(1 * time.Second)
+	var buf bytes.Buffer
+	if err := p.writeCPUProfile(&buf); err != nil {
+		t.Errorf("writeCPUProfile() = %v, want %v", err, nil)
+		t.FailNow()
+	}
+	if buf.Len() == 0 {
+		t.Error("len of written cpu profile = 0, want > 0")
+	}
+}
+
+func TestDefaultProfiler_ProfileCPU_inUse(t *testing.T) {
+	// Simulate the concurrent cpu profiling by the others.
+	//  (e.g. /debug/pprof/profile of the net/http/pprof)
+	if err := pprof.StartCPUProfile(io.Discard); err != nil {
+		t.Fatalf("StartCPUProfile() = %v, want nil", err)
+	}
+	defer pprof.StopCPUProfile()
+
+	p := newDefaultProfiler(1 * time.Second)
+	if _, err := p.profileCPU(); !errors.Is(err, ErrCPUProfilingInUse) {
+		t.Errorf("profileCPU() = %v, want %v", err, ErrCPUProfilingInUse)
+	}
+}
+
+func TestDefaultProfiler_ProfileNamed(t *testing.T) {
+	testCases := []struct {
+		name    string
+		wantErr error
+	}{
+		{name: "threadcreate", wantErr: nil},
+		{name: "mutex", wantErr: nil},
+		{name: "unknown", wantErr: ErrUnknownProfile},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newDefaultProfiler(defaultCPUProfilingDuration)
+			b, err := p.profileNamed(tc.name)
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("profileNamed() = %v, want %v", err, tc.wantErr)
+			}
+			if tc.wantErr == nil && len(b) == 0 {
+				t.Error("len of profile bytes = 0, want > 0")
+			}
+		})
+	}
+}
+
 func TestDefaultProfiler_ProfileHeap(t *testing.T) {
 	p := newDefaultProfiler(defaultCPUProfilingDuration)
 	b, err := p.profileHeap()
@@ -27,3 +97,109 @@ func TestDefaultProfiler_ProfileHeap(t *testing.T) {
 		t.Error("len of heap profile bytes= 0, want > 0")
 	}
 }
+
+func TestDefaultProfiler_ProfileHeap_sampleTypes(t *testing.T) {
+	p := newDefaultProfiler(defaultCPUProfilingDuration)
+	b, err := p.profileHeap()
+	if err != nil {
+		t.Fatalf("profileHeap() = %v, want %v", err, nil)
+	}
+	prof, err := profile.ParseData(b)
+	if err != nil {
+		t.Fatalf("ParseData() = %v, want %v", err, nil)
+	}
+
+	got := make(map[string]bool, len(prof.SampleType))
+	for _, st := range prof.SampleType {
+		got[st.Type] = true
+	}
+	for _, want := range []string{
+		report.SampleTypeInuseSpace,
+		report.SampleTypeInuseObjects,
+		report.SampleTypeAllocSpace,
+		report.SampleTypeAllocObjects,
+	} {
+		if !got[want] {
+			t.Errorf("sample type %s is missing in the heap profile", want)
+		}
+	}
+}
+
+func TestDefaultProfiler_lowPriority(t *testing.T) {
+	p := newDefaultProfiler(1 * time.Second)
+	p.lowPriority = true
+	b, err := p.profileCPU()
+	if err != nil {
+		t.Errorf("profileCPU() = %v, want %v", err, nil)
+		t.FailNow()
+	}
+	if len(b) == 0 {
+		t.Error("len of cpu profile bytes= 0, want > 0")
+	}
+	b, err = p.profileHeap()
+	if err != nil {
+		t.Errorf("profileHeap() = %v, want %v", err, nil)
+		t.FailNow()
+	}
+	if len(b) == 0 {
+		t.Error("len of heap profile bytes= 0, want > 0")
+	}
+}
+
+func TestDefaultProfiler_lockOSThread(t *testing.T) {
+	p := newDefaultProfiler(500 * time.Millisecond)
+	p.lockOSThread = true
+	b, err := p.profileCPU()
+	if err != nil {
+		t.Errorf("profileCPU() = %v, want %v", err, nil)
+		t.FailNow()
+	}
+	if len(b) == 0 {
+		t.Error("len of cpu profile bytes= 0, want > 0")
+	}
+	b, err = p.profileWallClock()
+	if err != nil {
+		t.Errorf("profileWallClock() = %v, want %v", err, nil)
+		t.FailNow()
+	}
+	if len(b) == 0 {
+		t.Error("len of wall-clock profile bytes= 0, want > 0")
+	}
+}
+
+func TestDefaultProfiler_ProfileGoroutine(t *testing.T) {
+	p := newDefaultProfiler(defaultCPUProfilingDuration)
+	b, err := p.profileGoroutine()
+	if err != nil {
+		t.Errorf("profileGoroutine() = %v, want %v", err, nil)
+		t.FailNow()
+	}
+	if len(b) == 0 {
+		t.Error("len of goroutine profile bytes= 0, want > 0")
+	}
+}
+
+func TestDefaultProfiler_ProfileWallClock(t *testing.T) {
+	p := newDefaultProfiler(500 * time.Millisecond)
+	b, err := p.profileWallClock()
+	if err != nil {
+		t.Errorf("profileWallClock() = %v, want %v", err, nil)
+		t.FailNow()
+	}
+	if _, err := profile.ParseData(b); err != nil {
+		t.Errorf("profile.ParseData() = %v, want the pprof format", err)
+	}
+}
+
+func TestDefaultProfiler_DumpGoroutines(t *testing.T) {
+	p := newDefaultProfiler(defaultCPUProfilingDuration)
+	b, err := p.dumpGoroutines()
+	if err != nil {
+		t.Errorf("dumpGoroutines() = %v, want %v", err, nil)
+		t.FailNow()
+	}
+	// The dump starts with the header of the current goroutine.
+	if !bytes.HasPrefix(b, []byte("goroutine ")) {
+		t.Errorf("goroutine dump = %.20q..., want the human-readable stacks", b)
+	}
+}
@@ -0,0 +1,56 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const cgroupV2CPUPressureFile = "cpu.pressure"
+
+// readPSISomeAvg10 reads the "some" avg10 of the pressure stall
+// information file (e.g. cpu.pressure) as a ratio between 0 and 1.
+// It's the share of the last 10s in which some tasks were stalled on
+// the resource. The file looks like:
+//
+//	some avg10=1.23 avg60=0.50 avg300=0.10 total=123456
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//
+// It returns ErrPSIUnavailable if the file doesn't exist.
+func readPSISomeAvg10(file string) (float64, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return 0, ErrPSIUnavailable
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "some" {
+			continue
+		}
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "avg10=") {
+				continue
+			}
+			v, err := strconv.ParseFloat(strings.TrimPrefix(field, "avg10="), 64)
+			if err != nil {
+				return 0, err
+			}
+			return v / 100, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("autopprof: no some avg10 in %s", file)
+}
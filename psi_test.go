@@ -0,0 +1,68 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadPSISomeAvg10(t *testing.T) {
+	testCases := []struct {
+		name     string
+		pressure string
+		want     float64
+		wantErr  bool
+	}{
+		{
+			name: "some and full",
+			pressure: "some avg10=12.50 avg60=3.21 avg300=0.80 total=1234567\n" +
+				"full avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+			want: 0.125,
+		},
+		{
+			name:     "no stall",
+			pressure: "some avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+			want:     0,
+		},
+		{
+			name:     "no some",
+			pressure: "full avg10=1.00 avg60=0.00 avg300=0.00 total=0\n",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid avg10",
+			pressure: "some avg10=abc avg60=0.00 avg300=0.00 total=0\n",
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), cgroupV2CPUPressureFile)
+			if err := os.WriteFile(file, []byte(tc.pressure), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readPSISomeAvg10(file)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("readPSISomeAvg10() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("readPSISomeAvg10() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	_, err := readPSISomeAvg10(filepath.Join(t.TempDir(), cgroupV2CPUPressureFile))
+	if !errors.Is(err, ErrPSIUnavailable) {
+		t.Errorf("readPSISomeAvg10() error = %v for the missing file, want %v", err, ErrPSIUnavailable)
+	}
+}
+
+func TestCgroupV1_cpuPressure(t *testing.T) {
+	if _, err := (&cgroupV1{}).cpuPressure(); !errors.Is(err, ErrPSIUnavailable) {
+		t.Errorf("cpuPressure() error = %v, want %v", err, ErrPSIUnavailable)
+	}
+}
@@ -1,9 +1,14 @@
 package autopprof
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 // cpuUsageSnapshotQueue is a circular queue of cpuUsageSnapshot.
 // It doesn't implement dequeue() method because it's not needed.
+// It's safe for the concurrent use, so the snapshots are exported
+// while the cpu watcher takes them.
 type cpuUsageSnapshotQueuer interface {
 	// Enqueue adds an element to the queue.
 	// If the queue is full, the oldest element is overwritten.
@@ -21,6 +26,10 @@ type cpuUsageSnapshotQueuer interface {
 	cap() int
 	// The number of elements that the queue holds.
 	len() int
+
+	// export returns the copies of the elements from the oldest to
+	//  the newest, whose usages are in the unit.
+	export(unit time.Duration) []CPUSnapshot
 }
 
 type cpuUsageSnapshot struct {
@@ -31,6 +40,7 @@ type cpuUsageSnapshot struct {
 }
 
 type cpuUsageSnapshotQueue struct {
+	mu      sync.Mutex
 	list    []*cpuUsageSnapshot
 	headIdx int
 	tailIdx int
@@ -43,42 +53,76 @@ func newCPUUsageSnapshotQueue(cap int) *cpuUsageSnapshotQueue {
 }
 
 func (q *cpuUsageSnapshotQueue) enqueue(cs *cpuUsageSnapshot) {
-	if q.len() == q.cap() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.list) == cap(q.list) {
 		q.list[q.tailIdx] = cs
-		q.tailIdx = (q.tailIdx + 1) % q.cap()
-		q.headIdx = (q.headIdx + 1) % q.cap()
+		q.tailIdx = (q.tailIdx + 1) % cap(q.list)
+		q.headIdx = (q.headIdx + 1) % cap(q.list)
 	} else {
 		q.list = append(q.list, cs)
-		q.tailIdx = (q.tailIdx + 1) % q.cap()
+		q.tailIdx = (q.tailIdx + 1) % cap(q.list)
 	}
 }
 
 func (q *cpuUsageSnapshotQueue) head() *cpuUsageSnapshot {
-	if q.len() == 0 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.list) == 0 {
 		return nil
 	}
 	return q.list[q.headIdx]
 }
 
 func (q *cpuUsageSnapshotQueue) tail() *cpuUsageSnapshot {
-	if q.len() == 0 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.list) == 0 {
 		return nil
 	}
 	baseIdx := q.tailIdx
 	if baseIdx == 0 {
-		baseIdx = q.cap()
+		baseIdx = cap(q.list)
 	}
-	return q.list[(baseIdx-1)%q.cap()]
+	return q.list[(baseIdx-1)%cap(q.list)]
 }
 
 func (q *cpuUsageSnapshotQueue) isFull() bool {
-	return q.len() == q.cap()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.list) == cap(q.list)
 }
 
 func (q *cpuUsageSnapshotQueue) cap() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
 	return cap(q.list)
 }
 
 func (q *cpuUsageSnapshotQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
 	return len(q.list)
 }
+
+func (q *cpuUsageSnapshotQueue) export(unit time.Duration) []CPUSnapshot {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	snapshots := make([]CPUSnapshot, 0, len(q.list))
+	for i := 0; i < len(q.list); i++ {
+		// The oldest one is at the head once the queue is full.
+		cs := q.list[(q.headIdx+i)%len(q.list)]
+		snapshots = append(snapshots, CPUSnapshot{
+			Timestamp: cs.timestamp,
+			Usage:     time.Duration(cs.usage) * unit,
+		})
+	}
+	return snapshots
+}
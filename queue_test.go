@@ -299,6 +299,55 @@ func TestCPUUsageSnapshotQueue_len(t *testing.T) {
 	}
 }
 
+func TestCPUUsageSnapshotQueue_export(t *testing.T) {
+	testCases := []struct {
+		name  string
+		count int
+		want  []uint64
+	}{
+		{
+			name:  "empty",
+			count: 0,
+			want:  []uint64{},
+		},
+		{
+			name:  "partially filled",
+			count: 2,
+			want:  []uint64{0, 1},
+		},
+		{
+			name:  "wrapped around",
+			count: 5,
+			want:  []uint64{2, 3, 4},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q := newCPUUsageSnapshotQueue(3)
+			for i := 0; i < tc.count; i++ {
+				q.enqueue(&cpuUsageSnapshot{
+					usage:     uint64(i),
+					timestamp: testTimestamp.Add(time.Duration(i) * time.Second),
+				})
+			}
+
+			got := q.export(time.Microsecond)
+			if len(got) != len(tc.want) {
+				t.Fatalf("export() = %v, want %d snapshots", got, len(tc.want))
+			}
+			for i, usage := range tc.want {
+				want := CPUSnapshot{
+					Timestamp: testTimestamp.Add(time.Duration(usage) * time.Second),
+					Usage:     time.Duration(usage) * time.Microsecond,
+				}
+				if got[i] != want {
+					t.Errorf("export()[%d] = %+v, want %+v", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
 func equalCPUUsageSnapshotSlice(a []*cpuUsageSnapshot, b []*cpuUsageSnapshot) bool {
 	if len(a) != len(b) {
 		return false
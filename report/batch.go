@@ -0,0 +1,273 @@
+package report
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBatchMaxProfiles   = 10
+	defaultBatchMaxBytes      = 8 << 20 // 8MiB.
+	defaultBatchFlushInterval = time.Minute
+	defaultBatchFlushTimeout  = 30 * time.Second
+
+	// BundleFilenameFmt is the filename format for the bundle of
+	// the batched profiles.
+	// bundle.<app>.<hostname>.<report_time>.tar.
+	BundleFilenameFmt = "bundle.%s.%s.%s.tar"
+)
+
+// BundleInfo is the information about the bundle of the profiles.
+type BundleInfo struct {
+	// SchemaVersion is the SchemaVersion the struct is filled with.
+	SchemaVersion int
+
+	// Count is the number of the profiles in the bundle.
+	Count int
+	// IncidentID is the incident of the profiles in the bundle.
+	//  (See CPUInfo.IncidentID) A bundle doesn't mix the profiles of
+	//  the different incidents.
+	IncidentID string
+	// FirstReportedAt and LastReportedAt are when the first and
+	//  the last profiles in the bundle are reported.
+	FirstReportedAt time.Time
+	LastReportedAt  time.Time
+}
+
+// BatchReporter accumulates the profiles across the triggers and
+// flushes them as a single bundle to the inner reporter, to reduce
+// the per-request overhead on the sink for the low-severity continuous
+// profiling.
+// The batch is flushed when it has the MaxProfiles profiles or
+// the MaxBytes bytes, or the FlushInterval passes since its first
+// profile. It's also flushed before a profile of the other incident,
+// so a bundle is the correlated profiles of an incident.
+//
+// The bundle is a tar archive. For the i-th profile, it has
+// <i>.<kind>.pprof with the profiling data and <i>.<kind>.json with
+// the JSON encoded CPUInfo, MemInfo or GoroutineInfo.
+type BatchReporter struct {
+	inner BundleReporter
+
+	maxProfiles   int
+	maxBytes      int64
+	flushInterval time.Duration
+
+	mu      sync.Mutex
+	entries []batchEntry
+	size    int64
+	timer   *time.Timer
+	// flushErr is the error of the last flush by the timer, returned
+	//  by the next call.
+	flushErr error
+}
+
+// BatchReporterOption is the option for the batch reporter.
+type BatchReporterOption struct {
+	// MaxProfiles is the maximum number of the profiles in a batch.
+	// Default: 10.
+	MaxProfiles int
+	// MaxBytes is the maximum size of the profiles in a batch.
+	// Default: 8MiB.
+	MaxBytes int64
+	// FlushInterval is the maximum time a profile waits in a batch.
+	// Default: 1m.
+	FlushInterval time.Duration
+}
+
+type batchEntry struct {
+	kind       ProfileKind
+	data       []byte
+	info       interface{}
+	reportedAt time.Time
+}
+
+// NewBatchReporter returns the BatchReporter wrapping the inner
+// reporter.
+func NewBatchReporter(inner BundleReporter, opt *BatchReporterOption) *BatchReporter {
+	b := &BatchReporter{
+		inner:         inner,
+		maxProfiles:   opt.MaxProfiles,
+		maxBytes:      opt.MaxBytes,
+		flushInterval: opt.FlushInterval,
+	}
+	if b.maxProfiles <= 0 {
+		b.maxProfiles = defaultBatchMaxProfiles
+	}
+	if b.maxBytes <= 0 {
+		b.maxBytes = defaultBatchMaxBytes
+	}
+	if b.flushInterval <= 0 {
+		b.flushInterval = defaultBatchFlushInterval
+	}
+	return b
+}
+
+// ReportCPUProfile adds the CPU profiling data to the batch.
+func (b *BatchReporter) ReportCPUProfile(
+	ctx context.Context, r io.Reader, ci CPUInfo,
+) error {
+	return b.add(ctx, ProfileKindCPU, r, ci)
+}
+
+// ReportHeapProfile adds the heap profiling data to the batch.
+func (b *BatchReporter) ReportHeapProfile(
+	ctx context.Context, r io.Reader, mi MemInfo,
+) error {
+	return b.add(ctx, ProfileKindHeap, r, mi)
+}
+
+// ReportGoroutineProfile adds the goroutine profiling data to the batch.
+func (b *BatchReporter) ReportGoroutineProfile(
+	ctx context.Context, r io.Reader, gi GoroutineInfo,
+) error {
+	return b.add(ctx, ProfileKindGoroutine, r, gi)
+}
+
+// Flush sends the batched profiles to the inner reporter.
+func (b *BatchReporter) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.takeFlushErr(); err != nil {
+		return err
+	}
+	return b.flushLocked(ctx)
+}
+
+func (b *BatchReporter) add(
+	ctx context.Context, kind ProfileKind, r io.Reader, info interface{},
+) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to read the profile: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.entries) > 0 && incidentOf(b.entries[0].info) != incidentOf(info) {
+		if err := b.flushLocked(ctx); err != nil {
+			return err
+		}
+	}
+	b.entries = append(b.entries, batchEntry{
+		kind:       kind,
+		data:       data,
+		info:       info,
+		reportedAt: time.Now(),
+	})
+	b.size += int64(len(data))
+	if len(b.entries) >= b.maxProfiles || b.size >= b.maxBytes {
+		if err := b.flushLocked(ctx); err != nil {
+			return err
+		}
+	} else if b.timer == nil {
+		b.timer = time.AfterFunc(b.flushInterval, b.flushByTimer)
+	}
+	return b.takeFlushErr()
+}
+
+func (b *BatchReporter) flushByTimer() {
+	timeout := defaultBatchFlushTimeout
+	if tr, ok := b.inner.(TimeoutReporter); ok && tr.Timeout() > 0 {
+		timeout = tr.Timeout()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.timer = nil
+	if err := b.flushLocked(ctx); err != nil {
+		b.flushErr = err
+	}
+}
+
+// flushLocked sends the batch. The b.mu must be held.
+func (b *BatchReporter) flushLocked(ctx context.Context) error {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.entries) == 0 {
+		return nil
+	}
+	entries := b.entries
+	b.entries, b.size = nil, 0
+
+	bundle, err := writeBundle(entries)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to bundle the profiles: %w", err)
+	}
+	bi := BundleInfo{
+		SchemaVersion:   SchemaVersion,
+		Count:           len(entries),
+		IncidentID:      incidentOf(entries[0].info),
+		FirstReportedAt: entries[0].reportedAt,
+		LastReportedAt:  entries[len(entries)-1].reportedAt,
+	}
+	return b.inner.ReportBundle(ctx, bytes.NewReader(bundle), bi)
+}
+
+func (b *BatchReporter) takeFlushErr() error {
+	err := b.flushErr
+	b.flushErr = nil
+	return err
+}
+
+// incidentOf returns the incident id of the info.
+func incidentOf(info interface{}) string {
+	switch i := info.(type) {
+	case CPUInfo:
+		return i.IncidentID
+	case MemInfo:
+		return i.IncidentID
+	case GoroutineInfo:
+		return i.IncidentID
+	}
+	return ""
+}
+
+// writeBundle returns the tar archive of the entries.
+func writeBundle(entries []batchEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i, e := range entries {
+		metadata, err := json.Marshal(e.info)
+		if err != nil {
+			return nil, err
+		}
+		name := fmt.Sprintf("%03d.%s", i, e.kind)
+		if err := writeTarFile(tw, name+".pprof", e.data, e.reportedAt); err != nil {
+			return nil, err
+		}
+		if err := writeTarFile(tw, name+".json", metadata, e.reportedAt); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
@@ -0,0 +1,197 @@
+package report
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+)
+
+// readBundle returns the files in the bundle by their names.
+func readBundle(t *testing.T, r io.Reader) map[string][]byte {
+	t.Helper()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatalf("tar.Next() = %v, want nil", err)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("io.ReadAll() = %v, want nil", err)
+		}
+		files[hdr.Name] = b
+	}
+}
+
+func TestBatchReporter_maxProfiles(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var (
+		files map[string][]byte
+		info  BundleInfo
+	)
+	mockReporter := NewMockBundleReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportBundle(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, r io.Reader, bi BundleInfo) error {
+				files = readBundle(t, r)
+				info = bi
+				return nil
+			},
+		)
+
+	b := NewBatchReporter(mockReporter, &BatchReporterOption{
+		MaxProfiles:   2,
+		FlushInterval: time.Hour,
+	})
+	ctx := context.Background()
+	ci := CPUInfo{SchemaVersion: SchemaVersion, UsagePercentage: 80}
+	if err := b.ReportCPUProfile(ctx, strings.NewReader("cpu"), ci); err != nil {
+		t.Fatalf("ReportCPUProfile() = %v, want nil", err)
+	}
+	if files != nil {
+		t.Fatalf("bundle is flushed with 1 profile, want to wait for 2")
+	}
+	mi := MemInfo{SchemaVersion: SchemaVersion, UsagePercentage: 90}
+	if err := b.ReportHeapProfile(ctx, strings.NewReader("heap"), mi); err != nil {
+		t.Fatalf("ReportHeapProfile() = %v, want nil", err)
+	}
+
+	if info.Count != 2 || info.SchemaVersion != SchemaVersion {
+		t.Errorf("bundle info = %+v, want 2 profiles", info)
+	}
+	if got := string(files["000.cpu.pprof"]); got != "cpu" {
+		t.Errorf("000.cpu.pprof = %q, want %q", got, "cpu")
+	}
+	if got := string(files["001.heap.pprof"]); got != "heap" {
+		t.Errorf("001.heap.pprof = %q, want %q", got, "heap")
+	}
+	var gotMI MemInfo
+	if err := json.Unmarshal(files["001.heap.json"], &gotMI); err != nil || !reflect.DeepEqual(gotMI, mi) {
+		t.Errorf("001.heap.json = %s, want the JSON encoded %+v", files["001.heap.json"], mi)
+	}
+}
+
+func TestBatchReporter_incident(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var infos []BundleInfo
+	mockReporter := NewMockBundleReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportBundle(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(2).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, bi BundleInfo) error {
+				infos = append(infos, bi)
+				return nil
+			},
+		)
+
+	b := NewBatchReporter(mockReporter, &BatchReporterOption{
+		FlushInterval: time.Hour,
+	})
+	ctx := context.Background()
+	if err := b.ReportCPUProfile(
+		ctx, strings.NewReader("cpu"), CPUInfo{IncidentID: "a"},
+	); err != nil {
+		t.Fatalf("ReportCPUProfile() = %v, want nil", err)
+	}
+	if err := b.ReportHeapProfile(
+		ctx, strings.NewReader("heap"), MemInfo{IncidentID: "a"},
+	); err != nil {
+		t.Fatalf("ReportHeapProfile() = %v, want nil", err)
+	}
+	// The profile of the other incident flushes the bundle of the incident.
+	if err := b.ReportGoroutineProfile(
+		ctx, strings.NewReader("goroutine"), GoroutineInfo{IncidentID: "b"},
+	); err != nil {
+		t.Fatalf("ReportGoroutineProfile() = %v, want nil", err)
+	}
+	if err := b.Flush(ctx); err != nil {
+		t.Fatalf("Flush() = %v, want nil", err)
+	}
+
+	want := []BundleInfo{
+		{IncidentID: "a", Count: 2},
+		{IncidentID: "b", Count: 1},
+	}
+	if len(infos) != len(want) {
+		t.Fatalf("bundles = %+v, want %d", infos, len(want))
+	}
+	for i, bi := range infos {
+		if bi.IncidentID != want[i].IncidentID || bi.Count != want[i].Count {
+			t.Errorf("bundle[%d] = %+v, want %+v", i, bi, want[i])
+		}
+	}
+}
+
+func TestBatchReporter_flushInterval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	flushedC := make(chan BundleInfo, 1)
+	mockReporter := NewMockBundleReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportBundle(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, bi BundleInfo) error {
+				flushedC <- bi
+				return nil
+			},
+		)
+
+	b := NewBatchReporter(mockReporter, &BatchReporterOption{
+		FlushInterval: 100 * time.Millisecond,
+	})
+	if err := b.ReportGoroutineProfile(
+		context.Background(), strings.NewReader("goroutine"), GoroutineInfo{},
+	); err != nil {
+		t.Fatalf("ReportGoroutineProfile() = %v, want nil", err)
+	}
+	select {
+	case bi := <-flushedC:
+		if bi.Count != 1 {
+			t.Errorf("bundle info = %+v, want 1 profile", bi)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("bundle isn't flushed after the flush interval")
+	}
+}
+
+func TestBatchReporter_Flush(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockReporter := NewMockBundleReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportBundle(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(nil)
+
+	b := NewBatchReporter(mockReporter, &BatchReporterOption{
+		FlushInterval: time.Hour,
+	})
+	ctx := context.Background()
+	if err := b.ReportCPUProfile(ctx, bytes.NewReader([]byte("cpu")), CPUInfo{}); err != nil {
+		t.Fatalf("ReportCPUProfile() = %v, want nil", err)
+	}
+	if err := b.Flush(ctx); err != nil {
+		t.Errorf("Flush() = %v, want nil", err)
+	}
+	// Nothing to flush.
+	if err := b.Flush(ctx); err != nil {
+		t.Errorf("Flush() = %v, want nil", err)
+	}
+}
@@ -0,0 +1,231 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/pprof/profile"
+)
+
+// dedupTopFrames is the number of the frames from the leaf of the hot
+// path which make the signature of the profile.
+const dedupTopFrames = 5
+
+// StackDedupReporter suppresses the profiles whose dominant hot path is
+// the same as the one reported within the window, so the repetitive
+// captures of an ongoing incident aren't reported again and again.
+// The signature of a profile is the top frames of its hottest stack,
+// so the profiles with the same hot path are the duplicates even if
+// their bytes differ.
+// The profiles which can't be parsed (e.g. the goroutine dumps) are
+// always reported.
+type StackDedupReporter struct {
+	inner  Reporter
+	window time.Duration
+
+	mu sync.Mutex
+	// reportedAt is when the signatures were reported last.
+	reportedAt map[string]time.Time
+	// suppressed is the number of the suppressed profiles by the kind.
+	suppressed map[ProfileKind]int
+}
+
+// WithStackDedup returns the StackDedupReporter wrapping the inner
+// reporter. The window is the duration to suppress the duplicates of
+// a reported profile.
+func WithStackDedup(inner Reporter, window time.Duration) *StackDedupReporter {
+	return &StackDedupReporter{
+		inner:      inner,
+		window:     window,
+		reportedAt: make(map[string]time.Time),
+		suppressed: make(map[ProfileKind]int),
+	}
+}
+
+// Suppressed returns the number of the suppressed profiles by the kind.
+func (d *StackDedupReporter) Suppressed() map[ProfileKind]int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	suppressed := make(map[ProfileKind]int, len(d.suppressed))
+	for k, n := range d.suppressed {
+		suppressed[k] = n
+	}
+	return suppressed
+}
+
+// Timeout returns the timeout of the inner reporter if it's
+// a TimeoutReporter. Otherwise, it returns zero.
+func (d *StackDedupReporter) Timeout() time.Duration {
+	if tr, ok := d.inner.(TimeoutReporter); ok {
+		return tr.Timeout()
+	}
+	return 0
+}
+
+// Ping checks the inner reporter if it's a PingReporter.
+func (d *StackDedupReporter) Ping(ctx context.Context) error {
+	if pr, ok := d.inner.(PingReporter); ok {
+		return pr.Ping(ctx)
+	}
+	return nil
+}
+
+// Flush flushes the inner reporter if it's a Flusher.
+func (d *StackDedupReporter) Flush(ctx context.Context) error {
+	if f, ok := d.inner.(Flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+// ReportCPUProfile sends the CPU profiling data to the inner reporter
+// unless it's a duplicate.
+func (d *StackDedupReporter) ReportCPUProfile(
+	ctx context.Context, r io.Reader, ci CPUInfo,
+) error {
+	return d.report(r, ProfileKindCPU, ci.ContentEncoding, func(pr io.Reader) error {
+		return d.inner.ReportCPUProfile(ctx, pr, ci)
+	})
+}
+
+// ReportHeapProfile sends the heap profiling data to the inner reporter
+// unless it's a duplicate.
+func (d *StackDedupReporter) ReportHeapProfile(
+	ctx context.Context, r io.Reader, mi MemInfo,
+) error {
+	kind := ProfileKindHeap
+	if mi.SampleType != "" {
+		// The views of a heap profile aren't the duplicates of each other.
+		kind = ProfileKind(string(ProfileKindHeap) + "/" + mi.SampleType)
+	}
+	return d.report(r, kind, mi.ContentEncoding, func(pr io.Reader) error {
+		return d.inner.ReportHeapProfile(ctx, pr, mi)
+	})
+}
+
+// ReportGoroutineProfile sends the goroutine profiling data to the inner
+// reporter unless it's a duplicate.
+func (d *StackDedupReporter) ReportGoroutineProfile(
+	ctx context.Context, r io.Reader, gi GoroutineInfo,
+) error {
+	return d.report(r, ProfileKindGoroutine, gi.ContentEncoding, func(pr io.Reader) error {
+		return d.inner.ReportGoroutineProfile(ctx, pr, gi)
+	})
+}
+
+// ReportProfile sends the profiling data of the kind to the inner
+// reporter unless it's a duplicate. (See the ReportProfile function)
+func (d *StackDedupReporter) ReportProfile(
+	ctx context.Context, r io.Reader, kind ProfileKind, pi ProfileInfo,
+) error {
+	return d.report(r, kind, pi.ContentEncoding, func(pr io.Reader) error {
+		return ReportProfile(ctx, d.inner, pr, kind, pi)
+	})
+}
+
+func (d *StackDedupReporter) report(
+	r io.Reader, kind ProfileKind, encoding ContentEncoding,
+	fn func(pr io.Reader) error,
+) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to read the profile: %w", err)
+	}
+	sig, ok := stackSignature(b, encoding)
+	if !ok {
+		return fn(bytes.NewReader(b))
+	}
+	key := string(kind) + ":" + sig
+
+	now := time.Now()
+	d.mu.Lock()
+	for k, at := range d.reportedAt {
+		if now.Sub(at) >= d.window {
+			delete(d.reportedAt, k)
+		}
+	}
+	if _, dup := d.reportedAt[key]; dup {
+		d.suppressed[kind]++
+		d.mu.Unlock()
+		return nil
+	}
+	d.mu.Unlock()
+
+	if err := fn(bytes.NewReader(b)); err != nil {
+		// Don't suppress the retry of the failed report.
+		return err
+	}
+	d.mu.Lock()
+	d.reportedAt[key] = now
+	d.mu.Unlock()
+	return nil
+}
+
+// stackSignature returns the signature of the top frames of the hottest
+// stack in the profile by the last sample type. (e.g. cpu nanoseconds,
+// inuse_space) It returns false if the profile can't be parsed or has
+// no samples.
+func stackSignature(b []byte, encoding ContentEncoding) (string, bool) {
+	var src io.Reader = bytes.NewReader(b)
+	if encoding == ContentEncodingZstd {
+		rc, err := DecompressZstd(src)
+		if err != nil {
+			return "", false
+		}
+		defer rc.Close()
+		src = rc
+	}
+	p, err := profile.Parse(src)
+	if err != nil || len(p.SampleType) == 0 {
+		return "", false
+	}
+	idx := len(p.SampleType) - 1
+
+	// Sum the values by the top frames, since the samples of the same
+	//  hot path may differ in their callers.
+	values := make(map[string]int64)
+	for _, s := range p.Sample {
+		values[topFrames(s)] += s.Value[idx]
+	}
+	var (
+		hottest      string
+		hottestValue int64
+	)
+	for frames, v := range values {
+		// Break the tie by the frames to be deterministic.
+		if v > hottestValue || (v == hottestValue && frames < hottest) {
+			hottest, hottestValue = frames, v
+		}
+	}
+	if hottestValue == 0 {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(hottest))
+	return hex.EncodeToString(sum[:8]), true
+}
+
+// topFrames returns the function names of the frames from the leaf of
+// the sample up to the dedupTopFrames.
+func topFrames(s *profile.Sample) string {
+	var frames []string
+	for _, loc := range s.Location {
+		for _, line := range loc.Line {
+			if len(frames) == dedupTopFrames {
+				return strings.Join(frames, ";")
+			}
+			if line.Function == nil {
+				continue
+			}
+			frames = append(frames, line.Function.Name)
+		}
+	}
+	return strings.Join(frames, ";")
+}
@@ -0,0 +1,133 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/pprof/profile"
+)
+
+// hotPathProfile returns the gzipped cpu profile whose hottest stack is
+// the leaf function with the value, and a colder stack with the other.
+func hotPathProfile(t *testing.T, leaf string, value int64, other string) []byte {
+	t.Helper()
+
+	var (
+		fnLeaf  = &profile.Function{ID: 1, Name: leaf}
+		fnOther = &profile.Function{ID: 2, Name: other}
+		fnMain  = &profile.Function{ID: 3, Name: "main.main"}
+
+		locLeaf  = &profile.Location{ID: 1, Line: []profile.Line{{Function: fnLeaf}}}
+		locOther = &profile.Location{ID: 2, Line: []profile.Line{{Function: fnOther}}}
+		locMain  = &profile.Location{ID: 3, Line: []profile.Line{{Function: fnMain}}}
+	)
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		Sample: []*profile.Sample{
+			{Location: []*profile.Location{locLeaf, locMain}, Value: []int64{value, value}},
+			{Location: []*profile.Location{locOther, locMain}, Value: []int64{1, 1}},
+		},
+		Location: []*profile.Location{locLeaf, locOther, locMain},
+		Function: []*profile.Function{fnLeaf, fnOther, fnMain},
+	}
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestStackDedupReporter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockReporter := NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(2).
+		Return(nil)
+
+	d := WithStackDedup(mockReporter, time.Minute)
+	ctx := context.Background()
+	for _, b := range [][]byte{
+		hotPathProfile(t, "main.hot", 100, "main.cold"),
+		// Same hot path with the different bytes.
+		hotPathProfile(t, "main.hot", 200, "main.colder"),
+		// Different hot path.
+		hotPathProfile(t, "main.other", 100, "main.cold"),
+	} {
+		if err := d.ReportCPUProfile(ctx, bytes.NewReader(b), CPUInfo{}); err != nil {
+			t.Fatalf("ReportCPUProfile() = %v, want nil", err)
+		}
+	}
+	if got := d.Suppressed()[ProfileKindCPU]; got != 1 {
+		t.Errorf("suppressed cpu profiles = %d, want 1", got)
+	}
+}
+
+func TestStackDedupReporter_window(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockReporter := NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(2).
+		Return(nil)
+
+	d := WithStackDedup(mockReporter, 100*time.Millisecond)
+	b := hotPathProfile(t, "main.hot", 100, "main.cold")
+	ctx := context.Background()
+	if err := d.ReportCPUProfile(ctx, bytes.NewReader(b), CPUInfo{}); err != nil {
+		t.Fatalf("ReportCPUProfile() = %v, want nil", err)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if err := d.ReportCPUProfile(ctx, bytes.NewReader(b), CPUInfo{}); err != nil {
+		t.Fatalf("ReportCPUProfile() = %v, want nil", err)
+	}
+	if got := d.Suppressed()[ProfileKindCPU]; got != 0 {
+		t.Errorf("suppressed cpu profiles = %d, want 0", got)
+	}
+}
+
+func TestStackDedupReporter_unparsable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockReporter := NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportGoroutineProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(2).
+		Return(nil)
+
+	d := WithStackDedup(mockReporter, time.Minute)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		dump := bytes.NewReader([]byte("goroutine 1 [running]:\nmain.main()\n"))
+		if err := d.ReportGoroutineProfile(ctx, dump, GoroutineInfo{Dump: true}); err != nil {
+			t.Fatalf("ReportGoroutineProfile() = %v, want nil", err)
+		}
+	}
+}
+
+func TestStackDedupReporter_PingFlush(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	errPing := errors.New("ping")
+	pinger := NewMockPingReporter(ctrl)
+	pinger.EXPECT().Ping(gomock.Any()).Return(errPing)
+	if err := WithStackDedup(pinger, time.Minute).Ping(context.Background()); !errors.Is(err, errPing) {
+		t.Errorf("Ping() = %v, want %v", err, errPing)
+	}
+
+	errFlush := errors.New("flush")
+	flusher := flushReporter{NewMockReporter(ctrl), NewMockFlusher(ctrl)}
+	flusher.MockFlusher.EXPECT().Flush(gomock.Any()).Return(errFlush)
+	if err := WithStackDedup(flusher, time.Minute).Flush(context.Background()); !errors.Is(err, errFlush) {
+		t.Errorf("Flush() = %v, want %v", err, errFlush)
+	}
+}
@@ -0,0 +1,297 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultElasticsearchIndex            = "autopprof-{date}"
+	defaultElasticsearchIndexDateLayout  = "2006.01.02"
+	defaultElasticsearchMaxDocumentBytes = 10 << 20 // 10MiB.
+
+	// elasticsearchIndexDate is the placeholder of the date in
+	//  the index name.
+	elasticsearchIndexDate = "{date}"
+	// elasticsearchErrorBodyBytes is the maximum size of the error
+	//  response in the error.
+	elasticsearchErrorBodyBytes = 1 << 10
+)
+
+// ErrElasticsearchIndex is the error of the indexing by
+// the ElasticsearchReporter. The error has the status code and
+// the response of the cluster.
+var ErrElasticsearchIndex = errors.New("autopprof: failed to index the profile")
+
+// ElasticsearchReporter is the reporter to index a document per report
+// to the Elasticsearch or the OpenSearch, so the profiles are searchable
+// alongside the logs in the Kibana.
+//
+// The document has the metadata under the "autopprof" field, (e.g.
+// autopprof.UsagePercentage) the kind, the filename, the size and
+// the severity of the profile, and the profiling data as the base64
+// "data" field. With the ElasticsearchReporterOption.Pipeline, it's
+// indexed through the pipeline, e.g. the one of the attachment
+// processor reading the "data" field.
+//
+// The clusters limit the size of a request, so the profile larger than
+// the ElasticsearchReporterOption.MaxDocumentBytes isn't in
+// the document. The document refers to where the profile is stored by
+// the "location" field instead with the Location option. Otherwise,
+// the report fails.
+type ElasticsearchReporter struct {
+	app             string
+	url             string
+	index           string
+	indexDateLayout string
+	pipeline        string
+	client          *http.Client
+
+	username string
+	password string
+	apiKey   string
+	headers  map[string]string
+
+	maxDocumentBytes int
+	location         func(kind ProfileKind, info interface{}) string
+}
+
+// ElasticsearchReporterOption is the option for the Elasticsearch
+// reporter.
+type ElasticsearchReporterOption struct {
+	App string
+	// URL is the base URL of the cluster. (e.g. https://es:9200)
+	URL string
+	// Index is the name of the index to write to. The "{date}" in it is
+	//  replaced with the UTC date of the report formatted by
+	//  the IndexDateLayout, so the indices roll over by the date.
+	// Default: "autopprof-{date}" and "2006.01.02".
+	Index           string
+	IndexDateLayout string
+	// Pipeline is the ingest pipeline to index the documents through.
+	// Default: "". (means none)
+	Pipeline string
+	// Client is the HTTP client to index.
+	// Default: http.DefaultClient.
+	Client *http.Client
+
+	// Username and Password are the basic authentication, and APIKey
+	//  is the base64 encoded API key of the cluster. The Headers are
+	//  the other headers of the requests.
+	// Default: none.
+	Username string
+	Password string
+	APIKey   string
+	Headers  map[string]string
+
+	// MaxDocumentBytes is the maximum size of the profiling data in
+	//  a document.
+	// Default: 10MiB.
+	MaxDocumentBytes int
+	// Location returns where the profile is stored by the storage
+	//  reporter, which is indexed as the "location" field instead of
+	//  the profile larger than the MaxDocumentBytes. (See
+	//  OTLPLogReporterOption.Location)
+	// Default: nil. (means the large profile fails)
+	Location func(kind ProfileKind, info interface{}) string
+}
+
+// NewElasticsearchReporter returns the new ElasticsearchReporter.
+func NewElasticsearchReporter(opt *ElasticsearchReporterOption) *ElasticsearchReporter {
+	e := &ElasticsearchReporter{
+		app:              opt.App,
+		url:              strings.TrimSuffix(opt.URL, "/"),
+		index:            opt.Index,
+		indexDateLayout:  opt.IndexDateLayout,
+		pipeline:         opt.Pipeline,
+		client:           opt.Client,
+		username:         opt.Username,
+		password:         opt.Password,
+		apiKey:           opt.APIKey,
+		headers:          opt.Headers,
+		maxDocumentBytes: opt.MaxDocumentBytes,
+		location:         opt.Location,
+	}
+	if e.index == "" {
+		e.index = defaultElasticsearchIndex
+	}
+	if e.indexDateLayout == "" {
+		e.indexDateLayout = defaultElasticsearchIndexDateLayout
+	}
+	if e.client == nil {
+		e.client = http.DefaultClient
+	}
+	if e.maxDocumentBytes <= 0 {
+		e.maxDocumentBytes = defaultElasticsearchMaxDocumentBytes
+	}
+	return e
+}
+
+// Ping checks that the cluster is reachable and the credentials are
+// accepted.
+func (e *ElasticsearchReporter) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, e.url, nil)
+	if err != nil {
+		return err
+	}
+	e.setHeaders(req)
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to reach the cluster: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("autopprof: cluster responded %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ReportCPUProfile indexes the CPU profiling data.
+func (e *ElasticsearchReporter) ReportCPUProfile(
+	ctx context.Context, r io.Reader, ci CPUInfo,
+) error {
+	hostname, _ := os.Hostname() // Don't care about this error.
+	now := reportTime(ci.Sequence)
+	filename := fmt.Sprintf(CPUProfileFilenameFmt, e.app, hostname, now) + ci.ContentEncoding.Suffix()
+	return e.indexProfile(ctx, r, ProfileKindCPU, filename, ci)
+}
+
+// ReportHeapProfile indexes the heap profiling data.
+func (e *ElasticsearchReporter) ReportHeapProfile(
+	ctx context.Context, r io.Reader, mi MemInfo,
+) error {
+	hostname, _ := os.Hostname() // Don't care about this error.
+	now := reportTime(mi.Sequence)
+	filename := fmt.Sprintf(HeapProfileFilenameFmt, e.app, hostname, now) + mi.ContentEncoding.Suffix()
+	if mi.SampleType != "" {
+		filename = fmt.Sprintf(HeapViewProfileFilenameFmt, e.app, hostname, mi.SampleType, now) + mi.ContentEncoding.Suffix()
+	}
+	return e.indexProfile(ctx, r, ProfileKindHeap, filename, mi)
+}
+
+// ReportGoroutineProfile indexes the goroutine profiling data.
+func (e *ElasticsearchReporter) ReportGoroutineProfile(
+	ctx context.Context, r io.Reader, gi GoroutineInfo,
+) error {
+	hostname, _ := os.Hostname() // Don't care about this error.
+	now := reportTime(gi.Sequence)
+	filename := fmt.Sprintf(GoroutineProfileFilenameFmt, e.app, hostname, now) + gi.ContentEncoding.Suffix()
+	if gi.Dump {
+		filename = fmt.Sprintf(GoroutineDumpFilenameFmt, e.app, hostname, now) + gi.ContentEncoding.Suffix()
+	}
+	return e.indexProfile(ctx, r, ProfileKindGoroutine, filename, gi)
+}
+
+// ReportProfile indexes the profiling data of the kind.
+func (e *ElasticsearchReporter) ReportProfile(
+	ctx context.Context, r io.Reader, kind ProfileKind, pi ProfileInfo,
+) error {
+	hostname, _ := os.Hostname() // Don't care about this error.
+	now := reportTime(pi.Sequence)
+	filename := fmt.Sprintf(NamedProfileFilenameFmt, e.app, hostname, kind, now) + pi.ContentEncoding.Suffix()
+	return e.indexProfile(ctx, r, kind, filename, pi)
+}
+
+// elasticsearchDocument is the document of a report.
+type elasticsearchDocument struct {
+	Timestamp time.Time   `json:"@timestamp"`
+	App       string      `json:"app"`
+	Host      string      `json:"host"`
+	Kind      ProfileKind `json:"kind"`
+	Filename  string      `json:"filename"`
+	Size      int         `json:"size"`
+	Severity  string      `json:"severity"`
+	Autopprof interface{} `json:"autopprof"`
+	// Data is the base64 encoded profiling data by the JSON encoding.
+	Data     []byte `json:"data,omitempty"`
+	Location string `json:"location,omitempty"`
+}
+
+func (e *ElasticsearchReporter) indexProfile(
+	ctx context.Context, r io.Reader, kind ProfileKind, filename string, info interface{},
+) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to read the profile: %w", err)
+	}
+	hostname, _ := os.Hostname() // Don't care about this error.
+	doc := elasticsearchDocument{
+		Timestamp: time.Now().UTC(),
+		App:       e.app,
+		Host:      hostname,
+		Kind:      kind,
+		Filename:  filename,
+		Size:      len(b),
+		Severity:  SeverityOf(kind, info).String(),
+		Autopprof: info,
+		Data:      b,
+	}
+	if len(b) > e.maxDocumentBytes {
+		var loc string
+		if e.location != nil {
+			loc = e.location(kind, info)
+		}
+		if loc == "" {
+			return fmt.Errorf(
+				"%w: profile of %d bytes exceeds the max document bytes %d",
+				ErrElasticsearchIndex, len(b), e.maxDocumentBytes,
+			)
+		}
+		doc.Data, doc.Location = nil, loc
+	}
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to encode the document: %w", err)
+	}
+	return e.send(ctx, doc.Timestamp, body)
+}
+
+func (e *ElasticsearchReporter) send(ctx context.Context, now time.Time, body []byte) error {
+	index := strings.ReplaceAll(e.index, elasticsearchIndexDate, now.Format(e.indexDateLayout))
+	u := e.url + "/" + url.PathEscape(index) + "/_doc"
+	if e.pipeline != "" {
+		u += "?" + url.Values{"pipeline": {e.pipeline}}.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	e.setHeaders(req)
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrElasticsearchIndex, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// The response has the reason, e.g. the mapping conflict.
+		reason, _ := io.ReadAll(io.LimitReader(resp.Body, elasticsearchErrorBodyBytes))
+		return fmt.Errorf(
+			"%w: status code %d: %s", ErrElasticsearchIndex, resp.StatusCode, bytes.TrimSpace(reason),
+		)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body) // Reuse the connection.
+	return nil
+}
+
+func (e *ElasticsearchReporter) setHeaders(req *http.Request) {
+	switch {
+	case e.apiKey != "":
+		req.Header.Set("Authorization", "ApiKey "+e.apiKey)
+	case e.username != "":
+		req.SetBasicAuth(e.username, e.password)
+	}
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+}
@@ -0,0 +1,135 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// elasticsearchServer is the cluster storing the indexed documents.
+type elasticsearchServer struct {
+	paths []string
+	docs  []map[string]interface{}
+	auth  string
+}
+
+func (s *elasticsearchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var doc map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.paths = append(s.paths, r.URL.RequestURI())
+	s.docs = append(s.docs, doc)
+	s.auth = r.Header.Get("Authorization")
+	w.WriteHeader(http.StatusCreated)
+}
+
+func TestElasticsearchReporter_ReportHeapProfile(t *testing.T) {
+	srv := &elasticsearchServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	e := NewElasticsearchReporter(&ElasticsearchReporterOption{
+		App:      "app",
+		URL:      ts.URL,
+		Pipeline: "attachment",
+		APIKey:   "key",
+	})
+	profile := []byte("profile")
+	mi := MemInfo{
+		ThresholdPercentage: 75,
+		UsagePercentage:     80,
+	}
+	if err := e.ReportHeapProfile(context.Background(), bytes.NewReader(profile), mi); err != nil {
+		t.Fatalf("ReportHeapProfile() = %v, want nil", err)
+	}
+
+	if len(srv.docs) != 1 {
+		t.Fatalf("got %d documents, want 1", len(srv.docs))
+	}
+	wantPath := "/autopprof-" + time.Now().UTC().Format("2006.01.02") + "/_doc?pipeline=attachment"
+	if srv.paths[0] != wantPath {
+		t.Errorf("path = %s, want %s", srv.paths[0], wantPath)
+	}
+	if srv.auth != "ApiKey key" {
+		t.Errorf("Authorization = %q, want the API key", srv.auth)
+	}
+	doc := srv.docs[0]
+	// The []byte is the base64 encoded by the JSON encoding.
+	if doc["data"] != "cHJvZmlsZQ==" {
+		t.Errorf("data = %v, want the base64 of the profile", doc["data"])
+	}
+	if doc["kind"] != "heap" {
+		t.Errorf("kind = %v, want heap", doc["kind"])
+	}
+	meta, _ := doc["autopprof"].(map[string]interface{})
+	if meta["UsagePercentage"] != 80.0 {
+		t.Errorf("autopprof.UsagePercentage = %v, want 80", meta["UsagePercentage"])
+	}
+}
+
+func TestElasticsearchReporter_location(t *testing.T) {
+	srv := &elasticsearchServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	e := NewElasticsearchReporter(&ElasticsearchReporterOption{
+		App:              "app",
+		URL:              ts.URL,
+		Index:            "profiles",
+		MaxDocumentBytes: 4,
+		Location: func(kind ProfileKind, _ interface{}) string {
+			return "s3://bucket/" + string(kind)
+		},
+	})
+	if err := e.ReportCPUProfile(context.Background(), strings.NewReader("0123456789"), CPUInfo{}); err != nil {
+		t.Fatalf("ReportCPUProfile() = %v, want nil", err)
+	}
+	if srv.paths[0] != "/profiles/_doc" {
+		t.Errorf("path = %s, want /profiles/_doc", srv.paths[0])
+	}
+	doc := srv.docs[0]
+	if _, ok := doc["data"]; ok {
+		t.Errorf("data is in the document of the large profile")
+	}
+	if doc["location"] != "s3://bucket/cpu" {
+		t.Errorf("location = %v, want s3://bucket/cpu", doc["location"])
+	}
+	if doc["size"] != 10.0 {
+		t.Errorf("size = %v, want 10", doc["size"])
+	}
+
+	// Without the location, the large profile fails.
+	e.location = nil
+	err := e.ReportCPUProfile(context.Background(), strings.NewReader("0123456789"), CPUInfo{})
+	if !errors.Is(err, ErrElasticsearchIndex) {
+		t.Errorf("ReportCPUProfile() = %v, want %v", err, ErrElasticsearchIndex)
+	}
+}
+
+func TestElasticsearchReporter_failure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":{"type":"mapper_parsing_exception"}}`))
+	}))
+	defer ts.Close()
+
+	e := NewElasticsearchReporter(&ElasticsearchReporterOption{
+		App: "app",
+		URL: ts.URL,
+	})
+	err := e.ReportGoroutineProfile(context.Background(), strings.NewReader("profile"), GoroutineInfo{})
+	if !errors.Is(err, ErrElasticsearchIndex) {
+		t.Fatalf("ReportGoroutineProfile() = %v, want %v", err, ErrElasticsearchIndex)
+	}
+	if !strings.Contains(err.Error(), "mapper_parsing_exception") {
+		t.Errorf("error = %v, want the reason of the cluster", err)
+	}
+}
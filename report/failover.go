@@ -0,0 +1,209 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// FailoverReporter sends the profiling report to its primary reporter,
+// and on the failure, to the fallbacks in order until one succeeds,
+// e.g. the store of the primary region, then the one of the backup
+// region, then the local disk. So the profiles of an incident survive
+// the outage of the destination, which is often of the same incident.
+// The profile is read once and each attempt reads its own copy.
+//
+// The attempts share the deadline of the context, so set the timeouts
+// of the reporters which are the TimeoutReporter, and a hanging one
+// doesn't take the whole time of the others.
+type FailoverReporter struct {
+	reporters []Reporter
+
+	mu    sync.Mutex
+	stats []FailoverStats
+	// lastUsed is the index of the reporter which succeeded the last
+	//  report. -1 means none.
+	lastUsed int
+}
+
+// FailoverStats is the counts of the reports of a reporter of
+// the FailoverReporter.
+type FailoverStats struct {
+	// Successes and Failures are the numbers of the reports which
+	//  succeeded and failed by the reporter.
+	Successes uint64
+	Failures  uint64
+}
+
+// NewFailoverReporter returns the new FailoverReporter which tries
+// the primary first, and then the fallbacks in order.
+func NewFailoverReporter(primary Reporter, fallbacks ...Reporter) *FailoverReporter {
+	reporters := append([]Reporter{primary}, fallbacks...)
+	return &FailoverReporter{
+		reporters: reporters,
+		stats:     make([]FailoverStats, len(reporters)),
+		lastUsed:  -1,
+	}
+}
+
+// Timeout returns the sum of the timeouts of the reporters, so all of
+// the attempts fit in it. It returns zero (means the global timeout)
+// unless all of them are the TimeoutReporter with the timeout.
+func (f *FailoverReporter) Timeout() time.Duration {
+	var timeout time.Duration
+	for _, r := range f.reporters {
+		tr, ok := r.(TimeoutReporter)
+		if !ok || tr.Timeout() <= 0 {
+			return 0
+		}
+		timeout += tr.Timeout()
+	}
+	return timeout
+}
+
+// Stats returns the counts of the reports per reporter, the primary
+// first and then the fallbacks in order.
+func (f *FailoverReporter) Stats() []FailoverStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stats := make([]FailoverStats, len(f.stats))
+	copy(stats, f.stats)
+	return stats
+}
+
+// LastUsed returns the index of the reporter which succeeded the last
+// report, 0 for the primary and 1 or more for the fallbacks in order.
+// It returns -1 if no report has succeeded yet.
+func (f *FailoverReporter) LastUsed() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.lastUsed
+}
+
+// Ping checks the primary and the fallbacks which are
+// the PingReporter, so the misconfigured fallback is found before
+// the outage of the primary. The error has all of the failures.
+func (f *FailoverReporter) Ping(ctx context.Context) error {
+	errs := make([]error, len(f.reporters))
+	for i, rp := range f.reporters {
+		if pr, ok := rp.(PingReporter); ok {
+			errs[i] = pr.Ping(ctx)
+		}
+	}
+	return joinReporterErrors(errs)
+}
+
+// Flush flushes the primary and the fallbacks which are the Flusher,
+// since any of them may have buffered the reports. The error has all
+// of the failures.
+func (f *FailoverReporter) Flush(ctx context.Context) error {
+	errs := make([]error, len(f.reporters))
+	for i, rp := range f.reporters {
+		if fl, ok := rp.(Flusher); ok {
+			errs[i] = fl.Flush(ctx)
+		}
+	}
+	return joinReporterErrors(errs)
+}
+
+// ReportCPUProfile sends the CPU profiling data to the first reporter
+// which succeeds.
+func (f *FailoverReporter) ReportCPUProfile(
+	ctx context.Context, r io.Reader, ci CPUInfo,
+) error {
+	return f.report(ctx, r, func(ctx context.Context, rp Reporter, pr io.Reader) error {
+		return rp.ReportCPUProfile(ctx, pr, ci)
+	})
+}
+
+// ReportHeapProfile sends the heap profiling data to the first reporter
+// which succeeds.
+func (f *FailoverReporter) ReportHeapProfile(
+	ctx context.Context, r io.Reader, mi MemInfo,
+) error {
+	return f.report(ctx, r, func(ctx context.Context, rp Reporter, pr io.Reader) error {
+		return rp.ReportHeapProfile(ctx, pr, mi)
+	})
+}
+
+// ReportGoroutineProfile sends the goroutine profiling data to
+// the first reporter which succeeds.
+func (f *FailoverReporter) ReportGoroutineProfile(
+	ctx context.Context, r io.Reader, gi GoroutineInfo,
+) error {
+	return f.report(ctx, r, func(ctx context.Context, rp Reporter, pr io.Reader) error {
+		return rp.ReportGoroutineProfile(ctx, pr, gi)
+	})
+}
+
+// ReportProfile sends the profiling data of the kind to the first
+// reporter which succeeds. (See the ReportProfile function)
+func (f *FailoverReporter) ReportProfile(
+	ctx context.Context, r io.Reader, kind ProfileKind, pi ProfileInfo,
+) error {
+	return f.report(ctx, r, func(ctx context.Context, rp Reporter, pr io.Reader) error {
+		return ReportProfile(ctx, rp, pr, kind, pi)
+	})
+}
+
+func (f *FailoverReporter) report(
+	ctx context.Context, r io.Reader,
+	fn func(ctx context.Context, rp Reporter, pr io.Reader) error,
+) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to read the profile: %w", err)
+	}
+
+	var lastErr error
+	for i, rp := range f.reporters {
+		if ctx.Err() != nil {
+			// No time is left for the rest.
+			break
+		}
+		err := f.attempt(ctx, rp, func(ctx context.Context) error {
+			return fn(ctx, rp, bytes.NewReader(b))
+		})
+		f.record(i, err)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = ctx.Err()
+	}
+	return fmt.Errorf(
+		"autopprof: all of %d reporters failed: %w", len(f.reporters), lastErr,
+	)
+}
+
+// attempt runs the report by the rp within its own timeout if it's
+// a TimeoutReporter.
+func (f *FailoverReporter) attempt(
+	ctx context.Context, rp Reporter, fn func(ctx context.Context) error,
+) error {
+	if tr, ok := rp.(TimeoutReporter); ok && tr.Timeout() > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, tr.Timeout())
+		defer cancel()
+	}
+	return fn(ctx)
+}
+
+func (f *FailoverReporter) record(i int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err != nil {
+		f.stats[i].Failures++
+		return
+	}
+	f.stats[i].Successes++
+	f.lastUsed = i
+}
@@ -0,0 +1,185 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestFailoverReporter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	errDown := errors.New("region is down")
+	primary := NewMockReporter(ctrl)
+	primary.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, r io.Reader, _ CPUInfo) error {
+			// Read it partially before failing.
+			r.Read(make([]byte, 2))
+			return errDown
+		})
+	primary.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+
+	var got string
+	backup := NewMockReporter(ctrl)
+	backup.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, r io.Reader, _ CPUInfo) error {
+			b, err := io.ReadAll(r)
+			got = string(b)
+			return err
+		})
+	disk := NewMockReporter(ctrl)
+
+	f := NewFailoverReporter(primary, backup, disk)
+	if got := f.LastUsed(); got != -1 {
+		t.Errorf("LastUsed() = %d, want -1", got)
+	}
+
+	ctx := context.Background()
+	if err := f.ReportCPUProfile(ctx, strings.NewReader("prof"), CPUInfo{}); err != nil {
+		t.Fatalf("ReportCPUProfile() = %v, want nil", err)
+	}
+	if got != "prof" {
+		t.Errorf("profile of the fallback = %q, want %q", got, "prof")
+	}
+	if got := f.LastUsed(); got != 1 {
+		t.Errorf("LastUsed() = %d, want 1", got)
+	}
+
+	if err := f.ReportHeapProfile(ctx, strings.NewReader("prof"), MemInfo{}); err != nil {
+		t.Fatalf("ReportHeapProfile() = %v, want nil", err)
+	}
+	if got := f.LastUsed(); got != 0 {
+		t.Errorf("LastUsed() = %d, want 0", got)
+	}
+
+	want := []FailoverStats{
+		{Successes: 1, Failures: 1},
+		{Successes: 1},
+		{},
+	}
+	if got := f.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFailoverReporter_allFailed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var (
+		errPrimary = errors.New("primary is down")
+		errBackup  = errors.New("backup is down")
+	)
+	primary := NewMockReporter(ctrl)
+	primary.EXPECT().
+		ReportGoroutineProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(errPrimary)
+	backup := NewMockReporter(ctrl)
+	backup.EXPECT().
+		ReportGoroutineProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(errBackup)
+
+	f := NewFailoverReporter(primary, backup)
+	err := f.ReportGoroutineProfile(
+		context.Background(), strings.NewReader("prof"), GoroutineInfo{},
+	)
+	if !errors.Is(err, errBackup) {
+		t.Errorf("ReportGoroutineProfile() = %v, want %v", err, errBackup)
+	}
+	if got := f.LastUsed(); got != -1 {
+		t.Errorf("LastUsed() = %d, want -1", got)
+	}
+}
+
+func TestFailoverReporter_Timeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	primary := NewMockTimeoutReporter(ctrl)
+	primary.EXPECT().Timeout().Return(10 * time.Second).AnyTimes()
+	backup := NewMockTimeoutReporter(ctrl)
+	backup.EXPECT().Timeout().Return(5 * time.Second).AnyTimes()
+
+	testCases := []struct {
+		name string
+		f    *FailoverReporter
+		want time.Duration
+	}{
+		{
+			name: "all with the timeouts",
+			f:    NewFailoverReporter(primary, backup),
+			want: 15 * time.Second,
+		},
+		{
+			name: "some without the timeout",
+			f:    NewFailoverReporter(primary, NewMockReporter(ctrl)),
+			want: 0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.f.Timeout(); got != tc.want {
+				t.Errorf("Timeout() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFailoverReporter_attemptTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	// The hanging primary doesn't take the time of the fallback.
+	primary := NewMockTimeoutReporter(ctrl)
+	primary.EXPECT().Timeout().Return(50 * time.Millisecond).AnyTimes()
+	primary.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ io.Reader, _ CPUInfo) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	backup := NewMockReporter(ctrl)
+	backup.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	f := NewFailoverReporter(primary, backup)
+	if err := f.ReportCPUProfile(ctx, strings.NewReader("prof"), CPUInfo{}); err != nil {
+		t.Errorf("ReportCPUProfile() = %v, want nil", err)
+	}
+}
+
+func TestFailoverReporter_PingFlush(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	errPing := errors.New("bad credentials")
+	primary := NewMockPingReporter(ctrl)
+	primary.EXPECT().Ping(gomock.Any()).Return(nil)
+	fallback := NewMockPingReporter(ctrl)
+	fallback.EXPECT().Ping(gomock.Any()).Return(errPing)
+
+	f := NewFailoverReporter(primary, NewMockReporter(ctrl), fallback)
+	if err := f.Ping(context.Background()); !errors.Is(err, errPing) {
+		t.Errorf("Ping() = %v, want %v of the fallback", err, errPing)
+	}
+
+	errFlush := errors.New("flush")
+	flushPrimary := flushReporter{NewMockReporter(ctrl), NewMockFlusher(ctrl)}
+	flushPrimary.MockFlusher.EXPECT().Flush(gomock.Any()).Return(errFlush)
+	flushFallback := flushReporter{NewMockReporter(ctrl), NewMockFlusher(ctrl)}
+	flushFallback.MockFlusher.EXPECT().Flush(gomock.Any()).Return(nil)
+
+	f = NewFailoverReporter(flushPrimary, flushFallback)
+	if err := f.Flush(context.Background()); !errors.Is(err, errFlush) {
+		t.Errorf("Flush() = %v, want %v", err, errFlush)
+	}
+}
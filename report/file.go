@@ -0,0 +1,171 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FileReporter writes each profile to a new file in the directory, so
+// the whole pipeline is tested locally or in the air-gapped
+// environments without a remote backend. The filename has the kind,
+// the report time and the usage if any, e.g.
+// "cpu_2006-01-02T150405.MST_80pct.pprof".
+//
+// With the maxFiles of the NewFileReporterWithMaxFiles, the oldest
+// files are removed when the files in the directory exceed it, so
+// the disk doesn't fill up. The directory must be dedicated to
+// the reporter, since all files in it count.
+// For the fixed files per kind, see the RingFileReporter.
+type FileReporter struct {
+	dir      string
+	maxFiles int
+
+	mu sync.Mutex
+}
+
+// NewFileReporter returns the FileReporter writing to the dir, which
+// is created if it doesn't exist. It keeps all files.
+func NewFileReporter(dir string) (*FileReporter, error) {
+	return NewFileReporterWithMaxFiles(dir, 0)
+}
+
+// NewFileReporterWithMaxFiles returns the FileReporter which keeps
+// the maxFiles newest files in the dir. Zero means unlimited.
+func NewFileReporterWithMaxFiles(dir string, maxFiles int) (*FileReporter, error) {
+	if maxFiles < 0 {
+		return nil, fmt.Errorf("autopprof: max files can't be negative: %d", maxFiles)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("autopprof: failed to create the profile directory: %w", err)
+	}
+	return &FileReporter{
+		dir:      dir,
+		maxFiles: maxFiles,
+	}, nil
+}
+
+// ReportCPUProfile writes the CPU profiling data to a new file.
+func (fr *FileReporter) ReportCPUProfile(
+	_ context.Context, r io.Reader, ci CPUInfo,
+) error {
+	name := fmt.Sprintf(
+		"%s_%s_%.0fpct.pprof", ProfileKindCPU, reportTime(ci.Sequence), ci.UsagePercentage,
+	)
+	return fr.write(name+ci.ContentEncoding.Suffix(), r)
+}
+
+// ReportHeapProfile writes the heap profiling data to a new file.
+func (fr *FileReporter) ReportHeapProfile(
+	_ context.Context, r io.Reader, mi MemInfo,
+) error {
+	kind := string(ProfileKindHeap)
+	if mi.SampleType != "" {
+		kind += "-" + mi.SampleType
+	}
+	name := fmt.Sprintf(
+		"%s_%s_%.0fpct.pprof", kind, reportTime(mi.Sequence), mi.UsagePercentage,
+	)
+	return fr.write(name+mi.ContentEncoding.Suffix(), r)
+}
+
+// ReportGoroutineProfile writes the goroutine profiling data to a new
+// file.
+func (fr *FileReporter) ReportGoroutineProfile(
+	_ context.Context, r io.Reader, gi GoroutineInfo,
+) error {
+	name := fmt.Sprintf("%s_%s.pprof", ProfileKindGoroutine, reportTime(gi.Sequence))
+	if gi.Dump {
+		name = fmt.Sprintf("%s_%s.txt", ProfileKindGoroutine, reportTime(gi.Sequence))
+	}
+	return fr.write(name+gi.ContentEncoding.Suffix(), r)
+}
+
+// ReportProfile writes the profiling data of the kind to a new file.
+// The liveness marker isn't written, since it has no profiling data.
+func (fr *FileReporter) ReportProfile(
+	_ context.Context, r io.Reader, kind ProfileKind, pi ProfileInfo,
+) error {
+	if kind == ProfileKindLiveness {
+		return nil
+	}
+	name := fmt.Sprintf("%s_%s.pprof", kind, reportTime(pi.Sequence))
+	return fr.write(name+pi.ContentEncoding.Suffix(), r)
+}
+
+func (fr *FileReporter) write(name string, r io.Reader) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	path := fr.uniquePath(name)
+	if err := writeFileAtomic(path, r); err != nil {
+		return fmt.Errorf("autopprof: failed to write the profile %s: %w", path, err)
+	}
+	if err := fr.prune(); err != nil {
+		return fmt.Errorf("autopprof: failed to remove the old profiles: %w", err)
+	}
+	return nil
+}
+
+// uniquePath returns the path of the name in the dir, suffixed with
+// a number if the file exists, e.g. the heap views of the same second.
+func (fr *FileReporter) uniquePath(name string) string {
+	path := filepath.Join(fr.dir, name)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = filepath.Join(fr.dir, fmt.Sprintf("%s-%d%s", base, i, ext))
+	}
+}
+
+// prune removes the oldest files over the maxFiles.
+func (fr *FileReporter) prune() error {
+	if fr.maxFiles == 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(fr.dir)
+	if err != nil {
+		return err
+	}
+	type file struct {
+		name    string
+		modTime int64
+	}
+	var files []file
+	for _, e := range entries {
+		// Skip the temporary files of the writes in progress.
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			// Removed in the meantime.
+			continue
+		}
+		files = append(files, file{name: e.Name(), modTime: fi.ModTime().UnixNano()})
+	}
+	if len(files) <= fr.maxFiles {
+		return nil
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].modTime != files[j].modTime {
+			return files[i].modTime < files[j].modTime
+		}
+		return files[i].name < files[j].name
+	})
+	for _, f := range files[:len(files)-fr.maxFiles] {
+		err := os.Remove(filepath.Join(fr.dir, f.name))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package report
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readProfileFiles returns the contents of the files in the dir by
+// their names.
+func readProfileFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string, len(entries))
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[e.Name()] = string(b)
+	}
+	return files
+}
+
+func TestFileReporter(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "profiles")
+	fr, err := NewFileReporter(dir)
+	if err != nil {
+		t.Fatalf("NewFileReporter() = %v, want nil", err)
+	}
+	ctx := context.Background()
+	if err := fr.ReportCPUProfile(ctx, strings.NewReader("cpu"), CPUInfo{UsagePercentage: 80.4}); err != nil {
+		t.Fatalf("ReportCPUProfile() = %v, want nil", err)
+	}
+	// The heap views of the same second don't overwrite each other.
+	for _, st := range []string{SampleTypeInuseSpace, SampleTypeInuseSpace} {
+		if err := fr.ReportHeapProfile(ctx, strings.NewReader(st), MemInfo{SampleType: st, UsagePercentage: 90}); err != nil {
+			t.Fatalf("ReportHeapProfile() = %v, want nil", err)
+		}
+	}
+	if err := fr.ReportGoroutineProfile(ctx, strings.NewReader("dump"), GoroutineInfo{Dump: true}); err != nil {
+		t.Fatalf("ReportGoroutineProfile() = %v, want nil", err)
+	}
+	if err := fr.ReportProfile(ctx, strings.NewReader(""), ProfileKindLiveness, ProfileInfo{}); err != nil {
+		t.Fatalf("ReportProfile() = %v, want nil", err)
+	}
+
+	files := readProfileFiles(t, dir)
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) != 4 {
+		t.Fatalf("got the files %v, want 4 without the liveness marker", names)
+	}
+	// The heap views may be written across a second.
+	wantPrefixes := []string{"cpu_", "goroutine_", "heap-inuse_space_", "heap-inuse_space_"}
+	wantSuffixes := []string{"_80pct.pprof", ".txt", ".pprof", ".pprof"}
+	for i, name := range names {
+		if !strings.HasPrefix(name, wantPrefixes[i]) || !strings.HasSuffix(name, wantSuffixes[i]) {
+			t.Errorf("file %d = %s, want %s*%s", i, name, wantPrefixes[i], wantSuffixes[i])
+		}
+	}
+	if !strings.HasSuffix(names[2], "_90pct-1.pprof") && !strings.HasSuffix(names[2], "_90pct.pprof") {
+		t.Errorf("heap file = %s, want the usage percentage", names[2])
+	}
+}
+
+func TestFileReporter_maxFiles(t *testing.T) {
+	dir := t.TempDir()
+	fr, err := NewFileReporterWithMaxFiles(dir, 2)
+	if err != nil {
+		t.Fatalf("NewFileReporterWithMaxFiles() = %v, want nil", err)
+	}
+	ctx := context.Background()
+	for _, p := range []string{"heap1", "heap2", "heap3"} {
+		if err := fr.ReportHeapProfile(ctx, strings.NewReader(p), MemInfo{}); err != nil {
+			t.Fatalf("ReportHeapProfile() = %v, want nil", err)
+		}
+		// Order the files by the modification time.
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	files := readProfileFiles(t, dir)
+	if len(files) != 2 {
+		t.Fatalf("got %d files, want 2", len(files))
+	}
+	for _, content := range files {
+		if content == "heap1" {
+			t.Errorf("the oldest profile isn't removed")
+		}
+	}
+}
+
+func TestNewFileReporterWithMaxFiles_invalid(t *testing.T) {
+	if _, err := NewFileReporterWithMaxFiles(t.TempDir(), -1); err == nil {
+		t.Errorf("NewFileReporterWithMaxFiles() = nil, want an error")
+	}
+}
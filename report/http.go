@@ -0,0 +1,385 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHTTPChunkSize  = 8 << 20 // 8MiB.
+	defaultHTTPMaxRetries = 3
+
+	httpRetryBackoff = 100 * time.Millisecond
+
+	// statusResumeIncomplete is the status code of the acknowledged
+	// chunk while the upload isn't complete.
+	statusResumeIncomplete = 308
+
+	// HTTPUploadIDHeader is the header of the id of the upload shared
+	// by its chunks.
+	HTTPUploadIDHeader = "Autopprof-Upload-Id"
+	// HTTPFilenameHeader is the header of the filename of the profile.
+	HTTPFilenameHeader = "Autopprof-Filename"
+	// HTTPMetadataHeader is the header of the JSON encoded CPUInfo,
+	// MemInfo or GoroutineInfo.
+	HTTPMetadataHeader = "Autopprof-Metadata"
+	// HTTPRetentionHeader is the header of the RetentionHint of
+	// the profile in seconds, so the server sets the lifecycle of
+	// the stored profile without parsing the metadata. It's omitted if
+	// there's no hint.
+	HTTPRetentionHeader = "Autopprof-Retention"
+)
+
+// errUploadIncomplete is returned if the server doesn't acknowledge
+// the chunk.
+var errUploadIncomplete = errors.New("autopprof: upload is not acknowledged")
+
+// HTTPReporter is the reporter to upload the profiling report to
+// the HTTP endpoint.
+//
+// The profile is uploaded in chunks with the resumable upload protocol,
+// so the large profile is uploaded reliably over the flaky link:
+//
+//   - Each chunk is sent by a PUT request with the Content-Range header
+//     (e.g. bytes 0-8388607/209715200) and the HTTPUploadIDHeader.
+//   - The server responds 308 with the Range header of the acknowledged
+//     bytes (e.g. bytes=0-8388607) until the last chunk, and responds
+//     2xx when the upload is complete.
+//   - After a failure, the client asks the acknowledged offset by a PUT
+//     request with the Content-Range: bytes */<total> and an empty body,
+//     and resumes from there.
+//
+// The retries stop when the deadline of the context exceeds.
+type HTTPReporter struct {
+	app      string
+	endpoint string
+	client   *http.Client
+
+	chunkSize  int64
+	maxRetries int
+}
+
+// HTTPReporterOption is the option for the HTTP reporter.
+type HTTPReporterOption struct {
+	App string
+	// Endpoint is the URL to upload the profiles to.
+	Endpoint string
+	// Client is the HTTP client to upload.
+	// Default: http.DefaultClient.
+	Client *http.Client
+
+	// ChunkSize is the maximum size of a chunk in bytes.
+	// Default: 8MiB.
+	ChunkSize int64
+	// MaxRetries is the maximum number of consecutive retries without
+	//  any progress of the upload.
+	// Default: 3.
+	MaxRetries int
+}
+
+// NewHTTPReporter returns the new HTTPReporter.
+func NewHTTPReporter(opt *HTTPReporterOption) *HTTPReporter {
+	h := &HTTPReporter{
+		app:        opt.App,
+		endpoint:   opt.Endpoint,
+		client:     opt.Client,
+		chunkSize:  opt.ChunkSize,
+		maxRetries: opt.MaxRetries,
+	}
+	if h.client == nil {
+		h.client = http.DefaultClient
+	}
+	if h.chunkSize <= 0 {
+		h.chunkSize = defaultHTTPChunkSize
+	}
+	if h.maxRetries <= 0 {
+		h.maxRetries = defaultHTTPMaxRetries
+	}
+	return h
+}
+
+// Ping checks that the endpoint is reachable with a HEAD request.
+// The server errors (5xx) and the authentication errors (401 and 403)
+// are the failures, but the other statuses aren't since the endpoint
+// may not support the HEAD.
+func (h *HTTPReporter) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, h.endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to reach the endpoint: %w", err)
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 500,
+		resp.StatusCode == http.StatusUnauthorized,
+		resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("autopprof: endpoint responded %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ReportCPUProfile uploads the CPU profiling data to the endpoint.
+func (h *HTTPReporter) ReportCPUProfile(
+	ctx context.Context, r io.Reader, ci CPUInfo,
+) error {
+	hostname, _ := os.Hostname() // Don't care about this error.
+	now := reportTime(ci.Sequence)
+	filename := fmt.Sprintf(CPUProfileFilenameFmt, h.app, hostname, now) + ci.ContentEncoding.Suffix()
+	return h.upload(ctx, r, filename, ci)
+}
+
+// ReportHeapProfile uploads the heap profiling data to the endpoint.
+func (h *HTTPReporter) ReportHeapProfile(
+	ctx context.Context, r io.Reader, mi MemInfo,
+) error {
+	hostname, _ := os.Hostname() // Don't care about this error.
+	now := reportTime(mi.Sequence)
+	filename := fmt.Sprintf(HeapProfileFilenameFmt, h.app, hostname, now) + mi.ContentEncoding.Suffix()
+	if mi.SampleType != "" {
+		filename = fmt.Sprintf(HeapViewProfileFilenameFmt, h.app, hostname, mi.SampleType, now) + mi.ContentEncoding.Suffix()
+	}
+	return h.upload(ctx, r, filename, mi)
+}
+
+// ReportGoroutineProfile uploads the goroutine profiling data to the endpoint.
+func (h *HTTPReporter) ReportGoroutineProfile(
+	ctx context.Context, r io.Reader, gi GoroutineInfo,
+) error {
+	hostname, _ := os.Hostname() // Don't care about this error.
+	now := reportTime(gi.Sequence)
+	filename := fmt.Sprintf(GoroutineProfileFilenameFmt, h.app, hostname, now) + gi.ContentEncoding.Suffix()
+	if gi.Dump {
+		filename = fmt.Sprintf(GoroutineDumpFilenameFmt, h.app, hostname, now) + gi.ContentEncoding.Suffix()
+	}
+	return h.upload(ctx, r, filename, gi)
+}
+
+// ReportProfile uploads the profiling data of the kind to the endpoint.
+func (h *HTTPReporter) ReportProfile(
+	ctx context.Context, r io.Reader, kind ProfileKind, pi ProfileInfo,
+) error {
+	hostname, _ := os.Hostname() // Don't care about this error.
+	now := reportTime(pi.Sequence)
+	filename := fmt.Sprintf(NamedProfileFilenameFmt, h.app, hostname, kind, now) + pi.ContentEncoding.Suffix()
+	return h.upload(ctx, r, filename, pi)
+}
+
+// ReportBundle uploads the bundle of the batched profiles to
+// the endpoint. (See BatchReporter)
+func (h *HTTPReporter) ReportBundle(
+	ctx context.Context, r io.Reader, bi BundleInfo,
+) error {
+	hostname, _ := os.Hostname() // Don't care about this error.
+	now := time.Now().Format(reportTimeLayout)
+	filename := fmt.Sprintf(BundleFilenameFmt, h.app, hostname, now)
+	return h.upload(ctx, r, filename, bi)
+}
+
+// sizedReaderAt is the reader whose size is known. (e.g. bytes.Reader)
+type sizedReaderAt interface {
+	io.ReaderAt
+	Size() int64
+}
+
+func (h *HTTPReporter) upload(
+	ctx context.Context, r io.Reader, filename string, info interface{},
+) error {
+	metadata, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to encode the metadata: %w", err)
+	}
+	sr, ok := r.(sizedReaderAt)
+	if !ok {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("autopprof: failed to read the profile: %w", err)
+		}
+		sr = bytes.NewReader(b)
+	}
+	u := &httpUpload{
+		reporter:  h,
+		id:        newUploadID(),
+		filename:  filename,
+		metadata:  string(metadata),
+		retention: retentionHintOf(info),
+		r:         sr,
+		total:     sr.Size(),
+	}
+	if err := u.run(ctx); err != nil {
+		return fmt.Errorf("autopprof: failed to upload the profile: %w", err)
+	}
+	return nil
+}
+
+// retentionHintOf returns the RetentionHint of the info.
+func retentionHintOf(info interface{}) time.Duration {
+	switch i := info.(type) {
+	case CPUInfo:
+		return i.RetentionHint
+	case MemInfo:
+		return i.RetentionHint
+	case GoroutineInfo:
+		return i.RetentionHint
+	case ProfileInfo:
+		return i.RetentionHint
+	}
+	return 0
+}
+
+// httpUpload is the state of an upload.
+type httpUpload struct {
+	reporter *HTTPReporter
+
+	id       string
+	filename string
+	metadata string
+	// retention is the RetentionHint of the profile.
+	retention time.Duration
+
+	r     sizedReaderAt
+	total int64
+	// offset is the number of the bytes acknowledged by the server.
+	offset int64
+}
+
+func (u *httpUpload) run(ctx context.Context) error {
+	var retries int
+	for {
+		done, err := u.sendChunk(ctx)
+		if done {
+			return nil
+		}
+		if err == nil {
+			retries = 0
+			continue
+		}
+		// Stop retrying if the deadline exceeds.
+		if ctx.Err() != nil {
+			return err
+		}
+		retries++
+		if retries > u.reporter.maxRetries {
+			return err
+		}
+		select {
+		case <-time.After(time.Duration(retries) * httpRetryBackoff):
+		case <-ctx.Done():
+			return err
+		}
+		// Resume from the last acknowledged offset.
+		done, qerr := u.queryOffset(ctx)
+		if done {
+			return nil
+		}
+		if qerr != nil && ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// sendChunk sends the chunk from the offset, and reports whether
+// the upload is complete.
+func (u *httpUpload) sendChunk(ctx context.Context) (bool, error) {
+	size := u.reporter.chunkSize
+	if remaining := u.total - u.offset; remaining < size {
+		size = remaining
+	}
+	contentRange := fmt.Sprintf("bytes */%d", u.total) // The empty profile.
+	if size > 0 {
+		contentRange = fmt.Sprintf(
+			"bytes %d-%d/%d", u.offset, u.offset+size-1, u.total,
+		)
+	}
+	body := io.NewSectionReader(u.r, u.offset, size)
+	return u.do(ctx, body, size, contentRange)
+}
+
+// queryOffset asks the server the acknowledged offset, and reports
+// whether the upload is complete.
+func (u *httpUpload) queryOffset(ctx context.Context) (bool, error) {
+	return u.do(ctx, http.NoBody, 0, fmt.Sprintf("bytes */%d", u.total))
+}
+
+func (u *httpUpload) do(
+	ctx context.Context, body io.Reader, size int64, contentRange string,
+) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.reporter.endpoint, body)
+	if err != nil {
+		return false, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", ContentType("application/octet-stream"))
+	req.Header.Set("Content-Range", contentRange)
+	req.Header.Set(HTTPUploadIDHeader, u.id)
+	req.Header.Set(HTTPFilenameHeader, u.filename)
+	req.Header.Set(HTTPMetadataHeader, u.metadata)
+	if u.retention > 0 {
+		req.Header.Set(
+			HTTPRetentionHeader, strconv.FormatInt(int64(u.retention/time.Second), 10),
+		)
+	}
+
+	resp, err := u.reporter.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body) // Reuse the connection.
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		u.offset = u.total
+		return true, nil
+	case resp.StatusCode == statusResumeIncomplete:
+		offset, err := parseAckedOffset(resp.Header.Get("Range"))
+		if err != nil {
+			return false, err
+		}
+		if offset <= u.offset && size > 0 {
+			u.offset = offset
+			return false, errUploadIncomplete
+		}
+		u.offset = offset
+		return false, nil
+	}
+	return false, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+}
+
+// newUploadID returns the random id of an upload.
+func newUploadID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b) // It never fails on the supported platforms.
+	return hex.EncodeToString(b)
+}
+
+// parseAckedOffset returns the offset after the acknowledged bytes
+// in the Range header. (e.g. bytes=0-1023 means 1024)
+// The empty header means nothing is acknowledged.
+func parseAckedOffset(header string) (int64, error) {
+	if header == "" {
+		return 0, nil
+	}
+	i := strings.LastIndex(header, "-")
+	if !strings.HasPrefix(header, "bytes=0-") || i < 0 {
+		return 0, fmt.Errorf("invalid range header %q", header)
+	}
+	last, err := strconv.ParseInt(header[i+1:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid range header %q: %w", header, err)
+	}
+	return last + 1, nil
+}
@@ -0,0 +1,232 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// resumableServer is the server of the resumable upload protocol
+// which fails every failEvery-th chunk after storing it partially.
+type resumableServer struct {
+	failEvery int
+
+	mu       sync.Mutex
+	received map[string][]byte
+	metadata map[string]string
+	chunks   int
+}
+
+func (s *resumableServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := r.Header.Get(HTTPUploadIDHeader)
+	s.metadata[id] = r.Header.Get(HTTPMetadataHeader)
+	got := s.received[id]
+
+	var first, last, total int64
+	cr := r.Header.Get("Content-Range")
+	if strings.HasPrefix(cr, "bytes */") {
+		// The query of the acknowledged offset.
+		total, _ = strconv.ParseInt(strings.TrimPrefix(cr, "bytes */"), 10, 64)
+		s.ack(w, int64(len(got)), total)
+		return
+	}
+	if _, err := fmt.Sscanf(cr, "bytes %d-%d/%d", &first, &last, &total); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	if first != int64(len(got)) {
+		s.ack(w, int64(len(got)), total)
+		return
+	}
+	s.chunks++
+	if s.failEvery > 0 && s.chunks%s.failEvery == 0 {
+		// Store the half of the chunk and fail.
+		s.received[id] = append(got, body[:len(body)/2]...)
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	s.received[id] = append(got, body...)
+	s.ack(w, int64(len(s.received[id])), total)
+}
+
+func (s *resumableServer) ack(w http.ResponseWriter, offset, total int64) {
+	if offset == total {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	if offset > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", offset-1))
+	}
+	w.WriteHeader(statusResumeIncomplete)
+}
+
+func TestHTTPReporter_ReportHeapProfile(t *testing.T) {
+	srv := &resumableServer{
+		failEvery: 3,
+		received:  make(map[string][]byte),
+		metadata:  make(map[string]string),
+	}
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+
+	h := NewHTTPReporter(&HTTPReporterOption{
+		App:       "app",
+		Endpoint:  ts.URL,
+		ChunkSize: 100,
+	})
+	prof := bytes.Repeat([]byte("0123456789"), 105) // 1050 bytes.
+	mi := MemInfo{
+		SchemaVersion:       SchemaVersion,
+		ThresholdPercentage: 75,
+		UsagePercentage:     80,
+	}
+	if err := h.ReportHeapProfile(context.Background(), bytes.NewReader(prof), mi); err != nil {
+		t.Fatalf("ReportHeapProfile() = %v, want nil", err)
+	}
+
+	if len(srv.received) != 1 {
+		t.Fatalf("number of uploads = %d, want 1", len(srv.received))
+	}
+	for id, got := range srv.received {
+		if !bytes.Equal(got, prof) {
+			t.Errorf("uploaded %d bytes, want the same %d bytes", len(got), len(prof))
+		}
+		var gotMI MemInfo
+		if err := json.Unmarshal([]byte(srv.metadata[id]), &gotMI); err != nil {
+			t.Errorf("metadata = %v, want the JSON encoded MemInfo", err)
+		}
+		if !reflect.DeepEqual(gotMI, mi) {
+			t.Errorf("metadata = %+v, want %+v", gotMI, mi)
+		}
+	}
+}
+
+func TestHTTPReporter_deadline(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+	))
+	t.Cleanup(ts.Close)
+
+	h := NewHTTPReporter(&HTTPReporterOption{
+		Endpoint:   ts.URL,
+		MaxRetries: 100,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := h.ReportCPUProfile(ctx, strings.NewReader("prof"), CPUInfo{})
+	if err == nil {
+		t.Errorf("ReportCPUProfile() = nil, want error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ReportCPUProfile() took %s, want to stop at the deadline", elapsed)
+	}
+}
+
+func TestHTTPReporter_retention(t *testing.T) {
+	testCases := []struct {
+		name      string
+		retention time.Duration
+		want      string
+	}{
+		{
+			name:      "hint",
+			retention: 7 * 24 * time.Hour,
+			want:      "604800",
+		},
+		{
+			name: "no hint",
+			want: "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got string
+			ts := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					got = r.Header.Get(HTTPRetentionHeader)
+					w.WriteHeader(http.StatusCreated)
+				},
+			))
+			t.Cleanup(ts.Close)
+
+			h := NewHTTPReporter(&HTTPReporterOption{Endpoint: ts.URL})
+			if err := h.ReportCPUProfile(
+				context.Background(), strings.NewReader("prof"),
+				CPUInfo{RetentionHint: tc.retention},
+			); err != nil {
+				t.Fatalf("ReportCPUProfile() = %v, want nil", err)
+			}
+			if got != tc.want {
+				t.Errorf("%s = %q, want %q", HTTPRetentionHeader, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHTTPReporter_Ping(t *testing.T) {
+	testCases := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "ok", status: http.StatusOK, wantErr: false},
+		{name: "head not allowed", status: http.StatusMethodNotAllowed, wantErr: false},
+		{name: "forbidden", status: http.StatusForbidden, wantErr: true},
+		{name: "server error", status: http.StatusInternalServerError, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(tc.status)
+				},
+			))
+			t.Cleanup(ts.Close)
+
+			h := NewHTTPReporter(&HTTPReporterOption{Endpoint: ts.URL})
+			if err := h.Ping(context.Background()); (err != nil) != tc.wantErr {
+				t.Errorf("Ping() = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseAckedOffset(t *testing.T) {
+	testCases := []struct {
+		header  string
+		want    int64
+		wantErr bool
+	}{
+		{header: "", want: 0},
+		{header: "bytes=0-1023", want: 1024},
+		{header: "bytes=10-1023", wantErr: true},
+		{header: "bytes=0-x", wantErr: true},
+	}
+	for _, tc := range testCases {
+		got, err := parseAckedOffset(tc.header)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("parseAckedOffset(%q) error = %v, wantErr %v", tc.header, err, tc.wantErr)
+		}
+		if got != tc.want {
+			t.Errorf("parseAckedOffset(%q) = %d, want %d", tc.header, got, tc.want)
+		}
+	}
+}
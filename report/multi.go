@@ -0,0 +1,284 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MultiReporter sends the profiling report to all of its reporters
+// concurrently.
+// The profile is read once and each reporter reads its own copy.
+// A failing reporter doesn't prevent the others from the report, and
+// the error has all of the failures. (errors.Is matches any of them)
+type MultiReporter struct {
+	reporters []Reporter
+}
+
+// NewMultiReporter returns the new MultiReporter.
+func NewMultiReporter(reporters ...Reporter) *MultiReporter {
+	return &MultiReporter{
+		reporters: reporters,
+	}
+}
+
+// Timeout returns the longest timeout of the reporters which are
+// the TimeoutReporter, so the slowest one isn't cut off.
+func (m *MultiReporter) Timeout() time.Duration {
+	var timeout time.Duration
+	for _, r := range m.reporters {
+		tr, ok := r.(TimeoutReporter)
+		if !ok {
+			continue
+		}
+		if t := tr.Timeout(); t > timeout {
+			timeout = t
+		}
+	}
+	return timeout
+}
+
+// Ping checks all of the reporters which are the PingReporter.
+// The error has all of the failures like the reports.
+func (m *MultiReporter) Ping(ctx context.Context) error {
+	return m.each(func(rp Reporter) error {
+		if pr, ok := rp.(PingReporter); ok {
+			return pr.Ping(ctx)
+		}
+		return nil
+	})
+}
+
+// Flush flushes all of the reporters which are the Flusher.
+// The error has all of the failures like the reports.
+func (m *MultiReporter) Flush(ctx context.Context) error {
+	return m.each(func(rp Reporter) error {
+		if f, ok := rp.(Flusher); ok {
+			return f.Flush(ctx)
+		}
+		return nil
+	})
+}
+
+// ReportCPUProfile sends the CPU profiling data to all of the reporters.
+func (m *MultiReporter) ReportCPUProfile(
+	ctx context.Context, r io.Reader, ci CPUInfo,
+) error {
+	return m.report(r, func(rp Reporter, pr io.Reader) error {
+		return rp.ReportCPUProfile(ctx, pr, ci)
+	})
+}
+
+// ReportHeapProfile sends the heap profiling data to all of the reporters.
+func (m *MultiReporter) ReportHeapProfile(
+	ctx context.Context, r io.Reader, mi MemInfo,
+) error {
+	return m.report(r, func(rp Reporter, pr io.Reader) error {
+		return rp.ReportHeapProfile(ctx, pr, mi)
+	})
+}
+
+// ReportGoroutineProfile sends the goroutine profiling data to all of
+// the reporters.
+func (m *MultiReporter) ReportGoroutineProfile(
+	ctx context.Context, r io.Reader, gi GoroutineInfo,
+) error {
+	return m.report(r, func(rp Reporter, pr io.Reader) error {
+		return rp.ReportGoroutineProfile(ctx, pr, gi)
+	})
+}
+
+// ReportProfile sends the profiling data of the kind to all of
+// the reporters. (See the ReportProfile function)
+func (m *MultiReporter) ReportProfile(
+	ctx context.Context, r io.Reader, kind ProfileKind, pi ProfileInfo,
+) error {
+	return m.report(r, func(rp Reporter, pr io.Reader) error {
+		return ReportProfile(ctx, rp, pr, kind, pi)
+	})
+}
+
+func (m *MultiReporter) report(
+	r io.Reader, fn func(rp Reporter, pr io.Reader) error,
+) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to read the profile: %w", err)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(m.reporters))
+	)
+	for i, rp := range m.reporters {
+		wg.Add(1)
+		go func(i int, rp Reporter) {
+			defer wg.Done()
+			errs[i] = fn(rp, bytes.NewReader(b))
+		}(i, rp)
+	}
+	wg.Wait()
+	return joinReporterErrors(errs)
+}
+
+// each calls the fn with all of the reporters, and returns the error
+// with all of the failures.
+func (m *MultiReporter) each(fn func(rp Reporter) error) error {
+	errs := make([]error, len(m.reporters))
+	for i, rp := range m.reporters {
+		errs[i] = fn(rp)
+	}
+	return joinReporterErrors(errs)
+}
+
+// joinReporterErrors returns the error with the non-nil errors of
+// the reporters. The errs has an entry per reporter.
+func joinReporterErrors(errs []error) error {
+	var failed multiError
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf(
+			"autopprof: %d of %d reporters failed: %w",
+			len(failed), len(errs), failed,
+		)
+	}
+	return nil
+}
+
+// multiError is the errors of the reporters. It's the errors.Join
+// which the go1.19 lacks.
+type multiError []error
+
+func (e multiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the errors matches the target.
+func (e multiError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap returns the errors for the errors.As of the go1.20 or later.
+func (e multiError) Unwrap() []error {
+	return e
+}
+
+// FilteredReporter sends the profiling report to the inner reporter
+// only if the report matches the predicate.
+// The info passed to the predicate is the CPUInfo, MemInfo or
+// GoroutineInfo depending on the kind, or the ProfileInfo for
+// the ReportProfile.
+type FilteredReporter struct {
+	inner Reporter
+	match func(kind ProfileKind, info interface{}) bool
+}
+
+// NewFilteredReporter returns the FilteredReporter wrapping the inner
+// reporter. It's composable with the MultiReporter to route the reports
+// to the destinations, e.g. only the severe breaches to the remote one:
+//
+//	report.NewMultiReporter(
+//		local,
+//		report.NewFilteredReporter(remote, func(_ report.ProfileKind, info interface{}) bool {
+//			ci, ok := info.(report.CPUInfo)
+//			return ok && ci.UsagePercentage > 90
+//		}),
+//	)
+//
+// For the routing by the kind and the severity, see the Router.
+func NewFilteredReporter(
+	inner Reporter, match func(kind ProfileKind, info interface{}) bool,
+) *FilteredReporter {
+	return &FilteredReporter{
+		inner: inner,
+		match: match,
+	}
+}
+
+// Timeout returns the timeout of the inner reporter if it's
+// a TimeoutReporter. Otherwise, it returns zero.
+func (f *FilteredReporter) Timeout() time.Duration {
+	if tr, ok := f.inner.(TimeoutReporter); ok {
+		return tr.Timeout()
+	}
+	return 0
+}
+
+// Ping checks the inner reporter if it's a PingReporter.
+func (f *FilteredReporter) Ping(ctx context.Context) error {
+	if pr, ok := f.inner.(PingReporter); ok {
+		return pr.Ping(ctx)
+	}
+	return nil
+}
+
+// Flush flushes the inner reporter if it's a Flusher. The reports
+// filtered out aren't buffered, so all of the buffered ones are sent.
+func (f *FilteredReporter) Flush(ctx context.Context) error {
+	if fl, ok := f.inner.(Flusher); ok {
+		return fl.Flush(ctx)
+	}
+	return nil
+}
+
+// ReportCPUProfile sends the CPU profiling data to the inner reporter
+// if it matches the predicate.
+func (f *FilteredReporter) ReportCPUProfile(
+	ctx context.Context, r io.Reader, ci CPUInfo,
+) error {
+	if !f.match(ProfileKindCPU, ci) {
+		return nil
+	}
+	return f.inner.ReportCPUProfile(ctx, r, ci)
+}
+
+// ReportHeapProfile sends the heap profiling data to the inner reporter
+// if it matches the predicate.
+func (f *FilteredReporter) ReportHeapProfile(
+	ctx context.Context, r io.Reader, mi MemInfo,
+) error {
+	if !f.match(ProfileKindHeap, mi) {
+		return nil
+	}
+	return f.inner.ReportHeapProfile(ctx, r, mi)
+}
+
+// ReportGoroutineProfile sends the goroutine profiling data to the
+// inner reporter if it matches the predicate.
+func (f *FilteredReporter) ReportGoroutineProfile(
+	ctx context.Context, r io.Reader, gi GoroutineInfo,
+) error {
+	if !f.match(ProfileKindGoroutine, gi) {
+		return nil
+	}
+	return f.inner.ReportGoroutineProfile(ctx, r, gi)
+}
+
+// ReportProfile sends the profiling data of the kind to the inner
+// reporter if it matches the predicate. (See the ReportProfile function)
+func (f *FilteredReporter) ReportProfile(
+	ctx context.Context, r io.Reader, kind ProfileKind, pi ProfileInfo,
+) error {
+	if !f.match(kind, pi) {
+		return nil
+	}
+	return ReportProfile(ctx, f.inner, r, kind, pi)
+}
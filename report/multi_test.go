@@ -0,0 +1,194 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestMultiReporter_ReportCPUProfile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	ci := CPUInfo{
+		ThresholdPercentage: 50,
+		UsagePercentage:     95,
+	}
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	read := func(_ context.Context, r io.Reader, _ CPUInfo) error {
+		b, err := io.ReadAll(r)
+		mu.Lock()
+		got = append(got, string(b))
+		mu.Unlock()
+		return err
+	}
+	local := NewMockReporter(ctrl)
+	local.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), ci).
+		DoAndReturn(read)
+	remote := NewMockReporter(ctrl)
+	remote.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), ci).
+		DoAndReturn(read)
+
+	m := NewMultiReporter(local, remote)
+	if err := m.ReportCPUProfile(context.Background(), strings.NewReader("prof"), ci); err != nil {
+		t.Errorf("ReportCPUProfile() = %v, want nil", err)
+	}
+	if len(got) != 2 || got[0] != "prof" || got[1] != "prof" {
+		t.Errorf("reported profiles = %q, want the same profile for each reporter", got)
+	}
+}
+
+func TestMultiReporter_error(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	errDown := errors.New("sink is down")
+	ok := NewMockReporter(ctrl)
+	ok.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+	down := NewMockReporter(ctrl)
+	down.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(errDown)
+
+	m := NewMultiReporter(ok, down)
+	err := m.ReportHeapProfile(context.Background(), strings.NewReader("prof"), MemInfo{})
+	if !errors.Is(err, errDown) {
+		t.Errorf("ReportHeapProfile() = %v, want %v", err, errDown)
+	}
+}
+
+func TestMultiReporter_errors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	errDown := errors.New("sink is down")
+	errAuth := errors.New("invalid token")
+	down := NewMockReporter(ctrl)
+	down.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(errDown)
+	auth := NewMockReporter(ctrl)
+	auth.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(errAuth)
+
+	m := NewMultiReporter(down, auth)
+	err := m.ReportCPUProfile(context.Background(), strings.NewReader("prof"), CPUInfo{})
+	for _, want := range []error{errDown, errAuth} {
+		if !errors.Is(err, want) {
+			t.Errorf("ReportCPUProfile() = %v, want %v", err, want)
+		}
+	}
+	if !strings.Contains(err.Error(), "2 of 2 reporters failed") {
+		t.Errorf("ReportCPUProfile() = %v, want the number of the failures", err)
+	}
+}
+
+func TestMultiReporter_Timeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	short := NewMockTimeoutReporter(ctrl)
+	short.EXPECT().Timeout().Return(time.Second)
+	long := NewMockTimeoutReporter(ctrl)
+	long.EXPECT().Timeout().Return(time.Minute)
+
+	m := NewMultiReporter(short, NewMockReporter(ctrl), long)
+	if got := m.Timeout(); got != time.Minute {
+		t.Errorf("Timeout() = %s, want %s", got, time.Minute)
+	}
+}
+
+func TestMultiReporter_PingFlush(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	errDown := errors.New("sink is down")
+	errAuth := errors.New("invalid token")
+	down := NewMockPingReporter(ctrl)
+	down.EXPECT().Ping(gomock.Any()).Return(errDown)
+	auth := NewMockPingReporter(ctrl)
+	auth.EXPECT().Ping(gomock.Any()).Return(errAuth)
+
+	m := NewMultiReporter(down, NewMockReporter(ctrl), auth)
+	err := m.Ping(context.Background())
+	for _, want := range []error{errDown, errAuth} {
+		if !errors.Is(err, want) {
+			t.Errorf("Ping() = %v, want %v", err, want)
+		}
+	}
+
+	// All of the reporters are flushed though one fails.
+	errFlush := errors.New("flush")
+	failing := flushReporter{NewMockReporter(ctrl), NewMockFlusher(ctrl)}
+	failing.MockFlusher.EXPECT().Flush(gomock.Any()).Return(errFlush)
+	flushed := flushReporter{NewMockReporter(ctrl), NewMockFlusher(ctrl)}
+	flushed.MockFlusher.EXPECT().Flush(gomock.Any()).Return(nil)
+
+	m = NewMultiReporter(failing, NewMockReporter(ctrl), flushed)
+	err = m.Flush(context.Background())
+	if !errors.Is(err, errFlush) {
+		t.Errorf("Flush() = %v, want %v", err, errFlush)
+	}
+	if !strings.Contains(err.Error(), "1 of 3 reporters failed") {
+		t.Errorf("Flush() = %v, want the number of the failures", err)
+	}
+}
+
+func TestFilteredReporter_PingFlush(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	never := func(ProfileKind, interface{}) bool { return false }
+
+	errPing := errors.New("ping")
+	pinger := NewMockPingReporter(ctrl)
+	pinger.EXPECT().Ping(gomock.Any()).Return(errPing)
+	if err := NewFilteredReporter(pinger, never).Ping(context.Background()); !errors.Is(err, errPing) {
+		t.Errorf("Ping() = %v, want %v", err, errPing)
+	}
+
+	flusher := flushReporter{NewMockReporter(ctrl), NewMockFlusher(ctrl)}
+	flusher.MockFlusher.EXPECT().Flush(gomock.Any()).Return(nil)
+	if err := NewFilteredReporter(flusher, never).Flush(context.Background()); err != nil {
+		t.Errorf("Flush() = %v, want nil", err)
+	}
+}
+
+func TestFilteredReporter(t *testing.T) {
+	severe := func(kind ProfileKind, info interface{}) bool {
+		ci, ok := info.(CPUInfo)
+		return kind == ProfileKindCPU && ok && ci.UsagePercentage > 90
+	}
+	testCases := []struct {
+		name  string
+		ci    CPUInfo
+		wantN int
+	}{
+		{name: "matched", ci: CPUInfo{UsagePercentage: 95}, wantN: 1},
+		{name: "filtered", ci: CPUInfo{UsagePercentage: 80}, wantN: 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			mockReporter := NewMockReporter(ctrl)
+			mockReporter.EXPECT().
+				ReportCPUProfile(gomock.Any(), gomock.Any(), tc.ci).
+				Times(tc.wantN).
+				Return(nil)
+
+			f := NewFilteredReporter(mockReporter, severe)
+			if err := f.ReportCPUProfile(context.Background(), strings.NewReader("prof"), tc.ci); err != nil {
+				t.Errorf("ReportCPUProfile() = %v, want nil", err)
+			}
+		})
+	}
+}
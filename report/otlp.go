@@ -0,0 +1,355 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultOTLPMaxRecordBytes = 1 << 20 // 1MiB.
+
+	// The OTLP severity numbers of the Severity.
+	otlpSeverityInfo  = 9
+	otlpSeverityWarn  = 13
+	otlpSeverityError = 17
+
+	otlpScopeName = "autopprof"
+)
+
+// OTLPOption is the transport config of the OTLP/HTTP exporter.
+type OTLPOption struct {
+	// Endpoint is the URL of the OTLP/HTTP signal.
+	//  (e.g. http://otel-collector:4318/v1/logs)
+	Endpoint string
+	// Headers are the headers of the export requests, such as
+	//  the authorization of the backend.
+	// Default: nil.
+	Headers map[string]string
+	// Client is the HTTP client to export.
+	// Default: http.DefaultClient.
+	Client *http.Client
+}
+
+// OTLPLogReporter is the reporter to export an OTLP log record per
+// report to the OTLP/HTTP logs endpoint with the JSON encoding, so
+// the profiles go through the existing log pipeline without
+// the profiling backend.
+//
+// The metadata are the attributes of the record prefixed with
+// "autopprof.", (e.g. autopprof.UsagePercentage) and the profiling data
+// is the bytes body. The severity of the record follows the SeverityOf.
+//
+// The log backends limit the size of a record, so the profile larger
+// than the OTLPLogReporterOption.MaxRecordBytes is split into
+// the records of the chunks sharing the autopprof.chunk.id attribute
+// and ordered by the autopprof.chunk.index. With
+// the OTLPLogReporterOption.Location, the record refers to where
+// the profile is stored instead.
+type OTLPLogReporter struct {
+	app      string
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+
+	maxRecordBytes int
+	location       func(kind ProfileKind, info interface{}) string
+}
+
+// OTLPLogReporterOption is the option for the OTLP log reporter.
+type OTLPLogReporterOption struct {
+	App string
+	OTLPOption
+
+	// MaxRecordBytes is the maximum size of the profiling data in
+	//  a record.
+	// Default: 1MiB.
+	MaxRecordBytes int
+
+	// Location returns where the profile is stored by the storage
+	//  reporter, (e.g. the URL of the object) which is exported as
+	//  the autopprof.location attribute instead of the chunks of
+	//  the profile larger than the MaxRecordBytes. The info is
+	//  the CPUInfo, MemInfo, GoroutineInfo or ProfileInfo depending on
+	//  the kind.
+	// Default: nil. (means chunking)
+	Location func(kind ProfileKind, info interface{}) string
+}
+
+// NewOTLPLogReporter returns the new OTLPLogReporter.
+func NewOTLPLogReporter(opt *OTLPLogReporterOption) *OTLPLogReporter {
+	o := &OTLPLogReporter{
+		app:            opt.App,
+		endpoint:       opt.Endpoint,
+		headers:        opt.Headers,
+		client:         opt.Client,
+		maxRecordBytes: opt.MaxRecordBytes,
+		location:       opt.Location,
+	}
+	if o.client == nil {
+		o.client = http.DefaultClient
+	}
+	if o.maxRecordBytes <= 0 {
+		o.maxRecordBytes = defaultOTLPMaxRecordBytes
+	}
+	return o
+}
+
+// ReportCPUProfile exports the CPU profiling data as the log records.
+func (o *OTLPLogReporter) ReportCPUProfile(
+	ctx context.Context, r io.Reader, ci CPUInfo,
+) error {
+	hostname, _ := os.Hostname() // Don't care about this error.
+	now := reportTime(ci.Sequence)
+	filename := fmt.Sprintf(CPUProfileFilenameFmt, o.app, hostname, now) + ci.ContentEncoding.Suffix()
+	return o.export(ctx, r, ProfileKindCPU, filename, ci)
+}
+
+// ReportHeapProfile exports the heap profiling data as the log records.
+func (o *OTLPLogReporter) ReportHeapProfile(
+	ctx context.Context, r io.Reader, mi MemInfo,
+) error {
+	hostname, _ := os.Hostname() // Don't care about this error.
+	now := reportTime(mi.Sequence)
+	filename := fmt.Sprintf(HeapProfileFilenameFmt, o.app, hostname, now) + mi.ContentEncoding.Suffix()
+	if mi.SampleType != "" {
+		filename = fmt.Sprintf(HeapViewProfileFilenameFmt, o.app, hostname, mi.SampleType, now) + mi.ContentEncoding.Suffix()
+	}
+	return o.export(ctx, r, ProfileKindHeap, filename, mi)
+}
+
+// ReportGoroutineProfile exports the goroutine profiling data as the log
+// records.
+func (o *OTLPLogReporter) ReportGoroutineProfile(
+	ctx context.Context, r io.Reader, gi GoroutineInfo,
+) error {
+	hostname, _ := os.Hostname() // Don't care about this error.
+	now := reportTime(gi.Sequence)
+	filename := fmt.Sprintf(GoroutineProfileFilenameFmt, o.app, hostname, now) + gi.ContentEncoding.Suffix()
+	if gi.Dump {
+		filename = fmt.Sprintf(GoroutineDumpFilenameFmt, o.app, hostname, now) + gi.ContentEncoding.Suffix()
+	}
+	return o.export(ctx, r, ProfileKindGoroutine, filename, gi)
+}
+
+// ReportProfile exports the profiling data of the kind as the log
+// records.
+func (o *OTLPLogReporter) ReportProfile(
+	ctx context.Context, r io.Reader, kind ProfileKind, pi ProfileInfo,
+) error {
+	hostname, _ := os.Hostname() // Don't care about this error.
+	now := reportTime(pi.Sequence)
+	filename := fmt.Sprintf(NamedProfileFilenameFmt, o.app, hostname, kind, now) + pi.ContentEncoding.Suffix()
+	return o.export(ctx, r, kind, filename, pi)
+}
+
+func (o *OTLPLogReporter) export(
+	ctx context.Context, r io.Reader, kind ProfileKind, filename string, info interface{},
+) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to read the profile: %w", err)
+	}
+	attrs, err := otlpMetadataAttributes(info)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to encode the metadata: %w", err)
+	}
+	attrs = append(attrs,
+		otlpStringAttribute("autopprof.kind", string(kind)),
+		otlpStringAttribute("autopprof.filename", filename),
+		otlpIntAttribute("autopprof.size", int64(len(b))),
+	)
+	severity := SeverityOf(kind, info)
+
+	if len(b) > o.maxRecordBytes && o.location != nil {
+		if loc := o.location(kind, info); loc != "" {
+			attrs = append(attrs, otlpStringAttribute("autopprof.location", loc))
+			return o.send(ctx, []otlpLogRecord{newOTLPLogRecord(severity, nil, attrs)})
+		}
+	}
+	if len(b) <= o.maxRecordBytes {
+		return o.send(ctx, []otlpLogRecord{newOTLPLogRecord(severity, b, attrs)})
+	}
+
+	// Export a chunk per request, since the backends limit the size of
+	//  the request as well.
+	id := newUploadID()
+	count := (len(b) + o.maxRecordBytes - 1) / o.maxRecordBytes
+	for i := 0; i < count; i++ {
+		end := (i + 1) * o.maxRecordBytes
+		if end > len(b) {
+			end = len(b)
+		}
+		chunkAttrs := append(attrs[:len(attrs):len(attrs)],
+			otlpStringAttribute("autopprof.chunk.id", id),
+			otlpIntAttribute("autopprof.chunk.index", int64(i)),
+			otlpIntAttribute("autopprof.chunk.count", int64(count)),
+		)
+		record := newOTLPLogRecord(severity, b[i*o.maxRecordBytes:end], chunkAttrs)
+		if err := o.send(ctx, []otlpLogRecord{record}); err != nil {
+			return fmt.Errorf("autopprof: failed to export the chunk %d of %d: %w", i+1, count, err)
+		}
+	}
+	return nil
+}
+
+func (o *OTLPLogReporter) send(ctx context.Context, records []otlpLogRecord) error {
+	hostname, _ := os.Hostname() // Don't care about this error.
+	body, err := json.Marshal(otlpLogsRequest{
+		ResourceLogs: []otlpResourceLogs{{
+			Resource: otlpResource{
+				Attributes: []otlpAttribute{
+					otlpStringAttribute("service.name", o.app),
+					otlpStringAttribute("host.name", hostname),
+				},
+			},
+			ScopeLogs: []otlpScopeLogs{{
+				Scope:      otlpScope{Name: otlpScopeName},
+				LogRecords: records,
+			}},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to encode the log records: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to export the log records: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body) // Reuse the connection.
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("autopprof: failed to export the log records: unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// otlpMetadataAttributes returns the attributes of the fields of
+// the info sorted by the key. The numbers are the double attributes,
+// and the non-scalar ones (e.g. the Labels) are the JSON strings.
+func otlpMetadataAttributes(info interface{}) ([]otlpAttribute, error) {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]otlpAttribute, 0, len(keys))
+	for _, k := range keys {
+		key := "autopprof." + k
+		var v interface{}
+		if err := json.Unmarshal(fields[k], &v); err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case nil:
+			// Skip the empty fields.
+		case string:
+			if v != "" {
+				attrs = append(attrs, otlpStringAttribute(key, v))
+			}
+		case bool:
+			attrs = append(attrs, otlpAttribute{Key: key, Value: otlpAnyValue{BoolValue: &v}})
+		case float64:
+			attrs = append(attrs, otlpAttribute{Key: key, Value: otlpAnyValue{DoubleValue: &v}})
+		default:
+			attrs = append(attrs, otlpStringAttribute(key, string(fields[k])))
+		}
+	}
+	return attrs, nil
+}
+
+func newOTLPLogRecord(severity Severity, body []byte, attrs []otlpAttribute) otlpLogRecord {
+	number, text := otlpSeverityInfo, "INFO"
+	switch severity {
+	case SeverityHigh:
+		number, text = otlpSeverityWarn, "WARN"
+	case SeverityCritical:
+		number, text = otlpSeverityError, "ERROR"
+	}
+	record := otlpLogRecord{
+		TimeUnixNano:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		SeverityNumber: number,
+		SeverityText:   text,
+		Attributes:     attrs,
+	}
+	if body != nil {
+		// The bytes are base64 encoded by the JSON encoding.
+		record.Body = &otlpAnyValue{BytesValue: body}
+	}
+	return record
+}
+
+func otlpStringAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpAnyValue{StringValue: &value}}
+}
+
+func otlpIntAttribute(key string, value int64) otlpAttribute {
+	// The 64-bit integers are the strings in the OTLP JSON encoding.
+	v := strconv.FormatInt(value, 10)
+	return otlpAttribute{Key: key, Value: otlpAnyValue{IntValue: &v}}
+}
+
+// The OTLP/HTTP JSON encoding of the ExportLogsServiceRequest.
+type (
+	otlpLogsRequest struct {
+		ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+	}
+	otlpResourceLogs struct {
+		Resource  otlpResource    `json:"resource"`
+		ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeLogs struct {
+		Scope      otlpScope       `json:"scope"`
+		LogRecords []otlpLogRecord `json:"logRecords"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpLogRecord struct {
+		TimeUnixNano   string          `json:"timeUnixNano"`
+		SeverityNumber int             `json:"severityNumber"`
+		SeverityText   string          `json:"severityText"`
+		Body           *otlpAnyValue   `json:"body,omitempty"`
+		Attributes     []otlpAttribute `json:"attributes"`
+	}
+	otlpAttribute struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+		BytesValue  []byte   `json:"bytesValue,omitempty"`
+	}
+)
@@ -0,0 +1,173 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// otlpLogsServer is the OTLP/HTTP logs endpoint storing the records.
+type otlpLogsServer struct {
+	mu      sync.Mutex
+	records []otlpLogRecord
+	header  http.Header
+}
+
+func (s *otlpLogsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req otlpLogsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.header = r.Header
+	for _, rl := range req.ResourceLogs {
+		for _, sl := range rl.ScopeLogs {
+			s.records = append(s.records, sl.LogRecords...)
+		}
+	}
+}
+
+func otlpAttributeOf(record otlpLogRecord, key string) (otlpAnyValue, bool) {
+	for _, a := range record.Attributes {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return otlpAnyValue{}, false
+}
+
+func TestOTLPLogReporter_ReportCPUProfile(t *testing.T) {
+	srv := &otlpLogsServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	o := NewOTLPLogReporter(&OTLPLogReporterOption{
+		App: "app",
+		OTLPOption: OTLPOption{
+			Endpoint: ts.URL,
+			Headers:  map[string]string{"Authorization": "Bearer token"},
+		},
+	})
+	profile := []byte("profile")
+	ci := CPUInfo{
+		ThresholdPercentage: 75,
+		UsagePercentage:     90,
+		Labels:              map[string]string{"env": "prod"},
+	}
+	if err := o.ReportCPUProfile(context.Background(), bytes.NewReader(profile), ci); err != nil {
+		t.Fatalf("ReportCPUProfile() = %v, want nil", err)
+	}
+
+	if got := srv.header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization = %q, want the header of the option", got)
+	}
+	if len(srv.records) != 1 {
+		t.Fatalf("got %d records, want 1", len(srv.records))
+	}
+	record := srv.records[0]
+	if record.Body == nil || !bytes.Equal(record.Body.BytesValue, profile) {
+		t.Errorf("body = %v, want the profile", record.Body)
+	}
+	if record.SeverityText != "ERROR" {
+		t.Errorf("severity = %s, want ERROR for the critical usage", record.SeverityText)
+	}
+	if v, _ := otlpAttributeOf(record, "autopprof.UsagePercentage"); v.DoubleValue == nil || *v.DoubleValue != 90 {
+		t.Errorf("autopprof.UsagePercentage = %v, want 90", v)
+	}
+	if v, _ := otlpAttributeOf(record, "autopprof.Labels"); v.StringValue == nil || *v.StringValue != `{"env":"prod"}` {
+		t.Errorf("autopprof.Labels = %v, want the JSON of the labels", v)
+	}
+	if v, _ := otlpAttributeOf(record, "autopprof.kind"); v.StringValue == nil || *v.StringValue != "cpu" {
+		t.Errorf("autopprof.kind = %v, want cpu", v)
+	}
+	if _, ok := otlpAttributeOf(record, "autopprof.chunk.id"); ok {
+		t.Errorf("autopprof.chunk.id is set for the small profile")
+	}
+}
+
+func TestOTLPLogReporter_chunks(t *testing.T) {
+	srv := &otlpLogsServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	o := NewOTLPLogReporter(&OTLPLogReporterOption{
+		App:            "app",
+		OTLPOption:     OTLPOption{Endpoint: ts.URL},
+		MaxRecordBytes: 4,
+	})
+	profile := []byte("0123456789")
+	if err := o.ReportHeapProfile(context.Background(), bytes.NewReader(profile), MemInfo{}); err != nil {
+		t.Fatalf("ReportHeapProfile() = %v, want nil", err)
+	}
+
+	if len(srv.records) != 3 {
+		t.Fatalf("got %d records, want 3 chunks", len(srv.records))
+	}
+	var (
+		got []byte
+		id  string
+	)
+	for i, record := range srv.records {
+		got = append(got, record.Body.BytesValue...)
+		v, _ := otlpAttributeOf(record, "autopprof.chunk.id")
+		if i > 0 && *v.StringValue != id {
+			t.Errorf("chunk %d has the id %s, want %s", i, *v.StringValue, id)
+		}
+		id = *v.StringValue
+		if v, _ := otlpAttributeOf(record, "autopprof.chunk.count"); v.IntValue == nil || *v.IntValue != "3" {
+			t.Errorf("autopprof.chunk.count = %v, want 3", v)
+		}
+	}
+	if !bytes.Equal(got, profile) {
+		t.Errorf("chunks = %s, want %s", got, profile)
+	}
+}
+
+func TestOTLPLogReporter_location(t *testing.T) {
+	srv := &otlpLogsServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	o := NewOTLPLogReporter(&OTLPLogReporterOption{
+		App:            "app",
+		OTLPOption:     OTLPOption{Endpoint: ts.URL},
+		MaxRecordBytes: 4,
+		Location: func(kind ProfileKind, _ interface{}) string {
+			return "s3://bucket/" + string(kind)
+		},
+	})
+	if err := o.ReportGoroutineProfile(context.Background(), bytes.NewReader([]byte("0123456789")), GoroutineInfo{}); err != nil {
+		t.Fatalf("ReportGoroutineProfile() = %v, want nil", err)
+	}
+
+	if len(srv.records) != 1 {
+		t.Fatalf("got %d records, want 1 referring to the location", len(srv.records))
+	}
+	if srv.records[0].Body != nil {
+		t.Errorf("body = %v, want nil", srv.records[0].Body)
+	}
+	if v, _ := otlpAttributeOf(srv.records[0], "autopprof.location"); v.StringValue == nil || *v.StringValue != "s3://bucket/goroutine" {
+		t.Errorf("autopprof.location = %v, want s3://bucket/goroutine", v)
+	}
+}
+
+func TestOTLPLogReporter_failure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	}))
+	defer ts.Close()
+
+	o := NewOTLPLogReporter(&OTLPLogReporterOption{
+		App:        "app",
+		OTLPOption: OTLPOption{Endpoint: ts.URL},
+	})
+	if err := o.ReportCPUProfile(context.Background(), bytes.NewReader([]byte("profile")), CPUInfo{}); err == nil {
+		t.Errorf("ReportCPUProfile() = nil, want the error of the status")
+	}
+}
@@ -2,7 +2,12 @@ package report
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"sort"
+	"strings"
+	"time"
 )
 
 //go:generate mockgen -source=report.go -destination=report_mock.go -package=report
@@ -15,8 +20,160 @@ const (
 	// HeapProfileFilenameFmt is the filename format for the heap profile.
 	// pprof.<app>.<hostname>.alloc_objects.alloc_space.inuse_objects.inuse_space.<report_time>.pprof.
 	HeapProfileFilenameFmt = "pprof.%s.%s.alloc_objects.alloc_space.inuse_objects.inuse_space.%s.pprof"
+
+	// HeapViewProfileFilenameFmt is the filename format for a view of
+	// the heap profile. (See MemInfo.SampleType)
+	// pprof.<app>.<hostname>.<sample_type>.<report_time>.pprof.
+	HeapViewProfileFilenameFmt = "pprof.%s.%s.%s.%s.pprof"
+
+	// GoroutineProfileFilenameFmt is the filename format for the goroutine profile.
+	// pprof.<app>.<hostname>.goroutine.<report_time>.pprof.
+	GoroutineProfileFilenameFmt = "pprof.%s.%s.goroutine.%s.pprof"
+
+	// NamedProfileFilenameFmt is the filename format for the profile
+	// reported by the ReportProfile.
+	// pprof.<app>.<hostname>.<profile_name>.<report_time>.pprof.
+	NamedProfileFilenameFmt = "pprof.%s.%s.%s.%s.pprof"
+
+	// GoroutineDumpFilenameFmt is the filename format for the goroutine dump.
+	// goroutine.<app>.<hostname>.<report_time>.txt.
+	GoroutineDumpFilenameFmt = "goroutine.%s.%s.%s.txt"
+)
+
+// reportTime returns the <report_time> of the filenames. With
+// the sequence number, (See CPUInfo.Sequence) it's suffixed with
+// the zero-padded sequence number, so the filenames of the same second
+// are ordered by the capture.
+func reportTime(seq uint64) string {
+	now := time.Now().Format(reportTimeLayout)
+	if seq == 0 {
+		return now
+	}
+	return fmt.Sprintf("%s.%010d", now, seq)
+}
+
+// Labels of the Kubernetes. (See Option.KubernetesLabels of the autopprof)
+const (
+	// LabelPod is the name of the pod.
+	LabelPod = "pod"
+	// LabelNamespace is the namespace of the pod.
+	LabelNamespace = "namespace"
+	// LabelNode is the name of the node the pod runs on.
+	LabelNode = "node"
+)
+
+// formatLabels returns the labels as the space-separated key=value
+// pairs sorted by the key.
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+labels[k])
+	}
+	return strings.Join(pairs, " ")
+}
+
+// Sample types of the heap profile. All of them are in the heap
+// profile, and the pprof tools open the inuse_space by default.
+// (See MemInfo.PrimarySampleType)
+const (
+	SampleTypeInuseSpace   = "inuse_space"
+	SampleTypeInuseObjects = "inuse_objects"
+	SampleTypeAllocSpace   = "alloc_space"
+	SampleTypeAllocObjects = "alloc_objects"
+)
+
+// Triggers of the profiles.
+const (
+	// TriggerFD means that the file descriptor usage crossed the threshold.
+	TriggerFD = "fd"
+	// TriggerHeap means that the goroutine dump is captured with
+	// the heap profile.
+	TriggerHeap = "heap"
+	// TriggerCPU means that the goroutine dump is captured at the moment
+	// the cpu usage crossed the threshold, before the cpu profile.
+	TriggerCPU = "cpu"
+	// TriggerGC means that the gc pressure crossed the threshold.
+	TriggerGC = "gc"
+	// TriggerCrash means that the application captured the final
+	// snapshot before the process dies. (e.g. a recovered panic)
+	TriggerCrash = "crash"
+	// TriggerCPUPressure means that the cpu pressure crossed
+	// the threshold while the cpu usage didn't.
+	TriggerCPUPressure = "cpu_pressure"
+	// TriggerGoroutineDrop means that the goroutines dropped sharply
+	// between the watches.
+	TriggerGoroutineDrop = "goroutine_drop"
+	// TriggerGoroutine means that the goroutines stayed over
+	// the threshold.
+	TriggerGoroutine = "goroutine"
+	// TriggerPID means that the tasks of the cgroup crossed
+	// the threshold of its pids limit.
+	TriggerPID = "pid"
+	// TriggerLatencyBreach means that the application notified
+	// the violation of its SLO. (e.g. the p99 latency over the budget)
+	TriggerLatencyBreach = "latency_breach"
+	// TriggerNUMA means that the memory usage on a NUMA node crossed
+	// the threshold while the memory usage didn't.
+	TriggerNUMA = "numa"
+	// TriggerMemRequest means that the memory usage against the request
+	// crossed the threshold while the one against the limit didn't.
+	TriggerMemRequest = "mem_request"
+	// TriggerErrorRate means that the rate of the errors recorded by
+	// the application crossed the threshold.
+	TriggerErrorRate = "error_rate"
+	// TriggerStartup means that the profile is captured shortly after
+	// the start as the baseline of the cold start, regardless of
+	// the thresholds.
+	TriggerStartup = "startup"
+	// TriggerManual means that the operator requested the profile
+	// regardless of the thresholds. (e.g. by the trigger file)
+	TriggerManual = "manual"
 )
 
+// ProfileKind is the kind of the profile. Except for the ProfileKindCPU,
+// it's the name of the profile of the pprof.Lookup.
+type ProfileKind string
+
+// Kinds of the profile.
+const (
+	ProfileKindCPU          ProfileKind = "cpu"
+	ProfileKindHeap         ProfileKind = "heap"
+	ProfileKindAllocs       ProfileKind = "allocs"
+	ProfileKindGoroutine    ProfileKind = "goroutine"
+	ProfileKindThreadCreate ProfileKind = "threadcreate"
+	ProfileKindBlock        ProfileKind = "block"
+	ProfileKindMutex        ProfileKind = "mutex"
+
+	// ProfileKindLiveness is the marker report without the profiling
+	// data, sent periodically to tell that the autopprof is alive.
+	// (See Option.LivenessInterval)
+	ProfileKindLiveness ProfileKind = "liveness"
+	// ProfileKindWallClock is the wall-clock profile of both of
+	// the on-CPU and off-CPU time of the goroutines, in the pprof
+	// format. (See Option.EnableWallClock)
+	ProfileKindWallClock ProfileKind = "wallclock"
+)
+
+// LookupName returns the name of the profile for the pprof.Lookup.
+// It returns empty for the ProfileKindCPU, ProfileKindLiveness and
+// ProfileKindWallClock which aren't looked up.
+func (k ProfileKind) LookupName() string {
+	switch k {
+	case ProfileKindCPU, ProfileKindLiveness, ProfileKindWallClock:
+		return ""
+	}
+	return string(k)
+}
+
+// ErrUnsupportedProfileKind is returned if the reporter can't report
+// the kind of the profile.
+var ErrUnsupportedProfileKind = errors.New("autopprof: unsupported profile kind")
+
 // Reporter is responsible for reporting the profiling report to the destination.
 type Reporter interface {
 	// ReportCPUProfile sends the CPU profiling data to the specific destination.
@@ -24,16 +181,438 @@ type Reporter interface {
 
 	// ReportHeapProfile sends the heap profiling data to the specific destination.
 	ReportHeapProfile(ctx context.Context, r io.Reader, mi MemInfo) error
+
+	// ReportGoroutineProfile sends the goroutine profiling data to the specific destination.
+	ReportGoroutineProfile(ctx context.Context, r io.Reader, gi GoroutineInfo) error
+}
+
+// StreamReporter is the Reporter which can consume the CPU profile
+// as a stream.
+// If the CanStream returns true, the CPU profile is written into the
+// reader passed to the ReportCPUProfile through a pipe instead of
+// being buffered in memory first. The reader isn't seekable, and the
+// reading blocks until the profiling ends.
+type StreamReporter interface {
+	Reporter
+
+	// CanStream reports whether the reporter can consume the stream.
+	CanStream() bool
+}
+
+// TimeoutReporter is the Reporter which advertises its own timeout of
+// a report, so the slow destination isn't cut off by the global timeout
+// (Option.ReportTimeout) and the fast one fails fast.
+// Zero means the global timeout.
+type TimeoutReporter interface {
+	Reporter
+
+	// Timeout returns the timeout of a report.
+	Timeout() time.Duration
+}
+
+// PingReporter is the Reporter which can check its connectivity, so
+// the misconfiguration (e.g. a wrong bucket or bad credentials) is
+// found at the deploy time instead of the first report during an
+// incident. (See Option.VerifyReporter of the autopprof)
+type PingReporter interface {
+	Reporter
+
+	// Ping checks that the destination is reachable with the config.
+	Ping(ctx context.Context) error
+}
+
+// ProfileReporter is the Reporter which can report any kind of
+// the profile, so the new profile types of the runtime are reported
+// without adding a method per type.
+type ProfileReporter interface {
+	Reporter
+
+	// ReportProfile sends the profiling data of the kind to the specific
+	// destination.
+	ReportProfile(ctx context.Context, r io.Reader, kind ProfileKind, pi ProfileInfo) error
+}
+
+// ReportProfile sends the profiling data of the kind to the reporter.
+// If the reporter isn't a ProfileReporter, the cpu, heap and goroutine
+// profiles are sent by the methods of the Reporter, and the other kinds
+// return ErrUnsupportedProfileKind. So does the cpu diff, (the cpu
+// profile with the Delta) since the CPUInfo can't tell it from the cpu
+// profile.
+func ReportProfile(
+	ctx context.Context, rp Reporter, r io.Reader, kind ProfileKind, pi ProfileInfo,
+) error {
+	if pr, ok := rp.(ProfileReporter); ok {
+		return pr.ReportProfile(ctx, r, kind, pi)
+	}
+	switch kind {
+	case ProfileKindCPU:
+		if pi.Delta {
+			break
+		}
+		return rp.ReportCPUProfile(ctx, r, CPUInfo{
+			SchemaVersion:   pi.SchemaVersion,
+			TriggerID:       pi.TriggerID,
+			Labels:          pi.Labels,
+			RetentionHint:   pi.RetentionHint,
+			ContentEncoding: pi.ContentEncoding,
+		})
+	case ProfileKindHeap:
+		return rp.ReportHeapProfile(ctx, r, MemInfo{
+			SchemaVersion:   pi.SchemaVersion,
+			TriggerID:       pi.TriggerID,
+			Labels:          pi.Labels,
+			RetentionHint:   pi.RetentionHint,
+			ContentEncoding: pi.ContentEncoding,
+		})
+	case ProfileKindGoroutine:
+		return rp.ReportGoroutineProfile(ctx, r, GoroutineInfo{
+			SchemaVersion:   pi.SchemaVersion,
+			TriggerID:       pi.TriggerID,
+			Labels:          pi.Labels,
+			RetentionHint:   pi.RetentionHint,
+			ContentEncoding: pi.ContentEncoding,
+		})
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedProfileKind, kind)
+}
+
+// BundleReporter is the Reporter which can send the bundle of
+// the profiles batched by the BatchReporter as a single payload.
+type BundleReporter interface {
+	Reporter
+
+	// ReportBundle sends the bundle to the specific destination.
+	// The bundle is a tar archive of the profiles and their metadata.
+	// (See BatchReporter)
+	ReportBundle(ctx context.Context, r io.Reader, bi BundleInfo) error
+}
+
+// Flusher is the Reporter which buffers the reports. The autopprof
+// flushes it on the Stop.
+type Flusher interface {
+	// Flush sends the buffered reports.
+	Flush(ctx context.Context) error
 }
 
 // CPUInfo is the CPU usage information.
 type CPUInfo struct {
+	// SchemaVersion is the SchemaVersion the struct is filled with.
+	SchemaVersion int
+
+	// Trigger is what triggered the cpu profile other than the cpu
+	//  usage. (e.g. TriggerCPUPressure) Empty means the cpu usage.
+	Trigger string
+
 	ThresholdPercentage float64
 	UsagePercentage     float64
+
+	// PressurePercentage is the cpu pressure, (some avg10 of the PSI)
+	//  and PressureThresholdPercentage is its threshold. They're zero
+	//  unless the Option.CPUPressureThreshold of the autopprof is set.
+	PressurePercentage          float64
+	PressureThresholdPercentage float64
+
+	// CPUQuotaCores and MemLimitBytes are the cpu quota in cores and
+	//  the memory limit in bytes of the cgroup in effect at the capture,
+	//  so the profiles after the resize (e.g. by the VPA) are
+	//  interpreted against the right limits. The cpu quota is the one
+	//  the usage is computed against, and the memory limit is the last
+	//  one read by the memory watcher. They're zero if unknown.
+	CPUQuotaCores float64
+	MemLimitBytes uint64
+
+	// ErrorRate is the errors per second recorded by the application,
+	//  and ErrorRateThreshold is its threshold, for
+	//  the TriggerErrorRate.
+	ErrorRate          float64
+	ErrorRateThreshold float64
+
+	// TriggerID is shared with the other profiles reported by the same
+	//  trigger. (See MemInfo.TriggerID)
+	TriggerID string
+	// IncidentID is shared by the profiles of the breaches within
+	//  the Option.IncidentWindow of the autopprof, which are likely of
+	//  the same incident. Empty means the incidents aren't tracked.
+	IncidentID string
+
+	// TopFunctions is the top functions in the CPU profile sorted by
+	//  the flat value. It's empty unless the Option.CPUTopN is set.
+	TopFunctions []FunctionStat
+
+	// ProfileLabels are the pprof labels the CPU profile is scoped to,
+	//  and ProfileLabelsPercentage is the share of the cpu time of
+	//  the samples of them. They're empty unless the
+	//  Option.CPUProfileLabels is set.
+	ProfileLabels           map[string]string
+	ProfileLabelsPercentage float64
+
+	// Sequence is the capture sequence number incremented across all
+	//  kinds of the profiles, and Elapsed is the monotonic time since
+	//  the start of the autopprof at the capture. They order
+	//  the profiles regardless of the wall clock adjustments. They're
+	//  zero unless the Option.CaptureSequence of the autopprof is set.
+	Sequence uint64
+	Elapsed  time.Duration
+
+	// Labels are the labels of the process the profile is captured
+	//  in, such as the pod of the Kubernetes. (See LabelPod) They're
+	//  nil unless the Option.Labels, the Option.KubernetesLabels or
+	//  the Option.IncludeEnvVars of the autopprof is set.
+	Labels map[string]string
+
+	// RetentionHint is the suggested retention of the profile for
+	//  the storage backends, e.g. 90 days for the critical incidents
+	//  and 7 days for the routine captures. Zero means no hint. It's
+	//  set by the WithRetention.
+	RetentionHint time.Duration
+
+	// ContentEncoding is the encoding of the profiling data.
+	ContentEncoding ContentEncoding
+}
+
+// FunctionStat is the statistics of a function in the profile.
+type FunctionStat struct {
+	Name string
+
+	// Flat is the value of the function itself. (e.g. cpu nanoseconds)
+	Flat           int64
+	FlatPercentage float64
+	// Cum is the value of the function and its callees.
+	Cum           int64
+	CumPercentage float64
+}
+
+// NUMANodeStat is the memory usage of the process on a NUMA node.
+type NUMANodeStat struct {
+	Node int
+	// UsageBytes is the memory bytes of the cgroup on the node, and
+	//  TotalBytes is the memory bytes of the node.
+	UsageBytes      uint64
+	TotalBytes      uint64
+	UsagePercentage float64
+}
+
+// formatNUMANodes returns the usages of the nodes as the space-separated
+// N<node>=<usage>% pairs.
+func formatNUMANodes(nodes []NUMANodeStat) string {
+	pairs := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		pairs = append(pairs, fmt.Sprintf("N%d=%.2f%%", n.Node, n.UsagePercentage))
+	}
+	return strings.Join(pairs, " ")
 }
 
 // MemInfo is the memory usage information.
 type MemInfo struct {
+	// SchemaVersion is the SchemaVersion the struct is filled with.
+	SchemaVersion int
+
+	// Trigger is what triggered the heap profile other than the memory
+	//  usage. (e.g. TriggerGC) Empty means the memory usage.
+	Trigger string
+	// Reason is the reason given by the application for the
+	//  TriggerCrash.
+	Reason string
+
 	ThresholdPercentage float64
 	UsagePercentage     float64
+
+	// AvailableBytes is the available memory bytes until the limit.
+	AvailableBytes uint64
+	// MinAvailableBytes is the minimum available memory bytes to
+	//  trigger the heap profiling. Zero means it's disabled.
+	MinAvailableBytes uint64
+
+	// KernelMemoryBytes is the kernel memory (kmem) bytes of the cgroup.
+	//  Zero if the kmem accounting is disabled.
+	KernelMemoryBytes uint64
+
+	// UsageComponents is the memory fields summed into the usage joined
+	//  by the "+", (e.g. rss+swap) and UsageComponentBytes are their
+	//  bytes by the name. They're empty for the working set, which is
+	//  the default of the Option.MemUsageComponents of the autopprof.
+	UsageComponents     string
+	UsageComponentBytes map[string]uint64
+
+	// CPUQuotaCores and MemLimitBytes are the limits of the cgroup in
+	//  effect at the capture. (See CPUInfo.CPUQuotaCores)
+	CPUQuotaCores float64
+	MemLimitBytes uint64
+
+	// CgroupUsagePercentage and GoMemLimitUsagePercentage are the memory
+	//  usages against the memory limit of the cgroup and the GOMEMLIMIT.
+	//  The UsagePercentage is one of them depending on the MemLimitMode
+	//  option. They're zero if the GOMEMLIMIT isn't set.
+	CgroupUsagePercentage     float64
+	GoMemLimitUsagePercentage float64
+	// GoMemLimitBytes is the GOMEMLIMIT. Zero means it's not set.
+	GoMemLimitBytes uint64
+
+	// GCPerSecond and GCPauseP99 are the gc cycles per second and
+	//  the 99th percentile of the gc pauses for the TriggerGC, with
+	//  their thresholds.
+	GCPerSecond      float64
+	GCPauseP99       time.Duration
+	GCRateThreshold  float64
+	GCPauseThreshold time.Duration
+
+	// SampleType is the default sample type of the heap profile.
+	//  (e.g. inuse_space) It's set by the full heap capture which
+	//  reports a profile per view. Empty means the default view.
+	SampleType string
+	// PrimarySampleType is the sample type the backend should show by
+	//  default, (e.g. alloc_space for the allocation-heavy services)
+	//  while the heap profile has all of the sample types.
+	//  It's the Option.HeapPrimarySampleType of the autopprof, or
+	//  the inuse_space if it's not set. With the views, the one of
+	//  the same SampleType is the primary one.
+	PrimarySampleType string
+
+	// NUMANodes are the memory usages per NUMA node, and
+	//  NUMAThresholdPercentage is their threshold. They're empty unless
+	//  the Option.NUMAThreshold of the autopprof is set.
+	NUMANodes               []NUMANodeStat
+	NUMAThresholdPercentage float64
+
+	// RequestBytes is the memory request of the container, and
+	//  RequestUsagePercentage and RequestThresholdPercentage are
+	//  the memory usage against it and its threshold. The pod over its
+	//  request is evicted first under the memory pressure of the node.
+	//  They're zero unless the Option.MemRequestBytes of the autopprof
+	//  is set.
+	RequestBytes               uint64
+	RequestUsagePercentage     float64
+	RequestThresholdPercentage float64
+
+	// AllocBytesSinceLastReport is the bytes allocated since the last
+	//  heap report, or since the start for the first one, by
+	//  the runtime.MemStats.TotalAlloc. The high churn with the stable
+	//  heap is the gc pressure, while the low churn with the growing
+	//  heap is the leak. Zero for the heap profiles not triggered by
+	//  the memory usage.
+	AllocBytesSinceLastReport uint64
+
+	// TriggerID is shared by the profiles reported by the same trigger.
+	//  Empty means the profile is reported alone.
+	TriggerID string
+	// IncidentID is shared by the profiles of the same incident.
+	//  (See CPUInfo.IncidentID)
+	IncidentID string
+
+	// Sequence and Elapsed order the profiles. (See CPUInfo.Sequence)
+	Sequence uint64
+	Elapsed  time.Duration
+
+	// Labels are the labels of the process. (See CPUInfo.Labels)
+	Labels map[string]string
+
+	// RetentionHint is the suggested retention of the profile.
+	//  (See CPUInfo.RetentionHint)
+	RetentionHint time.Duration
+
+	// ContentEncoding is the encoding of the profiling data.
+	ContentEncoding ContentEncoding
+}
+
+// ProfileInfo is the information about the profile reported by
+// the ReportProfile.
+type ProfileInfo struct {
+	// SchemaVersion is the SchemaVersion the struct is filled with.
+	SchemaVersion int
+
+	// Name is the name of the profile of the pprof.Lookup, or "cpu"
+	//  for the cpu diff.
+	Name string
+	// Delta means the profile is the difference of the cumulative
+	//  profile (e.g. the allocs) since the last report instead of
+	//  the cumulative one since the start. For the cpu profile, it's
+	//  the difference from the last cpu profile.
+	Delta bool
+	// TriggerID is shared with the other profiles reported by the same
+	//  trigger. (See MemInfo.TriggerID)
+	TriggerID string
+	// BaseTriggerID is the TriggerID of the last cpu profile the cpu
+	//  diff is relative to. It's empty for the other profiles.
+	BaseTriggerID string
+
+	// Sequence and Elapsed order the profiles. (See CPUInfo.Sequence)
+	Sequence uint64
+	Elapsed  time.Duration
+
+	// Labels are the labels of the process. (See CPUInfo.Labels)
+	Labels map[string]string
+
+	// RetentionHint is the suggested retention of the profile.
+	//  (See CPUInfo.RetentionHint)
+	RetentionHint time.Duration
+
+	// ContentEncoding is the encoding of the profiling data.
+	ContentEncoding ContentEncoding
+}
+
+// GoroutineInfo is the information about what triggered the goroutine profile.
+type GoroutineInfo struct {
+	// SchemaVersion is the SchemaVersion the struct is filled with.
+	SchemaVersion int
+
+	// Trigger is what triggered the goroutine profile. (e.g. TriggerFD)
+	Trigger string
+	// Reason is the reason given by the application for the
+	//  TriggerCrash.
+	Reason string
+	// TriggerID is shared with the other profiles reported by the same
+	//  trigger. (See MemInfo.TriggerID)
+	TriggerID string
+	// IncidentID is shared by the profiles of the same incident.
+	//  (See CPUInfo.IncidentID)
+	IncidentID string
+
+	// ErrorRate and ErrorRateThreshold are the errors per second for
+	//  the TriggerErrorRate. (See CPUInfo.ErrorRate)
+	ErrorRate          float64
+	ErrorRateThreshold float64
+	// Dump reports whether the data is the human-readable goroutine
+	//  dump (debug=2) instead of the pprof protobuf.
+	Dump bool
+
+	// ThresholdPercentage and UsagePercentage are the ratios of
+	//  the goroutines gone for the TriggerGoroutineDrop, the file
+	//  descriptors for the TriggerFD, and the tasks for the TriggerPID.
+	ThresholdPercentage float64
+	UsagePercentage     float64
+
+	// Goroutines and GoroutineThreshold are the number of
+	//  the goroutines and its threshold for the TriggerGoroutine.
+	Goroutines         int
+	GoroutineThreshold int
+
+	// PIDs and PIDLimit are the number of the tasks (threads) of
+	//  the cgroup and its pids limit for the TriggerPID.
+	PIDs     uint64
+	PIDLimit uint64
+
+	// GoroutinesBefore and GoroutinesAfter are the numbers of
+	//  the goroutines at the previous and the current watches for
+	//  the TriggerGoroutineDrop.
+	GoroutinesBefore int
+	GoroutinesAfter  int
+
+	// CPUQuotaCores and MemLimitBytes are the limits of the cgroup in
+	//  effect at the capture. (See CPUInfo.CPUQuotaCores)
+	CPUQuotaCores float64
+	MemLimitBytes uint64
+
+	// Sequence and Elapsed order the profiles. (See CPUInfo.Sequence)
+	Sequence uint64
+	Elapsed  time.Duration
+
+	// Labels are the labels of the process. (See CPUInfo.Labels)
+	Labels map[string]string
+
+	// RetentionHint is the suggested retention of the profile.
+	//  (See CPUInfo.RetentionHint)
+	RetentionHint time.Duration
+
+	// ContentEncoding is the encoding of the profiling data.
+	ContentEncoding ContentEncoding
 }
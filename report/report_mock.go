@@ -8,6 +8,7 @@ import (
 	context "context"
 	io "io"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -49,6 +50,20 @@ func (mr *MockReporterMockRecorder) ReportCPUProfile(ctx, r, ci interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportCPUProfile", reflect.TypeOf((*MockReporter)(nil).ReportCPUProfile), ctx, r, ci)
 }
 
+// ReportGoroutineProfile mocks base method.
+func (m *MockReporter) ReportGoroutineProfile(ctx context.Context, r io.Reader, gi GoroutineInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportGoroutineProfile", ctx, r, gi)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportGoroutineProfile indicates an expected call of ReportGoroutineProfile.
+func (mr *MockReporterMockRecorder) ReportGoroutineProfile(ctx, r, gi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportGoroutineProfile", reflect.TypeOf((*MockReporter)(nil).ReportGoroutineProfile), ctx, r, gi)
+}
+
 // ReportHeapProfile mocks base method.
 func (m *MockReporter) ReportHeapProfile(ctx context.Context, r io.Reader, mi MemInfo) error {
 	m.ctrl.T.Helper()
@@ -62,3 +77,435 @@ func (mr *MockReporterMockRecorder) ReportHeapProfile(ctx, r, mi interface{}) *g
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportHeapProfile", reflect.TypeOf((*MockReporter)(nil).ReportHeapProfile), ctx, r, mi)
 }
+
+// MockStreamReporter is a mock of StreamReporter interface.
+type MockStreamReporter struct {
+	ctrl     *gomock.Controller
+	recorder *MockStreamReporterMockRecorder
+}
+
+// MockStreamReporterMockRecorder is the mock recorder for MockStreamReporter.
+type MockStreamReporterMockRecorder struct {
+	mock *MockStreamReporter
+}
+
+// NewMockStreamReporter creates a new mock instance.
+func NewMockStreamReporter(ctrl *gomock.Controller) *MockStreamReporter {
+	mock := &MockStreamReporter{ctrl: ctrl}
+	mock.recorder = &MockStreamReporterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStreamReporter) EXPECT() *MockStreamReporterMockRecorder {
+	return m.recorder
+}
+
+// CanStream mocks base method.
+func (m *MockStreamReporter) CanStream() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CanStream")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// CanStream indicates an expected call of CanStream.
+func (mr *MockStreamReporterMockRecorder) CanStream() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CanStream", reflect.TypeOf((*MockStreamReporter)(nil).CanStream))
+}
+
+// ReportCPUProfile mocks base method.
+func (m *MockStreamReporter) ReportCPUProfile(ctx context.Context, r io.Reader, ci CPUInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportCPUProfile", ctx, r, ci)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportCPUProfile indicates an expected call of ReportCPUProfile.
+func (mr *MockStreamReporterMockRecorder) ReportCPUProfile(ctx, r, ci interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportCPUProfile", reflect.TypeOf((*MockStreamReporter)(nil).ReportCPUProfile), ctx, r, ci)
+}
+
+// ReportGoroutineProfile mocks base method.
+func (m *MockStreamReporter) ReportGoroutineProfile(ctx context.Context, r io.Reader, gi GoroutineInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportGoroutineProfile", ctx, r, gi)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportGoroutineProfile indicates an expected call of ReportGoroutineProfile.
+func (mr *MockStreamReporterMockRecorder) ReportGoroutineProfile(ctx, r, gi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportGoroutineProfile", reflect.TypeOf((*MockStreamReporter)(nil).ReportGoroutineProfile), ctx, r, gi)
+}
+
+// ReportHeapProfile mocks base method.
+func (m *MockStreamReporter) ReportHeapProfile(ctx context.Context, r io.Reader, mi MemInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportHeapProfile", ctx, r, mi)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportHeapProfile indicates an expected call of ReportHeapProfile.
+func (mr *MockStreamReporterMockRecorder) ReportHeapProfile(ctx, r, mi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportHeapProfile", reflect.TypeOf((*MockStreamReporter)(nil).ReportHeapProfile), ctx, r, mi)
+}
+
+// MockTimeoutReporter is a mock of TimeoutReporter interface.
+type MockTimeoutReporter struct {
+	ctrl     *gomock.Controller
+	recorder *MockTimeoutReporterMockRecorder
+}
+
+// MockTimeoutReporterMockRecorder is the mock recorder for MockTimeoutReporter.
+type MockTimeoutReporterMockRecorder struct {
+	mock *MockTimeoutReporter
+}
+
+// NewMockTimeoutReporter creates a new mock instance.
+func NewMockTimeoutReporter(ctrl *gomock.Controller) *MockTimeoutReporter {
+	mock := &MockTimeoutReporter{ctrl: ctrl}
+	mock.recorder = &MockTimeoutReporterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTimeoutReporter) EXPECT() *MockTimeoutReporterMockRecorder {
+	return m.recorder
+}
+
+// ReportCPUProfile mocks base method.
+func (m *MockTimeoutReporter) ReportCPUProfile(ctx context.Context, r io.Reader, ci CPUInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportCPUProfile", ctx, r, ci)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportCPUProfile indicates an expected call of ReportCPUProfile.
+func (mr *MockTimeoutReporterMockRecorder) ReportCPUProfile(ctx, r, ci interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportCPUProfile", reflect.TypeOf((*MockTimeoutReporter)(nil).ReportCPUProfile), ctx, r, ci)
+}
+
+// ReportGoroutineProfile mocks base method.
+func (m *MockTimeoutReporter) ReportGoroutineProfile(ctx context.Context, r io.Reader, gi GoroutineInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportGoroutineProfile", ctx, r, gi)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportGoroutineProfile indicates an expected call of ReportGoroutineProfile.
+func (mr *MockTimeoutReporterMockRecorder) ReportGoroutineProfile(ctx, r, gi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportGoroutineProfile", reflect.TypeOf((*MockTimeoutReporter)(nil).ReportGoroutineProfile), ctx, r, gi)
+}
+
+// ReportHeapProfile mocks base method.
+func (m *MockTimeoutReporter) ReportHeapProfile(ctx context.Context, r io.Reader, mi MemInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportHeapProfile", ctx, r, mi)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportHeapProfile indicates an expected call of ReportHeapProfile.
+func (mr *MockTimeoutReporterMockRecorder) ReportHeapProfile(ctx, r, mi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportHeapProfile", reflect.TypeOf((*MockTimeoutReporter)(nil).ReportHeapProfile), ctx, r, mi)
+}
+
+// Timeout mocks base method.
+func (m *MockTimeoutReporter) Timeout() time.Duration {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Timeout")
+	ret0, _ := ret[0].(time.Duration)
+	return ret0
+}
+
+// Timeout indicates an expected call of Timeout.
+func (mr *MockTimeoutReporterMockRecorder) Timeout() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Timeout", reflect.TypeOf((*MockTimeoutReporter)(nil).Timeout))
+}
+
+// MockPingReporter is a mock of PingReporter interface.
+type MockPingReporter struct {
+	ctrl     *gomock.Controller
+	recorder *MockPingReporterMockRecorder
+}
+
+// MockPingReporterMockRecorder is the mock recorder for MockPingReporter.
+type MockPingReporterMockRecorder struct {
+	mock *MockPingReporter
+}
+
+// NewMockPingReporter creates a new mock instance.
+func NewMockPingReporter(ctrl *gomock.Controller) *MockPingReporter {
+	mock := &MockPingReporter{ctrl: ctrl}
+	mock.recorder = &MockPingReporterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPingReporter) EXPECT() *MockPingReporterMockRecorder {
+	return m.recorder
+}
+
+// Ping mocks base method.
+func (m *MockPingReporter) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockPingReporterMockRecorder) Ping(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockPingReporter)(nil).Ping), ctx)
+}
+
+// ReportCPUProfile mocks base method.
+func (m *MockPingReporter) ReportCPUProfile(ctx context.Context, r io.Reader, ci CPUInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportCPUProfile", ctx, r, ci)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportCPUProfile indicates an expected call of ReportCPUProfile.
+func (mr *MockPingReporterMockRecorder) ReportCPUProfile(ctx, r, ci interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportCPUProfile", reflect.TypeOf((*MockPingReporter)(nil).ReportCPUProfile), ctx, r, ci)
+}
+
+// ReportGoroutineProfile mocks base method.
+func (m *MockPingReporter) ReportGoroutineProfile(ctx context.Context, r io.Reader, gi GoroutineInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportGoroutineProfile", ctx, r, gi)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportGoroutineProfile indicates an expected call of ReportGoroutineProfile.
+func (mr *MockPingReporterMockRecorder) ReportGoroutineProfile(ctx, r, gi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportGoroutineProfile", reflect.TypeOf((*MockPingReporter)(nil).ReportGoroutineProfile), ctx, r, gi)
+}
+
+// ReportHeapProfile mocks base method.
+func (m *MockPingReporter) ReportHeapProfile(ctx context.Context, r io.Reader, mi MemInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportHeapProfile", ctx, r, mi)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportHeapProfile indicates an expected call of ReportHeapProfile.
+func (mr *MockPingReporterMockRecorder) ReportHeapProfile(ctx, r, mi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportHeapProfile", reflect.TypeOf((*MockPingReporter)(nil).ReportHeapProfile), ctx, r, mi)
+}
+
+// MockProfileReporter is a mock of ProfileReporter interface.
+type MockProfileReporter struct {
+	ctrl     *gomock.Controller
+	recorder *MockProfileReporterMockRecorder
+}
+
+// MockProfileReporterMockRecorder is the mock recorder for MockProfileReporter.
+type MockProfileReporterMockRecorder struct {
+	mock *MockProfileReporter
+}
+
+// NewMockProfileReporter creates a new mock instance.
+func NewMockProfileReporter(ctrl *gomock.Controller) *MockProfileReporter {
+	mock := &MockProfileReporter{ctrl: ctrl}
+	mock.recorder = &MockProfileReporterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProfileReporter) EXPECT() *MockProfileReporterMockRecorder {
+	return m.recorder
+}
+
+// ReportCPUProfile mocks base method.
+func (m *MockProfileReporter) ReportCPUProfile(ctx context.Context, r io.Reader, ci CPUInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportCPUProfile", ctx, r, ci)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportCPUProfile indicates an expected call of ReportCPUProfile.
+func (mr *MockProfileReporterMockRecorder) ReportCPUProfile(ctx, r, ci interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportCPUProfile", reflect.TypeOf((*MockProfileReporter)(nil).ReportCPUProfile), ctx, r, ci)
+}
+
+// ReportGoroutineProfile mocks base method.
+func (m *MockProfileReporter) ReportGoroutineProfile(ctx context.Context, r io.Reader, gi GoroutineInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportGoroutineProfile", ctx, r, gi)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportGoroutineProfile indicates an expected call of ReportGoroutineProfile.
+func (mr *MockProfileReporterMockRecorder) ReportGoroutineProfile(ctx, r, gi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportGoroutineProfile", reflect.TypeOf((*MockProfileReporter)(nil).ReportGoroutineProfile), ctx, r, gi)
+}
+
+// ReportHeapProfile mocks base method.
+func (m *MockProfileReporter) ReportHeapProfile(ctx context.Context, r io.Reader, mi MemInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportHeapProfile", ctx, r, mi)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportHeapProfile indicates an expected call of ReportHeapProfile.
+func (mr *MockProfileReporterMockRecorder) ReportHeapProfile(ctx, r, mi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportHeapProfile", reflect.TypeOf((*MockProfileReporter)(nil).ReportHeapProfile), ctx, r, mi)
+}
+
+// ReportProfile mocks base method.
+func (m *MockProfileReporter) ReportProfile(ctx context.Context, r io.Reader, kind ProfileKind, pi ProfileInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportProfile", ctx, r, kind, pi)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportProfile indicates an expected call of ReportProfile.
+func (mr *MockProfileReporterMockRecorder) ReportProfile(ctx, r, kind, pi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportProfile", reflect.TypeOf((*MockProfileReporter)(nil).ReportProfile), ctx, r, kind, pi)
+}
+
+// MockBundleReporter is a mock of BundleReporter interface.
+type MockBundleReporter struct {
+	ctrl     *gomock.Controller
+	recorder *MockBundleReporterMockRecorder
+}
+
+// MockBundleReporterMockRecorder is the mock recorder for MockBundleReporter.
+type MockBundleReporterMockRecorder struct {
+	mock *MockBundleReporter
+}
+
+// NewMockBundleReporter creates a new mock instance.
+func NewMockBundleReporter(ctrl *gomock.Controller) *MockBundleReporter {
+	mock := &MockBundleReporter{ctrl: ctrl}
+	mock.recorder = &MockBundleReporterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBundleReporter) EXPECT() *MockBundleReporterMockRecorder {
+	return m.recorder
+}
+
+// ReportBundle mocks base method.
+func (m *MockBundleReporter) ReportBundle(ctx context.Context, r io.Reader, bi BundleInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportBundle", ctx, r, bi)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportBundle indicates an expected call of ReportBundle.
+func (mr *MockBundleReporterMockRecorder) ReportBundle(ctx, r, bi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportBundle", reflect.TypeOf((*MockBundleReporter)(nil).ReportBundle), ctx, r, bi)
+}
+
+// ReportCPUProfile mocks base method.
+func (m *MockBundleReporter) ReportCPUProfile(ctx context.Context, r io.Reader, ci CPUInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportCPUProfile", ctx, r, ci)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportCPUProfile indicates an expected call of ReportCPUProfile.
+func (mr *MockBundleReporterMockRecorder) ReportCPUProfile(ctx, r, ci interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportCPUProfile", reflect.TypeOf((*MockBundleReporter)(nil).ReportCPUProfile), ctx, r, ci)
+}
+
+// ReportGoroutineProfile mocks base method.
+func (m *MockBundleReporter) ReportGoroutineProfile(ctx context.Context, r io.Reader, gi GoroutineInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportGoroutineProfile", ctx, r, gi)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportGoroutineProfile indicates an expected call of ReportGoroutineProfile.
+func (mr *MockBundleReporterMockRecorder) ReportGoroutineProfile(ctx, r, gi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportGoroutineProfile", reflect.TypeOf((*MockBundleReporter)(nil).ReportGoroutineProfile), ctx, r, gi)
+}
+
+// ReportHeapProfile mocks base method.
+func (m *MockBundleReporter) ReportHeapProfile(ctx context.Context, r io.Reader, mi MemInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportHeapProfile", ctx, r, mi)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportHeapProfile indicates an expected call of ReportHeapProfile.
+func (mr *MockBundleReporterMockRecorder) ReportHeapProfile(ctx, r, mi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportHeapProfile", reflect.TypeOf((*MockBundleReporter)(nil).ReportHeapProfile), ctx, r, mi)
+}
+
+// MockFlusher is a mock of Flusher interface.
+type MockFlusher struct {
+	ctrl     *gomock.Controller
+	recorder *MockFlusherMockRecorder
+}
+
+// MockFlusherMockRecorder is the mock recorder for MockFlusher.
+type MockFlusherMockRecorder struct {
+	mock *MockFlusher
+}
+
+// NewMockFlusher creates a new mock instance.
+func NewMockFlusher(ctrl *gomock.Controller) *MockFlusher {
+	mock := &MockFlusher{ctrl: ctrl}
+	mock.recorder = &MockFlusherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFlusher) EXPECT() *MockFlusherMockRecorder {
+	return m.recorder
+}
+
+// Flush mocks base method.
+func (m *MockFlusher) Flush(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Flush", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Flush indicates an expected call of Flush.
+func (mr *MockFlusherMockRecorder) Flush(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Flush", reflect.TypeOf((*MockFlusher)(nil).Flush), ctx)
+}
@@ -0,0 +1,116 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestReportProfile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		kind     ProfileKind
+		delta    bool
+		mockFunc func(*MockReporter)
+		wantErr  error
+	}{
+		{
+			name: "cpu",
+			kind: ProfileKindCPU,
+			mockFunc: func(m *MockReporter) {
+				m.EXPECT().
+					ReportCPUProfile(gomock.Any(), gomock.Any(), CPUInfo{
+						SchemaVersion: SchemaVersion,
+						TriggerID:     "id",
+					}).
+					Return(nil)
+			},
+		},
+		{
+			name:     "cpu diff",
+			kind:     ProfileKindCPU,
+			delta:    true,
+			mockFunc: func(m *MockReporter) {},
+			wantErr:  ErrUnsupportedProfileKind,
+		},
+		{
+			name: "heap",
+			kind: ProfileKindHeap,
+			mockFunc: func(m *MockReporter) {
+				m.EXPECT().
+					ReportHeapProfile(gomock.Any(), gomock.Any(), MemInfo{
+						SchemaVersion: SchemaVersion,
+						TriggerID:     "id",
+					}).
+					Return(nil)
+			},
+		},
+		{
+			name: "goroutine",
+			kind: ProfileKindGoroutine,
+			mockFunc: func(m *MockReporter) {
+				m.EXPECT().
+					ReportGoroutineProfile(gomock.Any(), gomock.Any(), GoroutineInfo{
+						SchemaVersion: SchemaVersion,
+						TriggerID:     "id",
+					}).
+					Return(nil)
+			},
+		},
+		{
+			name:     "threadcreate",
+			kind:     ProfileKindThreadCreate,
+			mockFunc: func(m *MockReporter) {},
+			wantErr:  ErrUnsupportedProfileKind,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			mockReporter := NewMockReporter(ctrl)
+			tc.mockFunc(mockReporter)
+
+			pi := ProfileInfo{
+				SchemaVersion: SchemaVersion,
+				Name:          tc.kind.LookupName(),
+				Delta:         tc.delta,
+				TriggerID:     "id",
+			}
+			err := ReportProfile(context.Background(), mockReporter, strings.NewReader("prof"), tc.kind, pi)
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("ReportProfile() = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestProfileKind_LookupName(t *testing.T) {
+	testCases := []struct {
+		kind ProfileKind
+		want string
+	}{
+		{kind: ProfileKindCPU, want: ""},
+		{kind: ProfileKindHeap, want: "heap"},
+		{kind: ProfileKindThreadCreate, want: "threadcreate"},
+		{kind: ProfileKindLiveness, want: ""},
+		{kind: ProfileKindWallClock, want: ""},
+	}
+	for _, tc := range testCases {
+		if got := tc.kind.LookupName(); got != tc.want {
+			t.Errorf("%s.LookupName() = %q, want %q", tc.kind, got, tc.want)
+		}
+	}
+}
+
+func TestReportTime(t *testing.T) {
+	if got := reportTime(0); strings.Count(got, ".") != 1 {
+		t.Errorf("reportTime(0) = %q, want no sequence suffix", got)
+	}
+	if got := reportTime(42); !strings.HasSuffix(got, ".0000000042") {
+		t.Errorf("reportTime(42) = %q, want the suffix .0000000042", got)
+	}
+}
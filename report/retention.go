@@ -0,0 +1,106 @@
+package report
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// RetentionPolicy is the suggested retention of the profiles by their
+// severity. (See SeverityOf) Zero means no hint for the severity.
+type RetentionPolicy struct {
+	Low      time.Duration
+	High     time.Duration
+	Critical time.Duration
+}
+
+// of returns the retention of the severity.
+func (p RetentionPolicy) of(severity Severity) time.Duration {
+	switch severity {
+	case SeverityCritical:
+		return p.Critical
+	case SeverityHigh:
+		return p.High
+	}
+	return p.Low
+}
+
+// RetentionReporter sets the RetentionHint of the reports by their
+// severity before sending them to the inner reporter, so the storage
+// backends manage the lifecycle of the profiles by the severity, e.g.
+// keep the critical incidents 90 days and the routine captures 7 days.
+// The HTTPReporter sends the hint in the HTTPRetentionHeader.
+type RetentionReporter struct {
+	inner  Reporter
+	policy RetentionPolicy
+}
+
+// WithRetention returns the RetentionReporter wrapping the inner
+// reporter with the policy.
+func WithRetention(inner Reporter, policy RetentionPolicy) *RetentionReporter {
+	return &RetentionReporter{
+		inner:  inner,
+		policy: policy,
+	}
+}
+
+// Timeout returns the timeout of the inner reporter if it's
+// a TimeoutReporter. Otherwise, it returns zero.
+func (rr *RetentionReporter) Timeout() time.Duration {
+	if tr, ok := rr.inner.(TimeoutReporter); ok {
+		return tr.Timeout()
+	}
+	return 0
+}
+
+// Ping checks the inner reporter if it's a PingReporter.
+func (rr *RetentionReporter) Ping(ctx context.Context) error {
+	if pr, ok := rr.inner.(PingReporter); ok {
+		return pr.Ping(ctx)
+	}
+	return nil
+}
+
+// Flush flushes the inner reporter if it's a Flusher.
+func (rr *RetentionReporter) Flush(ctx context.Context) error {
+	if f, ok := rr.inner.(Flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+// ReportCPUProfile sends the CPU profiling data with the retention hint
+// to the inner reporter.
+func (rr *RetentionReporter) ReportCPUProfile(
+	ctx context.Context, r io.Reader, ci CPUInfo,
+) error {
+	ci.RetentionHint = rr.policy.of(SeverityOf(ProfileKindCPU, ci))
+	return rr.inner.ReportCPUProfile(ctx, r, ci)
+}
+
+// ReportHeapProfile sends the heap profiling data with the retention
+// hint to the inner reporter.
+func (rr *RetentionReporter) ReportHeapProfile(
+	ctx context.Context, r io.Reader, mi MemInfo,
+) error {
+	mi.RetentionHint = rr.policy.of(SeverityOf(ProfileKindHeap, mi))
+	return rr.inner.ReportHeapProfile(ctx, r, mi)
+}
+
+// ReportGoroutineProfile sends the goroutine profiling data with
+// the retention hint to the inner reporter.
+func (rr *RetentionReporter) ReportGoroutineProfile(
+	ctx context.Context, r io.Reader, gi GoroutineInfo,
+) error {
+	gi.RetentionHint = rr.policy.of(SeverityOf(ProfileKindGoroutine, gi))
+	return rr.inner.ReportGoroutineProfile(ctx, r, gi)
+}
+
+// ReportProfile sends the profiling data of the kind with the retention
+// hint to the inner reporter. (See the ReportProfile function)
+func (rr *RetentionReporter) ReportProfile(
+	ctx context.Context, r io.Reader, kind ProfileKind, pi ProfileInfo,
+) error {
+	pi.RetentionHint = rr.policy.of(SeverityOf(kind, pi))
+	return ReportProfile(ctx, rr.inner, r, kind, pi)
+}
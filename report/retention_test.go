@@ -0,0 +1,90 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestRetentionReporter(t *testing.T) {
+	policy := RetentionPolicy{
+		Low:      7 * 24 * time.Hour,
+		High:     30 * 24 * time.Hour,
+		Critical: 90 * 24 * time.Hour,
+	}
+	testCases := []struct {
+		name string
+		ci   CPUInfo
+		want time.Duration
+	}{
+		{
+			name: "low",
+			ci:   CPUInfo{UsagePercentage: 60, ThresholdPercentage: 75},
+			want: policy.Low,
+		},
+		{
+			name: "high",
+			ci:   CPUInfo{UsagePercentage: 80, ThresholdPercentage: 75},
+			want: policy.High,
+		},
+		{
+			name: "critical",
+			ci:   CPUInfo{UsagePercentage: 95, ThresholdPercentage: 75},
+			want: policy.Critical,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			mockReporter := NewMockReporter(ctrl)
+			mockReporter.EXPECT().
+				ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(
+					func(_ context.Context, _ io.Reader, ci CPUInfo) error {
+						if ci.RetentionHint != tc.want {
+							t.Errorf("RetentionHint = %s, want %s", ci.RetentionHint, tc.want)
+						}
+						return nil
+					},
+				)
+
+			rr := WithRetention(mockReporter, policy)
+			if err := rr.ReportCPUProfile(
+				context.Background(), bytes.NewReader([]byte("prof")), tc.ci,
+			); err != nil {
+				t.Fatalf("ReportCPUProfile() = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestRetentionReporter_ReportProfile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	// The named profiles are low, and reach the reporter which isn't
+	//  a ProfileReporter with the hint.
+	mockReporter := NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportGoroutineProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, gi GoroutineInfo) error {
+				if gi.RetentionHint != time.Hour {
+					t.Errorf("RetentionHint = %s, want %s", gi.RetentionHint, time.Hour)
+				}
+				return nil
+			},
+		)
+
+	rr := WithRetention(mockReporter, RetentionPolicy{Low: time.Hour})
+	if err := rr.ReportProfile(
+		context.Background(), bytes.NewReader([]byte("prof")),
+		ProfileKindGoroutine, ProfileInfo{},
+	); err != nil {
+		t.Fatalf("ReportProfile() = %v, want nil", err)
+	}
+}
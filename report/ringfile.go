@@ -0,0 +1,201 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RingFileReporter writes the profiles to a fixed ring of the files per
+// kind in the directory, (e.g. heap-0.pprof..heap-4.pprof) overwriting
+// the oldest one on each report. So the most recent profiles are on
+// the local disk after a crash regardless of the uploads, and the disk
+// usage is bounded by the slots. Wrap the uploading reporter and it by
+// the NewMultiReporter to keep both.
+// The different payloads of a kind have their own rings, named like
+// the files of the FileReporter: the views of the heap profile by
+// the sample type, (e.g. heap-inuse_space-0.pprof) the goroutine dump,
+// (goroutine-dump-0.txt) the diffs (e.g. cpu-delta-0.pprof) and
+// the encodings. (e.g. cpu-0.pprof.zst)
+//
+// Each file is written to a temporary file and renamed over the slot,
+// so a crash in the middle of the write leaves the previous profile of
+// the slot intact instead of a truncated one. The ring resumes after
+// the newest file at the start, so the profiles before the restart
+// aren't overwritten first.
+type RingFileReporter struct {
+	dir   string
+	slots int
+
+	mu sync.Mutex
+	// next is the next slot per ring.
+	next map[ring]int
+}
+
+// ring is the files of the same payload, e.g. the heap profile of
+// a sample type, named <name>-<slot><ext>.
+type ring struct {
+	name string
+	ext  string
+}
+
+// profileRing returns the ring of the profile of the name in
+// the encoding.
+func profileRing(name string, encoding ContentEncoding) ring {
+	return ring{name: name, ext: ".pprof" + encoding.Suffix()}
+}
+
+// NewRingFileReporter returns the RingFileReporter writing to
+// the slots files per kind in the dir. The dir is created if it
+// doesn't exist.
+func NewRingFileReporter(dir string, slots int) (*RingFileReporter, error) {
+	if slots <= 0 {
+		return nil, fmt.Errorf("autopprof: ring file slots must be positive: %d", slots)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("autopprof: failed to create the ring file directory: %w", err)
+	}
+	return &RingFileReporter{
+		dir:   dir,
+		slots: slots,
+		next:  make(map[ring]int),
+	}, nil
+}
+
+// ReportCPUProfile writes the CPU profiling data to the next slot.
+func (rf *RingFileReporter) ReportCPUProfile(
+	_ context.Context, r io.Reader, ci CPUInfo,
+) error {
+	return rf.write(profileRing(string(ProfileKindCPU), ci.ContentEncoding), r)
+}
+
+// ReportHeapProfile writes the heap profiling data to the next slot.
+func (rf *RingFileReporter) ReportHeapProfile(
+	_ context.Context, r io.Reader, mi MemInfo,
+) error {
+	name := string(ProfileKindHeap)
+	if mi.SampleType != "" {
+		name += "-" + mi.SampleType
+	}
+	return rf.write(profileRing(name, mi.ContentEncoding), r)
+}
+
+// ReportGoroutineProfile writes the goroutine profiling data to
+// the next slot. The dump is written to its own ring as a text.
+func (rf *RingFileReporter) ReportGoroutineProfile(
+	_ context.Context, r io.Reader, gi GoroutineInfo,
+) error {
+	rg := profileRing(string(ProfileKindGoroutine), gi.ContentEncoding)
+	if gi.Dump {
+		rg = ring{
+			name: string(ProfileKindGoroutine) + "-dump",
+			ext:  ".txt" + gi.ContentEncoding.Suffix(),
+		}
+	}
+	return rf.write(rg, r)
+}
+
+// ReportProfile writes the profiling data of the kind to the next slot.
+// The liveness marker isn't written, since it has no profiling data.
+func (rf *RingFileReporter) ReportProfile(
+	_ context.Context, r io.Reader, kind ProfileKind, pi ProfileInfo,
+) error {
+	if kind == ProfileKindLiveness {
+		return nil
+	}
+	name := string(kind)
+	if pi.Delta {
+		name += "-delta"
+	}
+	return rf.write(profileRing(name, pi.ContentEncoding), r)
+}
+
+// Path returns the path of the slot of the kind, for the profile
+// without the encoding. (e.g. the cpu profile or the heap profile of
+// the default view)
+func (rf *RingFileReporter) Path(kind ProfileKind, slot int) string {
+	return rf.path(profileRing(string(kind), ContentEncodingNone), slot)
+}
+
+func (rf *RingFileReporter) path(rg ring, slot int) string {
+	return filepath.Join(rf.dir, fmt.Sprintf("%s-%d%s", rg.name, slot, rg.ext))
+}
+
+func (rf *RingFileReporter) write(rg ring, r io.Reader) error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	slot, ok := rf.next[rg]
+	if !ok {
+		slot = rf.resume(rg)
+	}
+	path := rf.path(rg, slot)
+	if err := writeFileAtomic(path, r); err != nil {
+		return fmt.Errorf("autopprof: failed to write the ring file %s: %w", path, err)
+	}
+	rf.next[rg] = (slot + 1) % rf.slots
+	return nil
+}
+
+// resume returns the slot after the newest file of the ring, or
+// the first one if there's none.
+func (rf *RingFileReporter) resume(rg ring) int {
+	var (
+		newest  = -1
+		modTime time.Time
+	)
+	for slot := 0; slot < rf.slots; slot++ {
+		fi, err := os.Stat(rf.path(rg, slot))
+		if err != nil {
+			continue
+		}
+		if newest < 0 || fi.ModTime().After(modTime) {
+			newest, modTime = slot, fi.ModTime()
+		}
+	}
+	return (newest + 1) % rf.slots
+}
+
+// writeFileAtomic writes the r to the temporary file in the directory
+// of the path and renames it to the path, so the path has either
+// the previous content or the whole new one.
+func writeFileAtomic(path string, r io.Reader) error {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	// The rename below makes this a no-op on the success.
+	defer os.Remove(tmp)
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	// Persist the content before the rename, or the crash may leave
+	//  the renamed file empty.
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	// Persist the rename itself.
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readRingFile(t *testing.T, rf *RingFileReporter, kind ProfileKind, slot int) string {
+	t.Helper()
+	b, err := os.ReadFile(rf.Path(kind, slot))
+	if err != nil {
+		t.Fatalf("failed to read the slot %d: %v", slot, err)
+	}
+	return string(b)
+}
+
+func TestRingFileReporter(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "ring")
+	rf, err := NewRingFileReporter(dir, 2)
+	if err != nil {
+		t.Fatalf("NewRingFileReporter() = %v, want nil", err)
+	}
+	ctx := context.Background()
+	for _, p := range []string{"heap1", "heap2", "heap3"} {
+		if err := rf.ReportHeapProfile(ctx, strings.NewReader(p), MemInfo{}); err != nil {
+			t.Fatalf("ReportHeapProfile() = %v, want nil", err)
+		}
+	}
+	if err := rf.ReportCPUProfile(ctx, strings.NewReader("cpu1"), CPUInfo{}); err != nil {
+		t.Fatalf("ReportCPUProfile() = %v, want nil", err)
+	}
+
+	// The third one overwrites the oldest slot.
+	if got := readRingFile(t, rf, ProfileKindHeap, 0); got != "heap3" {
+		t.Errorf("slot 0 = %q, want heap3", got)
+	}
+	if got := readRingFile(t, rf, ProfileKindHeap, 1); got != "heap2" {
+		t.Errorf("slot 1 = %q, want heap2", got)
+	}
+	if got := readRingFile(t, rf, ProfileKindCPU, 0); got != "cpu1" {
+		t.Errorf("cpu slot 0 = %q, want cpu1", got)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("got %d files, want 3 without the temporary ones", len(entries))
+	}
+}
+
+func TestRingFileReporter_resume(t *testing.T) {
+	dir := t.TempDir()
+	rf, err := NewRingFileReporter(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, p := range []string{"heap1", "heap2"} {
+		if err := rf.ReportHeapProfile(ctx, strings.NewReader(p), MemInfo{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Make the slot 1 the newest regardless of the resolution of
+	//  the mtime.
+	now := time.Now()
+	_ = os.Chtimes(rf.Path(ProfileKindHeap, 0), now.Add(-time.Minute), now.Add(-time.Minute))
+
+	// The restarted one doesn't overwrite the newest profile.
+	rf, err = NewRingFileReporter(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rf.ReportHeapProfile(ctx, strings.NewReader("heap3"), MemInfo{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readRingFile(t, rf, ProfileKindHeap, 2); got != "heap3" {
+		t.Errorf("slot 2 = %q, want heap3", got)
+	}
+	if got := readRingFile(t, rf, ProfileKindHeap, 1); got != "heap2" {
+		t.Errorf("slot 1 = %q, want heap2", got)
+	}
+}
+
+func TestRingFileReporter_payloads(t *testing.T) {
+	dir := t.TempDir()
+	rf, err := NewRingFileReporter(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := rf.ReportHeapProfile(ctx, strings.NewReader("inuse"), MemInfo{
+		SampleType: SampleTypeInuseSpace,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rf.ReportHeapProfile(ctx, strings.NewReader("alloc"), MemInfo{
+		SampleType: SampleTypeAllocSpace,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rf.ReportGoroutineProfile(ctx, strings.NewReader("profile"), GoroutineInfo{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rf.ReportGoroutineProfile(ctx, strings.NewReader("dump"), GoroutineInfo{
+		Dump: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rf.ReportCPUProfile(ctx, strings.NewReader("zstd"), CPUInfo{
+		ContentEncoding: ContentEncodingZstd,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rf.ReportProfile(ctx, strings.NewReader("delta"), ProfileKindCPU, ProfileInfo{
+		Delta: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// None of them overwrites the others in the single slot.
+	for name, want := range map[string]string{
+		"heap-inuse_space-0.pprof": "inuse",
+		"heap-alloc_space-0.pprof": "alloc",
+		"goroutine-0.pprof":        "profile",
+		"goroutine-dump-0.txt":     "dump",
+		"cpu-0.pprof.zst":          "zstd",
+		"cpu-delta-0.pprof":        "delta",
+	} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("failed to read %s: %v", name, err)
+			continue
+		}
+		if string(b) != want {
+			t.Errorf("%s = %q, want %q", name, b, want)
+		}
+	}
+}
+
+func TestRingFileReporter_failedCapture(t *testing.T) {
+	rf, err := NewRingFileReporter(t.TempDir(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := rf.ReportHeapProfile(ctx, strings.NewReader("heap1"), MemInfo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	errRead := errors.New("profiling failed")
+	partially := io.MultiReader(strings.NewReader("partial"), errReader{errRead})
+	if err := rf.ReportHeapProfile(ctx, partially, MemInfo{}); !errors.Is(err, errRead) {
+		t.Errorf("ReportHeapProfile() = %v, want %v", err, errRead)
+	}
+	// The previous profile is intact.
+	if got := readRingFile(t, rf, ProfileKindHeap, 0); got != "heap1" {
+		t.Errorf("slot 0 = %q, want heap1", got)
+	}
+}
+
+func TestNewRingFileReporter_invalidSlots(t *testing.T) {
+	if _, err := NewRingFileReporter(t.TempDir(), 0); err == nil {
+		t.Errorf("NewRingFileReporter() = nil, want the error of the slots")
+	}
+}
@@ -0,0 +1,284 @@
+package report
+
+import (
+	"context"
+	"io"
+	"sort"
+	"time"
+)
+
+// Severity is the severity of the report to route it by. (See Router)
+type Severity int
+
+const (
+	// SeverityLow is the report not triggered by the breach of the usage
+	//  threshold. (e.g. the gc pressure, the named captures)
+	SeverityLow Severity = iota
+	// SeverityHigh is the report of the usage over the threshold, or
+	//  the latency breach or the error rate of the application.
+	SeverityHigh
+	// SeverityCritical is the report of the usage over the midpoint
+	//  between the threshold and 100%, (e.g. 87.5% for the 75%
+	//  threshold) or the crash capture.
+	SeverityCritical
+)
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityLow:
+		return "low"
+	case SeverityHigh:
+		return "high"
+	case SeverityCritical:
+		return "critical"
+	}
+	return "unknown"
+}
+
+// SeverityOf returns the severity of the report by its info, which is
+// the CPUInfo, MemInfo or GoroutineInfo depending on the kind, or
+// the ProfileInfo for the ReportProfile. It's the default severity of
+// the Router.
+func SeverityOf(_ ProfileKind, info interface{}) Severity {
+	switch i := info.(type) {
+	case CPUInfo:
+		if i.Trigger == TriggerLatencyBreach || i.Trigger == TriggerErrorRate {
+			return SeverityHigh
+		}
+		return usageSeverity(i.UsagePercentage, i.ThresholdPercentage)
+	case MemInfo:
+		if i.Trigger == TriggerCrash {
+			return SeverityCritical
+		}
+		if i.Trigger != "" {
+			return SeverityLow
+		}
+		return usageSeverity(i.UsagePercentage, i.ThresholdPercentage)
+	case GoroutineInfo:
+		if i.Trigger == TriggerCrash {
+			return SeverityCritical
+		}
+		if i.Trigger == TriggerLatencyBreach || i.Trigger == TriggerErrorRate {
+			return SeverityHigh
+		}
+		return usageSeverity(i.UsagePercentage, i.ThresholdPercentage)
+	}
+	return SeverityLow
+}
+
+func usageSeverity(usage, threshold float64) Severity {
+	if threshold == 0 || usage < threshold {
+		return SeverityLow
+	}
+	if usage >= (threshold+100)/2 {
+		return SeverityCritical
+	}
+	return SeverityHigh
+}
+
+// Router sends the profiling report to the reporters of the route
+// matching its kind and severity, e.g. the critical cpu profiles to
+// the expensive store and the Slack, and the others to the local file.
+// It's built by the RouterBuilder.
+//
+// Among the routes of the kind, the one with the highest minimum
+// severity not above the severity of the report is taken. If no route
+// matches, the report goes to the default reporters, or it's dropped
+// if there are none.
+type Router struct {
+	// routes are the routes by the kind, sorted by the minimum severity
+	//  in descending order.
+	routes   map[ProfileKind][]route
+	fallback Reporter
+	severity func(kind ProfileKind, info interface{}) Severity
+
+	// reporters are all the reporters of the routes. A reporter of
+	//  the multiple routes is in them multiple times.
+	reporters []Reporter
+}
+
+type route struct {
+	minSeverity Severity
+	reporter    Reporter
+}
+
+// RouterBuilder builds the routing table of the Router:
+//
+//	router := report.NewRouterBuilder().
+//		Route(report.ProfileKindCPU, report.SeverityCritical, s3, slack).
+//		Route(report.ProfileKindCPU, report.SeverityLow, file).
+//		Default(file).
+//		Build()
+type RouterBuilder struct {
+	routes    map[ProfileKind][]route
+	fallback  Reporter
+	severity  func(kind ProfileKind, info interface{}) Severity
+	reporters []Reporter
+}
+
+// NewRouterBuilder returns the RouterBuilder of the empty routing table.
+func NewRouterBuilder() *RouterBuilder {
+	return &RouterBuilder{
+		routes:   make(map[ProfileKind][]route),
+		severity: SeverityOf,
+	}
+}
+
+// Route routes the reports of the kind with the minSeverity or higher
+// to the reporters. Multiple reporters receive the report concurrently
+// like the MultiReporter.
+func (b *RouterBuilder) Route(
+	kind ProfileKind, minSeverity Severity, reporters ...Reporter,
+) *RouterBuilder {
+	b.routes[kind] = append(b.routes[kind], route{
+		minSeverity: minSeverity,
+		reporter:    b.combine(reporters),
+	})
+	return b
+}
+
+// Default routes the reports matching no route to the reporters.
+func (b *RouterBuilder) Default(reporters ...Reporter) *RouterBuilder {
+	b.fallback = b.combine(reporters)
+	return b
+}
+
+// Severity replaces the SeverityOf to compute the severity of
+// the reports.
+func (b *RouterBuilder) Severity(
+	fn func(kind ProfileKind, info interface{}) Severity,
+) *RouterBuilder {
+	b.severity = fn
+	return b
+}
+
+// Build returns the Router of the routing table.
+func (b *RouterBuilder) Build() *Router {
+	r := &Router{
+		routes:    make(map[ProfileKind][]route, len(b.routes)),
+		fallback:  b.fallback,
+		severity:  b.severity,
+		reporters: b.reporters,
+	}
+	for kind, routes := range b.routes {
+		sorted := make([]route, len(routes))
+		copy(sorted, routes)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].minSeverity > sorted[j].minSeverity
+		})
+		r.routes[kind] = sorted
+	}
+	return r
+}
+
+// combine returns the reporter sending to all of the reporters.
+func (b *RouterBuilder) combine(reporters []Reporter) Reporter {
+	b.reporters = append(b.reporters, reporters...)
+	if len(reporters) == 1 {
+		return reporters[0]
+	}
+	return NewMultiReporter(reporters...)
+}
+
+// Timeout returns the longest timeout of the reporters which are
+// the TimeoutReporter, so the slowest one isn't cut off.
+func (r *Router) Timeout() time.Duration {
+	var timeout time.Duration
+	for _, rp := range r.reporters {
+		tr, ok := rp.(TimeoutReporter)
+		if !ok {
+			continue
+		}
+		if t := tr.Timeout(); t > timeout {
+			timeout = t
+		}
+	}
+	return timeout
+}
+
+// Ping checks all of the reporters which are the PingReporter.
+func (r *Router) Ping(ctx context.Context) error {
+	for _, rp := range r.reporters {
+		if pr, ok := rp.(PingReporter); ok {
+			if err := pr.Ping(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Flush flushes all of the reporters which are the Flusher. It
+// returns the first error after flushing all of them.
+func (r *Router) Flush(ctx context.Context) error {
+	var firstErr error
+	for _, rp := range r.reporters {
+		if f, ok := rp.(Flusher); ok {
+			if err := f.Flush(ctx); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// ReportCPUProfile sends the CPU profiling data to the reporters of
+// the matching route.
+func (r *Router) ReportCPUProfile(
+	ctx context.Context, pr io.Reader, ci CPUInfo,
+) error {
+	rp := r.route(ProfileKindCPU, ci)
+	if rp == nil {
+		return nil
+	}
+	return rp.ReportCPUProfile(ctx, pr, ci)
+}
+
+// ReportHeapProfile sends the heap profiling data to the reporters of
+// the matching route.
+func (r *Router) ReportHeapProfile(
+	ctx context.Context, pr io.Reader, mi MemInfo,
+) error {
+	rp := r.route(ProfileKindHeap, mi)
+	if rp == nil {
+		return nil
+	}
+	return rp.ReportHeapProfile(ctx, pr, mi)
+}
+
+// ReportGoroutineProfile sends the goroutine profiling data to
+// the reporters of the matching route.
+func (r *Router) ReportGoroutineProfile(
+	ctx context.Context, pr io.Reader, gi GoroutineInfo,
+) error {
+	rp := r.route(ProfileKindGoroutine, gi)
+	if rp == nil {
+		return nil
+	}
+	return rp.ReportGoroutineProfile(ctx, pr, gi)
+}
+
+// ReportProfile sends the profiling data of the kind to the reporters
+// of the matching route. (See the ReportProfile function)
+func (r *Router) ReportProfile(
+	ctx context.Context, pr io.Reader, kind ProfileKind, pi ProfileInfo,
+) error {
+	rp := r.route(kind, pi)
+	if rp == nil {
+		return nil
+	}
+	return ReportProfile(ctx, rp, pr, kind, pi)
+}
+
+// route returns the reporter of the route matching the report.
+// It's nil if no route matches and there's no default.
+func (r *Router) route(kind ProfileKind, info interface{}) Reporter {
+	severity := r.severity(kind, info)
+	for _, rt := range r.routes[kind] {
+		if severity >= rt.minSeverity {
+			return rt.reporter
+		}
+	}
+	return r.fallback
+}
@@ -0,0 +1,155 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestSeverityOf(t *testing.T) {
+	testCases := []struct {
+		name string
+		info interface{}
+		want Severity
+	}{
+		{
+			name: "cpu under the threshold",
+			info: CPUInfo{UsagePercentage: 60, ThresholdPercentage: 75},
+			want: SeverityLow,
+		},
+		{
+			name: "cpu over the threshold",
+			info: CPUInfo{UsagePercentage: 80, ThresholdPercentage: 75},
+			want: SeverityHigh,
+		},
+		{
+			name: "cpu over the midpoint",
+			info: CPUInfo{UsagePercentage: 87.5, ThresholdPercentage: 75},
+			want: SeverityCritical,
+		},
+		{
+			name: "cpu latency breach",
+			info: CPUInfo{Trigger: TriggerLatencyBreach, ThresholdPercentage: 75},
+			want: SeverityHigh,
+		},
+		{
+			name: "cpu error rate",
+			info: CPUInfo{Trigger: TriggerErrorRate, ErrorRate: 5, ErrorRateThreshold: 1},
+			want: SeverityHigh,
+		},
+		{
+			name: "cpu startup",
+			info: CPUInfo{Trigger: TriggerStartup, ThresholdPercentage: 75},
+			want: SeverityLow,
+		},
+		{
+			name: "mem gc trigger",
+			info: MemInfo{Trigger: TriggerGC, UsagePercentage: 90, ThresholdPercentage: 75},
+			want: SeverityLow,
+		},
+		{
+			name: "mem crash",
+			info: MemInfo{Trigger: TriggerCrash},
+			want: SeverityCritical,
+		},
+		{
+			name: "goroutine fd",
+			info: GoroutineInfo{Trigger: TriggerFD, UsagePercentage: 85, ThresholdPercentage: 80},
+			want: SeverityHigh,
+		},
+		{
+			name: "goroutine latency breach",
+			info: GoroutineInfo{Trigger: TriggerLatencyBreach},
+			want: SeverityHigh,
+		},
+		{
+			name: "goroutine error rate",
+			info: GoroutineInfo{Trigger: TriggerErrorRate},
+			want: SeverityHigh,
+		},
+		{
+			name: "named profile",
+			info: ProfileInfo{Name: "mutex"},
+			want: SeverityLow,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SeverityOf(ProfileKindCPU, tc.info); got != tc.want {
+				t.Errorf("SeverityOf() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRouter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var (
+		critical = NewMockReporter(ctrl)
+		slack    = NewMockReporter(ctrl)
+		low      = NewMockReporter(ctrl)
+		fallback = NewMockReporter(ctrl)
+	)
+	// The critical cpu profile goes to both of the critical route.
+	critical.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+	slack.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+	// The high one falls to the low route.
+	low.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+	// The heap profile has no route.
+	fallback.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+
+	router := NewRouterBuilder().
+		Route(ProfileKindCPU, SeverityLow, low).
+		Route(ProfileKindCPU, SeverityCritical, critical, slack).
+		Default(fallback).
+		Build()
+
+	ctx := context.Background()
+	if err := router.ReportCPUProfile(
+		ctx, bytes.NewReader([]byte("prof")),
+		CPUInfo{UsagePercentage: 95, ThresholdPercentage: 75},
+	); err != nil {
+		t.Errorf("ReportCPUProfile() = %v, want nil", err)
+	}
+	if err := router.ReportCPUProfile(
+		ctx, bytes.NewReader([]byte("prof")),
+		CPUInfo{UsagePercentage: 80, ThresholdPercentage: 75},
+	); err != nil {
+		t.Errorf("ReportCPUProfile() = %v, want nil", err)
+	}
+	if err := router.ReportHeapProfile(
+		ctx, bytes.NewReader([]byte("prof")),
+		MemInfo{UsagePercentage: 80, ThresholdPercentage: 75},
+	); err != nil {
+		t.Errorf("ReportHeapProfile() = %v, want nil", err)
+	}
+}
+
+func TestRouter_noRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	// The report of no route is dropped without the default.
+	high := NewMockReporter(ctrl)
+	router := NewRouterBuilder().
+		Route(ProfileKindCPU, SeverityHigh, high).
+		Severity(func(ProfileKind, interface{}) Severity { return SeverityLow }).
+		Build()
+
+	if err := router.ReportCPUProfile(
+		context.Background(), bytes.NewReader([]byte("prof")),
+		CPUInfo{UsagePercentage: 95, ThresholdPercentage: 75},
+	); err != nil {
+		t.Errorf("ReportCPUProfile() = %v, want nil", err)
+	}
+}
@@ -0,0 +1,44 @@
+package report
+
+import (
+	"fmt"
+	"mime"
+	"strconv"
+)
+
+// SchemaVersion is the version of the schema of the CPUInfo, MemInfo
+// and GoroutineInfo, so the consumers can parse the metadata reliably
+// as the structs evolve.
+// Bump it whenever the fields of them are added or changed.
+const SchemaVersion = 22
+
+// SchemaVersionParam is the parameter of the content type to carry
+// the SchemaVersion.
+const SchemaVersionParam = "schema-version"
+
+// ContentType returns the media type with the SchemaVersion parameter.
+// (e.g. application/octet-stream; schema-version=1)
+// The HTTP based reporters should send it as the Content-Type header.
+func ContentType(mediaType string) string {
+	return mime.FormatMediaType(mediaType, map[string]string{
+		SchemaVersionParam: strconv.Itoa(SchemaVersion),
+	})
+}
+
+// ParseSchemaVersion returns the schema version in the content type
+// made by the ContentType. It returns zero if there's no version.
+func ParseSchemaVersion(contentType string) (int, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return 0, fmt.Errorf("autopprof: failed to parse the content type: %w", err)
+	}
+	v, ok := params[SchemaVersionParam]
+	if !ok {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("autopprof: invalid schema version %q: %w", v, err)
+	}
+	return version, nil
+}
@@ -0,0 +1,60 @@
+package report
+
+import (
+	"testing"
+)
+
+func TestContentType(t *testing.T) {
+	ct := ContentType("application/octet-stream")
+	if want := "application/octet-stream; schema-version=22"; ct != want {
+		t.Errorf("ContentType() = %s, want %s", ct, want)
+	}
+	version, err := ParseSchemaVersion(ct)
+	if err != nil {
+		t.Errorf("ParseSchemaVersion() = %v, want nil", err)
+	}
+	if version != SchemaVersion {
+		t.Errorf("ParseSchemaVersion() = %d, want %d", version, SchemaVersion)
+	}
+}
+
+func TestParseSchemaVersion(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contentType string
+		want        int
+		wantErr     bool
+	}{
+		{
+			name:        "no version",
+			contentType: "application/octet-stream",
+			want:        0,
+		},
+		{
+			name:        "version",
+			contentType: "application/zstd; schema-version=3",
+			want:        3,
+		},
+		{
+			name:        "invalid version",
+			contentType: "application/octet-stream; schema-version=v1",
+			wantErr:     true,
+		},
+		{
+			name:        "invalid content type",
+			contentType: "; schema-version=1",
+			wantErr:     true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseSchemaVersion(tc.contentType)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ParseSchemaVersion() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ParseSchemaVersion() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
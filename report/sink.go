@@ -0,0 +1,137 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Sink is the destination of the profiles narrower than the Reporter.
+// It has a single method for all kinds of the profiles, so it doesn't
+// grow as the profile types are added. It's adapted to the Reporter by
+// the NewSinkReporter, and vice versa by the NewReporterSink.
+type Sink interface {
+	// Write sends the profile of the artifact to the specific
+	//  destination.
+	Write(ctx context.Context, a ProfileArtifact) error
+}
+
+// SinkFunc is the function which is the Sink.
+type SinkFunc func(ctx context.Context, a ProfileArtifact) error
+
+// Write calls the f.
+func (f SinkFunc) Write(ctx context.Context, a ProfileArtifact) error {
+	return f(ctx, a)
+}
+
+// ProfileArtifact is the profile written to the Sink.
+type ProfileArtifact struct {
+	// Kind is the kind of the profile.
+	Kind ProfileKind
+	// Reader is the profiling data.
+	Reader io.Reader
+	// Info is the CPUInfo, MemInfo or GoroutineInfo depending on
+	//  the kind, or the ProfileInfo for the other kinds.
+	Info interface{}
+
+	// Labels and TriggerID are the ones of the Info, so the sinks
+	//  don't have to switch on its type for them.
+	Labels    map[string]string
+	TriggerID string
+}
+
+// SinkReporter is the Reporter writing the reports to the Sink.
+type SinkReporter struct {
+	sink Sink
+}
+
+// NewSinkReporter returns the SinkReporter writing to the sink.
+func NewSinkReporter(sink Sink) *SinkReporter {
+	return &SinkReporter{
+		sink: sink,
+	}
+}
+
+// ReportCPUProfile writes the CPU profiling data to the sink.
+func (s *SinkReporter) ReportCPUProfile(
+	ctx context.Context, r io.Reader, ci CPUInfo,
+) error {
+	return s.sink.Write(ctx, ProfileArtifact{
+		Kind:      ProfileKindCPU,
+		Reader:    r,
+		Info:      ci,
+		Labels:    ci.Labels,
+		TriggerID: ci.TriggerID,
+	})
+}
+
+// ReportHeapProfile writes the heap profiling data to the sink.
+func (s *SinkReporter) ReportHeapProfile(
+	ctx context.Context, r io.Reader, mi MemInfo,
+) error {
+	return s.sink.Write(ctx, ProfileArtifact{
+		Kind:      ProfileKindHeap,
+		Reader:    r,
+		Info:      mi,
+		Labels:    mi.Labels,
+		TriggerID: mi.TriggerID,
+	})
+}
+
+// ReportGoroutineProfile writes the goroutine profiling data to
+// the sink.
+func (s *SinkReporter) ReportGoroutineProfile(
+	ctx context.Context, r io.Reader, gi GoroutineInfo,
+) error {
+	return s.sink.Write(ctx, ProfileArtifact{
+		Kind:      ProfileKindGoroutine,
+		Reader:    r,
+		Info:      gi,
+		Labels:    gi.Labels,
+		TriggerID: gi.TriggerID,
+	})
+}
+
+// ReportProfile writes the profiling data of the kind to the sink.
+func (s *SinkReporter) ReportProfile(
+	ctx context.Context, r io.Reader, kind ProfileKind, pi ProfileInfo,
+) error {
+	return s.sink.Write(ctx, ProfileArtifact{
+		Kind:      kind,
+		Reader:    r,
+		Info:      pi,
+		Labels:    pi.Labels,
+		TriggerID: pi.TriggerID,
+	})
+}
+
+// ReporterSink is the Sink sending the artifacts to the Reporter by
+// the method of the type of their Info, so the existing Reporter
+// implementations work where the Sink is expected.
+type ReporterSink struct {
+	reporter Reporter
+}
+
+// NewReporterSink returns the ReporterSink sending to the reporter.
+func NewReporterSink(reporter Reporter) *ReporterSink {
+	return &ReporterSink{
+		reporter: reporter,
+	}
+}
+
+// Write sends the artifact to the reporter. The ProfileInfo is sent by
+// the ReportProfile function. It returns ErrUnsupportedProfileKind for
+// the unknown type of the Info.
+func (s *ReporterSink) Write(ctx context.Context, a ProfileArtifact) error {
+	switch info := a.Info.(type) {
+	case CPUInfo:
+		return s.reporter.ReportCPUProfile(ctx, a.Reader, info)
+	case MemInfo:
+		return s.reporter.ReportHeapProfile(ctx, a.Reader, info)
+	case GoroutineInfo:
+		return s.reporter.ReportGoroutineProfile(ctx, a.Reader, info)
+	case ProfileInfo:
+		return ReportProfile(ctx, s.reporter, a.Reader, a.Kind, info)
+	}
+	return fmt.Errorf("%w: %s (%T)", ErrUnsupportedProfileKind, a.Kind, a.Info)
+}
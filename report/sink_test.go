@@ -0,0 +1,117 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestSinkReporter(t *testing.T) {
+	var got []ProfileArtifact
+	sink := SinkFunc(func(_ context.Context, a ProfileArtifact) error {
+		b, err := io.ReadAll(a.Reader)
+		if err != nil {
+			return err
+		}
+		if string(b) != string(a.Kind) {
+			t.Errorf("profile of %s = %q, want %q", a.Kind, b, a.Kind)
+		}
+		got = append(got, a)
+		return nil
+	})
+
+	var (
+		ctx    = context.Background()
+		labels = map[string]string{"version": "v1"}
+		s      = NewSinkReporter(sink)
+	)
+	if err := s.ReportCPUProfile(ctx, strings.NewReader("cpu"), CPUInfo{
+		Labels: labels, TriggerID: "cpu-id",
+	}); err != nil {
+		t.Fatalf("ReportCPUProfile() = %v, want nil", err)
+	}
+	if err := s.ReportHeapProfile(ctx, strings.NewReader("heap"), MemInfo{
+		TriggerID: "heap-id",
+	}); err != nil {
+		t.Fatalf("ReportHeapProfile() = %v, want nil", err)
+	}
+	if err := s.ReportGoroutineProfile(ctx, strings.NewReader("goroutine"), GoroutineInfo{
+		TriggerID: "goroutine-id",
+	}); err != nil {
+		t.Fatalf("ReportGoroutineProfile() = %v, want nil", err)
+	}
+	if err := ReportProfile(ctx, s, strings.NewReader("mutex"), ProfileKindMutex, ProfileInfo{
+		TriggerID: "mutex-id",
+	}); err != nil {
+		t.Fatalf("ReportProfile() = %v, want nil", err)
+	}
+
+	want := []struct {
+		kind      ProfileKind
+		triggerID string
+	}{
+		{kind: ProfileKindCPU, triggerID: "cpu-id"},
+		{kind: ProfileKindHeap, triggerID: "heap-id"},
+		{kind: ProfileKindGoroutine, triggerID: "goroutine-id"},
+		{kind: ProfileKindMutex, triggerID: "mutex-id"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("len of artifacts = %d, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Kind != w.kind || got[i].TriggerID != w.triggerID {
+			t.Errorf("artifact #%d = %s %q, want %s %q", i, got[i].Kind, got[i].TriggerID, w.kind, w.triggerID)
+		}
+	}
+	if got[0].Labels["version"] != "v1" {
+		t.Errorf("Labels = %v, want %v", got[0].Labels, labels)
+	}
+}
+
+func TestReporterSink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	reporter := NewMockReporter(ctrl)
+	reporter.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), CPUInfo{TriggerID: "cpu-id"}).
+		Return(nil)
+	reporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), MemInfo{TriggerID: "heap-id"}).
+		Return(nil)
+	reporter.EXPECT().
+		ReportGoroutineProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+
+	var (
+		ctx = context.Background()
+		s   = NewReporterSink(reporter)
+	)
+	for _, a := range []ProfileArtifact{
+		{Kind: ProfileKindCPU, Info: CPUInfo{TriggerID: "cpu-id"}},
+		{Kind: ProfileKindHeap, Info: MemInfo{TriggerID: "heap-id"}},
+		// The goroutine profile of the ProfileInfo is sent by
+		//  the ReportGoroutineProfile.
+		{Kind: ProfileKindGoroutine, Info: ProfileInfo{}},
+	} {
+		a.Reader = strings.NewReader("prof")
+		if err := s.Write(ctx, a); err != nil {
+			t.Errorf("Write(%s) = %v, want nil", a.Kind, err)
+		}
+	}
+
+	// The plain Reporter can't report the other kinds.
+	err := s.Write(ctx, ProfileArtifact{
+		Kind: ProfileKindMutex, Reader: strings.NewReader("prof"), Info: ProfileInfo{},
+	})
+	if !errors.Is(err, ErrUnsupportedProfileKind) {
+		t.Errorf("Write(mutex) = %v, want %v", err, ErrUnsupportedProfileKind)
+	}
+	err = s.Write(ctx, ProfileArtifact{Kind: ProfileKindCPU, Info: "unknown"})
+	if !errors.Is(err, ErrUnsupportedProfileKind) {
+		t.Errorf("Write() of the unknown info = %v, want %v", err, ErrUnsupportedProfileKind)
+	}
+}
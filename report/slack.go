@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"time"
+	"strings"
 
 	"github.com/slack-go/slack"
 )
@@ -15,13 +15,55 @@ const (
 
 	cpuCommentFmt = ":rotating_light:[CPU] usage (*%.2f%%*) > threshold (*%.2f%%*)"
 	memCommentFmt = ":rotating_light:[MEM] usage (*%.2f%%*) > threshold (*%.2f%%*)"
+
+	fdCommentFmt = ":rotating_light:[FD] usage (*%.2f%%*) > threshold (*%.2f%%*)"
+
+	goroutineCommentFmt = ":rotating_light:[GOROUTINE] count (*%d*) > threshold (*%d*)"
+
+	pidCommentFmt = ":rotating_light:[PID] usage (*%d/%d*, *%.2f%%*) > threshold (*%.2f%%*)"
+
+	memAvailableCommentFmt = ":rotating_light:[MEM] available (*%d bytes*) < min available (*%d bytes*)"
+
+	gcCommentFmt = ":rotating_light:[GC] rate (*%.2f/s*), pause p99 (*%s*) > threshold (*%.2f/s*, *%s*)"
+
+	crashCommentFmt = ":skull:[CRASH] %s"
+
+	goroutineDropCommentFmt = ":rotating_light:[GOROUTINE] dropped (*%d -> %d*, *%.2f%%*) > threshold (*%.2f%%*)"
+
+	cpuPressureCommentFmt = ":rotating_light:[CPU] pressure (*%.2f%%*) > threshold (*%.2f%%*), usage (*%.2f%%*)"
+
+	cpuPressureDumpCommentFmt = ":rotating_light:[CPU] pressure > threshold, usage (*%.2f%%*), threshold (*%.2f%%*)"
+
+	numaCommentFmt = ":rotating_light:[MEM] NUMA node usage (*%s*) > threshold (*%.2f%%*)"
+
+	memRequestCommentFmt = ":rotating_light:[MEM] usage of the request (*%.2f%%* of *%d bytes*) > threshold (*%.2f%%*)"
+
+	errorRateCommentFmt = ":rotating_light:[ERROR] rate (*%.2f/s*) > threshold (*%.2f/s*)"
+
+	latencyBreachComment = ":hourglass:[LATENCY] SLO breach notified by the application"
+
+	startupComment = ":seedling:[STARTUP] cold-start baseline"
+
+	manualComment = ":hand:[MANUAL] requested by the operator"
+
+	triggerIDCommentFmt = "\ntrigger: `%s`"
+
+	incidentCommentFmt = "\nincident: `%s`"
+
+	allocSinceCommentFmt = "\nallocated since the last report: `%d bytes`"
+
+	profileLabelsCommentFmt = "\nscoped to: `%s` (*%.2f%%* of the cpu time)"
+
+	limitsCommentFmt = "\nlimits: `%.2f cores, %d bytes`"
+
+	labelsCommentFmt = "\nlabels: `%s`"
 )
 
 // SlackReporter is the reporter to send the profiling report to the
 // specific Slack channel.
 type SlackReporter struct {
-	app     string
-	channel string
+	app        string
+	channel    string
 	serverName string
 
 	client *slack.Client
@@ -29,36 +71,77 @@ type SlackReporter struct {
 
 // SlackReporterOption is the option for the Slack reporter.
 type SlackReporterOption struct {
-	App     string
-	Token   string
-	Channel string
+	App        string
+	Token      string
+	Channel    string
 	ServerName string
 }
 
 // NewSlackReporter returns the new SlackReporter.
 func NewSlackReporter(opt *SlackReporterOption) *SlackReporter {
 	return &SlackReporter{
-		app:     opt.App,
-		channel: opt.Channel,
-		client:  slack.New(opt.Token),
+		app:        opt.App,
+		channel:    opt.Channel,
+		client:     slack.New(opt.Token),
 		serverName: opt.ServerName,
 	}
 }
 
+// Ping checks that the token is valid.
+func (s *SlackReporter) Ping(ctx context.Context) error {
+	if _, err := s.client.AuthTestContext(ctx); err != nil {
+		return fmt.Errorf("autopprof: failed to authenticate to Slack: %w", err)
+	}
+	return nil
+}
+
 // ReportCPUProfile sends the CPU profiling data to the Slack.
 func (s *SlackReporter) ReportCPUProfile(
 	ctx context.Context, r io.Reader, ci CPUInfo,
 ) error {
 	hostname, _ := os.Hostname() // Don't care about this error.
 	var (
-		now      = time.Now().Format(reportTimeLayout)
-		filename = fmt.Sprintf(CPUProfileFilenameFmt, s.app, hostname, now)
+		now      = reportTime(ci.Sequence)
+		filename = fmt.Sprintf(CPUProfileFilenameFmt, s.app, hostname, now) + ci.ContentEncoding.Suffix()
 		comment  = fmt.Sprintf(cpuCommentFmt, ci.UsagePercentage, ci.ThresholdPercentage)
 	)
+	if ci.Trigger == TriggerCPUPressure {
+		comment = fmt.Sprintf(cpuPressureCommentFmt, ci.PressurePercentage, ci.PressureThresholdPercentage, ci.UsagePercentage)
+	}
+	if ci.Trigger == TriggerLatencyBreach {
+		comment = latencyBreachComment
+	}
+	if ci.Trigger == TriggerErrorRate {
+		comment = fmt.Sprintf(errorRateCommentFmt, ci.ErrorRate, ci.ErrorRateThreshold)
+	}
+	if ci.Trigger == TriggerStartup {
+		comment = startupComment
+	}
+	if ci.Trigger == TriggerManual {
+		comment = manualComment
+	}
+	if len(ci.ProfileLabels) > 0 {
+		comment += fmt.Sprintf(profileLabelsCommentFmt, formatLabels(ci.ProfileLabels), ci.ProfileLabelsPercentage)
+	}
+	if len(ci.TopFunctions) > 0 {
+		comment += "\n" + topFunctionsComment(ci.TopFunctions)
+	}
+	if ci.TriggerID != "" {
+		comment += fmt.Sprintf(triggerIDCommentFmt, ci.TriggerID)
+	}
+	if ci.IncidentID != "" {
+		comment += fmt.Sprintf(incidentCommentFmt, ci.IncidentID)
+	}
+	if ci.CPUQuotaCores > 0 || ci.MemLimitBytes > 0 {
+		comment += fmt.Sprintf(limitsCommentFmt, ci.CPUQuotaCores, ci.MemLimitBytes)
+	}
+	if len(ci.Labels) > 0 {
+		comment += fmt.Sprintf(labelsCommentFmt, formatLabels(ci.Labels))
+	}
 	if _, err := s.client.UploadFileContext(ctx, slack.FileUploadParameters{
 		Reader:         r,
 		Filename:       filename,
-		Title:          s.serverName+"_"+filename,
+		Title:          s.serverName + "_" + filename,
 		InitialComment: comment,
 		Channels:       []string{s.channel},
 	}); err != nil {
@@ -73,14 +156,122 @@ func (s *SlackReporter) ReportHeapProfile(
 ) error {
 	hostname, _ := os.Hostname() // Don't care about this error.
 	var (
-		now      = time.Now().Format(reportTimeLayout)
-		filename = fmt.Sprintf(HeapProfileFilenameFmt, s.app, hostname, now)
+		now      = reportTime(mi.Sequence)
+		filename = fmt.Sprintf(HeapProfileFilenameFmt, s.app, hostname, now) + mi.ContentEncoding.Suffix()
 		comment  = fmt.Sprintf(memCommentFmt, mi.UsagePercentage, mi.ThresholdPercentage)
 	)
+	if mi.UsagePercentage < mi.ThresholdPercentage &&
+		mi.AvailableBytes < mi.MinAvailableBytes {
+		comment = fmt.Sprintf(memAvailableCommentFmt, mi.AvailableBytes, mi.MinAvailableBytes)
+	}
+	if mi.Trigger == TriggerGC {
+		comment = fmt.Sprintf(gcCommentFmt, mi.GCPerSecond, mi.GCPauseP99, mi.GCRateThreshold, mi.GCPauseThreshold)
+	}
+	if mi.Trigger == TriggerCrash {
+		comment = fmt.Sprintf(crashCommentFmt, mi.Reason)
+	}
+	if mi.Trigger == TriggerNUMA {
+		comment = fmt.Sprintf(numaCommentFmt, formatNUMANodes(mi.NUMANodes), mi.NUMAThresholdPercentage)
+	}
+	if mi.Trigger == TriggerMemRequest {
+		comment = fmt.Sprintf(memRequestCommentFmt, mi.RequestUsagePercentage, mi.RequestBytes, mi.RequestThresholdPercentage)
+	}
+	if mi.Trigger == TriggerStartup {
+		comment = startupComment
+	}
+	if mi.Trigger == TriggerManual {
+		comment = manualComment
+	}
+	if mi.SampleType != "" {
+		filename = fmt.Sprintf(HeapViewProfileFilenameFmt, s.app, hostname, mi.SampleType, now) + mi.ContentEncoding.Suffix()
+	}
+	if mi.TriggerID != "" {
+		comment += fmt.Sprintf(triggerIDCommentFmt, mi.TriggerID)
+	}
+	if mi.IncidentID != "" {
+		comment += fmt.Sprintf(incidentCommentFmt, mi.IncidentID)
+	}
+	if mi.AllocBytesSinceLastReport > 0 {
+		comment += fmt.Sprintf(allocSinceCommentFmt, mi.AllocBytesSinceLastReport)
+	}
+	if mi.CPUQuotaCores > 0 || mi.MemLimitBytes > 0 {
+		comment += fmt.Sprintf(limitsCommentFmt, mi.CPUQuotaCores, mi.MemLimitBytes)
+	}
+	if len(mi.Labels) > 0 {
+		comment += fmt.Sprintf(labelsCommentFmt, formatLabels(mi.Labels))
+	}
+	if _, err := s.client.UploadFileContext(ctx, slack.FileUploadParameters{
+		Reader:         r,
+		Filename:       filename,
+		Title:          s.serverName + "_" + filename,
+		InitialComment: comment,
+		Channels:       []string{s.channel},
+	}); err != nil {
+		return fmt.Errorf("autopprof: failed to upload a file to Slack channel: %w", err)
+	}
+	return nil
+}
+
+// ReportGoroutineProfile sends the goroutine profiling data to the Slack.
+func (s *SlackReporter) ReportGoroutineProfile(
+	ctx context.Context, r io.Reader, gi GoroutineInfo,
+) error {
+	hostname, _ := os.Hostname() // Don't care about this error.
+	var (
+		now      = reportTime(gi.Sequence)
+		filename = fmt.Sprintf(GoroutineProfileFilenameFmt, s.app, hostname, now) + gi.ContentEncoding.Suffix()
+		comment  = fmt.Sprintf(fdCommentFmt, gi.UsagePercentage, gi.ThresholdPercentage)
+	)
+	if gi.Trigger == TriggerCPU {
+		comment = fmt.Sprintf(cpuCommentFmt, gi.UsagePercentage, gi.ThresholdPercentage)
+	}
+	if gi.Trigger == TriggerCPUPressure {
+		// The dump carries the cpu usage and its threshold only.
+		comment = fmt.Sprintf(cpuPressureDumpCommentFmt, gi.UsagePercentage, gi.ThresholdPercentage)
+	}
+	if gi.Trigger == TriggerHeap {
+		comment = fmt.Sprintf(memCommentFmt, gi.UsagePercentage, gi.ThresholdPercentage)
+	}
+	if gi.Trigger == TriggerCrash {
+		comment = fmt.Sprintf(crashCommentFmt, gi.Reason)
+	}
+	if gi.Trigger == TriggerGoroutineDrop {
+		comment = fmt.Sprintf(goroutineDropCommentFmt, gi.GoroutinesBefore, gi.GoroutinesAfter, gi.UsagePercentage, gi.ThresholdPercentage)
+	}
+	if gi.Trigger == TriggerGoroutine {
+		comment = fmt.Sprintf(goroutineCommentFmt, gi.Goroutines, gi.GoroutineThreshold)
+	}
+	if gi.Trigger == TriggerPID {
+		comment = fmt.Sprintf(pidCommentFmt, gi.PIDs, gi.PIDLimit, gi.UsagePercentage, gi.ThresholdPercentage)
+	}
+	if gi.Trigger == TriggerLatencyBreach {
+		comment = latencyBreachComment
+	}
+	if gi.Trigger == TriggerErrorRate {
+		comment = fmt.Sprintf(errorRateCommentFmt, gi.ErrorRate, gi.ErrorRateThreshold)
+	}
+	if gi.Trigger == TriggerManual {
+		comment = manualComment
+	}
+	if gi.Dump {
+		filename = fmt.Sprintf(GoroutineDumpFilenameFmt, s.app, hostname, now) + gi.ContentEncoding.Suffix()
+	}
+	if gi.TriggerID != "" {
+		comment += fmt.Sprintf(triggerIDCommentFmt, gi.TriggerID)
+	}
+	if gi.IncidentID != "" {
+		comment += fmt.Sprintf(incidentCommentFmt, gi.IncidentID)
+	}
+	if gi.CPUQuotaCores > 0 || gi.MemLimitBytes > 0 {
+		comment += fmt.Sprintf(limitsCommentFmt, gi.CPUQuotaCores, gi.MemLimitBytes)
+	}
+	if len(gi.Labels) > 0 {
+		comment += fmt.Sprintf(labelsCommentFmt, formatLabels(gi.Labels))
+	}
 	if _, err := s.client.UploadFileContext(ctx, slack.FileUploadParameters{
 		Reader:         r,
 		Filename:       filename,
-		Title:          s.serverName+"_"+filename,
+		Title:          s.serverName + "_" + filename,
 		InitialComment: comment,
 		Channels:       []string{s.channel},
 	}); err != nil {
@@ -88,3 +279,12 @@ func (s *SlackReporter) ReportHeapProfile(
 	}
 	return nil
 }
+
+func topFunctionsComment(top []FunctionStat) string {
+	var sb strings.Builder
+	sb.WriteString("*Top functions (flat / cum)*")
+	for i, f := range top {
+		fmt.Fprintf(&sb, "\n%d. `%s` %.2f%% / %.2f%%", i+1, f.Name, f.FlatPercentage, f.CumPercentage)
+	}
+	return sb.String()
+}
@@ -0,0 +1,83 @@
+package report
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+// newTestSlackReporter returns the SlackReporter sending to the test
+// server, and the channel of the comments of the uploaded files.
+func newTestSlackReporter(t *testing.T) (*SlackReporter, <-chan string) {
+	t.Helper()
+	commentC := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/files.upload" {
+			_, _ = io.Copy(io.Discard, r.Body)
+			commentC <- r.URL.Query().Get("initial_comment")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	s := NewSlackReporter(&SlackReporterOption{
+		App:     "app",
+		Token:   "token",
+		Channel: "channel",
+	})
+	s.client = slack.New("token", slack.OptionAPIURL(srv.URL+"/"))
+	return s, commentC
+}
+
+func TestSlackReporter_ReportGoroutineProfile_crossingDump(t *testing.T) {
+	testCases := []struct {
+		name        string
+		trigger     string
+		wantComment string
+	}{
+		{
+			name:        "cpu",
+			trigger:     TriggerCPU,
+			wantComment: ":rotating_light:[CPU] usage (*80.00%*) > threshold (*75.00%*)",
+		},
+		{
+			name:        "cpu pressure",
+			trigger:     TriggerCPUPressure,
+			wantComment: ":rotating_light:[CPU] pressure > threshold, usage (*80.00%*), threshold (*75.00%*)",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, commentC := newTestSlackReporter(t)
+
+			// The goroutine dump captured when the cpu usage crosses
+			//  the threshold. (See the reportCrossingDump of the autopprof)
+			gi := GoroutineInfo{
+				SchemaVersion:       SchemaVersion,
+				Trigger:             tc.trigger,
+				TriggerID:           "id",
+				Dump:                true,
+				ThresholdPercentage: 75,
+				UsagePercentage:     80,
+			}
+			if err := s.ReportGoroutineProfile(
+				context.Background(), strings.NewReader("dump"), gi,
+			); err != nil {
+				t.Fatalf("ReportGoroutineProfile() = %v, want nil", err)
+			}
+			comment := <-commentC
+			if !strings.HasPrefix(comment, tc.wantComment) {
+				t.Errorf("comment = %q, want the prefix %q", comment, tc.wantComment)
+			}
+			if !strings.Contains(comment, "trigger: `id`") {
+				t.Errorf("comment = %q, want the trigger id", comment)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package report
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// SQLiteDriverName is the name of the database/sql driver used by
+// the NewSQLiteReporter. (e.g. github.com/mattn/go-sqlite3)
+// The driver isn't imported by the autopprof, so the application must
+// import it.
+const SQLiteDriverName = "sqlite3"
+
+// sqliteMigrations are the migrations of the schema. The i-th migration
+// upgrades the schema to the version i+1, which is stored in
+// the user_version of the database.
+var sqliteMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS autopprof_profiles (
+		id               INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind             TEXT    NOT NULL,
+		ts               INTEGER NOT NULL,
+		host             TEXT    NOT NULL,
+		usage            REAL    NOT NULL,
+		threshold        REAL    NOT NULL,
+		metadata         TEXT    NOT NULL,
+		content_encoding TEXT    NOT NULL,
+		profile          BLOB    NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS autopprof_profiles_kind_ts
+		ON autopprof_profiles (kind, ts)`,
+}
+
+// SQLiteReporter is the reporter to store the profiling report in
+// the local SQLite database for the offline analysis. (e.g. the air
+// gapped environments or the CI artifacts)
+// Each profile is a row of the autopprof_profiles table with
+// the metadata columns:
+//
+//   - kind: the ProfileKind.
+//   - ts: the report time in the unix nanoseconds.
+//   - host: the hostname.
+//   - usage, threshold: the usage and threshold percentages.
+//   - metadata: the JSON encoded CPUInfo, MemInfo, GoroutineInfo or
+//     ProfileInfo.
+//   - content_encoding: the ContentEncoding of the profile.
+//   - profile: the profiling data.
+type SQLiteReporter struct {
+	db *sql.DB
+
+	// mu serializes the writes, since the SQLite allows a writer at
+	//  a time.
+	mu sync.Mutex
+}
+
+// StoredProfile is a profile stored by the SQLiteReporter without its
+// profiling data. (See SQLiteReporter.Profile)
+type StoredProfile struct {
+	ID         int64
+	Kind       ProfileKind
+	ReportedAt time.Time
+	Host       string
+
+	UsagePercentage     float64
+	ThresholdPercentage float64
+
+	// Metadata is the JSON encoded CPUInfo, MemInfo, GoroutineInfo or
+	//  ProfileInfo.
+	Metadata string
+	// ContentEncoding is the encoding of the profiling data.
+	ContentEncoding ContentEncoding
+}
+
+// NewSQLiteReporter opens the SQLite database at the path with
+// the SQLiteDriverName driver, and returns the SQLiteReporter storing
+// the profiles in it.
+// The schema is created or migrated if needed.
+func NewSQLiteReporter(path string) (*SQLiteReporter, error) {
+	db, err := sql.Open(SQLiteDriverName, path)
+	if err != nil {
+		return nil, fmt.Errorf("autopprof: failed to open the sqlite database: %w", err)
+	}
+	// The SQLite allows a writer at a time.
+	db.SetMaxOpenConns(1)
+	s, err := NewSQLiteReporterWithDB(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewSQLiteReporterWithDB returns the SQLiteReporter storing
+// the profiles in the db opened by the application, e.g. with
+// the other driver than the SQLiteDriverName.
+// The schema is created or migrated if needed.
+func NewSQLiteReporterWithDB(db *sql.DB) (*SQLiteReporter, error) {
+	s := &SQLiteReporter{
+		db: db,
+	}
+	if err := s.migrate(context.Background()); err != nil {
+		return nil, fmt.Errorf("autopprof: failed to migrate the sqlite schema: %w", err)
+	}
+	return s, nil
+}
+
+// Ping checks that the database is reachable.
+func (s *SQLiteReporter) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the database.
+func (s *SQLiteReporter) Close() error {
+	return s.db.Close()
+}
+
+// migrate applies the migrations newer than the user_version.
+func (s *SQLiteReporter) migrate(ctx context.Context) error {
+	var version int
+	if err := s.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	for i := version; i < len(sqliteMigrations); i++ {
+		if _, err := s.db.ExecContext(ctx, sqliteMigrations[i]); err != nil {
+			return err
+		}
+		// The PRAGMA doesn't accept the placeholder.
+		if _, err := s.db.ExecContext(
+			ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReportCPUProfile stores the CPU profiling data.
+func (s *SQLiteReporter) ReportCPUProfile(
+	ctx context.Context, r io.Reader, ci CPUInfo,
+) error {
+	return s.insert(
+		ctx, r, ProfileKindCPU,
+		ci.UsagePercentage, ci.ThresholdPercentage, ci.ContentEncoding, ci,
+	)
+}
+
+// ReportHeapProfile stores the heap profiling data.
+func (s *SQLiteReporter) ReportHeapProfile(
+	ctx context.Context, r io.Reader, mi MemInfo,
+) error {
+	return s.insert(
+		ctx, r, ProfileKindHeap,
+		mi.UsagePercentage, mi.ThresholdPercentage, mi.ContentEncoding, mi,
+	)
+}
+
+// ReportGoroutineProfile stores the goroutine profiling data.
+func (s *SQLiteReporter) ReportGoroutineProfile(
+	ctx context.Context, r io.Reader, gi GoroutineInfo,
+) error {
+	return s.insert(
+		ctx, r, ProfileKindGoroutine,
+		gi.UsagePercentage, gi.ThresholdPercentage, gi.ContentEncoding, gi,
+	)
+}
+
+// ReportProfile stores the profiling data of the kind.
+func (s *SQLiteReporter) ReportProfile(
+	ctx context.Context, r io.Reader, kind ProfileKind, pi ProfileInfo,
+) error {
+	return s.insert(ctx, r, kind, 0, 0, pi.ContentEncoding, pi)
+}
+
+func (s *SQLiteReporter) insert(
+	ctx context.Context, r io.Reader, kind ProfileKind,
+	usage, threshold float64, encoding ContentEncoding, info interface{},
+) error {
+	profile, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to read the profile: %w", err)
+	}
+	metadata, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to encode the metadata: %w", err)
+	}
+	hostname, _ := os.Hostname() // Don't care about this error.
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO autopprof_profiles
+		(kind, ts, host, usage, threshold, metadata, content_encoding, profile)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		string(kind), time.Now().UnixNano(), hostname, usage, threshold,
+		string(metadata), string(encoding), profile,
+	); err != nil {
+		return fmt.Errorf("autopprof: failed to store the profile: %w", err)
+	}
+	return nil
+}
+
+// List returns the stored profiles of the kind in the order of
+// the report time. The empty kind means all kinds.
+func (s *SQLiteReporter) List(ctx context.Context, kind ProfileKind) ([]StoredProfile, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT
+		id, kind, ts, host, usage, threshold, metadata, content_encoding
+		FROM autopprof_profiles
+		WHERE ? = '' OR kind = ?
+		ORDER BY ts, id`,
+		string(kind), string(kind),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("autopprof: failed to list the profiles: %w", err)
+	}
+	defer rows.Close()
+
+	var profiles []StoredProfile
+	for rows.Next() {
+		var (
+			p        StoredProfile
+			kind     string
+			ts       int64
+			encoding string
+		)
+		if err := rows.Scan(
+			&p.ID, &kind, &ts, &p.Host, &p.UsagePercentage,
+			&p.ThresholdPercentage, &p.Metadata, &encoding,
+		); err != nil {
+			return nil, fmt.Errorf("autopprof: failed to list the profiles: %w", err)
+		}
+		p.Kind = ProfileKind(kind)
+		p.ReportedAt = time.Unix(0, ts)
+		p.ContentEncoding = ContentEncoding(encoding)
+		profiles = append(profiles, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("autopprof: failed to list the profiles: %w", err)
+	}
+	return profiles, nil
+}
+
+// Profile returns the profiling data of the stored profile by its id.
+// It returns sql.ErrNoRows if there's no such profile.
+func (s *SQLiteReporter) Profile(ctx context.Context, id int64) ([]byte, error) {
+	var profile []byte
+	if err := s.db.QueryRowContext(
+		ctx, "SELECT profile FROM autopprof_profiles WHERE id = ?", id,
+	).Scan(&profile); err != nil {
+		return nil, fmt.Errorf("autopprof: failed to get the profile: %w", err)
+	}
+	return profile, nil
+}
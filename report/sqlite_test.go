@@ -0,0 +1,214 @@
+package report
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSQLiteDriver is the database/sql driver understanding only
+// the statements of the SQLiteReporter, since the real SQLite driver
+// isn't a dependency of the autopprof.
+type fakeSQLiteDriver struct {
+	mu  sync.Mutex
+	dbs map[string]*fakeSQLiteDB
+}
+
+type fakeSQLiteDB struct {
+	mu         sync.Mutex
+	version    int64
+	migrations int
+	rows       [][]driver.Value // id, kind, ts, host, usage, threshold, metadata, content_encoding, profile.
+}
+
+var fakeSQLite = &fakeSQLiteDriver{dbs: make(map[string]*fakeSQLiteDB)}
+
+func init() {
+	sql.Register("fakesqlite", fakeSQLite)
+}
+
+func (d *fakeSQLiteDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	db, ok := d.dbs[name]
+	if !ok {
+		db = &fakeSQLiteDB{}
+		d.dbs[name] = db
+	}
+	return &fakeSQLiteConn{db: db}, nil
+}
+
+type fakeSQLiteConn struct {
+	db *fakeSQLiteDB
+}
+
+func (c *fakeSQLiteConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeSQLiteStmt{db: c.db, query: strings.Join(strings.Fields(query), " ")}, nil
+}
+
+func (c *fakeSQLiteConn) Close() error { return nil }
+
+func (c *fakeSQLiteConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type fakeSQLiteStmt struct {
+	db    *fakeSQLiteDB
+	query string
+}
+
+func (s *fakeSQLiteStmt) Close() error  { return nil }
+func (s *fakeSQLiteStmt) NumInput() int { return -1 }
+
+func (s *fakeSQLiteStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(s.query, "PRAGMA user_version = "):
+		var v int64
+		for _, c := range strings.TrimPrefix(s.query, "PRAGMA user_version = ") {
+			v = v*10 + int64(c-'0')
+		}
+		s.db.version = v
+	case strings.HasPrefix(s.query, "CREATE "):
+		s.db.migrations++
+	case strings.HasPrefix(s.query, "INSERT INTO autopprof_profiles"):
+		row := append([]driver.Value{int64(len(s.db.rows) + 1)}, args...)
+		s.db.rows = append(s.db.rows, row)
+	default:
+		return nil, errors.New("unexpected statement: " + s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeSQLiteStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	switch {
+	case s.query == "PRAGMA user_version":
+		return &fakeSQLiteRows{
+			columns: []string{"user_version"},
+			values:  [][]driver.Value{{s.db.version}},
+		}, nil
+	case strings.HasPrefix(s.query, "SELECT id, kind"):
+		rows := &fakeSQLiteRows{
+			columns: []string{"id", "kind", "ts", "host", "usage", "threshold", "metadata", "content_encoding"},
+		}
+		for _, row := range s.db.rows {
+			if args[0] != "" && row[1] != args[1] {
+				continue
+			}
+			rows.values = append(rows.values, row[:8])
+		}
+		return rows, nil
+	case strings.HasPrefix(s.query, "SELECT profile"):
+		rows := &fakeSQLiteRows{columns: []string{"profile"}}
+		for _, row := range s.db.rows {
+			if row[0] == args[0] {
+				rows.values = append(rows.values, row[8:])
+			}
+		}
+		return rows, nil
+	}
+	return nil, errors.New("unexpected query: " + s.query)
+}
+
+type fakeSQLiteRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeSQLiteRows) Columns() []string { return r.columns }
+func (r *fakeSQLiteRows) Close() error      { return nil }
+
+func (r *fakeSQLiteRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestSQLiteReporter(t *testing.T) {
+	db, err := sql.Open("fakesqlite", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSQLiteReporterWithDB(db)
+	if err != nil {
+		t.Fatalf("NewSQLiteReporterWithDB() = %v, want nil", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	if err := s.ReportCPUProfile(ctx, strings.NewReader("cpu"), CPUInfo{
+		SchemaVersion:       SchemaVersion,
+		ThresholdPercentage: 75,
+		UsagePercentage:     80,
+	}); err != nil {
+		t.Fatalf("ReportCPUProfile() = %v, want nil", err)
+	}
+	if err := s.ReportHeapProfile(ctx, strings.NewReader("heap"), MemInfo{
+		SchemaVersion:       SchemaVersion,
+		ThresholdPercentage: 75,
+		UsagePercentage:     90,
+	}); err != nil {
+		t.Fatalf("ReportHeapProfile() = %v, want nil", err)
+	}
+
+	all, err := s.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List() = %v, want nil", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("number of the stored profiles = %d, want 2", len(all))
+	}
+	heaps, err := s.List(ctx, ProfileKindHeap)
+	if err != nil {
+		t.Fatalf("List() = %v, want nil", err)
+	}
+	if len(heaps) != 1 {
+		t.Fatalf("number of the stored heap profiles = %d, want 1", len(heaps))
+	}
+	if heaps[0].UsagePercentage != 90 || heaps[0].ThresholdPercentage != 75 {
+		t.Errorf("stored heap profile = %+v, want the usage 90 and threshold 75", heaps[0])
+	}
+	prof, err := s.Profile(ctx, heaps[0].ID)
+	if err != nil {
+		t.Fatalf("Profile() = %v, want nil", err)
+	}
+	if string(prof) != "heap" {
+		t.Errorf("Profile() = %q, want %q", prof, "heap")
+	}
+}
+
+func TestSQLiteReporter_migrate(t *testing.T) {
+	db, err := sql.Open("fakesqlite", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Migrate twice. The second one is a no-op.
+	for i := 0; i < 2; i++ {
+		if _, err := NewSQLiteReporterWithDB(db); err != nil {
+			t.Fatalf("NewSQLiteReporterWithDB() = %v, want nil", err)
+		}
+	}
+	fdb := fakeSQLite.dbs[t.Name()]
+	if fdb.version != int64(len(sqliteMigrations)) {
+		t.Errorf("user_version = %d, want %d", fdb.version, len(sqliteMigrations))
+	}
+	if fdb.migrations != len(sqliteMigrations) {
+		t.Errorf("number of the applied migrations = %d, want %d", fdb.migrations, len(sqliteMigrations))
+	}
+}
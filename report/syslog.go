@@ -0,0 +1,255 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package report
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const defaultSyslogTag = "autopprof"
+
+// SyslogReporter is the reporter to log a structured record per
+// report to the syslog. The record is the key=value pairs of
+// the metadata, e.g.
+//
+//	app=myapp host=web-1 kind=cpu usage=82.50 threshold=75.00
+//
+// It only emits the metadata, not the profiling data, so it's meant
+// to be paired with the storage reporter by the MultiReporter. With
+// the SyslogReporterOption.Location, the record refers to where
+// the profile is stored.
+type SyslogReporter struct {
+	app      string
+	writer   *syslog.Writer
+	location func(kind ProfileKind, info interface{}) string
+}
+
+// SyslogReporterOption is the option for the syslog reporter.
+type SyslogReporterOption struct {
+	App string
+
+	// Network and Addr are the address of the syslog server.
+	//  (e.g. "udp", "syslog.internal:514")
+	// Default: "". (means the local syslog server)
+	Network string
+	Addr    string
+
+	// Facility is the facility of the records.
+	// Default: syslog.LOG_DAEMON.
+	Facility syslog.Priority
+	// Severity is the severity of the records.
+	// Default: syslog.LOG_WARNING.
+	Severity syslog.Priority
+	// Tag is the tag of the records.
+	// Default: "autopprof".
+	Tag string
+
+	// Location returns where the profile is stored by the storage
+	//  reporter, (e.g. the URL of the object) which is logged as
+	//  the location field. The info is the CPUInfo, MemInfo,
+	//  GoroutineInfo or ProfileInfo depending on the kind.
+	// Default: nil. (means no location field)
+	Location func(kind ProfileKind, info interface{}) string
+}
+
+// NewSyslogReporter returns the new SyslogReporter connected to
+// the syslog server.
+func NewSyslogReporter(opt *SyslogReporterOption) (*SyslogReporter, error) {
+	facility := syslog.LOG_DAEMON
+	if opt.Facility != 0 {
+		facility = opt.Facility
+	}
+	severity := syslog.LOG_WARNING
+	if opt.Severity != 0 {
+		severity = opt.Severity
+	}
+	tag := defaultSyslogTag
+	if opt.Tag != "" {
+		tag = opt.Tag
+	}
+	w, err := syslog.Dial(opt.Network, opt.Addr, facility|severity, tag)
+	if err != nil {
+		return nil, fmt.Errorf("autopprof: failed to connect to the syslog: %w", err)
+	}
+	return &SyslogReporter{
+		app:      opt.App,
+		writer:   w,
+		location: opt.Location,
+	}, nil
+}
+
+// Close closes the connection to the syslog server.
+func (s *SyslogReporter) Close() error {
+	return s.writer.Close()
+}
+
+// ReportCPUProfile logs the record of the CPU profile.
+func (s *SyslogReporter) ReportCPUProfile(
+	_ context.Context, _ io.Reader, ci CPUInfo,
+) error {
+	return s.log(ProfileKindCPU, ci,
+		"usage", strconv.FormatFloat(ci.UsagePercentage, 'f', 2, 64),
+		"threshold", strconv.FormatFloat(ci.ThresholdPercentage, 'f', 2, 64),
+		"trigger", ci.Trigger,
+		"pressure", syslogPressure(ci),
+		"trigger_id", ci.TriggerID,
+		"incident_id", ci.IncidentID,
+		"cpu_quota", syslogCores(ci.CPUQuotaCores),
+		"mem_limit", syslogBytes(ci.MemLimitBytes),
+		"labels", formatLabels(ci.Labels),
+		"seq", syslogSequence(ci.Sequence),
+	)
+}
+
+// ReportHeapProfile logs the record of the heap profile.
+func (s *SyslogReporter) ReportHeapProfile(
+	_ context.Context, _ io.Reader, mi MemInfo,
+) error {
+	return s.log(ProfileKindHeap, mi,
+		"usage", strconv.FormatFloat(mi.UsagePercentage, 'f', 2, 64),
+		"threshold", strconv.FormatFloat(mi.ThresholdPercentage, 'f', 2, 64),
+		"trigger", mi.Trigger,
+		"reason", mi.Reason,
+		"sample_type", mi.SampleType,
+		"primary_sample_type", mi.PrimarySampleType,
+		"numa", formatNUMANodes(mi.NUMANodes),
+		"request_usage", syslogRequestUsage(mi),
+		"alloc_since_last", syslogBytes(mi.AllocBytesSinceLastReport),
+		"trigger_id", mi.TriggerID,
+		"incident_id", mi.IncidentID,
+		"cpu_quota", syslogCores(mi.CPUQuotaCores),
+		"mem_limit", syslogBytes(mi.MemLimitBytes),
+		"labels", formatLabels(mi.Labels),
+		"seq", syslogSequence(mi.Sequence),
+	)
+}
+
+// ReportGoroutineProfile logs the record of the goroutine profile.
+func (s *SyslogReporter) ReportGoroutineProfile(
+	_ context.Context, _ io.Reader, gi GoroutineInfo,
+) error {
+	return s.log(ProfileKindGoroutine, gi,
+		"usage", strconv.FormatFloat(gi.UsagePercentage, 'f', 2, 64),
+		"threshold", strconv.FormatFloat(gi.ThresholdPercentage, 'f', 2, 64),
+		"trigger", gi.Trigger,
+		"reason", gi.Reason,
+		"trigger_id", gi.TriggerID,
+		"incident_id", gi.IncidentID,
+		"cpu_quota", syslogCores(gi.CPUQuotaCores),
+		"mem_limit", syslogBytes(gi.MemLimitBytes),
+		"labels", formatLabels(gi.Labels),
+		"seq", syslogSequence(gi.Sequence),
+	)
+}
+
+// ReportProfile logs the record of the profile of the kind.
+func (s *SyslogReporter) ReportProfile(
+	_ context.Context, _ io.Reader, kind ProfileKind, pi ProfileInfo,
+) error {
+	return s.log(kind, pi,
+		"name", pi.Name,
+		"delta", syslogFlag(pi.Delta),
+		"trigger_id", pi.TriggerID,
+		"labels", formatLabels(pi.Labels),
+		"seq", syslogSequence(pi.Sequence),
+	)
+}
+
+// log writes the record with the common fields and the key-value
+// pairs of the kvs. The empty values are omitted.
+func (s *SyslogReporter) log(kind ProfileKind, info interface{}, kvs ...string) error {
+	hostname, _ := os.Hostname() // Don't care about this error.
+	kvs = append([]string{
+		"app", s.app,
+		"host", hostname,
+		"kind", string(kind),
+	}, kvs...)
+	if s.location != nil {
+		kvs = append(kvs, "location", s.location(kind, info))
+	}
+
+	var b strings.Builder
+	for i := 0; i+1 < len(kvs); i += 2 {
+		k, v := kvs[i], kvs[i+1]
+		if v == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(syslogValue(v))
+	}
+	if _, err := s.writer.Write([]byte(b.String())); err != nil {
+		return fmt.Errorf("autopprof: failed to write to the syslog: %w", err)
+	}
+	return nil
+}
+
+// syslogValue quotes the value if it has the spaces, the quotes or
+// the equal signs, so the record is parsed back into the same pairs.
+func syslogValue(v string) string {
+	if strings.ContainsAny(v, " \t\n\"=") {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+// syslogSequence returns the sequence number, or empty if it's not set
+// so the field is omitted.
+func syslogSequence(seq uint64) string {
+	if seq == 0 {
+		return ""
+	}
+	return strconv.FormatUint(seq, 10)
+}
+
+// syslogBytes returns the bytes of the record. It's empty if zero.
+func syslogBytes(n uint64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatUint(n, 10)
+}
+
+// syslogCores returns the cores of the record. It's empty if zero.
+func syslogCores(cores float64) string {
+	if cores == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(cores, 'f', 2, 64)
+}
+
+// syslogFlag returns the flag of the record. It's empty if false.
+func syslogFlag(b bool) string {
+	if !b {
+		return ""
+	}
+	return "true"
+}
+
+// syslogPressure returns the cpu pressure of the record. It's empty
+// if the cpu pressure threshold isn't set.
+func syslogPressure(ci CPUInfo) string {
+	if ci.PressureThresholdPercentage == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(ci.PressurePercentage, 'f', 2, 64)
+}
+
+// syslogRequestUsage returns the memory usage against the request of
+// the record. It's empty if the request isn't set.
+func syslogRequestUsage(mi MemInfo) string {
+	if mi.RequestBytes == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(mi.RequestUsagePercentage, 'f', 2, 64)
+}
@@ -0,0 +1,81 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package report
+
+import (
+	"context"
+	"log/syslog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogReporter(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s, err := NewSyslogReporter(&SyslogReporterOption{
+		App:      "appname",
+		Network:  "udp",
+		Addr:     conn.LocalAddr().String(),
+		Facility: syslog.LOG_LOCAL0,
+		Severity: syslog.LOG_ERR,
+		Location: func(kind ProfileKind, _ interface{}) string {
+			return "s3://bucket/" + string(kind)
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewSyslogReporter() = %v, want nil", err)
+	}
+	defer s.Close()
+
+	if err := s.ReportHeapProfile(context.Background(), strings.NewReader("heap"), MemInfo{
+		Trigger:             TriggerCrash,
+		Reason:              "out of memory",
+		ThresholdPercentage: 75,
+		UsagePercentage:     90,
+
+		AllocBytesSinceLastReport: 1024,
+		CPUQuotaCores:             2,
+		MemLimitBytes:             1 << 30,
+	}); err != nil {
+		t.Fatalf("ReportHeapProfile() = %v, want nil", err)
+	}
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom() = %v, want nil", err)
+	}
+	record := string(buf[:n])
+
+	// LOG_LOCAL0 (16<<3) | LOG_ERR (3).
+	if !strings.HasPrefix(record, "<131>") {
+		t.Errorf("record = %q, want the priority <131>", record)
+	}
+	for _, field := range []string{
+		"app=appname",
+		"kind=heap",
+		"usage=90.00",
+		"threshold=75.00",
+		"trigger=crash",
+		`reason="out of memory"`,
+		"alloc_since_last=1024",
+		"cpu_quota=2.00",
+		"mem_limit=1073741824",
+		"location=s3://bucket/heap",
+	} {
+		if !strings.Contains(record, field) {
+			t.Errorf("record = %q, want the field %s", record, field)
+		}
+	}
+	if strings.Contains(record, "trigger_id=") {
+		t.Errorf("record = %q, want the empty trigger_id omitted", record)
+	}
+}
@@ -0,0 +1,625 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWALMaxBytes = 64 << 20 // 64MiB.
+
+	// walRecordHeaderSize is the size of the fixed header of a record:
+	// the type (1), the id (8), the metadata length (4) and the data
+	// length (4).
+	walRecordHeaderSize = 1 + 8 + 4 + 4
+	// walRecordTrailerSize is the size of the crc32 of a record.
+	walRecordTrailerSize = 4
+)
+
+// Types of the WAL records.
+const (
+	// walRecordEntry is the profile to be reported.
+	walRecordEntry byte = 1
+	// walRecordCommit marks the entry of the id as reported.
+	walRecordCommit byte = 2
+)
+
+// Methods of the reporter the WAL entry is reported by.
+const (
+	walMethodCPU       = "cpu"
+	walMethodHeap      = "heap"
+	walMethodGoroutine = "goroutine"
+	walMethodProfile   = "profile"
+)
+
+// ErrWALClosed is returned by the WALReporter after the Close.
+var ErrWALClosed = errors.New("autopprof: WAL is closed")
+
+// WALReporter appends the profiles to the write-ahead log before
+// reporting them to the inner reporter, and marks them as reported on
+// the success, so the profiles aren't lost even if the process crashes
+// mid-upload. The entries left in the WAL by a crash, and the ones
+// failed to report, are sent again by the Replay, which is also run
+// after each successful report. It's at-least-once delivery: an entry
+// reported right before the crash may be sent twice.
+//
+// The WAL is an append-only file. It's truncated when all the entries
+// are reported, and compacted to the unreported entries when it
+// exceeds the MaxBytes. If a profile doesn't fit even after
+// the compaction, it's reported without the WAL.
+// Only the metadata of the unreported entries is kept in the memory,
+// and their profiles are read back from the WAL for the retry.
+// The corrupted tail of the WAL (e.g. a torn write by the crash) is
+// truncated on the open.
+type WALReporter struct {
+	inner    Reporter
+	path     string
+	maxBytes int64
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+	// nextID is the id of the next entry.
+	nextID uint64
+	// pending is the entries not reported yet by the id.
+	pending map[uint64]walEntry
+	// retry is the ids of the entries sent by the Replay, the ones left
+	//  in the WAL on the open and the ones failed to report since.
+	//  The entries of the ongoing reports aren't in it, so they aren't
+	//  sent twice.
+	retry []uint64
+	// replaying reports whether the Replay is running.
+	replaying bool
+	// closed reports whether the Close is called, and inflight tracks
+	//  the reports and the Replay the Close waits for.
+	closed   bool
+	inflight sync.WaitGroup
+}
+
+// WALReporterOption is the option for the WAL reporter.
+type WALReporterOption struct {
+	// Path is the path of the WAL file. It's created if missing.
+	Path string
+	// MaxBytes is the maximum size of the WAL file.
+	// Default: 64MiB.
+	MaxBytes int64
+}
+
+// walEntry is the profile in the WAL. Its data is read back from
+// the record at the off of the WAL.
+type walEntry struct {
+	id   uint64
+	meta walMeta
+	off  int64
+	size int64
+}
+
+// walMeta is the JSON encoded metadata of the WAL entry.
+type walMeta struct {
+	Method string          `json:"method"`
+	Kind   ProfileKind     `json:"kind"`
+	Info   json.RawMessage `json:"info"`
+}
+
+// NewWALReporter returns the WALReporter wrapping the inner reporter.
+// It opens the WAL file, and the entries left in it are sent by
+// the Replay.
+func NewWALReporter(inner Reporter, opt *WALReporterOption) (*WALReporter, error) {
+	w := &WALReporter{
+		inner:    inner,
+		path:     opt.Path,
+		maxBytes: opt.MaxBytes,
+		pending:  make(map[uint64]walEntry),
+	}
+	if w.maxBytes <= 0 {
+		w.maxBytes = defaultWALMaxBytes
+	}
+	f, err := os.OpenFile(w.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("autopprof: failed to open the WAL: %w", err)
+	}
+	if err := w.load(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("autopprof: failed to load the WAL: %w", err)
+	}
+	w.f = f
+	return w, nil
+}
+
+// load reads the records of the file, and truncates the corrupted
+// tail if any.
+func (w *WALReporter) load(f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	var (
+		r     = bufio.NewReader(f)
+		valid int64
+	)
+	for {
+		typ, id, meta, _, n, err := readWALRecord(r, fi.Size()-valid)
+		if err != nil {
+			// The end of the WAL or the corrupted tail.
+			break
+		}
+		off := valid
+		valid += n
+		if id >= w.nextID {
+			w.nextID = id + 1
+		}
+		switch typ {
+		case walRecordEntry:
+			var m walMeta
+			if err := json.Unmarshal(meta, &m); err != nil {
+				continue
+			}
+			w.pending[id] = walEntry{id: id, meta: m, off: off, size: n}
+		case walRecordCommit:
+			delete(w.pending, id)
+		}
+	}
+	for id := range w.pending {
+		w.retry = append(w.retry, id)
+	}
+	sort.Slice(w.retry, func(i, j int) bool {
+		return w.retry[i] < w.retry[j]
+	})
+	if valid < fi.Size() {
+		if err := f.Truncate(valid); err != nil {
+			return err
+		}
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		return err
+	}
+	w.size = valid
+	return nil
+}
+
+// Pending returns the number of the entries not reported yet.
+func (w *WALReporter) Pending() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.pending)
+}
+
+// Close closes the WAL file after the ongoing reports and Replay.
+// The later reports and Replay return ErrWALClosed.
+func (w *WALReporter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	w.inflight.Wait()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.f.Close()
+}
+
+// begin registers the report or the Replay to be waited for by
+// the Close. It returns ErrWALClosed after the Close.
+func (w *WALReporter) begin() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrWALClosed
+	}
+	w.inflight.Add(1)
+	return nil
+}
+
+// Timeout returns the timeout of the inner reporter if it's
+// a TimeoutReporter. Otherwise, it returns zero.
+func (w *WALReporter) Timeout() time.Duration {
+	if tr, ok := w.inner.(TimeoutReporter); ok {
+		return tr.Timeout()
+	}
+	return 0
+}
+
+// Ping checks the inner reporter if it's a PingReporter.
+func (w *WALReporter) Ping(ctx context.Context) error {
+	if pr, ok := w.inner.(PingReporter); ok {
+		return pr.Ping(ctx)
+	}
+	return nil
+}
+
+// Flush flushes the inner reporter if it's a Flusher.
+func (w *WALReporter) Flush(ctx context.Context) error {
+	if f, ok := w.inner.(Flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+// ReportCPUProfile appends the CPU profiling data to the WAL and sends
+// it to the inner reporter.
+func (w *WALReporter) ReportCPUProfile(
+	ctx context.Context, r io.Reader, ci CPUInfo,
+) error {
+	return w.report(ctx, r, walMethodCPU, ProfileKindCPU, ci)
+}
+
+// ReportHeapProfile appends the heap profiling data to the WAL and
+// sends it to the inner reporter.
+func (w *WALReporter) ReportHeapProfile(
+	ctx context.Context, r io.Reader, mi MemInfo,
+) error {
+	return w.report(ctx, r, walMethodHeap, ProfileKindHeap, mi)
+}
+
+// ReportGoroutineProfile appends the goroutine profiling data to
+// the WAL and sends it to the inner reporter.
+func (w *WALReporter) ReportGoroutineProfile(
+	ctx context.Context, r io.Reader, gi GoroutineInfo,
+) error {
+	return w.report(ctx, r, walMethodGoroutine, ProfileKindGoroutine, gi)
+}
+
+// ReportProfile appends the profiling data of the kind to the WAL and
+// sends it to the inner reporter. (See the ReportProfile function)
+func (w *WALReporter) ReportProfile(
+	ctx context.Context, r io.Reader, kind ProfileKind, pi ProfileInfo,
+) error {
+	return w.report(ctx, r, walMethodProfile, kind, pi)
+}
+
+// Replay sends the entries left in the WAL on the open, and the ones
+// failed to report since, to the inner reporter in the order they were
+// appended. It stops at the first failure, and the failed and
+// the remaining entries are kept for the next Replay, which is run
+// after the next successful report. It returns nil without sending if
+// another Replay is running.
+func (w *WALReporter) Replay(ctx context.Context) error {
+	if err := w.begin(); err != nil {
+		return err
+	}
+	defer w.inflight.Done()
+
+	w.mu.Lock()
+	if w.replaying {
+		w.mu.Unlock()
+		return nil
+	}
+	w.replaying = true
+	ids := w.retry
+	w.retry = nil
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.replaying = false
+		w.mu.Unlock()
+	}()
+
+	for i, id := range ids {
+		e, data, ok, err := w.read(id)
+		if err != nil {
+			// Drop the unreadable entry not to block the others.
+			_ = w.commit(id)
+			w.requeue(ids[i+1:])
+			return fmt.Errorf("autopprof: failed to replay the WAL: %w", err)
+		}
+		if !ok {
+			continue
+		}
+		if err := w.send(ctx, e.meta, data); err != nil {
+			w.requeue(ids[i:])
+			return fmt.Errorf("autopprof: failed to replay the WAL: %w", err)
+		}
+		if err := w.commit(id); err != nil {
+			w.requeue(ids[i+1:])
+			return err
+		}
+	}
+	return nil
+}
+
+// requeue puts the ids back to the head of the retry, ahead of
+// the ones failed during the Replay.
+func (w *WALReporter) requeue(ids []uint64) {
+	if len(ids) == 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.retry = append(append([]uint64(nil), ids...), w.retry...)
+}
+
+// read returns the pending entry of the id and its data read from
+// the WAL. It returns false if the entry is already reported.
+func (w *WALReporter) read(id uint64) (walEntry, []byte, bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	e, ok := w.pending[id]
+	if !ok {
+		return walEntry{}, nil, false, nil
+	}
+	rec := make([]byte, e.size)
+	if _, err := w.f.ReadAt(rec, e.off); err != nil {
+		return walEntry{}, nil, false, err
+	}
+	_, _, _, data, _, err := readWALRecord(bytes.NewReader(rec), e.size)
+	if err != nil {
+		return walEntry{}, nil, false, err
+	}
+	return e, data, true, nil
+}
+
+func (w *WALReporter) report(
+	ctx context.Context, r io.Reader, method string, kind ProfileKind, info interface{},
+) error {
+	if err := w.begin(); err != nil {
+		return err
+	}
+	defer w.inflight.Done()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to read the profile: %w", err)
+	}
+	b, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to encode the profile info: %w", err)
+	}
+	meta := walMeta{Method: method, Kind: kind, Info: b}
+
+	id, logged, err := w.append(meta, data)
+	if err != nil {
+		return err
+	}
+	if err := w.send(ctx, meta, data); err != nil {
+		if logged {
+			// Keep the entry for the Replay.
+			w.requeue([]uint64{id})
+		}
+		return err
+	}
+	if logged {
+		if err := w.commit(id); err != nil {
+			return err
+		}
+	}
+	if w.hasRetry() {
+		// The inner reporter is back, so retry the failed entries. Its
+		//  failure is kept for the next one.
+		_ = w.Replay(ctx)
+	}
+	return nil
+}
+
+// hasRetry reports whether any entry is left for the Replay.
+func (w *WALReporter) hasRetry() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.retry) > 0
+}
+
+// send sends the entry to the inner reporter by its method.
+func (w *WALReporter) send(ctx context.Context, meta walMeta, data []byte) error {
+	r := bytes.NewReader(data)
+	switch meta.Method {
+	case walMethodCPU:
+		var ci CPUInfo
+		if err := json.Unmarshal(meta.Info, &ci); err != nil {
+			return err
+		}
+		return w.inner.ReportCPUProfile(ctx, r, ci)
+	case walMethodHeap:
+		var mi MemInfo
+		if err := json.Unmarshal(meta.Info, &mi); err != nil {
+			return err
+		}
+		return w.inner.ReportHeapProfile(ctx, r, mi)
+	case walMethodGoroutine:
+		var gi GoroutineInfo
+		if err := json.Unmarshal(meta.Info, &gi); err != nil {
+			return err
+		}
+		return w.inner.ReportGoroutineProfile(ctx, r, gi)
+	case walMethodProfile:
+		var pi ProfileInfo
+		if err := json.Unmarshal(meta.Info, &pi); err != nil {
+			return err
+		}
+		return ReportProfile(ctx, w.inner, r, meta.Kind, pi)
+	}
+	return fmt.Errorf("autopprof: unknown method of the WAL entry: %s", meta.Method)
+}
+
+// append appends the entry to the WAL, and returns its id. It returns
+// false if the entry doesn't fit in the MaxBytes even after
+// the compaction, so it isn't in the WAL.
+func (w *WALReporter) append(meta walMeta, data []byte) (uint64, bool, error) {
+	metaBytes, err := json.Marshal(meta)
+	if err != nil {
+		return 0, false, fmt.Errorf("autopprof: failed to encode the WAL entry: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	id := w.nextID
+	rec := encodeWALRecord(walRecordEntry, id, metaBytes, data)
+	if w.size+int64(len(rec)) > w.maxBytes {
+		if err := w.compactLocked(); err != nil {
+			return 0, false, err
+		}
+		if w.size+int64(len(rec)) > w.maxBytes {
+			return 0, false, nil
+		}
+	}
+	off := w.size
+	if err := w.writeLocked(rec); err != nil {
+		return 0, false, err
+	}
+	w.nextID++
+	w.pending[id] = walEntry{id: id, meta: meta, off: off, size: int64(len(rec))}
+	return id, true, nil
+}
+
+// commit marks the entry of the id as reported. The WAL is truncated
+// if no entry is left.
+func (w *WALReporter) commit(id uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.pending, id)
+	if len(w.pending) == 0 {
+		return w.truncateLocked()
+	}
+	return w.writeLocked(encodeWALRecord(walRecordCommit, id, nil, nil))
+}
+
+// compactLocked rewrites the WAL with the pending entries only.
+// The records are copied to a new file, which replaces the WAL, so
+// the crash during the compaction doesn't lose them.
+// The w.mu must be held.
+func (w *WALReporter) compactLocked() error {
+	ids := make([]uint64, 0, len(w.pending))
+	for id := range w.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	tmpPath := w.path + ".compact"
+	f, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to compact the WAL: %w", err)
+	}
+	var (
+		entries = make(map[uint64]walEntry, len(ids))
+		size    int64
+	)
+	for _, id := range ids {
+		e := w.pending[id]
+		rec := make([]byte, e.size)
+		if _, err := w.f.ReadAt(rec, e.off); err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("autopprof: failed to compact the WAL: %w", err)
+		}
+		if _, err := f.Write(rec); err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("autopprof: failed to compact the WAL: %w", err)
+		}
+		e.off = size
+		entries[id] = e
+		size += e.size
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("autopprof: failed to compact the WAL: %w", err)
+	}
+	if err := os.Rename(tmpPath, w.path); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("autopprof: failed to compact the WAL: %w", err)
+	}
+	w.f.Close()
+	w.f = f
+	w.size = size
+	w.pending = entries
+	return nil
+}
+
+// truncateLocked empties the WAL file. The w.mu must be held.
+func (w *WALReporter) truncateLocked() error {
+	if err := w.f.Truncate(0); err != nil {
+		return fmt.Errorf("autopprof: failed to truncate the WAL: %w", err)
+	}
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("autopprof: failed to truncate the WAL: %w", err)
+	}
+	w.size = 0
+	return nil
+}
+
+// writeLocked writes the record and syncs the file, so the record
+// survives the crash. The w.mu must be held.
+func (w *WALReporter) writeLocked(rec []byte) error {
+	if _, err := w.f.Write(rec); err != nil {
+		return fmt.Errorf("autopprof: failed to write the WAL: %w", err)
+	}
+	w.size += int64(len(rec))
+	if err := w.f.Sync(); err != nil {
+		return fmt.Errorf("autopprof: failed to sync the WAL: %w", err)
+	}
+	return nil
+}
+
+// encodeWALRecord returns the record of the type, the fixed header
+// followed by the metadata and the data, and the crc32 of them.
+func encodeWALRecord(typ byte, id uint64, meta, data []byte) []byte {
+	rec := make([]byte, walRecordHeaderSize, walRecordHeaderSize+len(meta)+len(data)+walRecordTrailerSize)
+	rec[0] = typ
+	binary.BigEndian.PutUint64(rec[1:9], id)
+	binary.BigEndian.PutUint32(rec[9:13], uint32(len(meta)))
+	binary.BigEndian.PutUint32(rec[13:17], uint32(len(data)))
+	rec = append(rec, meta...)
+	rec = append(rec, data...)
+	var sum [walRecordTrailerSize]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(rec))
+	return append(rec, sum[:]...)
+}
+
+var errCorruptedWALRecord = errors.New("autopprof: corrupted WAL record")
+
+// readWALRecord reads a record of at most the remaining bytes, and
+// returns the size of it. It returns the io.EOF at the end of the WAL,
+// and errCorruptedWALRecord if the record is torn or corrupted.
+func readWALRecord(
+	r io.Reader, remaining int64,
+) (typ byte, id uint64, meta, data []byte, n int64, err error) {
+	header := make([]byte, walRecordHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF {
+			return 0, 0, nil, nil, 0, io.EOF
+		}
+		return 0, 0, nil, nil, 0, errCorruptedWALRecord
+	}
+	typ = header[0]
+	id = binary.BigEndian.Uint64(header[1:9])
+	metaLen := int64(binary.BigEndian.Uint32(header[9:13]))
+	dataLen := int64(binary.BigEndian.Uint32(header[13:17]))
+	n = walRecordHeaderSize + metaLen + dataLen + walRecordTrailerSize
+	if (typ != walRecordEntry && typ != walRecordCommit) || n > remaining {
+		return 0, 0, nil, nil, 0, errCorruptedWALRecord
+	}
+
+	body := make([]byte, metaLen+dataLen+walRecordTrailerSize)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, 0, nil, nil, 0, errCorruptedWALRecord
+	}
+	crc := crc32.NewIEEE()
+	crc.Write(header)
+	crc.Write(body[:metaLen+dataLen])
+	if crc.Sum32() != binary.BigEndian.Uint32(body[metaLen+dataLen:]) {
+		return 0, 0, nil, nil, 0, errCorruptedWALRecord
+	}
+	return typ, id, body[:metaLen], body[metaLen : metaLen+dataLen], n, nil
+}
@@ -0,0 +1,244 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestWALReporter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockReporter := NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+
+	path := filepath.Join(t.TempDir(), "autopprof.wal")
+	w, err := NewWALReporter(mockReporter, &WALReporterOption{Path: path})
+	if err != nil {
+		t.Fatalf("NewWALReporter() = %v, want nil", err)
+	}
+	defer w.Close()
+
+	if err := w.ReportCPUProfile(
+		context.Background(), bytes.NewReader([]byte("prof")), CPUInfo{},
+	); err != nil {
+		t.Fatalf("ReportCPUProfile() = %v, want nil", err)
+	}
+	if got := w.Pending(); got != 0 {
+		t.Errorf("Pending() = %d, want 0", got)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != 0 {
+		t.Errorf("WAL size = %d, want 0 after the reports", fi.Size())
+	}
+}
+
+func TestWALReporter_Replay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var (
+		path      = filepath.Join(t.TempDir(), "autopprof.wal")
+		errReport = errors.New("unavailable")
+	)
+	failing := NewMockReporter(ctrl)
+	failing.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(errReport)
+	failing.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(errReport)
+
+	w, err := NewWALReporter(failing, &WALReporterOption{Path: path})
+	if err != nil {
+		t.Fatalf("NewWALReporter() = %v, want nil", err)
+	}
+	ctx := context.Background()
+	if err := w.ReportCPUProfile(
+		ctx, bytes.NewReader([]byte("cpu")), CPUInfo{UsagePercentage: 90},
+	); !errors.Is(err, errReport) {
+		t.Fatalf("ReportCPUProfile() = %v, want %v", err, errReport)
+	}
+	if err := w.ReportHeapProfile(
+		ctx, bytes.NewReader([]byte("heap")), MemInfo{Trigger: TriggerGC},
+	); !errors.Is(err, errReport) {
+		t.Fatalf("ReportHeapProfile() = %v, want %v", err, errReport)
+	}
+	w.Close()
+
+	// Tear the tail as if the process crashed mid-write.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{walRecordEntry, 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	var replayed []string
+	mockReporter := NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, r io.Reader, ci CPUInfo) error {
+				b, _ := io.ReadAll(r)
+				replayed = append(replayed, string(b))
+				if ci.UsagePercentage != 90 {
+					t.Errorf("CPUInfo.UsagePercentage = %v, want 90", ci.UsagePercentage)
+				}
+				return nil
+			},
+		)
+	mockReporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, r io.Reader, mi MemInfo) error {
+				b, _ := io.ReadAll(r)
+				replayed = append(replayed, string(b))
+				if mi.Trigger != TriggerGC {
+					t.Errorf("MemInfo.Trigger = %q, want %q", mi.Trigger, TriggerGC)
+				}
+				return nil
+			},
+		)
+
+	w, err = NewWALReporter(mockReporter, &WALReporterOption{Path: path})
+	if err != nil {
+		t.Fatalf("NewWALReporter() = %v, want nil", err)
+	}
+	defer w.Close()
+	if got := w.Pending(); got != 2 {
+		t.Fatalf("Pending() = %d, want 2", got)
+	}
+	if err := w.Replay(ctx); err != nil {
+		t.Fatalf("Replay() = %v, want nil", err)
+	}
+	if len(replayed) != 2 || replayed[0] != "cpu" || replayed[1] != "heap" {
+		t.Errorf("replayed = %v, want [cpu heap]", replayed)
+	}
+	if got := w.Pending(); got != 0 {
+		t.Errorf("Pending() = %d, want 0", got)
+	}
+}
+
+func TestWALReporter_maxBytes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockReporter := NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(errors.New("unavailable"))
+
+	w, err := NewWALReporter(mockReporter, &WALReporterOption{
+		Path:     filepath.Join(t.TempDir(), "autopprof.wal"),
+		MaxBytes: 16,
+	})
+	if err != nil {
+		t.Fatalf("NewWALReporter() = %v, want nil", err)
+	}
+	defer w.Close()
+
+	// It's reported without the WAL, since it doesn't fit.
+	if err := w.ReportCPUProfile(
+		context.Background(), bytes.NewReader(make([]byte, 64)), CPUInfo{},
+	); err == nil {
+		t.Errorf("ReportCPUProfile() = nil, want the error of the inner reporter")
+	}
+	if got := w.Pending(); got != 0 {
+		t.Errorf("Pending() = %d, want 0", got)
+	}
+}
+
+func TestWALReporter_retry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	errReport := errors.New("unavailable")
+	var reported []string
+	mockReporter := NewMockReporter(ctrl)
+	gomock.InOrder(
+		mockReporter.EXPECT().
+			ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(errReport),
+		mockReporter.EXPECT().
+			ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+			Times(2).
+			DoAndReturn(
+				func(_ context.Context, r io.Reader, _ MemInfo) error {
+					b, _ := io.ReadAll(r)
+					reported = append(reported, string(b))
+					return nil
+				},
+			),
+	)
+
+	// The WAL fits a single entry, so the second report compacts it
+	//  and is sent without the WAL.
+	w, err := NewWALReporter(mockReporter, &WALReporterOption{
+		Path:     filepath.Join(t.TempDir(), "autopprof.wal"),
+		MaxBytes: 1 << 10,
+	})
+	if err != nil {
+		t.Fatalf("NewWALReporter() = %v, want nil", err)
+	}
+	defer w.Close()
+
+	ctx := context.Background()
+	if err := w.ReportHeapProfile(
+		ctx, bytes.NewReader([]byte("heap1")), MemInfo{},
+	); !errors.Is(err, errReport) {
+		t.Fatalf("ReportHeapProfile() = %v, want %v", err, errReport)
+	}
+	if got := w.Pending(); got != 1 {
+		t.Fatalf("Pending() = %d, want 1", got)
+	}
+	// The failed one is retried after the next successful report.
+	if err := w.ReportHeapProfile(
+		ctx, bytes.NewReader([]byte("heap2")), MemInfo{},
+	); err != nil {
+		t.Fatalf("ReportHeapProfile() = %v, want nil", err)
+	}
+	if len(reported) != 2 || reported[0] != "heap2" || reported[1] != "heap1" {
+		t.Errorf("reported = %v, want [heap2 heap1]", reported)
+	}
+	if got := w.Pending(); got != 0 {
+		t.Errorf("Pending() = %d, want 0", got)
+	}
+}
+
+func TestWALReporter_Close(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	w, err := NewWALReporter(NewMockReporter(ctrl), &WALReporterOption{
+		Path: filepath.Join(t.TempDir(), "autopprof.wal"),
+	})
+	if err != nil {
+		t.Fatalf("NewWALReporter() = %v, want nil", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() = %v, want nil", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Close() again = %v, want nil", err)
+	}
+
+	ctx := context.Background()
+	if err := w.ReportCPUProfile(
+		ctx, bytes.NewReader([]byte("cpu")), CPUInfo{},
+	); !errors.Is(err, ErrWALClosed) {
+		t.Errorf("ReportCPUProfile() = %v, want %v", err, ErrWALClosed)
+	}
+	if err := w.Replay(ctx); !errors.Is(err, ErrWALClosed) {
+		t.Errorf("Replay() = %v, want %v", err, ErrWALClosed)
+	}
+}
@@ -0,0 +1,80 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// WriterReporter writes the raw profiling data to the writer, e.g.
+// the os.Stderr or a fifo, so a profile is retrieved by the docker logs
+// or the kubectl exec and read by the go tool pprof directly without
+// any backend. It's for the quick one-off captures by the CaptureNamed
+// in the development.
+//
+// The writer must be dedicated to the profiles, since the other output
+// corrupts them. The profiles are written whole one at a time, so
+// the concurrent captures don't interleave, and a profile failed to be
+// captured isn't written at all. To write only some kinds, wrap it by
+// the NewFilteredReporter.
+type WriterReporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterReporter returns the WriterReporter writing to the w.
+func NewWriterReporter(w io.Writer) *WriterReporter {
+	return &WriterReporter{
+		w: w,
+	}
+}
+
+// ReportCPUProfile writes the CPU profiling data.
+func (wr *WriterReporter) ReportCPUProfile(
+	_ context.Context, r io.Reader, _ CPUInfo,
+) error {
+	return wr.write(r)
+}
+
+// ReportHeapProfile writes the heap profiling data.
+func (wr *WriterReporter) ReportHeapProfile(
+	_ context.Context, r io.Reader, _ MemInfo,
+) error {
+	return wr.write(r)
+}
+
+// ReportGoroutineProfile writes the goroutine profiling data.
+func (wr *WriterReporter) ReportGoroutineProfile(
+	_ context.Context, r io.Reader, _ GoroutineInfo,
+) error {
+	return wr.write(r)
+}
+
+// ReportProfile writes the profiling data of the kind. The liveness
+// marker isn't written, since it has no profiling data.
+func (wr *WriterReporter) ReportProfile(
+	_ context.Context, r io.Reader, kind ProfileKind, _ ProfileInfo,
+) error {
+	if kind == ProfileKindLiveness {
+		return nil
+	}
+	return wr.write(r)
+}
+
+func (wr *WriterReporter) write(r io.Reader) error {
+	// Read the whole profile first, so the failed capture doesn't leave
+	//  the partial one in the writer.
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("autopprof: failed to read the profile: %w", err)
+	}
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	if _, err := wr.w.Write(b); err != nil {
+		return fmt.Errorf("autopprof: failed to write the profile: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWriterReporter(t *testing.T) {
+	var (
+		buf bytes.Buffer
+		w   = NewWriterReporter(&buf)
+		ctx = context.Background()
+	)
+	if err := w.ReportCPUProfile(ctx, strings.NewReader("cpu"), CPUInfo{}); err != nil {
+		t.Fatalf("ReportCPUProfile() = %v, want nil", err)
+	}
+	if err := w.ReportProfile(
+		ctx, strings.NewReader(""), ProfileKindLiveness, ProfileInfo{},
+	); err != nil {
+		t.Fatalf("ReportProfile() = %v, want nil", err)
+	}
+	if err := w.ReportProfile(
+		ctx, strings.NewReader("mutex"), ProfileKind("mutex"), ProfileInfo{Name: "mutex"},
+	); err != nil {
+		t.Fatalf("ReportProfile() = %v, want nil", err)
+	}
+	if got, want := buf.String(), "cpumutex"; got != want {
+		t.Errorf("written = %q, want %q", got, want)
+	}
+}
+
+func TestWriterReporter_failedCapture(t *testing.T) {
+	var (
+		buf       bytes.Buffer
+		w         = NewWriterReporter(&buf)
+		errRead   = errors.New("profiling failed")
+		partially = io.MultiReader(strings.NewReader("partial"), errReader{errRead})
+	)
+	if err := w.ReportHeapProfile(context.Background(), partially, MemInfo{}); !errors.Is(err, errRead) {
+		t.Errorf("ReportHeapProfile() = %v, want %v", err, errRead)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("written = %q, want nothing of the failed capture", buf.String())
+	}
+}
+
+func TestWriterReporter_concurrent(t *testing.T) {
+	var (
+		buf bytes.Buffer
+		w   = NewWriterReporter(&buf)
+		wg  sync.WaitGroup
+	)
+	profiles := []string{
+		strings.Repeat("a", 1<<16),
+		strings.Repeat("b", 1<<16),
+	}
+	for _, p := range profiles {
+		wg.Add(1)
+		go func(p string) {
+			defer wg.Done()
+			_ = w.ReportGoroutineProfile(context.Background(), strings.NewReader(p), GoroutineInfo{})
+		}(p)
+	}
+	wg.Wait()
+
+	got := buf.String()
+	if got != profiles[0]+profiles[1] && got != profiles[1]+profiles[0] {
+		t.Errorf("the profiles are interleaved")
+	}
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
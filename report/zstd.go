@@ -0,0 +1,183 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ContentEncoding is the encoding of the profiling data.
+type ContentEncoding string
+
+// Content encodings.
+const (
+	// ContentEncodingNone is the profile as it's captured. (gzipped protobuf)
+	ContentEncodingNone ContentEncoding = ""
+	// ContentEncodingZstd is the zstd compressed protobuf.
+	ContentEncodingZstd ContentEncoding = "zstd"
+)
+
+// Suffix returns the filename suffix of the encoding.
+func (e ContentEncoding) Suffix() string {
+	switch e {
+	case ContentEncodingZstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// ZstdReporter compresses the profiling data with zstd before passing
+// it to the inner reporter.
+// Since the captured profile is already gzipped, it's decompressed
+// first and the raw protobuf is compressed with zstd. It gives the
+// better ratio for the large heap profiles.
+type ZstdReporter struct {
+	inner Reporter
+	level zstd.EncoderLevel
+}
+
+// WithZstd returns the ZstdReporter wrapping the inner reporter.
+// The level is the zstd compression level between 1 and 22.
+// Zero means the default level.
+func WithZstd(inner Reporter, level int) *ZstdReporter {
+	l := zstd.SpeedDefault
+	if level > 0 {
+		l = zstd.EncoderLevelFromZstd(level)
+	}
+	return &ZstdReporter{
+		inner: inner,
+		level: l,
+	}
+}
+
+// Timeout returns the timeout of the inner reporter if it's
+// a TimeoutReporter. Otherwise, it returns zero.
+func (z *ZstdReporter) Timeout() time.Duration {
+	if tr, ok := z.inner.(TimeoutReporter); ok {
+		return tr.Timeout()
+	}
+	return 0
+}
+
+// Ping checks the inner reporter if it's a PingReporter.
+func (z *ZstdReporter) Ping(ctx context.Context) error {
+	if pr, ok := z.inner.(PingReporter); ok {
+		return pr.Ping(ctx)
+	}
+	return nil
+}
+
+// Flush flushes the inner reporter if it's a Flusher.
+func (z *ZstdReporter) Flush(ctx context.Context) error {
+	if f, ok := z.inner.(Flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+// ReportCPUProfile compresses the CPU profiling data and sends it to
+// the inner reporter.
+func (z *ZstdReporter) ReportCPUProfile(
+	ctx context.Context, r io.Reader, ci CPUInfo,
+) error {
+	cr, err := z.compress(r)
+	if err != nil {
+		return err
+	}
+	ci.ContentEncoding = ContentEncodingZstd
+	return z.inner.ReportCPUProfile(ctx, cr, ci)
+}
+
+// ReportHeapProfile compresses the heap profiling data and sends it to
+// the inner reporter.
+func (z *ZstdReporter) ReportHeapProfile(
+	ctx context.Context, r io.Reader, mi MemInfo,
+) error {
+	cr, err := z.compress(r)
+	if err != nil {
+		return err
+	}
+	mi.ContentEncoding = ContentEncodingZstd
+	return z.inner.ReportHeapProfile(ctx, cr, mi)
+}
+
+// ReportGoroutineProfile compresses the goroutine profiling data and
+// sends it to the inner reporter.
+func (z *ZstdReporter) ReportGoroutineProfile(
+	ctx context.Context, r io.Reader, gi GoroutineInfo,
+) error {
+	cr, err := z.compress(r)
+	if err != nil {
+		return err
+	}
+	gi.ContentEncoding = ContentEncodingZstd
+	return z.inner.ReportGoroutineProfile(ctx, cr, gi)
+}
+
+// ReportProfile compresses the profiling data of the kind and sends it
+// to the inner reporter. (See the ReportProfile function)
+// The liveness marker is sent as is, since it has no profiling data.
+func (z *ZstdReporter) ReportProfile(
+	ctx context.Context, r io.Reader, kind ProfileKind, pi ProfileInfo,
+) error {
+	if kind == ProfileKindLiveness {
+		return ReportProfile(ctx, z.inner, r, kind, pi)
+	}
+	cr, err := z.compress(r)
+	if err != nil {
+		return err
+	}
+	pi.ContentEncoding = ContentEncodingZstd
+	return ReportProfile(ctx, z.inner, cr, kind, pi)
+}
+
+func (z *ZstdReporter) compress(r io.Reader) (io.Reader, error) {
+	src, err := gunzipIfNeeded(r)
+	if err != nil {
+		return nil, fmt.Errorf("autopprof: failed to decompress the profile: %w", err)
+	}
+	var buf bytes.Buffer
+	enc, err := zstd.NewWriter(&buf, zstd.WithEncoderLevel(z.level))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(enc, src); err != nil {
+		enc.Close()
+		return nil, fmt.Errorf("autopprof: failed to compress the profile: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("autopprof: failed to compress the profile: %w", err)
+	}
+	return &buf, nil
+}
+
+// gunzipIfNeeded returns the reader of the decompressed data if the r
+// is gzipped. Otherwise, it returns the data as is.
+func gunzipIfNeeded(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(magic) < 2 || magic[0] != 0x1f || magic[1] != 0x8b {
+		return br, nil
+	}
+	return gzip.NewReader(br)
+}
+
+// DecompressZstd returns the reader of the profile compressed by
+// the ZstdReporter. The decompressed data is the raw protobuf of
+// the profile which the `go tool pprof` can read as is.
+func DecompressZstd(r io.Reader) (io.ReadCloser, error) {
+	dec, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return dec.IOReadCloser(), nil
+}
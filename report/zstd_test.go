@@ -0,0 +1,171 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/pprof/profile"
+)
+
+func TestZstdReporter_ReportHeapProfile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var heap bytes.Buffer
+	if err := pprof.WriteHeapProfile(&heap); err != nil {
+		t.Fatal(err)
+	}
+
+	var compressed []byte
+	mockReporter := NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), MemInfo{
+			ThresholdPercentage: 50,
+			UsagePercentage:     60,
+			ContentEncoding:     ContentEncodingZstd,
+		}).
+		DoAndReturn(
+			func(_ context.Context, r io.Reader, _ MemInfo) error {
+				b, err := io.ReadAll(r)
+				compressed = b
+				return err
+			},
+		)
+
+	z := WithZstd(mockReporter, 3)
+	if err := z.ReportHeapProfile(context.Background(), &heap, MemInfo{
+		ThresholdPercentage: 50,
+		UsagePercentage:     60,
+	}); err != nil {
+		t.Errorf("ReportHeapProfile() = %v, want nil", err)
+		t.FailNow()
+	}
+
+	rc, err := DecompressZstd(bytes.NewReader(compressed))
+	if err != nil {
+		t.Errorf("DecompressZstd() = %v, want nil", err)
+		t.FailNow()
+	}
+	defer rc.Close()
+	if _, err := profile.Parse(rc); err != nil {
+		t.Errorf("profile.Parse() = %v, want nil", err)
+	}
+}
+
+func TestContentEncoding_Suffix(t *testing.T) {
+	testCases := []struct {
+		encoding ContentEncoding
+		want     string
+	}{
+		{encoding: ContentEncodingNone, want: ""},
+		{encoding: ContentEncodingZstd, want: ".zst"},
+	}
+	for _, tc := range testCases {
+		if got := tc.encoding.Suffix(); got != tc.want {
+			t.Errorf("Suffix() of %q = %q, want %q", tc.encoding, got, tc.want)
+		}
+	}
+}
+
+func TestZstdReporter_Timeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	inner := NewMockTimeoutReporter(ctrl)
+	inner.EXPECT().Timeout().Return(time.Minute)
+	if got := WithZstd(inner, 0).Timeout(); got != time.Minute {
+		t.Errorf("Timeout() = %s, want %s", got, time.Minute)
+	}
+	if got := WithZstd(NewMockReporter(ctrl), 0).Timeout(); got != 0 {
+		t.Errorf("Timeout() = %s, want 0", got)
+	}
+}
+
+func TestZstdReporter_ReportProfile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var block bytes.Buffer
+	if err := pprof.Lookup("block").WriteTo(&block, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var compressed []byte
+	mockReporter := NewMockProfileReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportProfile(gomock.Any(), gomock.Any(), ProfileKindBlock, ProfileInfo{
+			TriggerID:       "id",
+			ContentEncoding: ContentEncodingZstd,
+		}).
+		DoAndReturn(
+			func(_ context.Context, r io.Reader, _ ProfileKind, _ ProfileInfo) error {
+				b, err := io.ReadAll(r)
+				compressed = b
+				return err
+			},
+		)
+	mockReporter.EXPECT().
+		ReportProfile(gomock.Any(), gomock.Any(), ProfileKindLiveness, ProfileInfo{
+			TriggerID: "id",
+		}).
+		Return(nil)
+
+	z := WithZstd(mockReporter, 0)
+	if err := z.ReportProfile(
+		context.Background(), &block, ProfileKindBlock, ProfileInfo{TriggerID: "id"},
+	); err != nil {
+		t.Errorf("ReportProfile() = %v, want nil", err)
+		t.FailNow()
+	}
+	rc, err := DecompressZstd(bytes.NewReader(compressed))
+	if err != nil {
+		t.Errorf("DecompressZstd() = %v, want nil", err)
+		t.FailNow()
+	}
+	defer rc.Close()
+	if _, err := profile.Parse(rc); err != nil {
+		t.Errorf("profile.Parse() = %v, want nil", err)
+	}
+
+	// The liveness marker has no profiling data to compress.
+	if err := z.ReportProfile(
+		context.Background(), bytes.NewReader(nil), ProfileKindLiveness, ProfileInfo{TriggerID: "id"},
+	); err != nil {
+		t.Errorf("ReportProfile() of the liveness = %v, want nil", err)
+	}
+}
+
+// flushReporter is the Reporter which buffers the reports.
+type flushReporter struct {
+	*MockReporter
+	*MockFlusher
+}
+
+func TestZstdReporter_PingFlush(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	errPing := errors.New("ping")
+	pinger := NewMockPingReporter(ctrl)
+	pinger.EXPECT().Ping(gomock.Any()).Return(errPing)
+	if err := WithZstd(pinger, 0).Ping(context.Background()); !errors.Is(err, errPing) {
+		t.Errorf("Ping() = %v, want %v", err, errPing)
+	}
+
+	errFlush := errors.New("flush")
+	flusher := flushReporter{NewMockReporter(ctrl), NewMockFlusher(ctrl)}
+	flusher.MockFlusher.EXPECT().Flush(gomock.Any()).Return(errFlush)
+	if err := WithZstd(flusher, 0).Flush(context.Background()); !errors.Is(err, errFlush) {
+		t.Errorf("Flush() = %v, want %v", err, errFlush)
+	}
+
+	z := WithZstd(NewMockReporter(ctrl), 0)
+	if err := z.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() = %v, want nil", err)
+	}
+	if err := z.Flush(context.Background()); err != nil {
+		t.Errorf("Flush() = %v, want nil", err)
+	}
+}
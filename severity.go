@@ -0,0 +1,47 @@
+package autopprof
+
+import (
+	"time"
+)
+
+// SeverityCooldown is the cooldown between the reports while the usage
+// is at or above the Usage. (See Option.SeverityBasedCooldown)
+type SeverityCooldown struct {
+	// Usage is the lower bound (between 0 and 1) of the usage band.
+	Usage float64 `json:"usage" yaml:"usage"`
+	// Cooldown is the minimum duration between the reports in the band.
+	Cooldown time.Duration `json:"cooldown" yaml:"cooldown"`
+}
+
+// validSeverityCooldowns reports whether the bands are monotonic, that
+// is, the usages increase and the cooldowns decrease.
+func validSeverityCooldowns(bands []SeverityCooldown) bool {
+	for i, b := range bands {
+		if b.Usage <= 0 || b.Usage > 1 || b.Cooldown <= 0 {
+			return false
+		}
+		if i == 0 {
+			continue
+		}
+		prev := bands[i-1]
+		if b.Usage <= prev.Usage || b.Cooldown >= prev.Cooldown {
+			return false
+		}
+	}
+	return true
+}
+
+// severityCooldown returns the cooldown of the highest band the usage
+// reaches. If the usage doesn't reach any band, it returns the base.
+func severityCooldown(
+	bands []SeverityCooldown, usage float64, base time.Duration,
+) time.Duration {
+	cooldown := base
+	for _, b := range bands {
+		if usage < b.Usage {
+			break
+		}
+		cooldown = b.Cooldown
+	}
+	return cooldown
+}
@@ -0,0 +1,87 @@
+package autopprof
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidSeverityCooldowns(t *testing.T) {
+	testCases := []struct {
+		name  string
+		bands []SeverityCooldown
+		want  bool
+	}{
+		{
+			name:  "nil",
+			bands: nil,
+			want:  true,
+		},
+		{
+			name: "monotonic",
+			bands: []SeverityCooldown{
+				{Usage: 0.75, Cooldown: 2 * time.Minute},
+				{Usage: 0.95, Cooldown: 30 * time.Second},
+			},
+			want: true,
+		},
+		{
+			name: "usages aren't increasing",
+			bands: []SeverityCooldown{
+				{Usage: 0.95, Cooldown: 2 * time.Minute},
+				{Usage: 0.75, Cooldown: 30 * time.Second},
+			},
+			want: false,
+		},
+		{
+			name: "cooldowns aren't decreasing",
+			bands: []SeverityCooldown{
+				{Usage: 0.75, Cooldown: 30 * time.Second},
+				{Usage: 0.95, Cooldown: 30 * time.Second},
+			},
+			want: false,
+		},
+		{
+			name: "usage is out of range",
+			bands: []SeverityCooldown{
+				{Usage: 1.5, Cooldown: 30 * time.Second},
+			},
+			want: false,
+		},
+		{
+			name: "cooldown is not positive",
+			bands: []SeverityCooldown{
+				{Usage: 0.75, Cooldown: 0},
+			},
+			want: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := validSeverityCooldowns(tc.bands); got != tc.want {
+				t.Errorf("validSeverityCooldowns() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSeverityCooldown(t *testing.T) {
+	bands := []SeverityCooldown{
+		{Usage: 0.75, Cooldown: 2 * time.Minute},
+		{Usage: 0.95, Cooldown: 30 * time.Second},
+	}
+	base := time.Minute
+	testCases := []struct {
+		usage float64
+		want  time.Duration
+	}{
+		{usage: 0.5, want: base},
+		{usage: 0.75, want: 2 * time.Minute},
+		{usage: 0.9, want: 2 * time.Minute},
+		{usage: 0.97, want: 30 * time.Second},
+	}
+	for _, tc := range testCases {
+		if got := severityCooldown(bands, tc.usage, base); got != tc.want {
+			t.Errorf("severityCooldown(%v) = %s, want %s", tc.usage, got, tc.want)
+		}
+	}
+}
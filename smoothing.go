@@ -0,0 +1,62 @@
+package autopprof
+
+import (
+	"sync"
+)
+
+// usageFilter smooths the usage readings by the exponential moving
+// average, so the momentary spikes don't flap around the threshold.
+// (See Option.UsageSmoothingAlpha) It keeps the last reading for
+// the Status. A nil usageFilter passes the readings through.
+type usageFilter struct {
+	// alpha is the weight of the new reading. 1 means no smoothing.
+	alpha float64
+
+	mu       sync.Mutex
+	raw      float64
+	smoothed float64
+	primed   bool
+}
+
+// newUsageFilter returns the usageFilter of the alpha. The zero alpha
+// means no smoothing.
+func newUsageFilter(alpha float64) *usageFilter {
+	if alpha == 0 {
+		alpha = 1
+	}
+	return &usageFilter{
+		alpha: alpha,
+	}
+}
+
+// update adds the reading and returns the smoothed usage.
+func (f *usageFilter) update(usage float64) float64 {
+	if f == nil {
+		return usage
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.raw = usage
+	if !f.primed {
+		// Start from the first reading rather than zero.
+		f.smoothed, f.primed = usage, true
+	} else {
+		f.smoothed = f.alpha*usage + (1-f.alpha)*f.smoothed
+	}
+	return f.smoothed
+}
+
+// status returns the last reading.
+func (f *usageFilter) status() UsageStatus {
+	if f == nil {
+		return UsageStatus{}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return UsageStatus{
+		Raw:      f.raw,
+		Smoothed: f.smoothed,
+	}
+}
@@ -0,0 +1,52 @@
+package autopprof
+
+import (
+	"math"
+	"testing"
+)
+
+func TestUsageFilter_update(t *testing.T) {
+	// A nil usageFilter passes the readings through.
+	var nilFilter *usageFilter
+	if got := nilFilter.update(0.8); got != 0.8 {
+		t.Errorf("update() of nil = %v, want 0.8", got)
+	}
+	if got := nilFilter.status(); got != (UsageStatus{}) {
+		t.Errorf("status() of nil = %+v, want zero", got)
+	}
+
+	testCases := []struct {
+		name     string
+		alpha    float64
+		readings []float64
+		want     []float64
+	}{
+		{
+			name:     "no smoothing",
+			alpha:    0,
+			readings: []float64{0.2, 0.9, 0.2},
+			want:     []float64{0.2, 0.9, 0.2},
+		},
+		{
+			name:     "smoothing",
+			alpha:    0.5,
+			readings: []float64{0.2, 0.9, 0.2, 0.2},
+			want:     []float64{0.2, 0.55, 0.375, 0.2875},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newUsageFilter(tc.alpha)
+			for i, reading := range tc.readings {
+				if got := f.update(reading); math.Abs(got-tc.want[i]) > 1e-9 {
+					t.Errorf("update(%v) = %v, want %v", reading, got, tc.want[i])
+				}
+			}
+			last := len(tc.readings) - 1
+			st := f.status()
+			if st.Raw != tc.readings[last] || math.Abs(st.Smoothed-tc.want[last]) > 1e-9 {
+				t.Errorf("status() = %+v, want {Raw:%v Smoothed:%v}", st, tc.readings[last], tc.want[last])
+			}
+		})
+	}
+}
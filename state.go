@@ -0,0 +1,159 @@
+package autopprof
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Kinds of the persisted report state.
+const (
+	stateKindCPU       = "cpu"
+	stateKindHeap      = "heap"
+	stateKindGoroutine = "goroutine"
+	stateKindGC        = "gc"
+	stateKindStartup   = "startup"
+	stateKindManual    = "manual"
+)
+
+// reportState is the state of the reporting persisted in the file.
+type reportState struct {
+	LastReportTimes map[string]time.Time `json:"last_report_times"`
+	ReportCounts    map[string]int       `json:"report_counts"`
+
+	// CPUBaseline is the learned baseline of the cpu usage.
+	CPUBaseline *baselineState `json:"cpu_baseline,omitempty"`
+}
+
+// baselineState is the expected usages per bucket of the usageBaseline.
+type baselineState struct {
+	Bucket   time.Duration `json:"bucket"`
+	Expected []float64     `json:"expected"`
+}
+
+// stateStore keeps the reportState in the file, so the cooldown of
+// the reporting survives the restarts of the process.
+// A nil store doesn't keep anything.
+type stateStore struct {
+	path string
+
+	mu    sync.Mutex
+	state reportState
+}
+
+// loadStateStore loads the state from the file.
+// If the file is missing or corrupted, it starts with the empty state.
+func loadStateStore(path string) *stateStore {
+	s := &stateStore{
+		path: path,
+		state: reportState{
+			LastReportTimes: make(map[string]time.Time),
+			ReportCounts:    make(map[string]int),
+		},
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s
+	}
+	if err != nil {
+		logger().Println(fmt.Errorf("autopprof: failed to read the state file: %w", err))
+		return s
+	}
+	var state reportState
+	if err := json.Unmarshal(b, &state); err != nil {
+		logger().Println(fmt.Errorf("autopprof: ignore the corrupted state file: %w", err))
+		return s
+	}
+	for k, v := range state.LastReportTimes {
+		s.state.LastReportTimes[k] = v
+	}
+	for k, v := range state.ReportCounts {
+		s.state.ReportCounts[k] = v
+	}
+	s.state.CPUBaseline = state.CPUBaseline
+	return s
+}
+
+// inCooldown reports whether the kind was reported within the cooldown.
+func (s *stateStore) inCooldown(kind string, cooldown time.Duration) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last, ok := s.state.LastReportTimes[kind]
+	return ok && time.Since(last) < cooldown
+}
+
+// recordReport records the report of the kind and saves the state.
+func (s *stateStore) recordReport(kind string, t time.Time) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state.LastReportTimes[kind] = t
+	s.state.ReportCounts[kind]++
+	return s.save()
+}
+
+// cpuBaseline returns the expected usages of the cpu baseline of
+// the bucket. It returns nil if the baseline of the bucket isn't saved,
+// so the baseline of the other bucket is relearned.
+func (s *stateStore) cpuBaseline(bucket time.Duration) []float64 {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state.CPUBaseline == nil || s.state.CPUBaseline.Bucket != bucket {
+		return nil
+	}
+	return s.state.CPUBaseline.Expected
+}
+
+// saveCPUBaseline saves the expected usages of the cpu baseline of
+// the bucket.
+func (s *stateStore) saveCPUBaseline(bucket time.Duration, expected []float64) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state.CPUBaseline = &baselineState{
+		Bucket:   bucket,
+		Expected: expected,
+	}
+	return s.save()
+}
+
+// save writes the state to the temporary file and renames it to
+// the state file, so the state file isn't corrupted by a crash.
+func (s *stateStore) save() error {
+	b, err := json.Marshal(s.state)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
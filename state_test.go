@@ -0,0 +1,75 @@
+package autopprof
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadStateStore(t *testing.T) {
+	dir := t.TempDir()
+
+	testCases := []struct {
+		name         string
+		content      string
+		wantCooldown bool
+	}{
+		{
+			name:         "missing file",
+			wantCooldown: false,
+		},
+		{
+			name:         "corrupted file",
+			content:      "{corrupted",
+			wantCooldown: false,
+		},
+		{
+			name: "reported recently",
+			content: `{"last_report_times":{"cpu":"` +
+				time.Now().Add(-time.Minute).Format(time.RFC3339Nano) + `"}}`,
+			wantCooldown: true,
+		},
+		{
+			name: "reported long ago",
+			content: `{"last_report_times":{"cpu":"` +
+				time.Now().Add(-time.Hour).Format(time.RFC3339Nano) + `"}}`,
+			wantCooldown: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name)
+			if tc.content != "" {
+				if err := os.WriteFile(path, []byte(tc.content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s := loadStateStore(path)
+			if got := s.inCooldown(stateKindCPU, 2*time.Minute); got != tc.wantCooldown {
+				t.Errorf("inCooldown() = %v, want %v", got, tc.wantCooldown)
+			}
+		})
+	}
+}
+
+func TestStateStore_recordReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "autopprof.state")
+
+	s := loadStateStore(path)
+	if err := s.recordReport(stateKindHeap, time.Now()); err != nil {
+		t.Errorf("recordReport() = %v, want nil", err)
+	}
+
+	// The restarted process must honor the cooldown.
+	restored := loadStateStore(path)
+	if !restored.inCooldown(stateKindHeap, time.Minute) {
+		t.Errorf("inCooldown() = false, want true")
+	}
+	if restored.inCooldown(stateKindCPU, time.Minute) {
+		t.Errorf("inCooldown() of other kind = true, want false")
+	}
+	if got := restored.state.ReportCounts[stateKindHeap]; got != 1 {
+		t.Errorf("report count = %d, want 1", got)
+	}
+}
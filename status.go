@@ -0,0 +1,157 @@
+package autopprof
+
+import "time"
+
+// StatusInfo is the runtime status of the autopprof.
+type StatusInfo struct {
+	// Running reports whether the autopprof is running.
+	Running bool
+
+	// Breaker is the status of the circuit breaker around the reporter.
+	Breaker BreakerStatus
+
+	// Cgroup is where the usages are read from.
+	Cgroup CgroupStatus
+
+	// CPUBudget is the status of the cpu profiling budget.
+	CPUBudget BudgetStatus
+
+	// InvalidProfiles is the number of the captured profiles skipped
+	//  since they failed to parse. It's zero unless
+	//  the Option.VerifyProfiles is set.
+	InvalidProfiles uint64
+
+	// Panics is the number of the panics of the goroutines of
+	//  the autopprof recovered. It's zero unless the Option.RecoverPanics
+	//  is set.
+	Panics uint64
+
+	// Incident is the status of the open incident. It's zero unless
+	//  the Option.IncidentWindow is set.
+	Incident IncidentStatus
+
+	// CPUSnapshots are the snapshots the cpu usage is computed from.
+	//  (See CPUSnapshots)
+	CPUSnapshots []CPUSnapshot
+
+	// CPUUsage and MemUsage are the last usages read by the watchers.
+	CPUUsage UsageStatus
+	MemUsage UsageStatus
+
+	// HeapThrottle is the status of the throttling of the heap
+	//  profiling. It's zero unless the Option.MaxHeapProfileSize is set.
+	HeapThrottle HeapThrottleStatus
+
+	// CPUBaseline is the expected and the actual cpu usage of
+	//  the current time-of-day bucket. It's zero unless
+	//  the Option.CPUBaselineDeviation is set.
+	CPUBaseline BaselineStatus
+}
+
+// CgroupStatus is where the autopprof reads the usages from.
+// It helps to diagnose the wrong usages.
+type CgroupStatus struct {
+	// Version is the resolved cgroup version. (1 or 2)
+	Version int
+	// Path is the resolved cgroup path of the process.
+	Path string
+
+	// CPUQuotaFiles are the files read to get the cpu quota.
+	CPUQuotaFiles []string
+	// CPUQuota is the parsed cpu quota in cores.
+	CPUQuota float64
+	// CPUUsageSource is where the cpu usage is read from.
+	CPUUsageSource string
+
+	// MemLimitSource is where the memory limit is read from.
+	MemLimitSource string
+
+	// GVisor reports whether the process runs in the gVisor sandbox,
+	//  whose cgroup files are virtualized by the sentry. The usages
+	//  may be approximate.
+	GVisor bool
+}
+
+// BreakerStatus is the status of the circuit breaker around the reporter.
+type BreakerStatus struct {
+	State BreakerState
+	// ConsecutiveFailures is the number of consecutive reporter failures.
+	ConsecutiveFailures int
+	// OpenedAt is the time when the breaker was opened last.
+	OpenedAt time.Time
+}
+
+// BudgetStatus is the status of the profiling budget.
+type BudgetStatus struct {
+	// Capacity is the profiling time allowed per hour.
+	//  Zero means the budget is disabled.
+	Capacity time.Duration
+	// Remaining is the profiling time left in the budget.
+	Remaining time.Duration
+	// Skipped is the number of the captures skipped since the budget
+	//  was exhausted.
+	Skipped int
+}
+
+// HeapThrottleStatus is the status of the throttling of the heap
+// profiling of the huge heap. (See Option.MaxHeapProfileSize)
+type HeapThrottleStatus struct {
+	// MaxSize is the heap size in bytes to throttle the profiling above.
+	MaxSize uint64
+	// Skipped and Downsampled are the numbers of the heap profiling
+	//  skipped and downsampled since the heap was over the MaxSize.
+	Skipped     uint64
+	Downsampled uint64
+}
+
+// BaselineStatus is the last observation of the usage baseline.
+// (See Option.CPUBaselineDeviation)
+type BaselineStatus struct {
+	// Bucket is the index of the time-of-day bucket, e.g. 13 for
+	//  13:00-14:00 of the one-hour buckets.
+	Bucket int
+	// Expected is the learned usage of the bucket. Zero means it isn't
+	//  learned yet, and the static threshold is used.
+	Expected float64
+	// Actual is the last usage observed.
+	Actual float64
+	// Threshold is the threshold the Actual was compared with.
+	Threshold float64
+}
+
+// IncidentStatus is the status of the incident the breaches are grouped
+// into. (See Option.IncidentWindow)
+type IncidentStatus struct {
+	// Open reports whether an incident is open, i.e. the last breach is
+	//  within the window.
+	Open bool
+	// ID is the report.CPUInfo.IncidentID of the profiles of it.
+	ID string
+	// OpenedAt and LastBreachAt are when the first and the last breaches
+	//  of it are reported.
+	OpenedAt     time.Time
+	LastBreachAt time.Time
+	// Reports is the number of the profiles reported in it.
+	Reports int
+}
+
+// CPUSnapshot is a snapshot of the cumulative cpu time of the cgroup.
+// The cpu usage is the delta of the usages between the oldest and
+// the newest snapshots over the duration between them, against
+// the cpu quota. So the snapshots help to verify the wrong cpu usages,
+// e.g. the clock or the counter anomalies.
+type CPUSnapshot struct {
+	// Timestamp is when the snapshot is taken.
+	Timestamp time.Time
+	// Usage is the cumulative cpu time read from the cgroup.
+	Usage time.Duration
+}
+
+// UsageStatus is the last usage read by the watcher.
+type UsageStatus struct {
+	// Raw is the usage read from the cgroup, and Smoothed is the one
+	//  compared with the thresholds. They're same unless
+	//  the Option.UsageSmoothingAlpha is set.
+	Raw      float64
+	Smoothed float64
+}
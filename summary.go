@@ -0,0 +1,191 @@
+package autopprof
+
+import (
+	"context"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/looko-corp/autopprof/report"
+)
+
+// defaultSummaryLogTemplate is the template of the summary log unless
+// the Option.SummaryLogTemplate is set. e.g.
+//
+//	autopprof: cpu report (usage 82.50% > 75.00%) sent 1234567 bytes in 340ms
+const defaultSummaryLogTemplate = `autopprof: {{.Kind}} report` +
+	`{{with .Trigger}} by {{.}}{{end}}` +
+	`{{if .ThresholdPercentage}} (usage {{printf "%.2f" .UsagePercentage}}% > {{printf "%.2f" .ThresholdPercentage}}%){{end}}` +
+	`{{if .Err}} failed after {{.Duration}}: {{.Err}}{{else}} sent {{.Bytes}} bytes in {{.Duration}}{{end}}`
+
+// ReportSummary is the data of the Option.SummaryLogTemplate.
+type ReportSummary struct {
+	// Kind is the kind of the profile.
+	Kind report.ProfileKind
+	// Trigger is what triggered the profile other than the usage
+	//  threshold. (See report.CPUInfo.Trigger)
+	Trigger string
+	// UsagePercentage and ThresholdPercentage are the usage and
+	//  the threshold of the trigger. They're zero if the trigger has
+	//  no threshold. (e.g. the named captures)
+	UsagePercentage     float64
+	ThresholdPercentage float64
+	// Bytes is the size of the profiling data read by the reporter.
+	Bytes int64
+	// Duration is how long the reporting took. It includes
+	//  the profiling for the streamed cpu profile.
+	Duration time.Duration
+	// Err is the error of the reporting. It's nil on the success.
+	Err error
+}
+
+// summaryReporter logs a one-line summary of each report of the inner
+// reporter, so the captures are easy to find in the logs during
+// the triage.
+type summaryReporter struct {
+	inner report.Reporter
+	tmpl  *template.Template
+}
+
+func newSummaryReporter(inner report.Reporter, text string) (*summaryReporter, error) {
+	if text == "" {
+		text = defaultSummaryLogTemplate
+	}
+	tmpl, err := template.New("summary").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &summaryReporter{
+		inner: inner,
+		tmpl:  tmpl,
+	}, nil
+}
+
+// CanStream reports whether the inner reporter can consume the stream.
+func (s *summaryReporter) CanStream() bool {
+	sr, ok := s.inner.(report.StreamReporter)
+	return ok && sr.CanStream()
+}
+
+// Timeout returns the timeout of the inner reporter if it's
+// a report.TimeoutReporter. Otherwise, it returns zero.
+func (s *summaryReporter) Timeout() time.Duration {
+	if tr, ok := s.inner.(report.TimeoutReporter); ok {
+		return tr.Timeout()
+	}
+	return 0
+}
+
+// Ping checks the inner reporter if it's a report.PingReporter.
+func (s *summaryReporter) Ping(ctx context.Context) error {
+	if pr, ok := s.inner.(report.PingReporter); ok {
+		return pr.Ping(ctx)
+	}
+	return nil
+}
+
+// Flush flushes the inner reporter if it's a report.Flusher.
+func (s *summaryReporter) Flush(ctx context.Context) error {
+	if f, ok := s.inner.(report.Flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+func (s *summaryReporter) ReportCPUProfile(
+	ctx context.Context, r io.Reader, ci report.CPUInfo,
+) error {
+	cr := &countingReader{r: r}
+	start := time.Now()
+	err := s.inner.ReportCPUProfile(ctx, cr, ci)
+	s.log(ReportSummary{
+		Kind:                report.ProfileKindCPU,
+		Trigger:             ci.Trigger,
+		UsagePercentage:     ci.UsagePercentage,
+		ThresholdPercentage: ci.ThresholdPercentage,
+		Bytes:               cr.n,
+		Duration:            time.Since(start),
+		Err:                 err,
+	})
+	return err
+}
+
+func (s *summaryReporter) ReportHeapProfile(
+	ctx context.Context, r io.Reader, mi report.MemInfo,
+) error {
+	cr := &countingReader{r: r}
+	start := time.Now()
+	err := s.inner.ReportHeapProfile(ctx, cr, mi)
+	s.log(ReportSummary{
+		Kind:                report.ProfileKindHeap,
+		Trigger:             mi.Trigger,
+		UsagePercentage:     mi.UsagePercentage,
+		ThresholdPercentage: mi.ThresholdPercentage,
+		Bytes:               cr.n,
+		Duration:            time.Since(start),
+		Err:                 err,
+	})
+	return err
+}
+
+func (s *summaryReporter) ReportGoroutineProfile(
+	ctx context.Context, r io.Reader, gi report.GoroutineInfo,
+) error {
+	cr := &countingReader{r: r}
+	start := time.Now()
+	err := s.inner.ReportGoroutineProfile(ctx, cr, gi)
+	s.log(ReportSummary{
+		Kind:                report.ProfileKindGoroutine,
+		Trigger:             gi.Trigger,
+		UsagePercentage:     gi.UsagePercentage,
+		ThresholdPercentage: gi.ThresholdPercentage,
+		Bytes:               cr.n,
+		Duration:            time.Since(start),
+		Err:                 err,
+	})
+	return err
+}
+
+// ReportProfile sends the profiling data of the kind to the inner
+// reporter. (See the report.ReportProfile function) The liveness marker
+// isn't logged, since it's not a capture.
+func (s *summaryReporter) ReportProfile(
+	ctx context.Context, r io.Reader, kind report.ProfileKind, pi report.ProfileInfo,
+) error {
+	if kind == report.ProfileKindLiveness {
+		return report.ReportProfile(ctx, s.inner, r, kind, pi)
+	}
+	cr := &countingReader{r: r}
+	start := time.Now()
+	err := report.ReportProfile(ctx, s.inner, cr, kind, pi)
+	s.log(ReportSummary{
+		Kind:     kind,
+		Bytes:    cr.n,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+	return err
+}
+
+func (s *summaryReporter) log(summary ReportSummary) {
+	summary.Duration = summary.Duration.Round(time.Millisecond)
+	var b strings.Builder
+	if err := s.tmpl.Execute(&b, summary); err != nil {
+		logger().Printf("autopprof: failed to format the summary log: %v", err)
+		return
+	}
+	logger().Println(b.String())
+}
+
+// countingReader counts the bytes read from the reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
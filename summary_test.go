@@ -0,0 +1,84 @@
+package autopprof
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/looko-corp/autopprof/report"
+)
+
+func TestSummaryReporter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	buf := captureLog(t)
+
+	errReport := errors.New("upload failed")
+	mockReporter := report.NewMockReporter(ctrl)
+	gomock.InOrder(
+		mockReporter.EXPECT().
+			ReportCPUProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, r io.Reader, _ report.CPUInfo) error {
+				_, err := io.ReadAll(r)
+				return err
+			}),
+		mockReporter.EXPECT().
+			ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(errReport),
+	)
+
+	s, err := newSummaryReporter(mockReporter, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ci := report.CPUInfo{UsagePercentage: 82.5, ThresholdPercentage: 75}
+	if err := s.ReportCPUProfile(context.Background(), strings.NewReader("profile"), ci); err != nil {
+		t.Errorf("ReportCPUProfile() = %v, want nil", err)
+	}
+	mi := report.MemInfo{Trigger: report.TriggerNUMA}
+	if err := s.ReportHeapProfile(context.Background(), strings.NewReader("profile"), mi); !errors.Is(err, errReport) {
+		t.Errorf("ReportHeapProfile() = %v, want %v", err, errReport)
+	}
+
+	got := buf.String()
+	for _, want := range []string{
+		"autopprof: cpu report (usage 82.50% > 75.00%) sent 7 bytes in",
+		"autopprof: heap report by numa failed after",
+		"upload failed",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("summary log = %q, want to contain %q", got, want)
+		}
+	}
+}
+
+func TestSummaryReporter_template(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	buf := captureLog(t)
+
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportGoroutineProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+
+	s, err := newSummaryReporter(mockReporter, "captured {{.Kind}} {{.Trigger}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gi := report.GoroutineInfo{Trigger: report.TriggerFD}
+	if err := s.ReportGoroutineProfile(context.Background(), strings.NewReader(""), gi); err != nil {
+		t.Errorf("ReportGoroutineProfile() = %v, want nil", err)
+	}
+	if want := "captured goroutine fd"; !strings.Contains(buf.String(), want) {
+		t.Errorf("summary log = %q, want to contain %q", buf.String(), want)
+	}
+
+	if _, err := newSummaryReporter(mockReporter, "{{.Kind"); err == nil {
+		t.Errorf("newSummaryReporter() = nil, want the error of the invalid template")
+	}
+}
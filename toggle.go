@@ -0,0 +1,118 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import "fmt"
+
+// EnableCPUProfiling enables or disables the cpu profiling of the global
+// autopprof process at runtime, e.g. to turn it off while it's too
+// expensive without a restart. The cpu watcher stops on the disable,
+// and starts again on the enable. Enabling reads the cpu quota again,
+// and returns its error if it's not set.
+// It returns ErrNotStarted if the autopprof isn't started or is
+// stopped.
+func EnableCPUProfiling(enabled bool) error {
+	ap := running()
+	if ap == nil {
+		return ErrNotStarted
+	}
+	return ap.enableCPUProf(enabled)
+}
+
+// EnableMemProfiling enables or disables the heap profiling of
+// the global autopprof process at runtime. (See EnableCPUProfiling)
+// It returns ErrNotStarted if the autopprof isn't started or is
+// stopped.
+func EnableMemProfiling(enabled bool) error {
+	ap := running()
+	if ap == nil {
+		return ErrNotStarted
+	}
+	ap.enableMemProf(enabled)
+	return nil
+}
+
+// cpuProfDisabled reports whether the cpu profiling is disabled now.
+func (ap *autoPprof) cpuProfDisabled() bool {
+	ap.profMu.RLock()
+	defer ap.profMu.RUnlock()
+	return ap.disableCPUProf
+}
+
+// memProfDisabled reports whether the heap profiling is disabled now.
+func (ap *autoPprof) memProfDisabled() bool {
+	ap.profMu.RLock()
+	defer ap.profMu.RUnlock()
+	return ap.disableMemProf
+}
+
+func (ap *autoPprof) enableCPUProf(enabled bool) error {
+	if enabled && ap.cpuProfDisabled() {
+		// The quota may be missing at the start, or changed since.
+		if err := ap.queryer.setCPUQuota(); err != nil {
+			return fmt.Errorf("autopprof: failed to enable the cpu profiling: %w", err)
+		}
+		ap.limits.setCPUQuota(ap.queryer.status().CPUQuota)
+		ap.resizeCPUSnapshots()
+	}
+	ap.setProfDisabled(&ap.disableCPUProf, !enabled)
+	return nil
+}
+
+func (ap *autoPprof) enableMemProf(enabled bool) {
+	ap.setProfDisabled(&ap.disableMemProf, !enabled)
+}
+
+// setProfDisabled sets the flag, and wakes up the watchers waiting for
+// the enable.
+func (ap *autoPprof) setProfDisabled(flag *bool, disabled bool) {
+	ap.profMu.Lock()
+	defer ap.profMu.Unlock()
+
+	if *flag == disabled {
+		return
+	}
+	*flag = disabled
+	if ap.profChanged != nil {
+		close(ap.profChanged)
+		ap.profChanged = nil
+	}
+}
+
+// awaitProfEnabled blocks until the profiling isn't disabled. It
+// returns false if the autopprof is stopped first.
+func (ap *autoPprof) awaitProfEnabled(disabled func() bool) bool {
+	for {
+		ap.profMu.Lock()
+		if ap.profChanged == nil {
+			ap.profChanged = make(chan struct{})
+		}
+		changed := ap.profChanged
+		ap.profMu.Unlock()
+
+		if !disabled() {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-ap.stopC:
+			return false
+		}
+	}
+}
+
+// watchWhileEnabled runs the watch of the watcher while the profiling
+// isn't disabled. The watch returns on the disable, and runs again on
+// the enable. If it returns for any other reason, the watcher stops
+// as before.
+func (ap *autoPprof) watchWhileEnabled(name string, disabled func() bool, watch func()) {
+	for ap.awaitProfEnabled(disabled) {
+		watch()
+		if !disabled() {
+			return
+		}
+		// It's paused, not stopped, so it doesn't degrade the health.
+		ap.watchers.remove(name)
+	}
+}
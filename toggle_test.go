@@ -0,0 +1,98 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestAutoPprof_enableMemProf(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var queried int64
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		memUsage().
+		AnyTimes().
+		DoAndReturn(
+			func() (*memStat, error) {
+				atomic.AddInt64(&queried, 1)
+				return &memStat{usage: 1, limit: 100}, nil
+			},
+		)
+
+	ap := &autoPprof{
+		disableCPUProf: true,
+		disableMemProf: true,
+		watchInterval:  10 * time.Millisecond,
+		memThreshold:   0.9,
+		queryer:        mockQueryer,
+		watchers:       newWatcherHealth(),
+		stopC:          make(chan struct{}),
+	}
+	done := make(chan struct{})
+	go func() {
+		ap.watchMemUsage()
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt64(&queried); n != 0 {
+		t.Fatalf("the memory usage is queried %d times while disabled", n)
+	}
+
+	ap.enableMemProf(true)
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt64(&queried); n == 0 {
+		t.Fatalf("the memory usage isn't queried after the enable")
+	}
+
+	ap.enableMemProf(false)
+	time.Sleep(30 * time.Millisecond)
+	n := atomic.LoadInt64(&queried)
+	time.Sleep(50 * time.Millisecond)
+	if got := atomic.LoadInt64(&queried); got != n {
+		t.Errorf("the memory usage is queried %d times after the disable", got-n)
+	}
+	if _, ok := ap.watchers.snapshot()[watcherMem]; ok {
+		t.Errorf("the paused mem watcher is tracked by the health")
+	}
+
+	// The stop closes the paused watcher.
+	close(ap.stopC)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("watchMemUsage() doesn't return after the stop")
+	}
+}
+
+func TestAutoPprof_enableCPUProf_noQuota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	errNoQuota := errors.New("no cpu quota")
+	mockQueryer := NewMockqueryer(ctrl)
+	mockQueryer.EXPECT().
+		setCPUQuota().
+		Return(errNoQuota)
+
+	ap := &autoPprof{
+		disableCPUProf: true,
+		queryer:        mockQueryer,
+	}
+	if err := ap.enableCPUProf(true); !errors.Is(err, errNoQuota) {
+		t.Errorf("enableCPUProf() = %v, want %v", err, errNoQuota)
+	}
+	if !ap.cpuProfDisabled() {
+		t.Errorf("the cpu profiling is enabled without the quota")
+	}
+	if err := ap.enableCPUProf(false); err != nil {
+		t.Errorf("enableCPUProf(false) = %v, want nil", err)
+	}
+}
@@ -0,0 +1,81 @@
+package autopprof
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/looko-corp/autopprof/report"
+)
+
+// topFunctions parses the profile and returns the top n functions
+// sorted by the flat value of the last sample type.
+// (e.g. cpu nanoseconds for the cpu profile)
+func topFunctions(b []byte, n int) ([]report.FunctionStat, error) {
+	p, err := profile.Parse(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	if len(p.SampleType) == 0 {
+		return nil, nil
+	}
+	idx := len(p.SampleType) - 1
+
+	var (
+		total int64
+		stats = make(map[string]*report.FunctionStat)
+	)
+	stat := func(name string) *report.FunctionStat {
+		s, ok := stats[name]
+		if !ok {
+			s = &report.FunctionStat{Name: name}
+			stats[name] = s
+		}
+		return s
+	}
+	for _, s := range p.Sample {
+		v := s.Value[idx]
+		total += v
+
+		// The first line of the first location is the leaf function.
+		seen := make(map[string]bool)
+		for i, loc := range s.Location {
+			for j, line := range loc.Line {
+				if line.Function == nil {
+					continue
+				}
+				name := line.Function.Name
+				if i == 0 && j == 0 {
+					stat(name).Flat += v
+				}
+				if !seen[name] {
+					seen[name] = true
+					stat(name).Cum += v
+				}
+			}
+		}
+	}
+
+	top := make([]report.FunctionStat, 0, len(stats))
+	for _, s := range stats {
+		if total > 0 {
+			s.FlatPercentage = float64(s.Flat) / float64(total) * 100
+			s.CumPercentage = float64(s.Cum) / float64(total) * 100
+		}
+		top = append(top, *s)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Flat != top[j].Flat {
+			return top[i].Flat > top[j].Flat
+		}
+		if top[i].Cum != top[j].Cum {
+			return top[i].Cum > top[j].Cum
+		}
+		return top[i].Name < top[j].Name
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top, nil
+}
@@ -0,0 +1,71 @@
+package autopprof
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/looko-corp/autopprof/report"
+)
+
+// newTestCPUProfile returns the cpu profile whose samples are
+// main -> handle -> parse (30), main -> handle (10) and main -> gc (60).
+func newTestCPUProfile(t *testing.T) []byte {
+	t.Helper()
+
+	var (
+		fnMain   = &profile.Function{ID: 1, Name: "main.main"}
+		fnHandle = &profile.Function{ID: 2, Name: "main.handle"}
+		fnParse  = &profile.Function{ID: 3, Name: "main.parse"}
+		fnGC     = &profile.Function{ID: 4, Name: "runtime.gc"}
+
+		locMain   = &profile.Location{ID: 1, Line: []profile.Line{{Function: fnMain}}}
+		locHandle = &profile.Location{ID: 2, Line: []profile.Line{{Function: fnHandle}}}
+		locParse  = &profile.Location{ID: 3, Line: []profile.Line{{Function: fnParse}}}
+		locGC     = &profile.Location{ID: 4, Line: []profile.Line{{Function: fnGC}}}
+	)
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		Sample: []*profile.Sample{
+			{Location: []*profile.Location{locParse, locHandle, locMain}, Value: []int64{3, 30}},
+			{Location: []*profile.Location{locHandle, locMain}, Value: []int64{1, 10}},
+			{Location: []*profile.Location{locGC, locMain}, Value: []int64{6, 60}},
+		},
+		Location: []*profile.Location{locMain, locHandle, locParse, locGC},
+		Function: []*profile.Function{fnMain, fnHandle, fnParse, fnGC},
+	}
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestTopFunctions(t *testing.T) {
+	b := newTestCPUProfile(t)
+
+	top, err := topFunctions(b, 3)
+	if err != nil {
+		t.Errorf("topFunctions() = %v, want nil", err)
+		t.FailNow()
+	}
+	want := []report.FunctionStat{
+		{Name: "runtime.gc", Flat: 60, FlatPercentage: 60, Cum: 60, CumPercentage: 60},
+		{Name: "main.parse", Flat: 30, FlatPercentage: 30, Cum: 30, CumPercentage: 30},
+		{Name: "main.handle", Flat: 10, FlatPercentage: 10, Cum: 40, CumPercentage: 40},
+	}
+	if !reflect.DeepEqual(top, want) {
+		t.Errorf("topFunctions() = %+v, want %+v", top, want)
+	}
+}
+
+func TestTopFunctions_invalidProfile(t *testing.T) {
+	if _, err := topFunctions([]byte("invalid"), 3); err == nil {
+		t.Errorf("topFunctions() = nil, want error")
+	}
+}
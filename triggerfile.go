@@ -0,0 +1,186 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/looko-corp/autopprof/report"
+)
+
+// triggerFileDebounce is the minimum interval between the captures of
+// the same trigger file, so the rapid touches capture once.
+const triggerFileDebounce = 5 * time.Second
+
+// triggerFileMask is the inotify events of the trigger file. The touch
+// closes the created or the existing file, and the mv moves it.
+const triggerFileMask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO
+
+// triggerFileWatcher watches the directory of the trigger file path by
+// the inotify, and returns the kinds of the touched trigger files.
+// The trigger file of a kind is the path suffixed with "-<kind>".
+// (e.g. /tmp/autopprof-capture-cpu for the /tmp/autopprof-capture)
+type triggerFileWatcher struct {
+	// prefix is the base name of the path with the "-".
+	prefix string
+	dir    string
+	f      *os.File
+
+	mu        sync.Mutex
+	debounces map[string]*debounce
+}
+
+func newTriggerFileWatcher(path string) (*triggerFileWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("autopprof: failed to init the inotify: %w", err)
+	}
+	dir := filepath.Dir(path)
+	if _, err := syscall.InotifyAddWatch(fd, dir, triggerFileMask); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("autopprof: failed to watch the trigger file directory %s: %w", dir, err)
+	}
+	return &triggerFileWatcher{
+		prefix: filepath.Base(path) + "-",
+		dir:    dir,
+		// The non-blocking fd is polled by the runtime, so the Close
+		//  unblocks the Read.
+		f:         os.NewFile(uintptr(fd), "inotify"),
+		debounces: make(map[string]*debounce),
+	}, nil
+}
+
+// read blocks until the trigger files are touched, and returns their
+// kinds. The trigger files are removed, and the touches within
+// the triggerFileDebounce of the last capture of the kind are ignored.
+func (w *triggerFileWatcher) read() ([]string, error) {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	n, err := w.f.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	var kinds []string
+	for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+		ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameStart := offset + syscall.SizeofInotifyEvent
+		name := string(bytes.TrimRight(buf[nameStart:nameStart+int(ev.Len)], "\x00"))
+		offset = nameStart + int(ev.Len)
+
+		kind := strings.TrimPrefix(name, w.prefix)
+		if kind == name || kind == "" {
+			continue
+		}
+		// Consume the trigger file, so the operator sees it's handled.
+		_ = os.Remove(filepath.Join(w.dir, name))
+		if !w.debounce(kind).allow(time.Now()) {
+			continue
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+func (w *triggerFileWatcher) debounce(kind string) *debounce {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	d, ok := w.debounces[kind]
+	if !ok {
+		d = newDebounce(triggerFileDebounce)
+		w.debounces[kind] = d
+	}
+	return d
+}
+
+// close stops the watch.
+func (w *triggerFileWatcher) close() error {
+	return w.f.Close()
+}
+
+// watchTriggerFile captures the profiles of the trigger files touched
+// by the operator until the stop.
+func (ap *autoPprof) watchTriggerFile() {
+	if ap.triggerFile == nil {
+		return
+	}
+	go func() {
+		<-ap.stopC
+		if err := ap.triggerFile.close(); err != nil {
+			logger().Println(fmt.Errorf(
+				"autopprof: failed to close the trigger file watch: %w", err,
+			))
+		}
+	}()
+	for {
+		kinds, err := ap.triggerFile.read()
+		if err != nil {
+			select {
+			case <-ap.stopC:
+			default:
+				logger().Println(fmt.Errorf(
+					"autopprof: failed to watch the trigger file: %w", err,
+				))
+			}
+			return
+		}
+		for _, kind := range kinds {
+			if err := ap.captureManual(kind); err != nil {
+				logger().Println(fmt.Errorf(
+					"autopprof: failed to report the %s profile of the trigger file: %w", kind, err,
+				))
+			}
+		}
+	}
+}
+
+// captureManual captures and reports the profile of the kind requested
+// by the operator regardless of the thresholds. The cpu, heap and
+// goroutine profiles are marked with the report.TriggerManual, and
+// the other kinds are captured by the captureNamed.
+func (ap *autoPprof) captureManual(kind string) error {
+	// Don't waste the profiling if the reporter is unavailable.
+	releaseTrial, ok := ap.breaker.acquire()
+	if !ok {
+		ap.stats.drop()
+		return nil
+	}
+	defer releaseTrial()
+	switch report.ProfileKind(kind) {
+	case report.ProfileKindCPU:
+		// The cpu usage is read by the cpu watcher only.
+		ci := ap.cpuInfo(0, 0)
+		ci.Trigger = report.TriggerManual
+		return ap.captureAppTriggeredCPUProfile(context.Background(), ci)
+	case report.ProfileKindHeap:
+		b, err := ap.profileHeap()
+		if err != nil {
+			return err
+		}
+		mi := report.MemInfo{
+			SchemaVersion:     report.SchemaVersion,
+			Labels:            ap.labels,
+			Trigger:           report.TriggerManual,
+			PrimarySampleType: ap.heapPrimarySampleType,
+		}
+		// It doesn't start the cooldown of the heap profiling by the usage.
+		return ap.recordReport(stateKindManual, ap.reportHeap(b, mi))
+	case report.ProfileKindGoroutine:
+		return ap.sendGoroutineProfile(context.Background(), report.GoroutineInfo{
+			SchemaVersion: report.SchemaVersion,
+			Labels:        ap.labels,
+			Trigger:       report.TriggerManual,
+		})
+	}
+	return ap.captureNamed(kind)
+}
@@ -0,0 +1,130 @@
+//go:build linux
+// +build linux
+
+package autopprof
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/looko-corp/autopprof/report"
+)
+
+func touch(t *testing.T, path string) {
+	t.Helper()
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTriggerFileWatcher(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "autopprof-capture")
+
+	w, err := newTriggerFileWatcher(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = w.close() })
+
+	touch(t, path+"-heap")
+	touch(t, path+"-goroutine")
+	touch(t, filepath.Join(dir, "unrelated"))
+
+	var got []string
+	for len(got) < 2 {
+		_ = w.f.SetReadDeadline(time.Now().Add(time.Second))
+		kinds, err := w.read()
+		if err != nil {
+			t.Fatalf("read() = %v, want the kinds", err)
+		}
+		got = append(got, kinds...)
+	}
+	sort.Strings(got)
+	if want := []string{"goroutine", "heap"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("read() = %v, want %v", got, want)
+	}
+	if _, err := os.Stat(path + "-heap"); !os.IsNotExist(err) {
+		t.Errorf("the trigger file isn't removed: %v", err)
+	}
+
+	// The touch of the heap again is debounced.
+	touch(t, path+"-heap")
+	touch(t, path+"-mutex")
+	got = nil
+	for len(got) < 1 {
+		_ = w.f.SetReadDeadline(time.Now().Add(time.Second))
+		kinds, err := w.read()
+		if err != nil {
+			t.Fatalf("read() = %v, want the kinds", err)
+		}
+		got = append(got, kinds...)
+	}
+	if want := []string{"mutex"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("read() = %v, want %v", got, want)
+	}
+}
+
+func TestAutoPprof_watchTriggerFile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	path := filepath.Join(t.TempDir(), "autopprof-capture")
+	w, err := newTriggerFileWatcher(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockProfiler := NewMockprofiler(ctrl)
+	mockProfiler.EXPECT().
+		profileHeap().
+		Return([]byte("prof"), nil)
+
+	reported := make(chan report.MemInfo, 1)
+	mockReporter := report.NewMockReporter(ctrl)
+	mockReporter.EXPECT().
+		ReportHeapProfile(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(
+			func(_ context.Context, _ io.Reader, mi report.MemInfo) error {
+				reported <- mi
+				return nil
+			},
+		)
+
+	ap := &autoPprof{
+		profiler:    mockProfiler,
+		reporter:    mockReporter,
+		triggerFile: w,
+		stopC:       make(chan struct{}),
+	}
+	done := make(chan struct{})
+	go func() {
+		ap.watchTriggerFile()
+		close(done)
+	}()
+
+	touch(t, path+"-heap")
+	select {
+	case mi := <-reported:
+		if mi.Trigger != report.TriggerManual {
+			t.Errorf("MemInfo.Trigger = %q, want %q", mi.Trigger, report.TriggerManual)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("the heap profile of the trigger file isn't reported")
+	}
+
+	// The stop closes the watch.
+	close(ap.stopC)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("watchTriggerFile() doesn't return after the stop")
+	}
+}
@@ -0,0 +1,71 @@
+package autopprof
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/google/pprof/profile"
+)
+
+// verifyingProfiler parses the profiles captured by the inner profiler,
+// so the truncated or corrupted ones (e.g. by the disrupted capture)
+// aren't reported. The invalid profile is returned with
+// ErrInvalidProfile and counted.
+// The goroutine dump isn't verified, since it's a text. Neither is
+// the streamed cpu profile, so the streaming is disabled with it.
+type verifyingProfiler struct {
+	profiler
+
+	// invalid is the number of the invalid profiles.
+	invalid uint64
+}
+
+func newVerifyingProfiler(inner profiler) *verifyingProfiler {
+	return &verifyingProfiler{
+		profiler: inner,
+	}
+}
+
+func (p *verifyingProfiler) profileCPU() ([]byte, error) {
+	return p.verify(p.profiler.profileCPU())
+}
+
+func (p *verifyingProfiler) profileHeap() ([]byte, error) {
+	return p.verify(p.profiler.profileHeap())
+}
+
+func (p *verifyingProfiler) profileGoroutine() ([]byte, error) {
+	return p.verify(p.profiler.profileGoroutine())
+}
+
+func (p *verifyingProfiler) profileBlock() ([]byte, error) {
+	return p.verify(p.profiler.profileBlock())
+}
+
+func (p *verifyingProfiler) profileMutex() ([]byte, error) {
+	return p.verify(p.profiler.profileMutex())
+}
+
+func (p *verifyingProfiler) profileWallClock() ([]byte, error) {
+	return p.verify(p.profiler.profileWallClock())
+}
+
+func (p *verifyingProfiler) profileNamed(name string) ([]byte, error) {
+	return p.verify(p.profiler.profileNamed(name))
+}
+
+// invalidCount returns the number of the invalid profiles.
+func (p *verifyingProfiler) invalidCount() uint64 {
+	return atomic.LoadUint64(&p.invalid)
+}
+
+func (p *verifyingProfiler) verify(b []byte, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	if _, err := profile.ParseData(b); err != nil {
+		atomic.AddUint64(&p.invalid, 1)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProfile, err)
+	}
+	return b, nil
+}
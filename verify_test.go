@@ -0,0 +1,107 @@
+package autopprof
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestVerifyingProfiler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	valid, err := newDefaultProfiler(defaultCPUProfilingDuration).profileHeap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	errProfiling := errors.New("profiling failed")
+
+	mockProfiler := NewMockprofiler(ctrl)
+	gomock.InOrder(
+		mockProfiler.EXPECT().profileHeap().Return(valid, nil),
+		// Truncated as if the capture was interrupted.
+		mockProfiler.EXPECT().profileHeap().Return(valid[:len(valid)/2], nil),
+		mockProfiler.EXPECT().profileHeap().Return(nil, errProfiling),
+	)
+
+	p := newVerifyingProfiler(mockProfiler)
+	if _, err := p.profileHeap(); err != nil {
+		t.Errorf("profileHeap() = %v, want nil for the valid profile", err)
+	}
+	if _, err := p.profileHeap(); !errors.Is(err, ErrInvalidProfile) {
+		t.Errorf("profileHeap() = %v, want %v for the truncated profile", err, ErrInvalidProfile)
+	}
+	// The failure of the capture isn't the invalid profile.
+	if _, err := p.profileHeap(); !errors.Is(err, errProfiling) {
+		t.Errorf("profileHeap() = %v, want %v", err, errProfiling)
+	}
+	if got := p.invalidCount(); got != 1 {
+		t.Errorf("invalidCount() = %d, want 1", got)
+	}
+}
+
+func TestVerifyingProfiler_blockMutex(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	dp := newDefaultProfiler(defaultCPUProfilingDuration)
+	block, err := dp.profileBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mutex, err := dp.profileMutex()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockProfiler := NewMockprofiler(ctrl)
+	gomock.InOrder(
+		mockProfiler.EXPECT().profileBlock().Return(block, nil),
+		mockProfiler.EXPECT().profileBlock().Return(block[:len(block)/2], nil),
+		mockProfiler.EXPECT().profileMutex().Return(mutex, nil),
+		mockProfiler.EXPECT().profileMutex().Return(mutex[:len(mutex)/2], nil),
+	)
+
+	p := newVerifyingProfiler(mockProfiler)
+	if _, err := p.profileBlock(); err != nil {
+		t.Errorf("profileBlock() = %v, want nil for the valid profile", err)
+	}
+	if _, err := p.profileBlock(); !errors.Is(err, ErrInvalidProfile) {
+		t.Errorf("profileBlock() = %v, want %v for the truncated profile", err, ErrInvalidProfile)
+	}
+	if _, err := p.profileMutex(); err != nil {
+		t.Errorf("profileMutex() = %v, want nil for the valid profile", err)
+	}
+	if _, err := p.profileMutex(); !errors.Is(err, ErrInvalidProfile) {
+		t.Errorf("profileMutex() = %v, want %v for the truncated profile", err, ErrInvalidProfile)
+	}
+	if got := p.invalidCount(); got != 2 {
+		t.Errorf("invalidCount() = %d, want 2", got)
+	}
+}
+
+func TestVerifyingProfiler_profileWallClock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	valid, err := newDefaultProfiler(10 * time.Millisecond).profileWallClock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockProfiler := NewMockprofiler(ctrl)
+	gomock.InOrder(
+		mockProfiler.EXPECT().profileWallClock().Return(valid, nil),
+		mockProfiler.EXPECT().profileWallClock().Return(valid[:len(valid)/2], nil),
+	)
+
+	p := newVerifyingProfiler(mockProfiler)
+	if _, err := p.profileWallClock(); err != nil {
+		t.Errorf("profileWallClock() = %v, want nil for the valid profile", err)
+	}
+	if _, err := p.profileWallClock(); !errors.Is(err, ErrInvalidProfile) {
+		t.Errorf("profileWallClock() = %v, want %v for the truncated profile", err, ErrInvalidProfile)
+	}
+	if got := p.invalidCount(); got != 1 {
+		t.Errorf("invalidCount() = %d, want 1", got)
+	}
+}